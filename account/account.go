@@ -0,0 +1,132 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package account_v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/joyent/triton-service-groups/accounts"
+	"github.com/joyent/triton-service-groups/features"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/rs/zerolog/log"
+)
+
+// Account is the TSG account of the requesting Triton user.
+type Account struct {
+	ID          string         `json:"id"`
+	AccountName string         `json:"account_name"`
+	TritonUUID  string         `json:"triton_uuid"`
+	Features    features.Flags `json:"features"`
+	// DefaultDatacenter is where groups are created when they don't set
+	// their datacenters. Empty means the datacenter of the request.
+	DefaultDatacenter string `json:"default_datacenter"`
+}
+
+// AccountInput is the body of an account update.
+type AccountInput struct {
+	DefaultDatacenter string `json:"default_datacenter"`
+}
+
+func Get(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		http.Error(w, handlers.ErrNoConnPool.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	account, err := accounts.NewStore(db).FindByID(ctx, session.AccountID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeAccount(w, account)
+}
+
+// Update changes the settings of the requesting account.
+func Update(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var input AccountInput
+	if err := json.Unmarshal(body, &input); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err := validateDefaultDatacenter(ctx, input.DefaultDatacenter); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		http.Error(w, handlers.ErrNoConnPool.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	account, err := accounts.NewStore(db).FindByID(ctx, session.AccountID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	account.DefaultDatacenter = input.DefaultDatacenter
+	if err := account.Save(ctx); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeAccount(w, account)
+}
+
+// validateDefaultDatacenter checks that a default datacenter is one groups
+// can run in. An empty default is always valid.
+func validateDefaultDatacenter(ctx context.Context, name string) error {
+	if name == "" || handlers.KnownDatacenter(ctx, name) {
+		return nil
+	}
+	return fmt.Errorf("unknown datacenter: %q", name)
+}
+
+func writeAccount(w http.ResponseWriter, account *accounts.Account) {
+	bytes, err := json.Marshal(&Account{
+		ID:                account.ID,
+		AccountName:       account.AccountName,
+		TritonUUID:        account.TritonUUID,
+		Features:          features.Resolve(account.AccountName),
+		DefaultDatacenter: account.DefaultDatacenter,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, bytes, http.StatusOK)
+}
+
+func writeJSONResponse(w http.ResponseWriter, bytes []byte, statusCode int) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(statusCode)
+	if n, err := w.Write(bytes); err != nil {
+		log.Printf("%v", err)
+	} else if n != len(bytes) {
+		log.Printf("short write: %d/%d", n, len(bytes))
+	}
+}
@@ -0,0 +1,84 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package account_v1
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/joyent/triton-service-groups/accounts"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/groups"
+	"github.com/joyent/triton-service-groups/keys"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/pkg/errors"
+)
+
+// KeyRotation is the response to rotating the key of the requesting account.
+type KeyRotation struct {
+	Fingerprint         string `json:"fingerprint"`
+	PreviousFingerprint string `json:"previous_fingerprint"`
+	// RetiredUntil is when the previous key is removed from Triton, by
+	// which time jobs still using it must have been resubmitted.
+	RetiredUntil time.Time `json:"retired_until"`
+	// FailedGroups are the names of groups whose jobs couldn't be
+	// resubmitted with the new key.
+	FailedGroups []string `json:"failed_groups,omitempty"`
+}
+
+// RotateKey replaces the key TSG uses to act as the requesting account, then
+// resubmits the jobs of the account's groups so they use the new key.
+func RotateKey(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		http.Error(w, handlers.ErrNoConnPool.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	account, err := accounts.NewStore(db).FindByID(ctx, session.AccountID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	rotation, err := session.RotateKey(ctx, account, keys.NewStore(db))
+	switch errors.Cause(err) {
+	case nil:
+	case auth.ErrNoActiveKey:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case auth.ErrRotateDevMode:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	default:
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	failed, err := groups_v1.ResubmitAccountJobs(ctx, session.AccountID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(&KeyRotation{
+		Fingerprint:         rotation.Current.Fingerprint,
+		PreviousFingerprint: rotation.Previous.Fingerprint,
+		RetiredUntil:        rotation.Previous.RetiredAt.Add(config.GetKeyGracePeriod()),
+		FailedGroups:        failed,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, bytes, http.StatusOK)
+}
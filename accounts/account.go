@@ -2,6 +2,8 @@ package accounts
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"time"
 
 	"github.com/jackc/pgx"
@@ -20,8 +22,11 @@ type Account struct {
 	AccountName string
 	TritonUUID  string
 	KeyID       string
-	CreatedAt   time.Time
-	UpdatedAt   time.Time
+	// DefaultDatacenter is where groups of the account run when created
+	// without per-datacenter capacity. Empty means the session datacenter.
+	DefaultDatacenter string
+	CreatedAt         time.Time
+	UpdatedAt         time.Time
 
 	store *Store
 }
@@ -32,6 +37,18 @@ type TritonCredential struct {
 	KeyMaterial string
 }
 
+// jobRefLen is the number of hex characters kept from the hashed Triton UUID
+// when deriving an account's job reference.
+const jobRefLen = 16
+
+// JobRef derives the stable, opaque reference for a Triton account UUID. The
+// reference is used in place of the raw UUID wherever an account must be
+// identified outside of TSG, such as within Nomad job names.
+func JobRef(tritonUUID string) string {
+	sum := sha256.Sum256([]byte(tritonUUID))
+	return hex.EncodeToString(sum[:])[:jobRefLen]
+}
+
 // New constructs a new Account with the Store for backend persistence.
 func New(store *Store) *Account {
 	return &Account{
@@ -42,26 +59,27 @@ func New(store *Store) *Account {
 // Insert inserts a new account into the tsg_accounts table.
 func (a *Account) Insert(ctx context.Context) error {
 	query := `
-INSERT INTO tsg_accounts (account_name, triton_uuid, created_at, updated_at)
-VALUES ($1, $2, NOW(), NOW());
+INSERT INTO tsg_accounts (account_name, triton_uuid, job_ref, created_at, updated_at)
+VALUES ($1, $2, $3, NOW(), NOW());
 `
 	pool := a.store.pool
 
-	tx, err := pool.Begin()
+	tx, err := pool.BeginEx(ctx, nil)
 	if err != nil {
 		return errors.Wrap(err, "failed to begin transaction")
 	}
 	defer tx.Rollback() // nolint: errcheck
 
-	_, err = pool.ExecEx(ctx, query, nil,
+	_, err = tx.ExecEx(ctx, query, nil,
 		a.AccountName,
 		a.TritonUUID,
+		a.JobRef(),
 	)
 	if err != nil {
 		return errors.Wrap(err, "failed to insert account")
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := tx.CommitEx(ctx); err != nil {
 		return errors.Wrap(err, "failed to commit transaction")
 	}
 
@@ -87,7 +105,7 @@ func (a *Account) Save(ctx context.Context) error {
 
 	pool := a.store.pool
 
-	tx, err := pool.Begin()
+	tx, err := pool.BeginEx(ctx, nil)
 	if err != nil {
 		return errors.Wrap(err, "failed to begin transaction")
 	}
@@ -95,13 +113,15 @@ func (a *Account) Save(ctx context.Context) error {
 
 	if a.KeyID == "" {
 		query := `
-UPDATE tsg_accounts SET (account_name, triton_uuid, updated_at) = ($2, $3, $4)
+UPDATE tsg_accounts SET (account_name, triton_uuid, job_ref, default_datacenter, updated_at) = ($2, $3, $4, $5, $6)
 WHERE id = $1;
 `
-		_, err := pool.ExecEx(ctx, query, nil,
+		_, err := tx.ExecEx(ctx, query, nil,
 			a.ID,
 			a.AccountName,
 			a.TritonUUID,
+			a.JobRef(),
+			a.DefaultDatacenter,
 			updatedAt,
 		)
 		if err != nil {
@@ -110,14 +130,16 @@ WHERE id = $1;
 	} else {
 
 		query := `
-UPDATE tsg_accounts SET (account_name, triton_uuid, key_id, updated_at) = ($2, $3, $4, $5)
+UPDATE tsg_accounts SET (account_name, triton_uuid, job_ref, key_id, default_datacenter, updated_at) = ($2, $3, $4, $5, $6, $7)
 WHERE id = $1;
 `
-		_, err := pool.ExecEx(ctx, query, nil,
+		_, err := tx.ExecEx(ctx, query, nil,
 			a.ID,
 			a.AccountName,
 			a.TritonUUID,
+			a.JobRef(),
 			a.KeyID,
+			a.DefaultDatacenter,
 			updatedAt,
 		)
 		if err != nil {
@@ -125,7 +147,7 @@ WHERE id = $1;
 		}
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := tx.CommitEx(ctx); err != nil {
 		return errors.Wrap(err, "failed to commit transaction")
 	}
 
@@ -134,6 +156,11 @@ WHERE id = $1;
 	return nil
 }
 
+// JobRef returns the opaque reference for this account's Triton UUID.
+func (a *Account) JobRef() string {
+	return JobRef(a.TritonUUID)
+}
+
 // Exists returns a boolean and error. True if the row exists, false if it
 // doesn't, error if there was an error executing the query.
 func (a *Account) Exists(ctx context.Context) (bool, error) {
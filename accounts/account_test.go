@@ -236,3 +236,17 @@ func TestExists(t *testing.T) {
 		assert.False(t, exists)
 	}
 }
+
+func TestJobRef(t *testing.T) {
+	tritonUUID := "87307a00-ab96-4fec-8df7-1a256e49fbcc"
+
+	ref := accounts.JobRef(tritonUUID)
+	assert.Len(t, ref, 16)
+	assert.NotContains(t, tritonUUID, ref)
+	assert.Equal(t, ref, accounts.JobRef(tritonUUID))
+	assert.NotEqual(t, ref, accounts.JobRef("f5435e8b-70b8-4e4d-8c59-1dbe5d100b5b"))
+
+	account := accounts.New(nil)
+	account.TritonUUID = tritonUUID
+	assert.Equal(t, ref, account.JobRef())
+}
@@ -26,12 +26,13 @@ func (s *Store) FindByID(ctx context.Context, accountID string) (*Account, error
 		keyID     pgtype.UUID
 		name      string
 		uuid      string
+		defaultDC string
 		createdAt pgtype.Timestamp
 		updatedAt pgtype.Timestamp
 	)
 
 	query := `
-SELECT id, account_name, triton_uuid, key_id, created_at, updated_at
+SELECT id, account_name, triton_uuid, key_id, COALESCE(default_datacenter, ''), created_at, updated_at
 FROM tsg_accounts
 WHERE id = $1 AND archived = false;
 `
@@ -40,6 +41,7 @@ WHERE id = $1 AND archived = false;
 		&name,
 		&uuid,
 		&keyID,
+		&defaultDC,
 		&createdAt,
 		&updatedAt,
 	)
@@ -52,6 +54,7 @@ WHERE id = $1 AND archived = false;
 	acct.AccountName = name
 	acct.TritonUUID = uuid
 	acct.KeyID = convert.BytesToUUID(keyID.Bytes)
+	acct.DefaultDatacenter = defaultDC
 	acct.CreatedAt = createdAt.Time
 	acct.UpdatedAt = updatedAt.Time
 
@@ -65,12 +68,13 @@ func (s *Store) FindByName(ctx context.Context, accountName string) (*Account, e
 		keyID     pgtype.UUID
 		name      string
 		uuid      string
+		defaultDC string
 		createdAt pgtype.Timestamp
 		updatedAt pgtype.Timestamp
 	)
 
 	query := `
-SELECT id, account_name, triton_uuid, key_id, created_at, updated_at
+SELECT id, account_name, triton_uuid, key_id, COALESCE(default_datacenter, ''), created_at, updated_at
 FROM tsg_accounts
 WHERE account_name = $1 AND archived = false;
 `
@@ -79,6 +83,7 @@ WHERE account_name = $1 AND archived = false;
 		&name,
 		&uuid,
 		&keyID,
+		&defaultDC,
 		&createdAt,
 		&updatedAt,
 	)
@@ -91,8 +96,97 @@ WHERE account_name = $1 AND archived = false;
 	acct.AccountName = name
 	acct.TritonUUID = uuid
 	acct.KeyID = convert.BytesToUUID(keyID.Bytes)
+	acct.DefaultDatacenter = defaultDC
 	acct.CreatedAt = createdAt.Time
 	acct.UpdatedAt = updatedAt.Time
 
 	return acct, nil
 }
+
+// FindByJobRef finds an account by the opaque job reference derived from its
+// Triton UUID.
+func (s *Store) FindByJobRef(ctx context.Context, jobRef string) (*Account, error) {
+	var (
+		id        pgtype.UUID
+		keyID     pgtype.UUID
+		name      string
+		uuid      string
+		defaultDC string
+		createdAt pgtype.Timestamp
+		updatedAt pgtype.Timestamp
+	)
+
+	query := `
+SELECT id, account_name, triton_uuid, key_id, COALESCE(default_datacenter, ''), created_at, updated_at
+FROM tsg_accounts
+WHERE job_ref = $1 AND archived = false;
+`
+	err := s.pool.QueryRowEx(ctx, query, nil, jobRef).Scan(
+		&id,
+		&name,
+		&uuid,
+		&keyID,
+		&defaultDC,
+		&createdAt,
+		&updatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	acct := New(s)
+	acct.ID = convert.BytesToUUID(id.Bytes)
+	acct.AccountName = name
+	acct.TritonUUID = uuid
+	acct.KeyID = convert.BytesToUUID(keyID.Bytes)
+	acct.DefaultDatacenter = defaultDC
+	acct.CreatedAt = createdAt.Time
+	acct.UpdatedAt = updatedAt.Time
+
+	return acct, nil
+}
+
+// BackfillJobRefs sets the job reference of every account saved before job
+// references were stored, returning the number of accounts updated. Accounts
+// without a Triton UUID are left alone.
+func (s *Store) BackfillJobRefs(ctx context.Context) (int, error) {
+	query := `
+SELECT id, triton_uuid
+FROM tsg_accounts
+WHERE (job_ref IS NULL OR job_ref = '')
+AND triton_uuid IS NOT NULL AND triton_uuid != '';
+`
+	rows, err := s.pool.QueryEx(ctx, query, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	refs := make(map[string]string)
+	for rows.Next() {
+		var (
+			id   pgtype.UUID
+			uuid string
+		)
+		if err := rows.Scan(&id, &uuid); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		refs[convert.BytesToUUID(id.Bytes)] = JobRef(uuid)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	update := `
+UPDATE tsg_accounts SET job_ref = $2
+WHERE id = $1;
+`
+	for id, ref := range refs {
+		if _, err := s.pool.ExecEx(ctx, update, nil, id, ref); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(refs), nil
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/joyent/triton-service-groups/accounts"
 	"github.com/joyent/triton-service-groups/testutils"
@@ -83,3 +84,84 @@ func TestFindByName(t *testing.T) {
 	assert.Equal(t, account.CreatedAt, found.CreatedAt)
 	assert.Equal(t, account.UpdatedAt, found.UpdatedAt)
 }
+
+func TestFindByIDCancelled(t *testing.T) {
+	if os.Getenv("TSG_TEST") == "" {
+		t.Skip("Acceptance tests skipped unless env 'TSG_TEST=1' set")
+		return
+	}
+
+	db, err := testutils.NewTestDB()
+	if err != nil {
+		t.Error(err)
+	}
+	db.Clear(t)
+	defer db.Clear(t)
+
+	store := accounts.NewStore(db.Conn)
+
+	account := accounts.New(store)
+	account.AccountName = "baconuser"
+	account.TritonUUID = "f5435e8b-70b8-4e4d-8c59-1dbe5d100b5b"
+	require.NoError(t, account.Insert(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = store.FindByID(ctx, account.ID)
+	assert.Equal(t, context.Canceled, err)
+
+	// A query still running when its context is done is aborted rather than
+	// holding on to its connection until it completes.
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	var count int
+	err = db.Conn.QueryRowEx(ctx, `SELECT count(*) FROM generate_series(1, 1000000000);`, nil).Scan(&count)
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, time.Since(start) < 5*time.Second, "query ran for %s", time.Since(start))
+
+	// The pool is left usable.
+	found, err := store.FindByID(context.Background(), account.ID)
+	require.NoError(t, err)
+	assert.Equal(t, account.ID, found.ID)
+}
+
+func TestBackfillJobRefs(t *testing.T) {
+	if os.Getenv("TSG_TEST") == "" {
+		t.Skip("Acceptance tests skipped unless env 'TSG_TEST=1' set")
+		return
+	}
+
+	db, err := testutils.NewTestDB()
+	if err != nil {
+		t.Error(err)
+	}
+	db.Clear(t)
+	defer db.Clear(t)
+
+	store := accounts.NewStore(db.Conn)
+
+	account := accounts.New(store)
+	account.AccountName = "baconuser"
+	account.TritonUUID = "f5435e8b-70b8-4e4d-8c59-1dbe5d100b5b"
+	require.NoError(t, account.Insert(context.Background()))
+
+	// Accounts saved before job references were stored have none.
+	_, err = db.Conn.Exec(`UPDATE tsg_accounts SET job_ref = NULL WHERE id = $1;`, account.ID)
+	require.NoError(t, err)
+	_, err = store.FindByJobRef(context.Background(), account.JobRef())
+	require.Error(t, err)
+
+	n, err := store.BackfillJobRefs(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	found, err := store.FindByJobRef(context.Background(), account.JobRef())
+	require.NoError(t, err)
+	assert.Equal(t, account.ID, found.ID)
+
+	n, err = store.BackfillJobRefs(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+}
@@ -8,7 +8,12 @@ import (
 	"github.com/jackc/pgx"
 	"github.com/joyent/triton-service-groups/buildtime"
 	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/groups"
+	"github.com/joyent/triton-service-groups/health"
 	"github.com/joyent/triton-service-groups/server"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/joyent/triton-service-groups/telemetry"
 	"github.com/rs/zerolog/log"
 )
 
@@ -19,6 +24,7 @@ type Agent struct {
 	config      *config.Config
 	pool        *pgx.ConnPool
 	nomad       *nomad.Client
+	datacenters handlers.Datacenters
 }
 
 func New(cfg *config.Config) *Agent {
@@ -36,6 +42,22 @@ func (a *Agent) Run(ctx context.Context) (err error) {
 
 	go a.handleSignals()
 
+	if err = telemetry.Start(); err != nil {
+		return err
+	}
+
+	if a.config.EnablePprof {
+		pprofSrv, err := startPprof(a.config.PprofAddr)
+		if err != nil {
+			return err
+		}
+		defer pprofSrv.Close()
+	}
+
+	if err = groups_v1.SetJobTemplate(a.config.JobTemplate); err != nil {
+		return err
+	}
+
 	if err = a.ensureDBPool(); err != nil {
 		return err
 	}
@@ -43,24 +65,80 @@ func (a *Agent) Run(ctx context.Context) (err error) {
 	if err = a.ensureNomadClient(); err != nil {
 		return err
 	}
+	srv := server.New(a.config.HTTPServer, a.pool, a.nomad, a.datacenters)
+	serverErr := srv.Start()
 
-	srv := server.New(a.config.HTTPServer, a.pool, a.nomad)
-	srv.Start()
+	drift := groups_v1.NewDriftDetector(a.config.Drift,
+		a.config.HTTPServer.DC, a.config.HTTPServer.TritonURL, a.pool, a.nomad)
+	go drift.Run(a.shutdownCtx)
 
-	for {
-		<-a.shutdownCtx.Done()
-		err := srv.Stop(a.shutdownCtx)
-		if err != nil {
-			log.Warn().Err(err)
-		}
+	slo := a.config.SLO
+	health.Convergences.Configure(slo.Target, slo.Timeout, slo.Window)
+	convergence := groups_v1.NewConvergenceMonitor(slo.Interval,
+		a.config.HTTPServer.TritonURL, a.pool, health.Convergences)
+	go convergence.Run(a.shutdownCtx)
+
+	budget := a.config.Budget
+	groups_v1.Budgets.Configure(budget.Daily, budget.ResetAt)
+	budgets := groups_v1.NewBudgetMonitor(budget.Interval,
+		a.config.HTTPServer.DC, a.pool, a.nomad, groups_v1.Budgets)
+	go budgets.Run(a.shutdownCtx)
+
+	refreshInterval := config.GetStatusRefreshInterval()
+	groups_v1.Statuses.Configure(refreshInterval)
+	statuses := groups_v1.NewStatusRefresher(refreshInterval,
+		a.config.HTTPServer.DC, a.pool, a.nomad, groups_v1.Statuses)
+	go statuses.Run(a.shutdownCtx)
+
+	canaries := groups_v1.NewCanaryMonitor(config.GetCanaryInterval(),
+		a.config.HTTPServer.DC, a.config.HTTPServer.TritonURL, a.pool, a.nomad, groups_v1.Canaries)
+	go canaries.Run(a.shutdownCtx)
+
+	alerts := groups_v1.NewAlertMonitor(a.config.Alerts, a.config.HTTPServer.TritonURL, a.pool)
+	go alerts.Run(a.shutdownCtx)
+
+	janitor := auth.NewKeyJanitor(config.GetKeyCleanupInterval(),
+		config.GetKeyGracePeriod(), a.config.HTTPServer.AuthURL, a.pool)
+	go janitor.Run(a.shutdownCtx)
+
+	purger := groups_v1.NewGroupPurger(config.GetGroupPurgeInterval(),
+		config.GetGroupRetention(), a.pool)
+	go purger.Run(a.shutdownCtx)
+
+	select {
+	case <-a.shutdownCtx.Done():
+		a.drain(srv)
 		return nil
+	case err := <-serverErr:
+		log.Error().Err(err).Msg("agent: HTTP server failed")
+		a.shutdown()
+		a.pool.Close()
+		return err
+	}
+}
+
+// stopper is the part of the HTTP server used to drain it.
+type stopper interface {
+	Stop(ctx context.Context) error
+}
+
+// drain stops srv and only then closes the database pool, since requests
+// still in flight while the server drains may need it.
+func (a *Agent) drain(srv stopper) {
+	// The shutdown context is already done, so draining is bounded by a
+	// context of its own.
+	stopCtx, cancel := context.WithTimeout(context.Background(), a.config.ShutdownTimeout)
+	defer cancel()
+
+	if err := srv.Stop(stopCtx); err != nil {
+		log.Warn().Err(err).Msg("agent: unable to shut down HTTP server")
 	}
+	a.pool.Close()
 }
 
 func (a *Agent) Stop() {
 	log.Info().Msgf("agent: shutting down %s agent", buildtime.PROGNAME)
 
 	a.stopSignalCh()
-	a.pool.Close()
 	a.shutdown()
 }
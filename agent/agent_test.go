@@ -0,0 +1,52 @@
+package agent
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// queryingServer stands in for an HTTP server with a request in flight which
+// queries the database while the server drains.
+type queryingServer struct {
+	agent *Agent
+	err   error
+}
+
+func (s *queryingServer) Stop(ctx context.Context) error {
+	var one int
+	s.err = s.agent.pool.QueryRowEx(ctx, `SELECT 1`, nil).Scan(&one)
+	return nil
+}
+
+func TestDrainKeepsPoolForInFlightRequests(t *testing.T) {
+	if os.Getenv("TSG_TEST") == "" {
+		t.Skip("Acceptance tests skipped unless env 'TSG_TEST=1' set")
+	}
+
+	db, err := testutils.NewTestDB()
+	require.NoError(t, err)
+
+	a := &Agent{
+		signalCh: make(chan os.Signal, 1),
+		config:   &config.Config{ShutdownTimeout: time.Second},
+		pool:     db.Conn,
+	}
+	a.shutdownCtx, a.shutdown = context.WithCancel(context.Background())
+
+	// A signal stops the agent before Run drains the server.
+	a.Stop()
+	srv := &queryingServer{agent: a}
+	a.drain(srv)
+
+	assert.NoError(t, srv.err)
+
+	_, err = a.pool.Acquire()
+	assert.Error(t, err, "expected the pool to be closed once drained")
+}
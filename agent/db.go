@@ -1,18 +1,83 @@
 package agent
 
 import (
+	"context"
+	"fmt"
+	"time"
+
 	"github.com/jackc/pgx"
+	"github.com/joyent/triton-service-groups/accounts"
+	"github.com/joyent/triton-service-groups/config"
 	"github.com/rs/zerolog/log"
 )
 
-func (a *Agent) ensureDBPool() error {
-	log.Debug().Msg("agent: connecting to database")
+// The backoff between attempts to connect to the database doubles from
+// dbInitialBackoff up to dbMaxBackoff.
+var (
+	dbInitialBackoff = 500 * time.Millisecond
+	dbMaxBackoff     = 10 * time.Second
+)
 
-	pool, err := pgx.NewConnPool(a.config.DBPool)
+func (a *Agent) ensureDBPool() error {
+	pool, err := connectDB(a.shutdownCtx, a.config.DBConnect, func() (*pgx.ConnPool, error) {
+		return pgx.NewConnPool(a.config.DBPool)
+	})
 	if err != nil {
+		log.Error().Err(err).Msg("agent: unable to connect to database")
 		return err
 	}
 	a.pool = pool
 
+	// Accounts saved before job references were stored need one for the
+	// jobs of their groups to be found by name.
+	backfilled, err := accounts.NewStore(pool).BackfillJobRefs(a.shutdownCtx)
+	if err != nil {
+		log.Error().Err(err).Msg("agent: unable to backfill account job references")
+		return err
+	}
+	if backfilled > 0 {
+		log.Info().Int("accounts", backfilled).Msg("agent: backfilled account job references")
+	}
+
 	return nil
 }
+
+// connectDB calls connect until it succeeds, backing off exponentially
+// between attempts, and gives up once the attempts or timeout of cfg are used
+// up or ctx is done.
+func connectDB(ctx context.Context, cfg config.DBConnect, connect func() (*pgx.ConnPool, error)) (*pgx.ConnPool, error) {
+	ctx, cancel := context.WithTimeout(ctx, cfg.Timeout)
+	defer cancel()
+
+	backoff := dbInitialBackoff
+	for attempt := 1; ; attempt++ {
+		log.Debug().Int("attempt", attempt).Msg("agent: connecting to database")
+
+		pool, err := connect()
+		if err == nil {
+			return pool, nil
+		}
+		if attempt >= cfg.Attempts {
+			return nil, fmt.Errorf("unable to connect to database after %d attempts: %v", attempt, err)
+		}
+
+		log.Debug().Err(err).
+			Int("attempt", attempt).
+			Dur("backoff", backoff).
+			Msg("agent: failed to connect to database, retrying")
+
+		select {
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				return nil, fmt.Errorf("unable to connect to database within %s: %v", cfg.Timeout, err)
+			}
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > dbMaxBackoff {
+			backoff = dbMaxBackoff
+		}
+	}
+}
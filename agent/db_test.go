@@ -0,0 +1,61 @@
+package agent
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDBBackoff() func() {
+	initial, max := dbInitialBackoff, dbMaxBackoff
+	dbInitialBackoff = time.Millisecond
+	dbMaxBackoff = 2 * time.Millisecond
+
+	return func() { dbInitialBackoff, dbMaxBackoff = initial, max }
+}
+
+func TestConnectDBRetries(t *testing.T) {
+	defer testDBBackoff()()
+
+	want := &pgx.ConnPool{}
+	var attempts int
+	pool, err := connectDB(context.Background(), config.DBConnect{Attempts: 5, Timeout: time.Minute}, func() (*pgx.ConnPool, error) {
+		attempts++
+		if attempts < 3 {
+			return nil, errors.New("dial tcp 127.0.0.1:26257: connect: connection refused")
+		}
+		return want, nil
+	})
+	require.NoError(t, err)
+	assert.Equal(t, want, pool)
+	assert.Equal(t, 3, attempts)
+}
+
+func TestConnectDBGivesUp(t *testing.T) {
+	defer testDBBackoff()()
+
+	var attempts int
+	_, err := connectDB(context.Background(), config.DBConnect{Attempts: 4, Timeout: time.Minute}, func() (*pgx.ConnPool, error) {
+		attempts++
+		return nil, errors.New("dial tcp 127.0.0.1:26257: connect: connection refused")
+	})
+	assert.EqualError(t, err, "unable to connect to database after 4 attempts: "+
+		"dial tcp 127.0.0.1:26257: connect: connection refused")
+	assert.Equal(t, 4, attempts)
+}
+
+func TestConnectDBTimeout(t *testing.T) {
+	defer testDBBackoff()()
+	dbInitialBackoff = time.Hour
+
+	_, err := connectDB(context.Background(), config.DBConnect{Attempts: 5, Timeout: 10 * time.Millisecond}, func() (*pgx.ConnPool, error) {
+		return nil, errors.New("connection refused")
+	})
+	assert.EqualError(t, err, "unable to connect to database within 10ms: connection refused")
+}
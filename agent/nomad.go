@@ -4,28 +4,51 @@ import (
 	"fmt"
 
 	nomad "github.com/hashicorp/nomad/api"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
 	"github.com/rs/zerolog/log"
 )
 
 func (a *Agent) ensureNomadClient() error {
 	log.Debug().Msg("agent: connecting to job scheduler")
 
+	c, err := newNomadClient(a.config.Nomad)
+	if err != nil {
+		return err
+	}
+	a.nomad = c
+
+	a.datacenters = make(handlers.Datacenters, len(a.config.Datacenters))
+	for name, dc := range a.config.Datacenters {
+		log.Debug().Msgf("agent: connecting to job scheduler in %s", name)
+
+		c, err := newNomadClient(dc.Nomad)
+		if err != nil {
+			return err
+		}
+		a.datacenters[name] = &handlers.Datacenter{
+			TritonURL:        dc.TritonURL,
+			Nomad:            c,
+			NomadNamespace:   dc.Namespace,
+			NomadRegion:      dc.Region,
+			NomadDatacenters: dc.Datacenters,
+		}
+	}
+
+	return nil
+}
+
+func newNomadClient(cfg config.Nomad) (*nomad.Client, error) {
 	nomadCfg := nomad.DefaultConfig()
 	scheme := "http"
 
-	if a.config.Nomad.TLSConfig != nil {
-		nomadCfg.TLSConfig = a.config.Nomad.TLSConfig
+	if cfg.TLSConfig != nil {
+		nomadCfg.TLSConfig = cfg.TLSConfig
 		scheme = "https"
 	}
 
 	nomadCfg.Address = fmt.Sprintf("%s://%s:%d",
-		scheme, a.config.Nomad.Addr, a.config.Nomad.Port)
-
-	c, err := nomad.NewClient(nomadCfg)
-	if err != nil {
-		return err
-	}
-	a.nomad = c
+		scheme, cfg.Addr, cfg.Port)
 
-	return nil
+	return nomad.NewClient(nomadCfg)
 }
@@ -0,0 +1,47 @@
+package agent
+
+import (
+	"net"
+	"net/http"
+	"net/http/pprof"
+
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// pprofHandler serves the standard net/http/pprof handlers under
+// /debug/pprof/. It's a mux of its own, rather than http.DefaultServeMux
+// which net/http/pprof registers with, so profiles are only served where
+// it's mounted.
+func pprofHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
+
+// startPprof listens on addr, an admin address apart from the API, and serves
+// pprof from it until the returned server is closed.
+func startPprof(addr string) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, errors.Wrapf(err, "agent: unable to listen for pprof on %q", addr)
+	}
+
+	srv := &http.Server{Addr: ln.Addr().String(), Handler: pprofHandler()}
+
+	log.Warn().
+		Str("pprof-addr", srv.Addr).
+		Msgf("agent: pprof is enabled, serving profiles without authentication at http://%s/debug/pprof/", srv.Addr)
+
+	go func() {
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			log.Error().Err(err).Msg("agent: pprof listener failed")
+		}
+	}()
+
+	return srv, nil
+}
@@ -0,0 +1,31 @@
+package agent
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartPprof(t *testing.T) {
+	srv, err := startPprof("127.0.0.1:0")
+	require.NoError(t, err)
+	defer srv.Close()
+
+	get := func(path string) int {
+		resp, err := http.Get("http://" + srv.Addr + path)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, get("/debug/pprof/"))
+	assert.Equal(t, http.StatusOK, get("/debug/pprof/goroutine?debug=1"))
+	assert.Equal(t, http.StatusOK, get("/debug/pprof/cmdline"))
+	assert.Equal(t, http.StatusNotFound, get("/v1/tsg/groups"))
+
+	_, err = startPprof(srv.Addr)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to listen for pprof")
+}
@@ -0,0 +1,34 @@
+package agent
+
+import (
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+)
+
+// reload re-reads the config on SIGHUP. Settings read as they're used, such
+// as job defaults and the Nomad retry backoff, take effect straight away and
+// the log level is applied. Everything else, such as the listen address,
+// keeps running as is until the agent restarts.
+func (a *Agent) reload() {
+	applied, restart, err := config.Reload()
+	if err != nil {
+		log.Error().Err(err).Msg("agent: unable to reload config")
+		return
+	}
+
+	level, err := config.GetLogLevel()
+	if err != nil {
+		log.Error().Err(err).Msg("agent: unable to reload log level")
+		return
+	}
+	zerolog.SetGlobalLevel(level)
+
+	for _, key := range restart {
+		log.Warn().Str("key", key).Msg("agent: config change requires restart")
+	}
+	log.Info().
+		Strs("applied", applied).
+		Strs("requires_restart", restart).
+		Msg("agent: reloaded config")
+}
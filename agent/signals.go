@@ -15,6 +15,7 @@ func (a *Agent) handleSignals() {
 	signal.Notify(a.signalCh,
 		syscall.SIGINT,
 		syscall.SIGTERM,
+		syscall.SIGHUP,
 	)
 	defer a.Stop()
 	for {
@@ -31,6 +32,12 @@ func (a *Agent) handleSignals() {
 					Msgf("agent: process received %s signal", sig)
 
 				return
+			case syscall.SIGHUP:
+				log.Debug().
+					Str("signal", sig.String()).
+					Msgf("agent: process received %s signal", sig)
+
+				a.reload()
 			default:
 				panic(fmt.Sprintf("unsupported signal: %v", sig))
 			}
@@ -0,0 +1,96 @@
+// Package audit keeps an append-only trail of the changes made to the groups
+// and templates of each account, and of their outcome.
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/rs/zerolog/log"
+)
+
+// The kinds of object whose changes are audited.
+const (
+	TargetGroup    = "group"
+	TargetTemplate = "template"
+)
+
+// The outcomes of audited changes.
+const (
+	OutcomeSuccess = "success"
+	OutcomeError   = "error"
+)
+
+// Entry is a single change made on behalf of an account.
+type Entry struct {
+	ID        string `json:"id"`
+	AccountID string `json:"account_id"`
+	// Action is what was done to the target, such as "submit" or "delete"
+	// for the job of a group.
+	Action     string `json:"action"`
+	TargetType string `json:"target_type"`
+	TargetID   string `json:"target_id,omitempty"`
+	TargetName string `json:"target_name,omitempty"`
+	// Datacenter is where the change was made, for changes to the jobs of
+	// groups which run in several.
+	Datacenter string    `json:"datacenter,omitempty"`
+	RequestID  string    `json:"request_id,omitempty"`
+	Outcome    string    `json:"outcome"`
+	Error      string    `json:"error,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// insertEntry is swapped out by tests.
+var insertEntry = func(ctx context.Context, entry *Entry) error {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return handlers.ErrNoConnPool
+	}
+	return NewStore(db).Insert(ctx, entry)
+}
+
+// Record appends an entry for a change made by the account of ctx, whose
+// outcome is err, filling in the request and datacenter it was made in.
+// Changes made by background work on behalf of an account, such as drift
+// remediation, are recorded without a request. Changes without an account
+// aren't recorded.
+//
+// Failing to record an entry is logged rather than returned, so that
+// auditing can never fail the change itself.
+func Record(ctx context.Context, entry Entry, err error) {
+	session := handlers.GetAuthSession(ctx)
+	if entry.AccountID == "" {
+		entry.AccountID = session.AccountID
+	}
+	if entry.AccountID == "" {
+		return
+	}
+	if entry.Datacenter == "" {
+		entry.Datacenter = session.Datacenter
+	}
+	entry.RequestID = handlers.GetRequestID(ctx)
+	entry.CreatedAt = time.Now().UTC()
+
+	entry.Outcome = OutcomeSuccess
+	if err != nil {
+		entry.Outcome = OutcomeError
+		entry.Error = err.Error()
+	}
+
+	// The request's own context may be done by now, such as when it timed
+	// out, but what happened must still be recorded.
+	dbCtx := context.Background()
+	if db, ok := handlers.GetDBPool(ctx); ok {
+		dbCtx = handlers.WithDBPool(dbCtx, db)
+	}
+
+	if err := insertEntry(dbCtx, &entry); err != nil {
+		log.Error().Err(err).
+			Str("account_id", entry.AccountID).
+			Str("action", entry.Action).
+			Str("target_id", entry.TargetID).
+			Str("request_id", entry.RequestID).
+			Msg("audit: failed to record entry")
+	}
+}
@@ -0,0 +1,92 @@
+package audit
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+const testAccountID = "6d6f3c38-2ed5-4b7a-a0ad-8a2caa32bbb4"
+
+// recordEntries collects the entries recorded instead of inserting them,
+// until restore is called.
+func recordEntries(insertErr error) (entries *[]Entry, restore func()) {
+	entries = &[]Entry{}
+	orig := insertEntry
+	insertEntry = func(ctx context.Context, entry *Entry) error {
+		*entries = append(*entries, *entry)
+		return insertErr
+	}
+	return entries, func() { insertEntry = orig }
+}
+
+func TestRecord(t *testing.T) {
+	entries, restore := recordEntries(nil)
+	defer restore()
+
+	ctx := handlers.WithAuthSession(context.Background(), &auth.Session{
+		AccountID:  testAccountID,
+		Datacenter: "us-sw-1",
+	})
+	ctx = handlers.WithRequestID(ctx, "2b0e3c4e-4b1c-4d0f-9d3a-5f0e1c2d3b4a")
+
+	Record(ctx, Entry{Action: "submit", TargetType: TargetGroup, TargetID: "group-id"}, nil)
+	Record(ctx, Entry{Action: "delete", TargetType: TargetGroup, TargetID: "group-id"}, errors.New("nomad is down"))
+
+	if assert.Len(t, *entries, 2) {
+		entry := (*entries)[0]
+		assert.Equal(t, testAccountID, entry.AccountID)
+		assert.Equal(t, "us-sw-1", entry.Datacenter)
+		assert.Equal(t, "2b0e3c4e-4b1c-4d0f-9d3a-5f0e1c2d3b4a", entry.RequestID)
+		assert.Equal(t, OutcomeSuccess, entry.Outcome)
+		assert.Empty(t, entry.Error)
+		assert.False(t, entry.CreatedAt.IsZero())
+
+		entry = (*entries)[1]
+		assert.Equal(t, OutcomeError, entry.Outcome)
+		assert.Equal(t, "nomad is down", entry.Error)
+	}
+}
+
+func TestRecordWithoutAccount(t *testing.T) {
+	entries, restore := recordEntries(nil)
+	defer restore()
+
+	Record(context.Background(), Entry{Action: "update", TargetType: TargetGroup}, nil)
+	assert.Empty(t, *entries)
+}
+
+func TestRecordFailure(t *testing.T) {
+	entries, restore := recordEntries(errors.New("connection refused"))
+	defer restore()
+
+	ctx := handlers.WithAuthSession(context.Background(), &auth.Session{AccountID: testAccountID})
+	assert.NotPanics(t, func() {
+		Record(ctx, Entry{Action: "create", TargetType: TargetTemplate}, nil)
+	})
+	assert.Len(t, *entries, 1)
+}
+
+func TestList(t *testing.T) {
+	ctx := handlers.WithAuthSession(context.Background(), &auth.Session{AccountID: testAccountID})
+
+	for _, test := range []struct {
+		query  string
+		status int
+	}{
+		{"?limit=-1", http.StatusBadRequest},
+		{"?offset=x", http.StatusBadRequest},
+		{"?limit=10&offset=20", http.StatusInternalServerError},
+	} {
+		r := httptest.NewRequest(http.MethodGet, "/v1/tsg/audit"+test.query, nil).WithContext(ctx)
+		w := httptest.NewRecorder()
+		List(w, r)
+		assert.Equal(t, test.status, w.Code, test.query)
+	}
+}
@@ -0,0 +1,80 @@
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/rs/zerolog/log"
+)
+
+// List serves a page of the audit log of the requesting account, optionally
+// only the entries of a target_id or action.
+func List(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	query := r.URL.Query()
+	filter := Filter{
+		TargetID: query.Get("target_id"),
+		Action:   query.Get("action"),
+	}
+
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		http.Error(w, handlers.ErrNoConnPool.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	page, err := NewStore(db).List(ctx, session.AccountID, filter, limit, offset)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(page)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(bytes); err != nil {
+		log.Printf("%v", err)
+	}
+}
+
+// parsePagination reads the optional limit and offset query parameters from
+// the request. Missing parameters are returned as zero.
+func parsePagination(r *http.Request) (int, int, error) {
+	var limit, offset int
+
+	query := r.URL.Query()
+
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("limit must be a positive integer")
+		}
+		limit = n
+	}
+
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("offset must be a positive integer")
+		}
+		offset = n
+	}
+
+	return limit, offset, nil
+}
@@ -0,0 +1,167 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx"
+	"github.com/jackc/pgx/pgtype"
+	"github.com/joyent/triton-service-groups/convert"
+)
+
+const (
+	defaultEntryLimit = 25
+	maxEntryLimit     = 100
+)
+
+// Store reads and appends the entries of the audit log. Entries are never
+// changed or removed.
+type Store struct {
+	pool *pgx.ConnPool
+}
+
+// NewStore returns a new store object.
+func NewStore(pool *pgx.ConnPool) *Store {
+	return &Store{
+		pool: pool,
+	}
+}
+
+// Insert appends an entry to the audit log, setting its ID.
+func (s *Store) Insert(ctx context.Context, entry *Entry) error {
+	var id pgtype.UUID
+
+	query := `
+INSERT INTO tsg_audit_log (account_id, action, target_type, target_id, target_name, datacenter, request_id, outcome, error, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+RETURNING id;
+`
+	err := s.pool.QueryRowEx(ctx, query, nil,
+		entry.AccountID,
+		entry.Action,
+		entry.TargetType,
+		entry.TargetID,
+		entry.TargetName,
+		entry.Datacenter,
+		entry.RequestID,
+		entry.Outcome,
+		entry.Error,
+		entry.CreatedAt,
+	).Scan(&id)
+	if err != nil {
+		return err
+	}
+
+	entry.ID = convert.BytesToUUID(id.Bytes)
+	return nil
+}
+
+// Filter narrows the entries listed to those of a single target or action.
+// Empty fields match every entry.
+type Filter struct {
+	TargetID string
+	Action   string
+}
+
+// Page is a page of the audit log of an account, newest first.
+type Page struct {
+	Total   int      `json:"total"`
+	Limit   int      `json:"limit"`
+	Offset  int      `json:"offset"`
+	Entries []*Entry `json:"entries"`
+}
+
+// List returns a page of the entries of the account matching filter.
+func (s *Store) List(ctx context.Context, accountID string, filter Filter, limit, offset int) (*Page, error) {
+	if limit <= 0 {
+		limit = defaultEntryLimit
+	}
+	if limit > maxEntryLimit {
+		limit = maxEntryLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	where := []string{"account_id = $1"}
+	args := []interface{}{accountID}
+	if filter.TargetID != "" {
+		args = append(args, filter.TargetID)
+		where = append(where, fmt.Sprintf("target_id = $%d", len(args)))
+	}
+	if filter.Action != "" {
+		args = append(args, filter.Action)
+		where = append(where, fmt.Sprintf("action = $%d", len(args)))
+	}
+	clause := strings.Join(where, " AND ")
+
+	page := &Page{
+		Limit:   limit,
+		Offset:  offset,
+		Entries: []*Entry{},
+	}
+
+	sqlStatement := `
+SELECT count(*)
+FROM tsg_audit_log
+WHERE ` + clause + `;`
+
+	err := s.pool.QueryRowEx(ctx, sqlStatement, nil, args...).Scan(&page.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	if page.Offset >= page.Total {
+		return page, nil
+	}
+
+	sqlStatement = fmt.Sprintf(`
+SELECT id, account_id, action, target_type, target_id, target_name, datacenter, request_id, outcome, error, created_at
+FROM tsg_audit_log
+WHERE %s
+ORDER BY created_at DESC, id ASC
+LIMIT $%d OFFSET $%d;`, clause, len(args)+1, len(args)+2)
+
+	rows, err := s.pool.QueryEx(ctx, sqlStatement, nil, append(args, page.Limit, page.Offset)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			entry     Entry
+			id        pgtype.UUID
+			accountID pgtype.UUID
+			createdAt pgtype.Timestamp
+		)
+		err := rows.Scan(
+			&id,
+			&accountID,
+			&entry.Action,
+			&entry.TargetType,
+			&entry.TargetID,
+			&entry.TargetName,
+			&entry.Datacenter,
+			&entry.RequestID,
+			&entry.Outcome,
+			&entry.Error,
+			&createdAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		entry.ID = convert.BytesToUUID(id.Bytes)
+		entry.AccountID = convert.BytesToUUID(accountID.Bytes)
+		entry.CreatedAt = createdAt.Time
+		page.Entries = append(page.Entries, &entry)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
@@ -0,0 +1,353 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package bundles_v1
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/joyent/triton-service-groups/groups"
+	"github.com/joyent/triton-service-groups/messages"
+	"github.com/joyent/triton-service-groups/names"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// BundleVersion is the version of the bundle format produced by ExportBundle.
+	// Version 2 holds every revision of each template rather than only the
+	// latest, so that groups pinned to an earlier revision keep it. Bundles of
+	// version 1 can still be imported.
+	BundleVersion = 2
+
+	signatureAlgorithm = "hmac-sha256"
+)
+
+var (
+	ErrNoSigningKey     = messages.New(messages.NoSigningKey)
+	ErrInvalidSignature = errors.New("bundle signature is invalid")
+)
+
+// Bundle is the complete configuration of an account. Credentials are never
+// included.
+//
+// NOTE: Templates and groups are currently everything an account configures.
+// Anything added later must bump BundleVersion.
+//
+// Templates holds every revision of each template, oldest first, which the
+// groups refer to by the ID of the revision they're pinned to.
+type Bundle struct {
+	Version    int                              `json:"version"`
+	ExportedAt time.Time                        `json:"exported_at"`
+	Templates  []*templates_v1.InstanceTemplate `json:"templates"`
+	Groups     []*groups_v1.ServiceGroup        `json:"groups"`
+}
+
+// SignedBundle is a bundle along with the signature used to detect tampering
+// between export and import.
+type SignedBundle struct {
+	Bundle    json.RawMessage `json:"bundle"`
+	Algorithm string          `json:"algorithm"`
+	Signature string          `json:"signature"`
+}
+
+// Conflict is an object in a bundle which can't be imported into the target
+// account.
+type Conflict struct {
+	Kind   string `json:"kind"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// ImportResult is the outcome of importing a bundle. Templates and Groups
+// count what was imported, and left in the account if the import failed
+// partway and was rolled back.
+type ImportResult struct {
+	DryRun     bool        `json:"dry_run"`
+	Templates  int         `json:"templates"`
+	Groups     int         `json:"groups"`
+	Conflicts  []*Conflict `json:"conflicts"`
+	RolledBack bool        `json:"rolled_back"`
+	Error      string      `json:"error,omitempty"`
+}
+
+// Store reads and recreates the configuration of a single account.
+type Store interface {
+	// ListTemplates returns every revision of every template, those of each
+	// template oldest first.
+	ListTemplates(ctx context.Context) ([]*templates_v1.InstanceTemplate, error)
+	ListGroups(ctx context.Context) ([]*groups_v1.ServiceGroup, error)
+	// CreateTemplate saves a new template, or a revision of the template whose
+	// latest revision has the ID previousID if it's set, and returns its ID.
+	CreateTemplate(ctx context.Context, template *templates_v1.InstanceTemplate, previousID string) (string, error)
+	// CreateGroup saves a new group and returns its ID. A group which can't
+	// be created entirely isn't left behind.
+	CreateGroup(ctx context.Context, group *groups_v1.ServiceGroup) (string, error)
+	// RemoveTemplate removes every revision of the template which the
+	// revision with the given ID belongs to.
+	RemoveTemplate(ctx context.Context, id string) error
+	// RemoveGroup removes the group with the given ID along with its job.
+	RemoveGroup(ctx context.Context, id string) error
+}
+
+// ExportBundle reads the complete configuration of an account and signs it with key.
+func ExportBundle(ctx context.Context, store Store, key []byte) (*SignedBundle, error) {
+	templates, err := store.ListTemplates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := store.ListGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle := &Bundle{
+		Version:    BundleVersion,
+		ExportedAt: time.Now().UTC(),
+		Templates:  templates,
+		Groups:     groups,
+	}
+	for _, group := range bundle.Groups {
+		group.Account = nil
+	}
+
+	return Sign(bundle, key)
+}
+
+// Sign encodes and signs bundle with key.
+func Sign(bundle *Bundle, key []byte) (*SignedBundle, error) {
+	if len(key) == 0 {
+		return nil, ErrNoSigningKey
+	}
+
+	raw, err := json.Marshal(bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	return &SignedBundle{
+		Bundle:    raw,
+		Algorithm: signatureAlgorithm,
+		Signature: sign(raw, key),
+	}, nil
+}
+
+// Verify checks the signature of a signed bundle against key and decodes it.
+func Verify(signed *SignedBundle, key []byte) (*Bundle, error) {
+	if len(key) == 0 {
+		return nil, ErrNoSigningKey
+	}
+
+	if signed.Algorithm != signatureAlgorithm {
+		return nil, fmt.Errorf("unsupported bundle signature algorithm: %q", signed.Algorithm)
+	}
+
+	expected := sign(signed.Bundle, key)
+	if !hmac.Equal([]byte(expected), []byte(signed.Signature)) {
+		return nil, ErrInvalidSignature
+	}
+
+	var bundle Bundle
+	if err := json.Unmarshal(signed.Bundle, &bundle); err != nil {
+		return nil, fmt.Errorf("unable to decode bundle: %v", err)
+	}
+
+	if bundle.Version < 1 || bundle.Version > BundleVersion {
+		return nil, fmt.Errorf("unsupported bundle version: %d", bundle.Version)
+	}
+
+	return &bundle, nil
+}
+
+func sign(raw []byte, key []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(raw) // nolint: errcheck
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ImportBundle recreates the contents of bundle within the account behind store.
+// Nothing is created if any object conflicts with the target account, and
+// nothing is created at all when dryRun is set.
+//
+// Groups have their jobs submitted as they're created, which no database
+// transaction could undo, so an import that fails partway removes the objects
+// it already created instead. The result then counts whatever couldn't be
+// removed, so that a retry isn't blocked by conflicts with a partial import.
+func ImportBundle(ctx context.Context, store Store, bundle *Bundle, dryRun bool) (*ImportResult, error) {
+	conflicts, err := findConflicts(ctx, store, bundle)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ImportResult{
+		DryRun:    dryRun,
+		Conflicts: conflicts,
+	}
+	if dryRun {
+		result.Templates = len(bundle.Templates)
+		result.Groups = len(bundle.Groups)
+		return result, nil
+	}
+	if len(conflicts) > 0 {
+		return result, nil
+	}
+
+	// template IDs are assigned by the target, so groups are pointed at the
+	// recreated revisions of their templates, each revision recreated as the
+	// one following the template's previous revision
+	revisions := make([]*templates_v1.InstanceTemplate, len(bundle.Templates))
+	copy(revisions, bundle.Templates)
+	sort.SliceStable(revisions, func(i, j int) bool {
+		if revisions[i].TemplateName != revisions[j].TemplateName {
+			return revisions[i].TemplateName < revisions[j].TemplateName
+		}
+		return revisions[i].Version < revisions[j].Version
+	})
+
+	templateIDs := make(map[string]string, len(bundle.Templates))
+	latest := make(map[string]string)
+	var created []string
+	for _, template := range revisions {
+		t := *template
+		t.ID = ""
+
+		id, err := store.CreateTemplate(ctx, &t, latest[template.TemplateName])
+		if err != nil {
+			err = fmt.Errorf("unable to import template %q: %v", template.TemplateName, err)
+			return rollback(ctx, store, result, revisions, created, latest, nil, err)
+		}
+		if _, ok := latest[template.TemplateName]; !ok {
+			created = append(created, template.TemplateName)
+		}
+		templateIDs[template.ID] = id
+		latest[template.TemplateName] = id
+		result.Templates++
+	}
+
+	var groupIDs []string
+	for _, group := range bundle.Groups {
+		g := *group
+		g.ID = ""
+		g.TemplateID = templateIDs[group.TemplateID]
+
+		id, err := store.CreateGroup(ctx, &g)
+		if err != nil {
+			err = fmt.Errorf("unable to import group %q: %v", group.GroupName, err)
+			return rollback(ctx, store, result, revisions, created, latest, groupIDs, err)
+		}
+		groupIDs = append(groupIDs, id)
+		result.Groups++
+	}
+
+	return result, nil
+}
+
+// rollback removes the groups and templates created by a failed import, most
+// recent first, and returns the result along with the error that failed it.
+// The templates are those named by templateNames, each removed through the ID
+// of its latest revision in latest.
+func rollback(ctx context.Context, store Store, result *ImportResult, revisions []*templates_v1.InstanceTemplate, templateNames []string, latest map[string]string, groupIDs []string, cause error) (*ImportResult, error) {
+	result.RolledBack = true
+
+	// the groups are removed first, as they use the templates
+	for i := len(groupIDs) - 1; i >= 0; i-- {
+		if err := store.RemoveGroup(ctx, groupIDs[i]); err != nil {
+			log.Printf("unable to roll back imported group %s: %v", groupIDs[i], err)
+			continue
+		}
+		result.Groups--
+	}
+
+	imported := revisions[:result.Templates]
+	for i := len(templateNames) - 1; i >= 0; i-- {
+		name := templateNames[i]
+		if err := store.RemoveTemplate(ctx, latest[name]); err != nil {
+			log.Printf("unable to roll back imported template %q: %v", name, err)
+			continue
+		}
+
+		// every imported revision of the template is removed along with it
+		for _, revision := range imported {
+			if revision.TemplateName == name {
+				result.Templates--
+			}
+		}
+	}
+
+	return result, cause
+}
+
+func findConflicts(ctx context.Context, store Store, bundle *Bundle) ([]*Conflict, error) {
+	existingTemplates, err := store.ListTemplates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	existingGroups, err := store.ListGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	conflicts := []*Conflict{}
+
+	templateNames := map[string]bool{}
+	for _, t := range existingTemplates {
+		templateNames[t.TemplateName] = true
+	}
+
+	// The revisions of a template share its name, which only conflicts with
+	// the account's templates.
+	templateIDs := map[string]bool{}
+	bundleNames := map[string]bool{}
+	for _, t := range bundle.Templates {
+		templateIDs[t.ID] = true
+
+		if err := names.Validate("template", t.TemplateName); err != nil {
+			conflicts = append(conflicts, &Conflict{"template", t.TemplateName, err.Error()})
+			continue
+		}
+		if err := templates_v1.CheckRequiredTags(t); err != nil {
+			conflicts = append(conflicts, &Conflict{"template", t.TemplateName, err.Error()})
+			continue
+		}
+		if bundleNames[t.TemplateName] {
+			continue
+		}
+		if templateNames[t.TemplateName] {
+			conflicts = append(conflicts, &Conflict{"template", t.TemplateName, "a template with this name already exists"})
+		}
+		bundleNames[t.TemplateName] = true
+	}
+
+	groupNames := map[string]bool{}
+	for _, g := range existingGroups {
+		groupNames[g.GroupName] = true
+	}
+
+	for _, g := range bundle.Groups {
+		if err := names.Validate("group", g.GroupName); err != nil {
+			conflicts = append(conflicts, &Conflict{"group", g.GroupName, err.Error()})
+			continue
+		}
+		if groupNames[g.GroupName] {
+			conflicts = append(conflicts, &Conflict{"group", g.GroupName, "a group with this name already exists"})
+		}
+		if !templateIDs[g.TemplateID] {
+			conflicts = append(conflicts, &Conflict{"group", g.GroupName, "the group's template is not in the bundle"})
+		}
+		groupNames[g.GroupName] = true
+	}
+
+	return conflicts, nil
+}
@@ -0,0 +1,384 @@
+package bundles_v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/groups"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testKey = []byte("test-signing-key")
+
+type memStore struct {
+	templates []*templates_v1.InstanceTemplate
+	groups    []*groups_v1.ServiceGroup
+
+	// failGroup is the name of a group which can't be created.
+	failGroup string
+}
+
+func (s *memStore) ListTemplates(ctx context.Context) ([]*templates_v1.InstanceTemplate, error) {
+	var result []*templates_v1.InstanceTemplate
+	for _, t := range s.templates {
+		copy := *t
+		result = append(result, &copy)
+	}
+	return result, nil
+}
+
+func (s *memStore) ListGroups(ctx context.Context) ([]*groups_v1.ServiceGroup, error) {
+	var result []*groups_v1.ServiceGroup
+	for _, g := range s.groups {
+		copy := *g
+		result = append(result, &copy)
+	}
+	return result, nil
+}
+
+func (s *memStore) CreateTemplate(ctx context.Context, template *templates_v1.InstanceTemplate, previousID string) (string, error) {
+	t := *template
+	t.ID = fmt.Sprintf("template-%d", len(s.templates)+1)
+	for _, previous := range s.templates {
+		if previous.ID == previousID {
+			previous.Superseded = true
+			t.Version = previous.Version + 1
+		}
+	}
+	s.templates = append(s.templates, &t)
+	return t.ID, nil
+}
+
+func (s *memStore) CreateGroup(ctx context.Context, group *groups_v1.ServiceGroup) (string, error) {
+	if group.GroupName == s.failGroup {
+		return "", fmt.Errorf("unable to submit job")
+	}
+
+	g := *group
+	g.ID = fmt.Sprintf("group-%d", len(s.groups)+1)
+	s.groups = append(s.groups, &g)
+	return g.ID, nil
+}
+
+func (s *memStore) RemoveTemplate(ctx context.Context, id string) error {
+	var name string
+	for _, t := range s.templates {
+		if t.ID == id {
+			name = t.TemplateName
+		}
+	}
+
+	var kept []*templates_v1.InstanceTemplate
+	for _, t := range s.templates {
+		if t.TemplateName != name {
+			kept = append(kept, t)
+		}
+	}
+	s.templates = kept
+	return nil
+}
+
+func (s *memStore) RemoveGroup(ctx context.Context, id string) error {
+	var kept []*groups_v1.ServiceGroup
+	for _, g := range s.groups {
+		if g.ID != id {
+			kept = append(kept, g)
+		}
+	}
+	s.groups = kept
+	return nil
+}
+
+func newSourceStore() *memStore {
+	return &memStore{
+		templates: []*templates_v1.InstanceTemplate{
+			{
+				ID:           "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+				TemplateName: "web",
+				Package:      "g4-highcpu-512M",
+				ImageID:      "49b22aec-0c8a-11e6-8807-a3eb4db576ba",
+				Networks:     []string{"f7ed95d3-faaf-43ef-9346-15644403b963"},
+				MetaData:     map[string]string{"role": "web"},
+				Tags:         map[string]string{"env": "prod"},
+			},
+		},
+		groups: []*groups_v1.ServiceGroup{
+			{
+				ID:         "722d25ed-f32a-4944-9861-8990e204850e",
+				GroupName:  "web-group",
+				TemplateID: "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+				Capacity:   3,
+			},
+		},
+	}
+}
+
+func roundTrip(t *testing.T, signed *SignedBundle) *SignedBundle {
+	bytes, err := json.Marshal(signed)
+	require.NoError(t, err)
+
+	var decoded SignedBundle
+	require.NoError(t, json.Unmarshal(bytes, &decoded))
+	return &decoded
+}
+
+func TestExportImportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	source := newSourceStore()
+
+	signed, err := ExportBundle(ctx, source, testKey)
+	require.NoError(t, err)
+
+	bundle, err := Verify(roundTrip(t, signed), testKey)
+	require.NoError(t, err)
+	assert.Equal(t, BundleVersion, bundle.Version)
+
+	target := &memStore{}
+	result, err := ImportBundle(ctx, target, bundle, false)
+	require.NoError(t, err)
+	assert.Empty(t, result.Conflicts)
+	assert.Equal(t, 1, result.Templates)
+	assert.Equal(t, 1, result.Groups)
+
+	require.Len(t, target.templates, 1)
+	require.Len(t, target.groups, 1)
+
+	template := target.templates[0]
+	expected := *source.templates[0]
+	expected.ID = template.ID
+	assert.Equal(t, &expected, template)
+
+	group := target.groups[0]
+	assert.Equal(t, "web-group", group.GroupName)
+	assert.Equal(t, 3, group.Capacity)
+	assert.Equal(t, template.ID, group.TemplateID)
+
+	exported, err := ExportBundle(ctx, target, testKey)
+	require.NoError(t, err)
+	again, err := Verify(exported, testKey)
+	require.NoError(t, err)
+	assert.Len(t, again.Templates, 1)
+	assert.Len(t, again.Groups, 1)
+}
+
+func TestImportRollsBackPartialImport(t *testing.T) {
+	ctx := context.Background()
+
+	source := newSourceStore()
+	source.groups = append(source.groups, &groups_v1.ServiceGroup{
+		ID:         "d9a1c0f2-8b7e-4c43-9f0b-3c1c0c7e9a11",
+		GroupName:  "web-canary",
+		TemplateID: source.templates[0].ID,
+		Capacity:   1,
+	})
+
+	signed, err := ExportBundle(ctx, source, testKey)
+	require.NoError(t, err)
+	bundle, err := Verify(roundTrip(t, signed), testKey)
+	require.NoError(t, err)
+
+	target := &memStore{failGroup: "web-canary"}
+	result, err := ImportBundle(ctx, target, bundle, false)
+	require.Error(t, err)
+	assert.True(t, result.RolledBack)
+	assert.Equal(t, 0, result.Templates)
+	assert.Equal(t, 0, result.Groups)
+	assert.Empty(t, target.templates)
+	assert.Empty(t, target.groups)
+
+	// Nothing is left behind to conflict with a retry.
+	target.failGroup = ""
+	result, err = ImportBundle(ctx, target, bundle, false)
+	require.NoError(t, err)
+	assert.Empty(t, result.Conflicts)
+	assert.False(t, result.RolledBack)
+	assert.Equal(t, 1, result.Templates)
+	assert.Equal(t, 2, result.Groups)
+}
+
+func TestExportImportPinnedRevision(t *testing.T) {
+	ctx := context.Background()
+
+	source := newSourceStore()
+	first := source.templates[0]
+	first.Version = 1
+	first.Superseded = true
+	latest := *first
+	latest.ID = "5b3f4a0e-36e7-4b0c-a5b8-7a3c2f2e2b4d"
+	latest.ImageID = "9a1e0d8c-0c8a-11e6-8807-a3eb4db576ba"
+	latest.Version = 2
+	latest.Superseded = false
+	source.templates = append(source.templates, &latest)
+
+	// web-group stays pinned to the first revision of the template.
+	source.groups = append(source.groups, &groups_v1.ServiceGroup{
+		ID:         "d9a1c0f2-8b7e-4c43-9f0b-3c1c0c7e9a11",
+		GroupName:  "web-latest",
+		TemplateID: latest.ID,
+		Capacity:   1,
+	})
+
+	signed, err := ExportBundle(ctx, source, testKey)
+	require.NoError(t, err)
+	bundle, err := Verify(roundTrip(t, signed), testKey)
+	require.NoError(t, err)
+	require.Len(t, bundle.Templates, 2)
+
+	target := &memStore{}
+	result, err := ImportBundle(ctx, target, bundle, false)
+	require.NoError(t, err)
+	assert.Empty(t, result.Conflicts)
+	assert.Equal(t, 2, result.Templates)
+	assert.Equal(t, 2, result.Groups)
+
+	require.Len(t, target.templates, 2)
+	pinned, revised := target.templates[0], target.templates[1]
+	assert.Equal(t, first.ImageID, pinned.ImageID)
+	assert.True(t, pinned.Superseded)
+	assert.Equal(t, latest.ImageID, revised.ImageID)
+	assert.Equal(t, 2, revised.Version)
+	assert.False(t, revised.Superseded)
+
+	require.Len(t, target.groups, 2)
+	assert.Equal(t, pinned.ID, target.groups[0].TemplateID)
+	assert.Equal(t, revised.ID, target.groups[1].TemplateID)
+}
+
+func TestExportImportPausedGroup(t *testing.T) {
+	ctx := context.Background()
+	source := newSourceStore()
+	source.groups[0].Paused = true
+
+	signed, err := ExportBundle(ctx, source, testKey)
+	require.NoError(t, err)
+
+	bundle, err := Verify(roundTrip(t, signed), testKey)
+	require.NoError(t, err)
+
+	target := &memStore{}
+	_, err = ImportBundle(ctx, target, bundle, false)
+	require.NoError(t, err)
+
+	require.Len(t, target.groups, 1)
+	assert.True(t, target.groups[0].Paused, "the group should be imported paused")
+}
+
+func TestVerify(t *testing.T) {
+	signed, err := ExportBundle(context.Background(), newSourceStore(), testKey)
+	require.NoError(t, err)
+
+	t.Run("wrong key", func(t *testing.T) {
+		_, err := Verify(signed, []byte("another-key"))
+		assert.Equal(t, ErrInvalidSignature, err)
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		var bundle Bundle
+		require.NoError(t, json.Unmarshal(signed.Bundle, &bundle))
+		bundle.Groups[0].Capacity = 100
+
+		raw, err := json.Marshal(bundle)
+		require.NoError(t, err)
+
+		tampered := *signed
+		tampered.Bundle = raw
+		_, err = Verify(&tampered, testKey)
+		assert.Equal(t, ErrInvalidSignature, err)
+	})
+
+	t.Run("no key", func(t *testing.T) {
+		_, err := Verify(signed, nil)
+		assert.Equal(t, ErrNoSigningKey, err)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		resigned, err := Sign(&Bundle{Version: BundleVersion + 1}, testKey)
+		require.NoError(t, err)
+
+		_, err = Verify(resigned, testKey)
+		assert.EqualError(t, err, fmt.Sprintf("unsupported bundle version: %d", BundleVersion+1))
+	})
+
+	t.Run("previous version", func(t *testing.T) {
+		resigned, err := Sign(&Bundle{Version: 1}, testKey)
+		require.NoError(t, err)
+
+		bundle, err := Verify(resigned, testKey)
+		require.NoError(t, err)
+		assert.Equal(t, 1, bundle.Version)
+	})
+}
+
+func TestImportConflicts(t *testing.T) {
+	ctx := context.Background()
+
+	signed, err := ExportBundle(ctx, newSourceStore(), testKey)
+	require.NoError(t, err)
+	bundle, err := Verify(signed, testKey)
+	require.NoError(t, err)
+
+	target := &memStore{
+		groups: []*groups_v1.ServiceGroup{
+			{ID: "existing", GroupName: "web-group", TemplateID: "other"},
+		},
+	}
+
+	result, err := ImportBundle(ctx, target, bundle, true)
+	require.NoError(t, err)
+	assert.True(t, result.DryRun)
+	assert.Equal(t, []*Conflict{
+		{"group", "web-group", "a group with this name already exists"},
+	}, result.Conflicts)
+	assert.Len(t, target.templates, 0)
+
+	result, err = ImportBundle(ctx, target, bundle, false)
+	require.NoError(t, err)
+	assert.Len(t, result.Conflicts, 1)
+	assert.Equal(t, 0, result.Templates)
+	assert.Len(t, target.templates, 0)
+	assert.Len(t, target.groups, 1)
+}
+
+func TestImportRequiredTags(t *testing.T) {
+	defer viper.Reset()
+	ctx := context.Background()
+
+	signed, err := ExportBundle(ctx, newSourceStore(), testKey)
+	require.NoError(t, err)
+	bundle, err := Verify(signed, testKey)
+	require.NoError(t, err)
+
+	viper.Set(config.KeyTagsRequired, []string{"owner"})
+
+	target := &memStore{}
+	result, err := ImportBundle(ctx, target, bundle, false)
+	require.NoError(t, err)
+	assert.Equal(t, []*Conflict{
+		{"template", "web", "template is missing required tags: owner"},
+	}, result.Conflicts)
+	assert.Len(t, target.templates, 0)
+}
+
+func TestImportDryRun(t *testing.T) {
+	ctx := context.Background()
+
+	signed, err := ExportBundle(ctx, newSourceStore(), testKey)
+	require.NoError(t, err)
+	bundle, err := Verify(signed, testKey)
+	require.NoError(t, err)
+
+	target := &memStore{}
+	result, err := ImportBundle(ctx, target, bundle, true)
+	require.NoError(t, err)
+	assert.Empty(t, result.Conflicts)
+	assert.Equal(t, 1, result.Templates)
+	assert.Equal(t, 1, result.Groups)
+	assert.Len(t, target.templates, 0)
+	assert.Len(t, target.groups, 0)
+}
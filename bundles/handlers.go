@@ -0,0 +1,120 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package bundles_v1
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/messages"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/rs/zerolog/log"
+)
+
+func Export(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	key := config.GetExportSigningKey()
+	if key == nil {
+		messages.Write(w, r, ErrNoSigningKey, http.StatusNotImplemented)
+		return
+	}
+
+	signed, err := ExportBundle(ctx, &accountStore{session.AccountID}, key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(signed)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, bytes, http.StatusOK)
+}
+
+func Import(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	key := config.GetExportSigningKey()
+	if key == nil {
+		messages.Write(w, r, ErrNoSigningKey, http.StatusNotImplemented)
+		return
+	}
+
+	var dryRun bool
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "dry_run must be a boolean", http.StatusBadRequest)
+			return
+		}
+		dryRun = b
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var signed SignedBundle
+	if err := json.Unmarshal(body, &signed); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	bundle, err := Verify(&signed, key)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	result, err := ImportBundle(ctx, &accountStore{session.AccountID}, bundle, dryRun)
+	if err != nil && result == nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err != nil {
+		// a failed import reports what was left of it after rolling back
+		result.Error = err.Error()
+	}
+
+	bytes, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	switch {
+	case result.RolledBack:
+		status = http.StatusInternalServerError
+	case len(result.Conflicts) > 0 && !dryRun:
+		status = http.StatusConflict
+	case !dryRun:
+		status = http.StatusCreated
+	}
+
+	writeJSONResponse(w, bytes, status)
+}
+
+func writeJSONResponse(w http.ResponseWriter, bytes []byte, statusCode int) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(statusCode)
+	if n, err := w.Write(bytes); err != nil {
+		log.Printf("%v", err)
+	} else if n != len(bytes) {
+		log.Printf("short write: %d/%d", n, len(bytes))
+	}
+}
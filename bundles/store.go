@@ -0,0 +1,105 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package bundles_v1
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/joyent/triton-service-groups/groups"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/rs/zerolog/log"
+)
+
+// accountStore is the Store of a single account backed by the database.
+// Imported groups have their orchestrator job submitted as they would when
+// created through the API, other than paused groups, whose job is registered
+// with its reconciles paused.
+type accountStore struct {
+	accountID string
+}
+
+func (s *accountStore) ListTemplates(ctx context.Context) ([]*templates_v1.InstanceTemplate, error) {
+	return templates_v1.FindTemplateRevisions(ctx, s.accountID)
+}
+
+func (s *accountStore) ListGroups(ctx context.Context) ([]*groups_v1.ServiceGroup, error) {
+	return groups_v1.FindGroups(ctx, s.accountID)
+}
+
+func (s *accountStore) CreateTemplate(ctx context.Context, template *templates_v1.InstanceTemplate, previousID string) (string, error) {
+	if previousID == "" {
+		if err := templates_v1.SaveTemplate(ctx, s.accountID, template); err != nil {
+			return "", err
+		}
+	} else {
+		current, ok := templates_v1.FindTemplateByID(ctx, previousID, s.accountID)
+		if !ok {
+			return "", fmt.Errorf("unable to find the previous revision of template %q", template.TemplateName)
+		}
+		if err := templates_v1.ReviseTemplate(ctx, s.accountID, current, template); err != nil {
+			return "", err
+		}
+	}
+
+	t, ok := templates_v1.FindTemplateByName(ctx, template.TemplateName, s.accountID)
+	if !ok {
+		return "", fmt.Errorf("unable to find template %q after saving", template.TemplateName)
+	}
+
+	return t.ID, nil
+}
+
+func (s *accountStore) CreateGroup(ctx context.Context, group *groups_v1.ServiceGroup) (string, error) {
+	if err := groups_v1.SaveGroup(ctx, s.accountID, group); err != nil {
+		return "", err
+	}
+
+	g, ok := groups_v1.FindGroupByName(ctx, group.GroupName, s.accountID)
+	if !ok {
+		return "", fmt.Errorf("unable to find group %q after saving", group.GroupName)
+	}
+
+	if _, err := submitGroupJob(ctx, g); err != nil {
+		// the job may have been registered in some of the group's
+		// datacenters before failing in another
+		if err := s.removeGroup(ctx, g); err != nil {
+			log.Printf("unable to remove group %s after failing to submit its job: %v", g.ID, err)
+		}
+		return "", err
+	}
+
+	return g.ID, nil
+}
+
+func (s *accountStore) RemoveTemplate(ctx context.Context, id string) error {
+	return templates_v1.RemoveTemplate(ctx, id, s.accountID)
+}
+
+func (s *accountStore) RemoveGroup(ctx context.Context, id string) error {
+	g, ok := groups_v1.FindGroupByID(ctx, id, s.accountID)
+	if !ok {
+		return fmt.Errorf("unable to find group %s", id)
+	}
+
+	return s.removeGroup(ctx, g)
+}
+
+// removeGroup deletes the group before its job, as the API does, so that the
+// group is gone even if its job can't be stopped.
+func (s *accountStore) removeGroup(ctx context.Context, group *groups_v1.ServiceGroup) error {
+	if err := groups_v1.RemoveGroup(ctx, group.ID, s.accountID); err != nil {
+		return err
+	}
+
+	return deleteGroupJob(ctx, group)
+}
+
+// submitGroupJob and deleteGroupJob are swapped out by tests.
+var (
+	submitGroupJob = groups_v1.SubmitOrchestratorJob
+	deleteGroupJob = groups_v1.DeleteOrchestratorJob
+)
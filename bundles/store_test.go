@@ -0,0 +1,81 @@
+package bundles_v1
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/accounts"
+	"github.com/joyent/triton-service-groups/groups"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/joyent/triton-service-groups/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccountStorePausedGroupRoundTrip(t *testing.T) {
+	if os.Getenv("TSG_TEST") == "" {
+		t.Skip("Acceptance tests skipped unless env 'TSG_TEST=1' set")
+		return
+	}
+
+	db, err := testutils.NewTestDB()
+	if err != nil {
+		t.Error(err)
+	}
+	db.Clear(t)
+	defer db.Clear(t)
+
+	ctx := handlers.WithDBPool(context.Background(), db.Conn)
+
+	newAccount := func(name, tritonUUID string) *accounts.Account {
+		account := accounts.New(accounts.NewStore(db.Conn))
+		account.AccountName = name
+		account.TritonUUID = tritonUUID
+		require.NoError(t, account.Insert(ctx))
+		return account
+	}
+	source := newAccount("baconuser", "f5435e8b-70b8-4e4d-8c59-1dbe5d100b5b")
+	target := newAccount("eggsuser", "0c7d3a36-94a1-4c5f-a0c4-3f5b8e1d2a91")
+
+	require.NoError(t, templates_v1.SaveTemplate(ctx, source.ID, &templates_v1.InstanceTemplate{
+		TemplateName: "web",
+		Package:      "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+		ImageID:      "342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+	}))
+	tmpl, ok := templates_v1.FindTemplateByName(ctx, "web", source.ID)
+	require.True(t, ok)
+	require.NoError(t, groups_v1.SaveGroup(ctx, source.ID, &groups_v1.ServiceGroup{
+		GroupName:  "web",
+		TemplateID: tmpl.ID,
+		Capacity:   2,
+		Paused:     true,
+	}))
+
+	defer func(f func(ctx context.Context, group *groups_v1.ServiceGroup) ([]*groups_v1.JobSubmission, error)) {
+		submitGroupJob = f
+	}(submitGroupJob)
+	var submitted []*groups_v1.ServiceGroup
+	submitGroupJob = func(ctx context.Context, group *groups_v1.ServiceGroup) ([]*groups_v1.JobSubmission, error) {
+		submitted = append(submitted, group)
+		return nil, nil
+	}
+
+	signed, err := ExportBundle(ctx, &accountStore{accountID: source.ID}, testKey)
+	require.NoError(t, err)
+	bundle, err := Verify(roundTrip(t, signed), testKey)
+	require.NoError(t, err)
+
+	result, err := ImportBundle(ctx, &accountStore{accountID: target.ID}, bundle, false)
+	require.NoError(t, err)
+	assert.Equal(t, 1, result.Groups)
+
+	group, ok := groups_v1.FindGroupByName(ctx, "web", target.ID)
+	require.True(t, ok)
+	assert.True(t, group.Paused, "the group should be imported paused")
+
+	// Its job is registered paused, as by PauseServiceGroup.
+	require.Len(t, submitted, 1)
+	assert.True(t, submitted[0].Paused)
+}
@@ -26,7 +26,10 @@ default (Go standard library) in order to follow HTTP/1.1.
 
 Agent will continue to run in the foreground until an interrupt signal has been
 received. Sending SIGINT or SIGTERM will drain/shutdown all HTTP connections
-gracefully, while performing a SIGKILL will not.
+gracefully, while performing a SIGKILL will not. Sending SIGHUP reloads the
+config file, applying the log level and every setting read as it's used, such
+as job defaults, while those of listeners, connections and background monitors
+are logged as requiring a restart.
 
 `, buildtime.PROGNAME),
 
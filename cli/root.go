@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"io"
 	stdlog "log"
-	"net/http"
-	_ "net/http/pprof"
 	"os"
 	"strings"
 
@@ -39,6 +37,12 @@ access, and optionally enabling introspective utilities like gops(1) and pprof.
 
 `, buildtime.PROGNAME),
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// Overlay the selected environment profile before anything reads
+		// the configuration
+		if err := config.ApplyProfile(viper.GetViper(), viper.GetString(config.KeyEnv)); err != nil {
+			return err
+		}
+
 		// Re-initialize logging with user-supplied configuration parameters
 		{
 			// os.Stdout isn't guaranteed to be thread-safe, wrap in a sync writer.
@@ -88,29 +92,11 @@ access, and optionally enabling introspective utilities like gops(1) and pprof.
 
 		// Perform input validation
 
-		logLevel := strings.ToUpper(viper.GetString(config.KeyLogLevel))
-		switch logLevel {
-		case "DEBUG":
-			zerolog.SetGlobalLevel(zerolog.DebugLevel)
-		case "INFO":
-			zerolog.SetGlobalLevel(zerolog.InfoLevel)
-		case "WARN":
-			zerolog.SetGlobalLevel(zerolog.WarnLevel)
-		case "ERROR":
-			zerolog.SetGlobalLevel(zerolog.ErrorLevel)
-		case "FATAL":
-			zerolog.SetGlobalLevel(zerolog.FatalLevel)
-		default:
-			// FIXME(seanc@): move the supported log levels into a global constant
-			return fmt.Errorf("unsupported error level: %q (supported levels: %s)", logLevel,
-				strings.Join([]string{
-					"DEBUG",
-					"INFO",
-					"WARN",
-					"ERROR",
-					"FATAL",
-				}, " "))
+		logLevel, err := config.GetLogLevel()
+		if err != nil {
+			return err
 		}
+		zerolog.SetGlobalLevel(logLevel)
 
 		go func() {
 			if !viper.GetBool(config.KeyGoogleAgentEnable) {
@@ -142,33 +128,6 @@ access, and optionally enabling introspective utilities like gops(1) and pprof.
 			}
 		}()
 
-		go func() {
-			if !viper.GetBool(config.KeyPProfEnable) {
-				log.Debug().Msg("pprof endpoint disabled by request")
-				return
-			}
-
-			log.Info().Msg("enabled pprof endpoint")
-
-			var (
-				bind = viper.GetString(config.KeyPProfBind)
-				port = viper.GetInt(config.KeyPProfPort)
-				addr = fmt.Sprintf("%s:%d", bind, port)
-			)
-
-			log.Debug().
-				Str("pprof-bind", bind).
-				Int("pprof-port", port).
-				Msgf("starting pprof endpoint at %q", addr)
-
-			if err := http.ListenAndServe(addr, nil); err != nil {
-				log.Fatal().
-					Err(err).
-					Msg("failed to start pprof listener")
-				return
-			}
-		}()
-
 		return nil
 	},
 }
@@ -187,6 +146,28 @@ func init() {
 	RootCmd.PersistentFlags().StringVar(&cfgFile,
 		"config", buildtime.PROGNAME+".toml", "config file")
 
+	{
+		const (
+			key          = config.KeyEnv
+			longName     = "env"
+			shortName    = ""
+			defaultValue = ""
+			description  = "Config profile to apply over the base configuration"
+		)
+
+		RootCmd.PersistentFlags().StringP(
+			longName,
+			shortName,
+			defaultValue,
+			description,
+		)
+		err := viper.BindPFlag(key, RootCmd.PersistentFlags().Lookup(longName))
+		if err != nil {
+			log.Warn().Err(err).Str("flag", longName).Msg("cli: unable to bind flag")
+		}
+		viper.SetDefault(key, defaultValue)
+	}
+
 	{
 		const (
 			key          = config.KeyLogLevel
@@ -204,7 +185,7 @@ func init() {
 		)
 		err := viper.BindPFlag(key, RootCmd.PersistentFlags().Lookup(longName))
 		if err != nil {
-			log.Warn().Err(err)
+			log.Warn().Err(err).Str("flag", longName).Msg("cli: unable to bind flag")
 		}
 		viper.SetDefault(key, defaultValue)
 	}
@@ -226,7 +207,29 @@ func init() {
 		)
 		err := viper.BindPFlag(key, RootCmd.PersistentFlags().Lookup(longName))
 		if err != nil {
-			log.Warn().Err(err)
+			log.Warn().Err(err).Str("flag", longName).Msg("cli: unable to bind flag")
+		}
+		viper.SetDefault(key, defaultValue)
+	}
+
+	{
+		const (
+			key          = config.KeyNomadForceOnSubmit
+			longName     = "force-on-submit"
+			shortName    = ""
+			defaultValue = config.DefaultForceOnSubmit
+			description  = "Run the job of a new group as soon as it's registered"
+		)
+
+		RootCmd.PersistentFlags().BoolP(
+			longName,
+			shortName,
+			defaultValue,
+			description,
+		)
+		err := viper.BindPFlag(key, RootCmd.PersistentFlags().Lookup(longName))
+		if err != nil {
+			log.Warn().Err(err).Str("flag", longName).Msg("cli: unable to bind flag")
 		}
 		viper.SetDefault(key, defaultValue)
 	}
@@ -236,8 +239,8 @@ func init() {
 			key          = config.KeyPProfEnable
 			longName     = "enable-pprof"
 			shortName    = ""
-			defaultValue = true
-			description  = "Enable the pprof endpoint interface"
+			defaultValue = false
+			description  = "Serve pprof on an admin listener apart from the API"
 		)
 
 		RootCmd.PersistentFlags().BoolP(
@@ -248,7 +251,7 @@ func init() {
 		)
 		err := viper.BindPFlag(key, RootCmd.PersistentFlags().Lookup(longName))
 		if err != nil {
-			log.Warn().Err(err)
+			log.Warn().Err(err).Str("flag", longName).Msg("cli: unable to bind flag")
 		}
 		viper.SetDefault(key, defaultValue)
 	}
@@ -258,7 +261,7 @@ func init() {
 			key          = config.KeyPProfPort
 			longName     = "pprof-port"
 			shortName    = ""
-			defaultValue = 4242
+			defaultValue = 9090
 			description  = "Specify the pprof port"
 		)
 
@@ -270,7 +273,7 @@ func init() {
 		)
 		err := viper.BindPFlag(key, RootCmd.PersistentFlags().Lookup(longName))
 		if err != nil {
-			log.Warn().Err(err)
+			log.Warn().Err(err).Str("flag", longName).Msg("cli: unable to bind flag")
 		}
 		viper.SetDefault(key, defaultValue)
 	}
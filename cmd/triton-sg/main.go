@@ -27,7 +27,7 @@ func main() {
 		p := conswriter.GetTerminal()
 		err := p.Wait()
 		if err != nil {
-			log.Warn().Err(err)
+			log.Warn().Err(err).Msg("unable to wait on terminal output")
 		}
 	}()
 
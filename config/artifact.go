@@ -0,0 +1,187 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+const (
+	DefaultArtifactBaseURL     = "https://github.com/joyent/tsg-cli/releases/download"
+	DefaultArtifactDestination = "local/"
+	DefaultArtifactMode        = "any"
+)
+
+var (
+	artifactPathRule   = regexp.MustCompile(`^[a-zA-Z0-9_.-]+(/[a-zA-Z0-9_.-]+)*/?$`)
+	artifactOptionKey  = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	artifactOptionRule = regexp.MustCompile(`^[^"\\\n\r]*$`)
+	artifactChecksum   = regexp.MustCompile(`^(md5|sha1|sha256|sha512):[0-9a-fA-F]+$`)
+)
+
+// Artifact configures how Nomad fetches and unpacks the tsg-cli release onto
+// the automater nodes running a group's job.
+type Artifact struct {
+	// BaseURL is where releases are downloaded from, such as an internal
+	// mirror of the GitHub releases. Each release is fetched from a path
+	// below it named for its version.
+	BaseURL string
+	// Destination is where the artifact is placed, relative to the task
+	// directory.
+	Destination string
+	// Mode is one of "any", "file" or "dir". In "file" mode Destination is
+	// the path of the binary itself, which requires Unpack to be disabled.
+	Mode string
+	// Unpack extracts the release archive. Disable it for nodes where the
+	// binary is fetched unarchived, from beside the archive without its
+	// extension.
+	Unpack bool
+	// Options are passed to Nomad's artifact fetcher as is.
+	Options map[string]string
+	// Checksums are the checksums of releases by version, which Nomad
+	// verifies the release it fetches against.
+	Checksums map[string]string
+}
+
+// GetArtifact returns the validated artifact configuration, falling back to
+// the defaults for anything which isn't set.
+func GetArtifact() (Artifact, error) {
+	artifact := Artifact{
+		BaseURL:     DefaultArtifactBaseURL,
+		Destination: DefaultArtifactDestination,
+		Mode:        DefaultArtifactMode,
+		Unpack:      true,
+		Options:     map[string]string{},
+		Checksums:   map[string]string{},
+	}
+
+	if baseURL := viper.GetString(KeyTSGCliArtifactBaseURL); baseURL != "" {
+		artifact.BaseURL = strings.TrimSuffix(baseURL, "/")
+	}
+	if dest := viper.GetString(KeyTSGCliArtifactDestination); dest != "" {
+		artifact.Destination = dest
+	}
+	if mode := viper.GetString(KeyTSGCliArtifactMode); mode != "" {
+		artifact.Mode = strings.ToLower(mode)
+	}
+	if viper.IsSet(KeyTSGCliArtifactUnpack) {
+		artifact.Unpack = viper.GetBool(KeyTSGCliArtifactUnpack)
+	}
+	for key, value := range viper.GetStringMap(KeyTSGCliArtifactOptions) {
+		artifact.Options[strings.ToLower(key)] = cast.ToString(value)
+	}
+	for version, checksum := range viper.GetStringMap(KeyTSGCliArtifactChecksums) {
+		artifact.Checksums[strings.TrimPrefix(version, "v")] = cast.ToString(checksum)
+	}
+
+	if err := artifact.validate(); err != nil {
+		return Artifact{}, err
+	}
+
+	if !artifact.Unpack {
+		artifact.Options["archive"] = "false"
+	}
+
+	return artifact, nil
+}
+
+// Source returns the URL of the given release of tsg-cli, which is its
+// archive unless Unpack is disabled.
+func (a Artifact) Source(version string) string {
+	baseURL := a.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultArtifactBaseURL
+	}
+	source := fmt.Sprintf("%s/v%s/%s", baseURL, version, releaseFile(version))
+	if a.Unpack {
+		source += ".tar.gz"
+	}
+	return source
+}
+
+// Command returns the path of the tsg-cli binary of the given release once
+// it's fetched, relative to the task directory.
+func (a Artifact) Command(version string) string {
+	dest := a.Destination
+	if dest == "" {
+		dest = DefaultArtifactDestination
+	}
+
+	switch {
+	case a.Mode == "file":
+		return path.Clean(dest)
+	case !a.Unpack:
+		// Nomad names a file fetched into a directory after its URL.
+		return path.Join(dest, releaseFile(version))
+	case path.Clean(dest) == path.Clean(DefaultArtifactDestination):
+		return "tsg-cli"
+	default:
+		return path.Join(dest, "tsg-cli")
+	}
+}
+
+// releaseFile is the name of the binary of the given release of tsg-cli,
+// which its archive is named after.
+func releaseFile(version string) string {
+	return fmt.Sprintf("tsg-cli_%s_linux_amd64", version)
+}
+
+func (a Artifact) validate() error {
+	u, err := url.Parse(a.BaseURL)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return fmt.Errorf("artifact base URL must be an absolute URL: %q", a.BaseURL)
+	}
+	if u.RawQuery != "" || u.Fragment != "" {
+		return fmt.Errorf("artifact base URL must not have a query or fragment: %q", a.BaseURL)
+	}
+
+	switch a.Mode {
+	case "any":
+	case "file":
+		if a.Unpack {
+			return fmt.Errorf("artifact mode %q requires disabling unpack, an archive can't be unpacked into a file", a.Mode)
+		}
+		if strings.HasSuffix(a.Destination, "/") {
+			return fmt.Errorf("artifact destination must name the binary in mode %q: %q", a.Mode, a.Destination)
+		}
+	case "dir":
+		if !a.Unpack {
+			return fmt.Errorf("artifact mode %q requires unpack, only an archive can be fetched as a directory", a.Mode)
+		}
+	default:
+		return fmt.Errorf("unsupported artifact mode: %q", a.Mode)
+	}
+
+	if !artifactPathRule.MatchString(a.Destination) ||
+		strings.HasPrefix(path.Clean(a.Destination), "..") {
+		return fmt.Errorf("artifact destination must be a relative path within the task directory: %q", a.Destination)
+	}
+
+	for key, value := range a.Options {
+		if !artifactOptionKey.MatchString(key) {
+			return fmt.Errorf("invalid artifact option name: %q", key)
+		}
+		if !artifactOptionRule.MatchString(value) {
+			return fmt.Errorf("invalid artifact option value for %q", key)
+		}
+		if key == "archive" && !a.Unpack {
+			return fmt.Errorf("artifact option %q conflicts with disabling unpack", key)
+		}
+		if key == "checksum" && len(a.Checksums) > 0 {
+			return fmt.Errorf("artifact option %q conflicts with artifact checksums", key)
+		}
+	}
+
+	for version, checksum := range a.Checksums {
+		if !artifactChecksum.MatchString(checksum) {
+			return fmt.Errorf("invalid artifact checksum for version %q: %q", version, checksum)
+		}
+	}
+
+	return nil
+}
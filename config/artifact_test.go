@@ -0,0 +1,156 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetArtifactDefaults(t *testing.T) {
+	defer viper.Reset()
+
+	artifact, err := config.GetArtifact()
+	require.NoError(t, err)
+	assert.Equal(t, config.Artifact{
+		BaseURL:     "https://github.com/joyent/tsg-cli/releases/download",
+		Destination: "local/",
+		Mode:        "any",
+		Unpack:      true,
+		Options:     map[string]string{},
+		Checksums:   map[string]string{},
+	}, artifact)
+}
+
+func TestGetArtifact(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set(config.KeyTSGCliArtifactBaseURL, "https://artifacts.example.com/tsg-cli/")
+	viper.Set(config.KeyTSGCliArtifactDestination, "local/bin/tsg-cli")
+	viper.Set(config.KeyTSGCliArtifactMode, "File")
+	viper.Set(config.KeyTSGCliArtifactUnpack, false)
+	viper.Set(config.KeyTSGCliArtifactOptions, map[string]interface{}{
+		"checksum": "sha256:abc123",
+	})
+
+	artifact, err := config.GetArtifact()
+	require.NoError(t, err)
+	assert.Equal(t, config.Artifact{
+		BaseURL:     "https://artifacts.example.com/tsg-cli",
+		Destination: "local/bin/tsg-cli",
+		Mode:        "file",
+		Unpack:      false,
+		Options: map[string]string{
+			"checksum": "sha256:abc123",
+			"archive":  "false",
+		},
+		Checksums: map[string]string{},
+	}, artifact)
+	assert.Equal(t, "https://artifacts.example.com/tsg-cli/v0.1.0/tsg-cli_0.1.0_linux_amd64", artifact.Source("0.1.0"))
+	assert.Equal(t, "local/bin/tsg-cli", artifact.Command("0.1.0"))
+}
+
+func TestArtifactModes(t *testing.T) {
+	tests := []struct {
+		name     string
+		artifact config.Artifact
+		source   string
+		command  string
+	}{
+		{"default", config.Artifact{Destination: "local/", Mode: "any", Unpack: true},
+			"tsg-cli_0.1.0_linux_amd64.tar.gz", "tsg-cli"},
+		{"any", config.Artifact{Destination: "local/bin", Mode: "any", Unpack: true},
+			"tsg-cli_0.1.0_linux_amd64.tar.gz", "local/bin/tsg-cli"},
+		{"any without unpack", config.Artifact{Destination: "local/bin", Mode: "any"},
+			"tsg-cli_0.1.0_linux_amd64", "local/bin/tsg-cli_0.1.0_linux_amd64"},
+		{"dir", config.Artifact{Destination: "local/bin/", Mode: "dir", Unpack: true},
+			"tsg-cli_0.1.0_linux_amd64.tar.gz", "local/bin/tsg-cli"},
+		{"file", config.Artifact{Destination: "local/tsg", Mode: "file"},
+			"tsg-cli_0.1.0_linux_amd64", "local/tsg"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, config.DefaultArtifactBaseURL+"/v0.1.0/"+tt.source, tt.artifact.Source("0.1.0"))
+			assert.Equal(t, tt.command, tt.artifact.Command("0.1.0"))
+		})
+	}
+}
+
+func TestGetArtifactChecksums(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set(config.KeyTSGCliArtifactChecksums, map[string]interface{}{
+		"0.1.0":  "sha256:abc123",
+		"v0.2.0": "sha512:DEF456",
+	})
+
+	artifact, err := config.GetArtifact()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"0.1.0": "sha256:abc123",
+		"0.2.0": "sha512:DEF456",
+	}, artifact.Checksums)
+
+	// A single checksum option can't be told apart from those of versions.
+	viper.Set(config.KeyTSGCliArtifactOptions, map[string]interface{}{
+		"checksum": "sha256:abc123",
+	})
+	_, err = config.GetArtifact()
+	assert.EqualError(t, err, `artifact option "checksum" conflicts with artifact checksums`)
+}
+
+func TestGetArtifactInvalid(t *testing.T) {
+	tests := []struct {
+		name  string
+		key   string
+		value interface{}
+		err   string
+	}{
+		{"relative base URL", config.KeyTSGCliArtifactBaseURL, "artifacts.example.com/tsg-cli",
+			`artifact base URL must be an absolute URL: "artifacts.example.com/tsg-cli"`},
+		{"base URL query", config.KeyTSGCliArtifactBaseURL, "https://artifacts.example.com/tsg-cli?token=x",
+			`artifact base URL must not have a query or fragment: "https://artifacts.example.com/tsg-cli?token=x"`},
+		{"mode", config.KeyTSGCliArtifactMode, "folder", `unsupported artifact mode: "folder"`},
+		{"file mode unpacking", config.KeyTSGCliArtifactMode, "file",
+			`artifact mode "file" requires disabling unpack, an archive can't be unpacked into a file`},
+		{"absolute destination", config.KeyTSGCliArtifactDestination, "/usr/local/bin",
+			`artifact destination must be a relative path within the task directory: "/usr/local/bin"`},
+		{"escaping destination", config.KeyTSGCliArtifactDestination, "../../bin",
+			`artifact destination must be a relative path within the task directory: "../../bin"`},
+		{"option name", config.KeyTSGCliArtifactOptions, map[string]interface{}{"bad key": "x"},
+			`invalid artifact option name: "bad key"`},
+		{"option value", config.KeyTSGCliArtifactOptions, map[string]interface{}{"checksum": `a"b`},
+			`invalid artifact option value for "checksum"`},
+		{"checksum", config.KeyTSGCliArtifactChecksums, map[string]interface{}{"0.1.0": "abc123"},
+			`invalid artifact checksum for version "0.1.0": "abc123"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer viper.Reset()
+			viper.Set(tt.key, tt.value)
+
+			_, err := config.GetArtifact()
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
+func TestGetArtifactInvalidWithoutUnpack(t *testing.T) {
+	for mode, msg := range map[string]string{
+		"dir":  `artifact mode "dir" requires unpack, only an archive can be fetched as a directory`,
+		"file": `artifact destination must name the binary in mode "file": "local/"`,
+	} {
+		t.Run(mode, func(t *testing.T) {
+			defer viper.Reset()
+			viper.Set(config.KeyTSGCliArtifactMode, mode)
+			viper.Set(config.KeyTSGCliArtifactUnpack, false)
+
+			_, err := config.GetArtifact()
+			assert.EqualError(t, err, msg)
+		})
+	}
+}
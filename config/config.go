@@ -2,11 +2,16 @@ package config
 
 import (
 	"fmt"
+	"io/ioutil"
+	"net/url"
+	"regexp"
 	"strings"
+	"time"
 
 	nomad "github.com/hashicorp/nomad/api"
 	"github.com/jackc/pgx"
 	"github.com/joyent/triton-service-groups/buildtime"
+	"github.com/joyent/triton-service-groups/webhooks"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
@@ -14,13 +19,62 @@ import (
 	"github.com/spf13/viper"
 )
 
+// DBPool configures the pool of database connections. MaxConnections
+// defaults to DefaultDBMaxConnections and AcquireTimeout to waiting for a
+// free connection indefinitely. AfterConnect is called on every new
+// connection and sets the configured session parameters, including the
+// statement timeout.
 type DBPool = pgx.ConnPoolConfig
 
+// DefaultDBMaxConnections is how many connections the database pool opens at
+// most, unless configured otherwise.
+const DefaultDBMaxConnections = 5
+
 type Config struct {
 	DBPool
+	DBConnect
 	Agent
 	HTTPServer
 	Nomad
+	NomadRetry
+	Drift
+	Alerts
+	SLO
+	Budget
+
+	// Datacenters are the remote datacenters multi-datacenter groups can
+	// run in, by name.
+	Datacenters map[string]Datacenter
+
+	// JobTemplate is a custom template of the job of every group, read from
+	// the file named by nomad.job-template. It's empty to use the built in
+	// templates.
+	JobTemplate string
+
+	// EnablePprof serves the net/http/pprof handlers under /debug/pprof/ on
+	// an admin listener at PprofAddr, apart from the API. It's off by
+	// default.
+	EnablePprof bool
+	PprofAddr   string
+}
+
+// DBConnect configures how the agent retries connecting to the database as
+// it starts, backing off exponentially between attempts.
+type DBConnect struct {
+	// Attempts is how many times connecting is tried before giving up.
+	Attempts int
+	// Timeout bounds the total time spent connecting.
+	Timeout time.Duration
+}
+
+// NomadRetry configures how calls which register and deregister jobs are
+// retried when Nomad fails transiently, backing off exponentially between
+// attempts.
+type NomadRetry struct {
+	// Attempts is how many times a call is made before giving up.
+	Attempts       int
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
 }
 
 type Agent struct {
@@ -36,6 +90,97 @@ type HTTPServer struct {
 	AuthURL         string
 	KeyNamePrefix   string
 	EnableWhitelist bool
+
+	ReadyFailureThreshold float64
+	ReadyFailureWindow    time.Duration
+	ReadyMinSamples       int
+
+	// ShutdownTimeout bounds how long in-flight requests are drained for
+	// when the agent shuts down, after which their connections are closed.
+	ShutdownTimeout time.Duration
+
+	// CompressionMinSize is the size in bytes a response body must reach
+	// before it's gzipped for clients which accept it. A negative size
+	// disables compression.
+	CompressionMinSize int
+
+	// RequestTimeout bounds how long a request is handled for before its
+	// context is cancelled and it's answered with a 504, unless its route
+	// overrides it. A zero timeout never times out.
+	RequestTimeout time.Duration
+
+	// CORSAllowedOrigins are the origins, such as https://dashboard.example.com,
+	// of browser scripts allowed to call the API, or "*" for any origin. CORS
+	// is disabled without any.
+	CORSAllowedOrigins []string
+
+	RateLimit RateLimit
+}
+
+// RateLimit configures how many requests per second clients may make, with
+// bursts of up to the burst size. Authenticated requests are limited per
+// account, while requests which fail authentication share a stricter limit
+// per IP address. A rate of zero disables the limit.
+type RateLimit struct {
+	AccountRate          float64
+	AccountBurst         int
+	UnauthenticatedRate  float64
+	UnauthenticatedBurst int
+	// CleanupInterval is how often the buckets of idle clients are dropped.
+	CleanupInterval time.Duration
+}
+
+// Drift configures the background detection of drift between the groups
+// stored in the database and the jobs running in Nomad.
+type Drift struct {
+	// Policy is one of "off", "alert" or "remediate".
+	Policy          string
+	Interval        time.Duration
+	MaxRemediations int
+	// MinHealthy is the ratio of groups which must have a healthy job before
+	// any are remediated. Below it drift is only alerted on, since mass
+	// re-registration likely means Nomad itself is in trouble.
+	MinHealthy float64
+	// Freeze disables remediation without disabling detection.
+	Freeze bool
+}
+
+// SLO configures how the convergence of reconciles is measured.
+type SLO struct {
+	// Target is how quickly a group must converge for its reconcile to meet
+	// the SLO.
+	Target time.Duration
+	// Timeout is how long a group may take to converge before it's counted as
+	// a breach and no longer watched.
+	Timeout time.Duration
+	Window  time.Duration
+	// Interval is how often converging groups are checked.
+	Interval time.Duration
+}
+
+// Alerts configures the background monitor which notifies a webhook when a
+// group crosses one of its alert thresholds.
+type Alerts struct {
+	Interval time.Duration
+	// WebhookURL receives alert deliveries. The monitor is disabled if unset.
+	WebhookURL    string
+	WebhookSecret string
+	// WebhookFormat is how deliveries are encoded, either the raw event or
+	// a CloudEvents envelope.
+	WebhookFormat webhooks.Format
+	// EventSource identifies this server as the source of CloudEvents.
+	EventSource string
+}
+
+// Budget caps the total runtime of each group's reconcile tasks per day.
+type Budget struct {
+	// Daily is the runtime allowed per group before reconciles are skipped.
+	// Budgets are disabled when zero.
+	Daily time.Duration
+	// ResetAt is the time of day, in UTC, at which every budget resets.
+	ResetAt time.Duration
+	// Interval is how often reconcile runtime is collected from Nomad.
+	Interval time.Duration
 }
 
 type PGXLogger struct {
@@ -46,6 +191,22 @@ type Nomad struct {
 	Addr      string
 	Port      uint16
 	TLSConfig *nomad.TLSConfig
+	// Namespace is the Nomad namespace the jobs of groups are managed in.
+	Namespace string
+	// Region is the Nomad region the jobs of groups are managed in. It's
+	// empty to use the region of the Nomad agent.
+	Region string
+	// Datacenters are the Nomad datacenters the jobs of groups may be
+	// placed in. They're empty to place jobs in the datacenter named after
+	// the Triton datacenter.
+	Datacenters []string
+}
+
+// Datacenter configures a remote datacenter along with the Nomad cluster which
+// runs the jobs of groups in it.
+type Datacenter struct {
+	Nomad
+	TritonURL string
 }
 
 // Custom logging facade that implements the pgx.Logger interface in order to
@@ -76,6 +237,470 @@ func GetTSGCliVersion() string {
 	return viper.GetString(KeyTSGCliVersion)
 }
 
+// GetTSGCliJSONArgs returns true if tags and metadata are passed to tsg-cli
+// as a single JSON argument each, rather than one argument per pair.
+func GetTSGCliJSONArgs() bool {
+	return viper.GetBool(KeyTSGCliJSONArgs)
+}
+
+// DefaultDeregisterWait is how long the orchestrator waits for in-flight
+// allocations to finish before deregistering a job unless configured
+// otherwise, which gives the scale-down run of a deleted group time to tear
+// its instances down.
+const DefaultDeregisterWait = 5 * time.Minute
+
+// GetDeregisterWait returns how long the orchestrator should wait for in-flight
+// allocations to finish before deregistering a job. A zero value disables
+// waiting altogether.
+func GetDeregisterWait() time.Duration {
+	if !viper.IsSet(KeyNomadDeregisterWait) {
+		return DefaultDeregisterWait
+	}
+	return viper.GetDuration(KeyNomadDeregisterWait)
+}
+
+// GetJobCacheTTL returns how long Nomad job info may be served from cache. A
+// zero value disables caching.
+func GetJobCacheTTL() time.Duration {
+	return viper.GetDuration(KeyNomadJobCacheTTL)
+}
+
+// GetCheckImages returns true if the image of a group's template must exist in
+// Triton before the group's job is submitted.
+func GetCheckImages() bool {
+	return viper.GetBool(KeyTritonCheckImages)
+}
+
+// DefaultImageCacheTTL is how long the existence of an image is cached unless
+// configured otherwise.
+const DefaultImageCacheTTL = 5 * time.Minute
+
+// GetImageCacheTTL returns how long the existence of an image may be served
+// from cache. A zero value disables caching.
+func GetImageCacheTTL() time.Duration {
+	if !viper.IsSet(KeyTritonImageCacheTTL) {
+		return DefaultImageCacheTTL
+	}
+	return viper.GetDuration(KeyTritonImageCacheTTL)
+}
+
+// GetCheckPackages returns true if the package of a group's template must
+// exist in Triton before the group's job is submitted.
+func GetCheckPackages() bool {
+	return viper.GetBool(KeyTritonCheckPackages)
+}
+
+// DefaultPackageCacheTTL is how long the existence of a package is cached
+// unless configured otherwise.
+const DefaultPackageCacheTTL = 5 * time.Minute
+
+// GetPackageCacheTTL returns how long the existence of a package may be
+// served from cache. A zero value disables caching.
+func GetPackageCacheTTL() time.Duration {
+	if !viper.IsSet(KeyTritonPackageCacheTTL) {
+		return DefaultPackageCacheTTL
+	}
+	return viper.GetDuration(KeyTritonPackageCacheTTL)
+}
+
+// GetCheckNetworks returns true if the networks of a group's template must
+// exist in the group's datacenter before its job is submitted.
+func GetCheckNetworks() bool {
+	return viper.GetBool(KeyTritonCheckNetworks)
+}
+
+// DefaultNetworkCacheTTL is how long the existence of a network is cached
+// unless configured otherwise.
+const DefaultNetworkCacheTTL = 5 * time.Minute
+
+// GetNetworkCacheTTL returns how long the existence of a network may be
+// served from cache. A zero value disables caching.
+func GetNetworkCacheTTL() time.Duration {
+	if !viper.IsSet(KeyTritonNetworkCacheTTL) {
+		return DefaultNetworkCacheTTL
+	}
+	return viper.GetDuration(KeyTritonNetworkCacheTTL)
+}
+
+// DefaultTeardownTimeout is how long a synchronous delete waits on a group's
+// instances to be destroyed unless configured otherwise.
+const DefaultTeardownTimeout = 10 * time.Minute
+
+// GetTeardownTimeout returns how long a synchronous delete waits on a group's
+// instances to be destroyed before returning what remains.
+func GetTeardownTimeout() time.Duration {
+	if timeout := viper.GetDuration(KeyTritonTeardownTimeout); timeout > 0 {
+		return timeout
+	}
+	return DefaultTeardownTimeout
+}
+
+// DefaultKeyGracePeriod is how long an account's key is kept once it's been
+// rotated out unless configured otherwise.
+const DefaultKeyGracePeriod = 24 * time.Hour
+
+// GetKeyGracePeriod returns how long an account's key is kept once it's been
+// rotated out, so that jobs still running with it can finish.
+func GetKeyGracePeriod() time.Duration {
+	if period := viper.GetDuration(KeyTritonKeyGracePeriod); period > 0 {
+		return period
+	}
+	return DefaultKeyGracePeriod
+}
+
+// DefaultKeyCleanupInterval is how often keys past their grace period are
+// removed unless configured otherwise.
+const DefaultKeyCleanupInterval = 10 * time.Minute
+
+// GetKeyCleanupInterval returns how often keys past their grace period are
+// removed.
+func GetKeyCleanupInterval() time.Duration {
+	if interval := viper.GetDuration(KeyTritonKeyCleanupInterval); interval > 0 {
+		return interval
+	}
+	return DefaultKeyCleanupInterval
+}
+
+// DefaultGroupRetention is how long a deleted group is kept, and can be
+// restored, unless configured otherwise.
+const DefaultGroupRetention = 7 * 24 * time.Hour
+
+// GetGroupRetention returns how long a deleted group is kept, during which it
+// can be restored. A zero value keeps deleted groups forever.
+func GetGroupRetention() time.Duration {
+	if !viper.IsSet(KeyGroupsRetention) {
+		return DefaultGroupRetention
+	}
+	return viper.GetDuration(KeyGroupsRetention)
+}
+
+// DefaultGroupPurgeInterval is how often deleted groups past their retention
+// are purged unless configured otherwise.
+const DefaultGroupPurgeInterval = time.Hour
+
+// GetGroupPurgeInterval returns how often deleted groups past their
+// retention are purged.
+func GetGroupPurgeInterval() time.Duration {
+	if interval := viper.GetDuration(KeyGroupsPurgeInterval); interval > 0 {
+		return interval
+	}
+	return DefaultGroupPurgeInterval
+}
+
+// DefaultIdempotencyKeyTTL is how long the response to a request with an
+// Idempotency-Key is replayed unless configured otherwise.
+const DefaultIdempotencyKeyTTL = 24 * time.Hour
+
+// GetIdempotencyKeyTTL returns how long the response to a request with an
+// Idempotency-Key is replayed to retries of it, after which the key can be
+// used again.
+func GetIdempotencyKeyTTL() time.Duration {
+	if ttl := viper.GetDuration(KeyGroupsIdempotencyTTL); ttl > 0 {
+		return ttl
+	}
+	return DefaultIdempotencyKeyTTL
+}
+
+// DefaultFirstRunTimeout is how long a synchronous submit waits on the first
+// run of a group's job unless configured otherwise.
+const DefaultFirstRunTimeout = 2 * time.Minute
+
+// GetFirstRunTimeout returns how long a synchronous submit waits on the first
+// run of a group's job to be placed and finish before returning.
+func GetFirstRunTimeout() time.Duration {
+	if timeout := viper.GetDuration(KeyNomadFirstRunTimeout); timeout > 0 {
+		return timeout
+	}
+	return DefaultFirstRunTimeout
+}
+
+// DefaultForceOnSubmit is whether the job of a new group runs as soon as it's
+// registered unless configured otherwise.
+const DefaultForceOnSubmit = true
+
+// GetForceOnSubmit returns true if the periodic job of a new group is forced
+// to run once as soon as it's registered. Otherwise its first run waits for
+// its schedule, up to a cron interval.
+func GetForceOnSubmit() bool {
+	if !viper.IsSet(KeyNomadForceOnSubmit) {
+		return DefaultForceOnSubmit
+	}
+	return viper.GetBool(KeyNomadForceOnSubmit)
+}
+
+// DefaultReconcileWorkers is how many groups a bulk reconcile forces at once
+// unless configured otherwise.
+const DefaultReconcileWorkers = 4
+
+// GetReconcileWorkers returns how many groups a bulk reconcile forces the
+// jobs of at once.
+func GetReconcileWorkers() int {
+	if workers := viper.GetInt(KeyNomadReconcileWorkers); workers > 0 {
+		return workers
+	}
+	return DefaultReconcileWorkers
+}
+
+// DefaultNomadNamespace is the namespace Nomad places jobs in when none is
+// given.
+const DefaultNomadNamespace = "default"
+
+// GetNomadNamespace returns the Nomad namespace the jobs of groups are
+// managed in.
+func GetNomadNamespace() string {
+	if namespace := viper.GetString(KeyNomadNamespace); namespace != "" {
+		return namespace
+	}
+	return DefaultNomadNamespace
+}
+
+// GetNomadRegion returns the Nomad region the jobs of groups are managed in,
+// or an empty string to use the region of the Nomad agent.
+func GetNomadRegion() string {
+	return viper.GetString(KeyNomadRegion)
+}
+
+// DefaultNomadTimeZone is the time zone the periodic schedules of jobs run in
+// unless configured otherwise.
+const DefaultNomadTimeZone = "UTC"
+
+// GetNomadTimeZone returns the IANA time zone, such as Europe/Berlin, the
+// periodic schedules of jobs run in unless their group sets its own.
+func GetNomadTimeZone() string {
+	if tz := viper.GetString(KeyNomadTimeZone); tz != "" {
+		return tz
+	}
+	return DefaultNomadTimeZone
+}
+
+// GetNomadDatacenters returns the Nomad datacenters the jobs of groups may be
+// placed in, or nil to place them in the datacenter named after the Triton
+// datacenter.
+func GetNomadDatacenters() []string {
+	return viper.GetStringSlice(KeyNomadDatacenters)
+}
+
+// validateNomadDatacenters checks that none of the Nomad datacenters, read
+// from key, is blank.
+func validateNomadDatacenters(key string, datacenters []string) error {
+	for _, dc := range datacenters {
+		if strings.TrimSpace(dc) == "" {
+			return fmt.Errorf("%s must not contain an empty datacenter", key)
+		}
+	}
+	return nil
+}
+
+// validateCORSOrigins checks that every allowed CORS origin is "*" or a bare
+// http or https origin, without a path, as sent by browsers.
+func validateCORSOrigins(origins []string) error {
+	for _, origin := range origins {
+		if origin == "*" {
+			continue
+		}
+
+		u, err := url.Parse(origin)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" ||
+			u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+			return fmt.Errorf("%s: invalid origin %q", KeyHTTPServerCORSAllowedOrigins, origin)
+		}
+	}
+	return nil
+}
+
+// Nomad calls are retried with these defaults unless configured otherwise.
+const (
+	DefaultNomadRetryAttempts       = 3
+	DefaultNomadRetryInitialBackoff = 250 * time.Millisecond
+	DefaultNomadRetryMaxBackoff     = 5 * time.Second
+)
+
+// GetNomadRetry returns how Nomad calls which register and deregister jobs
+// are retried.
+func GetNomadRetry() NomadRetry {
+	retry := NomadRetry{
+		Attempts:       DefaultNomadRetryAttempts,
+		InitialBackoff: DefaultNomadRetryInitialBackoff,
+		MaxBackoff:     DefaultNomadRetryMaxBackoff,
+	}
+	if attempts := viper.GetInt(KeyNomadRetryAttempts); attempts > 0 {
+		retry.Attempts = attempts
+	}
+	if backoff := viper.GetDuration(KeyNomadRetryInitialBackoff); backoff > 0 {
+		retry.InitialBackoff = backoff
+	}
+	if backoff := viper.GetDuration(KeyNomadRetryMaxBackoff); backoff > 0 {
+		retry.MaxBackoff = backoff
+	}
+	return retry
+}
+
+// DefaultMaxJobSize is the largest rendered job spec, in bytes, submitted to
+// Nomad unless configured otherwise.
+const DefaultMaxJobSize = 1 << 20
+
+// GetMaxJobSize returns the largest rendered job spec, in bytes, which may be
+// submitted to Nomad. A zero value disables the check.
+func GetMaxJobSize() int {
+	if !viper.IsSet(KeyNomadMaxJobSize) {
+		return DefaultMaxJobSize
+	}
+	return viper.GetInt(KeyNomadMaxJobSize)
+}
+
+// DefaultTaskCPU and DefaultTaskMemoryMB are the resources, in MHz and MB,
+// reserved for the task which runs tsg-cli unless configured otherwise.
+const (
+	DefaultTaskCPU      = 500
+	DefaultTaskMemoryMB = 512
+)
+
+// GetTaskCPU returns the CPU, in MHz, reserved for the task which runs tsg-cli
+// when the template of a group doesn't set it.
+func GetTaskCPU() int {
+	if cpu := viper.GetInt(KeyNomadTaskCPU); cpu > 0 {
+		return cpu
+	}
+	return DefaultTaskCPU
+}
+
+// GetTaskMemoryMB returns the memory, in MB, reserved for the task which runs
+// tsg-cli when the template of a group doesn't set it.
+func GetTaskMemoryMB() int {
+	if memory := viper.GetInt(KeyNomadTaskMemoryMB); memory > 0 {
+		return memory
+	}
+	return DefaultTaskMemoryMB
+}
+
+// DefaultMaxCapacity is the largest capacity of a group's job unless
+// configured otherwise.
+const DefaultMaxCapacity = 100
+
+// GetMaxCapacity returns the largest capacity a job of the named account's
+// groups may run with. An account's own maximum takes precedence over the
+// one configured for every account.
+func GetMaxCapacity(accountName string) int {
+	// NOTE: viper lowercases every key, account names included.
+	accounts := viper.GetStringMap(KeyCapacityAccounts)
+	if max := cast.ToInt(accounts[strings.ToLower(accountName)]); max > 0 {
+		return max
+	}
+	if max := viper.GetInt(KeyCapacityMax); max > 0 {
+		return max
+	}
+	return DefaultMaxCapacity
+}
+
+// DefaultCanaryInterval is how often pending canary instances are checked
+// unless configured otherwise.
+const DefaultCanaryInterval = 15 * time.Second
+
+// GetCanaryInterval returns how often pending canary instances are checked.
+func GetCanaryInterval() time.Duration {
+	if interval := viper.GetDuration(KeyCanaryInterval); interval > 0 {
+		return interval
+	}
+	return DefaultCanaryInterval
+}
+
+// DefaultStatusRefreshInterval is how often the cached job status of every
+// group is refreshed unless configured otherwise.
+const DefaultStatusRefreshInterval = 30 * time.Second
+
+// GetStatusRefreshInterval returns how often the cached job status of every
+// group is refreshed. A zero interval disables the cache, so every status is
+// read from Nomad as it's requested.
+func GetStatusRefreshInterval() time.Duration {
+	if !viper.IsSet(KeyStatusRefreshInterval) {
+		return DefaultStatusRefreshInterval
+	}
+	return viper.GetDuration(KeyStatusRefreshInterval)
+}
+
+const (
+	// JobTypeBatch reconciles each group with a periodic batch job, which
+	// runs tsg-cli on every tick of its schedule.
+	JobTypeBatch = "batch"
+	// JobTypeService reconciles each group with a long-running service job,
+	// which runs tsg-cli in a loop.
+	JobTypeService = "service"
+)
+
+// GetJobType returns the type of the Nomad job which reconciles each group,
+// defaulting to JobTypeBatch.
+func GetJobType() (string, error) {
+	switch jobType := strings.ToLower(viper.GetString(KeyNomadJobType)); jobType {
+	case "", JobTypeBatch:
+		return JobTypeBatch, nil
+	case JobTypeService:
+		return JobTypeService, nil
+	default:
+		return "", fmt.Errorf("unsupported nomad job type: %q", jobType)
+	}
+}
+
+// GetRequiredTags returns the tag keys every template must set on the
+// instances it provisions, or nil if none are required.
+func GetRequiredTags() []string {
+	return viper.GetStringSlice(KeyTagsRequired)
+}
+
+// GetNameMinLength returns the configured minimum length of template and
+// group names, or zero if unset.
+func GetNameMinLength() int {
+	return viper.GetInt(KeyNamesMinLength)
+}
+
+// GetNameMaxLength returns the configured maximum length of template and
+// group names, or zero if unset.
+func GetNameMaxLength() int {
+	return viper.GetInt(KeyNamesMaxLength)
+}
+
+// GetNamePattern returns the configured regular expression template and group
+// names must match, or an empty string if unset.
+func GetNamePattern() string {
+	return viper.GetString(KeyNamesPattern)
+}
+
+// GetExportSigningKey returns the key used to sign and verify exported
+// account bundles, or nil if unset.
+func GetExportSigningKey() []byte {
+	key := viper.GetString(KeyExportSigningKey)
+	if key == "" {
+		return nil
+	}
+	return []byte(key)
+}
+
+// GetFeatureDefaults returns the feature flags configured for every account.
+func GetFeatureDefaults() map[string]bool {
+	return toFeatureFlags(viper.GetStringMap(KeyFeaturesDefaults))
+}
+
+// GetFeatureOverrides returns the feature flags configured for a single
+// account, by account name, which take precedence over the defaults.
+func GetFeatureOverrides(accountName string) map[string]bool {
+	// NOTE: viper lowercases every key, account names included.
+	accounts := viper.GetStringMap(KeyFeaturesAccounts)
+	return toFeatureFlags(cast.ToStringMap(accounts[strings.ToLower(accountName)]))
+}
+
+// GetFeatureDisabledStatus returns the status code of responses to requests
+// for a disabled feature, or zero if unset.
+func GetFeatureDisabledStatus() int {
+	return viper.GetInt(KeyFeaturesDisabledStatus)
+}
+
+func toFeatureFlags(m map[string]interface{}) map[string]bool {
+	flags := make(map[string]bool, len(m))
+	for name, value := range m {
+		flags[strings.ToLower(name)] = cast.ToBool(value)
+	}
+	return flags
+}
+
 func NewDefault() (cfg *Config, err error) {
 	var pgxLogLevel int = pgx.LogLevelInfo
 	switch logLevel := strings.ToUpper(viper.GetString(KeyLogLevel)); logLevel {
@@ -140,6 +765,74 @@ func NewDefault() (cfg *Config, err error) {
 		if prefix := viper.GetString(KeyTritonKeyPrefix); prefix != "" {
 			httpServerConfig.KeyNamePrefix = prefix
 		}
+
+		httpServerConfig.ReadyFailureThreshold = 0.5
+		if viper.IsSet(KeyHTTPServerReadyFailureThreshold) {
+			httpServerConfig.ReadyFailureThreshold = viper.GetFloat64(KeyHTTPServerReadyFailureThreshold)
+		}
+
+		httpServerConfig.ReadyFailureWindow = 5 * time.Minute
+		if window := viper.GetDuration(KeyHTTPServerReadyFailureWindow); window != 0 {
+			httpServerConfig.ReadyFailureWindow = window
+		}
+
+		httpServerConfig.ReadyMinSamples = 5
+		if samples := viper.GetInt(KeyHTTPServerReadyMinSamples); samples != 0 {
+			httpServerConfig.ReadyMinSamples = samples
+		}
+
+		httpServerConfig.ShutdownTimeout = 30 * time.Second
+		if timeout := viper.GetDuration(KeyHTTPServerShutdownTimeout); timeout > 0 {
+			httpServerConfig.ShutdownTimeout = timeout
+		}
+
+		httpServerConfig.RequestTimeout = 30 * time.Second
+		if viper.IsSet(KeyHTTPServerRequestTimeout) {
+			httpServerConfig.RequestTimeout = viper.GetDuration(KeyHTTPServerRequestTimeout)
+		}
+
+		httpServerConfig.CORSAllowedOrigins = viper.GetStringSlice(KeyHTTPServerCORSAllowedOrigins)
+		if err := validateCORSOrigins(httpServerConfig.CORSAllowedOrigins); err != nil {
+			return nil, err
+		}
+
+		httpServerConfig.CompressionMinSize = 1024
+		if viper.IsSet(KeyHTTPServerCompressionMinSize) {
+			httpServerConfig.CompressionMinSize = viper.GetInt(KeyHTTPServerCompressionMinSize)
+		}
+
+		rateLimit := &httpServerConfig.RateLimit
+		rateLimit.AccountRate = 10
+		if viper.IsSet(KeyRateLimitAccountRate) {
+			rateLimit.AccountRate = viper.GetFloat64(KeyRateLimitAccountRate)
+		}
+
+		rateLimit.AccountBurst = 20
+		if burst := viper.GetInt(KeyRateLimitAccountBurst); burst != 0 {
+			rateLimit.AccountBurst = burst
+		}
+
+		rateLimit.UnauthenticatedRate = 1
+		if viper.IsSet(KeyRateLimitUnauthenticatedRate) {
+			rateLimit.UnauthenticatedRate = viper.GetFloat64(KeyRateLimitUnauthenticatedRate)
+		}
+
+		rateLimit.UnauthenticatedBurst = 5
+		if burst := viper.GetInt(KeyRateLimitUnauthenticatedBurst); burst != 0 {
+			rateLimit.UnauthenticatedBurst = burst
+		}
+
+		rateLimit.CleanupInterval = 5 * time.Minute
+		if interval := viper.GetDuration(KeyRateLimitCleanupInterval); interval > 0 {
+			rateLimit.CleanupInterval = interval
+		}
+
+		if rateLimit.AccountRate < 0 || rateLimit.UnauthenticatedRate < 0 {
+			return nil, errors.New("rate limits must not be negative")
+		}
+		if rateLimit.AccountBurst < 1 || rateLimit.UnauthenticatedBurst < 1 {
+			return nil, errors.New("rate limit bursts must be at least 1")
+		}
 	}
 
 	pgxLogger := &PGXLogger{}
@@ -161,13 +854,211 @@ func NewDefault() (cfg *Config, err error) {
 		if port := cast.ToUint16(viper.GetInt(KeyNomadPort)); port != 0 {
 			nomadConfig.Port = port
 		}
+
+		nomadConfig.Namespace = GetNomadNamespace()
+		nomadConfig.Region = GetNomadRegion()
+
+		nomadConfig.Datacenters = GetNomadDatacenters()
+		if err := validateNomadDatacenters(KeyNomadDatacenters, nomadConfig.Datacenters); err != nil {
+			return nil, err
+		}
+
+		if _, err := time.LoadLocation(GetNomadTimeZone()); err != nil {
+			return nil, fmt.Errorf("%s: unknown time zone %q", KeyNomadTimeZone, GetNomadTimeZone())
+		}
+	}
+
+	driftConfig := Drift{}
+	{
+		driftConfig.Policy = "alert"
+		if policy := strings.ToLower(viper.GetString(KeyDriftPolicy)); policy != "" {
+			driftConfig.Policy = policy
+		}
+		switch driftConfig.Policy {
+		case "off", "alert", "remediate":
+		default:
+			return nil, fmt.Errorf("unsupported drift policy: %q", driftConfig.Policy)
+		}
+
+		driftConfig.Interval = 5 * time.Minute
+		if interval := viper.GetDuration(KeyDriftInterval); interval < 0 {
+			return nil, fmt.Errorf("%s must be positive: %v", KeyDriftInterval, interval)
+		} else if interval != 0 {
+			driftConfig.Interval = interval
+		}
+
+		driftConfig.MaxRemediations = 5
+		if max := viper.GetInt(KeyDriftMaxRemediations); max != 0 {
+			driftConfig.MaxRemediations = max
+		}
+
+		driftConfig.MinHealthy = 0.5
+		if viper.IsSet(KeyDriftMinHealthy) {
+			driftConfig.MinHealthy = viper.GetFloat64(KeyDriftMinHealthy)
+		}
+
+		driftConfig.Freeze = viper.GetBool(KeyDriftFreeze)
+	}
+
+	// Fail on startup rather than when the first job is rendered
+	if _, err := GetArtifact(); err != nil {
+		return nil, err
+	}
+	if _, err := GetConstraints(); err != nil {
+		return nil, err
+	}
+	if _, err := GetKeySource(); err != nil {
+		return nil, err
+	}
+
+	// Likewise rather than when the first template or group is named
+	if pattern := GetNamePattern(); pattern != "" {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return nil, fmt.Errorf("invalid name pattern %q: %v", pattern, err)
+		}
+	}
+
+	dbConnectConfig := DBConnect{}
+	{
+		dbConnectConfig.Attempts = 5
+		if attempts := viper.GetInt(KeyCRDBConnectAttempts); attempts != 0 {
+			dbConnectConfig.Attempts = attempts
+		}
+		if dbConnectConfig.Attempts < 1 {
+			return nil, errors.New("database connect attempts must be at least 1")
+		}
+
+		dbConnectConfig.Timeout = time.Minute
+		if timeout := viper.GetDuration(KeyCRDBConnectTimeout); timeout != 0 {
+			dbConnectConfig.Timeout = timeout
+		}
+	}
+
+	dbPoolConfig := DBPool{}
+	{
+		dbPoolConfig.MaxConnections = DefaultDBMaxConnections
+		if maxConns := viper.GetInt(KeyCRDBMaxConnections); maxConns != 0 {
+			dbPoolConfig.MaxConnections = maxConns
+		}
+		if dbPoolConfig.MaxConnections < 1 {
+			return nil, errors.New("database max connections must be at least 1")
+		}
+
+		dbPoolConfig.AcquireTimeout = viper.GetDuration(KeyCRDBAcquireTimeout)
+		if dbPoolConfig.AcquireTimeout < 0 {
+			return nil, errors.New("database acquire timeout must not be negative")
+		}
+
+		params, err := GetSessionParams()
+		if err != nil {
+			return nil, err
+		}
+		if err := withStatementTimeout(params, viper.GetDuration(KeyCRDBStatementTimeout)); err != nil {
+			return nil, err
+		}
+		dbPoolConfig.AfterConnect = setSessionParams(params)
+	}
+
+	sloConfig := SLO{}
+	{
+		sloConfig.Target = 5 * time.Minute
+		if target := viper.GetDuration(KeySLOTarget); target != 0 {
+			sloConfig.Target = target
+		}
+
+		sloConfig.Timeout = 30 * time.Minute
+		if timeout := viper.GetDuration(KeySLOTimeout); timeout != 0 {
+			sloConfig.Timeout = timeout
+		}
+
+		sloConfig.Window = 24 * time.Hour
+		if window := viper.GetDuration(KeySLOWindow); window != 0 {
+			sloConfig.Window = window
+		}
+
+		sloConfig.Interval = 30 * time.Second
+		if interval := viper.GetDuration(KeySLOInterval); interval != 0 {
+			sloConfig.Interval = interval
+		}
+	}
+
+	alertsConfig := Alerts{}
+	{
+		alertsConfig.Interval = time.Minute
+		if interval := viper.GetDuration(KeyAlertsInterval); interval != 0 {
+			alertsConfig.Interval = interval
+		}
+
+		alertsConfig.WebhookURL = viper.GetString(KeyAlertsWebhookURL)
+		alertsConfig.WebhookSecret = viper.GetString(KeyAlertsWebhookSecret)
+		if alertsConfig.WebhookURL != "" && alertsConfig.WebhookSecret == "" {
+			return nil, errors.New("alerts webhook requires a signing secret")
+		}
+
+		format, err := webhooks.ParseFormat(viper.GetString(KeyAlertsWebhookFormat))
+		if err != nil {
+			return nil, err
+		}
+		alertsConfig.WebhookFormat = format
+
+		alertsConfig.EventSource = "/tsg/" + httpServerConfig.DC
+		if source := viper.GetString(KeyAlertsEventSource); source != "" {
+			alertsConfig.EventSource = source
+		}
+	}
+
+	budgetConfig := Budget{}
+	{
+		budgetConfig.Daily = viper.GetDuration(KeyBudgetDaily)
+
+		if resetAt := viper.GetString(KeyBudgetResetAt); resetAt != "" {
+			t, err := time.Parse("15:04", resetAt)
+			if err != nil {
+				return nil, fmt.Errorf("unsupported budget reset time: %q", resetAt)
+			}
+			budgetConfig.ResetAt = time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute
+		}
+
+		budgetConfig.Interval = time.Minute
+		if interval := viper.GetDuration(KeyBudgetInterval); interval != 0 {
+			budgetConfig.Interval = interval
+		}
+	}
+
+	datacenters, err := getDatacenters(httpServerConfig.DC)
+	if err != nil {
+		return nil, err
+	}
+
+	var jobTemplate string
+	if path := viper.GetString(KeyNomadJobTemplate); path != "" {
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read job template: %v", err)
+		}
+		jobTemplate = string(src)
+	}
+
+	pprofBind := "127.0.0.1"
+	if bind := viper.GetString(KeyPProfBind); bind != "" {
+		pprofBind = bind
+	}
+
+	pprofPort := uint16(9090)
+	if port := viper.GetInt(KeyPProfPort); port != 0 {
+		pprofPort = uint16(port)
+	}
+
+	enablePprof := viper.GetBool(KeyPProfEnable)
+	if enablePprof && pprofPort == httpServerConfig.Port {
+		return nil, fmt.Errorf("%s must differ from %s, pprof is never served by the API", KeyPProfPort, KeyHTTPServerPort)
 	}
 
 	return &Config{
 		DBPool: pgx.ConnPoolConfig{
-			MaxConnections: 5,
-			AfterConnect:   nil,
-			AcquireTimeout: 0,
+			MaxConnections: dbPoolConfig.MaxConnections,
+			AfterConnect:   dbPoolConfig.AfterConnect,
+			AcquireTimeout: dbPoolConfig.AcquireTimeout,
 
 			ConnConfig: pgx.ConnConfig{
 				Database: viper.GetString(KeyCRDBDatabase),
@@ -183,12 +1074,68 @@ func NewDefault() (cfg *Config, err error) {
 				},
 			},
 		},
+		DBConnect:  dbConnectConfig,
 		Agent:      agentConfig,
 		HTTPServer: httpServerConfig,
 		Nomad:      nomadConfig,
+		NomadRetry: GetNomadRetry(),
+		Drift:      driftConfig,
+		Alerts:     alertsConfig,
+		SLO:        sloConfig,
+		Budget:     budgetConfig,
+
+		Datacenters: datacenters,
+		JobTemplate: jobTemplate,
+
+		EnablePprof: enablePprof,
+		PprofAddr:   fmt.Sprintf("%s:%d", pprofBind, pprofPort),
 	}, nil
 }
 
+// getDatacenters reads the remote datacenters, each configured as a table
+// under datacenters keyed by its name.
+func getDatacenters(local string) (map[string]Datacenter, error) {
+	datacenters := make(map[string]Datacenter)
+	for name := range viper.GetStringMap(KeyDatacenters) {
+		if name == local {
+			return nil, fmt.Errorf("datacenter %q is the local datacenter", name)
+		}
+
+		key := func(k string) string {
+			return strings.Join([]string{KeyDatacenters, name, k}, ".")
+		}
+
+		dc := Datacenter{
+			Nomad: Nomad{
+				Addr:      viper.GetString(key("nomad-url")),
+				Port:      4646,
+				Namespace: GetNomadNamespace(),
+				Region:    GetNomadRegion(),
+			},
+			TritonURL: viper.GetString(key("triton-url")),
+		}
+		if port := cast.ToUint16(viper.GetInt(key("nomad-port"))); port != 0 {
+			dc.Port = port
+		}
+		if namespace := viper.GetString(key("nomad-namespace")); namespace != "" {
+			dc.Namespace = namespace
+		}
+		if region := viper.GetString(key("nomad-region")); region != "" {
+			dc.Region = region
+		}
+		dc.Datacenters = viper.GetStringSlice(key("nomad-datacenters"))
+		if err := validateNomadDatacenters(key("nomad-datacenters"), dc.Datacenters); err != nil {
+			return nil, err
+		}
+		if dc.Addr == "" || dc.TritonURL == "" {
+			return nil, fmt.Errorf("datacenter %q requires a nomad-url and triton-url", name)
+		}
+
+		datacenters[name] = dc
+	}
+	return datacenters, nil
+}
+
 // IsDebug returns true when the server is configured for debug level
 func IsDebug() bool {
 	switch logLevel := strings.ToUpper(viper.GetString(KeyLogLevel)); logLevel {
@@ -198,3 +1145,29 @@ func IsDebug() bool {
 		return false
 	}
 }
+
+// GetLogLevel returns the configured level of logging.
+func GetLogLevel() (zerolog.Level, error) {
+	switch logLevel := strings.ToUpper(viper.GetString(KeyLogLevel)); logLevel {
+	case "DEBUG":
+		return zerolog.DebugLevel, nil
+	case "INFO":
+		return zerolog.InfoLevel, nil
+	case "WARN":
+		return zerolog.WarnLevel, nil
+	case "ERROR":
+		return zerolog.ErrorLevel, nil
+	case "FATAL":
+		return zerolog.FatalLevel, nil
+	default:
+		// FIXME(seanc@): move the supported log levels into a global constant
+		return zerolog.NoLevel, fmt.Errorf("unsupported error level: %q (supported levels: %s)", logLevel,
+			strings.Join([]string{
+				"DEBUG",
+				"INFO",
+				"WARN",
+				"ERROR",
+				"FATAL",
+			}, " "))
+	}
+}
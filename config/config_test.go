@@ -0,0 +1,428 @@
+package config_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/webhooks"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDefaultDatacenters(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set(config.KeyLogLevel, "INFO")
+	viper.Set(config.KeyAgentLogFormat, "json")
+	viper.Set(config.KeyTritonDC, "us-east-1")
+	viper.Set(config.KeyDatacenters, map[string]interface{}{
+		"us-west-1": map[string]interface{}{
+			"nomad-url":  "10.0.0.5",
+			"triton-url": "https://us-west-1.api.joyent.com",
+		},
+	})
+
+	cfg, err := config.NewDefault()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]config.Datacenter{
+		"us-west-1": {
+			Nomad:     config.Nomad{Addr: "10.0.0.5", Port: 4646, Namespace: "default"},
+			TritonURL: "https://us-west-1.api.joyent.com",
+		},
+	}, cfg.Datacenters)
+	assert.Equal(t, "default", cfg.Nomad.Namespace)
+
+	viper.Set(config.KeyNomadNamespace, "tsg")
+	viper.Set(config.KeyNomadRegion, "us")
+	viper.Set(config.KeyDatacenters, map[string]interface{}{
+		"us-west-1": map[string]interface{}{
+			"nomad-url":  "10.0.0.5",
+			"triton-url": "https://us-west-1.api.joyent.com",
+		},
+		"eu-ams-1": map[string]interface{}{
+			"nomad-url":         "10.0.0.7",
+			"nomad-namespace":   "tsg-eu",
+			"nomad-region":      "eu",
+			"nomad-datacenters": []string{"eu-ams-1a", "eu-ams-1b"},
+			"triton-url":        "https://eu-ams-1.api.joyent.com",
+		},
+	})
+	cfg, err = config.NewDefault()
+	require.NoError(t, err)
+	assert.Equal(t, "tsg", cfg.Nomad.Namespace)
+	assert.Equal(t, "tsg", cfg.Datacenters["us-west-1"].Namespace)
+	assert.Equal(t, "tsg-eu", cfg.Datacenters["eu-ams-1"].Namespace)
+	assert.Equal(t, "us", cfg.Nomad.Region)
+	assert.Equal(t, "us", cfg.Datacenters["us-west-1"].Region)
+	assert.Equal(t, "eu", cfg.Datacenters["eu-ams-1"].Region)
+	assert.Empty(t, cfg.Datacenters["us-west-1"].Datacenters)
+	assert.Equal(t, []string{"eu-ams-1a", "eu-ams-1b"}, cfg.Datacenters["eu-ams-1"].Datacenters)
+	viper.Set(config.KeyNomadNamespace, nil)
+	viper.Set(config.KeyNomadRegion, nil)
+
+	viper.Set(config.KeyNomadDatacenters, []string{"us-east-1a", ""})
+	_, err = config.NewDefault()
+	assert.EqualError(t, err, "nomad.datacenters must not contain an empty datacenter")
+	viper.Set(config.KeyNomadDatacenters, nil)
+
+	viper.Set(config.KeyDatacenters, map[string]interface{}{
+		"us-east-1": map[string]interface{}{
+			"nomad-url":  "10.0.0.6",
+			"triton-url": "https://us-east-1.api.joyent.com",
+		},
+	})
+	_, err = config.NewDefault()
+	assert.EqualError(t, err, `datacenter "us-east-1" is the local datacenter`)
+
+	viper.Set(config.KeyDatacenters, map[string]interface{}{
+		"us-west-1": map[string]interface{}{"nomad-url": "10.0.0.5"},
+	})
+	_, err = config.NewDefault()
+	assert.EqualError(t, err, `datacenter "us-west-1" requires a nomad-url and triton-url`)
+}
+
+func TestNewDefaultWebhookFormat(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set(config.KeyLogLevel, "INFO")
+	viper.Set(config.KeyAgentLogFormat, "json")
+	viper.Set(config.KeyTritonDC, "us-west-1")
+
+	cfg, err := config.NewDefault()
+	require.NoError(t, err)
+	assert.Equal(t, webhooks.FormatRaw, cfg.Alerts.WebhookFormat)
+	assert.Equal(t, "/tsg/us-west-1", cfg.Alerts.EventSource)
+
+	viper.Set(config.KeyAlertsWebhookFormat, "CloudEvents")
+	viper.Set(config.KeyAlertsEventSource, "https://tsg.us-west-1.example.com")
+	cfg, err = config.NewDefault()
+	require.NoError(t, err)
+	assert.Equal(t, webhooks.FormatCloudEvents, cfg.Alerts.WebhookFormat)
+	assert.Equal(t, "https://tsg.us-west-1.example.com", cfg.Alerts.EventSource)
+
+	viper.Set(config.KeyAlertsWebhookFormat, "xml")
+	_, err = config.NewDefault()
+	assert.EqualError(t, err, `unsupported webhook format: "xml"`)
+}
+
+func TestNewDefaultDBConnect(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set(config.KeyLogLevel, "INFO")
+	viper.Set(config.KeyAgentLogFormat, "json")
+
+	cfg, err := config.NewDefault()
+	require.NoError(t, err)
+	assert.Equal(t, config.DBConnect{Attempts: 5, Timeout: time.Minute}, cfg.DBConnect)
+
+	viper.Set(config.KeyCRDBConnectAttempts, 10)
+	viper.Set(config.KeyCRDBConnectTimeout, "5m")
+	cfg, err = config.NewDefault()
+	require.NoError(t, err)
+	assert.Equal(t, config.DBConnect{Attempts: 10, Timeout: 5 * time.Minute}, cfg.DBConnect)
+
+	viper.Set(config.KeyCRDBConnectAttempts, -1)
+	_, err = config.NewDefault()
+	assert.EqualError(t, err, "database connect attempts must be at least 1")
+}
+
+func TestNewDefaultDBPool(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set(config.KeyLogLevel, "INFO")
+	viper.Set(config.KeyAgentLogFormat, "json")
+
+	cfg, err := config.NewDefault()
+	require.NoError(t, err)
+	assert.Equal(t, config.DefaultDBMaxConnections, cfg.DBPool.MaxConnections)
+	assert.Equal(t, time.Duration(0), cfg.DBPool.AcquireTimeout)
+	assert.Nil(t, cfg.DBPool.AfterConnect)
+
+	viper.Set(config.KeyCRDBMaxConnections, 20)
+	viper.Set(config.KeyCRDBAcquireTimeout, "5s")
+	viper.Set(config.KeyCRDBSessionParams, map[string]interface{}{
+		"statement_timeout": "30s",
+	})
+	cfg, err = config.NewDefault()
+	require.NoError(t, err)
+	assert.Equal(t, 20, cfg.DBPool.MaxConnections)
+	assert.Equal(t, 5*time.Second, cfg.DBPool.AcquireTimeout)
+	assert.NotNil(t, cfg.DBPool.AfterConnect)
+
+	viper.Set(config.KeyCRDBSessionParams, map[string]interface{}{
+		"statement_timeout; DROP": "30s",
+	})
+	_, err = config.NewDefault()
+	assert.EqualError(t, err, `invalid database session parameter: "statement_timeout; drop"`)
+
+	viper.Set(config.KeyCRDBSessionParams, nil)
+	viper.Set(config.KeyCRDBStatementTimeout, "30s")
+	cfg, err = config.NewDefault()
+	require.NoError(t, err)
+	assert.NotNil(t, cfg.DBPool.AfterConnect)
+
+	viper.Set(config.KeyCRDBSessionParams, map[string]interface{}{
+		"statement_timeout": "10s",
+	})
+	_, err = config.NewDefault()
+	assert.EqualError(t, err, "database statement timeout is set by both crdb.statement-timeout and crdb.session-params.statement_timeout")
+
+	viper.Set(config.KeyCRDBSessionParams, nil)
+	viper.Set(config.KeyCRDBStatementTimeout, "-1s")
+	_, err = config.NewDefault()
+	assert.EqualError(t, err, "database statement timeout must not be negative")
+
+	viper.Set(config.KeyCRDBStatementTimeout, nil)
+	viper.Set(config.KeyCRDBAcquireTimeout, "-1s")
+	_, err = config.NewDefault()
+	assert.EqualError(t, err, "database acquire timeout must not be negative")
+
+	viper.Set(config.KeyCRDBMaxConnections, -1)
+	_, err = config.NewDefault()
+	assert.EqualError(t, err, "database max connections must be at least 1")
+}
+
+func TestNewDefaultRateLimit(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set(config.KeyLogLevel, "INFO")
+	viper.Set(config.KeyAgentLogFormat, "json")
+
+	cfg, err := config.NewDefault()
+	require.NoError(t, err)
+	assert.Equal(t, config.RateLimit{
+		AccountRate:          10,
+		AccountBurst:         20,
+		UnauthenticatedRate:  1,
+		UnauthenticatedBurst: 5,
+		CleanupInterval:      5 * time.Minute,
+	}, cfg.RateLimit)
+
+	viper.Set(config.KeyRateLimitAccountRate, 0)
+	cfg, err = config.NewDefault()
+	require.NoError(t, err)
+	assert.Equal(t, 0.0, cfg.RateLimit.AccountRate)
+
+	viper.Set(config.KeyRateLimitUnauthenticatedRate, -1)
+	_, err = config.NewDefault()
+	assert.EqualError(t, err, "rate limits must not be negative")
+}
+
+func TestNewDefaultCORSAllowedOrigins(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set(config.KeyLogLevel, "INFO")
+	viper.Set(config.KeyAgentLogFormat, "json")
+
+	cfg, err := config.NewDefault()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.CORSAllowedOrigins)
+
+	viper.Set(config.KeyHTTPServerCORSAllowedOrigins, []string{"https://dashboard.example.com", "http://localhost:8080", "*"})
+	cfg, err = config.NewDefault()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"https://dashboard.example.com", "http://localhost:8080", "*"}, cfg.CORSAllowedOrigins)
+
+	for _, origin := range []string{"dashboard.example.com", "https://dashboard.example.com/", "ftp://dashboard.example.com"} {
+		viper.Set(config.KeyHTTPServerCORSAllowedOrigins, []string{origin})
+		_, err = config.NewDefault()
+		assert.EqualError(t, err, fmt.Sprintf("http.cors-allowed-origins: invalid origin %q", origin))
+	}
+}
+
+func TestNewDefaultJobTemplate(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set(config.KeyLogLevel, "INFO")
+	viper.Set(config.KeyAgentLogFormat, "json")
+
+	cfg, err := config.NewDefault()
+	require.NoError(t, err)
+	assert.Empty(t, cfg.JobTemplate)
+
+	dir, err := ioutil.TempDir("", "tsg-job-template")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "job.tmpl")
+	require.NoError(t, ioutil.WriteFile(path, []byte(`job "{{ .JobName }}" {}`), 0644))
+	viper.Set(config.KeyNomadJobTemplate, path)
+	cfg, err = config.NewDefault()
+	require.NoError(t, err)
+	assert.Equal(t, `job "{{ .JobName }}" {}`, cfg.JobTemplate)
+
+	viper.Set(config.KeyNomadJobTemplate, filepath.Join(dir, "missing.tmpl"))
+	_, err = config.NewDefault()
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unable to read job template")
+}
+
+func TestNewDefaultPprof(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set(config.KeyLogLevel, "INFO")
+	viper.Set(config.KeyAgentLogFormat, "json")
+
+	cfg, err := config.NewDefault()
+	require.NoError(t, err)
+	assert.False(t, cfg.EnablePprof)
+	assert.Equal(t, "127.0.0.1:9090", cfg.PprofAddr)
+
+	viper.Set(config.KeyPProfEnable, true)
+	viper.Set(config.KeyPProfBind, "10.0.0.5")
+	viper.Set(config.KeyPProfPort, 6060)
+	cfg, err = config.NewDefault()
+	require.NoError(t, err)
+	assert.True(t, cfg.EnablePprof)
+	assert.Equal(t, "10.0.0.5:6060", cfg.PprofAddr)
+
+	viper.Set(config.KeyPProfPort, 3000)
+	_, err = config.NewDefault()
+	assert.EqualError(t, err, "pprof.port must differ from http.port, pprof is never served by the API")
+}
+
+func TestNewDefaultNamePattern(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set(config.KeyLogLevel, "INFO")
+	viper.Set(config.KeyAgentLogFormat, "json")
+
+	_, err := config.NewDefault()
+	require.NoError(t, err)
+
+	viper.Set(config.KeyNamesPattern, `^[a-z]+$`)
+	_, err = config.NewDefault()
+	require.NoError(t, err)
+
+	viper.Set(config.KeyNamesPattern, `^[a-z+$`)
+	_, err = config.NewDefault()
+	assert.EqualError(t, err, "invalid name pattern \"^[a-z+$\": error parsing regexp: missing closing ]: `[a-z+$`")
+}
+
+func TestNewDefaultDriftInterval(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set(config.KeyLogLevel, "INFO")
+	viper.Set(config.KeyAgentLogFormat, "json")
+
+	cfg, err := config.NewDefault()
+	require.NoError(t, err)
+	assert.Equal(t, 5*time.Minute, cfg.Drift.Interval)
+
+	viper.Set(config.KeyDriftInterval, "30s")
+	cfg, err = config.NewDefault()
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, cfg.Drift.Interval)
+
+	viper.Set(config.KeyDriftInterval, "-1m")
+	_, err = config.NewDefault()
+	assert.EqualError(t, err, "drift.interval must be positive: -1m0s")
+}
+
+func TestGetNomadRetry(t *testing.T) {
+	defer viper.Reset()
+
+	assert.Equal(t, config.NomadRetry{
+		Attempts:       3,
+		InitialBackoff: 250 * time.Millisecond,
+		MaxBackoff:     5 * time.Second,
+	}, config.GetNomadRetry())
+
+	viper.Set(config.KeyNomadRetryAttempts, 5)
+	viper.Set(config.KeyNomadRetryInitialBackoff, "1s")
+	viper.Set(config.KeyNomadRetryMaxBackoff, "30s")
+	assert.Equal(t, config.NomadRetry{
+		Attempts:       5,
+		InitialBackoff: time.Second,
+		MaxBackoff:     30 * time.Second,
+	}, config.GetNomadRetry())
+}
+
+func TestGetTaskResources(t *testing.T) {
+	defer viper.Reset()
+
+	assert.Equal(t, config.DefaultTaskCPU, config.GetTaskCPU())
+	assert.Equal(t, config.DefaultTaskMemoryMB, config.GetTaskMemoryMB())
+
+	viper.Set(config.KeyNomadTaskCPU, 1000)
+	viper.Set(config.KeyNomadTaskMemoryMB, 2048)
+	assert.Equal(t, 1000, config.GetTaskCPU())
+	assert.Equal(t, 2048, config.GetTaskMemoryMB())
+
+	viper.Set(config.KeyNomadTaskCPU, -1)
+	viper.Set(config.KeyNomadTaskMemoryMB, 0)
+	assert.Equal(t, config.DefaultTaskCPU, config.GetTaskCPU())
+	assert.Equal(t, config.DefaultTaskMemoryMB, config.GetTaskMemoryMB())
+}
+
+func TestGetForceOnSubmit(t *testing.T) {
+	defer viper.Reset()
+
+	assert.True(t, config.GetForceOnSubmit())
+
+	viper.Set(config.KeyNomadForceOnSubmit, false)
+	assert.False(t, config.GetForceOnSubmit())
+}
+
+func TestGetDeregisterWait(t *testing.T) {
+	defer viper.Reset()
+
+	assert.Equal(t, config.DefaultDeregisterWait, config.GetDeregisterWait())
+
+	viper.Set(config.KeyNomadDeregisterWait, "30s")
+	assert.Equal(t, 30*time.Second, config.GetDeregisterWait())
+
+	// Waiting can still be disabled.
+	viper.Set(config.KeyNomadDeregisterWait, "0s")
+	assert.Equal(t, time.Duration(0), config.GetDeregisterWait())
+}
+
+func TestGetReconcileWorkers(t *testing.T) {
+	defer viper.Reset()
+
+	assert.Equal(t, config.DefaultReconcileWorkers, config.GetReconcileWorkers())
+
+	viper.Set(config.KeyNomadReconcileWorkers, 16)
+	assert.Equal(t, 16, config.GetReconcileWorkers())
+
+	viper.Set(config.KeyNomadReconcileWorkers, 0)
+	assert.Equal(t, config.DefaultReconcileWorkers, config.GetReconcileWorkers())
+}
+
+func TestGetMaxCapacity(t *testing.T) {
+	defer viper.Reset()
+
+	assert.Equal(t, config.DefaultMaxCapacity, config.GetMaxCapacity("testacct"))
+
+	viper.Set(config.KeyCapacityMax, 50)
+	viper.Set(config.KeyCapacityAccounts, map[string]interface{}{
+		"bigacct": 500,
+		"badacct": -1,
+	})
+	assert.Equal(t, 50, config.GetMaxCapacity("testacct"))
+	assert.Equal(t, 500, config.GetMaxCapacity("BigAcct"))
+	assert.Equal(t, 50, config.GetMaxCapacity("badacct"))
+}
+
+func TestGetJobType(t *testing.T) {
+	defer viper.Reset()
+
+	jobType, err := config.GetJobType()
+	require.NoError(t, err)
+	assert.Equal(t, config.JobTypeBatch, jobType)
+
+	viper.Set(config.KeyNomadJobType, "Service")
+	jobType, err = config.GetJobType()
+	require.NoError(t, err)
+	assert.Equal(t, config.JobTypeService, jobType)
+
+	viper.Set(config.KeyNomadJobType, "system")
+	_, err = config.GetJobType()
+	assert.EqualError(t, err, `unsupported nomad job type: "system"`)
+}
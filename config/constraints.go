@@ -0,0 +1,93 @@
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+var (
+	constraintInterpolation = regexp.MustCompile(`^\$\{[^${}"\\]+\}$`)
+	constraintOperators     = []string{"=", "!=", ">", ">=", "<", "<=", "regexp", "version", "set_contains"}
+)
+
+// Constraint is a placement rule which the Nomad clients running the jobs of
+// groups must satisfy.
+type Constraint struct {
+	// Attribute is the client attribute compared against Value, such as
+	// "${meta.role}".
+	Attribute string
+	Operator  string
+	Value     string
+}
+
+// DefaultConstraints place the jobs of groups on clients whose role is
+// "automater".
+var DefaultConstraints = []Constraint{
+	{Attribute: "${meta.role}", Operator: "=", Value: "automater"},
+}
+
+// GetConstraints returns the validated placement constraints of the jobs of
+// groups, configured as an array of tables under nomad.constraints. Unless
+// it's set, DefaultConstraints apply; an empty array places jobs on any
+// client.
+func GetConstraints() ([]Constraint, error) {
+	if !viper.IsSet(KeyNomadConstraints) {
+		constraints := make([]Constraint, len(DefaultConstraints))
+		copy(constraints, DefaultConstraints)
+		return constraints, nil
+	}
+
+	tables, err := cast.ToSliceE(viper.Get(KeyNomadConstraints))
+	if err != nil {
+		return nil, fmt.Errorf("nomad constraints must be an array of tables")
+	}
+
+	constraints := make([]Constraint, 0, len(tables))
+	for _, table := range tables {
+		fields, err := cast.ToStringMapStringE(table)
+		if err != nil {
+			return nil, fmt.Errorf("nomad constraints must be an array of tables")
+		}
+
+		constraint := Constraint{
+			Attribute: fields["attribute"],
+			Operator:  fields["operator"],
+			Value:     fields["value"],
+		}
+		if constraint.Operator == "" {
+			constraint.Operator = "="
+		}
+		if err := constraint.validate(); err != nil {
+			return nil, err
+		}
+
+		constraints = append(constraints, constraint)
+	}
+	return constraints, nil
+}
+
+// validate checks the constraint can be written into a job. Nomad reads an
+// attribute such as "${meta.role}" as a reference to a client attribute, so
+// attributes may only be a single reference, and values may not contain one.
+func (c Constraint) validate() error {
+	if c.Attribute == "" {
+		return fmt.Errorf("nomad constraint requires an attribute")
+	}
+	if strings.Contains(c.Attribute, "${") && !constraintInterpolation.MatchString(c.Attribute) {
+		return fmt.Errorf("invalid nomad constraint attribute: %q", c.Attribute)
+	}
+	if strings.Contains(c.Value, "${") {
+		return fmt.Errorf("invalid nomad constraint value for %q: %q", c.Attribute, c.Value)
+	}
+
+	for _, operator := range constraintOperators {
+		if c.Operator == operator {
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported nomad constraint operator: %q", c.Operator)
+}
@@ -0,0 +1,63 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetConstraints(t *testing.T) {
+	defer viper.Reset()
+
+	constraints, err := config.GetConstraints()
+	require.NoError(t, err)
+	assert.Equal(t, []config.Constraint{
+		{Attribute: "${meta.role}", Operator: "=", Value: "automater"},
+	}, constraints)
+
+	viper.Set(config.KeyNomadConstraints, []map[string]interface{}{
+		{"attribute": "${node.class}", "value": "tsg"},
+		{"attribute": "${attr.kernel.name}", "operator": "!=", "value": "windows"},
+	})
+	constraints, err = config.GetConstraints()
+	require.NoError(t, err)
+	assert.Equal(t, []config.Constraint{
+		{Attribute: "${node.class}", Operator: "=", Value: "tsg"},
+		{Attribute: "${attr.kernel.name}", Operator: "!=", Value: "windows"},
+	}, constraints)
+
+	viper.Set(config.KeyNomadConstraints, []interface{}{})
+	constraints, err = config.GetConstraints()
+	require.NoError(t, err)
+	assert.Empty(t, constraints)
+}
+
+func TestGetConstraintsInvalid(t *testing.T) {
+	tests := []struct {
+		name       string
+		constraint map[string]interface{}
+		err        string
+	}{
+		{"attribute", map[string]interface{}{"value": "automater"},
+			"nomad constraint requires an attribute"},
+		{"attribute interpolation", map[string]interface{}{"attribute": `${meta."role"}`, "value": "automater"},
+			`invalid nomad constraint attribute: "${meta.\"role\"}"`},
+		{"value interpolation", map[string]interface{}{"attribute": "${meta.role}", "value": "${node.class}"},
+			`invalid nomad constraint value for "${meta.role}": "${node.class}"`},
+		{"operator", map[string]interface{}{"attribute": "${meta.role}", "operator": "~", "value": "automater"},
+			`unsupported nomad constraint operator: "~"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer viper.Reset()
+			viper.Set(config.KeyNomadConstraints, []map[string]interface{}{tt.constraint})
+
+			_, err := config.GetConstraints()
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
@@ -8,6 +8,8 @@ import (
 )
 
 const (
+	KeyEnv = "env"
+
 	KeyLogLevel = "log.level"
 
 	KeyCRDBDatabase = "crdb.database"
@@ -17,6 +19,14 @@ const (
 	KeyCRDBPassword = "crdb.password"
 	KeyCRDBMode     = "crdb.mode"
 
+	KeyCRDBConnectAttempts = "crdb.connect-attempts"
+	KeyCRDBConnectTimeout  = "crdb.connect-timeout"
+
+	KeyCRDBMaxConnections   = "crdb.max-connections"
+	KeyCRDBAcquireTimeout   = "crdb.acquire-timeout"
+	KeyCRDBSessionParams    = "crdb.session-params"
+	KeyCRDBStatementTimeout = "crdb.statement-timeout"
+
 	KeyAgentLogFormat = "agent.log-format"
 
 	KeyGoogleAgentEnable = "gops.enable"
@@ -27,8 +37,21 @@ const (
 	KeyPProfBind   = "pprof.bind"
 	KeyPProfPort   = "pprof.port"
 
-	KeyHTTPServerBind = "http.bind"
-	KeyHTTPServerPort = "http.port"
+	KeyHTTPServerBind                  = "http.bind"
+	KeyHTTPServerCompressionMinSize    = "http.compression-min-size"
+	KeyHTTPServerCORSAllowedOrigins    = "http.cors-allowed-origins"
+	KeyHTTPServerPort                  = "http.port"
+	KeyHTTPServerReadyFailureThreshold = "http.ready-failure-threshold"
+	KeyHTTPServerReadyFailureWindow    = "http.ready-failure-window"
+	KeyHTTPServerReadyMinSamples       = "http.ready-min-samples"
+	KeyHTTPServerRequestTimeout        = "http.request-timeout"
+	KeyHTTPServerShutdownTimeout       = "http.shutdown-timeout"
+
+	KeyRateLimitAccountRate          = "ratelimit.account-rate"
+	KeyRateLimitAccountBurst         = "ratelimit.account-burst"
+	KeyRateLimitUnauthenticatedRate  = "ratelimit.unauthenticated-rate"
+	KeyRateLimitUnauthenticatedBurst = "ratelimit.unauthenticated-burst"
+	KeyRateLimitCleanupInterval      = "ratelimit.cleanup-interval"
 
 	KeyTritonDC        = "triton.dc"
 	KeyTritonURL       = "triton.url"
@@ -36,10 +59,109 @@ const (
 	KeyTritonKeyPrefix = "triton.key-prefix"
 	KeyTritonWhitelist = "triton.whitelist"
 
-	KeyNomadURL  = "nomad.url"
-	KeyNomadPort = "nomad.port"
-
-	KeyTSGCliVersion = "tsgcli.version"
+	KeyTritonCheckImages   = "triton.check-images"
+	KeyTritonImageCacheTTL = "triton.image-cache-ttl"
+
+	KeyTritonCheckPackages   = "triton.check-packages"
+	KeyTritonPackageCacheTTL = "triton.package-cache-ttl"
+
+	KeyTritonCheckNetworks   = "triton.check-networks"
+	KeyTritonNetworkCacheTTL = "triton.network-cache-ttl"
+
+	KeyTritonTeardownTimeout = "triton.teardown-timeout"
+
+	KeyTritonKeyGracePeriod     = "triton.key-grace-period"
+	KeyTritonKeyCleanupInterval = "triton.key-cleanup-interval"
+
+	KeyDatacenters = "datacenters"
+
+	KeyNomadURL             = "nomad.url"
+	KeyNomadPort            = "nomad.port"
+	KeyNomadDeregisterWait  = "nomad.deregister-wait"
+	KeyNomadJobCacheTTL     = "nomad.job-cache-ttl"
+	KeyNomadMaxJobSize      = "nomad.max-job-size"
+	KeyNomadJobType         = "nomad.job-type"
+	KeyNomadNamespace       = "nomad.namespace"
+	KeyNomadRegion          = "nomad.region"
+	KeyNomadDatacenters     = "nomad.datacenters"
+	KeyNomadTaskCPU         = "nomad.task-cpu"
+	KeyNomadTaskMemoryMB    = "nomad.task-memory-mb"
+	KeyNomadConstraints     = "nomad.constraints"
+	KeyNomadJobTemplate     = "nomad.job-template"
+	KeyNomadFirstRunTimeout = "nomad.first-run-timeout"
+	KeyNomadTimeZone        = "nomad.time-zone"
+	KeyNomadForceOnSubmit   = "nomad.force-on-submit"
+
+	KeyNomadReconcileWorkers = "nomad.reconcile-workers"
+
+	KeyNomadKeySource     = "nomad.key-source"
+	KeyNomadVaultPath     = "nomad.vault.path"
+	KeyNomadVaultField    = "nomad.vault.field"
+	KeyNomadVaultPolicies = "nomad.vault.policies"
+
+	KeyNomadRestartAttempts    = "nomad.restart.attempts"
+	KeyNomadRestartInterval    = "nomad.restart.interval"
+	KeyNomadRestartDelay       = "nomad.restart.delay"
+	KeyNomadRestartMode        = "nomad.restart.mode"
+	KeyNomadRescheduleAttempts = "nomad.reschedule.attempts"
+	KeyNomadRescheduleInterval = "nomad.reschedule.interval"
+
+	KeyNomadRetryAttempts       = "nomad.retry-attempts"
+	KeyNomadRetryInitialBackoff = "nomad.retry-initial-backoff"
+	KeyNomadRetryMaxBackoff     = "nomad.retry-max-backoff"
+
+	KeyDriftPolicy          = "drift.policy"
+	KeyDriftInterval        = "drift.interval"
+	KeyDriftMaxRemediations = "drift.max-remediations"
+	KeyDriftMinHealthy      = "drift.min-healthy"
+	KeyDriftFreeze          = "drift.freeze"
+
+	KeySLOTarget   = "slo.target"
+	KeySLOTimeout  = "slo.timeout"
+	KeySLOWindow   = "slo.window"
+	KeySLOInterval = "slo.interval"
+
+	KeyBudgetDaily    = "budget.daily"
+	KeyBudgetResetAt  = "budget.reset-at"
+	KeyBudgetInterval = "budget.interval"
+
+	KeyCanaryInterval = "canary.interval"
+
+	KeyStatusRefreshInterval = "status.refresh-interval"
+
+	KeyAlertsInterval      = "alerts.interval"
+	KeyAlertsWebhookURL    = "alerts.webhook-url"
+	KeyAlertsWebhookSecret = "alerts.webhook-secret"
+	KeyAlertsWebhookFormat = "alerts.webhook-format"
+	KeyAlertsEventSource   = "alerts.event-source"
+
+	KeyTagsRequired = "tags.required"
+
+	KeyGroupsRetention      = "groups.retention"
+	KeyGroupsPurgeInterval  = "groups.purge-interval"
+	KeyGroupsIdempotencyTTL = "groups.idempotency-ttl"
+
+	KeyCapacityMax      = "capacity.max"
+	KeyCapacityAccounts = "capacity.accounts"
+
+	KeyNamesMinLength = "names.min-length"
+	KeyNamesMaxLength = "names.max-length"
+	KeyNamesPattern   = "names.pattern"
+
+	KeyExportSigningKey = "export.signing-key"
+
+	KeyFeaturesDefaults       = "features.defaults"
+	KeyFeaturesAccounts       = "features.accounts"
+	KeyFeaturesDisabledStatus = "features.disabled-status"
+
+	KeyTSGCliVersion             = "tsgcli.version"
+	KeyTSGCliArtifactBaseURL     = "tsgcli.artifact-base-url"
+	KeyTSGCliArtifactDestination = "tsgcli.artifact-destination"
+	KeyTSGCliArtifactMode        = "tsgcli.artifact-mode"
+	KeyTSGCliArtifactUnpack      = "tsgcli.artifact-unpack"
+	KeyTSGCliArtifactOptions     = "tsgcli.artifact-options"
+	KeyTSGCliArtifactChecksums   = "tsgcli.artifact-checksums"
+	KeyTSGCliJSONArgs            = "tsgcli.json-args"
 )
 
 const (
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/spf13/viper"
+)
+
+var sessionParamName = regexp.MustCompile(`^[a-z_][a-z0-9_]*(\.[a-z_][a-z0-9_]*)?$`)
+
+// GetSessionParams returns the session parameters set on every database
+// connection, such as statement_timeout, configured as a table under
+// crdb.session-params.
+func GetSessionParams() (map[string]string, error) {
+	params := viper.GetStringMapString(KeyCRDBSessionParams)
+	for name := range params {
+		if !sessionParamName.MatchString(name) {
+			return nil, fmt.Errorf("invalid database session parameter: %q", name)
+		}
+	}
+	return params, nil
+}
+
+// withStatementTimeout adds the statement_timeout session parameter to params,
+// after which the database aborts any statement of the connection, so that a
+// stuck query can't hold on to a connection of the pool. A timeout of zero
+// leaves statements unbounded, unless params sets statement_timeout itself.
+func withStatementTimeout(params map[string]string, timeout time.Duration) error {
+	if timeout < 0 {
+		return errors.New("database statement timeout must not be negative")
+	}
+	if timeout == 0 {
+		return nil
+	}
+	if _, ok := params["statement_timeout"]; ok {
+		return fmt.Errorf("database statement timeout is set by both %s and %s.statement_timeout",
+			KeyCRDBStatementTimeout, KeyCRDBSessionParams)
+	}
+
+	params["statement_timeout"] = fmt.Sprintf("%dms", timeout/time.Millisecond)
+	return nil
+}
+
+// setSessionParams returns an AfterConnect hook which sets params on each new
+// connection of the pool, or nil if there are none to set.
+func setSessionParams(params map[string]string) func(*pgx.Conn) error {
+	if len(params) == 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return func(conn *pgx.Conn) error {
+		for _, name := range names {
+			// NOTE: SET doesn't take placeholders, so the value is quoted as a
+			// string literal, which every parameter accepts.
+			value := "'" + strings.Replace(params[name], "'", "''", -1) + "'"
+			if _, err := conn.Exec(fmt.Sprintf("SET %s = %s", name, value)); err != nil {
+				return fmt.Errorf("unable to set database session parameter %q: %v", name, err)
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,113 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/viper"
+)
+
+// RestartModeFail and RestartModeDelay are what Nomad does once a failed task
+// has used up its restart attempts: fail the allocation, or wait out the rest
+// of the interval and try again.
+const (
+	RestartModeFail  = "fail"
+	RestartModeDelay = "delay"
+)
+
+// RestartPolicy configures how Nomad restarts the failed tsg-cli task of a
+// group's job in place. Unset fields are left to Nomad's defaults.
+type RestartPolicy struct {
+	// Attempts is how many restarts are made within Interval.
+	Attempts *int
+	Interval *time.Duration
+	// Delay is how long Nomad waits before each restart.
+	Delay *time.Duration
+	// Mode is RestartModeFail or RestartModeDelay.
+	Mode string
+}
+
+// ReschedulePolicy configures how Nomad reschedules the failed allocation of
+// a group's job onto another client. Unset fields are left to Nomad's
+// defaults.
+type ReschedulePolicy struct {
+	// Attempts is how many reschedules are made within Interval.
+	Attempts *int
+	Interval *time.Duration
+}
+
+// GetRestartPolicy returns the validated restart policy of the jobs of
+// groups, configured under nomad.restart.
+func GetRestartPolicy() (RestartPolicy, error) {
+	var policy RestartPolicy
+	var err error
+
+	if policy.Attempts, err = getAttempts(KeyNomadRestartAttempts); err != nil {
+		return RestartPolicy{}, err
+	}
+	if policy.Interval, err = getInterval(KeyNomadRestartInterval); err != nil {
+		return RestartPolicy{}, err
+	}
+	if policy.Delay, err = getInterval(KeyNomadRestartDelay); err != nil {
+		return RestartPolicy{}, err
+	}
+
+	policy.Mode = viper.GetString(KeyNomadRestartMode)
+	if err := ValidateRestartMode(policy.Mode); err != nil {
+		return RestartPolicy{}, err
+	}
+
+	return policy, nil
+}
+
+// GetReschedulePolicy returns the validated reschedule policy of the jobs of
+// groups, configured under nomad.reschedule.
+func GetReschedulePolicy() (ReschedulePolicy, error) {
+	var policy ReschedulePolicy
+	var err error
+
+	if policy.Attempts, err = getAttempts(KeyNomadRescheduleAttempts); err != nil {
+		return ReschedulePolicy{}, err
+	}
+	if policy.Interval, err = getInterval(KeyNomadRescheduleInterval); err != nil {
+		return ReschedulePolicy{}, err
+	}
+
+	return policy, nil
+}
+
+// ValidateRestartMode returns an error unless mode is empty, to use Nomad's
+// default, or one of the restart modes.
+func ValidateRestartMode(mode string) error {
+	switch mode {
+	case "", RestartModeFail, RestartModeDelay:
+		return nil
+	default:
+		return fmt.Errorf("restart mode must be %q or %q", RestartModeFail, RestartModeDelay)
+	}
+}
+
+func getAttempts(key string) (*int, error) {
+	if !viper.IsSet(key) {
+		return nil, nil
+	}
+
+	attempts, err := cast.ToIntE(viper.Get(key))
+	if err != nil || attempts < 0 {
+		return nil, fmt.Errorf("%s must be a non-negative integer", key)
+	}
+	return &attempts, nil
+}
+
+func getInterval(key string) (*time.Duration, error) {
+	if !viper.IsSet(key) {
+		return nil, nil
+	}
+
+	interval, err := cast.ToDurationE(viper.Get(key))
+	if err != nil || interval < 0 {
+		return nil, fmt.Errorf("%s must be a non-negative duration", key)
+	}
+	return &interval, nil
+}
@@ -0,0 +1,68 @@
+package config_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetRestartPolicy(t *testing.T) {
+	defer viper.Reset()
+
+	policy, err := config.GetRestartPolicy()
+	require.NoError(t, err)
+	assert.Equal(t, config.RestartPolicy{}, policy)
+
+	viper.Set(config.KeyNomadRestartAttempts, 0)
+	viper.Set(config.KeyNomadRestartInterval, "30m")
+	viper.Set(config.KeyNomadRestartDelay, "15s")
+	viper.Set(config.KeyNomadRestartMode, "fail")
+	policy, err = config.GetRestartPolicy()
+	require.NoError(t, err)
+	require.NotNil(t, policy.Attempts)
+	assert.Equal(t, 0, *policy.Attempts)
+	require.NotNil(t, policy.Interval)
+	assert.Equal(t, 30*time.Minute, *policy.Interval)
+	require.NotNil(t, policy.Delay)
+	assert.Equal(t, 15*time.Second, *policy.Delay)
+	assert.Equal(t, config.RestartModeFail, policy.Mode)
+
+	viper.Set(config.KeyNomadRestartMode, "retry")
+	_, err = config.GetRestartPolicy()
+	assert.EqualError(t, err, `restart mode must be "fail" or "delay"`)
+	viper.Set(config.KeyNomadRestartMode, "")
+
+	viper.Set(config.KeyNomadRestartAttempts, -1)
+	_, err = config.GetRestartPolicy()
+	assert.EqualError(t, err, "nomad.restart.attempts must be a non-negative integer")
+	viper.Set(config.KeyNomadRestartAttempts, 2)
+
+	viper.Set(config.KeyNomadRestartDelay, "-15s")
+	_, err = config.GetRestartPolicy()
+	assert.EqualError(t, err, "nomad.restart.delay must be a non-negative duration")
+}
+
+func TestGetReschedulePolicy(t *testing.T) {
+	defer viper.Reset()
+
+	policy, err := config.GetReschedulePolicy()
+	require.NoError(t, err)
+	assert.Equal(t, config.ReschedulePolicy{}, policy)
+
+	viper.Set(config.KeyNomadRescheduleAttempts, 3)
+	viper.Set(config.KeyNomadRescheduleInterval, "1h")
+	policy, err = config.GetReschedulePolicy()
+	require.NoError(t, err)
+	require.NotNil(t, policy.Attempts)
+	assert.Equal(t, 3, *policy.Attempts)
+	require.NotNil(t, policy.Interval)
+	assert.Equal(t, time.Hour, *policy.Interval)
+
+	viper.Set(config.KeyNomadRescheduleInterval, "soon")
+	_, err = config.GetReschedulePolicy()
+	assert.EqualError(t, err, "nomad.reschedule.interval must be a non-negative duration")
+}
@@ -0,0 +1,96 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	// KeySourceInline writes the account's private key into the job of each
+	// group, as an argument of tsg-cli.
+	KeySourceInline = "inline"
+	// KeySourceVault has the task of each group's job read the account's
+	// private key from Vault, so the key is never part of the job.
+	KeySourceVault = "vault"
+
+	DefaultVaultField = "key_material"
+)
+
+var (
+	vaultPathRule  = regexp.MustCompile(`^[a-zA-Z0-9_.-]+(/[a-zA-Z0-9_.-]+)*$`)
+	vaultFieldRule = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*(\.[a-zA-Z_][a-zA-Z0-9_]*)*$`)
+	vaultPolicy    = regexp.MustCompile(`^[a-zA-Z0-9_.-]+$`)
+)
+
+// KeySource configures where the task of a group's job gets the private key
+// of the group's account from.
+type KeySource struct {
+	// Source is KeySourceInline or KeySourceVault.
+	Source string
+	// VaultPath is the path below which the key of each account is kept in
+	// Vault, in a secret named for the account's ID.
+	VaultPath string
+	// VaultField is the field of the secret holding the key, such as
+	// "data.key_material" for a version 2 key/value secrets engine.
+	VaultField string
+	// VaultPolicies are the Vault policies granted to the task, which must
+	// allow it to read the secret.
+	VaultPolicies []string
+}
+
+// GetKeySource returns the validated key source, defaulting to
+// KeySourceInline.
+func GetKeySource() (KeySource, error) {
+	source := KeySource{
+		Source:     strings.ToLower(viper.GetString(KeyNomadKeySource)),
+		VaultField: DefaultVaultField,
+	}
+
+	switch source.Source {
+	case "", KeySourceInline:
+		return KeySource{Source: KeySourceInline}, nil
+	case KeySourceVault:
+	default:
+		return KeySource{}, fmt.Errorf("unsupported key source: %q", source.Source)
+	}
+
+	source.VaultPath = strings.Trim(viper.GetString(KeyNomadVaultPath), "/")
+	if field := viper.GetString(KeyNomadVaultField); field != "" {
+		source.VaultField = field
+	}
+	source.VaultPolicies = viper.GetStringSlice(KeyNomadVaultPolicies)
+
+	if err := source.validate(); err != nil {
+		return KeySource{}, err
+	}
+
+	return source, nil
+}
+
+// SecretPath returns the path of the secret holding the key of the account
+// with the given ID.
+func (k KeySource) SecretPath(accountID string) string {
+	return k.VaultPath + "/" + accountID
+}
+
+func (k KeySource) validate() error {
+	if !vaultPathRule.MatchString(k.VaultPath) {
+		return fmt.Errorf("vault path must be a path of letters, digits, '_', '.' and '-': %q", k.VaultPath)
+	}
+	if !vaultFieldRule.MatchString(k.VaultField) {
+		return fmt.Errorf("invalid vault field: %q", k.VaultField)
+	}
+	if len(k.VaultPolicies) == 0 {
+		return errors.New("vault policies must be set to read keys from vault")
+	}
+	for _, policy := range k.VaultPolicies {
+		if !vaultPolicy.MatchString(policy) {
+			return fmt.Errorf("invalid vault policy: %q", policy)
+		}
+	}
+	return nil
+}
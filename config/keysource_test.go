@@ -0,0 +1,107 @@
+package config_test
+
+import (
+	"testing"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetKeySource(t *testing.T) {
+	defer viper.Reset()
+
+	source, err := config.GetKeySource()
+	require.NoError(t, err)
+	assert.Equal(t, config.KeySource{Source: config.KeySourceInline}, source)
+
+	viper.Set(config.KeyNomadKeySource, "Vault")
+	viper.Set(config.KeyNomadVaultPath, "/secret/tsg/")
+	viper.Set(config.KeyNomadVaultPolicies, []string{"tsg"})
+
+	source, err = config.GetKeySource()
+	require.NoError(t, err)
+	assert.Equal(t, config.KeySource{
+		Source:        config.KeySourceVault,
+		VaultPath:     "secret/tsg",
+		VaultField:    "key_material",
+		VaultPolicies: []string{"tsg"},
+	}, source)
+	assert.Equal(t, "secret/tsg/6d6f3c38-2ed5-4b7a-a0ad-8a2caa32bbb4", source.SecretPath("6d6f3c38-2ed5-4b7a-a0ad-8a2caa32bbb4"))
+
+	viper.Set(config.KeyNomadVaultField, "data.key_material")
+	source, err = config.GetKeySource()
+	require.NoError(t, err)
+	assert.Equal(t, "data.key_material", source.VaultField)
+}
+
+func TestGetKeySourceInvalid(t *testing.T) {
+	tests := []struct {
+		name     string
+		settings map[string]interface{}
+		err      string
+	}{
+		{
+			name:     "source",
+			settings: map[string]interface{}{config.KeyNomadKeySource: "file"},
+			err:      `unsupported key source: "file"`,
+		},
+		{
+			name: "no path",
+			settings: map[string]interface{}{
+				config.KeyNomadKeySource:     "vault",
+				config.KeyNomadVaultPolicies: []string{"tsg"},
+			},
+			err: `vault path must be a path of letters, digits, '_', '.' and '-': ""`,
+		},
+		{
+			name: "path",
+			settings: map[string]interface{}{
+				config.KeyNomadKeySource:     "vault",
+				config.KeyNomadVaultPath:     `secret/"tsg"`,
+				config.KeyNomadVaultPolicies: []string{"tsg"},
+			},
+			err: `vault path must be a path of letters, digits, '_', '.' and '-': "secret/\"tsg\""`,
+		},
+		{
+			name: "field",
+			settings: map[string]interface{}{
+				config.KeyNomadKeySource:     "vault",
+				config.KeyNomadVaultPath:     "secret/tsg",
+				config.KeyNomadVaultField:    "key ]]",
+				config.KeyNomadVaultPolicies: []string{"tsg"},
+			},
+			err: `invalid vault field: "key ]]"`,
+		},
+		{
+			name: "no policies",
+			settings: map[string]interface{}{
+				config.KeyNomadKeySource: "vault",
+				config.KeyNomadVaultPath: "secret/tsg",
+			},
+			err: "vault policies must be set to read keys from vault",
+		},
+		{
+			name: "policy",
+			settings: map[string]interface{}{
+				config.KeyNomadKeySource:     "vault",
+				config.KeyNomadVaultPath:     "secret/tsg",
+				config.KeyNomadVaultPolicies: []string{`tsg"`},
+			},
+			err: `invalid vault policy: "tsg\""`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			defer viper.Reset()
+			for key, value := range test.settings {
+				viper.Set(key, value)
+			}
+
+			_, err := config.GetKeySource()
+			assert.EqualError(t, err, test.err)
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	toml "github.com/pelletier/go-toml"
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// profilesKey is the config table which holds every named environment
+// profile.
+const profilesKey = "profiles"
+
+var matchProfileName = regexp.MustCompile(`^[a-zA-Z0-9_-]+$`)
+
+// ApplyProfile merges the named environment profile over the base
+// configuration already read into v. Profiles live under the "profiles" table
+// of the config file and may override any base setting, e.g.
+//
+//	[profiles.staging.crdb]
+//	host = "crdb.staging.internal"
+//
+// Profile values replace those of the base config file, while environment
+// variables and command line flags continue to take precedence over both. An
+// empty name leaves the base configuration untouched and an unknown name
+// returns an error.
+func ApplyProfile(v *viper.Viper, name string) error {
+	if name == "" {
+		return nil
+	}
+
+	if !matchProfileName.MatchString(name) {
+		return fmt.Errorf("invalid config profile name: %q", name)
+	}
+
+	profile := v.Sub(strings.Join([]string{profilesKey, name}, "."))
+	if profile == nil {
+		return fmt.Errorf("unknown config profile: %q", name)
+	}
+
+	// NOTE: Viper only merges config in the format of the config file
+	// itself, so the overlay is re-encoded to match before merging.
+	var (
+		overlay []byte
+		err     error
+	)
+	switch configType := strings.TrimPrefix(filepath.Ext(v.ConfigFileUsed()), "."); configType {
+	case "", "toml":
+		var tree *toml.Tree
+		tree, err = toml.TreeFromMap(profile.AllSettings())
+		if err == nil {
+			var encoded string
+			encoded, err = tree.ToTomlString()
+			overlay = []byte(encoded)
+		}
+	case "json", "yaml", "yml":
+		// JSON is also valid YAML
+		overlay, err = json.Marshal(profile.AllSettings())
+	default:
+		return fmt.Errorf("config profiles are not supported for %q config files", configType)
+	}
+	if err != nil {
+		return errors.Wrapf(err, "unable to encode config profile %q", name)
+	}
+
+	if err := v.MergeConfig(bytes.NewReader(overlay)); err != nil {
+		return errors.Wrapf(err, "unable to merge config profile %q", name)
+	}
+
+	return nil
+}
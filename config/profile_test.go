@@ -0,0 +1,88 @@
+package config_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const profileConfig = `
+[log]
+level = "INFO"
+
+[crdb]
+database = "triton"
+host = "127.0.0.1"
+port = 26257
+
+[profiles.staging.crdb]
+host = "crdb.staging.internal"
+
+[profiles.prod.log]
+level = "WARN"
+
+[profiles.prod.crdb]
+host = "crdb.prod.internal"
+database = "triton_prod"
+`
+
+func newProfileViper(t *testing.T) *viper.Viper {
+	v := viper.New()
+	v.SetConfigType("toml")
+	require.NoError(t, v.ReadConfig(strings.NewReader(profileConfig)))
+	return v
+}
+
+func TestApplyProfile(t *testing.T) {
+	t.Run("base only", func(t *testing.T) {
+		v := newProfileViper(t)
+		require.NoError(t, config.ApplyProfile(v, ""))
+
+		assert.Equal(t, "127.0.0.1", v.GetString(config.KeyCRDBHost))
+		assert.Equal(t, "triton", v.GetString(config.KeyCRDBDatabase))
+		assert.Equal(t, "INFO", v.GetString(config.KeyLogLevel))
+	})
+
+	t.Run("merges selected profile", func(t *testing.T) {
+		v := newProfileViper(t)
+		require.NoError(t, config.ApplyProfile(v, "staging"))
+
+		assert.Equal(t, "crdb.staging.internal", v.GetString(config.KeyCRDBHost))
+		assert.Equal(t, "triton", v.GetString(config.KeyCRDBDatabase))
+		assert.Equal(t, 26257, v.GetInt(config.KeyCRDBPort))
+		assert.Equal(t, "INFO", v.GetString(config.KeyLogLevel))
+	})
+
+	t.Run("only selected profile applies", func(t *testing.T) {
+		v := newProfileViper(t)
+		require.NoError(t, config.ApplyProfile(v, "prod"))
+
+		assert.Equal(t, "crdb.prod.internal", v.GetString(config.KeyCRDBHost))
+		assert.Equal(t, "triton_prod", v.GetString(config.KeyCRDBDatabase))
+		assert.Equal(t, "WARN", v.GetString(config.KeyLogLevel))
+	})
+
+	t.Run("overrides still win", func(t *testing.T) {
+		v := newProfileViper(t)
+		v.Set(config.KeyCRDBHost, "10.0.0.1")
+		require.NoError(t, config.ApplyProfile(v, "staging"))
+
+		assert.Equal(t, "10.0.0.1", v.GetString(config.KeyCRDBHost))
+	})
+
+	t.Run("unknown profile", func(t *testing.T) {
+		v := newProfileViper(t)
+		err := config.ApplyProfile(v, "qa")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown config profile")
+	})
+
+	t.Run("invalid profile name", func(t *testing.T) {
+		v := newProfileViper(t)
+		require.Error(t, config.ApplyProfile(v, "prod.crdb"))
+	})
+}
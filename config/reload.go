@@ -0,0 +1,131 @@
+package config
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/viper"
+)
+
+// restartKeys are the settings, or tables of settings, which are only read as
+// the agent starts: those of its listeners, connections and background
+// monitors. Every other setting is read as it's used and takes effect as soon
+// as the config is reloaded.
+var restartKeys = []string{
+	KeyEnv,
+	KeyAgentLogFormat,
+	"crdb",
+	"gops",
+	"pprof",
+	"http",
+	"ratelimit",
+	KeyTritonDC,
+	KeyTritonURL,
+	KeyTritonAuthURL,
+	KeyTritonKeyPrefix,
+	KeyTritonWhitelist,
+	KeyDatacenters,
+	KeyNomadURL,
+	KeyNomadPort,
+	KeyNomadNamespace,
+	KeyNomadRegion,
+	KeyNomadDatacenters,
+	KeyNomadJobTemplate,
+	"drift",
+	"slo",
+	"budget",
+	"status",
+	"alerts",
+}
+
+// Reload re-reads the config file, along with its environment profile, and
+// returns the settings which changed: those applied and those which require
+// a restart. The latter keep their running values until the agent restarts so
+// that what's running stays consistent. The reloaded config is validated the
+// same way as on startup, although settings read as they're used already
+// reflect it when it's invalid.
+func Reload() (applied, restart []string, err error) {
+	before := settings()
+	datacenters := viper.Get(KeyDatacenters)
+
+	if err := viper.ReadInConfig(); err != nil {
+		return nil, nil, errors.Wrap(err, "unable to read config file")
+	}
+	if err := ApplyProfile(viper.GetViper(), viper.GetString(KeyEnv)); err != nil {
+		return nil, nil, err
+	}
+
+	after := settings()
+	for _, key := range changedKeys(before, after) {
+		if !requiresRestart(key) {
+			applied = append(applied, key)
+			continue
+		}
+
+		restart = append(restart, key)
+		switch value, ok := before[key]; {
+		case strings.HasPrefix(key, KeyDatacenters+"."):
+			// NOTE: Datacenters are listed from the table as a whole, which
+			// a single setting would shadow.
+			viper.Set(KeyDatacenters, datacenters)
+		case ok:
+			viper.Set(key, value)
+		default:
+			// NOTE: Viper ignores a nil override, so a setting which was
+			// unset is pinned to the zero value it read as instead.
+			viper.Set(key, reflect.Zero(reflect.TypeOf(after[key])).Interface())
+		}
+	}
+
+	// NOTE: NewDefault panics on an unsupported log level.
+	if _, err := GetLogLevel(); err != nil {
+		return nil, nil, err
+	}
+	if _, err := NewDefault(); err != nil {
+		return nil, nil, err
+	}
+
+	return applied, restart, nil
+}
+
+// settings returns the current value of every setting, other than those of
+// profiles which are only read through ApplyProfile.
+func settings() map[string]interface{} {
+	values := make(map[string]interface{})
+	for _, key := range viper.AllKeys() {
+		if strings.HasPrefix(key, profilesKey+".") {
+			continue
+		}
+		values[key] = viper.Get(key)
+	}
+	return values
+}
+
+// changedKeys returns the sorted keys whose values differ between before and
+// after, including those set in only one of them.
+func changedKeys(before, after map[string]interface{}) []string {
+	var changed []string
+	for key, value := range after {
+		if !reflect.DeepEqual(before[key], value) {
+			changed = append(changed, key)
+		}
+	}
+	for key := range before {
+		if _, ok := after[key]; !ok {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+func requiresRestart(key string) bool {
+	for _, restartKey := range restartKeys {
+		if key == restartKey || strings.HasPrefix(key, restartKey+".") {
+			return true
+		}
+	}
+	return false
+}
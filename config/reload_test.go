@@ -0,0 +1,100 @@
+package config_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const reloadConfig = `
+[log]
+level = "INFO"
+
+[http]
+port = 3000
+
+[nomad]
+job-type = "batch"
+`
+
+func TestReload(t *testing.T) {
+	defer viper.Reset()
+	viper.SetDefault(config.KeyAgentLogFormat, "json")
+
+	dir, err := ioutil.TempDir("", "tsg-reload")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "triton-sg.toml")
+	require.NoError(t, ioutil.WriteFile(path, []byte(reloadConfig), 0600))
+	viper.SetConfigFile(path)
+	require.NoError(t, viper.ReadInConfig())
+
+	t.Run("unchanged", func(t *testing.T) {
+		applied, restart, err := config.Reload()
+		require.NoError(t, err)
+		assert.Empty(t, applied)
+		assert.Empty(t, restart)
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(path, []byte(`
+[log]
+level = "DEBUG"
+
+[http]
+port = 3001
+
+[nomad]
+job-type = "service"
+`), 0600))
+
+		applied, restart, err := config.Reload()
+		require.NoError(t, err)
+		assert.Equal(t, []string{config.KeyLogLevel, config.KeyNomadJobType}, applied)
+		assert.Equal(t, []string{config.KeyHTTPServerPort}, restart)
+
+		assert.Equal(t, "service", viper.GetString(config.KeyNomadJobType))
+		assert.Equal(t, 3000, viper.GetInt(config.KeyHTTPServerPort))
+	})
+
+	t.Run("added", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(path, []byte(`
+[log]
+level = "DEBUG"
+
+[http]
+port = 3001
+
+[nomad]
+job-type = "service"
+namespace = "tsg"
+`), 0600))
+
+		applied, restart, err := config.Reload()
+		require.NoError(t, err)
+		assert.Empty(t, applied)
+		assert.Equal(t, []string{config.KeyNomadNamespace}, restart)
+
+		// The running agent never read a namespace, so none is set until it
+		// restarts.
+		assert.Empty(t, viper.GetString(config.KeyNomadNamespace))
+		assert.Equal(t, config.DefaultNomadNamespace, config.GetNomadNamespace())
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(path, []byte(`
+[log]
+level = "LOUD"
+`), 0600))
+
+		_, _, err := config.Reload()
+		assert.Error(t, err)
+	})
+}
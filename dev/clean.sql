@@ -1,5 +1,7 @@
 SET sql_safe_updates = false;
 
+DELETE FROM tsg_audit_log;
+DELETE FROM tsg_idempotency_keys;
 DELETE FROM tsg_groups;
 DELETE FROM tsg_templates;
 DELETE FROM tsg_users;
@@ -1,5 +1,7 @@
 SET sql_safe_updates = false;
 
+DROP TABLE IF EXISTS tsg_audit_log;
+DROP TABLE IF EXISTS tsg_idempotency_keys;
 DROP TABLE IF EXISTS tsg_groups;
 DROP TABLE IF EXISTS tsg_templates;
 DROP TABLE IF EXISTS tsg_users;
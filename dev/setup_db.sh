@@ -27,13 +27,15 @@ CREATE TABLE IF NOT EXISTS tsg_keys (
     account_id UUID NULL,
     created_at TIMESTAMP WITH TIME ZONE NOT NULL,
     updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    retired_at TIMESTAMP WITH TIME ZONE NULL,
     archived BOOL NULL DEFAULT false,
     CONSTRAINT "primary" PRIMARY KEY (id ASC),
     INDEX name_idx ("name" ASC),
     INDEX id_name_idx (id ASC, "name" ASC),
     INDEX id_account_id_idx (id ASC, account_id ASC),
     INDEX archived_idx (archived ASC),
-    FAMILY "primary" (id, "name", fingerprint, material, account_id, created_at, updated_at, archived)
+    INDEX retired_at_idx (retired_at ASC),
+    FAMILY "primary" (id, "name", fingerprint, material, account_id, created_at, updated_at, retired_at, archived)
 );
 EOS
 
@@ -42,7 +44,9 @@ CREATE TABLE IF NOT EXISTS tsg_accounts (
     id UUID NOT NULL DEFAULT gen_random_uuid(),
     account_name STRING NOT NULL,
     triton_uuid STRING NULL,
+    job_ref STRING NULL,
     key_id UUID NULL,
+    default_datacenter STRING NULL,
     created_at TIMESTAMP WITH TIME ZONE NOT NULL,
     updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
     archived BOOL NULL DEFAULT false,
@@ -51,8 +55,9 @@ CREATE TABLE IF NOT EXISTS tsg_accounts (
     INDEX key_id_tsg_keys_id_fk_idx (key_id ASC),
     INDEX name_idx (account_name ASC),
     INDEX id_name_idx (id ASC, account_name ASC),
+    INDEX job_ref_idx (job_ref ASC),
     INDEX archived_idx (archived ASC),
-    FAMILY "primary" (id, account_name, triton_uuid, key_id, created_at, updated_at, archived)
+    FAMILY "primary" (id, account_name, triton_uuid, job_ref, key_id, created_at, updated_at, archived)
 );
 EOS
 
@@ -75,10 +80,15 @@ CREATE TABLE IF NOT EXISTS tsg_templates (
     package STRING NOT NULL,
     image_id STRING NOT NULL,
     firewall_enabled BOOL NULL DEFAULT false,
+    firewall_rules STRING NULL,
     networks STRING NULL,
     userdata STRING NULL,
     metadata STRING NULL,
     tags STRING NULL,
+    task_cpu INT NOT NULL DEFAULT 0:::INT,
+    task_memory_mb INT NOT NULL DEFAULT 0:::INT,
+    version INT NOT NULL DEFAULT 1:::INT,
+    superseded BOOL NOT NULL DEFAULT false,
     created_at TIMESTAMP WITH TIME ZONE NOT NULL,
     archived BOOL NULL DEFAULT false,
     CONSTRAINT "primary" PRIMARY KEY (id ASC),
@@ -86,7 +96,7 @@ CREATE TABLE IF NOT EXISTS tsg_templates (
     INDEX account_id_tsg_accounts_id_fk_idx (account_id ASC),
     INDEX name_idx (template_name ASC),
     INDEX archived_idx (archived ASC),
-    FAMILY "primary" (id, template_name, account_id, package, image_id, firewall_enabled, networks, userdata, metadata, tags, created_at, archived)
+    FAMILY "primary" (id, template_name, account_id, package, image_id, firewall_enabled, firewall_rules, networks, userdata, metadata, tags, task_cpu, task_memory_mb, version, superseded, created_at, archived)
 );
 EOS
 
@@ -98,8 +108,19 @@ CREATE TABLE IF NOT EXISTS tsg_groups (
     account_id UUID NOT NULL,
     capacity INT NOT NULL,
     health_check_interval INT NULL DEFAULT 300:::INT,
+    alert_below_capacity_minutes INT NOT NULL DEFAULT 0:::INT,
+    instance_name_pattern STRING NOT NULL DEFAULT '':::STRING,
+    datacenter_capacity STRING NOT NULL DEFAULT '':::STRING,
+    canary STRING NOT NULL DEFAULT '':::STRING,
+    tsg_cli_version STRING NOT NULL DEFAULT '':::STRING,
+    time_zone STRING NOT NULL DEFAULT '':::STRING,
+    job_policies STRING NOT NULL DEFAULT '':::STRING,
+    instance_overrides STRING NOT NULL DEFAULT '':::STRING,
+    labels STRING NOT NULL DEFAULT '':::STRING,
+    paused BOOL NOT NULL DEFAULT false,
     created_at TIMESTAMP WITH TIME ZONE NOT NULL,
     updated_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    deleted_at TIMESTAMP WITH TIME ZONE NULL,
     archived BOOL NULL DEFAULT false,
     CONSTRAINT "primary" PRIMARY KEY (id ASC),
     CONSTRAINT template_id_tsg_templates_id_fk FOREIGN KEY (template_id) REFERENCES tsg_templates (id),
@@ -109,7 +130,52 @@ CREATE TABLE IF NOT EXISTS tsg_groups (
     INDEX name_idx ("name" ASC),
     INDEX name_templates_id_idx ("name" ASC, template_id ASC),
     INDEX archived_idx (archived ASC),
-    FAMILY "primary" (id, "name", template_id, account_id, capacity, health_check_interval, created_at, updated_at, archived)
+    FAMILY "primary" (id, "name", template_id, account_id, capacity, health_check_interval, alert_below_capacity_minutes, instance_name_pattern, datacenter_capacity, canary, tsg_cli_version, time_zone, job_policies, instance_overrides, labels, paused, created_at, updated_at, deleted_at, archived)
+);
+EOS
+
+    cat <<'EOS' | $SQL -d $env
+CREATE TABLE IF NOT EXISTS tsg_group_tombstones (
+    id UUID NOT NULL,
+    account_id UUID NOT NULL,
+    deleted_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT "primary" PRIMARY KEY (id ASC),
+    FAMILY "primary" (id, account_id, deleted_at)
+);
+EOS
+
+    cat <<'EOS' | $SQL -d $env
+CREATE TABLE IF NOT EXISTS tsg_idempotency_keys (
+    account_id UUID NOT NULL,
+    idempotency_key STRING NOT NULL,
+    request_hash STRING NOT NULL,
+    status_code INT NOT NULL DEFAULT 0:::INT,
+    headers STRING NOT NULL DEFAULT '':::STRING,
+    body BYTES NULL,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT "primary" PRIMARY KEY (account_id ASC, idempotency_key ASC),
+    CONSTRAINT account_id_tsg_accounts_id_fk FOREIGN KEY (account_id) REFERENCES tsg_accounts (id),
+    INDEX created_at_idx (created_at ASC),
+    FAMILY "primary" (account_id, idempotency_key, request_hash, status_code, headers, body, created_at)
+);
+
+CREATE TABLE IF NOT EXISTS tsg_audit_log (
+    id UUID NOT NULL DEFAULT gen_random_uuid(),
+    account_id UUID NOT NULL,
+    action STRING NOT NULL,
+    target_type STRING NOT NULL,
+    target_id STRING NOT NULL DEFAULT '':::STRING,
+    target_name STRING NOT NULL DEFAULT '':::STRING,
+    datacenter STRING NOT NULL DEFAULT '':::STRING,
+    request_id STRING NOT NULL DEFAULT '':::STRING,
+    outcome STRING NOT NULL,
+    error STRING NOT NULL DEFAULT '':::STRING,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    CONSTRAINT "primary" PRIMARY KEY (id ASC),
+    CONSTRAINT account_id_tsg_accounts_id_fk FOREIGN KEY (account_id) REFERENCES tsg_accounts (id),
+    INDEX account_id_created_at_idx (account_id ASC, created_at DESC),
+    INDEX account_id_target_id_idx (account_id ASC, target_id ASC),
+    FAMILY "primary" (id, account_id, action, target_type, target_id, target_name, datacenter, request_id, outcome, error, created_at)
 );
 EOS
 
@@ -0,0 +1,132 @@
+// Package features controls which capabilities are enabled for each account,
+// so new features can be rolled out gradually.
+package features
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"github.com/joyent/triton-service-groups/accounts"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/messages"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// Adopt gates adopting existing Nomad jobs into groups.
+	Adopt = "adopt"
+	// Export gates exporting and importing account bundles.
+	Export = "export"
+)
+
+// defaults are the built in state of every known feature, used unless the
+// configuration says otherwise. Features which are still being rolled out
+// should default to false.
+var defaults = map[string]bool{
+	Adopt:  true,
+	Export: true,
+}
+
+// Known returns the name of every known feature.
+func Known() []string {
+	names := make([]string, 0, len(defaults))
+	for name := range defaults {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Flags is the state of every known feature for an account.
+type Flags map[string]bool
+
+// Enabled reports whether the named feature is enabled. Unknown features are
+// never enabled.
+func (f Flags) Enabled(name string) bool {
+	return f[name]
+}
+
+// Resolve returns the flags of the named account. Each feature's built in
+// default is overridden by the configured defaults, which are in turn
+// overridden by the account's own configuration.
+func Resolve(accountName string) Flags {
+	flags := make(Flags, len(defaults))
+	for name, enabled := range defaults {
+		flags[name] = enabled
+	}
+
+	for _, layer := range []map[string]bool{
+		config.GetFeatureDefaults(),
+		config.GetFeatureOverrides(accountName),
+	} {
+		for name, enabled := range layer {
+			if _, ok := defaults[name]; !ok {
+				log.Warn().Str("feature", name).Msg("features: ignoring unknown feature flag")
+				continue
+			}
+			flags[name] = enabled
+		}
+	}
+
+	return flags
+}
+
+// ForSession returns the flags of the account behind the current request.
+func ForSession(ctx context.Context) (Flags, error) {
+	name, err := accountName(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return Resolve(name), nil
+}
+
+// accountName looks up the name of the session's account. It's a variable so
+// tests can run without a database.
+var accountName = func(ctx context.Context) (string, error) {
+	session := handlers.GetAuthSession(ctx)
+
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return "", handlers.ErrNoConnPool
+	}
+
+	account, err := accounts.NewStore(db).FindByID(ctx, session.AccountID)
+	if err != nil {
+		return "", err
+	}
+
+	return account.AccountName, nil
+}
+
+// Require wraps h so that it is only served to accounts with the named
+// feature enabled. Everyone else receives the configured disabled status,
+// which defaults to a 404 so that disabled features aren't discoverable.
+func Require(name string, h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flags, err := ForSession(r.Context())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if !flags.Enabled(name) {
+			if disabledStatus() == http.StatusForbidden {
+				messages.Write(w, r, messages.New(messages.FeatureDisabled, name), http.StatusForbidden)
+				return
+			}
+			http.NotFound(w, r)
+			return
+		}
+
+		h(w, r)
+	}
+}
+
+func disabledStatus() int {
+	if config.GetFeatureDisabledStatus() == http.StatusForbidden {
+		return http.StatusForbidden
+	}
+	return http.StatusNotFound
+}
@@ -0,0 +1,74 @@
+package features
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func withAccountName(name string) func() {
+	orig := accountName
+	accountName = func(ctx context.Context) (string, error) {
+		return name, nil
+	}
+	return func() { accountName = orig }
+}
+
+func TestResolve(t *testing.T) {
+	defer viper.Reset()
+
+	assert.Equal(t, Flags{Adopt: true, Export: true}, Resolve("testacct"))
+
+	viper.Set(config.KeyFeaturesDefaults, map[string]interface{}{
+		"export":  false,
+		"unknown": true,
+	})
+	viper.Set(config.KeyFeaturesAccounts, map[string]interface{}{
+		"testacct": map[string]interface{}{"export": true, "adopt": "false"},
+	})
+
+	assert.Equal(t, Flags{Adopt: false, Export: true}, Resolve("TestAcct"))
+	assert.Equal(t, Flags{Adopt: true, Export: false}, Resolve("otheracct"))
+	assert.False(t, Resolve("otheracct").Enabled("unknown"))
+}
+
+func TestRequire(t *testing.T) {
+	defer viper.Reset()
+	defer withAccountName("testacct")()
+
+	handler := Require(Export, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	serve := func() int {
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest(http.MethodGet, "/v1/tsg/export", nil))
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusTeapot, serve(), "enabled feature should be served")
+
+	viper.Set(config.KeyFeaturesAccounts, map[string]interface{}{
+		"testacct": map[string]interface{}{"export": false},
+	})
+	assert.Equal(t, http.StatusNotFound, serve())
+
+	viper.Set(config.KeyFeaturesDisabledStatus, http.StatusForbidden)
+	assert.Equal(t, http.StatusForbidden, serve())
+
+	// The reason is localized to the client's language.
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/v1/tsg/export", nil)
+	r.Header.Set("Accept-Language", "es-ES, en;q=0.8")
+	handler(w, r)
+	assert.Equal(t, "la función \"export\" no está habilitada para esta cuenta\n", w.Body.String())
+	assert.Equal(t, "FeatureDisabled", w.Header().Get("X-TSG-Error-Code"))
+
+	viper.Set(config.KeyFeaturesDisabledStatus, http.StatusTeapot)
+	assert.Equal(t, http.StatusNotFound, serve(), "unsupported statuses fall back to 404")
+}
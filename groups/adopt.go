@@ -0,0 +1,139 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/templates"
+)
+
+// groupIDMetaKey is the job meta key which records the service group that
+// manages a Nomad job.
+const groupIDMetaKey = "tsg_group_id"
+
+var (
+	ErrJobNotFound = errors.New("no existing Nomad job found for group")
+	ErrJobManaged  = errors.New("Nomad job is already managed by another group")
+)
+
+// AdoptResult describes the outcome of adopting an existing Nomad job into a
+// service group.
+type AdoptResult struct {
+	JobID       string   `json:"job_id"`
+	Adopted     bool     `json:"adopted"`
+	Divergent   bool     `json:"divergent"`
+	Differences []string `json:"differences,omitempty"`
+}
+
+// AdoptOrchestratorJob brings an existing Nomad job, named as the group's job
+// would be, under management of the group. If the running job differs from
+// the group's stored spec the divergence is reported and the job is left
+// alone, unless reconcile is set in which case the job is replaced with the
+// group's spec.
+func AdoptOrchestratorJob(ctx context.Context, group *ServiceGroup, reconcile bool) (*AdoptResult, error) {
+	session := handlers.GetAuthSession(ctx)
+
+	t, found := templates_v1.FindTemplateByID(ctx, group.TemplateID, session.AccountID)
+	if !found {
+		return nil, errors.New("Error finding template by ID")
+	}
+
+	job, err := prepareJob(ctx, t, group)
+	if err != nil {
+		return nil, err
+	}
+
+	return adoptJob(ctx, group, job, reconcile)
+}
+
+func adoptJob(ctx context.Context, group *ServiceGroup, job *nomad.Job, reconcile bool) (*AdoptResult, error) {
+	client, ok := handlers.GetNomadClient(ctx)
+	if !ok {
+		return nil, handlers.ErrNoNomadClient
+	}
+
+	existing, err := getJobInfo(ctx, client, *job.ID)
+	if err != nil {
+		if isNotFound(err) {
+			return nil, ErrJobNotFound
+		}
+		return nil, &ErrNomad{Op: ErrNomadJobInfo, Err: err}
+	}
+
+	if owner, ok := existing.Meta[groupIDMetaKey]; ok && owner != group.ID {
+		return nil, ErrJobManaged
+	}
+
+	plan, _, err := client.Jobs().Plan(job, true, nomadScopeOf(ctx).writeOptions())
+	if err != nil {
+		return nil, &ErrNomad{Op: ErrNomadPlan, Err: err}
+	}
+
+	result := &AdoptResult{
+		JobID:       *job.ID,
+		Differences: jobDifferences(plan.Diff),
+	}
+	result.Divergent = len(result.Differences) > 0
+
+	if result.Divergent && !reconcile {
+		return result, nil
+	}
+
+	if _, err := registerJob(ctx, job, true); err != nil {
+		return nil, err
+	}
+	result.Adopted = true
+
+	return result, nil
+}
+
+// jobDifferences flattens a job plan diff into the names of every changed
+// field. The group ID meta key is ignored since it is expected to be missing
+// from jobs which were created outside of TSG.
+func jobDifferences(diff *nomad.JobDiff) []string {
+	if diff == nil {
+		return nil
+	}
+
+	var names []string
+	names = appendFieldDiffs(names, "Job", diff.Fields, diff.Objects)
+	for _, tg := range diff.TaskGroups {
+		prefix := fmt.Sprintf("TaskGroup[%s]", tg.Name)
+		names = appendFieldDiffs(names, prefix, tg.Fields, tg.Objects)
+		for _, task := range tg.Tasks {
+			prefix := fmt.Sprintf("TaskGroup[%s].Task[%s]", tg.Name, task.Name)
+			names = appendFieldDiffs(names, prefix, task.Fields, task.Objects)
+		}
+	}
+
+	return names
+}
+
+func appendFieldDiffs(names []string, prefix string, fields []*nomad.FieldDiff, objects []*nomad.ObjectDiff) []string {
+	for _, field := range fields {
+		if field.Type == "None" || field.Name == fmt.Sprintf("Meta[%s]", groupIDMetaKey) {
+			continue
+		}
+		names = append(names, prefix+"."+field.Name)
+	}
+	for _, obj := range objects {
+		if obj.Type == "None" {
+			continue
+		}
+		names = appendFieldDiffs(names, prefix+"."+obj.Name, obj.Fields, obj.Objects)
+	}
+	return names
+}
+
+func isNotFound(err error) bool {
+	return strings.Contains(err.Error(), "Unexpected response code: 404")
+}
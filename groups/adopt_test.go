@@ -0,0 +1,151 @@
+package groups_v1
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/helper"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newAdoptFake(t *testing.T, jobID string, existing *nomad.Job, diff *nomad.JobDiff) (*testutils.FakeNomad, *bool) {
+	fake := testutils.NewFakeNomad(t)
+
+	registered := false
+	fake.HandleFunc("/v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		registered = true
+		testutils.WriteJSON(w, nomad.JobRegisterResponse{})
+	})
+	fake.HandleJSON("/v1/validate/job", nomad.JobValidateResponse{})
+	fake.HandleFunc("/v1/job/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/job/" + jobID:
+			if existing == nil {
+				http.NotFound(w, r)
+				return
+			}
+			testutils.WriteJSON(w, existing)
+		case "/v1/job/" + jobID + "/plan":
+			testutils.WriteJSON(w, nomad.JobPlanResponse{Diff: diff})
+		case "/v1/job/" + jobID + "/periodic/force":
+			testutils.WriteJSON(w, map[string]string{"EvalID": ""})
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	return fake, &registered
+}
+
+func TestAdoptJob(t *testing.T) {
+	const (
+		groupID = "722d25ed-f32a-4944-9861-8990e204850e"
+		jobID   = "jolly-jelly_c2e4d1491ce423e3"
+	)
+
+	group := &ServiceGroup{ID: groupID, GroupName: "jolly-jelly"}
+	job := &nomad.Job{
+		ID:   helper.StringToPtr(jobID),
+		Name: helper.StringToPtr(jobID),
+		Meta: map[string]string{groupIDMetaKey: groupID},
+	}
+
+	t.Run("clean adoption", func(t *testing.T) {
+		existing := &nomad.Job{ID: helper.StringToPtr(jobID), Name: helper.StringToPtr(jobID)}
+		diff := &nomad.JobDiff{
+			Type: "Edited",
+			Fields: []*nomad.FieldDiff{
+				{Type: "Added", Name: "Meta[tsg_group_id]", New: groupID},
+				{Type: "None", Name: "Type", Old: "batch", New: "batch"},
+			},
+		}
+
+		fake, registered := newAdoptFake(t, jobID, existing, diff)
+		defer fake.Close()
+		ctx := handlers.WithNomadClient(context.Background(), fake.Client)
+
+		result, err := adoptJob(ctx, group, job, false)
+		require.NoError(t, err)
+		assert.Equal(t, jobID, result.JobID)
+		assert.True(t, result.Adopted)
+		assert.False(t, result.Divergent)
+		assert.Empty(t, result.Differences)
+		assert.True(t, *registered)
+	})
+
+	t.Run("divergent", func(t *testing.T) {
+		existing := &nomad.Job{ID: helper.StringToPtr(jobID), Name: helper.StringToPtr(jobID)}
+		diff := &nomad.JobDiff{
+			Type: "Edited",
+			TaskGroups: []*nomad.TaskGroupDiff{
+				{
+					Type: "Edited",
+					Name: "scale",
+					Tasks: []*nomad.TaskDiff{
+						{
+							Type: "Edited",
+							Name: "healthy",
+							Objects: []*nomad.ObjectDiff{
+								{
+									Type: "Edited",
+									Name: "Config",
+									Fields: []*nomad.FieldDiff{
+										{Type: "Edited", Name: "args[2]", Old: "3", New: "5"},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		}
+
+		fake, registered := newAdoptFake(t, jobID, existing, diff)
+		defer fake.Close()
+		ctx := handlers.WithNomadClient(context.Background(), fake.Client)
+
+		result, err := adoptJob(ctx, group, job, false)
+		require.NoError(t, err)
+		assert.False(t, result.Adopted)
+		assert.True(t, result.Divergent)
+		assert.Equal(t, []string{"TaskGroup[scale].Task[healthy].Config.args[2]"}, result.Differences)
+		assert.False(t, *registered)
+
+		result, err = adoptJob(ctx, group, job, true)
+		require.NoError(t, err)
+		assert.True(t, result.Adopted)
+		assert.True(t, result.Divergent)
+		assert.True(t, *registered)
+	})
+
+	t.Run("managed by another group", func(t *testing.T) {
+		existing := &nomad.Job{
+			ID:   helper.StringToPtr(jobID),
+			Name: helper.StringToPtr(jobID),
+			Meta: map[string]string{groupIDMetaKey: "3d51f5d1-2f3a-4b7c-bb17-7c8b6d1b0a3e"},
+		}
+
+		fake, registered := newAdoptFake(t, jobID, existing, nil)
+		defer fake.Close()
+		ctx := handlers.WithNomadClient(context.Background(), fake.Client)
+
+		_, err := adoptJob(ctx, group, job, true)
+		assert.Equal(t, ErrJobManaged, err)
+		assert.False(t, *registered)
+	})
+
+	t.Run("no existing job", func(t *testing.T) {
+		fake, registered := newAdoptFake(t, jobID, nil, nil)
+		defer fake.Close()
+		ctx := handlers.WithNomadClient(context.Background(), fake.Client)
+
+		_, err := adoptJob(ctx, group, job, false)
+		assert.Equal(t, ErrJobNotFound, err)
+		assert.False(t, *registered)
+	})
+}
@@ -0,0 +1,231 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/webhooks"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// AlertBelowCapacity fires once a group has run fewer instances than its
+	// capacity for longer than its threshold.
+	AlertBelowCapacity = "below_capacity"
+
+	AlertFiring   = "alert.firing"
+	AlertResolved = "alert.resolved"
+)
+
+// AlertThresholds configures when the alert monitor notifies about a group.
+// A zero threshold disables its alert.
+type AlertThresholds struct {
+	BelowCapacityMinutes int `json:"below_capacity_minutes"`
+}
+
+// AlertEvent is the payload delivered when an alert fires or resolves.
+type AlertEvent struct {
+	Event     string    `json:"event"`
+	Alert     string    `json:"alert"`
+	GroupID   string    `json:"group_id"`
+	GroupName string    `json:"group_name"`
+	AccountID string    `json:"account_id"`
+	Capacity  int       `json:"capacity"`
+	Running   int       `json:"running"`
+	Since     time.Time `json:"since"`
+	At        time.Time `json:"at"`
+}
+
+// EventSubject identifies the group an alert is about.
+func (e *AlertEvent) EventSubject() string {
+	return "groups/" + e.GroupID
+}
+
+// EventTime is when the alert fired or resolved.
+func (e *AlertEvent) EventTime() time.Time {
+	return e.At
+}
+
+// alertState tracks a group between checks. Alerts are only delivered when
+// firing changes, so a group which stays unhealthy is alerted on once.
+type alertState struct {
+	since  time.Time
+	firing bool
+}
+
+// AlertMonitor periodically evaluates the alert thresholds of every group.
+type AlertMonitor struct {
+	cfg       config.Alerts
+	tritonURL string
+	pool      *pgx.ConnPool
+
+	state map[string]*alertState
+	now   func() time.Time
+
+	findGroups     func(ctx context.Context) ([]*ManagedGroup, error)
+	countInstances func(ctx context.Context, group *ManagedGroup) (int, error)
+	notify         func(ctx context.Context, event *AlertEvent) error
+}
+
+// NewAlertMonitor constructs an alert monitor which delivers to the configured
+// webhook.
+func NewAlertMonitor(cfg config.Alerts, tritonURL string, pool *pgx.ConnPool) *AlertMonitor {
+	m := &AlertMonitor{
+		cfg:        cfg,
+		tritonURL:  tritonURL,
+		pool:       pool,
+		state:      map[string]*alertState{},
+		now:        time.Now,
+		findGroups: findLocalGroups,
+	}
+	m.countInstances = m.runningInstances
+
+	sender := webhooks.NewSender(cfg.WebhookURL, cfg.WebhookSecret, cfg.WebhookFormat, cfg.EventSource)
+	m.notify = func(ctx context.Context, event *AlertEvent) error {
+		return sender.Send(ctx, event.Event, event)
+	}
+
+	return m
+}
+
+// Run checks every group once per interval until ctx is done.
+func (m *AlertMonitor) Run(ctx context.Context) {
+	if m.cfg.WebhookURL == "" {
+		log.Debug().Msg("alerts: no webhook configured, monitor disabled")
+		return
+	}
+
+	ticker := time.NewTicker(m.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Check(ctx); err != nil {
+				log.Error().Err(err).Msg("alerts: failed to check groups")
+			}
+		}
+	}
+}
+
+// Check evaluates the alert thresholds of every group once, delivering an
+// event for each alert which starts or stops firing.
+func (m *AlertMonitor) Check(ctx context.Context) error {
+	ctx = handlers.WithDBPool(ctx, m.pool)
+
+	groups, err := m.findGroups(ctx)
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(groups))
+	for _, group := range groups {
+		seen[group.ID] = true
+
+		// A paused group is expected to drift from its capacity, so it's
+		// treated as having no threshold, which resolves a firing alert.
+		threshold := time.Duration(group.Alerts.BelowCapacityMinutes) * time.Minute
+		if group.Paused {
+			threshold = 0
+		}
+		if threshold == 0 && m.state[group.ID] == nil {
+			continue
+		}
+
+		running, err := m.countInstances(ctx, group)
+		if err != nil {
+			log.Error().Err(err).
+				Str("group_id", group.ID).
+				Msg("alerts: failed to count group instances")
+			continue
+		}
+
+		state, event := m.evaluate(group, threshold, running)
+		if event != nil {
+			// the state is left alone on failure so that delivery is retried on
+			// the next check
+			if err := m.notify(ctx, event); err != nil {
+				log.Error().Err(err).
+					Str("group_id", group.ID).
+					Str("event", event.Event).
+					Msg("alerts: failed to deliver alert")
+				continue
+			}
+
+			log.Info().
+				Str("group_id", group.ID).
+				Str("event", event.Event).
+				Msg("alerts: delivered alert")
+		}
+
+		if state == nil {
+			delete(m.state, group.ID)
+		} else {
+			m.state[group.ID] = state
+		}
+	}
+
+	for id := range m.state {
+		if !seen[id] {
+			delete(m.state, id)
+		}
+	}
+
+	return nil
+}
+
+// evaluate returns the next state of a group, or nil if it is healthy, along
+// with the event to deliver, if any. Removing a threshold while its alert is
+// firing resolves it.
+func (m *AlertMonitor) evaluate(group *ManagedGroup, threshold time.Duration, running int) (*alertState, *AlertEvent) {
+	now := m.now()
+
+	state := &alertState{}
+	if current, ok := m.state[group.ID]; ok {
+		*state = *current
+	}
+
+	below := threshold > 0 && running < group.Capacity
+	if below && state.since.IsZero() {
+		state.since = now
+	}
+
+	event := &AlertEvent{
+		Alert:     AlertBelowCapacity,
+		GroupID:   group.ID,
+		GroupName: group.GroupName,
+		AccountID: group.AccountID,
+		Capacity:  group.Capacity,
+		Running:   running,
+		Since:     state.since,
+		At:        now,
+	}
+
+	switch {
+	case below && !state.firing && now.Sub(state.since) >= threshold:
+		state.firing = true
+		event.Event = AlertFiring
+		return state, event
+	case below:
+		return state, nil
+	case state.firing:
+		event.Event = AlertResolved
+		return nil, event
+	default:
+		return nil, nil
+	}
+}
+
+func (m *AlertMonitor) runningInstances(ctx context.Context, group *ManagedGroup) (int, error) {
+	return countRunningInstances(ctx, m.tritonURL, group)
+}
@@ -0,0 +1,175 @@
+package groups_v1
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testAlertMonitor struct {
+	*AlertMonitor
+	clock     time.Time
+	running   int
+	events    []*AlertEvent
+	notifyErr error
+}
+
+func newTestAlertMonitor(groups []*ManagedGroup) *testAlertMonitor {
+	m := &testAlertMonitor{
+		clock: time.Date(2018, 5, 2, 12, 0, 0, 0, time.UTC),
+	}
+	m.AlertMonitor = &AlertMonitor{
+		cfg:   config.Alerts{Interval: time.Minute},
+		state: map[string]*alertState{},
+		now:   func() time.Time { return m.clock },
+		findGroups: func(ctx context.Context) ([]*ManagedGroup, error) {
+			return groups, nil
+		},
+		countInstances: func(ctx context.Context, group *ManagedGroup) (int, error) {
+			return m.running, nil
+		},
+		notify: func(ctx context.Context, event *AlertEvent) error {
+			if m.notifyErr != nil {
+				return m.notifyErr
+			}
+			m.events = append(m.events, event)
+			return nil
+		},
+	}
+	return m
+}
+
+func (m *testAlertMonitor) checkAfter(t *testing.T, d time.Duration) {
+	m.clock = m.clock.Add(d)
+	require.NoError(t, m.Check(context.Background()))
+}
+
+func TestAlertMonitorBelowCapacity(t *testing.T) {
+	groups := testManagedGroups("web", "web-2", "web-3")
+	groups[2].Alerts.BelowCapacityMinutes = 5
+
+	m := newTestAlertMonitor(groups)
+	m.running = 1
+
+	m.checkAfter(t, 0)
+	m.checkAfter(t, 4*time.Minute)
+	assert.Empty(t, m.events, "should not fire before the threshold")
+
+	m.checkAfter(t, time.Minute)
+	require.Len(t, m.events, 1)
+	firing := m.events[0]
+	assert.Equal(t, AlertFiring, firing.Event)
+	assert.Equal(t, AlertBelowCapacity, firing.Alert)
+	assert.Equal(t, "web-3-id", firing.GroupID)
+	assert.Equal(t, 3, firing.Capacity)
+	assert.Equal(t, 1, firing.Running)
+	assert.Equal(t, 5*time.Minute, firing.At.Sub(firing.Since))
+
+	m.checkAfter(t, time.Minute)
+	m.checkAfter(t, time.Minute)
+	assert.Len(t, m.events, 1, "should not repeat while still firing")
+
+	m.running = 3
+	m.checkAfter(t, time.Minute)
+	require.Len(t, m.events, 2)
+	assert.Equal(t, AlertResolved, m.events[1].Event)
+	assert.Equal(t, "web-3-id", m.events[1].GroupID)
+
+	m.checkAfter(t, time.Minute)
+	assert.Len(t, m.events, 2, "should not repeat once resolved")
+	assert.Empty(t, m.state)
+}
+
+func TestAlertMonitorRecovery(t *testing.T) {
+	groups := testManagedGroups("web")
+	groups[0].Capacity = 2
+	groups[0].Alerts.BelowCapacityMinutes = 5
+
+	m := newTestAlertMonitor(groups)
+	m.running = 1
+
+	m.checkAfter(t, 0)
+	m.checkAfter(t, 3*time.Minute)
+
+	// recovering before the threshold restarts the clock
+	m.running = 2
+	m.checkAfter(t, time.Minute)
+	m.running = 1
+	m.checkAfter(t, time.Minute)
+	m.checkAfter(t, 4*time.Minute)
+	assert.Empty(t, m.events)
+
+	m.checkAfter(t, time.Minute)
+	require.Len(t, m.events, 1)
+	assert.Equal(t, AlertFiring, m.events[0].Event)
+}
+
+func TestAlertMonitorDeliveryFailure(t *testing.T) {
+	groups := testManagedGroups("web")
+	groups[0].Capacity = 2
+	groups[0].Alerts.BelowCapacityMinutes = 1
+
+	m := newTestAlertMonitor(groups)
+	m.running = 0
+	m.notifyErr = errors.New("connection refused")
+
+	m.checkAfter(t, 0)
+	m.checkAfter(t, time.Minute)
+	assert.Empty(t, m.events)
+
+	m.notifyErr = nil
+	m.checkAfter(t, time.Minute)
+	require.Len(t, m.events, 1, "should retry a failed delivery")
+	assert.Equal(t, AlertFiring, m.events[0].Event)
+}
+
+func TestAlertMonitorThresholdRemoved(t *testing.T) {
+	groups := testManagedGroups("web")
+	groups[0].Capacity = 2
+	groups[0].Alerts.BelowCapacityMinutes = 1
+
+	m := newTestAlertMonitor(groups)
+	m.running = 0
+
+	m.checkAfter(t, 0)
+	m.checkAfter(t, time.Minute)
+	require.Len(t, m.events, 1)
+
+	groups[0].Alerts.BelowCapacityMinutes = 0
+	m.checkAfter(t, time.Minute)
+	require.Len(t, m.events, 2)
+	assert.Equal(t, AlertResolved, m.events[1].Event)
+}
+
+func TestAlertMonitorPaused(t *testing.T) {
+	groups := testManagedGroups("web", "db")
+	web, db := groups[0], groups[1]
+	web.Capacity, db.Capacity = 2, 2
+	web.Alerts.BelowCapacityMinutes = 1
+	db.Alerts.BelowCapacityMinutes = 1
+	db.Paused = true
+
+	m := newTestAlertMonitor(groups)
+	m.running = 0
+
+	m.checkAfter(t, 0)
+	m.checkAfter(t, time.Minute)
+	require.Len(t, m.events, 1, "paused groups shouldn't alert")
+	assert.Equal(t, web.ID, m.events[0].GroupID)
+	assert.Equal(t, AlertFiring, m.events[0].Event)
+
+	web.Paused = true
+	m.checkAfter(t, time.Minute)
+	require.Len(t, m.events, 2)
+	assert.Equal(t, web.ID, m.events[1].GroupID)
+	assert.Equal(t, AlertResolved, m.events[1].Event)
+
+	m.checkAfter(t, time.Minute)
+	assert.Len(t, m.events, 2)
+	assert.Empty(t, m.state)
+}
@@ -0,0 +1,40 @@
+package groups_v1
+
+import (
+	"testing"
+
+	"github.com/joyent/triton-service-groups/testutils"
+)
+
+func TestAPITypesSnakeCase(t *testing.T) {
+	testutils.AssertSnakeCaseJSON(t,
+		ServiceGroup{},
+		ActionableInput{},
+		Instance{},
+		EvaluationPage{},
+		ScaleInput{},
+		ScaleResult{},
+		GroupPage{},
+		GroupStatus{},
+		AdoptResult{},
+		RenderInput{},
+		RenderedJob{},
+		AlertEvent{},
+		CanaryConfig{},
+		CanaryStatus{},
+		TeardownResult{},
+		JobStatus{},
+		JobRun{},
+		GroupSnapshot{},
+		TemplateSnapshot{},
+		BudgetStatus{},
+		JobSubmission{},
+		JobSubmissions{},
+		ReconcileResult{},
+		ReconcileResults{},
+		TemplateValidation{},
+		TemplateVersionInput{},
+		AlertThresholds{},
+		GroupAccount{},
+	)
+}
@@ -0,0 +1,115 @@
+package groups_v1
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/nomad/jobspec"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderArtifactOptions(t *testing.T) {
+	tests := []struct {
+		name     string
+		artifact config.Artifact
+		dest     string
+		mode     string
+		options  map[string]string
+		command  string
+		source   string
+	}{
+		{
+			"defaults",
+			config.Artifact{Destination: "local/", Mode: "any", Unpack: true, Options: map[string]string{}},
+			"local/", "any", nil, "tsg-cli",
+			"https://github.com/joyent/tsg-cli/releases/download/v0.1.0/tsg-cli_0.1.0_linux_amd64.tar.gz",
+		},
+		{
+			"custom",
+			config.Artifact{
+				BaseURL:     "https://artifacts.example.com/tsg-cli",
+				Destination: "local/bin",
+				Mode:        "dir",
+				Unpack:      true,
+				Options:     map[string]string{"checksum": "sha256:abc123"},
+			},
+			"local/bin", "dir", map[string]string{"checksum": "sha256:abc123"}, "local/bin/tsg-cli",
+			"https://artifacts.example.com/tsg-cli/v0.1.0/tsg-cli_0.1.0_linux_amd64.tar.gz",
+		},
+		{
+			"file",
+			config.Artifact{
+				Destination: "local/bin/tsg-cli",
+				Mode:        "file",
+				Options:     map[string]string{"archive": "false"},
+			},
+			"local/bin/tsg-cli", "file", map[string]string{"archive": "false"}, "local/bin/tsg-cli",
+			"https://github.com/joyent/tsg-cli/releases/download/v0.1.0/tsg-cli_0.1.0_linux_amd64",
+		},
+		{
+			"unarchived",
+			config.Artifact{
+				Destination: "local/",
+				Mode:        "any",
+				Options:     map[string]string{"archive": "false"},
+			},
+			"local/", "any", map[string]string{"archive": "false"}, "local/tsg-cli_0.1.0_linux_amd64",
+			"https://github.com/joyent/tsg-cli/releases/download/v0.1.0/tsg-cli_0.1.0_linux_amd64",
+		},
+		{
+			"checksum of version",
+			config.Artifact{
+				Destination: "local/",
+				Mode:        "any",
+				Unpack:      true,
+				Options:     map[string]string{},
+				Checksums:   map[string]string{"0.1.0": "sha256:abc123", "0.2.0": "sha256:def456"},
+			},
+			"local/", "any", map[string]string{"checksum": "sha256:abc123"}, "tsg-cli",
+			"https://github.com/joyent/tsg-cli/releases/download/v0.1.0/tsg-cli_0.1.0_linux_amd64.tar.gz",
+		},
+		{
+			"no checksum of version",
+			config.Artifact{
+				Destination: "local/",
+				Mode:        "any",
+				Unpack:      true,
+				Options:     map[string]string{},
+				Checksums:   map[string]string{"0.2.0": "sha256:def456"},
+			},
+			"local/", "any", nil, "tsg-cli",
+			"https://github.com/joyent/tsg-cli/releases/download/v0.1.0/tsg-cli_0.1.0_linux_amd64.tar.gz",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			details := testJobDetails(nil)
+			details.TSGCliVersion = "0.1.0"
+			details.setArtifact(tt.artifact)
+
+			spec, err := renderJobSpec(details)
+			require.NoError(t, err)
+			if checksum, ok := tt.options["checksum"]; ok {
+				assert.Contains(t, spec, `"checksum" = "`+checksum+`"`)
+			} else {
+				assert.NotContains(t, spec, "checksum")
+			}
+
+			job, err := jobspec.Parse(strings.NewReader(spec))
+			require.NoError(t, err)
+
+			task := job.TaskGroups[0].Tasks[0]
+			require.Len(t, task.Artifacts, 1)
+
+			artifact := task.Artifacts[0]
+			assert.Equal(t, tt.source, *artifact.GetterSource)
+			assert.Equal(t, tt.dest, *artifact.RelativeDest)
+			assert.Equal(t, tt.mode, *artifact.GetterMode)
+			assert.Equal(t, tt.options, artifact.GetterOptions)
+			assert.Equal(t, tt.command, task.Config["command"])
+		})
+	}
+}
@@ -0,0 +1,23 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+
+	"github.com/joyent/triton-service-groups/audit"
+)
+
+// auditJobOp records an operation on the job of a group in a single
+// datacenter in the audit log, as it's counted by countJobOp.
+func auditJobOp(ctx context.Context, op string, group *ServiceGroup, err error) {
+	audit.Record(ctx, audit.Entry{
+		Action:     op,
+		TargetType: audit.TargetGroup,
+		TargetID:   group.ID,
+		TargetName: group.GroupName,
+	}, err)
+}
@@ -0,0 +1,387 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/helper"
+	"github.com/jackc/pgx"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/rs/zerolog/log"
+)
+
+// Budgets tracks the reconcile runtime of every group. It's disabled until
+// configured.
+var Budgets = NewReconcileBudgets()
+
+// BudgetStatus reports how much of its daily reconcile budget a group has
+// used.
+type BudgetStatus struct {
+	LimitSeconds     float64   `json:"limit_seconds"`
+	UsedSeconds      float64   `json:"used_seconds"`
+	RemainingSeconds float64   `json:"remaining_seconds"`
+	Exhausted        bool      `json:"exhausted"`
+	ResetsAt         time.Time `json:"resets_at"`
+}
+
+type budgetUsage struct {
+	window time.Time
+	// allocs records the runtime counted for each reconcile allocation, so
+	// that allocations observed again as they run are never double counted.
+	allocs map[string]time.Duration
+}
+
+func (u *budgetUsage) used() time.Duration {
+	var used time.Duration
+	for _, runtime := range u.allocs {
+		used += runtime
+	}
+	return used
+}
+
+// ReconcileBudgets accumulates the runtime of each group's reconcile tasks
+// over a daily window.
+type ReconcileBudgets struct {
+	mu      sync.Mutex
+	limit   time.Duration
+	resetAt time.Duration
+	groups  map[string]*budgetUsage
+
+	now func() time.Time
+}
+
+// NewReconcileBudgets constructs a disabled budget tracker.
+func NewReconcileBudgets() *ReconcileBudgets {
+	return &ReconcileBudgets{
+		groups: make(map[string]*budgetUsage),
+		now:    time.Now,
+	}
+}
+
+// Configure sets the runtime allowed per group each day and the time of day,
+// in UTC, at which usage resets. A zero limit disables the budget.
+func (b *ReconcileBudgets) Configure(limit, resetAt time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.limit = limit
+	b.resetAt = resetAt
+}
+
+// Enabled returns true if a budget is being enforced.
+func (b *ReconcileBudgets) Enabled() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.limit > 0
+}
+
+// Window returns the start of the current budget window.
+func (b *ReconcileBudgets) Window() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.window()
+}
+
+// Observe records that allocID, a reconcile task of the group, has run for
+// runtime within the current window.
+func (b *ReconcileBudgets) Observe(groupID, allocID string, runtime time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	usage := b.usage(groupID)
+	if runtime > usage.allocs[allocID] {
+		usage.allocs[allocID] = runtime
+	}
+}
+
+// Exhausted returns true if the group has used all of its budget for the
+// current window.
+func (b *ReconcileBudgets) Exhausted(groupID string) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.limit > 0 && b.usage(groupID).used() >= b.limit
+}
+
+// Status returns the budget of the group, or nil if budgets are disabled.
+func (b *ReconcileBudgets) Status(groupID string) *BudgetStatus {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.limit <= 0 {
+		return nil
+	}
+
+	used := b.usage(groupID).used()
+	remaining := b.limit - used
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	return &BudgetStatus{
+		LimitSeconds:     b.limit.Seconds(),
+		UsedSeconds:      used.Seconds(),
+		RemainingSeconds: remaining.Seconds(),
+		Exhausted:        remaining == 0,
+		ResetsAt:         b.window().Add(24 * time.Hour),
+	}
+}
+
+// Forget discards the usage of a group, such as one which was deleted.
+func (b *ReconcileBudgets) Forget(groupID string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.groups, groupID)
+}
+
+// usage returns the group's usage for the current window, resetting it if
+// the window has moved on. Must be called with mu held.
+func (b *ReconcileBudgets) usage(groupID string) *budgetUsage {
+	window := b.window()
+
+	usage, ok := b.groups[groupID]
+	if !ok || !usage.window.Equal(window) {
+		usage = &budgetUsage{
+			window: window,
+			allocs: make(map[string]time.Duration),
+		}
+		b.groups[groupID] = usage
+	}
+	return usage
+}
+
+func (b *ReconcileBudgets) window() time.Time {
+	now := b.now().UTC()
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC).Add(b.resetAt)
+	if now.Before(start) {
+		start = start.Add(-24 * time.Hour)
+	}
+	return start
+}
+
+// BudgetMonitor periodically collects the runtime of every group's reconcile
+// tasks from Nomad. Once a group exhausts its budget the periodic schedule of
+// its job is disabled, skipping further reconciles until the budget resets.
+type BudgetMonitor struct {
+	interval   time.Duration
+	datacenter string
+	scope      nomadScope
+	pool       *pgx.ConnPool
+	client     *nomad.Client
+	budgets    *ReconcileBudgets
+
+	findGroups   func(ctx context.Context) ([]*ManagedGroup, error)
+	listRuntimes func(jobID string, since time.Time) (map[string]time.Duration, error)
+	setPeriodic  func(jobID string, enabled bool) (bool, error)
+}
+
+// NewBudgetMonitor constructs a budget monitor for the given tracker.
+func NewBudgetMonitor(interval time.Duration, datacenter string, pool *pgx.ConnPool, client *nomad.Client, budgets *ReconcileBudgets) *BudgetMonitor {
+	m := &BudgetMonitor{
+		interval:   interval,
+		datacenter: datacenter,
+		scope:      configuredScope(),
+		pool:       pool,
+		client:     client,
+		budgets:    budgets,
+		findGroups: findLocalGroups,
+	}
+	m.listRuntimes = func(jobID string, since time.Time) (map[string]time.Duration, error) {
+		return reconcileRuntimes(m.client, m.scope, jobID, since, time.Now())
+	}
+	m.setPeriodic = func(jobID string, enabled bool) (bool, error) {
+		defer jobInfoCache.Invalidate(m.datacenter, m.scope, jobID)
+		defer Statuses.Invalidate(m.datacenter, m.scope, jobID)
+		return setJobPeriodic(m.client, m.scope, jobID, enabled)
+	}
+	return m
+}
+
+// Run collects reconcile runtime once per interval until ctx is done.
+func (m *BudgetMonitor) Run(ctx context.Context) {
+	if !m.budgets.Enabled() {
+		log.Debug().Msg("budget: reconcile budgets disabled")
+		return
+	}
+
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Check(ctx); err != nil {
+				log.Error().Err(err).Msg("budget: failed to check groups")
+			}
+		}
+	}
+}
+
+// Check updates the usage of every group once, suspending the reconciles of
+// groups which have exhausted their budget and resuming those whose budget
+// has reset.
+func (m *BudgetMonitor) Check(ctx context.Context) error {
+	ctx = handlers.WithDBPool(ctx, m.pool)
+
+	groups, err := m.findGroups(ctx)
+	if err != nil {
+		return err
+	}
+
+	window := m.budgets.Window()
+
+	var exhausted int
+	for _, group := range groups {
+		runtimes, err := m.listRuntimes(group.JobName(), window)
+		if err != nil {
+			log.Error().Err(err).
+				Str("group_id", group.ID).
+				Msg("budget: failed to collect reconcile runtime")
+			continue
+		}
+		for allocID, runtime := range runtimes {
+			m.budgets.Observe(group.ID, allocID, runtime)
+		}
+
+		suspend := m.budgets.Exhausted(group.ID)
+		if suspend {
+			exhausted++
+		}
+
+		// The schedule of a paused group stays disabled until it's resumed,
+		// whatever its budget.
+		if group.Paused {
+			continue
+		}
+
+		changed, err := m.setPeriodic(group.JobName(), !suspend)
+		if err != nil {
+			log.Error().Err(err).
+				Str("group_id", group.ID).
+				Msg("budget: failed to update reconcile schedule")
+			continue
+		}
+		if !changed {
+			continue
+		}
+
+		if suspend {
+			log.Warn().
+				Str("group_id", group.ID).
+				Str("job_id", group.JobName()).
+				Msg("budget: reconcile budget exhausted, skipping reconciles until it resets")
+		} else {
+			log.Info().
+				Str("group_id", group.ID).
+				Str("job_id", group.JobName()).
+				Msg("budget: reconcile budget reset, resuming reconciles")
+		}
+	}
+
+	metrics.SetGauge([]string{"budget", "exhausted"}, float32(exhausted))
+
+	return nil
+}
+
+// reconcileRuntimes returns how long each reconcile allocation of jobID, in
+// scope, has run since the given time.
+func reconcileRuntimes(client *nomad.Client, scope nomadScope, jobID string, since, now time.Time) (map[string]time.Duration, error) {
+	jobIDs, err := jobFamilyIDs(client, scope, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	runtimes := make(map[string]time.Duration)
+	for _, id := range jobIDs {
+		allocs, _, err := client.Jobs().Allocations(id, true, scope.queryOptions())
+		if err != nil {
+			return nil, &ErrNomad{Op: ErrNomadAllocations, Err: err}
+		}
+
+		for _, alloc := range allocs {
+			if runtime := allocRuntime(alloc, since, now); runtime > 0 {
+				runtimes[alloc.ID] = runtime
+			}
+		}
+	}
+
+	return runtimes, nil
+}
+
+// allocRuntime sums how long the tasks of alloc ran between since and now.
+// Tasks which are still running are counted up to now.
+func allocRuntime(alloc *nomad.AllocationListStub, since, now time.Time) time.Duration {
+	var runtime time.Duration
+	for _, state := range alloc.TaskStates {
+		if state.StartedAt.IsZero() {
+			continue
+		}
+
+		start, end := state.StartedAt, state.FinishedAt
+		if end.IsZero() || end.After(now) {
+			end = now
+		}
+		if start.Before(since) {
+			start = since
+		}
+		if end.After(start) {
+			runtime += end.Sub(start)
+		}
+	}
+	return runtime
+}
+
+// setJobPeriodic enables or disables the periodic schedule of jobID, in
+// scope, returning true if the job was changed.
+func setJobPeriodic(client *nomad.Client, scope nomadScope, jobID string, enabled bool) (bool, error) {
+	job, _, err := client.Jobs().Info(jobID, scope.queryOptions())
+	if err != nil {
+		if isNotFound(err) {
+			return false, nil
+		}
+		return false, &ErrNomad{Op: ErrNomadJobInfo, Err: err}
+	}
+
+	if job.Periodic == nil || periodicEnabled(job) == enabled {
+		return false, nil
+	}
+
+	job.Periodic.Enabled = helper.BoolToPtr(enabled)
+	if _, _, err := client.Jobs().Register(job, scope.writeOptions()); err != nil {
+		return false, &ErrNomad{Op: ErrNomadRegister, Err: err}
+	}
+
+	return true, nil
+}
+
+func periodicEnabled(job *nomad.Job) bool {
+	return job.Periodic == nil || job.Periodic.Enabled == nil || *job.Periodic.Enabled
+}
+
+// limitReconciles disables the periodic schedule of job if the group has
+// exhausted its reconcile budget, so registering the job doesn't resume
+// reconciles early.
+func limitReconciles(group *ServiceGroup, job *nomad.Job) {
+	if job.Periodic == nil || !Budgets.Exhausted(group.ID) {
+		return
+	}
+
+	log.Warn().
+		Str("group_id", group.ID).
+		Str("job_id", *job.ID).
+		Msg("budget: reconcile budget exhausted, skipping reconciles until it resets")
+	job.Periodic.Enabled = helper.BoolToPtr(false)
+}
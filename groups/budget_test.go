@@ -0,0 +1,182 @@
+package groups_v1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/helper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testBudgets(limit, resetAt time.Duration, now *time.Time) *ReconcileBudgets {
+	b := NewReconcileBudgets()
+	b.now = func() time.Time { return *now }
+	b.Configure(limit, resetAt)
+	return b
+}
+
+func TestReconcileBudgetsConsume(t *testing.T) {
+	now := time.Date(2018, 4, 14, 15, 0, 0, 0, time.UTC)
+	b := testBudgets(10*time.Minute, 0, &now)
+
+	b.Observe("web-id", "alloc-1", 4*time.Minute)
+	assert.False(t, b.Exhausted("web-id"))
+
+	// An allocation observed again counts its latest runtime once.
+	b.Observe("web-id", "alloc-1", 6*time.Minute)
+	b.Observe("web-id", "alloc-1", 5*time.Minute)
+
+	status := b.Status("web-id")
+	require.NotNil(t, status)
+	assert.Equal(t, 600.0, status.LimitSeconds)
+	assert.Equal(t, 360.0, status.UsedSeconds)
+	assert.Equal(t, 240.0, status.RemainingSeconds)
+	assert.False(t, status.Exhausted)
+	assert.Equal(t, time.Date(2018, 4, 15, 0, 0, 0, 0, time.UTC), status.ResetsAt)
+
+	b.Observe("web-id", "alloc-2", 4*time.Minute)
+	assert.True(t, b.Exhausted("web-id"))
+	assert.Equal(t, 0.0, b.Status("web-id").RemainingSeconds)
+	assert.True(t, b.Status("web-id").Exhausted)
+
+	assert.False(t, b.Exhausted("db-id"))
+}
+
+func TestReconcileBudgetsReset(t *testing.T) {
+	now := time.Date(2018, 4, 14, 5, 0, 0, 0, time.UTC)
+	b := testBudgets(time.Minute, 6*time.Hour, &now)
+
+	assert.Equal(t, time.Date(2018, 4, 13, 6, 0, 0, 0, time.UTC), b.Window())
+
+	b.Observe("web-id", "alloc-1", 2*time.Minute)
+	assert.True(t, b.Exhausted("web-id"))
+
+	now = now.Add(59 * time.Minute)
+	assert.True(t, b.Exhausted("web-id"))
+
+	now = now.Add(time.Minute)
+	assert.False(t, b.Exhausted("web-id"))
+	assert.Equal(t, 0.0, b.Status("web-id").UsedSeconds)
+	assert.Equal(t, time.Date(2018, 4, 15, 6, 0, 0, 0, time.UTC), b.Status("web-id").ResetsAt)
+}
+
+func TestReconcileBudgetsDisabled(t *testing.T) {
+	now := time.Date(2018, 4, 14, 15, 0, 0, 0, time.UTC)
+	b := testBudgets(0, 0, &now)
+
+	b.Observe("web-id", "alloc-1", 24*time.Hour)
+	assert.False(t, b.Enabled())
+	assert.False(t, b.Exhausted("web-id"))
+	assert.Nil(t, b.Status("web-id"))
+}
+
+func TestAllocRuntime(t *testing.T) {
+	since := time.Date(2018, 4, 14, 0, 0, 0, 0, time.UTC)
+	now := since.Add(time.Hour)
+
+	alloc := &nomad.AllocationListStub{
+		TaskStates: map[string]*nomad.TaskState{
+			// Started before the window, only the part within it counts.
+			"before": {StartedAt: since.Add(-time.Minute), FinishedAt: since.Add(2 * time.Minute)},
+			"done":   {StartedAt: since.Add(10 * time.Minute), FinishedAt: since.Add(13 * time.Minute)},
+			// Still running, counted up to now.
+			"running": {StartedAt: now.Add(-time.Minute)},
+			"pending": {},
+		},
+	}
+
+	assert.Equal(t, 6*time.Minute, allocRuntime(alloc, since, now))
+}
+
+func TestBudgetMonitor(t *testing.T) {
+	now := time.Date(2018, 4, 14, 15, 0, 0, 0, time.UTC)
+	budgets := testBudgets(10*time.Minute, 0, &now)
+
+	groups := testManagedGroups("web", "db")
+	runtimes := map[string]map[string]time.Duration{
+		groups[0].JobName(): {"alloc-1": 8 * time.Minute},
+		groups[1].JobName(): {"alloc-2": time.Minute},
+	}
+	enabled := map[string]bool{}
+
+	m := &BudgetMonitor{
+		budgets: budgets,
+		findGroups: func(ctx context.Context) ([]*ManagedGroup, error) {
+			return groups, nil
+		},
+		listRuntimes: func(jobID string, since time.Time) (map[string]time.Duration, error) {
+			assert.Equal(t, budgets.Window(), since)
+			return runtimes[jobID], nil
+		},
+		setPeriodic: func(jobID string, e bool) (bool, error) {
+			changed := enabled[jobID] != e
+			enabled[jobID] = e
+			return changed, nil
+		},
+	}
+
+	require.NoError(t, m.Check(context.Background()))
+	assert.True(t, enabled[groups[0].JobName()])
+	assert.True(t, enabled[groups[1].JobName()])
+
+	runtimes[groups[0].JobName()]["alloc-3"] = 3 * time.Minute
+	require.NoError(t, m.Check(context.Background()))
+	assert.False(t, enabled[groups[0].JobName()])
+	assert.True(t, enabled[groups[1].JobName()])
+
+	// Once the window resets the old allocations fall outside of it.
+	now = now.Add(12 * time.Hour)
+	runtimes[groups[0].JobName()] = nil
+	require.NoError(t, m.Check(context.Background()))
+	assert.True(t, enabled[groups[0].JobName()])
+}
+
+func TestBudgetMonitorPausedGroup(t *testing.T) {
+	now := time.Date(2018, 4, 14, 15, 0, 0, 0, time.UTC)
+	budgets := testBudgets(10*time.Minute, 0, &now)
+
+	groups := testManagedGroups("web")
+	groups[0].Paused = true
+
+	m := &BudgetMonitor{
+		budgets: budgets,
+		findGroups: func(ctx context.Context) ([]*ManagedGroup, error) {
+			return groups, nil
+		},
+		listRuntimes: func(jobID string, since time.Time) (map[string]time.Duration, error) {
+			return nil, nil
+		},
+		setPeriodic: func(jobID string, e bool) (bool, error) {
+			t.Fatalf("the schedule of paused job %s was set to %v", jobID, e)
+			return false, nil
+		},
+	}
+
+	require.NoError(t, m.Check(context.Background()))
+}
+
+func TestLimitReconciles(t *testing.T) {
+	now := time.Date(2018, 4, 14, 15, 0, 0, 0, time.UTC)
+	defer func(b *ReconcileBudgets) { Budgets = b }(Budgets)
+	Budgets = testBudgets(time.Minute, 0, &now)
+
+	group := &ServiceGroup{ID: "web-id"}
+	newJob := func() *nomad.Job {
+		return &nomad.Job{
+			ID:       helper.StringToPtr("web_c2e4d1491ce423e3"),
+			Periodic: &nomad.PeriodicConfig{Spec: helper.StringToPtr("*/2 * * * * *")},
+		}
+	}
+
+	job := newJob()
+	limitReconciles(group, job)
+	assert.True(t, periodicEnabled(job))
+
+	Budgets.Observe(group.ID, "alloc-1", time.Minute)
+	job = newJob()
+	limitReconciles(group, job)
+	assert.False(t, periodicEnabled(job))
+}
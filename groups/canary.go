@@ -0,0 +1,507 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/jackc/pgx"
+	"github.com/joyent/triton-go/compute"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	CanaryCheckHTTP = "http"
+	CanaryCheckTCP  = "tcp"
+
+	// CanaryPending is a canary which has yet to pass its check.
+	CanaryPending = "pending"
+	// CanaryPassed is a canary which passed its check, after which the group
+	// was scaled up to its full capacity.
+	CanaryPassed = "passed"
+	// CanaryDegraded is a canary which failed its check before timing out.
+	// The group is held at the canary's capacity until it's next updated.
+	CanaryDegraded = "degraded"
+
+	// DefaultCanaryTimeout is how long a canary has to pass its check unless
+	// the group configures otherwise.
+	DefaultCanaryTimeout = 5 * time.Minute
+
+	canaryProbeTimeout = 5 * time.Second
+)
+
+// Canaries tracks the canary of every group which is scaling up.
+var Canaries = NewCanaryTracker()
+
+// CanaryConfig configures the check a group's canary instance must pass
+// before the group scales up by more than one instance.
+type CanaryConfig struct {
+	// Check is either "http", requiring a 2xx response to a GET of Path, or
+	// "tcp", requiring a connection to be accepted.
+	Check          string `json:"check"`
+	Port           int    `json:"port"`
+	Path           string `json:"path,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds,omitempty"`
+}
+
+func (c *CanaryConfig) timeout() time.Duration {
+	if c.TimeoutSeconds == 0 {
+		return DefaultCanaryTimeout
+	}
+	return time.Duration(c.TimeoutSeconds) * time.Second
+}
+
+func (c *CanaryConfig) validate() error {
+	switch c.Check {
+	case CanaryCheckHTTP, CanaryCheckTCP:
+	default:
+		return fmt.Errorf("canary check must be %q or %q", CanaryCheckHTTP, CanaryCheckTCP)
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return errors.New("canary port must be between 1 and 65535")
+	}
+	if c.TimeoutSeconds < 0 {
+		return errors.New("canary timeout cannot be a negative number")
+	}
+	return nil
+}
+
+// CanaryStatus reports the canary of a group scaling up.
+type CanaryStatus struct {
+	State string `json:"state"`
+	// Capacity is the capacity the group runs at until its canary passes.
+	Capacity int `json:"capacity"`
+	// Target is the capacity the group scales up to once its canary passes.
+	Target     int       `json:"target"`
+	InstanceID string    `json:"instance_id,omitempty"`
+	Reason     string    `json:"reason,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	Deadline   time.Time `json:"deadline"`
+}
+
+type canary struct {
+	status CanaryStatus
+	// baseline is the number of instances running before the canary.
+	baseline int
+}
+
+// CanaryTracker holds the canary of each group.
+type CanaryTracker struct {
+	mu       sync.Mutex
+	canaries map[string]*canary
+
+	now func() time.Time
+}
+
+// NewCanaryTracker constructs an empty canary tracker.
+func NewCanaryTracker() *CanaryTracker {
+	return &CanaryTracker{
+		canaries: make(map[string]*canary),
+		now:      time.Now,
+	}
+}
+
+// Start begins a canary for a group running baseline instances which is
+// scaling up to target, returning the capacity to run until it passes.
+func (t *CanaryTracker) Start(groupID string, baseline, target int, timeout time.Duration) int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := t.now()
+	c := &canary{
+		status: CanaryStatus{
+			State:     CanaryPending,
+			Capacity:  baseline + 1,
+			Target:    target,
+			StartedAt: now,
+			Deadline:  now.Add(timeout),
+		},
+		baseline: baseline,
+	}
+	t.canaries[groupID] = c
+
+	return c.status.Capacity
+}
+
+// Capacity returns the capacity to run a group scaling up to target at, if it
+// already has a canary for that target.
+func (t *CanaryTracker) Capacity(groupID string, target int) (int, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c, ok := t.canaries[groupID]
+	if !ok || c.status.Target != target {
+		return 0, false
+	}
+	if c.status.State == CanaryPassed {
+		return target, true
+	}
+	return c.status.Capacity, true
+}
+
+// Pending returns the IDs of every group with a pending canary.
+func (t *CanaryTracker) Pending() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var ids []string
+	for id, c := range t.canaries {
+		if c.status.State == CanaryPending {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// Pass records that the group's canary instance passed its check.
+func (t *CanaryTracker) Pass(groupID, instanceID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if c, ok := t.canaries[groupID]; ok {
+		c.status.State = CanaryPassed
+		c.status.InstanceID = instanceID
+		c.status.Reason = ""
+	}
+}
+
+// Fail records that the group's canary didn't pass before timing out.
+func (t *CanaryTracker) Fail(groupID, instanceID, reason string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if c, ok := t.canaries[groupID]; ok {
+		c.status.State = CanaryDegraded
+		c.status.InstanceID = instanceID
+		c.status.Reason = reason
+	}
+}
+
+// Status returns the canary of the group, or nil if it has none.
+func (t *CanaryTracker) Status(groupID string) *CanaryStatus {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c, ok := t.canaries[groupID]
+	if !ok {
+		return nil
+	}
+	status := c.status
+	return &status
+}
+
+// Forget discards the canary of a group.
+func (t *CanaryTracker) Forget(groupID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.canaries, groupID)
+}
+
+func (t *CanaryTracker) get(groupID string) (canary, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c, ok := t.canaries[groupID]
+	if !ok {
+		return canary{}, false
+	}
+	return *c, true
+}
+
+// countCanaryBaseline counts the instances a group runs before a canary.
+var countCanaryBaseline = func(ctx context.Context, group *ServiceGroup) (int, error) {
+	session := handlers.GetAuthSession(ctx)
+	return countRunningInstances(ctx, session.TritonURL, &ManagedGroup{
+		ServiceGroup: group,
+		AccountID:    session.AccountID,
+	})
+}
+
+// canaryCapacity returns the capacity to submit a group's job with. A group
+// with a canary check which is scaling up by more than one instance first
+// runs a single additional instance, until the canary monitor has verified
+// that it's healthy.
+func canaryCapacity(ctx context.Context, group *ServiceGroup) (int, error) {
+	if group.Canary == nil {
+		Canaries.Forget(group.ID)
+		return group.Capacity, nil
+	}
+
+	if capacity, ok := Canaries.Capacity(group.ID, group.Capacity); ok {
+		return capacity, nil
+	}
+
+	running, err := countCanaryBaseline(ctx, group)
+	if err != nil {
+		return 0, err
+	}
+
+	if group.Capacity <= running+1 {
+		Canaries.Forget(group.ID)
+		return group.Capacity, nil
+	}
+
+	capacity := Canaries.Start(group.ID, running, group.Capacity, group.Canary.timeout())
+	log.Info().
+		Str("group_id", group.ID).
+		Int("capacity", capacity).
+		Int("target", group.Capacity).
+		Msg("canary: scaling up to a single canary instance first")
+
+	return capacity, nil
+}
+
+// withCapacity returns a copy of group running capacity instances.
+func withCapacity(group *ServiceGroup, capacity int) *ServiceGroup {
+	if capacity == group.Capacity {
+		return group
+	}
+	g := *group
+	g.Capacity = capacity
+	return &g
+}
+
+// CanaryMonitor periodically checks the canary instance of every group with
+// a pending canary, scaling the group up to its full capacity once it passes
+// and holding it at the canary's capacity if it times out.
+type CanaryMonitor struct {
+	interval   time.Duration
+	datacenter string
+	tritonURL  string
+	pool       *pgx.ConnPool
+	client     *nomad.Client
+	canaries   *CanaryTracker
+
+	findGroups    func(ctx context.Context) ([]*ManagedGroup, error)
+	listInstances func(ctx context.Context, group *ManagedGroup) ([]*compute.Instance, error)
+	probe         func(ctx context.Context, check *CanaryConfig, ip string) error
+	promote       func(ctx context.Context, group *ManagedGroup) error
+}
+
+// NewCanaryMonitor constructs a canary monitor for the given tracker which
+// acts as the given datacenter and Triton URL, promoting groups through the
+// given Nomad client.
+func NewCanaryMonitor(interval time.Duration, datacenter, tritonURL string, pool *pgx.ConnPool, client *nomad.Client, canaries *CanaryTracker) *CanaryMonitor {
+	m := &CanaryMonitor{
+		interval:   interval,
+		datacenter: datacenter,
+		tritonURL:  tritonURL,
+		pool:       pool,
+		client:     client,
+		canaries:   canaries,
+		findGroups: findLocalGroups,
+		probe:      probeCanary,
+	}
+	m.listInstances = func(ctx context.Context, group *ManagedGroup) ([]*compute.Instance, error) {
+		return listGroupInstances(ctx, group.AccountID, m.tritonURL, group.ServiceGroup)
+	}
+	m.promote = func(ctx context.Context, group *ManagedGroup) error {
+		ctx = handlers.WithAuthSession(ctx, backgroundSession(group.AccountID, m.datacenter, m.tritonURL))
+		_, err := registerGroupJob(ctx, group.ServiceGroup, group.Capacity, true, config.GetForceOnSubmit())
+		return err
+	}
+	return m
+}
+
+// Run checks pending canaries once per interval until ctx is done.
+func (m *CanaryMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Check(ctx); err != nil {
+				log.Error().Err(err).Msg("canary: failed to check groups")
+			}
+		}
+	}
+}
+
+// Check runs the check of every pending canary once.
+func (m *CanaryMonitor) Check(ctx context.Context) error {
+	pending := m.canaries.Pending()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	ctx = handlers.WithNomadClient(handlers.WithDBPool(ctx, m.pool), m.client)
+
+	groups, err := m.findGroups(ctx)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[string]*ManagedGroup, len(groups))
+	for _, group := range groups {
+		byID[group.ID] = group
+	}
+
+	for _, id := range pending {
+		group, ok := byID[id]
+		c, tracked := m.canaries.get(id)
+		if !ok || !tracked || group.Canary == nil || group.Capacity != c.status.Target {
+			m.canaries.Forget(id)
+			continue
+		}
+
+		m.checkCanary(ctx, group, c)
+	}
+
+	return nil
+}
+
+func (m *CanaryMonitor) checkCanary(ctx context.Context, group *ManagedGroup, c canary) {
+	expired := !m.canaries.now().Before(c.status.Deadline)
+
+	instances, err := m.listInstances(ctx, group)
+	if err != nil {
+		log.Error().Err(err).
+			Str("group_id", group.ID).
+			Msg("canary: failed to list group instances")
+		return
+	}
+
+	instance := newestRunning(instances, c.baseline)
+	if instance == nil {
+		if expired {
+			m.fail(group, "", "canary instance didn't start before the timeout")
+		}
+		return
+	}
+
+	if err := m.probe(ctx, group.Canary, instance.PrimaryIP); err != nil {
+		if expired {
+			m.fail(group, instance.ID, fmt.Sprintf("canary instance failed its %s check: %v", group.Canary.Check, err))
+		}
+		return
+	}
+
+	if err := m.promote(ctx, group); err != nil {
+		log.Error().Err(err).
+			Str("group_id", group.ID).
+			Msg("canary: failed to scale up group")
+		return
+	}
+	m.canaries.Pass(group.ID, instance.ID)
+
+	log.Info().
+		Str("group_id", group.ID).
+		Str("instance_id", instance.ID).
+		Int("capacity", group.Capacity).
+		Msg("canary: canary passed, scaling up group")
+}
+
+func (m *CanaryMonitor) fail(group *ManagedGroup, instanceID, reason string) {
+	m.canaries.Fail(group.ID, instanceID, reason)
+
+	log.Warn().
+		Str("group_id", group.ID).
+		Str("instance_id", instanceID).
+		Str("reason", reason).
+		Msg("canary: canary failed, holding group at canary capacity")
+}
+
+// newestRunning returns the most recently created running instance, once
+// more than baseline instances are running.
+func newestRunning(instances []*compute.Instance, baseline int) *compute.Instance {
+	var (
+		newest  *compute.Instance
+		running int
+	)
+	for _, instance := range instances {
+		if instance.State != "running" {
+			continue
+		}
+		running++
+		if newest == nil || instance.Created.After(newest.Created) {
+			newest = instance
+		}
+	}
+
+	if running <= baseline {
+		return nil
+	}
+	return newest
+}
+
+// probeCanary runs check against the instance at ip.
+func probeCanary(ctx context.Context, check *CanaryConfig, ip string) error {
+	addr := net.JoinHostPort(ip, strconv.Itoa(check.Port))
+
+	ctx, cancel := context.WithTimeout(ctx, canaryProbeTimeout)
+	defer cancel()
+
+	if check.Check == CanaryCheckTCP {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	}
+
+	path := check.Path
+	if path == "" {
+		path = "/"
+	}
+
+	req, err := http.NewRequest(http.MethodGet, "http://"+addr+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("responded with %s", resp.Status)
+	}
+	return nil
+}
+
+func encodeCanary(check *CanaryConfig) (string, error) {
+	if check == nil {
+		return "", nil
+	}
+
+	bytes, err := json.Marshal(check)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+func decodeCanary(data string) (*CanaryConfig, error) {
+	if data == "" {
+		return nil, nil
+	}
+
+	var check CanaryConfig
+	if err := json.Unmarshal([]byte(data), &check); err != nil {
+		return nil, err
+	}
+	return &check, nil
+}
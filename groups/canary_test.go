@@ -0,0 +1,366 @@
+package groups_v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/joyent/triton-go/compute"
+	"github.com/joyent/triton-service-groups/accounts"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/joyent/triton-service-groups/testutils"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCanaries(now *time.Time) *CanaryTracker {
+	c := NewCanaryTracker()
+	c.now = func() time.Time { return *now }
+	return c
+}
+
+func testCanaryInstances(created time.Time, states ...string) []*compute.Instance {
+	var instances []*compute.Instance
+	for i, state := range states {
+		instances = append(instances, &compute.Instance{
+			ID:        "instance-" + strconv.Itoa(i),
+			State:     state,
+			PrimaryIP: "10.0.0." + strconv.Itoa(i+10),
+			Created:   created.Add(time.Duration(i) * time.Minute),
+		})
+	}
+	return instances
+}
+
+func TestCanaryCapacity(t *testing.T) {
+	now := time.Date(2018, 4, 14, 15, 0, 0, 0, time.UTC)
+	defer func(c *CanaryTracker) { Canaries = c }(Canaries)
+	Canaries = testCanaries(&now)
+
+	defer func(f func(ctx context.Context, group *ServiceGroup) (int, error)) {
+		countCanaryBaseline = f
+	}(countCanaryBaseline)
+	running := 2
+	countCanaryBaseline = func(ctx context.Context, group *ServiceGroup) (int, error) {
+		return running, nil
+	}
+
+	ctx := context.Background()
+
+	// Groups without a canary check always run their full capacity.
+	group := &ServiceGroup{ID: "web-id", Capacity: 5}
+	capacity, err := canaryCapacity(ctx, group)
+	require.NoError(t, err)
+	assert.Equal(t, 5, capacity)
+	assert.Nil(t, Canaries.Status(group.ID))
+
+	group.Canary = &CanaryConfig{Check: CanaryCheckHTTP, Port: 80, TimeoutSeconds: 60}
+	capacity, err = canaryCapacity(ctx, group)
+	require.NoError(t, err)
+	assert.Equal(t, 3, capacity)
+
+	status := Canaries.Status(group.ID)
+	require.NotNil(t, status)
+	assert.Equal(t, CanaryPending, status.State)
+	assert.Equal(t, 3, status.Capacity)
+	assert.Equal(t, 5, status.Target)
+	assert.Equal(t, now.Add(time.Minute), status.Deadline)
+
+	// Resubmitting the same capacity keeps to the canary's.
+	running = 3
+	capacity, err = canaryCapacity(ctx, group)
+	require.NoError(t, err)
+	assert.Equal(t, 3, capacity)
+
+	Canaries.Pass(group.ID, "instance-2")
+	capacity, err = canaryCapacity(ctx, group)
+	require.NoError(t, err)
+	assert.Equal(t, 5, capacity)
+
+	// Scaling up by a single instance needs no canary.
+	Canaries.Forget(group.ID)
+	group.Capacity = 4
+	capacity, err = canaryCapacity(ctx, group)
+	require.NoError(t, err)
+	assert.Equal(t, 4, capacity)
+	assert.Nil(t, Canaries.Status(group.ID))
+
+	// Nor does scaling down.
+	group.Capacity = 1
+	capacity, err = canaryCapacity(ctx, group)
+	require.NoError(t, err)
+	assert.Equal(t, 1, capacity)
+}
+
+func TestCanaryMonitorPass(t *testing.T) {
+	now := time.Date(2018, 4, 14, 15, 0, 0, 0, time.UTC)
+	canaries := testCanaries(&now)
+
+	groups := testManagedGroups("web")
+	group := groups[0]
+	group.Capacity = 5
+	group.Canary = &CanaryConfig{Check: CanaryCheckHTTP, Port: 8080, Path: "/health"}
+	canaries.Start(group.ID, 2, group.Capacity, group.Canary.timeout())
+
+	instances := testCanaryInstances(now.Add(-time.Hour), "running", "running")
+	healthy := false
+	var probed []string
+	var promoted []*ManagedGroup
+
+	m := &CanaryMonitor{
+		canaries: canaries,
+		findGroups: func(ctx context.Context) ([]*ManagedGroup, error) {
+			return groups, nil
+		},
+		listInstances: func(ctx context.Context, group *ManagedGroup) ([]*compute.Instance, error) {
+			return instances, nil
+		},
+		probe: func(ctx context.Context, check *CanaryConfig, ip string) error {
+			probed = append(probed, ip)
+			if !healthy {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+		promote: func(ctx context.Context, group *ManagedGroup) error {
+			promoted = append(promoted, group)
+			return nil
+		},
+	}
+
+	// The canary instance hasn't started yet.
+	require.NoError(t, m.Check(context.Background()))
+	assert.Empty(t, probed)
+
+	instances = testCanaryInstances(now.Add(-time.Hour), "running", "running", "running")
+	require.NoError(t, m.Check(context.Background()))
+	assert.Equal(t, []string{"10.0.0.12"}, probed)
+	assert.Empty(t, promoted)
+	assert.Equal(t, CanaryPending, canaries.Status(group.ID).State)
+
+	healthy = true
+	require.NoError(t, m.Check(context.Background()))
+	require.Len(t, promoted, 1)
+	assert.Equal(t, 5, promoted[0].Capacity)
+
+	status := canaries.Status(group.ID)
+	assert.Equal(t, CanaryPassed, status.State)
+	assert.Equal(t, "instance-2", status.InstanceID)
+	assert.Empty(t, canaries.Pending())
+
+	capacity, ok := canaries.Capacity(group.ID, 5)
+	assert.True(t, ok)
+	assert.Equal(t, 5, capacity)
+}
+
+func TestCanaryMonitorPromote(t *testing.T) {
+	now := time.Date(2018, 4, 14, 15, 0, 0, 0, time.UTC)
+	canaries := testCanaries(&now)
+
+	groups := testManagedGroups("web")
+	group := groups[0]
+	group.Capacity = 5
+	group.Canary = &CanaryConfig{Check: CanaryCheckTCP, Port: 5432}
+	canaries.Start(group.ID, 0, group.Capacity, group.Canary.timeout())
+
+	defer viper.Reset()
+	viper.Set(config.KeyNomadDatacenters, []string{"east-a", "east-b"})
+
+	defer func(f func(ctx context.Context, group *ServiceGroup, capacity int, check bool) (*nomad.Job, error)) {
+		buildGroupJob = f
+	}(buildGroupJob)
+	var session *auth.Session
+	buildGroupJob = func(ctx context.Context, group *ServiceGroup, capacity int, check bool) (*nomad.Job, error) {
+		session = handlers.GetAuthSession(ctx)
+		details := testJobDetails(nil)
+		details.DesiredCount = capacity
+		return buildJob(details)
+	}
+
+	defer func(f func(ctx context.Context) (*accounts.Account, error)) {
+		findSessionAccount = f
+	}(findSessionAccount)
+	findSessionAccount = func(ctx context.Context) (*accounts.Account, error) {
+		return &accounts.Account{TritonUUID: "87307a00-ab96-4fec-8df7-1a256e49fbcc"}, nil
+	}
+
+	fake := testutils.NewFakeNomad(t)
+	defer fake.Close()
+
+	var registered []*nomad.Job
+	fake.HandleJSON("/v1/validate/job", &nomad.JobValidateResponse{})
+	fake.HandleFunc("/v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		var req nomad.JobRegisterRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		registered = append(registered, req.Job)
+		testutils.WriteJSON(w, &nomad.JobRegisterResponse{EvalID: "register-eval"})
+	})
+	fake.HandleFunc("/v1/job/"+jobName("web", testJobRef)+"/periodic/force", func(w http.ResponseWriter, r *http.Request) {
+		testutils.WriteJSON(w, map[string]string{"EvalID": "periodic-eval"})
+	})
+
+	m := NewCanaryMonitor(time.Minute, "us-east-1", "https://us-east-1.api.joyent.com", nil, fake.Client, canaries)
+	m.findGroups = func(ctx context.Context) ([]*ManagedGroup, error) {
+		return groups, nil
+	}
+	m.listInstances = func(ctx context.Context, group *ManagedGroup) ([]*compute.Instance, error) {
+		return testCanaryInstances(now, "running"), nil
+	}
+	m.probe = func(ctx context.Context, check *CanaryConfig, ip string) error {
+		return nil
+	}
+
+	require.NoError(t, m.Check(context.Background()))
+
+	require.Len(t, registered, 1)
+	var args []string
+	for _, arg := range registered[0].TaskGroups[0].Tasks[0].Config["args"].([]interface{}) {
+		args = append(args, arg.(string))
+	}
+	assert.Equal(t, "5", argValue(args, "--count"))
+	assert.Equal(t, group.AccountID, session.AccountID)
+	assert.Equal(t, "us-east-1", session.Datacenter)
+	assert.Equal(t, []string{"east-a", "east-b"}, session.NomadDatacenters)
+	assert.Equal(t, CanaryPassed, canaries.Status(group.ID).State)
+}
+
+func TestCanaryMonitorFail(t *testing.T) {
+	now := time.Date(2018, 4, 14, 15, 0, 0, 0, time.UTC)
+	canaries := testCanaries(&now)
+
+	groups := testManagedGroups("web", "db")
+	web, db := groups[0], groups[1]
+	web.Capacity = 5
+	web.Canary = &CanaryConfig{Check: CanaryCheckTCP, Port: 5432, TimeoutSeconds: 60}
+	canaries.Start(web.ID, 0, web.Capacity, web.Canary.timeout())
+
+	// The canary of a group which no longer has a check is forgotten.
+	canaries.Start(db.ID, 0, db.Capacity, DefaultCanaryTimeout)
+
+	m := &CanaryMonitor{
+		canaries: canaries,
+		findGroups: func(ctx context.Context) ([]*ManagedGroup, error) {
+			return groups, nil
+		},
+		listInstances: func(ctx context.Context, group *ManagedGroup) ([]*compute.Instance, error) {
+			return testCanaryInstances(now, "running"), nil
+		},
+		probe: func(ctx context.Context, check *CanaryConfig, ip string) error {
+			return errors.New("connection refused")
+		},
+		promote: func(ctx context.Context, group *ManagedGroup) error {
+			t.Fatal("a failed canary must not be promoted")
+			return nil
+		},
+	}
+
+	require.NoError(t, m.Check(context.Background()))
+	assert.Equal(t, CanaryPending, canaries.Status(web.ID).State)
+	assert.Nil(t, canaries.Status(db.ID))
+
+	now = now.Add(time.Minute)
+	require.NoError(t, m.Check(context.Background()))
+
+	status := canaries.Status(web.ID)
+	assert.Equal(t, CanaryDegraded, status.State)
+	assert.Equal(t, "instance-0", status.InstanceID)
+	assert.Equal(t, "canary instance failed its tcp check: connection refused", status.Reason)
+	assert.Empty(t, canaries.Pending())
+
+	// The group is held at the canary's capacity.
+	capacity, ok := canaries.Capacity(web.ID, 5)
+	assert.True(t, ok)
+	assert.Equal(t, 1, capacity)
+
+	// Until its capacity changes.
+	_, ok = canaries.Capacity(web.ID, 6)
+	assert.False(t, ok)
+}
+
+func TestProbeCanaryHTTP(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/health" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	host, port, err := net.SplitHostPort(u.Host)
+	require.NoError(t, err)
+	p, err := strconv.Atoi(port)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	assert.NoError(t, probeCanary(ctx, &CanaryConfig{Check: CanaryCheckHTTP, Port: p, Path: "/health"}, host))
+	assert.EqualError(t, probeCanary(ctx, &CanaryConfig{Check: CanaryCheckHTTP, Port: p}, host),
+		"responded with 503 Service Unavailable")
+}
+
+func TestProbeCanaryTCP(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	port := l.Addr().(*net.TCPAddr).Port
+	check := &CanaryConfig{Check: CanaryCheckTCP, Port: port}
+
+	ctx := context.Background()
+	assert.NoError(t, probeCanary(ctx, check, "127.0.0.1"))
+
+	require.NoError(t, l.Close())
+	assert.Error(t, probeCanary(ctx, check, "127.0.0.1"))
+}
+
+func TestDecodeGroupCanary(t *testing.T) {
+	group, err := decodeGroupResponseBodyAndValidate([]byte(`{
+		"group_name": "web",
+		"template_id": "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+		"capacity": 5,
+		"canary": {"check": "http", "port": 8080, "path": "/health"}
+	}`))
+	require.NoError(t, err)
+	require.NotNil(t, group.Canary)
+	assert.Equal(t, DefaultCanaryTimeout, group.Canary.timeout())
+
+	_, err = decodeGroupResponseBodyAndValidate([]byte(`{
+		"template_id": "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+		"canary": {"check": "udp", "port": 8080}
+	}`))
+	assert.EqualError(t, err, `canary check must be "http" or "tcp"`)
+
+	_, err = decodeGroupResponseBodyAndValidate([]byte(`{
+		"template_id": "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+		"canary": {"check": "tcp", "port": 0}
+	}`))
+	assert.EqualError(t, err, "canary port must be between 1 and 65535")
+
+	ctx := handlers.WithAuthSession(context.Background(), &auth.Session{Datacenter: "us-east-1"})
+	assert.EqualError(t, validateDatacenters(ctx, &ServiceGroup{
+		Datacenters: map[string]int{"us-east-1": 2},
+		Canary:      group.Canary,
+	}), "canary checks are not supported for groups with per-datacenter capacity")
+
+	data, err := encodeCanary(group.Canary)
+	require.NoError(t, err)
+	assert.Equal(t, `{"check":"http","port":8080,"path":"/health"}`, data)
+
+	check, err := decodeCanary(data)
+	require.NoError(t, err)
+	assert.Equal(t, group.Canary, check)
+
+	check, err = decodeCanary("")
+	require.NoError(t, err)
+	assert.Nil(t, check)
+}
@@ -0,0 +1,56 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+
+	"github.com/joyent/triton-service-groups/accounts"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+)
+
+// checkCapacity returns an ErrInvalidCapacity if group would run more
+// instances in any of its datacenters than its account may, so the group is
+// rejected before it's saved rather than once its job is prepared.
+func checkCapacity(ctx context.Context, accountID string, group *ServiceGroup) error {
+	max, err := maxCapacity(ctx, accountID)
+	if err != nil {
+		return err
+	}
+
+	for _, capacity := range datacenterCapacity(ctx, group) {
+		if capacity > max {
+			return &ErrInvalidCapacity{Capacity: capacity, Max: max}
+		}
+	}
+	return nil
+}
+
+// maxCapacity returns the largest capacity the groups of the account may run
+// with in a datacenter.
+func maxCapacity(ctx context.Context, accountID string) (int, error) {
+	name, err := findAccountName(ctx, accountID)
+	if err != nil {
+		return 0, err
+	}
+	return config.GetMaxCapacity(name), nil
+}
+
+// findAccountName returns the name of the account. It's a variable so tests
+// can run without a database.
+var findAccountName = func(ctx context.Context, accountID string) (string, error) {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return "", handlers.ErrNoConnPool
+	}
+
+	account, err := accounts.NewStore(db).FindByID(ctx, accountID)
+	if err != nil {
+		return "", err
+	}
+	return account.AccountName, nil
+}
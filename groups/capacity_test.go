@@ -0,0 +1,54 @@
+package groups_v1
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckCapacity(t *testing.T) {
+	defer viper.Reset()
+	viper.Set(config.KeyCapacityAccounts, map[string]interface{}{"bigacct": 500})
+
+	defer func(f func(context.Context, string) (string, error)) { findAccountName = f }(findAccountName)
+	names := map[string]string{"a1": "testacct", "a2": "bigacct"}
+	findAccountName = func(ctx context.Context, accountID string) (string, error) {
+		return names[accountID], nil
+	}
+
+	ctx := handlers.WithAuthSession(context.Background(), &auth.Session{Datacenter: "us-east-1"})
+
+	assert.NoError(t, checkCapacity(ctx, "a1", &ServiceGroup{Capacity: 0}))
+	assert.NoError(t, checkCapacity(ctx, "a1", &ServiceGroup{Capacity: 100}))
+	assert.NoError(t, checkCapacity(ctx, "a2", &ServiceGroup{Capacity: 500}))
+
+	err := checkCapacity(ctx, "a1", &ServiceGroup{Capacity: 101})
+	assert.EqualError(t, err, "group capacity cannot be more than 100 compute instances")
+	assert.Equal(t, http.StatusBadRequest, orchestratorErrorStatus(err))
+
+	// The maximum applies to each datacenter rather than the total.
+	group := &ServiceGroup{Capacity: 150, Datacenters: map[string]int{"us-east-1": 75, "us-west-1": 75}}
+	assert.NoError(t, checkCapacity(ctx, "a1", group))
+	group.Datacenters["us-west-1"] = 101
+	assert.Equal(t, &ErrInvalidCapacity{Capacity: 101, Max: 100}, checkCapacity(ctx, "a1", group))
+}
+
+func TestJobDetailsCapacity(t *testing.T) {
+	tmpl := &templates_v1.InstanceTemplate{Package: "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0", ImageID: "49b22aec-0c8a-11e6-8807-a3eb4db576ba"}
+
+	details, err := createJobDetails(tmpl, &ServiceGroup{GroupName: "web", Capacity: 0})
+	require.NoError(t, err)
+	assert.Equal(t, 0, details.DesiredCount)
+
+	_, err = createJobDetails(tmpl, &ServiceGroup{GroupName: "web", Capacity: -1})
+	assert.EqualError(t, err, "group capacity cannot be a negative number")
+	assert.Equal(t, http.StatusBadRequest, orchestratorErrorStatus(err))
+}
@@ -0,0 +1,102 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/joyent/triton-service-groups/health"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/rs/zerolog/log"
+)
+
+// ConvergenceMonitor periodically checks whether groups with a newly
+// submitted job are running their desired capacity, completing their
+// convergence in the tracker once they are.
+type ConvergenceMonitor struct {
+	interval  time.Duration
+	tritonURL string
+	pool      *pgx.ConnPool
+	tracker   *health.ConvergenceTracker
+
+	findGroups     func(ctx context.Context) ([]*ManagedGroup, error)
+	countInstances func(ctx context.Context, group *ManagedGroup) (int, error)
+}
+
+// NewConvergenceMonitor constructs a convergence monitor for the given
+// tracker.
+func NewConvergenceMonitor(interval time.Duration, tritonURL string, pool *pgx.ConnPool, tracker *health.ConvergenceTracker) *ConvergenceMonitor {
+	m := &ConvergenceMonitor{
+		interval:   interval,
+		tritonURL:  tritonURL,
+		pool:       pool,
+		tracker:    tracker,
+		findGroups: findLocalGroups,
+	}
+	m.countInstances = func(ctx context.Context, group *ManagedGroup) (int, error) {
+		return countRunningInstances(ctx, m.tritonURL, group)
+	}
+	return m
+}
+
+// Run checks converging groups once per interval until ctx is done.
+func (m *ConvergenceMonitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(m.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := m.Check(ctx); err != nil {
+				log.Error().Err(err).Msg("convergence: failed to check groups")
+			}
+		}
+	}
+}
+
+// Check observes every converging group once.
+func (m *ConvergenceMonitor) Check(ctx context.Context) error {
+	pending := m.tracker.Pending()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	ctx = handlers.WithDBPool(ctx, m.pool)
+
+	groups, err := m.findGroups(ctx)
+	if err != nil {
+		return err
+	}
+
+	byID := make(map[string]*ManagedGroup, len(groups))
+	for _, group := range groups {
+		byID[group.ID] = group
+	}
+
+	for _, id := range pending {
+		group, ok := byID[id]
+		if !ok {
+			m.tracker.Forget(id)
+			continue
+		}
+
+		running, err := m.countInstances(ctx, group)
+		if err != nil {
+			log.Error().Err(err).
+				Str("group_id", id).
+				Msg("convergence: failed to count group instances")
+			continue
+		}
+
+		m.tracker.Observed(id, running == group.Capacity)
+	}
+
+	return nil
+}
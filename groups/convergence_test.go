@@ -0,0 +1,40 @@
+package groups_v1
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-service-groups/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConvergenceMonitor(t *testing.T) {
+	groups := testManagedGroups("web", "db")
+	running := map[string]int{"web-id": 0, "db-id": 2}
+
+	tracker := health.NewConvergenceTracker(5*time.Minute, 30*time.Minute, time.Hour)
+	m := &ConvergenceMonitor{
+		tracker: tracker,
+		findGroups: func(ctx context.Context) ([]*ManagedGroup, error) {
+			return groups, nil
+		},
+		countInstances: func(ctx context.Context, group *ManagedGroup) (int, error) {
+			return running[group.ID], nil
+		},
+	}
+
+	tracker.Submitted("web-id")
+	tracker.Submitted("db-id")
+	tracker.Submitted("deleted-id")
+
+	require.NoError(t, m.Check(context.Background()))
+	assert.Equal(t, []string{"web-id"}, tracker.Pending())
+	assert.Equal(t, 1, tracker.Report().Samples)
+
+	running["web-id"] = 1
+	require.NoError(t, m.Check(context.Background()))
+	assert.Empty(t, tracker.Pending())
+	assert.Equal(t, 2, tracker.Report().Samples)
+}
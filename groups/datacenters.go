@@ -0,0 +1,212 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/joyent/triton-service-groups/accounts"
+	"github.com/joyent/triton-service-groups/messages"
+	"github.com/joyent/triton-service-groups/server/handlers"
+)
+
+// ErrMultiDatacenter is returned for operations which act on a single job
+// when the group runs in several datacenters.
+var ErrMultiDatacenter = messages.New(messages.MultiDatacenter)
+
+// ErrDatacenters is returned when acting on the jobs of a multi-datacenter
+// group failed in some of its datacenters. The others are unaffected.
+type ErrDatacenters struct {
+	Errors map[string]error
+}
+
+func (e *ErrDatacenters) Error() string {
+	names := make([]string, 0, len(e.Errors))
+	for name := range e.Errors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	msgs := make([]string, 0, len(names))
+	for _, name := range names {
+		msgs = append(msgs, fmt.Sprintf("%s: %v", name, e.Errors[name]))
+	}
+	return "failed in datacenters " + strings.Join(msgs, "; ")
+}
+
+// isMultiDatacenter returns true if the group sets its capacity per
+// datacenter.
+func (g *ServiceGroup) isMultiDatacenter() bool {
+	return len(g.Datacenters) > 0
+}
+
+// validateDatacenters checks that every datacenter of a multi-datacenter
+// group is either the local datacenter or a configured remote one, and that
+// it doesn't set a canary check, which only runs in the local datacenter.
+func validateDatacenters(ctx context.Context, group *ServiceGroup) error {
+	if group.isMultiDatacenter() && group.Canary != nil {
+		return errors.New("canary checks are not supported for groups with per-datacenter capacity")
+	}
+	for _, name := range sortedDatacenters(group.Datacenters) {
+		if !handlers.KnownDatacenter(ctx, name) {
+			return fmt.Errorf("unknown datacenter: %q", name)
+		}
+	}
+	return nil
+}
+
+// applyDefaultDatacenter places a new group in the account's default
+// datacenter. Datacenters are resolved in order: those set explicitly on the
+// group, then the account default, then the session datacenter.
+func applyDefaultDatacenter(ctx context.Context, group *ServiceGroup, accountDefault string) {
+	if group.isMultiDatacenter() || accountDefault == "" {
+		return
+	}
+	if accountDefault == handlers.GetAuthSession(ctx).Datacenter {
+		return
+	}
+	group.Datacenters = map[string]int{accountDefault: group.Capacity}
+}
+
+// findDefaultDatacenter returns the default datacenter of the account, or an
+// empty string if it has none.
+func findDefaultDatacenter(ctx context.Context, accountID string) (string, error) {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return "", handlers.ErrNoConnPool
+	}
+
+	account, err := accounts.NewStore(db).FindByID(ctx, accountID)
+	if err != nil {
+		return "", err
+	}
+	return account.DefaultDatacenter, nil
+}
+
+// datacenterCapacity returns the capacity of group in each datacenter it runs
+// in. Groups without per-datacenter capacity run in the local datacenter.
+func datacenterCapacity(ctx context.Context, group *ServiceGroup) map[string]int {
+	if group.isMultiDatacenter() {
+		return group.Datacenters
+	}
+	return map[string]int{handlers.GetAuthSession(ctx).Datacenter: group.Capacity}
+}
+
+// withDatacenter returns a copy of ctx which acts on the named datacenter,
+// with the session and Nomad client of a remote datacenter swapped in.
+func withDatacenter(ctx context.Context, name string) (context.Context, error) {
+	session := handlers.GetAuthSession(ctx)
+	if name == session.Datacenter {
+		return ctx, nil
+	}
+
+	dc, ok := handlers.GetDatacenter(ctx, name)
+	if !ok {
+		return nil, fmt.Errorf("unknown datacenter: %q", name)
+	}
+
+	remote := *session
+	remote.Datacenter = name
+	remote.TritonURL = dc.TritonURL
+	if dc.NomadNamespace != "" {
+		remote.NomadNamespace = dc.NomadNamespace
+	}
+	if dc.NomadRegion != "" {
+		remote.NomadRegion = dc.NomadRegion
+	}
+	// The local Nomad datacenters aren't those of the remote datacenter.
+	remote.NomadDatacenters = dc.NomadDatacenters
+
+	ctx = handlers.WithAuthSession(ctx, &remote)
+	return handlers.WithNomadClient(ctx, dc.Nomad), nil
+}
+
+// forEachDatacenter calls fn for each of the given datacenters with a copy of
+// the group holding its capacity in that datacenter. A datacenter which fails
+// doesn't stop the others from being acted on.
+func forEachDatacenter(ctx context.Context, group *ServiceGroup, capacity map[string]int, fn func(ctx context.Context, group *ServiceGroup) error) error {
+	failed := make(map[string]error)
+
+	for _, name := range sortedDatacenters(capacity) {
+		dcCtx, err := withDatacenter(ctx, name)
+		if err == nil {
+			g := *group
+			g.Capacity = capacity[name]
+			g.Datacenters = nil
+			err = fn(dcCtx, &g)
+		}
+		if err != nil {
+			failed[name] = err
+		}
+	}
+
+	if len(failed) > 0 {
+		return &ErrDatacenters{Errors: failed}
+	}
+	return nil
+}
+
+// removedDatacenters returns the datacenters previous ran in which group no
+// longer does, with a capacity of zero.
+func removedDatacenters(ctx context.Context, previous, group *ServiceGroup) map[string]int {
+	current := datacenterCapacity(ctx, group)
+
+	removed := make(map[string]int)
+	for name := range datacenterCapacity(ctx, previous) {
+		if _, ok := current[name]; !ok {
+			removed[name] = 0
+		}
+	}
+	return removed
+}
+
+// DeleteRemovedDatacenterJobs deletes the jobs of a group from every
+// datacenter it ran in before being updated but no longer does.
+func DeleteRemovedDatacenterJobs(ctx context.Context, previous, group *ServiceGroup) error {
+	removed := removedDatacenters(ctx, previous, group)
+	if len(removed) == 0 {
+		return nil
+	}
+	return forEachDatacenter(ctx, group, removed, DeleteOrchestratorJob)
+}
+
+func sortedDatacenters(capacity map[string]int) []string {
+	names := make([]string, 0, len(capacity))
+	for name := range capacity {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func encodeDatacenters(capacity map[string]int) (string, error) {
+	if len(capacity) == 0 {
+		return "", nil
+	}
+
+	bytes, err := json.Marshal(capacity)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+func decodeDatacenters(data string) (map[string]int, error) {
+	if data == "" {
+		return nil, nil
+	}
+
+	var capacity map[string]int
+	if err := json.Unmarshal([]byte(data), &capacity); err != nil {
+		return nil, err
+	}
+	return capacity, nil
+}
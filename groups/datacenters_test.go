@@ -0,0 +1,256 @@
+package groups_v1
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDatacentersContext(t *testing.T) (context.Context, *nomad.Client, *nomad.Client) {
+	local, err := nomad.NewClient(nomad.DefaultConfig())
+	require.NoError(t, err)
+	remote, err := nomad.NewClient(&nomad.Config{Address: "http://10.0.0.5:4646"})
+	require.NoError(t, err)
+
+	ctx := handlers.WithAuthSession(context.Background(), &auth.Session{
+		AccountID:  "6d6f3c38-2ed5-4b7a-a0ad-8a2caa32bbb4",
+		Datacenter: "us-east-1",
+		TritonURL:  "https://us-east-1.api.joyent.com",
+	})
+	ctx = handlers.WithNomadClient(ctx, local)
+	ctx = handlers.WithDatacenters(ctx, handlers.Datacenters{
+		"us-west-1": {
+			TritonURL: "https://us-west-1.api.joyent.com",
+			Nomad:     remote,
+		},
+	})
+
+	return ctx, local, remote
+}
+
+func TestForEachDatacenterSubmit(t *testing.T) {
+	ctx, local, remote := testDatacentersContext(t)
+
+	group := &ServiceGroup{
+		ID:          "722d25ed-f32a-4944-9861-8990e204850e",
+		GroupName:   "web",
+		Capacity:    5,
+		Datacenters: map[string]int{"us-east-1": 2, "us-west-1": 3},
+	}
+	tmpl := &templates_v1.InstanceTemplate{
+		ID:       "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+		Package:  "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+		ImageID:  "342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+		Networks: []string{"0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0"},
+	}
+
+	type submitted struct {
+		tritonURL string
+		client    *nomad.Client
+		job       *nomad.Job
+	}
+	jobs := map[string]submitted{}
+
+	err := forEachDatacenter(ctx, group, group.Datacenters, func(ctx context.Context, g *ServiceGroup) error {
+		assert.False(t, g.isMultiDatacenter())
+
+		session := handlers.GetAuthSession(ctx)
+		client, _ := handlers.GetNomadClient(ctx)
+
+		details, err := createJobDetails(tmpl, g)
+		require.NoError(t, err)
+		details.JobName = jobName(g.GroupName, "c2e4d1491ce423e3")
+		details.Datacenters = jobDatacenters(session)
+		job, err := buildJob(details)
+		require.NoError(t, err)
+
+		jobs[session.Datacenter] = submitted{session.TritonURL, client, job}
+		return nil
+	})
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+
+	east := jobs["us-east-1"]
+	assert.Equal(t, "https://us-east-1.api.joyent.com", east.tritonURL)
+	assert.Equal(t, local, east.client)
+	assert.Equal(t, []string{"us-east-1"}, east.job.Datacenters)
+	assert.Contains(t, east.job.TaskGroups[0].Tasks[0].Config["args"], "2")
+
+	west := jobs["us-west-1"]
+	assert.Equal(t, "https://us-west-1.api.joyent.com", west.tritonURL)
+	assert.Equal(t, remote, west.client)
+	assert.Equal(t, []string{"us-west-1"}, west.job.Datacenters)
+	assert.Contains(t, west.job.TaskGroups[0].Tasks[0].Config["args"], "3")
+
+	assert.Equal(t, *east.job.ID, *west.job.ID)
+
+	// The request's own session is left untouched.
+	assert.Equal(t, "us-east-1", handlers.GetAuthSession(ctx).Datacenter)
+	assert.Equal(t, 5, group.Capacity)
+}
+
+func TestForEachDatacenterPartialFailure(t *testing.T) {
+	ctx, _, _ := testDatacentersContext(t)
+
+	group := &ServiceGroup{
+		GroupName:   "web",
+		Datacenters: map[string]int{"us-east-1": 2, "us-west-1": 3, "eu-ams-1": 1},
+	}
+
+	var called []string
+	err := forEachDatacenter(ctx, group, group.Datacenters, func(ctx context.Context, g *ServiceGroup) error {
+		datacenter := handlers.GetAuthSession(ctx).Datacenter
+		called = append(called, datacenter)
+		if datacenter == "us-west-1" {
+			return errors.New("Unable to register job with Nomad: connection refused")
+		}
+		return nil
+	})
+
+	// The unavailable datacenter doesn't stop the others.
+	assert.Equal(t, []string{"us-east-1", "us-west-1"}, called)
+
+	errs, ok := err.(*ErrDatacenters)
+	require.True(t, ok)
+	assert.Len(t, errs.Errors, 2)
+	assert.EqualError(t, err, `failed in datacenters eu-ams-1: unknown datacenter: "eu-ams-1"; `+
+		`us-west-1: Unable to register job with Nomad: connection refused`)
+	assert.Equal(t, http.StatusBadGateway, orchestratorErrorStatus(err))
+}
+
+func TestGetDatacentersStatus(t *testing.T) {
+	ctx, _, _ := testDatacentersContext(t)
+
+	defer func(f func(ctx context.Context, group *ServiceGroup) (*GroupStatus, error)) {
+		getOrchestratorStatus = f
+	}(getOrchestratorStatus)
+	getOrchestratorStatus = func(ctx context.Context, group *ServiceGroup) (*GroupStatus, error) {
+		if handlers.GetAuthSession(ctx).Datacenter == "us-west-1" {
+			return nil, errors.New("Unable to list job evaluations with Nomad: connection refused")
+		}
+		return &GroupStatus{
+			GroupID:  group.ID,
+			JobID:    "web_c2e4d1491ce423e3",
+			Capacity: group.Capacity,
+			PlacementFailures: []*PlacementFailure{
+				{TaskGroup: "scale", Reasons: []string{"insufficient memory (1 node)"}},
+			},
+		}, nil
+	}
+
+	group := &ServiceGroup{
+		ID:          "722d25ed-f32a-4944-9861-8990e204850e",
+		GroupName:   "web",
+		Capacity:    5,
+		Datacenters: map[string]int{"us-east-1": 2, "us-west-1": 3},
+	}
+
+	status, err := GetOrchestratorStatus(ctx, group)
+	require.NoError(t, err)
+
+	assert.Equal(t, "web_c2e4d1491ce423e3", status.JobID)
+	assert.Equal(t, 5, status.Capacity)
+	require.Len(t, status.PlacementFailures, 1)
+	assert.Equal(t, "us-east-1", status.PlacementFailures[0].Datacenter)
+
+	require.Len(t, status.Datacenters, 2)
+	assert.Equal(t, 2, status.Datacenters["us-east-1"].Capacity)
+	assert.Empty(t, status.Datacenters["us-east-1"].Error)
+	assert.Equal(t, 3, status.Datacenters["us-west-1"].Capacity)
+	assert.Equal(t, "Unable to list job evaluations with Nomad: connection refused", status.Datacenters["us-west-1"].Error)
+	assert.Empty(t, status.Datacenters["us-west-1"].PlacementFailures)
+}
+
+func TestRemovedDatacenters(t *testing.T) {
+	ctx, _, _ := testDatacentersContext(t)
+
+	single := &ServiceGroup{Capacity: 2}
+	multi := &ServiceGroup{Datacenters: map[string]int{"us-west-1": 3}}
+	both := &ServiceGroup{Datacenters: map[string]int{"us-east-1": 2, "us-west-1": 3}}
+
+	assert.Equal(t, map[string]int{"us-east-1": 0}, removedDatacenters(ctx, single, multi))
+	assert.Equal(t, map[string]int{"us-west-1": 0}, removedDatacenters(ctx, multi, single))
+	assert.Empty(t, removedDatacenters(ctx, single, both))
+	assert.Equal(t, map[string]int{"us-east-1": 0}, removedDatacenters(ctx, both, multi))
+}
+
+func TestValidateDatacenters(t *testing.T) {
+	ctx, _, _ := testDatacentersContext(t)
+
+	assert.NoError(t, validateDatacenters(ctx, &ServiceGroup{}))
+	assert.NoError(t, validateDatacenters(ctx, &ServiceGroup{
+		Datacenters: map[string]int{"us-east-1": 2, "us-west-1": 3},
+	}))
+	assert.EqualError(t, validateDatacenters(ctx, &ServiceGroup{
+		Datacenters: map[string]int{"us-east-1": 2, "eu-ams-1": 1},
+	}), `unknown datacenter: "eu-ams-1"`)
+}
+
+func TestDecodeGroupDatacenters(t *testing.T) {
+	group, err := decodeGroupResponseBodyAndValidate([]byte(`{
+		"group_name": "web",
+		"template_id": "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+		"capacity": 1,
+		"datacenters": {"us-east-1": 2, "us-west-1": 3}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, 5, group.Capacity)
+
+	_, err = decodeGroupResponseBodyAndValidate([]byte(`{
+		"template_id": "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+		"datacenters": {"us-east-1": -1}
+	}`))
+	assert.EqualError(t, err, "datacenter capacity cannot be a negative number")
+
+	data, err := encodeDatacenters(map[string]int{"us-west-1": 3, "us-east-1": 2})
+	require.NoError(t, err)
+	assert.Equal(t, `{"us-east-1":2,"us-west-1":3}`, data)
+
+	capacity, err := decodeDatacenters(data)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"us-east-1": 2, "us-west-1": 3}, capacity)
+
+	capacity, err = decodeDatacenters("")
+	require.NoError(t, err)
+	assert.Nil(t, capacity)
+}
+
+func TestApplyDefaultDatacenter(t *testing.T) {
+	ctx, _, _ := testDatacentersContext(t)
+
+	// Explicit datacenters take precedence over the account default.
+	explicit := &ServiceGroup{Capacity: 5, Datacenters: map[string]int{"us-east-1": 2, "us-west-1": 3}}
+	applyDefaultDatacenter(ctx, explicit, "us-west-1")
+	assert.Equal(t, map[string]int{"us-east-1": 2, "us-west-1": 3}, explicit.Datacenters)
+
+	// Then the account default.
+	remote := &ServiceGroup{Capacity: 3}
+	applyDefaultDatacenter(ctx, remote, "us-west-1")
+	assert.Equal(t, map[string]int{"us-west-1": 3}, remote.Datacenters)
+	assert.Equal(t, 3, remote.Capacity)
+	assert.NoError(t, validateDatacenters(ctx, remote))
+
+	// A default of the session datacenter leaves the group local.
+	local := &ServiceGroup{Capacity: 3}
+	applyDefaultDatacenter(ctx, local, "us-east-1")
+	assert.False(t, local.isMultiDatacenter())
+
+	// Then the session datacenter.
+	session := &ServiceGroup{Capacity: 3}
+	applyDefaultDatacenter(ctx, session, "")
+	assert.False(t, session.isMultiDatacenter())
+	assert.Equal(t, map[string]int{"us-east-1": 3}, datacenterCapacity(ctx, session))
+
+	// A default which is no longer configured is rejected.
+	stale := &ServiceGroup{Capacity: 3}
+	applyDefaultDatacenter(ctx, stale, "eu-ams-1")
+	assert.EqualError(t, validateDatacenters(ctx, stale), `unknown datacenter: "eu-ams-1"`)
+}
@@ -0,0 +1,311 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/jackc/pgx"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	DriftPolicyOff       = "off"
+	DriftPolicyAlert     = "alert"
+	DriftPolicyRemediate = "remediate"
+)
+
+const (
+	// DriftMissing is a group whose job is not registered with Nomad.
+	DriftMissing = "missing"
+	// DriftOrphaned is a Nomad job named for a TSG account with no group, or
+	// the legacy job of a group left beside its current one.
+	DriftOrphaned = "orphaned"
+)
+
+// ManagedGroup is a service group along with the account details required to
+// act on its orchestrator job outside of a request.
+type ManagedGroup struct {
+	*ServiceGroup
+	AccountID  string
+	JobRef     string
+	TritonUUID string
+}
+
+// JobName returns the name of the group's orchestrator job.
+func (g *ManagedGroup) JobName() string {
+	return jobName(g.GroupName, g.JobRef)
+}
+
+// LegacyJobName returns the name the group's orchestrator job had before jobs
+// were named after the account's job reference, see legacyJobName.
+func (g *ManagedGroup) LegacyJobName() string {
+	return legacyJobName(g.GroupName, g.TritonUUID)
+}
+
+// Drift is a single inconsistency between the database and Nomad.
+type Drift struct {
+	Kind      string
+	JobID     string
+	GroupID   string
+	AccountID string
+}
+
+// DriftReport is the outcome of a single drift detection cycle.
+type DriftReport struct {
+	Groups     int
+	Drift      []*Drift
+	Remediated int
+	Skipped    string
+}
+
+// DriftDetector periodically compares the desired state of every group
+// against the jobs registered with Nomad.
+type DriftDetector struct {
+	cfg        config.Drift
+	datacenter string
+	tritonURL  string
+	pool       *pgx.ConnPool
+	client     *nomad.Client
+
+	findGroups func(ctx context.Context) ([]*ManagedGroup, error)
+	register   func(ctx context.Context, group *ManagedGroup) error
+}
+
+// NewDriftDetector constructs a drift detector which remediates as the given
+// datacenter and Triton URL.
+func NewDriftDetector(cfg config.Drift, datacenter, tritonURL string, pool *pgx.ConnPool, client *nomad.Client) *DriftDetector {
+	d := &DriftDetector{
+		cfg:        cfg,
+		datacenter: datacenter,
+		tritonURL:  tritonURL,
+		pool:       pool,
+		client:     client,
+		findGroups: FindManagedGroups,
+	}
+	d.register = d.submitJob
+	return d
+}
+
+// Run checks for drift once per interval until ctx is done.
+func (d *DriftDetector) Run(ctx context.Context) {
+	if d.cfg.Policy == DriftPolicyOff {
+		log.Debug().Msg("drift: detection disabled")
+		return
+	}
+
+	ticker := time.NewTicker(d.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := d.Check(ctx); err != nil {
+				log.Error().Err(err).Msg("drift: failed to check for drift")
+			}
+		}
+	}
+}
+
+// Check runs a single drift detection cycle, remediating missing jobs if the
+// policy allows it.
+func (d *DriftDetector) Check(ctx context.Context) (*DriftReport, error) {
+	ctx = handlers.WithNomadClient(handlers.WithDBPool(ctx, d.pool), d.client)
+
+	groups, err := d.findGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	stubs, _, err := d.client.Jobs().List(nomadScopeOf(ctx).queryOptions())
+	if err != nil {
+		return nil, &ErrNomad{Op: ErrNomadListJobs, Err: err}
+	}
+
+	report := &DriftReport{
+		Groups: len(groups),
+		Drift:  detectDrift(groups, stubs),
+	}
+
+	var missing []*Drift
+	for _, drift := range report.Drift {
+		log.Warn().
+			Str("kind", drift.Kind).
+			Str("job_id", drift.JobID).
+			Str("group_id", drift.GroupID).
+			Str("account_id", drift.AccountID).
+			Msg("drift: detected drift between database and nomad")
+
+		if drift.Kind == DriftMissing {
+			missing = append(missing, drift)
+		}
+	}
+
+	metrics.SetGauge([]string{"drift", "missing"}, float32(len(missing)))
+	metrics.SetGauge([]string{"drift", "orphaned"}, float32(len(report.Drift)-len(missing)))
+
+	if len(missing) == 0 || d.cfg.Policy != DriftPolicyRemediate {
+		return report, nil
+	}
+
+	if reason := d.skipRemediation(len(groups), len(missing)); reason != "" {
+		report.Skipped = reason
+		log.Warn().
+			Int("missing", len(missing)).
+			Msgf("drift: skipping remediation, %s", reason)
+		return report, nil
+	}
+
+	byID := make(map[string]*ManagedGroup, len(groups))
+	for _, group := range groups {
+		byID[group.ID] = group
+	}
+
+	// NOTE: Failed attempts count toward the limit so that a struggling Nomad
+	// isn't hit with every missing job at once.
+	var attempts int
+	for i, drift := range missing {
+		if attempts >= d.cfg.MaxRemediations {
+			log.Warn().
+				Int("remaining", len(missing)-i).
+				Msg("drift: reached remediation limit for this cycle")
+			break
+		}
+
+		group := byID[drift.GroupID]
+		if group.Capacity == 0 {
+			continue
+		}
+
+		attempts++
+		if err := d.register(ctx, group); err != nil {
+			log.Error().Err(err).
+				Str("job_id", drift.JobID).
+				Msg("drift: failed to re-register job")
+			continue
+		}
+
+		log.Info().
+			Str("job_id", drift.JobID).
+			Str("group_id", drift.GroupID).
+			Msg("drift: re-registered missing job")
+		metrics.IncrCounter([]string{"drift", "remediated"}, 1)
+		report.Remediated++
+	}
+
+	return report, nil
+}
+
+// skipRemediation returns why remediation should not run this cycle, if at
+// all.
+func (d *DriftDetector) skipRemediation(groups, missing int) string {
+	if d.cfg.Freeze {
+		return "remediation is frozen"
+	}
+
+	healthy := float64(groups-missing) / float64(groups)
+	if healthy < d.cfg.MinHealthy {
+		return fmt.Sprintf("only %.0f%% of groups are healthy", healthy*100)
+	}
+
+	return ""
+}
+
+func (d *DriftDetector) submitJob(ctx context.Context, group *ManagedGroup) error {
+	ctx = handlers.WithAuthSession(ctx, backgroundSession(group.AccountID, d.datacenter, d.tritonURL))
+	_, err := SubmitOrchestratorJob(ctx, group.ServiceGroup)
+	return err
+}
+
+// detectDrift compares groups against the jobs registered with Nomad. Only
+// jobs named for an account which owns at least one group are considered, so
+// jobs unrelated to TSG are never reported. A group whose job still has its
+// legacy name isn't missing, and its legacy job is only orphaned once the
+// group also has a job under its current name.
+func detectDrift(groups []*ManagedGroup, stubs []*nomad.JobListStub) []*Drift {
+	jobs := make(map[string]*nomad.JobListStub, len(stubs))
+	for _, stub := range stubs {
+		if stub.ParentID == "" {
+			jobs[stub.ID] = stub
+		}
+	}
+
+	var drift []*Drift
+
+	running := func(name string) bool {
+		stub, ok := jobs[name]
+		return ok && !stub.Stop
+	}
+
+	expected := make(map[string]bool, len(groups))
+	legacy := make(map[string]*ManagedGroup, len(groups))
+	accountRefs := make(map[string]string, len(groups))
+	for _, group := range groups {
+		name := group.JobName()
+		expected[name] = true
+		accountRefs[group.JobRef] = group.AccountID
+		if group.TritonUUID != "" {
+			legacy[group.LegacyJobName()] = group
+			accountRefs[group.TritonUUID] = group.AccountID
+		}
+
+		// NOTE: Multi-datacenter groups may not run in this datacenter at
+		// all, so only their jobs which do are accounted for.
+		if group.isMultiDatacenter() {
+			continue
+		}
+
+		if !running(name) && !running(group.LegacyJobName()) {
+			drift = append(drift, &Drift{
+				Kind:      DriftMissing,
+				JobID:     name,
+				GroupID:   group.ID,
+				AccountID: group.AccountID,
+			})
+		}
+	}
+
+	for _, stub := range stubs {
+		if stub.ParentID != "" || stub.Stop || expected[stub.ID] {
+			continue
+		}
+
+		if group, ok := legacy[stub.ID]; ok {
+			if running(group.JobName()) {
+				drift = append(drift, &Drift{
+					Kind:      DriftOrphaned,
+					JobID:     stub.ID,
+					GroupID:   group.ID,
+					AccountID: group.AccountID,
+				})
+			}
+			continue
+		}
+
+		_, ref, ok := parseJobName(stub.ID)
+		if !ok {
+			continue
+		}
+
+		if accountID, ok := accountRefs[ref]; ok {
+			drift = append(drift, &Drift{
+				Kind:      DriftOrphaned,
+				JobID:     stub.ID,
+				AccountID: accountID,
+			})
+		}
+	}
+
+	return drift
+}
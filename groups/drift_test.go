@@ -0,0 +1,180 @@
+package groups_v1
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testJobRef = "c2e4d1491ce423e3"
+
+func testManagedGroups(names ...string) []*ManagedGroup {
+	var groups []*ManagedGroup
+	for i, name := range names {
+		groups = append(groups, &ManagedGroup{
+			ServiceGroup: &ServiceGroup{
+				ID:        name + "-id",
+				GroupName: name,
+				Capacity:  i + 1,
+			},
+			AccountID: "6d6f3c38-2ed5-4b7a-a0ad-8a2caa32bbb4",
+			JobRef:    testJobRef,
+		})
+	}
+	return groups
+}
+
+func TestDetectDrift(t *testing.T) {
+	groups := testManagedGroups("running", "missing", "stopped")
+
+	stubs := []*nomad.JobListStub{
+		{ID: jobName("running", testJobRef)},
+		{ID: jobName("running", testJobRef) + "/periodic-1525209600", ParentID: jobName("running", testJobRef)},
+		{ID: jobName("stopped", testJobRef), Stop: true},
+		{ID: jobName("leftover", testJobRef)},
+		{ID: jobName("other-account", "0123456789abcdef")},
+		{ID: "not-tsg"},
+	}
+
+	drift := detectDrift(groups, stubs)
+	require.Len(t, drift, 3)
+
+	assert.Equal(t, DriftMissing, drift[0].Kind)
+	assert.Equal(t, "missing-id", drift[0].GroupID)
+	assert.Equal(t, DriftMissing, drift[1].Kind)
+	assert.Equal(t, "stopped-id", drift[1].GroupID)
+	assert.Equal(t, DriftOrphaned, drift[2].Kind)
+	assert.Equal(t, jobName("leftover", testJobRef), drift[2].JobID)
+}
+
+func TestDetectDriftLegacyJobs(t *testing.T) {
+	const tritonUUID = "87307a00-ab96-4fec-8df7-1a256e49fbcc"
+
+	groups := testManagedGroups("upgraded", "legacy", "missing")
+	for _, group := range groups {
+		group.TritonUUID = tritonUUID
+	}
+
+	// Groups created before jobs were named after the account's job
+	// reference have their jobs named after the account's Triton UUID.
+	stubs := []*nomad.JobListStub{
+		{ID: jobName("upgraded", testJobRef)},
+		{ID: legacyJobName("upgraded", tritonUUID)},
+		{ID: legacyJobName("legacy", tritonUUID)},
+		{ID: legacyJobName("missing", tritonUUID), Stop: true},
+		{ID: legacyJobName("deleted", tritonUUID)},
+	}
+
+	drift := detectDrift(groups, stubs)
+	require.Len(t, drift, 3)
+
+	assert.Equal(t, DriftMissing, drift[0].Kind)
+	assert.Equal(t, "missing-id", drift[0].GroupID)
+	assert.Equal(t, DriftOrphaned, drift[1].Kind)
+	assert.Equal(t, legacyJobName("upgraded", tritonUUID), drift[1].JobID)
+	assert.Equal(t, "upgraded-id", drift[1].GroupID)
+	assert.Equal(t, DriftOrphaned, drift[2].Kind)
+	assert.Equal(t, legacyJobName("deleted", tritonUUID), drift[2].JobID)
+	assert.Equal(t, groups[0].AccountID, drift[2].AccountID)
+}
+
+func TestDriftDetectorRemediation(t *testing.T) {
+	groups := testManagedGroups("healthy-1", "healthy-2", "healthy-3", "healthy-4", "lost-1", "lost-2", "lost-3")
+
+	fake := testutils.NewFakeNomad(t)
+	defer fake.Close()
+
+	var stubs []*nomad.JobListStub
+	for _, group := range groups[:4] {
+		stubs = append(stubs, &nomad.JobListStub{ID: group.JobName()})
+	}
+	fake.HandleJSON("/v1/jobs", stubs)
+
+	newDetector := func(cfg config.Drift) (*DriftDetector, *[]string) {
+		var registered []string
+
+		d := NewDriftDetector(cfg, "us-east-1", "https://us-east-1.api.joyent.com", nil, fake.Client)
+		d.findGroups = func(context.Context) ([]*ManagedGroup, error) {
+			return groups, nil
+		}
+		d.register = func(_ context.Context, group *ManagedGroup) error {
+			registered = append(registered, group.GroupName)
+			return nil
+		}
+		return d, &registered
+	}
+
+	cfg := config.Drift{
+		Policy:          DriftPolicyRemediate,
+		Interval:        time.Minute,
+		MaxRemediations: 2,
+		MinHealthy:      0.5,
+	}
+
+	t.Run("bounded", func(t *testing.T) {
+		d, registered := newDetector(cfg)
+
+		report, err := d.Check(context.Background())
+		require.NoError(t, err)
+		assert.Len(t, report.Drift, 3)
+		assert.Equal(t, 2, report.Remediated)
+		assert.Equal(t, []string{"lost-1", "lost-2"}, *registered)
+	})
+
+	t.Run("alert only", func(t *testing.T) {
+		alert := cfg
+		alert.Policy = DriftPolicyAlert
+		d, registered := newDetector(alert)
+
+		report, err := d.Check(context.Background())
+		require.NoError(t, err)
+		assert.Len(t, report.Drift, 3)
+		assert.Zero(t, report.Remediated)
+		assert.Empty(t, *registered)
+	})
+
+	t.Run("frozen", func(t *testing.T) {
+		frozen := cfg
+		frozen.Freeze = true
+		d, registered := newDetector(frozen)
+
+		report, err := d.Check(context.Background())
+		require.NoError(t, err)
+		assert.NotEmpty(t, report.Skipped)
+		assert.Empty(t, *registered)
+	})
+
+	t.Run("below min healthy", func(t *testing.T) {
+		floor := cfg
+		floor.MinHealthy = 0.75
+		d, registered := newDetector(floor)
+
+		report, err := d.Check(context.Background())
+		require.NoError(t, err)
+		assert.NotEmpty(t, report.Skipped)
+		assert.Empty(t, *registered)
+	})
+
+	t.Run("failures count toward limit", func(t *testing.T) {
+		d, registered := newDetector(cfg)
+		register := d.register
+		d.register = func(ctx context.Context, group *ManagedGroup) error {
+			if group.GroupName == "lost-1" {
+				return errors.New("nomad unavailable")
+			}
+			return register(ctx, group)
+		}
+
+		report, err := d.Check(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, report.Remediated)
+		assert.Equal(t, []string{"lost-2"}, *registered)
+	})
+}
@@ -0,0 +1,120 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/joyent/triton-service-groups/server/handlers"
+)
+
+// The calls to Nomad which an ErrNomad reports as failed, to be matched with
+// errors.Is.
+var (
+	ErrNomadJobInfo       = errors.New("Unable to find job with Nomad")
+	ErrNomadListJobs      = errors.New("Unable to list jobs with Nomad")
+	ErrNomadChildJobs     = errors.New("Unable to list child jobs with Nomad")
+	ErrNomadAllocations   = errors.New("Unable to list job allocations with Nomad")
+	ErrNomadEvaluations   = errors.New("Unable to list job evaluations with Nomad")
+	ErrNomadValidate      = errors.New("Failed to validate Nomad Job")
+	ErrNomadPlan          = errors.New("Unable to plan job with Nomad")
+	ErrNomadRegister      = errors.New("Unable to register job with Nomad")
+	ErrNomadPeriodicForce = errors.New("Unable to trigger a periodic instance of job")
+	ErrNomadDeregister    = errors.New("Unable to deregister job with Nomad")
+)
+
+// ErrNomad is returned when a call to Nomad fails. Op is the call, one of the
+// ErrNomad sentinels above, and Err is why it failed.
+type ErrNomad struct {
+	Op  error
+	Err error
+}
+
+func (e *ErrNomad) Error() string {
+	return fmt.Sprintf("%v: %v", e.Op, e.Err)
+}
+
+func (e *ErrNomad) Unwrap() error {
+	return e.Err
+}
+
+// Is matches the sentinel of the call which failed.
+func (e *ErrNomad) Is(target error) bool {
+	return target == e.Op
+}
+
+// isNomadFailure returns true if err, or an error it wraps, is an ErrNomad.
+func isNomadFailure(err error) bool {
+	var nomadErr *ErrNomad
+	return errors.As(err, &nomadErr)
+}
+
+// isNomadUnavailable returns true if err, or an error it wraps, is because
+// the request has no Nomad client to call, which is the server's failure
+// rather than Nomad's.
+func isNomadUnavailable(err error) bool {
+	return errors.Is(err, handlers.ErrNoNomadClient)
+}
+
+// ErrTemplateNotFound is returned when the template of a group doesn't exist
+// for the account of the session.
+type ErrTemplateNotFound struct {
+	TemplateID string
+}
+
+func (e *ErrTemplateNotFound) Error() string {
+	return "Error finding template by ID"
+}
+
+// ErrInvalidCapacity is returned when a group's job would run with a negative
+// capacity, or more than Max instances allowed for the group's account.
+type ErrInvalidCapacity struct {
+	Capacity int
+	Max      int
+}
+
+func (e *ErrInvalidCapacity) Error() string {
+	if e.Capacity < 0 {
+		return "group capacity cannot be a negative number"
+	}
+	return fmt.Sprintf("group capacity cannot be more than %d compute instances", e.Max)
+}
+
+// ErrInvalidTritonID is returned when a template's package or image isn't a
+// Triton UUID, which tsg-cli would otherwise only fail on once it runs.
+type ErrInvalidTritonID struct {
+	Field string
+	Value string
+}
+
+func (e *ErrInvalidTritonID) Error() string {
+	return fmt.Sprintf("%s %q is not a valid Triton UUID", e.Field, e.Value)
+}
+
+// ErrJobRender is returned when the job of a group can't be rendered from its
+// template, or the rendered job can't be parsed.
+type ErrJobRender struct {
+	Err error
+}
+
+func (e *ErrJobRender) Error() string {
+	return fmt.Sprintf("Unable to render job: %v", e.Err)
+}
+
+func (e *ErrJobRender) Unwrap() error {
+	return e.Err
+}
+
+// ErrJobNameCollision is returned when a Nomad job name belongs to the groups
+// of several accounts, whose job references collide.
+type ErrJobNameCollision struct {
+	JobName string
+}
+
+func (e *ErrJobNameCollision) Error() string {
+	return fmt.Sprintf("job %q belongs to the groups of several accounts", e.JobName)
+}
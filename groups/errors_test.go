@@ -0,0 +1,33 @@
+package groups_v1
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrNomad(t *testing.T) {
+	cause := errors.New("connection refused")
+	err := error(&ErrNomad{Op: ErrNomadRegister, Err: cause})
+
+	assert.EqualError(t, err, "Unable to register job with Nomad: connection refused")
+	assert.True(t, errors.Is(err, ErrNomadRegister))
+	assert.False(t, errors.Is(err, ErrNomadValidate))
+	assert.True(t, errors.Is(err, cause))
+	assert.True(t, errors.Is(&ErrJobUpdate{Err: err}, ErrNomadRegister))
+}
+
+func TestOrchestratorErrorStatusTyped(t *testing.T) {
+	for status, err := range map[int]error{
+		http.StatusNotFound:            &ErrTemplateNotFound{TemplateID: "abc"},
+		http.StatusBadGateway:          &ErrNomad{Op: ErrNomadValidate, Err: errors.New("no leader")},
+		http.StatusInternalServerError: &ErrJobRender{Err: errors.New("unexpected token")},
+		http.StatusServiceUnavailable:  &ErrJobUpdate{Err: handlers.ErrNoNomadClient},
+	} {
+		assert.Equal(t, status, orchestratorErrorStatus(err), "%v", err)
+	}
+	assert.Equal(t, http.StatusServiceUnavailable, orchestratorErrorStatus(handlers.ErrNoNomadClient))
+}
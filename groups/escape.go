@@ -0,0 +1,111 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/joyent/triton-service-groups/templates"
+)
+
+// ErrUnsafeJobValue is returned when a value of a group or its template can't
+// be written into the group's job without changing its meaning.
+type ErrUnsafeJobValue struct {
+	Field  string
+	Value  string
+	Reason string
+}
+
+func (e *ErrUnsafeJobValue) Error() string {
+	return fmt.Sprintf("%s %q can't be used in a job: %s", e.Field, e.Value, e.Reason)
+}
+
+// hclString escapes s to be placed between the quotes of an HCL string
+// literal. Quotes, backslashes, newlines and other control characters are
+// escaped the way Go quotes strings, which HCL unquotes in the same way.
+func hclString(s string) string {
+	quoted := strconv.Quote(s)
+	return quoted[1 : len(quoted)-1]
+}
+
+// checkJobValue returns an error if value can't be escaped by hclString. HCL
+// reads everything within "${" and "}" as an interpolation, quotes included,
+// and Nomad interpolates it again when running the task. Keys, such as
+// those of tags, are passed to tsg-cli as "key=value" and can't contain "=".
+func checkJobValue(field, value string, key bool) error {
+	if strings.Contains(value, "${") {
+		return &ErrUnsafeJobValue{Field: field, Value: value, Reason: `must not contain "${"`}
+	}
+	if key && strings.Contains(value, "=") {
+		return &ErrUnsafeJobValue{Field: field, Value: value, Reason: `must not contain "="`}
+	}
+	return nil
+}
+
+// checkJobValues returns the first value of the group or its template which
+// checkJobValue rejects. Base64 encoded values, such as user data and
+// metadata values, are always safe.
+func checkJobValues(template *templates_v1.InstanceTemplate, group *ServiceGroup) error {
+	if err := checkJobValue("group name", group.GroupName, false); err != nil {
+		return err
+	}
+	if err := checkJobValue("package", template.Package, false); err != nil {
+		return err
+	}
+	if err := checkJobValue("image", template.ImageID, false); err != nil {
+		return err
+	}
+	if err := validateTSGCliVersion(group.TSGCliVersion); err != nil {
+		return err
+	}
+	if template.TaskCPU < 0 {
+		return &ErrUnsafeJobValue{Field: "task cpu", Value: strconv.Itoa(template.TaskCPU), Reason: "must be a positive integer"}
+	}
+	if template.TaskMemoryMB < 0 {
+		return &ErrUnsafeJobValue{Field: "task memory", Value: strconv.Itoa(template.TaskMemoryMB), Reason: "must be a positive integer"}
+	}
+	for _, network := range template.Networks {
+		if err := checkJobValue("network", network, false); err != nil {
+			return err
+		}
+	}
+	for _, rule := range template.FirewallRules {
+		if strings.TrimSpace(rule) == "" {
+			return &ErrUnsafeJobValue{Field: "firewall rule", Value: rule, Reason: "must not be empty"}
+		}
+		if err := checkJobValue("firewall rule", rule, false); err != nil {
+			return err
+		}
+	}
+
+	for _, key := range sortedKeys(template.Tags) {
+		if err := checkJobValue("tag key", key, true); err != nil {
+			return err
+		}
+		if err := checkJobValue("tag value", template.Tags[key], false); err != nil {
+			return err
+		}
+	}
+	for _, key := range sortedKeys(template.MetaData) {
+		if err := checkJobValue("metadata key", key, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,140 @@
+package groups_v1
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/nomad/jobspec"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHCLString(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+	}{
+		{"", ""},
+		{"web", "web"},
+		{`say "hi"`, `say \"hi\"`},
+		{`C:\temp`, `C:\\temp`},
+		{"one\ntwo\r\n", `one\ntwo\r\n`},
+		{"\t\x00", `\t\x00`},
+		{"héllo", "héllo"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, hclString(tt.value), "value %q", tt.value)
+	}
+}
+
+func TestRenderAdversarialValues(t *testing.T) {
+	tmpl := &templates_v1.InstanceTemplate{
+		ID:       "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+		Package:  "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+		ImageID:  "342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+		Networks: []string{`net\"]`},
+		Tags: map[string]string{
+			`role"`: "web\"\n\"--evil\\",
+		},
+	}
+	group := &ServiceGroup{GroupName: `web", "--count", "100`, Capacity: 2}
+
+	details, err := createJobDetails(tmpl, group)
+	require.NoError(t, err)
+	details.JobName = jobName("web", "c2e4d1491ce423e3")
+	details.Datacenters = []string{"us-sw-1"}
+
+	spec, err := renderJobSpec(details)
+	require.NoError(t, err)
+
+	job, err := jobspec.Parse(strings.NewReader(spec))
+	require.NoError(t, err)
+
+	var args []string
+	for _, arg := range job.TaskGroups[0].Tasks[0].Config["args"].([]interface{}) {
+		args = append(args, arg.(string))
+	}
+
+	// Every value arrives as a single argument, exactly as it was given.
+	assert.NotContains(t, args, "--evil")
+	assert.Equal(t, []string{"--count"}, filterArgs(args, "--count"))
+	assert.Equal(t, "2", argValue(args, "--count"))
+	assert.Equal(t, group.GroupName, argValue(args, "--tsg-name"))
+	assert.Equal(t, tmpl.Package, argValue(args, "--pkg-id"))
+	assert.Equal(t, tmpl.Networks[0], argValue(args, "--networks"))
+	assert.Equal(t, "role\"=web\"\n\"--evil\\", argValue(args, "--tag"))
+
+	// Packages and images are UUIDs, so never get as far as rendering.
+	bad := *tmpl
+	bad.Package = `g4"], "--evil", "`
+	_, err = createJobDetails(&bad, group)
+	assert.EqualError(t, err, `package "g4\"], \"--evil\", \"" is not a valid Triton UUID`)
+}
+
+func TestCheckJobValues(t *testing.T) {
+	tmpl := func() *templates_v1.InstanceTemplate {
+		return &templates_v1.InstanceTemplate{
+			Package:  "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+			ImageID:  "342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+			Tags:     map[string]string{"role": "web=frontend"},
+			MetaData: map[string]string{"user-script": "echo ${HOME}"},
+		}
+	}
+	group := &ServiceGroup{GroupName: "web"}
+
+	assert.NoError(t, checkJobValues(tmpl(), group))
+
+	t.Run("interpolation", func(t *testing.T) {
+		bad := tmpl()
+		bad.Tags["role"] = "${attr.unique.hostname}"
+		err := checkJobValues(bad, group)
+		assert.EqualError(t, err, `tag value "${attr.unique.hostname}" can't be used in a job: must not contain "${"`)
+		assert.Equal(t, http.StatusUnprocessableEntity, orchestratorErrorStatus(err))
+
+		_, err = createJobDetails(tmpl(), &ServiceGroup{GroupName: "web-${NOMAD_JOB_NAME}"})
+		assert.EqualError(t, err, `group name "web-${NOMAD_JOB_NAME}" can't be used in a job: must not contain "${"`)
+	})
+
+	t.Run("keys", func(t *testing.T) {
+		bad := tmpl()
+		bad.Tags["role=db"] = "web"
+		assert.EqualError(t, checkJobValues(bad, group), `tag key "role=db" can't be used in a job: must not contain "="`)
+
+		bad = tmpl()
+		bad.MetaData["user=script"] = "echo"
+		assert.EqualError(t, checkJobValues(bad, group), `metadata key "user=script" can't be used in a job: must not contain "="`)
+	})
+
+	t.Run("firewall rules", func(t *testing.T) {
+		bad := tmpl()
+		bad.FirewallRules = []string{"FROM any TO all vms ALLOW tcp PORT 80", " "}
+		err := checkJobValues(bad, group)
+		assert.EqualError(t, err, `firewall rule " " can't be used in a job: must not be empty`)
+		assert.Equal(t, http.StatusUnprocessableEntity, orchestratorErrorStatus(err))
+
+		bad.FirewallRules = []string{"FROM any TO tag \"${role}\" ALLOW tcp PORT 80"}
+		assert.EqualError(t, checkJobValues(bad, group), `firewall rule "FROM any TO tag \"${role}\" ALLOW tcp PORT 80" can't be used in a job: must not contain "${"`)
+	})
+}
+
+func filterArgs(args []string, name string) []string {
+	var found []string
+	for _, arg := range args {
+		if arg == name {
+			found = append(found, arg)
+		}
+	}
+	return found
+}
+
+func argValue(args []string, name string) string {
+	for i, arg := range args {
+		if arg == name && i+1 < len(args) {
+			return args[i+1]
+		}
+	}
+	return ""
+}
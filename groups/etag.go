@@ -0,0 +1,51 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// ETag returns an entity tag derived from the current state of the group. It
+// changes on every mutation since each one also bumps UpdatedAt.
+func (g *ServiceGroup) ETag() string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%d\x00%d",
+		g.ID,
+		g.GroupName,
+		g.TemplateID,
+		g.Capacity,
+		g.UpdatedAt.UnixNano(),
+	)
+	return `"` + hex.EncodeToString(h.Sum(nil))[:32] + `"`
+}
+
+// ifMatch reports whether the If-Match precondition of the request, if any,
+// is satisfied by the group's current state.
+func ifMatch(r *http.Request, group *ServiceGroup) bool {
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		return true
+	}
+
+	etag := group.ETag()
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+func writeETag(w http.ResponseWriter, group *ServiceGroup) {
+	w.Header().Set("ETag", group.ETag())
+}
@@ -0,0 +1,44 @@
+package groups_v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIfMatch(t *testing.T) {
+	group := &ServiceGroup{
+		ID:         "722d25ed-f32a-4944-9861-8990e204850e",
+		GroupName:  "jolly-jelly",
+		TemplateID: "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+		Capacity:   2,
+		UpdatedAt:  time.Date(2018, 4, 14, 16, 26, 39, 0, time.UTC),
+	}
+	etag := group.ETag()
+
+	request := func(ifMatch string) *http.Request {
+		r := httptest.NewRequest(http.MethodPut, "/v1/tsg/groups/"+group.ID, nil)
+		if ifMatch != "" {
+			r.Header.Set("If-Match", ifMatch)
+		}
+		return r
+	}
+
+	assert.True(t, ifMatch(request(""), group), "no precondition")
+	assert.True(t, ifMatch(request(etag), group), "matching etag")
+	assert.True(t, ifMatch(request(`"stale", `+etag), group), "matching etag in list")
+	assert.True(t, ifMatch(request("*"), group), "wildcard")
+
+	// a capacity change alone produces a new etag
+	stale := etag
+	group.Capacity = 3
+	assert.NotEqual(t, stale, group.ETag())
+	assert.False(t, ifMatch(request(stale), group), "stale etag")
+
+	stale = group.ETag()
+	group.UpdatedAt = group.UpdatedAt.Add(time.Microsecond)
+	assert.False(t, ifMatch(request(stale), group), "stale etag after update")
+}
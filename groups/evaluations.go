@@ -0,0 +1,136 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/joyent/triton-service-groups/server/handlers"
+)
+
+const (
+	defaultEvaluationLimit = 25
+	maxEvaluationLimit     = 100
+)
+
+// JobEvaluation is a single Nomad evaluation of a service group's job or one
+// of its periodic runs.
+type JobEvaluation struct {
+	ID                string     `json:"id"`
+	JobID             string     `json:"job_id"`
+	Status            string     `json:"status"`
+	StatusDescription string     `json:"status_description,omitempty"`
+	TriggeredBy       string     `json:"triggered_by"`
+	LaunchedAt        *time.Time `json:"launched_at,omitempty"`
+	CreateIndex       uint64     `json:"create_index"`
+	ModifyIndex       uint64     `json:"modify_index"`
+}
+
+// EvaluationPage is a page of evaluation history, newest first.
+type EvaluationPage struct {
+	Total       int              `json:"total"`
+	Limit       int              `json:"limit"`
+	Offset      int              `json:"offset"`
+	Evaluations []*JobEvaluation `json:"evaluations"`
+}
+
+// ListOrchestratorEvaluations returns the evaluation history for a service
+// group's job, including every periodic run Nomad still knows about.
+func ListOrchestratorEvaluations(ctx context.Context, group *ServiceGroup, limit, offset int) (*EvaluationPage, error) {
+	name, err := resolveJobName(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	client, ok := handlers.GetNomadClient(ctx)
+	if !ok {
+		return nil, handlers.ErrNoNomadClient
+	}
+
+	return listEvaluations(client, nomadScopeOf(ctx), name, limit, offset)
+}
+
+func listEvaluations(client *nomad.Client, scope nomadScope, jobID string, limit, offset int) (*EvaluationPage, error) {
+	if limit <= 0 {
+		limit = defaultEvaluationLimit
+	}
+	if limit > maxEvaluationLimit {
+		limit = maxEvaluationLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	jobIDs, err := jobFamilyIDs(client, scope, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	var evals []*nomad.Evaluation
+	for _, id := range jobIDs {
+		jobEvals, _, err := client.Jobs().Evaluations(id, scope.queryOptions())
+		if err != nil {
+			return nil, &ErrNomad{Op: ErrNomadEvaluations, Err: err}
+		}
+		evals = append(evals, jobEvals...)
+	}
+
+	sort.Sort(nomad.EvalIndexSort(evals))
+
+	page := &EvaluationPage{
+		Total:       len(evals),
+		Limit:       limit,
+		Offset:      offset,
+		Evaluations: []*JobEvaluation{},
+	}
+
+	if offset >= len(evals) {
+		return page, nil
+	}
+
+	end := offset + limit
+	if end > len(evals) {
+		end = len(evals)
+	}
+
+	for _, eval := range evals[offset:end] {
+		page.Evaluations = append(page.Evaluations, &JobEvaluation{
+			ID:                eval.ID,
+			JobID:             eval.JobID,
+			Status:            eval.Status,
+			StatusDescription: eval.StatusDescription,
+			TriggeredBy:       eval.TriggeredBy,
+			LaunchedAt:        periodicLaunchTime(eval.JobID),
+			CreateIndex:       eval.CreateIndex,
+			ModifyIndex:       eval.ModifyIndex,
+		})
+	}
+
+	return page, nil
+}
+
+// periodicLaunchTime parses the launch time Nomad encodes into the ID of a
+// periodic child job (e.g. "name/periodic-1525209600"). Returns nil for any
+// other job ID.
+func periodicLaunchTime(jobID string) *time.Time {
+	idx := strings.LastIndex(jobID, "/periodic-")
+	if idx < 0 {
+		return nil
+	}
+
+	secs, err := strconv.ParseInt(jobID[idx+len("/periodic-"):], 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	launched := time.Unix(secs, 0).UTC()
+	return &launched
+}
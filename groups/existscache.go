@@ -0,0 +1,78 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	lru "github.com/hashicorp/golang-lru"
+)
+
+// resourceKey identifies a Triton resource as seen by an account in a
+// datacenter, since resources may be private to an account.
+type resourceKey struct {
+	tritonURL string
+	accountID string
+	id        string
+}
+
+type existsCacheEntry struct {
+	exists  bool
+	fetched time.Time
+}
+
+// existsCache caches whether Triton resources, such as images, exist.
+type existsCache struct {
+	name    string
+	entries *lru.Cache
+	ttl     func() time.Duration
+	now     func() time.Time
+}
+
+func newExistsCache(name string, size int, ttl func() time.Duration) *existsCache {
+	entries, err := lru.New(size)
+	if err != nil {
+		panic(err)
+	}
+
+	return &existsCache{
+		name:    name,
+		entries: entries,
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// Exists returns whether the resource exists from the cache if it was looked
+// up within the TTL, otherwise it is looked up with lookup. Errors are never
+// cached.
+func (c *existsCache) Exists(key resourceKey, lookup func() (bool, error)) (bool, error) {
+	ttl := c.ttl()
+
+	if ttl > 0 {
+		if value, ok := c.entries.Get(key); ok {
+			entry := value.(existsCacheEntry)
+			if c.now().Sub(entry.fetched) < ttl {
+				metrics.IncrCounter([]string{"triton", c.name + "_cache", "hit"}, 1)
+				return entry.exists, nil
+			}
+			c.entries.Remove(key)
+		}
+	}
+	metrics.IncrCounter([]string{"triton", c.name + "_cache", "miss"}, 1)
+
+	exists, err := lookup()
+	if err != nil {
+		return false, err
+	}
+
+	if ttl > 0 {
+		c.entries.Add(key, existsCacheEntry{exists: exists, fetched: c.now()})
+	}
+
+	return exists, nil
+}
@@ -0,0 +1,92 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/joyent/triton-service-groups/accounts"
+	"github.com/joyent/triton-service-groups/server/handlers"
+)
+
+const expandAccount = "account"
+
+// GroupAccount is the Triton account which owns a group. It is only included
+// in group reads when requested with "?expand=account".
+type GroupAccount struct {
+	AccountName string `json:"account_name"`
+	TritonUUID  string `json:"triton_uuid"`
+}
+
+// parseExpand reads the comma separated list of related objects to include
+// in a response from the request's expand query parameter.
+func parseExpand(r *http.Request) (map[string]bool, error) {
+	expand := map[string]bool{}
+
+	for _, values := range r.URL.Query()["expand"] {
+		for _, value := range strings.Split(values, ",") {
+			switch value = strings.TrimSpace(value); value {
+			case "":
+			case expandAccount:
+				expand[value] = true
+			default:
+				return nil, fmt.Errorf("unsupported expand value: %q", value)
+			}
+		}
+	}
+
+	return expand, nil
+}
+
+// expandGroups includes the requested related objects in each group.
+func expandGroups(ctx context.Context, expand map[string]bool, accountID string, groups ...*ServiceGroup) error {
+	if len(groups) == 0 {
+		return nil
+	}
+
+	expandGroup, err := groupExpander(ctx, expand, accountID)
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		expandGroup(group)
+	}
+
+	return nil
+}
+
+// groupExpander looks up everything needed for the requested expansions once,
+// returning a func which expands a single group of the account.
+func groupExpander(ctx context.Context, expand map[string]bool, accountID string) (func(group *ServiceGroup), error) {
+	if !expand[expandAccount] {
+		return func(*ServiceGroup) {}, nil
+	}
+
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return nil, handlers.ErrNoConnPool
+	}
+
+	account, err := accounts.NewStore(db).FindByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(group *ServiceGroup) {
+		withAccount(group, account)
+	}, nil
+}
+
+func withAccount(group *ServiceGroup, account *accounts.Account) {
+	group.Account = &GroupAccount{
+		AccountName: account.AccountName,
+		TritonUUID:  account.TritonUUID,
+	}
+}
@@ -0,0 +1,62 @@
+package groups_v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/accounts"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseExpand(t *testing.T) {
+	parse := func(query string) (map[string]bool, error) {
+		r := httptest.NewRequest(http.MethodGet, "/v1/tsg/groups"+query, nil)
+		return parseExpand(r)
+	}
+
+	expand, err := parse("")
+	require.NoError(t, err)
+	assert.Empty(t, expand)
+
+	expand, err = parse("?expand=account")
+	require.NoError(t, err)
+	assert.True(t, expand[expandAccount])
+
+	_, err = parse("?expand=account,credentials")
+	assert.Error(t, err)
+}
+
+func TestExpandAccount(t *testing.T) {
+	marshal := func(g *ServiceGroup) map[string]interface{} {
+		bytes, err := json.Marshal(g)
+		require.NoError(t, err)
+
+		fields := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(bytes, &fields))
+		return fields
+	}
+
+	g := &ServiceGroup{ID: "722d25ed-f32a-4944-9861-8990e204850e", GroupName: "jolly-jelly"}
+
+	// not expanded, so no database access is needed
+	require.NoError(t, expandGroups(context.Background(), map[string]bool{}, "", g))
+	assert.NotContains(t, marshal(g), "account")
+
+	withAccount(g, &accounts.Account{
+		ID:          "6d6f3c38-2ed5-4b7a-a0ad-8a2caa32bbb4",
+		AccountName: "testacct",
+		TritonUUID:  "87307a00-ab96-4fec-8df7-1a256e49fbcc",
+		KeyID:       "b1b0d0a8-b2a5-4d0c-9c3e-2b1f4b0f3b7e",
+	})
+
+	fields := marshal(g)
+	require.Contains(t, fields, "account")
+	assert.Equal(t, map[string]interface{}{
+		"account_name": "testacct",
+		"triton_uuid":  "87307a00-ab96-4fec-8df7-1a256e49fbcc",
+	}, fields["account"])
+}
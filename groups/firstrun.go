@@ -0,0 +1,169 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+)
+
+// firstRunPollInterval is how often Nomad is polled while waiting on the
+// first run of a submitted job.
+var firstRunPollInterval = 2 * time.Second
+
+type firstRunKey struct{}
+
+// withFirstRunWait returns a copy of ctx with which submitting a group's job
+// waits on the job's first periodic run, see awaitFirstRun.
+func withFirstRunWait(ctx context.Context) context.Context {
+	return context.WithValue(ctx, firstRunKey{}, true)
+}
+
+func waitsForFirstRun(ctx context.Context) bool {
+	wait, _ := ctx.Value(firstRunKey{}).(bool)
+	return wait
+}
+
+// ErrFirstRun is returned by a synchronous submit when the periodic instance
+// forced to run as the group's job was registered failed, such as when it
+// couldn't be placed or tsg-cli exited with an error. The job itself stays
+// registered.
+type ErrFirstRun struct {
+	JobID   string
+	EvalID  string
+	Reasons []string
+}
+
+func (e *ErrFirstRun) Error() string {
+	return fmt.Sprintf("First run of job %s failed: %s", e.JobID, strings.Join(e.Reasons, "; "))
+}
+
+// awaitFirstRun waits on the periodic instance forced to run when the job of
+// submission was registered, if ctx asks to, returning an ErrFirstRun if it
+// fails. A run which neither failed nor finished by the configured timeout
+// isn't an error, and nor is a job without a periodic instance.
+func awaitFirstRun(ctx context.Context, submission *JobSubmission) error {
+	if !waitsForFirstRun(ctx) || submission.PeriodicEvalID == "" {
+		return nil
+	}
+
+	client, ok := handlers.GetNomadClient(ctx)
+	if !ok {
+		return handlers.ErrNoNomadClient
+	}
+
+	timeout := config.GetFirstRunTimeout()
+	deadline := time.Now().Add(timeout)
+	scope := nomadScopeOf(ctx)
+
+	for {
+		done, err := firstRunOutcome(client, scope, submission)
+		if err != nil || done {
+			return err
+		}
+
+		if time.Now().After(deadline) {
+			handlers.Logger(ctx).Info().
+				Str("job_id", submission.JobID).
+				Str("eval_id", submission.PeriodicEvalID).
+				Dur("timeout", timeout).
+				Msg("orchestrator: first run of job still in progress, not waiting any longer")
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(firstRunPollInterval):
+		}
+	}
+}
+
+// firstRunOutcome returns true once the periodic instance of submission has
+// finished, or an ErrFirstRun if it failed.
+func firstRunOutcome(client *nomad.Client, scope nomadScope, submission *JobSubmission) (bool, error) {
+	eval, _, err := client.Evaluations().Info(submission.PeriodicEvalID, scope.queryOptions())
+	if err != nil {
+		return false, &ErrNomad{Op: ErrNomadEvaluations, Err: err}
+	}
+
+	failed := func(reasons ...string) (bool, error) {
+		return true, &ErrFirstRun{
+			JobID:   eval.JobID,
+			EvalID:  eval.ID,
+			Reasons: reasons,
+		}
+	}
+
+	if len(eval.FailedTGAllocs) > 0 {
+		taskGroups := make([]string, 0, len(eval.FailedTGAllocs))
+		for tg := range eval.FailedTGAllocs {
+			taskGroups = append(taskGroups, tg)
+		}
+		sort.Strings(taskGroups)
+
+		var reasons []string
+		for _, tg := range taskGroups {
+			for _, reason := range placementReasons(eval.FailedTGAllocs[tg]) {
+				reasons = append(reasons, fmt.Sprintf("unable to place %s: %s", tg, reason))
+			}
+		}
+		if len(reasons) == 0 {
+			reasons = append(reasons, "unable to place the job")
+		}
+		return failed(reasons...)
+	}
+
+	switch eval.Status {
+	case "complete":
+	case "failed", "canceled":
+		return failed(fmt.Sprintf("evaluation %s %s: %s", eval.ID, eval.Status, eval.StatusDescription))
+	default:
+		return false, nil
+	}
+
+	allocs, _, err := client.Evaluations().Allocations(eval.ID, scope.queryOptions())
+	if err != nil {
+		return false, &ErrNomad{Op: ErrNomadAllocations, Err: err}
+	}
+
+	done := true
+	for _, alloc := range allocs {
+		switch alloc.ClientStatus {
+		case "failed", "lost":
+			return failed(allocFailure(alloc))
+		case "complete":
+		default:
+			done = false
+		}
+	}
+	return done, nil
+}
+
+// allocFailure describes why a failed allocation failed, by the last event of
+// a task which has one.
+func allocFailure(alloc *nomad.AllocationListStub) string {
+	for task, state := range alloc.TaskStates {
+		if state == nil || len(state.Events) == 0 {
+			continue
+		}
+		event := state.Events[len(state.Events)-1]
+		if event.DisplayMessage != "" {
+			return fmt.Sprintf("allocation %s %s: task %s: %s", alloc.ID, alloc.ClientStatus, task, event.DisplayMessage)
+		}
+	}
+	if alloc.ClientDescription != "" {
+		return fmt.Sprintf("allocation %s %s: %s", alloc.ID, alloc.ClientStatus, alloc.ClientDescription)
+	}
+	return fmt.Sprintf("allocation %s %s", alloc.ID, alloc.ClientStatus)
+}
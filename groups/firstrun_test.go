@@ -0,0 +1,134 @@
+package groups_v1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/joyent/triton-service-groups/testutils"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAwaitFirstRun(t *testing.T) {
+	defer func(interval time.Duration) { firstRunPollInterval = interval }(firstRunPollInterval)
+	firstRunPollInterval = time.Millisecond
+
+	const childID = "web_c2e4d1491ce423e3/periodic-1523718000"
+	submission := &JobSubmission{
+		JobID:          "web_c2e4d1491ce423e3",
+		EvalID:         "a1e1fc3a-9ca0-4a61-878c-c7b2bb4b1c0e",
+		PeriodicEvalID: "8f7a0b4c-2b8f-4f2e-9d4a-3c8d2f7e6b1a",
+	}
+
+	// setup serves the evaluation and allocations of the first run, which
+	// advance a step each time the evaluation is polled.
+	setup := func(t *testing.T, evals []*nomad.Evaluation, allocs []*nomad.AllocationListStub) (context.Context, *int, func()) {
+		fake := testutils.NewFakeNomad(t)
+		polls := 0
+
+		fake.HandleFunc("/v1/evaluation/"+submission.PeriodicEvalID, func(w http.ResponseWriter, r *http.Request) {
+			eval := evals[polls]
+			if polls < len(evals)-1 {
+				polls++
+			}
+			eval.ID, eval.JobID = submission.PeriodicEvalID, childID
+			testutils.WriteJSON(w, eval)
+		})
+		fake.HandleJSON("/v1/evaluation/"+submission.PeriodicEvalID+"/allocations", allocs)
+
+		ctx := handlers.WithAuthSession(context.Background(), &auth.Session{Datacenter: "us-east-1"})
+		ctx = handlers.WithNomadClient(ctx, fake.Client)
+		return withFirstRunWait(ctx), &polls, fake.Close
+	}
+
+	t.Run("async", func(t *testing.T) {
+		// No Nomad client is needed when the submit doesn't wait.
+		assert.NoError(t, awaitFirstRun(context.Background(), submission))
+	})
+
+	t.Run("not periodic", func(t *testing.T) {
+		ctx := withFirstRunWait(context.Background())
+		assert.NoError(t, awaitFirstRun(ctx, &JobSubmission{JobID: submission.JobID}))
+	})
+
+	t.Run("complete", func(t *testing.T) {
+		ctx, polls, done := setup(t, []*nomad.Evaluation{
+			{Status: "pending"},
+			{Status: "complete"},
+		}, []*nomad.AllocationListStub{
+			{ID: "c1", ClientStatus: "complete"},
+		})
+		defer done()
+
+		require.NoError(t, awaitFirstRun(ctx, submission))
+		assert.Equal(t, 1, *polls)
+	})
+
+	t.Run("placement failed", func(t *testing.T) {
+		ctx, _, done := setup(t, []*nomad.Evaluation{{
+			Status: "complete",
+			FailedTGAllocs: map[string]*nomad.AllocationMetric{
+				"scale": {NodesEvaluated: 3, DimensionExhausted: map[string]int{"memory": 3}},
+			},
+		}}, nil)
+		defer done()
+
+		err := awaitFirstRun(ctx, submission)
+		require.Error(t, err)
+		firstRunErr, ok := err.(*ErrFirstRun)
+		require.True(t, ok, "%T", err)
+		assert.Equal(t, childID, firstRunErr.JobID)
+		assert.Equal(t, []string{"unable to place scale: resources exhausted: memory (3 nodes)"}, firstRunErr.Reasons)
+		assert.Equal(t, http.StatusUnprocessableEntity, orchestratorErrorStatus(err))
+	})
+
+	t.Run("allocation failed", func(t *testing.T) {
+		ctx, _, done := setup(t, []*nomad.Evaluation{{Status: "complete"}}, []*nomad.AllocationListStub{{
+			ID:           "c1",
+			ClientStatus: "failed",
+			TaskStates: map[string]*nomad.TaskState{
+				"healthy": {Events: []*nomad.TaskEvent{
+					{DisplayMessage: "Task started by client"},
+					{DisplayMessage: "Exit Code: 1"},
+				}},
+			},
+		}})
+		defer done()
+
+		err := awaitFirstRun(ctx, submission)
+		assert.EqualError(t, err, "First run of job "+childID+" failed: allocation c1 failed: task healthy: Exit Code: 1")
+	})
+
+	t.Run("timed out", func(t *testing.T) {
+		defer viper.Reset()
+		viper.Set(config.KeyNomadFirstRunTimeout, 10*time.Millisecond)
+
+		ctx, _, done := setup(t, []*nomad.Evaluation{{Status: "complete"}}, []*nomad.AllocationListStub{
+			{ID: "c1", ClientStatus: "running"},
+		})
+		defer done()
+
+		assert.NoError(t, awaitFirstRun(ctx, submission))
+	})
+}
+
+func TestWaitParam(t *testing.T) {
+	wait, err := waitParam(httptest.NewRequest(http.MethodPost, "/v1/tsg/groups", nil))
+	require.NoError(t, err)
+	assert.False(t, wait)
+
+	wait, err = waitParam(httptest.NewRequest(http.MethodPost, "/v1/tsg/groups?wait=true", nil))
+	require.NoError(t, err)
+	assert.True(t, wait)
+
+	_, err = waitParam(httptest.NewRequest(http.MethodPost, "/v1/tsg/groups?wait=soon", nil))
+	assert.EqualError(t, err, "wait must be a boolean")
+}
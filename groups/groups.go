@@ -6,20 +6,23 @@
 package groups_v1
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/http"
 	"path"
+	"strconv"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
-	"github.com/joyent/triton-go"
-	"github.com/joyent/triton-go/authentication"
-	"github.com/joyent/triton-go/compute"
-	"github.com/joyent/triton-service-groups/accounts"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/messages"
+	"github.com/joyent/triton-service-groups/names"
 	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/templates"
 	"github.com/pkg/errors"
 	"github.com/rs/zerolog/log"
 )
@@ -31,6 +34,41 @@ type ServiceGroup struct {
 	Capacity   int       `json:"capacity"`
 	CreatedAt  time.Time `json:"created_at"`
 	UpdatedAt  time.Time `json:"updated_at"`
+
+	InstanceNamePattern string          `json:"instance_name_pattern"`
+	Alerts              AlertThresholds `json:"alerts"`
+	// Datacenters optionally sets the capacity of the group in each of
+	// several datacenters, in which case Capacity is their total.
+	Datacenters map[string]int `json:"datacenters,omitempty"`
+	// Canary optionally sets a check which a single new instance must pass
+	// before the group scales up any further.
+	Canary *CanaryConfig `json:"canary,omitempty"`
+	// TSGCliVersion optionally runs the group's job with a release of
+	// tsg-cli other than the configured one.
+	TSGCliVersion string `json:"tsg_cli_version,omitempty"`
+	// TimeZone optionally runs the periodic schedule of the group's job in an
+	// IANA time zone other than the configured one.
+	TimeZone string `json:"time_zone,omitempty"`
+	// Restart and Reschedule optionally override the configured policies
+	// with which Nomad retries the group's failed job.
+	Restart    *RestartPolicy    `json:"restart,omitempty"`
+	Reschedule *ReschedulePolicy `json:"reschedule,omitempty"`
+	// Networks, Tags and MetaData optionally override those of the template
+	// on the group's instances, see withInstanceOverrides.
+	Networks *[]string          `json:"networks,omitempty"`
+	Tags     map[string]*string `json:"tags,omitempty"`
+	MetaData map[string]*string `json:"metadata,omitempty"`
+	// Labels organize the group, such as to select it with others for a
+	// bulk reconcile. Unlike Tags they aren't set on the group's instances.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Paused is set while the group's reconciles are paused, see
+	// PauseServiceGroup. It can't be changed by updating the group.
+	Paused bool `json:"paused"`
+
+	Account *GroupAccount `json:"account,omitempty"`
+	// Jobs are the jobs registered with Nomad by the request which created or
+	// updated the group. They aren't stored with the group.
+	Jobs []*JobSubmission `json:"jobs,omitempty"`
 }
 
 func Get(w http.ResponseWriter, r *http.Request) {
@@ -42,9 +80,20 @@ func Get(w http.ResponseWriter, r *http.Request) {
 
 	var group *ServiceGroup
 
+	expand, err := parseExpand(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	group, ok := FindGroupByID(ctx, uuid, session.AccountID)
 	if !ok {
-		http.NotFound(w, r)
+		groupNotFound(w, r, uuid)
+		return
+	}
+
+	if err := expandGroups(ctx, expand, session.AccountID, group); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
@@ -54,13 +103,29 @@ func Get(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	writeETag(w, group)
 	writeJSONResponse(w, bytes, http.StatusOK)
 }
 
+// Create creates a group and registers its job. A request with an
+// Idempotency-Key header is only served once, see withIdempotencyKey.
 func Create(w http.ResponseWriter, r *http.Request) {
+	withIdempotencyKey(w, r, create)
+}
+
+func create(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	session := handlers.GetAuthSession(ctx)
 
+	wait, err := waitParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if wait {
+		ctx = withFirstRunWait(ctx)
+	}
+
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -73,6 +138,54 @@ func Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := names.Validate("group", group.GroupName); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	max, err := maxCapacity(ctx, session.AccountID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := validateNamePattern(group.InstanceNamePattern, group.GroupName, session.Datacenter, max); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateTSGCliVersion(group.TSGCliVersion); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateTimeZone(group.TimeZone); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateLabels(group.Labels); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	accountDefault, err := findDefaultDatacenter(ctx, session.AccountID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	applyDefaultDatacenter(ctx, group, accountDefault)
+
+	if err := validateDatacenters(ctx, group); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := checkCapacity(ctx, session.AccountID, group); err != nil {
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
+		return
+	}
+
 	groupExists, err := CheckGroupExistsByName(ctx, group.GroupName, session.AccountID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -85,13 +198,24 @@ func Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = SaveGroup(ctx, session.AccountID, group)
+	jobNameTaken, err := CheckJobNameTaken(ctx, group.GroupName, session.AccountID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
+	if jobNameTaken {
+		http.Error(w, fmt.Sprintf("Cannot create group %q, "+
+			"its job name conflicts with a group of another account.",
+			group.GroupName), http.StatusConflict)
+		return
+	}
 
-	err = SubmitOrchestratorJob(ctx, group)
+	if err := checkGroupJob(ctx, group); err != nil {
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
+		return
+	}
+
+	err = SaveGroup(ctx, session.AccountID, group)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -103,6 +227,12 @@ func Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	com.Jobs, err = SubmitOrchestratorJob(ctx, com)
+	if err != nil {
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
+		return
+	}
+
 	bytes, err := json.Marshal(com)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -110,6 +240,7 @@ func Create(w http.ResponseWriter, r *http.Request) {
 	}
 
 	w.Header().Set("Location", path.Join(r.URL.Path, com.ID))
+	writeETag(w, com)
 	writeJSONResponse(w, bytes, http.StatusCreated)
 }
 
@@ -120,6 +251,15 @@ func Update(w http.ResponseWriter, r *http.Request) {
 	vars := mux.Vars(r)
 	identifier := vars["identifier"]
 
+	wait, err := waitParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if wait {
+		ctx = withFirstRunWait(ctx)
+	}
+
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -132,9 +272,50 @@ func Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := names.Validate("group", group.GroupName); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	max, err := maxCapacity(ctx, session.AccountID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if err := validateNamePattern(group.InstanceNamePattern, group.GroupName, session.Datacenter, max); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateTSGCliVersion(group.TSGCliVersion); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateTimeZone(group.TimeZone); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateLabels(group.Labels); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := validateDatacenters(ctx, group); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := checkCapacity(ctx, session.AccountID, group); err != nil {
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
+		return
+	}
+
 	com, ok := FindGroupByID(ctx, identifier, session.AccountID)
 	if !ok {
-		http.NotFound(w, r)
+		groupNotFound(w, r, identifier)
 		return
 	}
 
@@ -145,21 +326,44 @@ func Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = UpdateGroup(ctx, identifier, session.AccountID, group)
+	if !ifMatch(r, com) {
+		messages.Write(w, r, ErrGroupModified, http.StatusPreconditionFailed)
+		return
+	}
+	group.Paused = com.Paused
+
+	if err := checkGroupJob(ctx, group); err != nil {
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
+		return
+	}
+
+	err = updateGroup(ctx, r, session.AccountID, com, group)
+	if err == ErrGroupModified {
+		messages.Write(w, r, err, http.StatusPreconditionFailed)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = UpdateOrchestratorJob(ctx, group)
+	previous := com
+	com, ok = FindGroupByID(ctx, identifier, session.AccountID)
+	if !ok {
+		groupNotFound(w, r, identifier)
+		return
+	}
+
+	com.Jobs, err = UpdateOrchestratorJob(ctx, com)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
 		return
 	}
 
-	com.Capacity = group.Capacity
-	com.TemplateID = group.TemplateID
-	com.UpdatedAt = group.UpdatedAt
+	if err := DeleteRemovedDatacenterJobs(ctx, previous, com); err != nil {
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
+		return
+	}
 
 	bytes, err := json.Marshal(com)
 	if err != nil {
@@ -167,6 +371,7 @@ func Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	writeETag(w, com)
 	writeJSONResponse(w, bytes, http.StatusOK)
 }
 
@@ -179,42 +384,147 @@ func Delete(w http.ResponseWriter, r *http.Request) {
 
 	var group *ServiceGroup
 
+	wait, err := waitParam(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
 	group, ok := FindGroupByID(ctx, uuid, session.AccountID)
 	if !ok {
-		http.NotFound(w, r)
+		groupNotFound(w, r, uuid)
 		return
 	}
 
-	err := RemoveGroup(ctx, group.ID, session.AccountID)
+	var initial []string
+	if wait {
+		ids, err := liveInstances(ctx, group)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		initial = ids
+	}
+
+	err = RemoveGroup(ctx, group.ID, session.AccountID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	if err := DeleteOrchestratorJob(ctx, group); err != nil {
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
+		return
+	}
+
+	if !wait {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	result, err := waitForTeardown(ctx, group, initial, config.GetTeardownTimeout())
+	if err != nil {
+		// The client has gone away, so there's no one left to respond to.
+		log.Printf("stopped waiting on teardown of group %s: %v", group.ID, err)
+		return
+	}
+
+	bytes, err := json.Marshal(result)
+	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	w.WriteHeader(http.StatusNoContent)
+	status := http.StatusOK
+	if !result.Complete {
+		status = http.StatusAccepted
+	}
+	writeJSONResponse(w, bytes, status)
 }
 
 func List(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	session := handlers.GetAuthSession(ctx)
 
-	rows, err := FindGroups(ctx, session.AccountID)
+	expand, err := parseExpand(r)
 	if err != nil {
-		http.NotFound(w, r)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	selector, err := parseLabelSelector(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	if rows == nil || len(rows) == 0 {
-		writeJSONResponse(w, []byte("[]"), http.StatusOK)
+	if isPageRequest(r) {
+		listPage(w, r, expand, selector)
 		return
 	}
 
-	bytes, err := json.Marshal(rows)
+	expandGroup, err := groupExpander(ctx, expand, session.AccountID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	enc := handlers.NewArrayEncoder(w)
+
+	err = EachGroupByLabels(ctx, session.AccountID, selector, func(group *ServiceGroup) error {
+		expandGroup(group)
+		return enc.Encode(group)
+	})
+	if err != nil {
+		if !enc.Started() {
+			http.NotFound(w, r)
+			return
+		}
+		// The array is left unterminated so clients see the truncated
+		// response is invalid rather than mistaking it for a complete list.
+		log.Printf("failed to stream groups: %v", err)
+		return
+	}
+
+	if err := enc.Close(); err != nil {
+		log.Printf("%v", err)
+	}
+}
+
+// listPage writes a page of the account's groups with the labels of selector,
+// as selected by the limit, offset and order query parameters.
+func listPage(w http.ResponseWriter, r *http.Request, expand map[string]bool, selector valueFilter) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	order, err := parseGroupOrder(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	expandGroup, err := groupExpander(ctx, expand, session.AccountID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	page, err := ListServiceGroups(ctx, session.AccountID, selector, limit, offset, order)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	for _, group := range page.Groups {
+		expandGroup(group)
+	}
+
+	bytes, err := json.Marshal(page)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -239,10 +549,21 @@ func Increment(w http.ResponseWriter, r *http.Request) {
 	// Get the Current Group Config
 	group, ok := FindGroupByID(ctx, uuid, session.AccountID)
 	if !ok {
-		http.NotFound(w, r)
+		groupNotFound(w, r, uuid)
+		return
+	}
+
+	if group.isMultiDatacenter() {
+		messages.Write(w, r, ErrMultiDatacenter, http.StatusBadRequest)
 		return
 	}
 
+	if !ifMatch(r, group) {
+		messages.Write(w, r, ErrGroupModified, http.StatusPreconditionFailed)
+		return
+	}
+	current := *group
+
 	input, err := buildActionableInput(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
@@ -261,21 +582,30 @@ func Increment(w http.ResponseWriter, r *http.Request) {
 		group.Capacity = group.Capacity + input.InstanceCount
 	}
 
+	if err := checkCapacity(ctx, session.AccountID, group); err != nil {
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
+		return
+	}
+
 	//Update the Database and the orchestration job
-	err = UpdateGroup(ctx, uuid, session.AccountID, group)
+	err = updateGroup(ctx, r, session.AccountID, &current, group)
+	if err == ErrGroupModified {
+		messages.Write(w, r, err, http.StatusPreconditionFailed)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	err = UpdateOrchestratorJob(ctx, group)
+	jobs, err := UpdateOrchestratorJob(ctx, group)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
 		return
 	}
 
 	//Return a 202 to suggest accepted
-	w.WriteHeader(http.StatusAccepted)
+	writeJobSubmissions(w, jobs)
 }
 
 func Decrement(w http.ResponseWriter, r *http.Request) {
@@ -288,9 +618,20 @@ func Decrement(w http.ResponseWriter, r *http.Request) {
 	// Get the Current Group Config
 	group, ok := FindGroupByID(ctx, uuid, session.AccountID)
 	if !ok {
-		http.NotFound(w, r)
+		groupNotFound(w, r, uuid)
+		return
+	}
+
+	if group.isMultiDatacenter() {
+		messages.Write(w, r, ErrMultiDatacenter, http.StatusBadRequest)
+		return
+	}
+
+	if !ifMatch(r, group) {
+		messages.Write(w, r, ErrGroupModified, http.StatusPreconditionFailed)
 		return
 	}
+	current := *group
 
 	input, err := buildActionableInput(r)
 	if err != nil {
@@ -311,19 +652,42 @@ func Decrement(w http.ResponseWriter, r *http.Request) {
 	}
 
 	//Update the Database and the orchestration job
-	err = UpdateGroup(ctx, uuid, session.AccountID, group)
+	err = updateGroup(ctx, r, session.AccountID, &current, group)
+	if err == ErrGroupModified {
+		messages.Write(w, r, err, http.StatusPreconditionFailed)
+		return
+	}
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	if err := UpdateOrchestratorJob(ctx, group); err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	jobs, err := UpdateOrchestratorJob(ctx, group)
+	if err != nil {
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
 		return
 	}
 
 	//Return a 202 to suggest accepted
-	w.WriteHeader(http.StatusAccepted)
+	writeJobSubmissions(w, jobs)
+}
+
+// writeJobSubmissions responds that a scaling request was accepted with the
+// jobs registered to carry it out.
+// JobSubmissions are the jobs registered by a request to change the capacity
+// of a group.
+type JobSubmissions struct {
+	Jobs []*JobSubmission `json:"jobs"`
+}
+
+func writeJobSubmissions(w http.ResponseWriter, jobs []*JobSubmission) {
+	bytes, err := json.Marshal(JobSubmissions{jobs})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, bytes, http.StatusAccepted)
 }
 
 func ListInstances(w http.ResponseWriter, r *http.Request) {
@@ -335,80 +699,352 @@ func ListInstances(w http.ResponseWriter, r *http.Request) {
 
 	group, ok := FindGroupByID(ctx, uuid, session.AccountID)
 	if !ok {
-		http.NotFound(w, r)
+		groupNotFound(w, r, uuid)
+		return
+	}
+
+	instances, err := listGroupInstances(ctx, session.AccountID, session.TritonURL, group)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	db, ok := handlers.GetDBPool(ctx)
+	bytes, err := json.Marshal(newInstances(instances))
+	if err != nil {
+		returnError := errors.Wrapf(err, "error marshalling TSG instance list")
+		http.Error(w, returnError.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, bytes, http.StatusOK)
+}
+
+func ListEvaluations(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	vars := mux.Vars(r)
+	uuid := vars["identifier"]
+
+	group, ok := FindGroupByID(ctx, uuid, session.AccountID)
 	if !ok {
-		http.Error(w, handlers.ErrNoConnPool.Error(), http.StatusInternalServerError)
+		groupNotFound(w, r, uuid)
+		return
+	}
+
+	limit, offset, err := parsePagination(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	page, err := ListOrchestratorEvaluations(ctx, group, limit, offset)
+	if err != nil {
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
 		return
 	}
-	store := accounts.NewStore(db)
-	account, err := store.FindByID(ctx, session.AccountID)
+
+	bytes, err := json.Marshal(page)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	credential, err := account.GetTritonCredential(ctx)
+	writeJSONResponse(w, bytes, http.StatusOK)
+}
+
+func GetStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	vars := mux.Vars(r)
+	uuid := vars["identifier"]
+
+	group, ok := FindGroupByID(ctx, uuid, session.AccountID)
+	if !ok {
+		groupNotFound(w, r, uuid)
+		return
+	}
+
+	status, err := GetOrchestratorStatus(ctx, group)
+	if err != nil {
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
+		return
+	}
+
+	bytes, err := json.Marshal(status)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	input := authentication.PrivateKeySignerInput{
-		KeyID:              credential.KeyID,
-		PrivateKeyMaterial: []byte(credential.KeyMaterial),
-		AccountName:        credential.AccountName,
+	writeJSONResponse(w, bytes, http.StatusOK)
+}
+
+func GetJobStatus(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	vars := mux.Vars(r)
+	uuid := vars["identifier"]
+
+	group, ok := FindGroupByID(ctx, uuid, session.AccountID)
+	if !ok {
+		groupNotFound(w, r, uuid)
+		return
 	}
-	signer, err := authentication.NewPrivateKeySigner(input)
+
+	if group.isMultiDatacenter() {
+		messages.Write(w, r, ErrMultiDatacenter, http.StatusBadRequest)
+		return
+	}
+
+	status, err := GetOrchestratorJobStatus(ctx, group)
 	if err != nil {
-		returnError := errors.Wrapf(err, "error Creating SSH Private Key Signer")
-		http.Error(w, returnError.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
+		return
+	}
+
+	bytes, err := json.Marshal(status)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, bytes, http.StatusOK)
+}
+
+func Render(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	vars := mux.Vars(r)
+	uuid := vars["identifier"]
+
+	group, ok := FindGroupByID(ctx, uuid, session.AccountID)
+	if !ok {
+		groupNotFound(w, r, uuid)
 		return
 	}
 
-	config := &triton.ClientConfig{
-		TritonURL:   session.TritonURL,
-		AccountName: credential.AccountName,
-		Signers:     []authentication.Signer{signer},
+	if group.isMultiDatacenter() {
+		messages.Write(w, r, ErrMultiDatacenter, http.StatusBadRequest)
+		return
 	}
 
-	c, err := compute.NewClient(config)
+	input, err := buildRenderInput(r)
 	if err != nil {
-		returnError := errors.Wrapf(err, "error constructing ComputeClient")
-		http.Error(w, returnError.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	params := &compute.ListInstancesInput{}
-	t := make(map[string]interface{}, 0)
-	t["tsg.name"] = group.GroupName
-	params.Tags = t
+	rendered, err := RenderOrchestratorJob(ctx, group, input)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
 
-	instances, err := c.Instances().List(ctx, params)
+	bytes, err := json.Marshal(rendered)
 	if err != nil {
-		returnError := errors.Wrapf(err, "error listing instances in TSG")
-		http.Error(w, returnError.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, bytes, http.StatusOK)
+}
+
+// JobSpec writes the HCL jobspec of the group's current job as plain text. It
+// only ever renders the job, Nomad is never called.
+func JobSpec(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	vars := mux.Vars(r)
+	uuid := vars["identifier"]
+
+	if v := r.URL.Query().Get("dry_run"); v != "" {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "dry_run must be a boolean", http.StatusBadRequest)
+			return
+		}
+		if !dryRun {
+			http.Error(w, "jobspec can only be rendered as a dry run", http.StatusBadRequest)
+			return
+		}
+	}
+
+	group, ok := FindGroupByID(ctx, uuid, session.AccountID)
+	if !ok {
+		groupNotFound(w, r, uuid)
 		return
 	}
 
-	if instances != nil && len(instances) == 0 {
-		writeJSONResponse(w, []byte("[]"), http.StatusOK)
+	if group.isMultiDatacenter() {
+		messages.Write(w, r, ErrMultiDatacenter, http.StatusBadRequest)
 		return
 	}
 
-	bytes, err := json.Marshal(instances)
+	rendered, err := RenderOrchestratorJob(ctx, group, &RenderInput{})
 	if err != nil {
-		returnError := errors.Wrapf(err, "error marshalling TSG instance list")
-		http.Error(w, returnError.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.WriteString(w, rendered.JobSpec); err != nil {
+		log.Printf("%v", err)
+	}
+}
+
+func Snapshot(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	vars := mux.Vars(r)
+	uuid := vars["identifier"]
+
+	group, ok := FindGroupByID(ctx, uuid, session.AccountID)
+	if !ok {
+		groupNotFound(w, r, uuid)
+		return
+	}
+
+	snapshot, err := GetGroupSnapshot(ctx, group)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := snapshot.Marshal()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	writeJSONResponse(w, bytes, http.StatusOK)
 }
 
+func Adopt(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	vars := mux.Vars(r)
+	uuid := vars["identifier"]
+
+	group, ok := FindGroupByID(ctx, uuid, session.AccountID)
+	if !ok {
+		groupNotFound(w, r, uuid)
+		return
+	}
+
+	if group.isMultiDatacenter() {
+		messages.Write(w, r, ErrMultiDatacenter, http.StatusBadRequest)
+		return
+	}
+
+	reconcile := r.URL.Query().Get("reconcile") == "true"
+
+	result, err := AdoptOrchestratorJob(ctx, group, reconcile)
+	switch err {
+	case nil:
+	case ErrJobNotFound:
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case ErrJobManaged:
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	default:
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
+		return
+	}
+
+	bytes, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, bytes, http.StatusOK)
+}
+
+// updateGroup saves the changes to current held in group. When the request
+// carries an If-Match precondition the update only succeeds if current is
+// still the latest state of the group.
+func updateGroup(ctx context.Context, r *http.Request, accountID string, current, group *ServiceGroup) error {
+	if r.Header.Get("If-Match") == "" {
+		return UpdateGroup(ctx, current.ID, accountID, group)
+	}
+	return UpdateGroupIfUnmodified(ctx, current.ID, accountID, group, current.UpdatedAt)
+}
+
+// orchestratorErrorStatus maps an error from building or submitting a group's
+// job to the status code of the response. Failed calls to Nomad are upstream
+// failures, including those wrapped by an ErrJobUpdate, while a request
+// without a Nomad client to call is unavailable.
+func orchestratorErrorStatus(err error) int {
+	switch err := err.(type) {
+	case *ErrJobTooLarge:
+		return http.StatusRequestEntityTooLarge
+	case *ErrImageNotFound, *ErrPackageNotFound, *ErrNetworksNotFound, *ErrUnsafeJobValue, *ErrFirstRun, *templates_v1.ErrMissingTags:
+		return http.StatusUnprocessableEntity
+	case *ErrTemplateNotFound:
+		return http.StatusNotFound
+	case *ErrInvalidCapacity, *ErrInvalidTritonID:
+		return http.StatusBadRequest
+	case *ErrDatacenters:
+		return datacentersErrorStatus(err)
+	}
+
+	if isNomadUnavailable(err) {
+		return http.StatusServiceUnavailable
+	}
+	if isNomadFailure(err) {
+		return http.StatusBadGateway
+	}
+	return http.StatusInternalServerError
+}
+
+// datacentersErrorStatus returns the status shared by every failed
+// datacenter when each was rejected as a client error, such as a template
+// which doesn't suit any of them. Otherwise some datacenters failed
+// upstream.
+func datacentersErrorStatus(err *ErrDatacenters) int {
+	status := 0
+	for _, dcErr := range err.Errors {
+		s := orchestratorErrorStatus(dcErr)
+		if s >= http.StatusInternalServerError || (status != 0 && s != status) {
+			return http.StatusBadGateway
+		}
+		status = s
+	}
+	if status == 0 {
+		return http.StatusBadGateway
+	}
+	return status
+}
+
+// findGroupTombstone is swapped out by tests.
+var findGroupTombstone = FindGroupTombstone
+
+// groupNotFound responds to a request for a group which couldn't be found.
+// Groups which existed and have been deleted are 410 Gone, noting when they
+// were deleted, so clients can tell them apart from IDs which never existed.
+func groupNotFound(w http.ResponseWriter, r *http.Request, groupID string) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	deletedAt, ok := findGroupTombstone(ctx, groupID, session.AccountID)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	http.Error(w, fmt.Sprintf("group %s was deleted at %s",
+		groupID, deletedAt.UTC().Format(time.RFC3339)), http.StatusGone)
+}
+
 func writeJSONResponse(w http.ResponseWriter, bytes []byte, statusCode int) {
 	w.Header().Set("Content-Type", "application/json; charset=utf-8")
 	w.WriteHeader(statusCode)
@@ -419,6 +1055,33 @@ func writeJSONResponse(w http.ResponseWriter, bytes []byte, statusCode int) {
 	}
 }
 
+// parsePagination reads the optional limit and offset query parameters from
+// the request. Missing parameters are returned as zero, as is an explicit zero,
+// which leaves the caller's default page size in place.
+func parsePagination(r *http.Request) (int, int, error) {
+	var limit, offset int
+
+	query := r.URL.Query()
+
+	if v := query.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("limit must be a non-negative integer")
+		}
+		limit = n
+	}
+
+	if v := query.Get("offset"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return 0, 0, errors.New("offset must be a non-negative integer")
+		}
+		offset = n
+	}
+
+	return limit, offset, nil
+}
+
 func buildActionableInput(r *http.Request) (*ActionableInput, error) {
 	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
@@ -447,20 +1110,38 @@ func (i *ActionableInput) Validate() error {
 	return nil
 }
 
-func decodeGroupResponseBodyAndValidate(body []byte) (*ServiceGroup, error) {
-	var group *ServiceGroup
-	err := json.Unmarshal(body, &group)
+func buildRenderInput(r *http.Request) (*RenderInput, error) {
+	body, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		return nil, errors.New("error in unmarshal request body")
+		return nil, err
 	}
 
-	if group.GroupName == "" {
-		return nil, errors.New("group name cannot be empty")
+	input := &RenderInput{}
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, input); err != nil {
+			return nil, errors.New("error in unmarshal request body")
+		}
 	}
 
-	if len(group.GroupName) > 182 {
-		return nil, errors.New("group name cannot be more than 182 characters")
+	if input.TemplateID != "" && !isValidUUID(input.TemplateID) {
+		return nil, errors.New("template ID must be a valid UUID")
+	}
+
+	if input.Capacity != nil && *input.Capacity < 0 {
+		return nil, errors.New("group capacity cannot be a negative number")
+	}
+
+	return input, nil
+}
+
+func decodeGroupResponseBodyAndValidate(body []byte) (*ServiceGroup, error) {
+	var group *ServiceGroup
+	err := json.Unmarshal(body, &group)
+	if err != nil {
+		return nil, errors.New("error in unmarshal request body")
 	}
+	// A group is only paused by PauseServiceGroup.
+	group.Paused = false
 
 	if !isValidUUID(group.TemplateID) {
 		return nil, errors.New("template ID must be a valid UUID")
@@ -470,13 +1151,56 @@ func decodeGroupResponseBodyAndValidate(body []byte) (*ServiceGroup, error) {
 		return nil, errors.New("group capacity cannot be a negative number")
 	}
 
-	if group.Capacity > 100 {
-		return nil, errors.New("group capacity cannot be more than 100 compute instances")
+	if group.Alerts.BelowCapacityMinutes < 0 {
+		return nil, errors.New("alert thresholds cannot be negative numbers")
+	}
+
+	if group.isMultiDatacenter() {
+		group.Capacity = 0
+		for _, capacity := range group.Datacenters {
+			if capacity < 0 {
+				return nil, errors.New("datacenter capacity cannot be a negative number")
+			}
+			group.Capacity += capacity
+		}
+	}
+
+	if group.Canary != nil {
+		if err := group.Canary.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if group.Restart != nil {
+		if err := group.Restart.validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	if group.Reschedule != nil {
+		if err := group.Reschedule.validate(); err != nil {
+			return nil, err
+		}
 	}
 
 	return group, nil
 }
 
+// waitParam parses the optional wait query parameter, with which a request
+// waits on the outcome of the change it makes.
+func waitParam(r *http.Request) (bool, error) {
+	v := r.URL.Query().Get("wait")
+	if v == "" {
+		return false, nil
+	}
+
+	wait, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, errors.New("wait must be a boolean")
+	}
+	return wait, nil
+}
+
 func isValidUUID(u string) bool {
 	_, err := uuid.Parse(u)
 	return err == nil
@@ -8,13 +8,20 @@ package groups_v1
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/jackc/pgx"
 	"github.com/jackc/pgx/pgtype"
+	"github.com/joyent/triton-service-groups/accounts"
 	"github.com/joyent/triton-service-groups/convert"
+	"github.com/joyent/triton-service-groups/messages"
 	"github.com/joyent/triton-service-groups/server/handlers"
 )
 
+// ErrGroupModified is returned when a group has been modified since it was
+// last read.
+var ErrGroupModified = messages.New(messages.GroupModified)
+
 func CheckGroupExistsByName(ctx context.Context, groupName, accountID string) (bool, error) {
 	db, ok := handlers.GetDBPool(ctx)
 	if !ok {
@@ -40,92 +47,388 @@ SELECT EXISTS
 }
 
 func FindGroups(ctx context.Context, accountID string) ([]*ServiceGroup, error) {
+	return FindGroupsByLabels(ctx, accountID, nil)
+}
+
+// FindGroupsByLabels returns the active groups of the account with every
+// label of selector, which must have been validated.
+func FindGroupsByLabels(ctx context.Context, accountID string, selector valueFilter) ([]*ServiceGroup, error) {
+	var groups []*ServiceGroup
+
+	err := EachGroupByLabels(ctx, accountID, selector, func(group *ServiceGroup) error {
+		groups = append(groups, group)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return groups, nil
+}
+
+// EachGroup calls fn for every active group of the account as it's read from
+// the database, without holding all of them in memory. Iteration stops at the
+// first error returned by fn.
+func EachGroup(ctx context.Context, accountID string, fn func(group *ServiceGroup) error) error {
+	return EachGroupByLabels(ctx, accountID, nil, fn)
+}
+
+// EachGroupByLabels is EachGroup for only the groups with every label of
+// selector, which must have been validated.
+func EachGroupByLabels(ctx context.Context, accountID string, selector valueFilter, fn func(group *ServiceGroup) error) error {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return handlers.ErrNoConnPool
+	}
+
+	labels, args := selectLabels(selector, []interface{}{accountID})
+
+	sqlStatement := `
+SELECT ` + groupColumns + `
+FROM tsg_groups
+WHERE account_id = $1
+AND archived = false` + labels + `;`
+
+	rows, err := db.QueryEx(ctx, sqlStatement, nil, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		group, err := scanGroup(rows)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(group); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+// ListServiceGroups returns a page of the active groups of the account with
+// every label of selector in the given order, along with the total number of
+// them. The selector must have been validated.
+func ListServiceGroups(ctx context.Context, accountID string, selector valueFilter, limit, offset int, order GroupOrder) (*GroupPage, error) {
 	db, ok := handlers.GetDBPool(ctx)
 	if !ok {
 		return nil, handlers.ErrNoConnPool
 	}
 
-	var groups []*ServiceGroup
+	page := newGroupPage(limit, offset, order)
+
+	labels, args := selectLabels(selector, []interface{}{accountID})
 
 	sqlStatement := `
-SELECT id, name, template_id, capacity, created_at, updated_at
+SELECT count(*)
 FROM tsg_groups
 WHERE account_id = $1
-AND archived = false;`
+AND archived = false` + labels + `;`
 
-	rows, err := db.QueryEx(ctx, sqlStatement, nil, accountID)
+	err := db.QueryRowEx(ctx, sqlStatement, nil, args...).Scan(&page.Total)
+	if err != nil {
+		return nil, err
+	}
+
+	if page.Offset >= page.Total {
+		return page, nil
+	}
+
+	sqlStatement = fmt.Sprintf(`
+SELECT `+groupColumns+`
+FROM tsg_groups
+WHERE account_id = $1
+AND archived = false`+labels+`
+ORDER BY `+page.Order.orderBy()+`
+LIMIT $%d OFFSET $%d;`, len(args)+1, len(args)+2)
+
+	rows, err := db.QueryEx(ctx, sqlStatement, nil, append(args, page.Limit, page.Offset)...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
 	for rows.Next() {
-		var (
-			group     ServiceGroup
-			groupID   pgtype.UUID
-			createdAt pgtype.Timestamp
-			updatedAt pgtype.Timestamp
-		)
-
-		err := rows.Scan(
-			&groupID,
-			&group.GroupName,
-			&group.TemplateID,
-			&group.Capacity,
-			&createdAt,
-			&updatedAt,
-		)
+		group, err := scanGroup(rows)
 		if err != nil {
 			return nil, err
 		}
+		page.Groups = append(page.Groups, group)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return page, nil
+}
+
+// groupColumns are the columns of tsg_groups read by scanGroup.
+const groupColumns = `id, name, template_id, capacity, alert_below_capacity_minutes, instance_name_pattern, datacenter_capacity, COALESCE(canary, ''), tsg_cli_version, time_zone, job_policies, instance_overrides, labels, paused, created_at, updated_at`
+
+// rowScanner is a single row read from the database, either a *pgx.Row or
+// the current row of *pgx.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanGroup reads a group from row, which must select groupColumns followed
+// by any columns read into extra.
+func scanGroup(row rowScanner, extra ...interface{}) (*ServiceGroup, error) {
+	var (
+		group       ServiceGroup
+		groupID     pgtype.UUID
+		datacenters string
+		canary      string
+		policies    string
+		overrides   string
+		labels      string
+		createdAt   pgtype.Timestamp
+		updatedAt   pgtype.Timestamp
+	)
+
+	dest := []interface{}{
+		&groupID,
+		&group.GroupName,
+		&group.TemplateID,
+		&group.Capacity,
+		&group.Alerts.BelowCapacityMinutes,
+		&group.InstanceNamePattern,
+		&datacenters,
+		&canary,
+		&group.TSGCliVersion,
+		&group.TimeZone,
+		&policies,
+		&overrides,
+		&labels,
+		&group.Paused,
+		&createdAt,
+		&updatedAt,
+	}
+	if err := row.Scan(append(dest, extra...)...); err != nil {
+		return nil, err
+	}
 
-		group.ID = convert.BytesToUUID(groupID.Bytes)
+	group.ID = convert.BytesToUUID(groupID.Bytes)
 
-		group.CreatedAt = createdAt.Time
-		group.UpdatedAt = updatedAt.Time
+	var err error
+	group.Datacenters, err = decodeDatacenters(datacenters)
+	if err != nil {
+		return nil, err
+	}
 
-		groups = append(groups, &group)
+	group.Canary, err = decodeCanary(canary)
+	if err != nil {
+		return nil, err
 	}
 
-	return groups, nil
+	if err := decodeJobPolicies(policies, &group); err != nil {
+		return nil, err
+	}
+
+	if err := decodeInstanceOverrides(overrides, &group); err != nil {
+		return nil, err
+	}
+
+	group.Labels, err = decodeLabels(labels)
+	if err != nil {
+		return nil, err
+	}
+
+	group.CreatedAt = createdAt.Time
+	group.UpdatedAt = updatedAt.Time
+
+	return &group, nil
 }
 
-func FindGroupByID(ctx context.Context, key string, accountID string) (*ServiceGroup, bool) {
+// FindManagedGroups returns every active group across all accounts along with
+// the details needed to locate its orchestrator job.
+func FindManagedGroups(ctx context.Context) ([]*ManagedGroup, error) {
 	db, ok := handlers.GetDBPool(ctx)
 	if !ok {
-		return nil, false
+		return nil, handlers.ErrNoConnPool
+	}
+
+	var groups []*ManagedGroup
+
+	sqlStatement := `
+SELECT ` + managedGroupColumns + `
+FROM tsg_groups g
+JOIN tsg_accounts a ON a.id = g.account_id
+WHERE g.archived = false
+AND a.archived = false;`
+
+	rows, err := db.QueryEx(ctx, sqlStatement, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		group, err := scanManagedGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
 	}
 
+	setTrackedGroups(len(groups))
+	return groups, nil
+}
+
+// managedGroupColumns are the columns of tsg_groups g, joined with the
+// tsg_accounts a owning them, read by scanManagedGroup.
+const managedGroupColumns = `g.id, g.name, g.template_id, g.capacity, g.alert_below_capacity_minutes, g.instance_name_pattern, g.datacenter_capacity, COALESCE(g.canary, ''), g.tsg_cli_version, g.time_zone, g.job_policies, g.instance_overrides, g.labels, g.paused, g.created_at, g.updated_at, g.account_id, COALESCE(a.triton_uuid, '')`
+
+// scanManagedGroup reads a group from row, which must select
+// managedGroupColumns.
+func scanManagedGroup(row rowScanner) (*ManagedGroup, error) {
 	var (
-		group     ServiceGroup
-		groupID   pgtype.UUID
-		createdAt pgtype.Timestamp
-		updatedAt pgtype.Timestamp
+		accountID  pgtype.UUID
+		tritonUUID string
 	)
 
+	group, err := scanGroup(row, &accountID, &tritonUUID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ManagedGroup{
+		ServiceGroup: group,
+		AccountID:    convert.BytesToUUID(accountID.Bytes),
+		JobRef:       accounts.JobRef(tritonUUID),
+		TritonUUID:   tritonUUID,
+	}, nil
+}
+
+// FindGroupByJobName returns the active group whose orchestrator job, or a
+// periodic instance of it, is named jobName, mapping the jobs seen in Nomad
+// back to their group. pgx.ErrNoRows is returned if no group has the job.
+// The job names of groups named alike in two accounts collide if the job
+// references of the accounts do, in which case an *ErrJobNameCollision is
+// returned rather than either group.
+func FindGroupByJobName(ctx context.Context, jobName string) (*ManagedGroup, error) {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return nil, handlers.ErrNoConnPool
+	}
+
+	groupName, jobRef, ok := parseJobName(periodicParent(jobName))
+	if !ok {
+		return nil, pgx.ErrNoRows
+	}
+
+	sqlStatement := `
+SELECT ` + managedGroupColumns + `
+FROM tsg_groups g
+JOIN tsg_accounts a ON a.id = g.account_id
+WHERE g.name = $1
+AND a.job_ref = $2
+AND g.archived = false
+AND a.archived = false
+LIMIT 2;`
+
+	rows, err := db.QueryEx(ctx, sqlStatement, nil, groupName, jobRef)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var groups []*ManagedGroup
+	for rows.Next() {
+		group, err := scanManagedGroup(rows)
+		if err != nil {
+			return nil, err
+		}
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	switch len(groups) {
+	case 0:
+		return nil, pgx.ErrNoRows
+	case 1:
+		return groups[0], nil
+	default:
+		return nil, &ErrJobNameCollision{JobName: jobName}
+	}
+}
+
+// CheckJobNameTaken returns true if a group of another account has the job
+// name a group named groupName would have in the account, as their accounts'
+// job references collide. Creating the group would then take over the job of
+// the other account's group.
+func CheckJobNameTaken(ctx context.Context, groupName, accountID string) (bool, error) {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return false, handlers.ErrNoConnPool
+	}
+
+	var taken bool
+
+	sql := `
+SELECT EXISTS
+  (SELECT 1
+   FROM tsg_groups g
+   JOIN tsg_accounts a ON a.id = g.account_id
+   JOIN tsg_accounts o ON o.job_ref = a.job_ref
+   WHERE o.id = $2
+     AND g.account_id != $2
+     AND g.name = $1
+     AND g.archived IS FALSE
+     AND a.archived IS FALSE);`
+
+	err := db.QueryRowEx(ctx, sql, nil, groupName, accountID).Scan(&taken)
+	if err != nil {
+		return false, err
+	}
+
+	return taken, nil
+}
+
+// findLocalGroups returns the managed groups which only run in the local
+// datacenter. Background monitors only watch the local datacenter, so groups
+// with per-datacenter capacity are left out.
+func findLocalGroups(ctx context.Context) ([]*ManagedGroup, error) {
+	groups, err := FindManagedGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	local := groups[:0]
+	for _, group := range groups {
+		if !group.isMultiDatacenter() {
+			local = append(local, group)
+		}
+	}
+	return local, nil
+}
+
+func FindGroupByID(ctx context.Context, key string, accountID string) (*ServiceGroup, bool) {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return nil, false
+	}
+
 	sqlStatement := `
-SELECT id, name, template_id, capacity, created_at, updated_at
+SELECT ` + groupColumns + `
 FROM tsg_groups
 WHERE account_id = $2 and id = $1
 AND archived = false
 `
 
-	err := db.QueryRowEx(ctx, sqlStatement, nil, key, accountID).Scan(
-		&groupID,
-		&group.GroupName,
-		&group.TemplateID,
-		&group.Capacity,
-		&createdAt,
-		&updatedAt,
-	)
+	group, err := scanGroup(db.QueryRowEx(ctx, sqlStatement, nil, key, accountID))
 	switch err {
 	case nil:
-		group.ID = convert.BytesToUUID(groupID.Bytes)
-
-		group.CreatedAt = createdAt.Time
-		group.UpdatedAt = updatedAt.Time
-
-		return &group, true
+		return group, true
 	case pgx.ErrNoRows:
 		fmt.Println("No rows were returned!")
 		return nil, false
@@ -140,35 +443,17 @@ func FindGroupByName(ctx context.Context, name string, accountID string) (*Servi
 		return nil, false
 	}
 
-	var (
-		group     ServiceGroup
-		groupID   pgtype.UUID
-		createdAt pgtype.Timestamp
-		updatedAt pgtype.Timestamp
-	)
-
 	sqlStatement := `
-SELECT id, name, template_id, capacity, created_at, updated_at
+SELECT ` + groupColumns + `
 FROM tsg_groups
 WHERE account_id = $2 and name = $1
 AND archived = false;
 `
-	err := db.QueryRowEx(ctx, sqlStatement, nil, name, accountID).Scan(
-		&groupID,
-		&group.GroupName,
-		&group.TemplateID,
-		&group.Capacity,
-		&createdAt,
-		&updatedAt,
-	)
+
+	group, err := scanGroup(db.QueryRowEx(ctx, sqlStatement, nil, name, accountID))
 	switch err {
 	case nil:
-		group.ID = convert.BytesToUUID(groupID.Bytes)
-
-		group.CreatedAt = createdAt.Time
-		group.UpdatedAt = updatedAt.Time
-
-		return &group, true
+		return group, true
 	case pgx.ErrNoRows:
 		fmt.Println("No rows were returned!")
 		return nil, false
@@ -177,6 +462,8 @@ AND archived = false;
 	}
 }
 
+// SaveGroup saves a new group of the account. A paused group, such as one
+// imported from a bundle, is saved paused.
 func SaveGroup(ctx context.Context, accountID string, group *ServiceGroup) error {
 	db, ok := handlers.GetDBPool(ctx)
 	if !ok {
@@ -184,14 +471,44 @@ func SaveGroup(ctx context.Context, accountID string, group *ServiceGroup) error
 	}
 
 	sqlStatement := `
-INSERT INTO tsg_groups (name, template_id, capacity, account_id, created_at, updated_at)
-VALUES ($1, $2, $3, $4, NOW(), NOW())
+INSERT INTO tsg_groups (name, template_id, capacity, account_id, alert_below_capacity_minutes, instance_name_pattern, datacenter_capacity, canary, tsg_cli_version, job_policies, instance_overrides, time_zone, labels, paused, created_at, updated_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, NOW(), NOW())
 `
-	_, err := db.ExecEx(ctx, sqlStatement, nil,
+	datacenters, err := encodeDatacenters(group.Datacenters)
+	if err != nil {
+		return err
+	}
+
+	canary, err := encodeCanary(group.Canary)
+	if err != nil {
+		return err
+	}
+
+	policies, err := encodeJobPolicies(group)
+	if err != nil {
+		return err
+	}
+
+	overrides, err := encodeInstanceOverrides(group)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecEx(ctx, sqlStatement, nil,
 		group.GroupName,
 		group.TemplateID,
 		group.Capacity,
 		accountID,
+		group.Alerts.BelowCapacityMinutes,
+		group.InstanceNamePattern,
+		datacenters,
+		canary,
+		group.TSGCliVersion,
+		policies,
+		overrides,
+		group.TimeZone,
+		encodeLabels(group.Labels),
+		group.Paused,
 	)
 	if err != nil {
 		return err
@@ -208,14 +525,43 @@ func UpdateGroup(ctx context.Context, uuid string, accountID string, group *Serv
 
 	sqlStatement := `
 UPDATE tsg_groups
-SET template_id = $3, capacity = $4, updated_at = NOW()
+SET template_id = $3, capacity = $4, alert_below_capacity_minutes = $5, instance_name_pattern = $6, datacenter_capacity = $7, canary = $8, tsg_cli_version = $9, job_policies = $10, instance_overrides = $11, time_zone = $12, labels = $13, updated_at = NOW()
 WHERE id = $1 and account_id = $2
 `
-	_, err := db.ExecEx(ctx, sqlStatement, nil,
+	datacenters, err := encodeDatacenters(group.Datacenters)
+	if err != nil {
+		return err
+	}
+
+	canary, err := encodeCanary(group.Canary)
+	if err != nil {
+		return err
+	}
+
+	policies, err := encodeJobPolicies(group)
+	if err != nil {
+		return err
+	}
+
+	overrides, err := encodeInstanceOverrides(group)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecEx(ctx, sqlStatement, nil,
 		uuid,
 		accountID,
 		group.TemplateID,
 		group.Capacity,
+		group.Alerts.BelowCapacityMinutes,
+		group.InstanceNamePattern,
+		datacenters,
+		canary,
+		group.TSGCliVersion,
+		policies,
+		overrides,
+		group.TimeZone,
+		encodeLabels(group.Labels),
 	)
 	if err != nil {
 		return err
@@ -224,6 +570,114 @@ WHERE id = $1 and account_id = $2
 	return nil
 }
 
+// UpdateGroupIfUnmodified updates a group only if it has not been modified
+// since updatedAt, returning ErrGroupModified otherwise.
+func UpdateGroupIfUnmodified(ctx context.Context, uuid string, accountID string, group *ServiceGroup, updatedAt time.Time) error {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return handlers.ErrNoConnPool
+	}
+
+	sqlStatement := `
+UPDATE tsg_groups
+SET template_id = $3, capacity = $4, alert_below_capacity_minutes = $5, instance_name_pattern = $6, datacenter_capacity = $7, canary = $8, tsg_cli_version = $9, job_policies = $10, instance_overrides = $11, time_zone = $12, labels = $13, updated_at = NOW()
+WHERE id = $1 and account_id = $2
+AND updated_at = $14
+`
+	datacenters, err := encodeDatacenters(group.Datacenters)
+	if err != nil {
+		return err
+	}
+
+	canary, err := encodeCanary(group.Canary)
+	if err != nil {
+		return err
+	}
+
+	policies, err := encodeJobPolicies(group)
+	if err != nil {
+		return err
+	}
+
+	overrides, err := encodeInstanceOverrides(group)
+	if err != nil {
+		return err
+	}
+
+	tag, err := db.ExecEx(ctx, sqlStatement, nil,
+		uuid,
+		accountID,
+		group.TemplateID,
+		group.Capacity,
+		group.Alerts.BelowCapacityMinutes,
+		group.InstanceNamePattern,
+		datacenters,
+		canary,
+		group.TSGCliVersion,
+		policies,
+		overrides,
+		group.TimeZone,
+		encodeLabels(group.Labels),
+		updatedAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrGroupModified
+	}
+
+	return nil
+}
+
+// SetGroupPaused pauses or resumes the reconciles of the group.
+func SetGroupPaused(ctx context.Context, uuid string, accountID string, paused bool) error {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return handlers.ErrNoConnPool
+	}
+
+	sqlStatement := `
+UPDATE tsg_groups
+SET paused = $3, updated_at = NOW()
+WHERE id = $1 and account_id = $2
+AND archived = false
+`
+	_, err := db.ExecEx(ctx, sqlStatement, nil, uuid, accountID, paused)
+	return err
+}
+
+// FindGroupTombstone returns when the group was deleted, if it existed and
+// has since been deleted. Deleted groups are kept archived as a tombstone,
+// and once purged only their tombstone in tsg_group_tombstones is kept.
+func FindGroupTombstone(ctx context.Context, key string, accountID string) (time.Time, bool) {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return time.Time{}, false
+	}
+
+	sqlStatement := `
+SELECT COALESCE(deleted_at, updated_at)
+FROM tsg_groups
+WHERE id = $1 AND account_id = $2
+AND archived = true
+UNION ALL
+SELECT deleted_at
+FROM tsg_group_tombstones
+WHERE id = $1 AND account_id = $2
+LIMIT 1;`
+
+	var deletedAt pgtype.Timestamp
+
+	err := db.QueryRowEx(ctx, sqlStatement, nil, key, accountID).Scan(&deletedAt)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return deletedAt.Time, true
+}
+
 func RemoveGroup(ctx context.Context, identifier string, accountID string) error {
 	db, ok := handlers.GetDBPool(ctx)
 	if !ok {
@@ -232,7 +686,7 @@ func RemoveGroup(ctx context.Context, identifier string, accountID string) error
 
 	sqlStatement := `
 UPDATE tsg_groups
-SET archived = true, updated_at = NOW()
+SET archived = true, deleted_at = NOW(), updated_at = NOW()
 WHERE id = $1 and account_id = $2
 `
 	_, err := db.ExecEx(ctx, sqlStatement, nil, identifier, accountID)
@@ -242,3 +696,96 @@ WHERE id = $1 and account_id = $2
 
 	return nil
 }
+
+// FindDeletedGroup returns a deleted group and when it was deleted, if it
+// hasn't been purged yet.
+func FindDeletedGroup(ctx context.Context, key string, accountID string) (*ServiceGroup, time.Time, bool) {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return nil, time.Time{}, false
+	}
+
+	sqlStatement := `
+SELECT ` + groupColumns + `, COALESCE(deleted_at, updated_at)
+FROM tsg_groups
+WHERE id = $1 AND account_id = $2
+AND archived = true;`
+
+	var deletedAt pgtype.Timestamp
+
+	group, err := scanGroup(db.QueryRowEx(ctx, sqlStatement, nil, key, accountID), &deletedAt)
+	if err != nil {
+		return nil, time.Time{}, false
+	}
+
+	return group, deletedAt.Time, true
+}
+
+// RestoreGroup undoes the deletion of a group deleted since the given time,
+// returning false if there's no such group.
+func RestoreGroup(ctx context.Context, identifier string, accountID string, deletedSince time.Time) (bool, error) {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return false, handlers.ErrNoConnPool
+	}
+
+	sqlStatement := `
+UPDATE tsg_groups
+SET archived = false, deleted_at = NULL, updated_at = NOW()
+WHERE id = $1 and account_id = $2
+AND archived = true
+AND COALESCE(deleted_at, updated_at) >= $3
+`
+	tag, err := db.ExecEx(ctx, sqlStatement, nil, identifier, accountID, deletedSince)
+	if err != nil {
+		return false, err
+	}
+
+	return tag.RowsAffected() == 1, nil
+}
+
+// PurgeDeletedGroups permanently removes every group deleted before the
+// given time, returning how many were removed. A tombstone of each group,
+// holding only its ID, account and when it was deleted, is kept in
+// tsg_group_tombstones so that it's still known to have been deleted, see
+// FindGroupTombstone.
+func PurgeDeletedGroups(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return 0, handlers.ErrNoConnPool
+	}
+
+	tx, err := db.BeginEx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	sqlStatement := `
+INSERT INTO tsg_group_tombstones (id, account_id, deleted_at)
+SELECT id, account_id, COALESCE(deleted_at, updated_at)
+FROM tsg_groups
+WHERE archived = true
+AND COALESCE(deleted_at, updated_at) < $1
+ON CONFLICT (id) DO NOTHING;
+`
+	if _, err := tx.ExecEx(ctx, sqlStatement, nil, deletedBefore); err != nil {
+		return 0, err
+	}
+
+	sqlStatement = `
+DELETE FROM tsg_groups
+WHERE archived = true
+AND COALESCE(deleted_at, updated_at) < $1
+`
+	tag, err := tx.ExecEx(ctx, sqlStatement, nil, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := tx.CommitEx(ctx); err != nil {
+		return 0, err
+	}
+
+	return tag.RowsAffected(), nil
+}
@@ -0,0 +1,342 @@
+package groups_v1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/joyent/triton-service-groups/accounts"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/joyent/triton-service-groups/testutils"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetDeletedGroup(t *testing.T) {
+	const (
+		accountID = "6d6f3c38-2ed5-4b7a-a0ad-8a2caa32bbb4"
+		deletedID = "722d25ed-f32a-4944-9861-8990e204850e"
+		missingID = "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5"
+	)
+
+	defer func(f func(ctx context.Context, key, accountID string) (time.Time, bool)) {
+		findGroupTombstone = f
+	}(findGroupTombstone)
+	findGroupTombstone = func(ctx context.Context, key, id string) (time.Time, bool) {
+		assert.Equal(t, accountID, id)
+		if key == deletedID {
+			return time.Date(2018, 4, 14, 15, 4, 5, 0, time.UTC), true
+		}
+		return time.Time{}, false
+	}
+
+	get := func(groupID string) *httptest.ResponseRecorder {
+		ctx := handlers.WithAuthSession(context.Background(), &auth.Session{AccountID: accountID})
+		r := httptest.NewRequest(http.MethodGet, "/v1/tsg/groups/"+groupID, nil).WithContext(ctx)
+		r = mux.SetURLVars(r, map[string]string{"identifier": groupID})
+
+		w := httptest.NewRecorder()
+		Get(w, r)
+		return w
+	}
+
+	w := get(missingID)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = get(deletedID)
+	assert.Equal(t, http.StatusGone, w.Code)
+	assert.Equal(t, "group "+deletedID+" was deleted at 2018-04-14T15:04:05Z\n", w.Body.String())
+}
+
+func TestJobSpecDryRun(t *testing.T) {
+	const groupID = "722d25ed-f32a-4944-9861-8990e204850e"
+
+	defer func(f func(ctx context.Context, key, accountID string) (time.Time, bool)) {
+		findGroupTombstone = f
+	}(findGroupTombstone)
+	findGroupTombstone = func(ctx context.Context, key, id string) (time.Time, bool) {
+		return time.Time{}, false
+	}
+
+	get := func(query string) *httptest.ResponseRecorder {
+		ctx := handlers.WithAuthSession(context.Background(), &auth.Session{AccountID: "6d6f3c38-2ed5-4b7a-a0ad-8a2caa32bbb4"})
+		r := httptest.NewRequest(http.MethodGet, "/v1/tsg/groups/"+groupID+"/jobspec"+query, nil).WithContext(ctx)
+		r = mux.SetURLVars(r, map[string]string{"identifier": groupID})
+
+		w := httptest.NewRecorder()
+		JobSpec(w, r)
+		return w
+	}
+
+	w := get("?dry_run=maybe")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "dry_run must be a boolean\n", w.Body.String())
+
+	w = get("?dry_run=false")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "jobspec can only be rendered as a dry run\n", w.Body.String())
+
+	assert.Equal(t, http.StatusNotFound, get("?dry_run=true").Code)
+	assert.Equal(t, http.StatusNotFound, get("").Code)
+}
+
+func TestRejectedGroupNotSaved(t *testing.T) {
+	if os.Getenv("TSG_TEST") == "" {
+		t.Skip("Acceptance tests skipped unless env 'TSG_TEST=1' set")
+		return
+	}
+
+	db, err := testutils.NewTestDB()
+	if err != nil {
+		t.Error(err)
+	}
+	db.Clear(t)
+	defer db.Clear(t)
+
+	account := accounts.New(accounts.NewStore(db.Conn))
+	account.AccountName = "baconuser"
+	account.TritonUUID = "f5435e8b-70b8-4e4d-8c59-1dbe5d100b5b"
+	require.NoError(t, account.Insert(context.Background()))
+
+	ctx := handlers.WithDBPool(context.Background(), db.Conn)
+	ctx = handlers.WithAuthSession(ctx, &auth.Session{AccountID: account.ID, Datacenter: "us-east-1"})
+
+	require.NoError(t, templates_v1.SaveTemplate(ctx, account.ID, &templates_v1.InstanceTemplate{
+		TemplateName: "web",
+		Package:      "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+		ImageID:      testImageID,
+	}))
+	tmpl, ok := templates_v1.FindTemplateByName(ctx, "web", account.ID)
+	require.True(t, ok)
+
+	require.NoError(t, SaveGroup(ctx, account.ID, &ServiceGroup{GroupName: "api", TemplateID: tmpl.ID, Capacity: 1}))
+	existing, ok := FindGroupByName(ctx, "api", account.ID)
+	require.True(t, ok)
+
+	// The template's image has since been deleted.
+	defer viper.Reset()
+	viper.Set(config.KeyTritonCheckImages, true)
+
+	defer func(lookup func(ctx context.Context, accountID, tritonURL, imageID string) (bool, error)) {
+		lookupImage = lookup
+	}(lookupImage)
+	lookupImage = func(ctx context.Context, accountID, tritonURL, imageID string) (bool, error) {
+		return false, nil
+	}
+
+	defer func(cache *existsCache) { imageExistsCache = cache }(imageExistsCache)
+	imageExistsCache = newExistsCache("image", imageCacheSize, config.GetImageCacheTTL)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/v1/tsg/groups",
+		strings.NewReader(`{"group_name": "web", "template_id": "`+tmpl.ID+`", "capacity": 1}`))
+	create(w, r.WithContext(ctx))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	_, ok = FindGroupByName(ctx, "web", account.ID)
+	assert.False(t, ok, "a rejected group shouldn't be saved")
+
+	w = httptest.NewRecorder()
+	r = httptest.NewRequest(http.MethodPut, "/v1/tsg/groups/"+existing.ID,
+		strings.NewReader(`{"group_name": "api", "template_id": "`+tmpl.ID+`", "capacity": 3}`))
+	r = mux.SetURLVars(r.WithContext(ctx), map[string]string{"identifier": existing.ID})
+	Update(w, r)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	group, ok := FindGroupByID(ctx, existing.ID, account.ID)
+	require.True(t, ok)
+	assert.Equal(t, 1, group.Capacity, "a rejected update shouldn't be saved")
+}
+
+func TestUnknownNetworksGroupNotSaved(t *testing.T) {
+	if os.Getenv("TSG_TEST") == "" {
+		t.Skip("Acceptance tests skipped unless env 'TSG_TEST=1' set")
+		return
+	}
+
+	db, err := testutils.NewTestDB()
+	if err != nil {
+		t.Error(err)
+	}
+	db.Clear(t)
+	defer db.Clear(t)
+
+	account := accounts.New(accounts.NewStore(db.Conn))
+	account.AccountName = "baconuser"
+	account.TritonUUID = "f5435e8b-70b8-4e4d-8c59-1dbe5d100b5b"
+	require.NoError(t, account.Insert(context.Background()))
+
+	ctx := handlers.WithDBPool(context.Background(), db.Conn)
+	ctx = handlers.WithAuthSession(ctx, &auth.Session{AccountID: account.ID, Datacenter: "us-east-1"})
+
+	require.NoError(t, templates_v1.SaveTemplate(ctx, account.ID, &templates_v1.InstanceTemplate{
+		TemplateName: "web",
+		Package:      "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+		ImageID:      testImageID,
+		Networks:     []string{"f7ed95d3-faaf-43ef-9346-15644b5b3f3c"},
+	}))
+	tmpl, ok := templates_v1.FindTemplateByName(ctx, "web", account.ID)
+	require.True(t, ok)
+
+	defer viper.Reset()
+	viper.Set(config.KeyTritonCheckNetworks, true)
+
+	defer func(lookup func(ctx context.Context, accountID, tritonURL, networkID string) (bool, error)) {
+		lookupNetwork = lookup
+	}(lookupNetwork)
+	lookupNetwork = func(ctx context.Context, accountID, tritonURL, networkID string) (bool, error) {
+		return false, nil
+	}
+
+	defer func(cache *existsCache) { networkExistsCache = cache }(networkExistsCache)
+	networkExistsCache = newExistsCache("network", networkCacheSize, config.GetNetworkCacheTTL)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/v1/tsg/groups",
+		strings.NewReader(`{"group_name": "web", "template_id": "`+tmpl.ID+`", "capacity": 1}`))
+	create(w, r.WithContext(ctx))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	_, ok = FindGroupByName(ctx, "web", account.ID)
+	assert.False(t, ok, "a rejected group shouldn't be saved")
+}
+
+func TestMissingTagsGroupNotSaved(t *testing.T) {
+	if os.Getenv("TSG_TEST") == "" {
+		t.Skip("Acceptance tests skipped unless env 'TSG_TEST=1' set")
+		return
+	}
+
+	db, err := testutils.NewTestDB()
+	if err != nil {
+		t.Error(err)
+	}
+	db.Clear(t)
+	defer db.Clear(t)
+
+	account := accounts.New(accounts.NewStore(db.Conn))
+	account.AccountName = "baconuser"
+	account.TritonUUID = "f5435e8b-70b8-4e4d-8c59-1dbe5d100b5b"
+	require.NoError(t, account.Insert(context.Background()))
+
+	ctx := handlers.WithDBPool(context.Background(), db.Conn)
+	ctx = handlers.WithAuthSession(ctx, &auth.Session{AccountID: account.ID, Datacenter: "us-east-1"})
+
+	require.NoError(t, templates_v1.SaveTemplate(ctx, account.ID, &templates_v1.InstanceTemplate{
+		TemplateName: "web",
+		Package:      "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+		ImageID:      testImageID,
+	}))
+	tmpl, ok := templates_v1.FindTemplateByName(ctx, "web", account.ID)
+	require.True(t, ok)
+
+	// The template was saved before the tag was required.
+	defer viper.Reset()
+	viper.Set(config.KeyTagsRequired, []string{"owner"})
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/v1/tsg/groups",
+		strings.NewReader(`{"group_name": "web", "template_id": "`+tmpl.ID+`", "capacity": 1}`))
+	create(w, r.WithContext(ctx))
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	_, ok = FindGroupByName(ctx, "web", account.ID)
+	assert.False(t, ok, "a rejected group shouldn't be saved")
+}
+
+func TestInvalidTritonIDGroupNotSaved(t *testing.T) {
+	if os.Getenv("TSG_TEST") == "" {
+		t.Skip("Acceptance tests skipped unless env 'TSG_TEST=1' set")
+		return
+	}
+
+	db, err := testutils.NewTestDB()
+	if err != nil {
+		t.Error(err)
+	}
+	db.Clear(t)
+	defer db.Clear(t)
+
+	account := accounts.New(accounts.NewStore(db.Conn))
+	account.AccountName = "baconuser"
+	account.TritonUUID = "f5435e8b-70b8-4e4d-8c59-1dbe5d100b5b"
+	require.NoError(t, account.Insert(context.Background()))
+
+	ctx := handlers.WithDBPool(context.Background(), db.Conn)
+	ctx = handlers.WithAuthSession(ctx, &auth.Session{AccountID: account.ID, Datacenter: "us-east-1"})
+
+	// The template was saved before its package was validated.
+	require.NoError(t, templates_v1.SaveTemplate(ctx, account.ID, &templates_v1.InstanceTemplate{
+		TemplateName: "web",
+		Package:      "g4-highcpu-1G",
+		ImageID:      testImageID,
+	}))
+	tmpl, ok := templates_v1.FindTemplateByName(ctx, "web", account.ID)
+	require.True(t, ok)
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/v1/tsg/groups",
+		strings.NewReader(`{"group_name": "web", "template_id": "`+tmpl.ID+`", "capacity": 1}`))
+	create(w, r.WithContext(ctx))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "package \"g4-highcpu-1G\" is not a valid Triton UUID\n", w.Body.String())
+
+	_, ok = FindGroupByName(ctx, "web", account.ID)
+	assert.False(t, ok, "a rejected group shouldn't be saved")
+}
+
+func TestStatusWithoutNomadUnavailable(t *testing.T) {
+	if os.Getenv("TSG_TEST") == "" {
+		t.Skip("Acceptance tests skipped unless env 'TSG_TEST=1' set")
+		return
+	}
+
+	db, err := testutils.NewTestDB()
+	if err != nil {
+		t.Error(err)
+	}
+	db.Clear(t)
+	defer db.Clear(t)
+
+	account := accounts.New(accounts.NewStore(db.Conn))
+	account.AccountName = "baconuser"
+	account.TritonUUID = "f5435e8b-70b8-4e4d-8c59-1dbe5d100b5b"
+	require.NoError(t, account.Insert(context.Background()))
+
+	// No Nomad client is configured for the request.
+	ctx := handlers.WithDBPool(context.Background(), db.Conn)
+	ctx = handlers.WithAuthSession(ctx, &auth.Session{AccountID: account.ID, Datacenter: "us-east-1"})
+
+	require.NoError(t, templates_v1.SaveTemplate(ctx, account.ID, &templates_v1.InstanceTemplate{
+		TemplateName: "web",
+		Package:      "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+		ImageID:      testImageID,
+	}))
+	tmpl, ok := templates_v1.FindTemplateByName(ctx, "web", account.ID)
+	require.True(t, ok)
+
+	require.NoError(t, SaveGroup(ctx, account.ID, &ServiceGroup{GroupName: "web", TemplateID: tmpl.ID, Capacity: 1}))
+	group, ok := FindGroupByName(ctx, "web", account.ID)
+	require.True(t, ok)
+
+	for name, handler := range map[string]http.HandlerFunc{
+		"evaluations": ListEvaluations,
+		"status":      GetStatus,
+		"job_status":  GetJobStatus,
+	} {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/v1/tsg/groups/"+group.ID+"/"+name, nil)
+		r = mux.SetURLVars(r.WithContext(ctx), map[string]string{"identifier": group.ID})
+		handler(w, r)
+		assert.Equal(t, http.StatusServiceUnavailable, w.Code, name)
+	}
+}
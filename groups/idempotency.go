@@ -0,0 +1,265 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	// idempotencyKeyHeader is sent by clients to make a create safe to
+	// retry.
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayedHeader marks a response replayed from an earlier
+	// request with the same key.
+	idempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// replayedHeaders are the response headers stored along with the response to
+// a request with an idempotency key, and replayed with it.
+var replayedHeaders = []string{"Content-Type", "Location", "ETag"}
+
+// IdempotentResponse is the response to a request with an idempotency key.
+// A response with a zero StatusCode is still being served.
+type IdempotentResponse struct {
+	RequestHash string
+	StatusCode  int
+	Headers     http.Header
+	Body        []byte
+}
+
+// withIdempotencyKey serves the first request from an account with a given
+// Idempotency-Key with h, storing its response, and replays that response to
+// later requests with the same key until it expires. Requests without a key
+// are served by h as usual.
+//
+// Responses with a 5xx status aren't stored, so such a request can be retried
+// with the same key.
+func withIdempotencyKey(w http.ResponseWriter, r *http.Request, h http.HandlerFunc) {
+	key := r.Header.Get(idempotencyKeyHeader)
+	if key == "" {
+		h(w, r)
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		http.Error(w, fmt.Sprintf("%s must be at most %d characters",
+			idempotencyKeyHeader, maxIdempotencyKeyLength), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+	hash := requestHash(r, body)
+	expiredBefore := time.Now().Add(-config.GetIdempotencyKeyTTL())
+
+	stored, claimed, err := claimIdempotencyKey(ctx, session.AccountID, key, hash, expiredBefore)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if !claimed {
+		switch {
+		case stored.RequestHash != hash:
+			http.Error(w, fmt.Sprintf("%s %q was already used for a different request",
+				idempotencyKeyHeader, key), http.StatusUnprocessableEntity)
+		case stored.StatusCode == 0:
+			http.Error(w, fmt.Sprintf("the request with %s %q is still in progress",
+				idempotencyKeyHeader, key), http.StatusConflict)
+		default:
+			writeIdempotentResponse(w, stored)
+		}
+		return
+	}
+
+	rec := &idempotentResponseWriter{ResponseWriter: w}
+	h(rec, r)
+
+	// The request's own context may be done by now, such as when the client
+	// gave up on it, but its outcome must still be recorded. Claiming the key
+	// already required the pool.
+	db, _ := handlers.GetDBPool(ctx)
+	dbCtx := handlers.WithDBPool(context.Background(), db)
+
+	if rec.status >= http.StatusInternalServerError {
+		if err := releaseIdempotencyKey(dbCtx, session.AccountID, key); err != nil {
+			log.Error().Err(err).
+				Str("account_id", session.AccountID).
+				Msg("groups: failed to release idempotency key")
+		}
+		return
+	}
+
+	resp := &IdempotentResponse{
+		RequestHash: hash,
+		StatusCode:  rec.status,
+		Headers:     make(http.Header),
+		Body:        rec.body.Bytes(),
+	}
+	for _, name := range replayedHeaders {
+		if value := w.Header().Get(name); value != "" {
+			resp.Headers.Set(name, value)
+		}
+	}
+	if err := storeIdempotentResponse(dbCtx, session.AccountID, key, resp); err != nil {
+		log.Error().Err(err).
+			Str("account_id", session.AccountID).
+			Msg("groups: failed to store idempotent response")
+	}
+}
+
+// requestHash identifies a request by its method, URL and body, so a key
+// can't be reused for a different request.
+func requestHash(r *http.Request, body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s %s\n", r.Method, r.URL.RequestURI())
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func writeIdempotentResponse(w http.ResponseWriter, resp *IdempotentResponse) {
+	for name, values := range resp.Headers {
+		for _, value := range values {
+			w.Header().Add(name, value)
+		}
+	}
+	w.Header().Set(idempotentReplayedHeader, "true")
+	w.WriteHeader(resp.StatusCode)
+	if _, err := w.Write(resp.Body); err != nil {
+		log.Printf("%v", err)
+	}
+}
+
+// idempotentResponseWriter records the status and body of a response as it's
+// written.
+type idempotentResponseWriter struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (w *idempotentResponseWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *idempotentResponseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// claimIdempotencyKey claims an idempotency key of the account for the
+// request identified by requestHash, returning true if it's unused. Otherwise
+// the response stored for the key is returned. Keys created before
+// expiredBefore are removed first, and so can be claimed again.
+func claimIdempotencyKey(ctx context.Context, accountID, key, requestHash string, expiredBefore time.Time) (*IdempotentResponse, bool, error) {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return nil, false, handlers.ErrNoConnPool
+	}
+
+	_, err := db.ExecEx(ctx, `
+DELETE FROM tsg_idempotency_keys
+WHERE account_id = $1
+AND created_at < $2;`, nil, accountID, expiredBefore)
+	if err != nil {
+		return nil, false, err
+	}
+
+	tag, err := db.ExecEx(ctx, `
+INSERT INTO tsg_idempotency_keys (account_id, idempotency_key, request_hash, created_at)
+VALUES ($1, $2, $3, NOW())
+ON CONFLICT (account_id, idempotency_key) DO NOTHING;`, nil, accountID, key, requestHash)
+	if err != nil {
+		return nil, false, err
+	}
+	if tag.RowsAffected() == 1 {
+		return nil, true, nil
+	}
+
+	var (
+		resp    IdempotentResponse
+		headers string
+	)
+	err = db.QueryRowEx(ctx, `
+SELECT request_hash, status_code, headers, COALESCE(body, b'')
+FROM tsg_idempotency_keys
+WHERE account_id = $1
+AND idempotency_key = $2;`, nil, accountID, key).Scan(&resp.RequestHash, &resp.StatusCode, &headers, &resp.Body)
+	if err != nil {
+		return nil, false, err
+	}
+	if headers != "" {
+		if err := json.Unmarshal([]byte(headers), &resp.Headers); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return &resp, false, nil
+}
+
+// storeIdempotentResponse stores the response served for a claimed
+// idempotency key.
+func storeIdempotentResponse(ctx context.Context, accountID, key string, resp *IdempotentResponse) error {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return handlers.ErrNoConnPool
+	}
+
+	headers, err := json.Marshal(resp.Headers)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.ExecEx(ctx, `
+UPDATE tsg_idempotency_keys
+SET status_code = $3, headers = $4, body = $5
+WHERE account_id = $1
+AND idempotency_key = $2;`, nil, accountID, key, resp.StatusCode, string(headers), resp.Body)
+	return err
+}
+
+// releaseIdempotencyKey removes a claimed idempotency key whose request
+// failed, so that it can be retried.
+func releaseIdempotencyKey(ctx context.Context, accountID, key string) error {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return handlers.ErrNoConnPool
+	}
+
+	_, err := db.ExecEx(ctx, `
+DELETE FROM tsg_idempotency_keys
+WHERE account_id = $1
+AND idempotency_key = $2;`, nil, accountID, key)
+	return err
+}
@@ -0,0 +1,85 @@
+package groups_v1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithIdempotencyKey(t *testing.T) {
+	var served int
+	created := func(w http.ResponseWriter, r *http.Request) {
+		served++
+		w.WriteHeader(http.StatusCreated)
+	}
+
+	serve := func(key string) *httptest.ResponseRecorder {
+		ctx := handlers.WithAuthSession(context.Background(), &auth.Session{AccountID: "6d6f3c38-2ed5-4b7a-a0ad-8a2caa32bbb4"})
+		r := httptest.NewRequest(http.MethodPost, "/v1/tsg/groups", strings.NewReader(`{}`)).WithContext(ctx)
+		if key != "" {
+			r.Header.Set(idempotencyKeyHeader, key)
+		}
+
+		w := httptest.NewRecorder()
+		withIdempotencyKey(w, r, created)
+		return w
+	}
+
+	// Requests without a key are served as usual.
+	w := serve("")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, 1, served)
+
+	w = serve(strings.Repeat("k", maxIdempotencyKeyLength+1))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "Idempotency-Key must be at most 255 characters\n", w.Body.String())
+
+	// The key can't be claimed without the database, so nothing is created.
+	w = serve("7d0b4c5e-create-web")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, handlers.ErrNoConnPool.Error()+"\n", w.Body.String())
+	assert.Equal(t, 1, served)
+}
+
+func TestRequestHash(t *testing.T) {
+	hash := func(target, body string) string {
+		r := httptest.NewRequest(http.MethodPost, target, nil)
+		return requestHash(r, []byte(body))
+	}
+
+	assert.Equal(t, hash("/v1/tsg/groups", `{"group_name": "web"}`), hash("/v1/tsg/groups", `{"group_name": "web"}`))
+	assert.NotEqual(t, hash("/v1/tsg/groups", `{"group_name": "web"}`), hash("/v1/tsg/groups", `{"group_name": "db"}`))
+	assert.NotEqual(t, hash("/v1/tsg/groups", `{}`), hash("/v1/tsg/groups?wait=true", `{}`))
+}
+
+func TestIdempotentResponseReplay(t *testing.T) {
+	w := httptest.NewRecorder()
+	rec := &idempotentResponseWriter{ResponseWriter: w}
+	rec.Header().Set("Location", "/v1/tsg/groups/722d25ed-f32a-4944-9861-8990e204850e")
+	writeJSONResponse(rec, []byte(`{"id": "722d25ed-f32a-4944-9861-8990e204850e"}`), http.StatusCreated)
+
+	assert.Equal(t, http.StatusCreated, rec.status)
+	assert.Equal(t, `{"id": "722d25ed-f32a-4944-9861-8990e204850e"}`, rec.body.String())
+
+	replay := httptest.NewRecorder()
+	writeIdempotentResponse(replay, &IdempotentResponse{
+		StatusCode: rec.status,
+		Headers: http.Header{
+			"Content-Type": {w.Header().Get("Content-Type")},
+			"Location":     {w.Header().Get("Location")},
+		},
+		Body: rec.body.Bytes(),
+	})
+
+	assert.Equal(t, http.StatusCreated, replay.Code)
+	assert.Equal(t, w.Body.String(), replay.Body.String())
+	assert.Equal(t, "application/json; charset=utf-8", replay.Header().Get("Content-Type"))
+	assert.Equal(t, "/v1/tsg/groups/722d25ed-f32a-4944-9861-8990e204850e", replay.Header().Get("Location"))
+	assert.Equal(t, "true", replay.Header().Get(idempotentReplayedHeader))
+}
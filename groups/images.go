@@ -0,0 +1,84 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/joyent/triton-go/compute"
+	tritonerrors "github.com/joyent/triton-go/errors"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/pkg/errors"
+)
+
+// ErrImageNotFound is returned when a template's image no longer exists in
+// Triton, which would otherwise only fail once tsg-cli runs.
+type ErrImageNotFound struct {
+	ImageID string
+}
+
+func (e *ErrImageNotFound) Error() string {
+	return fmt.Sprintf("image %q referenced by the template no longer exists", e.ImageID)
+}
+
+// imageCacheSize bounds the number of image lookups held in imageExistsCache.
+const imageCacheSize = 1024
+
+var imageExistsCache = newExistsCache("image", imageCacheSize, config.GetImageCacheTTL)
+
+// lookupImage reports whether an image exists in Triton as seen by the
+// account.
+var lookupImage = func(ctx context.Context, accountID, tritonURL, imageID string) (bool, error) {
+	c, err := newComputeClient(ctx, accountID, tritonURL)
+	if err != nil {
+		return false, err
+	}
+	return imageExists(ctx, c, imageID)
+}
+
+// checkImage returns an ErrImageNotFound if checking images is enabled and the
+// template's image no longer exists.
+func checkImage(ctx context.Context, t *templates_v1.InstanceTemplate) error {
+	if !config.GetCheckImages() {
+		return nil
+	}
+	if _, err := tritonID("image", t.ImageID); err != nil {
+		return err
+	}
+
+	session := handlers.GetAuthSession(ctx)
+
+	key := resourceKey{
+		tritonURL: session.TritonURL,
+		accountID: session.AccountID,
+		id:        t.ImageID,
+	}
+	exists, err := imageExistsCache.Exists(key, func() (bool, error) {
+		return lookupImage(ctx, session.AccountID, session.TritonURL, t.ImageID)
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to check template image")
+	}
+	if !exists {
+		return &ErrImageNotFound{ImageID: t.ImageID}
+	}
+
+	return nil
+}
+
+func imageExists(ctx context.Context, c *compute.ComputeClient, imageID string) (bool, error) {
+	_, err := c.Images().Get(ctx, &compute.GetImageInput{ImageID: imageID})
+	if err != nil {
+		if tritonerrors.IsResourceNotFound(err) || tritonerrors.IsStatusNotFoundCode(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,128 @@
+package groups_v1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-go"
+	"github.com/joyent/triton-go/authentication"
+	"github.com/joyent/triton-go/compute"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testImageID = "342045ce-6af1-4adf-9ef1-e5bfaf9de28c"
+
+// newTestCloudAPI serves the images of testacct, answering every image but
+// those listed as missing.
+func newTestCloudAPI(t *testing.T, missing ...string) (*compute.ComputeClient, *int) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		for _, id := range missing {
+			if r.URL.Path == "/testacct/images/"+id {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(http.StatusNotFound)
+				w.Write([]byte(`{"code": "ResourceNotFound", "message": "image not found"}`))
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": "` + testImageID + `", "name": "base-64", "state": "active"}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	signer, err := authentication.NewTestSigner()
+	require.NoError(t, err)
+
+	c, err := compute.NewClient(&triton.ClientConfig{
+		TritonURL:   srv.URL,
+		AccountName: "testacct",
+		Signers:     []authentication.Signer{signer},
+	})
+	require.NoError(t, err)
+
+	return c, &requests
+}
+
+func TestImageExists(t *testing.T) {
+	c, _ := newTestCloudAPI(t, "d3f1c7a2-5c0b-4f4e-8a62-2f1b0e9f0c11")
+
+	exists, err := imageExists(context.Background(), c, testImageID)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = imageExists(context.Background(), c, "d3f1c7a2-5c0b-4f4e-8a62-2f1b0e9f0c11")
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestCheckImage(t *testing.T) {
+	defer viper.Reset()
+
+	c, requests := newTestCloudAPI(t, testImageID)
+
+	defer func(lookup func(ctx context.Context, accountID, tritonURL, imageID string) (bool, error)) {
+		lookupImage = lookup
+	}(lookupImage)
+	lookupImage = func(ctx context.Context, accountID, tritonURL, imageID string) (bool, error) {
+		return imageExists(ctx, c, imageID)
+	}
+
+	defer func(cache *existsCache) { imageExistsCache = cache }(imageExistsCache)
+	imageExistsCache = newExistsCache("image", imageCacheSize, config.GetImageCacheTTL)
+
+	ctx := handlers.WithAuthSession(context.Background(), &auth.Session{
+		AccountID: "6d6f3c38-2ed5-4b7a-a0ad-8a2caa32bbb4",
+		TritonURL: "https://us-east-1.api.joyent.com",
+	})
+	tmpl := &templates_v1.InstanceTemplate{ImageID: testImageID}
+
+	// Disabled by default.
+	require.NoError(t, checkImage(ctx, tmpl))
+	assert.Equal(t, 0, *requests)
+
+	viper.Set(config.KeyTritonCheckImages, true)
+
+	err := checkImage(ctx, tmpl)
+	assert.Equal(t, &ErrImageNotFound{ImageID: testImageID}, err)
+	assert.EqualError(t, err, `image "342045ce-6af1-4adf-9ef1-e5bfaf9de28c" referenced by the template no longer exists`)
+	assert.Equal(t, http.StatusUnprocessableEntity, orchestratorErrorStatus(err))
+
+	// The answer is cached.
+	assert.Error(t, checkImage(ctx, tmpl))
+	assert.Equal(t, 1, *requests)
+}
+
+func TestExistsCacheTTL(t *testing.T) {
+	now := time.Date(2018, 4, 14, 15, 0, 0, 0, time.UTC)
+	cache := newExistsCache("image", imageCacheSize, func() time.Duration { return time.Minute })
+	cache.now = func() time.Time { return now }
+
+	var lookups int
+	lookup := func() (bool, error) {
+		lookups++
+		return true, nil
+	}
+
+	key := resourceKey{id: testImageID}
+	for i := 0; i < 3; i++ {
+		exists, err := cache.Exists(key, lookup)
+		require.NoError(t, err)
+		assert.True(t, exists)
+	}
+	assert.Equal(t, 1, lookups)
+
+	now = now.Add(time.Minute)
+	_, err := cache.Exists(key, lookup)
+	require.NoError(t, err)
+	assert.Equal(t, 2, lookups)
+}
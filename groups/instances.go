@@ -0,0 +1,177 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"time"
+
+	"github.com/joyent/triton-go"
+	"github.com/joyent/triton-go/authentication"
+	"github.com/joyent/triton-go/compute"
+	"github.com/joyent/triton-service-groups/accounts"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/pkg/errors"
+)
+
+// Instance is a compute instance running as part of a service group. It
+// mirrors the CloudAPI machine object with consistent field naming.
+type Instance struct {
+	ID                 string                 `json:"id"`
+	Name               string                 `json:"name"`
+	Type               string                 `json:"type"`
+	Brand              string                 `json:"brand"`
+	State              string                 `json:"state"`
+	Image              string                 `json:"image"`
+	Memory             int                    `json:"memory"`
+	Disk               int                    `json:"disk"`
+	Metadata           map[string]string      `json:"metadata"`
+	Tags               map[string]interface{} `json:"tags"`
+	Created            time.Time              `json:"created"`
+	Updated            time.Time              `json:"updated"`
+	Docker             bool                   `json:"docker"`
+	IPs                []string               `json:"ips"`
+	Networks           []string               `json:"networks"`
+	PrimaryIP          string                 `json:"primary_ip"`
+	FirewallEnabled    bool                   `json:"firewall_enabled"`
+	ComputeNode        string                 `json:"compute_node"`
+	Package            string                 `json:"package"`
+	DomainNames        []string               `json:"dns_names"`
+	DeletionProtection bool                   `json:"deletion_protection"`
+	CNS                InstanceCNS            `json:"cns"`
+}
+
+// InstanceCNS is the Triton CNS configuration of an instance.
+type InstanceCNS struct {
+	Disable    bool     `json:"disable"`
+	ReversePTR string   `json:"reverse_ptr"`
+	Services   []string `json:"services"`
+}
+
+func newInstances(instances []*compute.Instance) []*Instance {
+	result := make([]*Instance, 0, len(instances))
+	for _, i := range instances {
+		result = append(result, &Instance{
+			ID:                 i.ID,
+			Name:               i.Name,
+			Type:               i.Type,
+			Brand:              i.Brand,
+			State:              i.State,
+			Image:              i.Image,
+			Memory:             i.Memory,
+			Disk:               i.Disk,
+			Metadata:           i.Metadata,
+			Tags:               i.Tags,
+			Created:            i.Created,
+			Updated:            i.Updated,
+			Docker:             i.Docker,
+			IPs:                i.IPs,
+			Networks:           i.Networks,
+			PrimaryIP:          i.PrimaryIP,
+			FirewallEnabled:    i.FirewallEnabled,
+			ComputeNode:        i.ComputeNode,
+			Package:            i.Package,
+			DomainNames:        i.DomainNames,
+			DeletionProtection: i.DeletionProtection,
+			CNS: InstanceCNS{
+				Disable:    i.CNS.Disable,
+				ReversePTR: i.CNS.ReversePTR,
+				Services:   i.CNS.Services,
+			},
+		})
+	}
+	return result
+}
+
+// listGroupInstances lists the compute instances of a group using the Triton
+// credentials of its account.
+func listGroupInstances(ctx context.Context, accountID, tritonURL string, group *ServiceGroup) ([]*compute.Instance, error) {
+	c, err := newComputeClient(ctx, accountID, tritonURL)
+	if err != nil {
+		return nil, err
+	}
+
+	params := &compute.ListInstancesInput{}
+	t := make(map[string]interface{}, 0)
+	t["tsg.name"] = group.GroupName
+	params.Tags = t
+
+	instances, err := c.Instances().List(ctx, params)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error listing instances in TSG")
+	}
+
+	return instances, nil
+}
+
+// newComputeClient constructs a CloudAPI client using the Triton credentials
+// of the account.
+func newComputeClient(ctx context.Context, accountID, tritonURL string) (*compute.ComputeClient, error) {
+	config, err := newTritonConfig(ctx, accountID, tritonURL)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := compute.NewClient(config)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error constructing ComputeClient")
+	}
+
+	return c, nil
+}
+
+// newTritonConfig returns the client configuration for acting as the account
+// against the CloudAPI at tritonURL.
+func newTritonConfig(ctx context.Context, accountID, tritonURL string) (*triton.ClientConfig, error) {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return nil, handlers.ErrNoConnPool
+	}
+	store := accounts.NewStore(db)
+	account, err := store.FindByID(ctx, accountID)
+	if err != nil {
+		return nil, err
+	}
+
+	credential, err := account.GetTritonCredential(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	input := authentication.PrivateKeySignerInput{
+		KeyID:              credential.KeyID,
+		PrivateKeyMaterial: []byte(credential.KeyMaterial),
+		AccountName:        credential.AccountName,
+	}
+	signer, err := authentication.NewPrivateKeySigner(input)
+	if err != nil {
+		return nil, errors.Wrapf(err, "error Creating SSH Private Key Signer")
+	}
+
+	return &triton.ClientConfig{
+		TritonURL:   tritonURL,
+		AccountName: credential.AccountName,
+		Signers:     []authentication.Signer{signer},
+	}, nil
+}
+
+// countRunningInstances counts the instances of a managed group which are
+// running.
+func countRunningInstances(ctx context.Context, tritonURL string, group *ManagedGroup) (int, error) {
+	instances, err := listGroupInstances(ctx, group.AccountID, tritonURL, group.ServiceGroup)
+	if err != nil {
+		return 0, err
+	}
+
+	var running int
+	for _, instance := range instances {
+		if instance.State == "running" {
+			running++
+		}
+	}
+
+	return running, nil
+}
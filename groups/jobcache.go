@@ -0,0 +1,124 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	lru "github.com/hashicorp/golang-lru"
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+)
+
+// jobCacheSize bounds the number of jobs held by the job info cache.
+const jobCacheSize = 1024
+
+// jobInfoCache is shared across every request served by this process.
+var jobInfoCache = newJobCache(jobCacheSize, config.GetJobCacheTTL)
+
+// getJobInfo returns a job's info, by way of the cache, for the datacenter and
+// Nomad scope of the current session.
+func getJobInfo(ctx context.Context, client *nomad.Client, jobID string) (*nomad.Job, error) {
+	return jobInfoCache.Info(client, handlers.GetAuthSession(ctx).Datacenter, nomadScopeOf(ctx), jobID)
+}
+
+// invalidateJobInfo drops the cached info and status of a job in the
+// datacenter and Nomad scope of the current session.
+func invalidateJobInfo(ctx context.Context, jobID string) {
+	datacenter := handlers.GetAuthSession(ctx).Datacenter
+	jobInfoCache.Invalidate(datacenter, nomadScopeOf(ctx), jobID)
+	Statuses.Invalidate(datacenter, nomadScopeOf(ctx), jobID)
+}
+
+// jobCacheKey identifies a job. Job IDs are only unique within a namespace
+// and region.
+type jobCacheKey struct {
+	datacenter string
+	scope      nomadScope
+	jobID      string
+}
+
+type jobCacheEntry struct {
+	job     *nomad.Job
+	fetched time.Time
+}
+
+// jobCache is a short lived read-through cache of Nomad job info. Jobs
+// returned from the cache are shared and must not be modified.
+type jobCache struct {
+	entries *lru.Cache
+	ttl     func() time.Duration
+	now     func() time.Time
+
+	// generation is bumped on every invalidation so that a lookup which
+	// raced with an invalidation doesn't repopulate the cache with stale info.
+	mu         sync.Mutex
+	generation uint64
+}
+
+func newJobCache(size int, ttl func() time.Duration) *jobCache {
+	entries, err := lru.New(size)
+	if err != nil {
+		panic(err)
+	}
+
+	return &jobCache{
+		entries: entries,
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// Info returns the job from the cache if it was fetched within the TTL,
+// otherwise it is fetched from Nomad. Errors are never cached.
+func (c *jobCache) Info(client *nomad.Client, datacenter string, scope nomadScope, jobID string) (*nomad.Job, error) {
+	key := jobCacheKey{datacenter, scope, jobID}
+	ttl := c.ttl()
+
+	if ttl > 0 {
+		if value, ok := c.entries.Get(key); ok {
+			entry := value.(jobCacheEntry)
+			if c.now().Sub(entry.fetched) < ttl {
+				metrics.IncrCounter([]string{"nomad", "job_cache", "hit"}, 1)
+				return entry.job, nil
+			}
+			c.entries.Remove(key)
+		}
+	}
+	metrics.IncrCounter([]string{"nomad", "job_cache", "miss"}, 1)
+
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+
+	job, _, err := client.Jobs().Info(jobID, scope.queryOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	if ttl > 0 {
+		c.mu.Lock()
+		if generation == c.generation {
+			c.entries.Add(key, jobCacheEntry{job: job, fetched: c.now()})
+		}
+		c.mu.Unlock()
+	}
+
+	return job, nil
+}
+
+// Invalidate drops the cached info of a job, which must be done whenever the
+// job is changed.
+func (c *jobCache) Invalidate(datacenter string, scope nomadScope, jobID string) {
+	c.mu.Lock()
+	c.generation++
+	c.entries.Remove(jobCacheKey{datacenter, scope, jobID})
+	c.mu.Unlock()
+}
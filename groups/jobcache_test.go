@@ -0,0 +1,119 @@
+package groups_v1
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/helper"
+	"github.com/joyent/triton-service-groups/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobCache(t *testing.T) {
+	const (
+		dc    = "us-east-1"
+		jobID = "jolly-jelly_c2e4d1491ce423e3"
+	)
+	scope := nomadScope{Namespace: "default"}
+
+	fake := testutils.NewFakeNomad(t)
+	defer fake.Close()
+
+	var (
+		mu      sync.Mutex
+		lookups int
+		query   url.Values
+	)
+	fake.HandleFunc("/v1/job/"+jobID, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		lookups++
+		query = r.URL.Query()
+		mu.Unlock()
+		testutils.WriteJSON(w, &nomad.Job{ID: helper.StringToPtr(jobID)})
+	})
+	calls := func() int {
+		mu.Lock()
+		defer mu.Unlock()
+		return lookups
+	}
+
+	now := time.Now()
+	cache := newJobCache(16, func() time.Duration { return 5 * time.Second })
+	cache.now = func() time.Time { return now }
+
+	t.Run("hit", func(t *testing.T) {
+		job, err := cache.Info(fake.Client, dc, scope, jobID)
+		require.NoError(t, err)
+		assert.Equal(t, jobID, *job.ID)
+		assert.Equal(t, 1, calls())
+
+		_, err = cache.Info(fake.Client, dc, scope, jobID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, calls())
+
+		// keyed by datacenter, namespace and region as well as job ID
+		_, err = cache.Info(fake.Client, "us-west-1", scope, jobID)
+		require.NoError(t, err)
+		assert.Equal(t, 2, calls())
+
+		_, err = cache.Info(fake.Client, dc, nomadScope{Namespace: "tsg"}, jobID)
+		require.NoError(t, err)
+		assert.Equal(t, 3, calls())
+
+		_, err = cache.Info(fake.Client, dc, nomadScope{Namespace: "tsg", Region: "eu"}, jobID)
+		require.NoError(t, err)
+		assert.Equal(t, 4, calls())
+		mu.Lock()
+		assert.Equal(t, "tsg", query.Get("namespace"))
+		assert.Equal(t, "eu", query.Get("region"))
+		mu.Unlock()
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		now = now.Add(6 * time.Second)
+
+		_, err := cache.Info(fake.Client, dc, scope, jobID)
+		require.NoError(t, err)
+		assert.Equal(t, 5, calls())
+
+		_, err = cache.Info(fake.Client, dc, scope, jobID)
+		require.NoError(t, err)
+		assert.Equal(t, 5, calls())
+	})
+
+	t.Run("invalidation", func(t *testing.T) {
+		cache.Invalidate(dc, scope, jobID)
+
+		_, err := cache.Info(fake.Client, dc, scope, jobID)
+		require.NoError(t, err)
+		assert.Equal(t, 6, calls())
+	})
+
+	t.Run("errors aren't cached", func(t *testing.T) {
+		_, err := cache.Info(fake.Client, dc, scope, "missing")
+		assert.Error(t, err)
+		_, err = cache.Info(fake.Client, dc, scope, "missing")
+		assert.Error(t, err)
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 20; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				if i%5 == 0 {
+					cache.Invalidate(dc, scope, jobID)
+				}
+				_, err := cache.Info(fake.Client, dc, scope, jobID)
+				assert.NoError(t, err)
+			}(i)
+		}
+		wg.Wait()
+	})
+}
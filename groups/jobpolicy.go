@@ -0,0 +1,143 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/joyent/triton-service-groups/config"
+)
+
+// RestartPolicy overrides how Nomad restarts the failed tsg-cli task of a
+// group's job. Unset fields fall back to the server's restart policy.
+type RestartPolicy struct {
+	Attempts        *int `json:"attempts,omitempty"`
+	IntervalSeconds *int `json:"interval_seconds,omitempty"`
+	DelaySeconds    *int `json:"delay_seconds,omitempty"`
+	// Mode is either "fail" or "delay".
+	Mode string `json:"mode,omitempty"`
+}
+
+func (p *RestartPolicy) validate() error {
+	if isNegative(p.Attempts) {
+		return errors.New("restart attempts cannot be a negative number")
+	}
+	if isNegative(p.IntervalSeconds) || isNegative(p.DelaySeconds) {
+		return errors.New("restart interval and delay cannot be negative numbers")
+	}
+	return config.ValidateRestartMode(p.Mode)
+}
+
+// ReschedulePolicy overrides how Nomad reschedules the failed allocation of a
+// group's job. Unset fields fall back to the server's reschedule policy.
+type ReschedulePolicy struct {
+	Attempts        *int `json:"attempts,omitempty"`
+	IntervalSeconds *int `json:"interval_seconds,omitempty"`
+}
+
+func (p *ReschedulePolicy) validate() error {
+	if isNegative(p.Attempts) {
+		return errors.New("reschedule attempts cannot be a negative number")
+	}
+	if isNegative(p.IntervalSeconds) {
+		return errors.New("reschedule interval cannot be a negative number")
+	}
+	return nil
+}
+
+// restartPolicy returns the restart policy of the group's job, the server's
+// with the group's overrides applied.
+func restartPolicy(group *ServiceGroup) (config.RestartPolicy, error) {
+	policy, err := config.GetRestartPolicy()
+	if err != nil {
+		return policy, err
+	}
+
+	if override := group.Restart; override != nil {
+		if override.Attempts != nil {
+			policy.Attempts = override.Attempts
+		}
+		if override.IntervalSeconds != nil {
+			policy.Interval = seconds(*override.IntervalSeconds)
+		}
+		if override.DelaySeconds != nil {
+			policy.Delay = seconds(*override.DelaySeconds)
+		}
+		if override.Mode != "" {
+			policy.Mode = override.Mode
+		}
+	}
+	return policy, nil
+}
+
+// reschedulePolicy returns the reschedule policy of the group's job, the
+// server's with the group's overrides applied.
+func reschedulePolicy(group *ServiceGroup) (config.ReschedulePolicy, error) {
+	policy, err := config.GetReschedulePolicy()
+	if err != nil {
+		return policy, err
+	}
+
+	if override := group.Reschedule; override != nil {
+		if override.Attempts != nil {
+			policy.Attempts = override.Attempts
+		}
+		if override.IntervalSeconds != nil {
+			policy.Interval = seconds(*override.IntervalSeconds)
+		}
+	}
+	return policy, nil
+}
+
+// jobPolicies are the policy overrides of a group as they're stored.
+type jobPolicies struct {
+	Restart    *RestartPolicy    `json:"restart,omitempty"`
+	Reschedule *ReschedulePolicy `json:"reschedule,omitempty"`
+}
+
+func encodeJobPolicies(group *ServiceGroup) (string, error) {
+	if group.Restart == nil && group.Reschedule == nil {
+		return "", nil
+	}
+
+	bytes, err := json.Marshal(jobPolicies{
+		Restart:    group.Restart,
+		Reschedule: group.Reschedule,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+func decodeJobPolicies(data string, group *ServiceGroup) error {
+	if data == "" {
+		return nil
+	}
+
+	var policies jobPolicies
+	if err := json.Unmarshal([]byte(data), &policies); err != nil {
+		return err
+	}
+	group.Restart = policies.Restart
+	group.Reschedule = policies.Reschedule
+	return nil
+}
+
+func isNegative(n *int) bool {
+	return n != nil && *n < 0
+}
+
+func seconds(n int) *time.Duration {
+	d := time.Duration(n) * time.Second
+	return &d
+}
+
+func intPtr(n int) *int {
+	return &n
+}
@@ -0,0 +1,111 @@
+package groups_v1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobPolicies(t *testing.T) {
+	defer viper.Reset()
+
+	// Nothing configured leaves both policies to Nomad.
+	spec, err := renderJobSpec(testJobDetails(nil))
+	require.NoError(t, err)
+	assert.NotContains(t, spec, "restart {")
+	assert.NotContains(t, spec, "reschedule {")
+
+	viper.Set(config.KeyNomadRestartAttempts, 2)
+	viper.Set(config.KeyNomadRestartInterval, "30m")
+	viper.Set(config.KeyNomadRestartDelay, "15s")
+	viper.Set(config.KeyNomadRestartMode, "delay")
+	viper.Set(config.KeyNomadRescheduleAttempts, 1)
+	viper.Set(config.KeyNomadRescheduleInterval, "1h")
+
+	job, err := buildJob(testJobDetails(nil))
+	require.NoError(t, err)
+	restart := job.TaskGroups[0].RestartPolicy
+	require.NotNil(t, restart)
+	assert.Equal(t, 2, *restart.Attempts)
+	assert.Equal(t, 30*time.Minute, *restart.Interval)
+	assert.Equal(t, 15*time.Second, *restart.Delay)
+	assert.Equal(t, "delay", *restart.Mode)
+	reschedule := job.TaskGroups[0].ReschedulePolicy
+	require.NotNil(t, reschedule)
+	assert.Equal(t, 1, *reschedule.Attempts)
+	assert.Equal(t, time.Hour, *reschedule.Interval)
+
+	// A group's overrides replace only the fields they set.
+	restartP, err := restartPolicy(&ServiceGroup{
+		Restart: &RestartPolicy{Attempts: intPtr(0), Mode: "fail"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 0, *restartP.Attempts)
+	assert.Equal(t, 30*time.Minute, *restartP.Interval)
+	assert.Equal(t, 15*time.Second, *restartP.Delay)
+	assert.Equal(t, "fail", restartP.Mode)
+
+	rescheduleP, err := reschedulePolicy(&ServiceGroup{
+		Reschedule: &ReschedulePolicy{IntervalSeconds: intPtr(600)},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 1, *rescheduleP.Attempts)
+	assert.Equal(t, 10*time.Minute, *rescheduleP.Interval)
+
+	viper.Set(config.KeyNomadRestartAttempts, -1)
+	_, err = createJobDetails(&templates_v1.InstanceTemplate{
+		Package: "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+		ImageID: "342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+	}, &ServiceGroup{GroupName: "web"})
+	assert.EqualError(t, err, "nomad.restart.attempts must be a non-negative integer")
+}
+
+func TestDecodeGroupJobPolicies(t *testing.T) {
+	group, err := decodeGroupResponseBodyAndValidate([]byte(`{
+		"group_name": "web",
+		"template_id": "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+		"capacity": 2,
+		"restart": {"attempts": 3, "delay_seconds": 30, "mode": "delay"},
+		"reschedule": {"attempts": 0}
+	}`))
+	require.NoError(t, err)
+	assert.Equal(t, &RestartPolicy{Attempts: intPtr(3), DelaySeconds: intPtr(30), Mode: "delay"}, group.Restart)
+	assert.Equal(t, &ReschedulePolicy{Attempts: intPtr(0)}, group.Reschedule)
+
+	_, err = decodeGroupResponseBodyAndValidate([]byte(`{
+		"template_id": "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+		"restart": {"interval_seconds": -60}
+	}`))
+	assert.EqualError(t, err, "restart interval and delay cannot be negative numbers")
+
+	_, err = decodeGroupResponseBodyAndValidate([]byte(`{
+		"template_id": "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+		"restart": {"mode": "retry"}
+	}`))
+	assert.EqualError(t, err, `restart mode must be "fail" or "delay"`)
+
+	_, err = decodeGroupResponseBodyAndValidate([]byte(`{
+		"template_id": "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+		"reschedule": {"attempts": -1}
+	}`))
+	assert.EqualError(t, err, "reschedule attempts cannot be a negative number")
+
+	data, err := encodeJobPolicies(group)
+	require.NoError(t, err)
+	assert.Equal(t, `{"restart":{"attempts":3,"delay_seconds":30,"mode":"delay"},"reschedule":{"attempts":0}}`, data)
+
+	var decoded ServiceGroup
+	require.NoError(t, decodeJobPolicies(data, &decoded))
+	assert.Equal(t, group.Restart, decoded.Restart)
+	assert.Equal(t, group.Reschedule, decoded.Reschedule)
+
+	data, err = encodeJobPolicies(&ServiceGroup{})
+	require.NoError(t, err)
+	assert.Empty(t, data)
+	require.NoError(t, decodeJobPolicies("", &decoded))
+}
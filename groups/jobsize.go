@@ -0,0 +1,75 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/joyent/triton-service-groups/config"
+)
+
+// ErrJobTooLarge is returned when a group's rendered job spec is larger than
+// Nomad is configured to accept.
+type ErrJobTooLarge struct {
+	Size  int
+	Limit int
+	// Field is the template field contributing most to the spec, if any.
+	Field string
+}
+
+func (e *ErrJobTooLarge) Error() string {
+	msg := fmt.Sprintf("Nomad job spec is %d bytes, larger than the limit of %d bytes", e.Size, e.Limit)
+	if e.Field != "" {
+		msg += fmt.Sprintf(", most of which comes from the template's %s", e.Field)
+	}
+	return msg
+}
+
+func checkJobSize(details OrchestratorJob, spec string) error {
+	limit := config.GetMaxJobSize()
+	if limit <= 0 || len(spec) <= limit {
+		return nil
+	}
+
+	return &ErrJobTooLarge{
+		Size:  len(spec),
+		Limit: limit,
+		Field: largestJobField(details),
+	}
+}
+
+// largestJobField estimates how much each variable sized template field adds
+// to the rendered job and returns the name of the largest.
+func largestJobField(details OrchestratorJob) string {
+	sizes := map[string]int{
+		"userdata": len(base64Encode(details.UserData)),
+	}
+	for _, network := range details.Networks {
+		sizes["networks"] += len(network)
+	}
+	for key, value := range details.Tags {
+		sizes["tags"] += len(key) + len(value)
+	}
+	for key, value := range details.MetaData {
+		sizes["metadata"] += len(base64Encode(key + "=" + value))
+	}
+
+	fields := make([]string, 0, len(sizes))
+	for field := range sizes {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+
+	var largest string
+	for _, field := range fields {
+		if sizes[field] > sizes[largest] {
+			largest = field
+		}
+	}
+
+	return largest
+}
@@ -0,0 +1,79 @@
+package groups_v1
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testJobDetails(metadata map[string]string) OrchestratorJob {
+	tmpl := &templates_v1.InstanceTemplate{
+		ID:       "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+		Package:  "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+		ImageID:  "342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+		Networks: []string{"0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0"},
+		Tags:     map[string]string{"role": "web"},
+		UserData: "#!/bin/sh\necho hello",
+		MetaData: metadata,
+	}
+	group := &ServiceGroup{GroupName: "web", Capacity: 2}
+
+	// The values are all safe to write into the job.
+	details, _ := createJobDetails(tmpl, group)
+	details.JobName = jobName(group.GroupName, "c2e4d1491ce423e3")
+	details.Datacenters = []string{"us-sw-1"}
+	return details
+}
+
+func TestBuildJobTooLarge(t *testing.T) {
+	viper.Set(config.KeyNomadMaxJobSize, 64*1024)
+	defer viper.Set(config.KeyNomadMaxJobSize, nil)
+
+	job, err := buildJob(testJobDetails(map[string]string{"small": "value"}))
+	require.NoError(t, err)
+	assert.Equal(t, "web_c2e4d1491ce423e3", *job.ID)
+
+	metadata := map[string]string{}
+	for i := 0; i < 100; i++ {
+		metadata[fmt.Sprintf("key-%d", i)] = strings.Repeat("x", 1024)
+	}
+
+	_, err = buildJob(testJobDetails(metadata))
+	require.Error(t, err)
+
+	tooLarge, ok := err.(*ErrJobTooLarge)
+	require.True(t, ok, "expected ErrJobTooLarge, got %T", err)
+	assert.Equal(t, 64*1024, tooLarge.Limit)
+	assert.True(t, tooLarge.Size > tooLarge.Limit)
+	assert.Equal(t, "metadata", tooLarge.Field)
+	assert.Contains(t, err.Error(), "most of which comes from the template's metadata")
+	assert.Equal(t, http.StatusRequestEntityTooLarge, orchestratorErrorStatus(err))
+}
+
+func TestBuildJobSizeDisabled(t *testing.T) {
+	viper.Set(config.KeyNomadMaxJobSize, 0)
+	defer viper.Set(config.KeyNomadMaxJobSize, nil)
+
+	_, err := buildJob(testJobDetails(map[string]string{"large": strings.Repeat("x", 2<<20)}))
+	assert.NoError(t, err)
+}
+
+func TestLargestJobField(t *testing.T) {
+	details := testJobDetails(nil)
+	assert.Equal(t, "networks", largestJobField(details))
+
+	details.UserData = strings.Repeat("x", 512)
+	assert.Equal(t, "userdata", largestJobField(details))
+
+	details.Tags = map[string]string{"notes": strings.Repeat("x", 1024)}
+	assert.Equal(t, "tags", largestJobField(details))
+
+	assert.Equal(t, "", largestJobField(OrchestratorJob{}))
+}
@@ -0,0 +1,178 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"time"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/joyent/triton-service-groups/server/handlers"
+)
+
+// JobStatusNotSubmitted is the status of a group whose job isn't registered
+// with Nomad yet.
+const JobStatusNotSubmitted = "not_submitted"
+
+// JobStatus is the state of a service group's job in Nomad.
+type JobStatus struct {
+	GroupID string `json:"group_id"`
+	JobID   string `json:"job_id"`
+	// Status is the Nomad status of the job, or JobStatusNotSubmitted.
+	Status string `json:"status"`
+	Type   string `json:"type,omitempty"`
+	// LastRun is the most recent periodic run of a batch job.
+	LastRun *JobRun `json:"last_run,omitempty"`
+	// NextLaunchAt is when the next periodic run of a batch job is launched,
+	// unless its reconciles are suspended.
+	NextLaunchAt     *time.Time     `json:"next_launch_at,omitempty"`
+	LatestEvaluation *JobEvaluation `json:"latest_evaluation,omitempty"`
+	// RefreshedAt is when the status was read from Nomad. Cached is set when
+	// it was served from the status cache rather than read for the request,
+	// and Stale when the cache hasn't been refreshed for two of its intervals,
+	// such as while Nomad is unavailable.
+	RefreshedAt time.Time `json:"refreshed_at"`
+	Cached      bool      `json:"cached"`
+	Stale       bool      `json:"stale"`
+}
+
+// JobRun is a single periodic run of a service group's job.
+type JobRun struct {
+	JobID      string     `json:"job_id"`
+	Status     string     `json:"status"`
+	LaunchedAt *time.Time `json:"launched_at,omitempty"`
+}
+
+// GetOrchestratorJobStatus returns the state of a service group's job: its
+// most recent run, when it next runs and the outcome of its most recent
+// evaluation. The status is served from Statuses when it holds the job,
+// otherwise it's read from Nomad.
+func GetOrchestratorJobStatus(ctx context.Context, group *ServiceGroup) (*JobStatus, error) {
+	name, err := resolveJobName(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	datacenter := handlers.GetAuthSession(ctx).Datacenter
+	scope := nomadScopeOf(ctx)
+	if status, ok := Statuses.Get(datacenter, scope, name); ok {
+		return status, nil
+	}
+
+	client, ok := handlers.GetNomadClient(ctx)
+	if !ok {
+		return nil, handlers.ErrNoNomadClient
+	}
+
+	generation := Statuses.Generation()
+	status, job, err := getJobStatus(ctx, client, group.ID, name, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	Statuses.Set(datacenter, scope, name, status, job, generation)
+	return status, nil
+}
+
+// getJobStatus returns the status of the job named jobID as of now, along
+// with the job it describes. A job which doesn't exist is reported as
+// JobStatusNotSubmitted, without a job.
+func getJobStatus(ctx context.Context, client *nomad.Client, groupID, jobID string, now time.Time) (*JobStatus, *nomad.Job, error) {
+	status := &JobStatus{
+		GroupID:     groupID,
+		JobID:       jobID,
+		Status:      JobStatusNotSubmitted,
+		RefreshedAt: now,
+	}
+
+	job, err := getJobInfo(ctx, client, jobID)
+	if err != nil {
+		if isNotFound(err) {
+			return status, nil, nil
+		}
+		return nil, nil, &ErrNomad{Op: ErrNomadJobInfo, Err: err}
+	}
+
+	if err := describeJob(client, nomadScopeOf(ctx), status, job, now); err != nil {
+		return nil, nil, err
+	}
+	return status, job, nil
+}
+
+// describeJob fills in the status of job, in scope, as of now.
+func describeJob(client *nomad.Client, scope nomadScope, status *JobStatus, job *nomad.Job, now time.Time) error {
+	status.Status = stringValue(job.Status)
+	status.Type = stringValue(job.Type)
+
+	if job.Periodic != nil {
+		children, err := childJobs(client, scope, *job.ID)
+		if err != nil {
+			return &ErrNomad{Op: ErrNomadChildJobs, Err: err}
+		}
+		status.LastRun = lastRun(*job.ID, children)
+		status.NextLaunchAt = nextLaunch(job, now)
+	}
+
+	page, err := listEvaluations(client, scope, *job.ID, 1, 0)
+	if err != nil {
+		return err
+	}
+	if len(page.Evaluations) > 0 {
+		status.LatestEvaluation = page.Evaluations[0]
+	}
+
+	return nil
+}
+
+// lastRun returns the most recently launched periodic child of jobID, or nil
+// if it hasn't launched any which Nomad still knows about.
+func lastRun(jobID string, children []*nomad.JobListStub) *JobRun {
+	var last *JobRun
+	for _, child := range children {
+		if child.ParentID != jobID {
+			continue
+		}
+
+		launched := periodicLaunchTime(child.ID)
+		if launched == nil {
+			continue
+		}
+		if last == nil || launched.After(*last.LaunchedAt) {
+			last = &JobRun{
+				JobID:      child.ID,
+				Status:     child.Status,
+				LaunchedAt: launched,
+			}
+		}
+	}
+	return last
+}
+
+// nextLaunch returns when the periodic schedule of job next launches a run
+// after now, or nil if it's disabled.
+func nextLaunch(job *nomad.Job, now time.Time) *time.Time {
+	if !periodicEnabled(job) || job.Periodic.SpecType == nil || job.Periodic.Spec == nil {
+		return nil
+	}
+
+	loc, err := job.Periodic.GetLocation()
+	if err != nil {
+		return nil
+	}
+
+	next := job.Periodic.Next(now.In(loc))
+	if next.IsZero() {
+		return nil
+	}
+	next = next.UTC()
+	return &next
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
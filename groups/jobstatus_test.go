@@ -0,0 +1,106 @@
+package groups_v1
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/helper"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/joyent/triton-service-groups/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescribeJob(t *testing.T) {
+	const jobID = "web_c2e4d1491ce423e3"
+	now := time.Date(2018, 5, 1, 21, 20, 1, 0, time.UTC)
+
+	fake := testutils.NewFakeNomad(t)
+	defer fake.Close()
+
+	fake.HandleJSON("/v1/jobs", []*nomad.JobListStub{
+		{ID: jobID + "/periodic-1525209598", ParentID: jobID, Status: "dead"},
+		{ID: jobID + "/periodic-1525209600", ParentID: jobID, Status: "running"},
+		{ID: "web_c2e4d1491ce423e3-old/periodic-1525209700", ParentID: "web_c2e4d1491ce423e3-old"},
+	})
+	fake.HandleFunc("/v1/job/", func(w http.ResponseWriter, r *http.Request) {
+		var evals []*nomad.Evaluation
+		if r.URL.Path == "/v1/job/"+jobID+"/periodic-1525209600/evaluations" {
+			evals = []*nomad.Evaluation{{
+				ID:                "eval-1",
+				JobID:             jobID + "/periodic-1525209600",
+				Status:            "failed",
+				StatusDescription: "maximum attempts reached (5)",
+				TriggeredBy:       "periodic-job",
+				CreateIndex:       10,
+			}}
+		}
+		testutils.WriteJSON(w, evals)
+	})
+
+	job := &nomad.Job{
+		ID:     helper.StringToPtr(jobID),
+		Type:   helper.StringToPtr("batch"),
+		Status: helper.StringToPtr("running"),
+		Periodic: &nomad.PeriodicConfig{
+			Spec:     helper.StringToPtr("*/2 * * * * *"),
+			SpecType: helper.StringToPtr(nomad.PeriodicSpecCron),
+		},
+	}
+
+	status := &JobStatus{JobID: jobID}
+	require.NoError(t, describeJob(fake.Client, nomadScope{}, status, job, now))
+
+	assert.Equal(t, "running", status.Status)
+	assert.Equal(t, "batch", status.Type)
+	require.NotNil(t, status.LastRun)
+	assert.Equal(t, jobID+"/periodic-1525209600", status.LastRun.JobID)
+	assert.Equal(t, "running", status.LastRun.Status)
+	require.NotNil(t, status.NextLaunchAt)
+	assert.Equal(t, time.Date(2018, 5, 1, 21, 22, 0, 0, time.UTC), *status.NextLaunchAt)
+	require.NotNil(t, status.LatestEvaluation)
+	assert.Equal(t, "failed", status.LatestEvaluation.Status)
+	assert.Equal(t, "maximum attempts reached (5)", status.LatestEvaluation.StatusDescription)
+
+	// Suspended reconciles aren't launched.
+	job.Periodic.Enabled = helper.BoolToPtr(false)
+	status = &JobStatus{JobID: jobID}
+	require.NoError(t, describeJob(fake.Client, nomadScope{}, status, job, now))
+	assert.Nil(t, status.NextLaunchAt)
+
+	// Services have no periodic runs.
+	service := &nomad.Job{
+		ID:     helper.StringToPtr(jobID),
+		Type:   helper.StringToPtr("service"),
+		Status: helper.StringToPtr("pending"),
+	}
+	status = &JobStatus{JobID: jobID}
+	require.NoError(t, describeJob(fake.Client, nomadScope{}, status, service, now))
+	assert.Equal(t, "pending", status.Status)
+	assert.Nil(t, status.LastRun)
+	assert.Nil(t, status.NextLaunchAt)
+}
+
+func TestGetJobStatusNotSubmitted(t *testing.T) {
+	fake := testutils.NewFakeNomad(t)
+	defer fake.Close()
+	fake.HandleFunc("/v1/job/", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "job not found", http.StatusNotFound)
+	})
+
+	ctx := handlers.WithAuthSession(context.Background(), &auth.Session{Datacenter: "us-east-1"})
+	now := time.Now()
+	status, job, err := getJobStatus(ctx, fake.Client, "722d25ed-f32a-4944-9861-8990e204850e", "web_c2e4d1491ce423e3", now)
+	require.NoError(t, err)
+	assert.Nil(t, job)
+	assert.Equal(t, &JobStatus{
+		GroupID:     "722d25ed-f32a-4944-9861-8990e204850e",
+		JobID:       "web_c2e4d1491ce423e3",
+		Status:      JobStatusNotSubmitted,
+		RefreshedAt: now,
+	}, status)
+}
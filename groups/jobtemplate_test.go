@@ -0,0 +1,94 @@
+package groups_v1
+
+import (
+	"testing"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCustomJobTemplate = `
+job "{{ .JobName | hcl_string }}" {
+  type = "{{ .JobType | hcl_string }}"
+  meta {
+    tsg_group_id = "{{ .ServiceGroupID | hcl_string }}"
+    site = "east"
+  }
+  {{- if ne .JobType "service" }}
+  periodic {
+    cron = "*/5 * * * * *"
+    prohibit_overlap = true
+  }
+  {{- end }}
+  datacenters = ["{{ .Datacenter | hcl_string }}"]
+  {{ template "scale" . }}
+}
+`
+
+func TestSetJobTemplate(t *testing.T) {
+	defer SetJobTemplate("")
+
+	require.NoError(t, SetJobTemplate(testCustomJobTemplate))
+
+	for _, jobType := range []string{config.JobTypeBatch, config.JobTypeService} {
+		details := sampleJobDetails(jobType)
+		job, err := buildJob(details)
+		require.NoError(t, err, jobType)
+
+		assert.Equal(t, details.JobName, *job.ID)
+		assert.Equal(t, jobType, *job.Type)
+		assert.Equal(t, "east", job.Meta["site"])
+		require.Len(t, job.TaskGroups, 1)
+		assert.Equal(t, "scale", *job.TaskGroups[0].Name)
+		if jobType == config.JobTypeBatch {
+			require.NotNil(t, job.Periodic)
+			assert.Equal(t, "*/5 * * * * *", *job.Periodic.Spec)
+		} else {
+			assert.Nil(t, job.Periodic)
+		}
+	}
+
+	// The built in templates are restored.
+	require.NoError(t, SetJobTemplate(""))
+	job, err := buildJob(sampleJobDetails(config.JobTypeBatch))
+	require.NoError(t, err)
+	assert.Empty(t, job.Meta["site"])
+	assert.Equal(t, "*/2 * * * * *", *job.Periodic.Spec)
+}
+
+func TestSetJobTemplateBuiltIn(t *testing.T) {
+	defer SetJobTemplate("")
+
+	for jobType, src := range jobTemplates {
+		assert.NoError(t, SetJobTemplate(src), jobType)
+	}
+}
+
+func TestSetJobTemplateInvalid(t *testing.T) {
+	defer SetJobTemplate("")
+
+	tests := []struct {
+		name string
+		src  string
+		err  string
+	}{
+		{"unparsable", `job "{{ .JobName }" {}`, "unable to parse job template"},
+		{"unknown func", `job "{{ .JobName | shout }}" {}`, "unable to parse job template"},
+		{"unknown field", `job "{{ .JobName }}" { region = "{{ .Zone }}" }`, "unable to render job template for a batch job"},
+		{"invalid job", `job "{{ .JobName }}" { type = `, "unable to parse the batch job rendered by job template"},
+		{"job name", `job "web" { type = "batch" }`, "job template must name the job"},
+	}
+
+	require.NoError(t, SetJobTemplate(testCustomJobTemplate))
+	for _, tt := range tests {
+		err := SetJobTemplate(tt.src)
+		require.Error(t, err, tt.name)
+		assert.Contains(t, err.Error(), tt.err, tt.name)
+
+		// A rejected template leaves the previous one in place.
+		job, err := buildJob(sampleJobDetails(config.JobTypeBatch))
+		require.NoError(t, err, tt.name)
+		assert.Equal(t, "east", job.Meta["site"], tt.name)
+	}
+}
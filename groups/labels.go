@@ -0,0 +1,123 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// The limits of the labels of a group.
+const (
+	maxLabels      = 32
+	maxLabelLength = 63
+)
+
+// Label keys are lowercase and values may be empty. Neither may hold "," or
+// "=", which separate labels as they're stored, see encodeLabels.
+var (
+	labelKeyPattern   = regexp.MustCompile(`^[a-z0-9]([a-z0-9._-]*[a-z0-9])?$`)
+	labelValuePattern = regexp.MustCompile(`^[A-Za-z0-9._-]*$`)
+)
+
+// validateLabels checks the labels of a group. Labels only organize groups,
+// unlike the tags of a group's instances they're never passed to Triton.
+func validateLabels(labels map[string]string) error {
+	if len(labels) > maxLabels {
+		return fmt.Errorf("a group can have at most %d labels", maxLabels)
+	}
+	for key, value := range labels {
+		if err := validateLabel(key, &value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateLabel checks a label key and, unless it's nil, its value.
+func validateLabel(key string, value *string) error {
+	if len(key) > maxLabelLength || !labelKeyPattern.MatchString(key) {
+		return fmt.Errorf("label key %q must be up to %d lowercase letters, digits, "+
+			"'.', '_' and '-', starting and ending with a letter or digit", key, maxLabelLength)
+	}
+	if value != nil && (len(*value) > maxLabelLength || !labelValuePattern.MatchString(*value)) {
+		return fmt.Errorf("label %q value %q must be up to %d letters, digits, "+
+			"'.', '_' and '-'", key, *value, maxLabelLength)
+	}
+	return nil
+}
+
+// parseLabelSelector reads the label query parameters of the request, each
+// either "key=value" or "key", selecting the groups with every label.
+func parseLabelSelector(r *http.Request) (valueFilter, error) {
+	selector, err := parseValueFilter(r, "label")
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range selector {
+		if err := validateLabel(key, value); err != nil {
+			return nil, err
+		}
+	}
+	return selector, nil
+}
+
+// encodeLabels stores labels as "key=value" pairs separated by commas, sorted
+// by key, so that selectLabels can match them without decoding them.
+func encodeLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+func decodeLabels(data string) (map[string]string, error) {
+	if data == "" {
+		return nil, nil
+	}
+
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(data, ",") {
+		i := strings.Index(pair, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("invalid stored label %q", pair)
+		}
+		labels[pair[:i]] = pair[i+1:]
+	}
+	return labels, nil
+}
+
+// selectLabels returns the conditions of a WHERE clause selecting the groups
+// with every label of selector, along with args extended by the arguments
+// they refer to. The selector must have been validated, so that the only
+// wildcard its labels can hold is "_".
+func selectLabels(selector valueFilter, args []interface{}) (string, []interface{}) {
+	keys := make([]string, 0, len(selector))
+	for key := range selector {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	escape := strings.NewReplacer(`_`, `\_`)
+
+	var clause string
+	for _, key := range keys {
+		pattern := "%," + escape.Replace(key) + "="
+		if value := selector[key]; value != nil {
+			pattern += escape.Replace(*value) + ",%"
+		} else {
+			pattern += "%"
+		}
+		args = append(args, pattern)
+		clause += fmt.Sprintf("\nAND ',' || labels || ',' LIKE $%d", len(args))
+	}
+	return clause, args
+}
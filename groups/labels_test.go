@@ -0,0 +1,72 @@
+package groups_v1
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateLabels(t *testing.T) {
+	assert.NoError(t, validateLabels(nil))
+	assert.NoError(t, validateLabels(map[string]string{
+		"env":         "staging",
+		"team.web":    "Checkout_2",
+		"cost-center": "",
+	}))
+
+	for _, key := range []string{"", "Env", "-env", "env-", "env=prod", "a,b", "env%", strings.Repeat("a", 64)} {
+		assert.Error(t, validateLabels(map[string]string{key: "staging"}), key)
+	}
+	for _, value := range []string{"a,b", "a=b", "50%", "two words", strings.Repeat("a", 64)} {
+		assert.Error(t, validateLabels(map[string]string{"env": value}), value)
+	}
+
+	labels := make(map[string]string)
+	for i := 0; i <= maxLabels; i++ {
+		labels[strings.Repeat("a", i+1)] = ""
+	}
+	assert.EqualError(t, validateLabels(labels), "a group can have at most 32 labels")
+}
+
+func TestEncodeLabels(t *testing.T) {
+	labels := map[string]string{"role": "web", "env": "staging", "canary": ""}
+
+	data := encodeLabels(labels)
+	assert.Equal(t, "canary=,env=staging,role=web", data)
+
+	decoded, err := decodeLabels(data)
+	require.NoError(t, err)
+	assert.Equal(t, labels, decoded)
+
+	assert.Equal(t, "", encodeLabels(nil))
+	decoded, err = decodeLabels("")
+	require.NoError(t, err)
+	assert.Nil(t, decoded)
+
+	_, err = decodeLabels("env")
+	assert.Error(t, err)
+}
+
+func TestParseLabelSelector(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/tsg/groups?label=env=staging&label=team_web", nil)
+	selector, err := parseLabelSelector(r)
+	require.NoError(t, err)
+
+	clause, args := selectLabels(selector, []interface{}{"account"})
+	assert.Equal(t, "\nAND ',' || labels || ',' LIKE $2\nAND ',' || labels || ',' LIKE $3", clause)
+	assert.Equal(t, []interface{}{"account", "%,env=staging,%", `%,team\_web=%`}, args)
+
+	for _, query := range []string{"label=Env=staging", "label=env=50%25", "label=env=a,b"} {
+		r := httptest.NewRequest(http.MethodGet, "/v1/tsg/groups?"+query, nil)
+		_, err := parseLabelSelector(r)
+		assert.Error(t, err, query)
+	}
+
+	clause, args = selectLabels(nil, []interface{}{"account"})
+	assert.Empty(t, clause)
+	assert.Equal(t, []interface{}{"account"}, args)
+}
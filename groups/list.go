@@ -0,0 +1,105 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	defaultGroupLimit = 25
+	maxGroupLimit     = 100
+)
+
+// GroupOrder is the order in which a page of groups is listed, by a field of
+// the groups which is descending when prefixed by "-".
+type GroupOrder string
+
+const (
+	OrderByName          GroupOrder = "name"
+	OrderByNameDesc      GroupOrder = "-name"
+	OrderByCreatedAt     GroupOrder = "created_at"
+	OrderByCreatedAtDesc GroupOrder = "-created_at"
+)
+
+// groupOrderBy holds the ORDER BY clause of each GroupOrder. Groups are
+// ordered by ID last so that pages are stable between requests.
+var groupOrderBy = map[GroupOrder]string{
+	OrderByName:          "name ASC, id ASC",
+	OrderByNameDesc:      "name DESC, id ASC",
+	OrderByCreatedAt:     "created_at ASC, id ASC",
+	OrderByCreatedAtDesc: "created_at DESC, id ASC",
+}
+
+func (o GroupOrder) orderBy() string {
+	if clause, ok := groupOrderBy[o]; ok {
+		return clause
+	}
+	return groupOrderBy[OrderByCreatedAt]
+}
+
+// GroupPage is a page of the groups of an account.
+type GroupPage struct {
+	Total  int             `json:"total"`
+	Limit  int             `json:"limit"`
+	Offset int             `json:"offset"`
+	Order  GroupOrder      `json:"order"`
+	Groups []*ServiceGroup `json:"groups"`
+}
+
+func newGroupPage(limit, offset int, order GroupOrder) *GroupPage {
+	if limit <= 0 {
+		limit = defaultGroupLimit
+	}
+	if limit > maxGroupLimit {
+		limit = maxGroupLimit
+	}
+	if offset < 0 {
+		offset = 0
+	}
+	if _, ok := groupOrderBy[order]; !ok {
+		order = OrderByCreatedAt
+	}
+
+	return &GroupPage{
+		Limit:  limit,
+		Offset: offset,
+		Order:  order,
+		Groups: []*ServiceGroup{},
+	}
+}
+
+// parseGroupOrder reads the optional order query parameter from the request,
+// defaulting to OrderByCreatedAt.
+func parseGroupOrder(r *http.Request) (GroupOrder, error) {
+	v := r.URL.Query().Get("order")
+	if v == "" {
+		return OrderByCreatedAt, nil
+	}
+
+	order := GroupOrder(v)
+	if _, ok := groupOrderBy[order]; !ok {
+		return "", fmt.Errorf("order must be one of %s", strings.Join([]string{
+			string(OrderByName), string(OrderByNameDesc),
+			string(OrderByCreatedAt), string(OrderByCreatedAtDesc),
+		}, ", "))
+	}
+	return order, nil
+}
+
+// isPageRequest reports whether the request asks for a page of groups rather
+// than the complete list.
+func isPageRequest(r *http.Request) bool {
+	query := r.URL.Query()
+	for _, param := range []string{"limit", "offset", "order"} {
+		if _, ok := query[param]; ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,102 @@
+package groups_v1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewGroupPage(t *testing.T) {
+	page := newGroupPage(0, -1, "")
+	assert.Equal(t, defaultGroupLimit, page.Limit)
+	assert.Equal(t, 0, page.Offset)
+	assert.Equal(t, OrderByCreatedAt, page.Order)
+	assert.NotNil(t, page.Groups)
+
+	page = newGroupPage(1000, 50, OrderByNameDesc)
+	assert.Equal(t, maxGroupLimit, page.Limit)
+	assert.Equal(t, 50, page.Offset)
+	assert.Equal(t, OrderByNameDesc, page.Order)
+}
+
+func TestGroupOrderBy(t *testing.T) {
+	assert.Equal(t, "name ASC, id ASC", OrderByName.orderBy())
+	assert.Equal(t, "created_at DESC, id ASC", OrderByCreatedAtDesc.orderBy())
+	assert.Equal(t, "created_at ASC, id ASC", GroupOrder("id; DROP TABLE tsg_groups").orderBy())
+}
+
+func TestParseGroupOrder(t *testing.T) {
+	parse := func(query string) (GroupOrder, error) {
+		return parseGroupOrder(httptest.NewRequest(http.MethodGet, "/v1/tsg/groups"+query, nil))
+	}
+
+	order, err := parse("")
+	assert.NoError(t, err)
+	assert.Equal(t, OrderByCreatedAt, order)
+
+	order, err = parse("?order=-name")
+	assert.NoError(t, err)
+	assert.Equal(t, OrderByNameDesc, order)
+
+	_, err = parse("?order=capacity")
+	assert.EqualError(t, err, "order must be one of name, -name, created_at, -created_at")
+}
+
+func TestParsePagination(t *testing.T) {
+	tests := []struct {
+		query  string
+		limit  int
+		offset int
+		err    string
+	}{
+		{query: ""},
+		{query: "?limit=0&offset=0"},
+		{query: "?limit=1&offset=1", limit: 1, offset: 1},
+		{query: "?limit=-1", err: "limit must be a non-negative integer"},
+		{query: "?offset=-1", err: "offset must be a non-negative integer"},
+		{query: "?limit=ten", err: "limit must be a non-negative integer"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			limit, offset, err := parsePagination(httptest.NewRequest(http.MethodGet, "/v1/tsg/groups"+test.query, nil))
+			if test.err != "" {
+				assert.EqualError(t, err, test.err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, test.limit, limit)
+			assert.Equal(t, test.offset, offset)
+		})
+	}
+}
+
+func TestListPageParams(t *testing.T) {
+	list := func(query string) *httptest.ResponseRecorder {
+		ctx := handlers.WithAuthSession(context.Background(), &auth.Session{AccountID: "6d6f3c38-2ed5-4b7a-a0ad-8a2caa32bbb4"})
+		r := httptest.NewRequest(http.MethodGet, "/v1/tsg/groups"+query, nil).WithContext(ctx)
+
+		w := httptest.NewRecorder()
+		List(w, r)
+		return w
+	}
+
+	assert.True(t, isPageRequest(httptest.NewRequest(http.MethodGet, "/v1/tsg/groups?offset=", nil)))
+	assert.False(t, isPageRequest(httptest.NewRequest(http.MethodGet, "/v1/tsg/groups?expand=account", nil)))
+
+	w := list("?limit=-1")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "limit must be a non-negative integer\n", w.Body.String())
+
+	w = list("?order=capacity")
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = list("?limit=10&order=name")
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Equal(t, handlers.ErrNoConnPool.Error()+"\n", w.Body.String())
+}
@@ -0,0 +1,78 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/joyent/triton-service-groups/health"
+)
+
+// The operations on the jobs of groups counted by tsg_orchestrator_jobs_total.
+const (
+	jobOpSubmit = "submit"
+	jobOpUpdate = "update"
+	jobOpDelete = "delete"
+	jobOpScale  = "scale"
+	jobOpPause  = "pause"
+	jobOpResume = "resume"
+	// jobOpReconcile forces a periodic instance of a job to run, outside of
+	// its schedule.
+	jobOpReconcile = "reconcile"
+)
+
+// The calls to Nomad timed by tsg_nomad_request_duration_seconds.
+const (
+	nomadCallRegister   = "register"
+	nomadCallDeregister = "deregister"
+)
+
+// countJobOp counts an operation on the job of a group in a single
+// datacenter, as a failure if err isn't nil.
+func countJobOp(op string, err error) {
+	result := "success"
+	if err != nil {
+		result = "failure"
+	}
+
+	metrics.IncrCounterWithLabels([]string{"orchestrator", "jobs_total"}, 1, []metrics.Label{
+		{Name: "op", Value: op},
+		{Name: "result", Value: result},
+	})
+}
+
+// recordReconcile records the outcome of a reconcile towards readiness. Only
+// failures of Nomad, or of reaching it, count against it: a request rejected
+// as invalid, or given up on by its client, says nothing about whether this
+// process can reconcile groups.
+func recordReconcile(err error) {
+	if err != nil {
+		if errors.Is(err, context.Canceled) {
+			return
+		}
+		if !isNomadFailure(err) && !isNomadUnavailable(err) {
+			return
+		}
+	}
+	health.Reconciles.Record(err)
+}
+
+// observeNomadCall records the latency of a call to Nomad made at start,
+// including any retries.
+func observeNomadCall(call string, start time.Time) {
+	metrics.AddSampleWithLabels([]string{"nomad", "request_duration_seconds"},
+		float32(time.Since(start).Seconds()), []metrics.Label{
+			{Name: "call", Value: call},
+		})
+}
+
+// setTrackedGroups records how many groups the server is managing jobs for.
+func setTrackedGroups(n int) {
+	metrics.SetGauge([]string{"groups", "tracked"}, float32(n))
+}
@@ -0,0 +1,84 @@
+package groups_v1
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/joyent/triton-service-groups/health"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/telemetry"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobMetrics(t *testing.T) {
+	sink := telemetry.NewPrometheusSink(telemetry.DefaultBuckets)
+	_, err := metrics.NewGlobal(&metrics.Config{ServiceName: telemetry.ServiceName, FilterDefault: true}, sink)
+	require.NoError(t, err)
+	defer metrics.NewGlobal(&metrics.Config{FilterDefault: true}, &metrics.BlackholeSink{})
+
+	countJobOp(jobOpSubmit, nil)
+	countJobOp(jobOpSubmit, nil)
+	countJobOp(jobOpDelete, errors.New("nomad is unavailable"))
+	observeNomadCall(nomadCallRegister, time.Now())
+	setTrackedGroups(4)
+
+	w := httptest.NewRecorder()
+	sink.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := w.Body.String()
+
+	assert.Contains(t, body, `tsg_orchestrator_jobs_total{op="submit",result="success"} 2`)
+	assert.Contains(t, body, `tsg_orchestrator_jobs_total{op="delete",result="failure"} 1`)
+	assert.Contains(t, body, `tsg_nomad_request_duration_seconds_count{call="register"} 1`)
+	assert.Contains(t, body, "tsg_groups_tracked 4\n")
+}
+
+func testReconciles() func() {
+	reconciles := health.Reconciles
+	health.Reconciles = health.NewTracker(time.Minute)
+	return func() { health.Reconciles = reconciles }
+}
+
+func TestRecordReconcile(t *testing.T) {
+	defer testReconciles()()
+
+	recordReconcile(nil)
+	recordReconcile(&ErrImageNotFound{ImageID: "f4b1ea6a-8e76-4b7a-a1cf-0bc3c6a8f3d0"})
+	recordReconcile(&ErrInvalidCapacity{Capacity: -1})
+	recordReconcile(context.Canceled)
+	recordReconcile(&ErrNomad{Op: ErrNomadRegister, Err: context.Canceled})
+	recordReconcile(&ErrNomad{Op: ErrNomadRegister, Err: errors.New("connection refused")})
+	recordReconcile(fmt.Errorf("datacenter us-east-1: %w", handlers.ErrNoNomadClient))
+
+	ratio, samples := health.Reconciles.FailureRatio()
+	assert.Equal(t, 3, samples)
+	assert.InDelta(t, 2.0/3, ratio, 0.001)
+}
+
+func TestRegisterGroupJobRejectedKeepsReadiness(t *testing.T) {
+	defer testReconciles()()
+
+	defer func(f func(ctx context.Context, group *ServiceGroup, capacity int, check bool) (*nomad.Job, error)) {
+		buildGroupJob = f
+	}(buildGroupJob)
+	buildGroupJob = func(ctx context.Context, group *ServiceGroup, capacity int, check bool) (*nomad.Job, error) {
+		return nil, &ErrImageNotFound{ImageID: "f4b1ea6a-8e76-4b7a-a1cf-0bc3c6a8f3d0"}
+	}
+
+	for i := 0; i < 5; i++ {
+		_, err := registerGroupJob(context.Background(), &ServiceGroup{GroupName: "web"}, 1, true, true)
+		require.Error(t, err)
+		assert.Equal(t, http.StatusUnprocessableEntity, orchestratorErrorStatus(err))
+	}
+
+	ratio, samples := health.Reconciles.FailureRatio()
+	assert.Zero(t, samples)
+	assert.Zero(t, ratio)
+}
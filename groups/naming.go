@@ -0,0 +1,90 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	namePatternGroup      = "group"
+	namePatternIndex      = "index"
+	namePatternDatacenter = "datacenter"
+
+	// maxInstanceNameLength is the longest instance name accepted by Triton.
+	maxInstanceNameLength = 189
+)
+
+var (
+	namePatternVariable = regexp.MustCompile(`\{\{\s*([^{}\s]*)\s*\}\}`)
+	instanceNameRule    = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+)
+
+// validateNamePattern checks that pattern renders instance names Triton will
+// accept for the given group and datacenter, up to the index of the largest
+// capacity the group may run with. An empty pattern leaves naming to tsg-cli.
+//
+// Every pattern must include {{index}} so that the instances of a group never
+// share a name.
+func validateNamePattern(pattern, groupName, datacenter string, maxCapacity int) error {
+	if pattern == "" {
+		return nil
+	}
+
+	var hasIndex bool
+	for _, match := range namePatternVariable.FindAllStringSubmatch(pattern, -1) {
+		switch match[1] {
+		case namePatternIndex:
+			hasIndex = true
+		case namePatternGroup, namePatternDatacenter:
+		default:
+			return fmt.Errorf("instance name pattern has unknown variable %q", match[0])
+		}
+	}
+	if !hasIndex {
+		return fmt.Errorf("instance name pattern must include {{%s}}", namePatternIndex)
+	}
+
+	name := renderInstanceName(pattern, groupName, datacenter, strconv.Itoa(maxCapacity))
+	if strings.ContainsAny(name, "{}") || !instanceNameRule.MatchString(name) {
+		return fmt.Errorf("instance name pattern renders invalid name %q: "+
+			"names must start with a letter or digit and contain only letters, digits, '_', '.' and '-'", name)
+	}
+	if len(name) > maxInstanceNameLength {
+		return fmt.Errorf("instance name pattern renders names longer than %d characters", maxInstanceNameLength)
+	}
+
+	return nil
+}
+
+// renderInstanceName substitutes the variables of pattern. Passing index as
+// "{{index}}" leaves it for tsg-cli to fill in.
+func renderInstanceName(pattern, groupName, datacenter, index string) string {
+	return namePatternVariable.ReplaceAllStringFunc(pattern, func(v string) string {
+		switch namePatternVariable.FindStringSubmatch(v)[1] {
+		case namePatternGroup:
+			return groupName
+		case namePatternDatacenter:
+			return datacenter
+		case namePatternIndex:
+			return index
+		default:
+			return v
+		}
+	})
+}
+
+// instanceNamePattern returns the pattern passed to tsg-cli, with everything
+// but the index already rendered.
+func instanceNamePattern(pattern, groupName, datacenter string) string {
+	if pattern == "" {
+		return ""
+	}
+	return renderInstanceName(pattern, groupName, datacenter, "{{"+namePatternIndex+"}}")
+}
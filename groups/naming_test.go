@@ -0,0 +1,96 @@
+package groups_v1
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/hashicorp/nomad/jobspec"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateNamePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		group   string
+		max     int
+		err     string
+	}{
+		{"", "web", 100, ""},
+		{"{{group}}-{{index}}", "web", 100, ""},
+		{"{{ datacenter }}.{{ group }}.{{ index }}", "web", 100, ""},
+		{"{{index}}-{{group}}", "web", 100, ""},
+		{"{{group}}", "web", 100, "instance name pattern must include {{index}}"},
+		{"{{group}}-{{idx}}", "web", 100, `instance name pattern has unknown variable "{{idx}}"`},
+		{"{{group}} {{index}}", "web", 100, `instance name pattern renders invalid name "web 100": ` +
+			"names must start with a letter or digit and contain only letters, digits, '_', '.' and '-'"},
+		{"-{{group}}-{{index}}", "web", 100, `instance name pattern renders invalid name "-web-100": ` +
+			"names must start with a letter or digit and contain only letters, digits, '_', '.' and '-'"},
+		{"{{group}}-{{index}}}", "web", 100, `instance name pattern renders invalid name "web-100}": ` +
+			"names must start with a letter or digit and contain only letters, digits, '_', '.' and '-'"},
+		{"{{group}}-{{index}}", strings.Repeat("a", 186), 100, "instance name pattern renders names longer than 189 characters"},
+		{"{{group}}-{{index}}", strings.Repeat("a", 185), 100, ""},
+		// The index of a larger configured capacity is wider.
+		{"{{group}}-{{index}}", strings.Repeat("a", 185), 1000, "instance name pattern renders names longer than 189 characters"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			err := validateNamePattern(tt.pattern, tt.group, "us-sw-1", tt.max)
+			if tt.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.err)
+			}
+		})
+	}
+}
+
+func TestRenderInstanceName(t *testing.T) {
+	assert.Equal(t, "us-sw-1.web.3",
+		renderInstanceName("{{datacenter}}.{{ group }}.{{index}}", "web", "us-sw-1", "3"))
+	assert.Equal(t, "web-{{index}}",
+		instanceNamePattern("{{group}}-{{ index }}", "web", "us-sw-1"))
+	assert.Equal(t, "", instanceNamePattern("", "web", "us-sw-1"))
+}
+
+func TestJobNamePatternArg(t *testing.T) {
+	tmpl := &templates_v1.InstanceTemplate{
+		ID:      "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+		Package: "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+		ImageID: "342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+	}
+
+	render := func(pattern string) []string {
+		group := &ServiceGroup{
+			GroupName:           "web",
+			Capacity:            2,
+			InstanceNamePattern: pattern,
+		}
+
+		details, err := createJobDetails(tmpl, group)
+		require.NoError(t, err)
+		details.JobName = jobName(group.GroupName, "c2e4d1491ce423e3")
+		details.Datacenters = []string{"us-sw-1"}
+		details.InstanceName = instanceNamePattern(pattern, group.GroupName, details.Datacenter())
+
+		spec, err := renderJobSpec(details)
+		require.NoError(t, err)
+
+		job, err := jobspec.Parse(strings.NewReader(spec))
+		require.NoError(t, err)
+
+		var args []string
+		for _, arg := range job.TaskGroups[0].Tasks[0].Config["args"].([]interface{}) {
+			args = append(args, arg.(string))
+		}
+		return args
+	}
+
+	args := render("{{group}}-{{datacenter}}-{{index}}")
+	assert.Contains(t, strings.Join(args, " "), "--name-pattern web-us-sw-1-{{index}}")
+
+	args = render("")
+	assert.NotContains(t, args, "--name-pattern")
+}
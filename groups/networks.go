@@ -0,0 +1,131 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	tritonerrors "github.com/joyent/triton-go/errors"
+	"github.com/joyent/triton-go/network"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/pkg/errors"
+)
+
+// ErrNetworksNotFound is returned when networks of a template don't exist in
+// the datacenter a group runs in, which would otherwise only fail once
+// tsg-cli runs.
+type ErrNetworksNotFound struct {
+	Datacenter string
+	NetworkIDs []string
+}
+
+func (e *ErrNetworksNotFound) Error() string {
+	quoted := make([]string, 0, len(e.NetworkIDs))
+	for _, id := range e.NetworkIDs {
+		quoted = append(quoted, fmt.Sprintf("%q", id))
+	}
+	return fmt.Sprintf("networks %s referenced by the template don't exist in datacenter %q",
+		strings.Join(quoted, ", "), e.Datacenter)
+}
+
+// networkCacheSize bounds the number of network lookups held in
+// networkExistsCache.
+const networkCacheSize = 1024
+
+var networkExistsCache = newExistsCache("network", networkCacheSize, config.GetNetworkCacheTTL)
+
+// lookupNetwork reports whether a network exists in Triton as seen by the
+// account.
+var lookupNetwork = func(ctx context.Context, accountID, tritonURL, networkID string) (bool, error) {
+	tritonConfig, err := newTritonConfig(ctx, accountID, tritonURL)
+	if err != nil {
+		return false, err
+	}
+
+	c, err := network.NewClient(tritonConfig)
+	if err != nil {
+		return false, errors.Wrapf(err, "error constructing NetworkClient")
+	}
+	return networkExists(ctx, c, networkID)
+}
+
+// checkNetworks returns an ErrNetworksNotFound if checking networks is enabled
+// and any of the template's networks don't exist in the datacenter of the
+// session.
+func checkNetworks(ctx context.Context, t *templates_v1.InstanceTemplate) error {
+	if !config.GetCheckNetworks() {
+		return nil
+	}
+
+	session := handlers.GetAuthSession(ctx)
+
+	var missing []string
+	for _, networkID := range t.Networks {
+		if networkID == "" {
+			continue
+		}
+
+		key := resourceKey{
+			tritonURL: session.TritonURL,
+			accountID: session.AccountID,
+			id:        networkID,
+		}
+		exists, err := networkExistsCache.Exists(key, func() (bool, error) {
+			return lookupNetwork(ctx, session.AccountID, session.TritonURL, networkID)
+		})
+		if err != nil {
+			return errors.Wrap(err, "unable to check template networks")
+		}
+		if !exists {
+			missing = append(missing, networkID)
+		}
+	}
+
+	if len(missing) > 0 {
+		return &ErrNetworksNotFound{
+			Datacenter: session.Datacenter,
+			NetworkIDs: missing,
+		}
+	}
+
+	return nil
+}
+
+// checkDatacenterNetworks checks the template's networks in every datacenter
+// of a multi-datacenter group up front, so that none of its datacenters are
+// submitted to unless the template suits all of them.
+func checkDatacenterNetworks(ctx context.Context, group *ServiceGroup) error {
+	if !config.GetCheckNetworks() {
+		return nil
+	}
+
+	session := handlers.GetAuthSession(ctx)
+
+	t, found := templates_v1.FindTemplateByID(ctx, group.TemplateID, session.AccountID)
+	if !found {
+		return errors.New("Error finding template by ID")
+	}
+	t = withInstanceOverrides(t, group)
+
+	return forEachDatacenter(ctx, group, group.Datacenters, func(ctx context.Context, _ *ServiceGroup) error {
+		return checkNetworks(ctx, t)
+	})
+}
+
+func networkExists(ctx context.Context, c *network.NetworkClient, networkID string) (bool, error) {
+	_, err := c.Get(ctx, &network.GetInput{ID: networkID})
+	if err != nil {
+		if tritonerrors.IsResourceNotFound(err) || tritonerrors.IsStatusNotFoundCode(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
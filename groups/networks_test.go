@@ -0,0 +1,153 @@
+package groups_v1
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joyent/triton-go"
+	"github.com/joyent/triton-go/authentication"
+	"github.com/joyent/triton-go/network"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testPublicNetwork  = "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0"
+	testPrivateNetwork = "5bd8d2f6-ea0a-4d5d-a4ea-5a2f2ab5e2ec"
+)
+
+// stubNetworks answers network lookups from the networks of each datacenter,
+// by CloudAPI URL, counting the lookups made.
+func stubNetworks(t *testing.T, networks map[string][]string) *int {
+	var lookups int
+
+	lookup := lookupNetwork
+	cache := networkExistsCache
+	t.Cleanup(func() {
+		lookupNetwork = lookup
+		networkExistsCache = cache
+	})
+
+	networkExistsCache = newExistsCache("network", networkCacheSize, config.GetNetworkCacheTTL)
+	lookupNetwork = func(ctx context.Context, accountID, tritonURL, networkID string) (bool, error) {
+		lookups++
+		for _, id := range networks[tritonURL] {
+			if id == networkID {
+				return true, nil
+			}
+		}
+		return false, nil
+	}
+
+	return &lookups
+}
+
+func TestNetworkExists(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path != "/testacct/networks/"+testPublicNetwork {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code": "ResourceNotFound", "message": "network not found"}`))
+			return
+		}
+		w.Write([]byte(`{"id": "` + testPublicNetwork + `", "name": "Joyent-SDC-Public", "public": true}`))
+	}))
+	defer srv.Close()
+
+	signer, err := authentication.NewTestSigner()
+	require.NoError(t, err)
+
+	c, err := network.NewClient(&triton.ClientConfig{
+		TritonURL:   srv.URL,
+		AccountName: "testacct",
+		Signers:     []authentication.Signer{signer},
+	})
+	require.NoError(t, err)
+
+	exists, err := networkExists(context.Background(), c, testPublicNetwork)
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = networkExists(context.Background(), c, testPrivateNetwork)
+	require.NoError(t, err)
+	assert.False(t, exists)
+}
+
+func TestCheckNetworks(t *testing.T) {
+	defer viper.Reset()
+
+	lookups := stubNetworks(t, map[string][]string{
+		"https://us-east-1.api.joyent.com": {testPublicNetwork, testPrivateNetwork},
+		"https://us-west-1.api.joyent.com": {testPublicNetwork},
+	})
+
+	east, _, _ := testDatacentersContext(t)
+	west, err := withDatacenter(east, "us-west-1")
+	require.NoError(t, err)
+
+	tmpl := &templates_v1.InstanceTemplate{
+		Networks: []string{testPublicNetwork, testPrivateNetwork},
+	}
+
+	// Disabled by default.
+	require.NoError(t, checkNetworks(west, tmpl))
+	assert.Equal(t, 0, *lookups)
+
+	viper.Set(config.KeyTritonCheckNetworks, true)
+
+	// Every network of the template exists in us-east-1.
+	require.NoError(t, checkNetworks(east, tmpl))
+
+	// The private network doesn't exist in us-west-1.
+	err = checkNetworks(west, tmpl)
+	assert.Equal(t, &ErrNetworksNotFound{
+		Datacenter: "us-west-1",
+		NetworkIDs: []string{testPrivateNetwork},
+	}, err)
+	assert.EqualError(t, err, `networks "5bd8d2f6-ea0a-4d5d-a4ea-5a2f2ab5e2ec" referenced by the template don't exist in datacenter "us-west-1"`)
+	assert.Equal(t, http.StatusUnprocessableEntity, orchestratorErrorStatus(err))
+
+	// The answers are cached per datacenter.
+	assert.Equal(t, 4, *lookups)
+	assert.Error(t, checkNetworks(west, tmpl))
+	assert.Equal(t, 4, *lookups)
+}
+
+func TestCheckNetworksDatacenters(t *testing.T) {
+	defer viper.Reset()
+	viper.Set(config.KeyTritonCheckNetworks, true)
+
+	stubNetworks(t, map[string][]string{
+		"https://us-east-1.api.joyent.com": {testPublicNetwork, testPrivateNetwork},
+		"https://us-west-1.api.joyent.com": {testPublicNetwork},
+	})
+
+	ctx, _, _ := testDatacentersContext(t)
+	group := &ServiceGroup{Datacenters: map[string]int{"us-east-1": 2, "us-west-1": 3}}
+
+	check := func(t *templates_v1.InstanceTemplate) error {
+		return forEachDatacenter(ctx, group, group.Datacenters, func(ctx context.Context, _ *ServiceGroup) error {
+			return checkNetworks(ctx, t)
+		})
+	}
+
+	assert.NoError(t, check(&templates_v1.InstanceTemplate{Networks: []string{testPublicNetwork}}))
+
+	// A template which doesn't suit one of the datacenters is rejected as a
+	// whole rather than treated as an upstream failure.
+	err := check(&templates_v1.InstanceTemplate{Networks: []string{testPrivateNetwork}})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusUnprocessableEntity, orchestratorErrorStatus(err))
+
+	err = &ErrDatacenters{Errors: map[string]error{
+		"us-east-1": &ErrNetworksNotFound{Datacenter: "us-east-1"},
+		"us-west-1": errors.New("Unable to register job with Nomad: connection refused"),
+	}}
+	assert.Equal(t, http.StatusBadGateway, orchestratorErrorStatus(err))
+}
@@ -4,202 +4,965 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
-	stdlog "log"
+	"regexp"
+	"strconv"
 	"strings"
 	"text/template"
+	"time"
 
 	nomad "github.com/hashicorp/nomad/api"
 	"github.com/hashicorp/nomad/jobspec"
 	"github.com/joyent/triton-service-groups/accounts"
 	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/health"
 	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
 	"github.com/joyent/triton-service-groups/templates"
+	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
 
 type OrchestratorJob struct {
-	Datacenter        string
-	JobName           string
-	DesiredCount      int
-	PackageID         string
-	ImageID           string
-	ServiceGroupName  string
-	TemplateID        string
-	UserData          string
-	FirewallEnabled   bool
-	Networks          []string
-	Tags              map[string]string
-	MetaData          map[string]string
+	// Datacenters are the Nomad datacenters the job may be placed in, at
+	// least one.
+	Datacenters []string
+	// Region is the Nomad region of the job, the agent's region when unset.
+	Region  string
+	JobName string
+	// JobType is config.JobTypeBatch or config.JobTypeService. Batch is
+	// assumed when unset.
+	JobType      string
+	DesiredCount int
+	// CPU, in MHz, and MemoryMB are the resources reserved for the task
+	// which runs tsg-cli.
+	CPU              int
+	MemoryMB         int
+	PackageID        string
+	ImageID          string
+	ServiceGroupID   string
+	ServiceGroupName string
+	InstanceName     string
+	TemplateID       string
+	UserData         string
+	FirewallEnabled  bool
+	// FirewallRules are passed to tsg-cli as an argument each.
+	FirewallRules []string
+	Networks      []string
+	Tags          map[string]string
+	MetaData      map[string]string
+	// JSONArgs passes Tags and MetaData to tsg-cli as a single JSON object
+	// each, rather than an argument per pair.
+	JSONArgs          bool
 	TritonAccount     string
 	TritonURL         string
 	TritonKeyID       string
 	TritonKeyMaterial string
-	TSGCliVersion     string
+	// VaultKeyPath, when set, is the Vault secret the task reads the
+	// account's key from, at VaultKeyField, in place of TritonKeyMaterial.
+	// VaultPolicies are granted to the task to read it.
+	VaultKeyPath  string
+	VaultKeyField string
+	VaultPolicies []string
+	TSGCliVersion string
+	// TSGCliSource is the URL the tsg-cli release is fetched from.
+	TSGCliSource  string
+	TSGCliCommand string
+	Artifact      config.Artifact
+	// Constraints place the job on the Nomad clients which may run it.
+	Constraints []config.Constraint
+	// Restart and Reschedule are how Nomad retries the failed job.
+	Restart    config.RestartPolicy
+	Reschedule config.ReschedulePolicy
+	// TimeZone is the IANA time zone the periodic schedule of a batch job
+	// runs in.
+	TimeZone string
 }
 
-func SubmitOrchestratorJob(ctx context.Context, group *ServiceGroup) error {
-	session := handlers.GetAuthSession(ctx)
+// JobSubmission is a job registered with Nomad on behalf of a group, and the
+// evaluations Nomad created for it.
+type JobSubmission struct {
+	Datacenter string `json:"datacenter,omitempty"`
+	JobID      string `json:"job_id"`
+	EvalID     string `json:"eval_id,omitempty"`
+	// PeriodicEvalID is the evaluation of the periodic instance forced to run
+	// when a batch job is registered.
+	PeriodicEvalID string `json:"periodic_eval_id,omitempty"`
+}
 
-	t, found := templates_v1.FindTemplateByID(ctx, group.TemplateID, session.AccountID)
-	if !found {
-		return errors.New("Error finding template by ID")
+// SubmitOrchestratorJob registers the jobs of group, returning those which
+// were registered even if another datacenter failed.
+func SubmitOrchestratorJob(ctx context.Context, group *ServiceGroup) (submissions []*JobSubmission, err error) {
+	if group.isMultiDatacenter() {
+		if err := checkDatacenterNetworks(ctx, group); err != nil {
+			return nil, err
+		}
+		return forEachSubmission(ctx, group, SubmitOrchestratorJob)
+	}
+
+	defer func() { countJobOp(jobOpSubmit, err) }()
+	defer func() { auditJobOp(ctx, jobOpSubmit, group, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	job, err := prepareJob(ctx, t, group)
+	capacity, err := canaryCapacity(ctx, group)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	// A new group's first run may be left to its schedule, such as for a
+	// group created ahead of a maintenance window.
+	submission, err := registerGroupJob(ctx, group, capacity, true, config.GetForceOnSubmit())
+	if err != nil {
+		return nil, err
 	}
+	return []*JobSubmission{submission}, nil
+}
 
-	deployed, err := registerJob(ctx, job)
+// ResubmitAccountJobs submits the jobs of every group of the account again, so
+// that they're rendered with the account's current key once it's rotated. The
+// names of the groups whose jobs failed to submit are returned, each failure
+// being logged, rather than stopping at the first.
+func ResubmitAccountJobs(ctx context.Context, accountID string) ([]string, error) {
+	groups, err := FindGroups(ctx, accountID)
 	if err != nil {
+		return nil, err
+	}
+
+	var failed []string
+	for _, group := range groups {
+		if _, err := SubmitOrchestratorJob(ctx, group); err != nil {
+			handlers.Logger(ctx).Error().Err(err).
+				Str("group_name", group.GroupName).
+				Msg("orchestrator: failed to resubmit job")
+			failed = append(failed, group.GroupName)
+		}
+	}
+	return failed, nil
+}
+
+// forEachSubmission calls fn in each datacenter of group as forEachDatacenter
+// does, collecting the jobs it submitted.
+func forEachSubmission(ctx context.Context, group *ServiceGroup, fn func(ctx context.Context, group *ServiceGroup) ([]*JobSubmission, error)) ([]*JobSubmission, error) {
+	var submissions []*JobSubmission
+	err := forEachDatacenter(ctx, group, group.Datacenters, func(ctx context.Context, group *ServiceGroup) error {
+		submitted, err := fn(ctx, group)
+		submissions = append(submissions, submitted...)
 		return err
+	})
+	return submissions, err
+}
+
+// registerGroupJob registers the job of a single datacenter group, running
+// capacity instances rather than the group's own capacity. The job is built
+// by groupJob, checking its template first if check is set, and its first
+// periodic run is forced if force is set. Both submitting and registering a
+// group's job again go through here, so that their jobs are built alike.
+func registerGroupJob(ctx context.Context, group *ServiceGroup, capacity int, check, force bool) (submission *JobSubmission, err error) {
+	defer func() { recordReconcile(err) }()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
 	}
 
-	stdlog.Print(deployed)
+	session := handlers.GetAuthSession(ctx)
 
-	return nil
+	job, err := buildGroupJob(ctx, group, capacity, check)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	submission, err = registerJob(ctx, job, force)
+	if err != nil {
+		return nil, err
+	}
+	logRetireLegacyJob(ctx, group)
+	trackConvergence(group)
+
+	if err := awaitFirstRun(ctx, submission); err != nil {
+		return nil, err
+	}
+
+	handlers.Logger(ctx).Info().
+		Str("account_id", session.AccountID).
+		Str("group_name", group.GroupName).
+		Str("job_name", *job.ID).
+		Int("capacity", capacity).
+		Msg("orchestrator: submitted job")
+
+	return submission, nil
 }
 
-func UpdateOrchestratorJob(ctx context.Context, group *ServiceGroup) error {
+// buildGroupJob is swapped out by tests.
+var buildGroupJob = groupJob
+
+// groupJob builds the job of a single datacenter group from its template,
+// running capacity instances. With check the template's image, package,
+// networks and tags are first known to be usable, see groupTemplate.
+func groupJob(ctx context.Context, group *ServiceGroup, capacity int, check bool) (*nomad.Job, error) {
+	find := findGroupTemplate
+	if check {
+		find = groupTemplate
+	}
+
+	t, err := find(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	return prepareJob(ctx, t, withCapacity(group, capacity))
+}
+
+// checkGroupJob checks that the job of group can be built in each datacenter
+// it runs in, so that a group whose job would be rejected is rejected before
+// it's saved rather than once it has been.
+func checkGroupJob(ctx context.Context, group *ServiceGroup) error {
+	if group.isMultiDatacenter() {
+		return forEachDatacenter(ctx, group, group.Datacenters, checkGroupJob)
+	}
+
+	_, err := groupTemplate(ctx, group)
+	return err
+}
+
+// groupTemplate returns the template of a single datacenter group, with the
+// group's instance overrides applied, once the values written into its job
+// are known to be safe, its image, package and networks are known to exist
+// and it sets every required tag.
+func groupTemplate(ctx context.Context, group *ServiceGroup) (*templates_v1.InstanceTemplate, error) {
+	t, err := findGroupTemplate(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	t = withInstanceOverrides(t, group)
+
+	if err := checkJobValues(t, group); err != nil {
+		return nil, err
+	}
+	if _, err := tritonID("package", t.Package); err != nil {
+		return nil, err
+	}
+	if _, err := tritonID("image", t.ImageID); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if err := checkImage(ctx, t); err != nil {
+		return nil, err
+	}
+
+	if err := checkPackage(ctx, t); err != nil {
+		return nil, err
+	}
+
+	if err := checkNetworks(ctx, t); err != nil {
+		return nil, err
+	}
+
+	if err := templates_v1.CheckRequiredTags(t); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// findGroupTemplate returns the template revision which group is pinned to.
+func findGroupTemplate(ctx context.Context, group *ServiceGroup) (*templates_v1.InstanceTemplate, error) {
 	session := handlers.GetAuthSession(ctx)
 
 	t, found := templates_v1.FindTemplateByID(ctx, group.TemplateID, session.AccountID)
 	if !found {
-		return errors.New("Error finding template by ID")
+		return nil, &ErrTemplateNotFound{TemplateID: group.TemplateID}
 	}
+	return t, nil
+}
 
-	job, err := prepareJob(ctx, t, group)
+// UpdateOrchestratorJob replaces the jobs of group, returning those which
+// were registered even if another datacenter failed.
+func UpdateOrchestratorJob(ctx context.Context, group *ServiceGroup) (submissions []*JobSubmission, err error) {
+	if group.isMultiDatacenter() {
+		if err := checkDatacenterNetworks(ctx, group); err != nil {
+			return nil, err
+		}
+		return forEachSubmission(ctx, group, UpdateOrchestratorJob)
+	}
+
+	defer func() { recordReconcile(err) }()
+	defer func() { countJobOp(jobOpUpdate, err) }()
+	defer func() { auditJobOp(ctx, jobOpUpdate, group, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	t, err := groupTemplate(ctx, group)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	// we always delete the old job
-	_, err = deregisterJob(ctx, *job.ID)
+	// A new capacity starts a new canary.
+	Canaries.Forget(group.ID)
+	capacity, err := canaryCapacity(ctx, group)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	_, err = registerJob(ctx, job)
+	job, err := prepareJob(ctx, t, withCapacity(group, capacity))
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	return nil
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	submission, err := replaceJob(ctx, job)
+	if err != nil {
+		return nil, err
+	}
+	logRetireLegacyJob(ctx, group)
+	trackConvergence(group)
+
+	if err := awaitFirstRun(ctx, submission); err != nil {
+		return nil, err
+	}
+
+	return []*JobSubmission{submission}, nil
 }
 
-func DeleteOrchestratorJob(ctx context.Context, group *ServiceGroup) error {
+// ErrJobUpdate is returned when a group's updated job couldn't be registered
+// over its previous job. If the previous job was registered again the group
+// keeps scaling as it did before the update.
+type ErrJobUpdate struct {
+	Err        error
+	RolledBack bool
+	// RollbackErr is why the previous job couldn't be registered again, if
+	// there was a previous job.
+	RollbackErr error
+}
+
+func (e *ErrJobUpdate) Error() string {
+	if e.RolledBack {
+		return fmt.Sprintf("%v; rolled back to the previous job", e.Err)
+	}
+	if e.RollbackErr != nil {
+		return fmt.Sprintf("%v; unable to roll back to the previous job: %v", e.Err, e.RollbackErr)
+	}
+	return e.Err.Error()
+}
+
+func (e *ErrJobUpdate) Unwrap() error {
+	return e.Err
+}
+
+// replaceJob registers job over the registered job sharing its ID, which
+// Nomad updates in place so the group's instances are never left without a
+// job. The new job is validated before the previous one is touched, so an
+// invalid spec leaves it running. If the new job fails to register, the
+// previous job is registered again and an ErrJobUpdate is returned.
+func replaceJob(ctx context.Context, job *nomad.Job) (*JobSubmission, error) {
+	client, ok := handlers.GetNomadClient(ctx)
+	if !ok {
+		return nil, handlers.ErrNoNomadClient
+	}
+
+	if err := validateJob(ctx, client, job); err != nil {
+		return nil, err
+	}
+
+	previous, _, err := client.Jobs().Info(*job.ID, nomadScopeOf(ctx).queryOptions())
+	if err != nil {
+		if !isNotFound(err) {
+			return nil, &ErrNomad{Op: ErrNomadJobInfo, Err: err}
+		}
+		previous = nil
+	}
+
+	// Registering over the previous job keeps its history as earlier
+	// versions of the job, until Nomad garbage collects them. Nothing has
+	// changed yet, so a cancelled update stops here.
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	submission, err := registerJob(ctx, job, true)
+	if err != nil {
+		updateErr := &ErrJobUpdate{Err: err}
+		if previous == nil {
+			return nil, updateErr
+		}
+
+		if _, err := registerJob(ctx, previous, true); err != nil {
+			updateErr.RollbackErr = err
+		} else {
+			updateErr.RolledBack = true
+		}
+
+		handlers.Logger(ctx).Error().Err(updateErr.Err).
+			Str("job_id", *job.ID).
+			Bool("rolled_back", updateErr.RolledBack).
+			Msg("orchestrator: failed to register updated job")
+		return nil, updateErr
+	}
+
+	return submission, nil
+}
+
+func DeleteOrchestratorJob(ctx context.Context, group *ServiceGroup) (err error) {
+	if group.isMultiDatacenter() {
+		return forEachDatacenter(ctx, group, group.Datacenters, DeleteOrchestratorJob)
+	}
+
+	defer func() { recordReconcile(err) }()
+	defer func() { countJobOp(jobOpDelete, err) }()
+	defer func() { auditJobOp(ctx, jobOpDelete, group, err) }()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
 	session := handlers.GetAuthSession(ctx)
 
 	t, found := templates_v1.FindTemplateByID(ctx, group.TemplateID, session.AccountID)
 	if !found {
-		return errors.New("Error finding template by ID")
+		return &ErrTemplateNotFound{TemplateID: group.TemplateID}
 	}
 
-	g := group
-	g.Capacity = 0
-	job, err := prepareJob(ctx, t, g)
+	job, err := prepareJob(ctx, t, withCapacity(group, 0))
 	if err != nil {
 		return err
 	}
 
-	// Delete current version of the job
-	_, err = deregisterJob(ctx, *job.ID)
-	if err != nil {
+	// The legacy job is stopped first, so that it can't scale the group's
+	// instances back up once the current job has scaled them down.
+	if err := retireLegacyJob(ctx, group, true); err != nil {
 		return err
 	}
 
-	// Submit a new version of the job with a count of 0
-	_, err = registerJob(ctx, job)
-	if err != nil {
+	if err := removeJob(ctx, job); err != nil {
 		return err
 	}
+	health.Convergences.Forget(group.ID)
+	Budgets.Forget(group.ID)
+	Canaries.Forget(group.ID)
+
+	return nil
+}
 
-	// Delete current version of the job
-	_, err = deregisterJob(ctx, *job.ID)
+// removeJob scales the group of job down to zero and then deregisters it.
+// job, which must have a capacity of zero, replaces the registered job in
+// place so there is always a job to finish removing, whichever step fails:
+// until it's registered the previous job keeps running, and afterwards the
+// group has no instances left to lose. With a deregister wait configured the
+// scale-down run is given that long to tear the instances down before the
+// job is purged.
+func removeJob(ctx context.Context, job *nomad.Job) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	submission, err := registerJob(ctx, job, true)
 	if err != nil {
 		return err
 	}
 
-	return nil
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	// The scale-down run has no allocations to wait on until its
+	// evaluation has placed them.
+	_, err = deregisterJob(ctx, *job.ID, true, submission.EvalID, submission.PeriodicEvalID)
+	return err
 }
 
-func deregisterJob(ctx context.Context, jobID string) (bool, error) {
+// allocPollInterval is how often Nomad is polled while waiting on in-flight
+// allocations to finish.
+var allocPollInterval = 2 * time.Second
+
+// deregisterJob stops the job named jobID, first waiting on the given
+// evaluations of it and its running allocations if a deregister wait is
+// configured.
+//
+// Without purge the stopped job stays in Nomad, along with its versions,
+// evaluations and allocations, until Nomad's job garbage collector removes
+// it, by default once it's been dead for four hours. Registering a job with
+// the same ID in the meantime makes it a new version of the stopped one.
+// With purge the job and its history are removed immediately, and any
+// further lookup of it is a 404.
+func deregisterJob(ctx context.Context, jobID string, purge bool, evalIDs ...string) (bool, error) {
+	defer observeNomadCall(nomadCallDeregister, time.Now())
+
 	client, ok := handlers.GetNomadClient(ctx)
 	if !ok {
 		return false, handlers.ErrNoNomadClient
 	}
+	defer invalidateJobInfo(ctx, jobID)
+
+	if wait := config.GetDeregisterWait(); wait > 0 {
+		forced, err := waitForAllocations(ctx, client, jobID, evalIDs, wait)
+		if err != nil {
+			return false, err
+		}
+		if forced {
+			handlers.Logger(ctx).Warn().
+				Str("job_name", jobID).
+				Dur("timeout", wait).
+				Msg("orchestrator: timed out waiting on running allocations, forcing deregister")
+		}
+	}
 
-	_, _, err := client.Jobs().Deregister(jobID, true, nil)
+	err := retryNomad(ctx, "deregister", func() error {
+		_, _, err := client.Jobs().Deregister(jobID, purge, nomadScopeOf(ctx).writeOptions())
+		return err
+	})
 	if err != nil {
-		return false, fmt.Errorf("Unable to deregister job with Nomad: %v", err)
+		return false, &ErrNomad{Op: ErrNomadDeregister, Err: err}
 	}
 
 	return true, nil
 }
 
-func registerJob(ctx context.Context, job *nomad.Job) (bool, error) {
+// waitForAllocations polls Nomad until the given evaluations have been
+// processed, and then until none of the allocations belonging to jobID, or to
+// any periodic child launched by it, are still pending or running. This keeps
+// a deregister from killing a reconcile mid-operation. Returns true if the
+// timeout elapsed while evaluations or allocations were still active.
+func waitForAllocations(ctx context.Context, client *nomad.Client, jobID string, evalIDs []string, timeout time.Duration) (bool, error) {
+	deadline := time.Now().Add(timeout)
+	scope := nomadScopeOf(ctx)
+
+	for {
+		pending, err := pendingEvaluations(client, scope, evalIDs)
+		if err != nil {
+			return false, err
+		}
+		evalIDs = pending
+
+		active := len(pending)
+		if active == 0 {
+			active, err = activeAllocations(client, scope, jobID)
+			if err != nil {
+				return false, err
+			}
+		}
+		if active == 0 {
+			return false, nil
+		}
+
+		if time.Now().After(deadline) {
+			return true, nil
+		}
+
+		handlers.Logger(ctx).Debug().
+			Str("job_name", jobID).
+			Int("pending", active).
+			Msg("orchestrator: waiting on running allocations before deregister")
+
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		case <-time.After(allocPollInterval):
+		}
+	}
+}
+
+// pendingEvaluations returns those of the given evaluations which Nomad has
+// yet to process, and so to place any allocations of.
+func pendingEvaluations(client *nomad.Client, scope nomadScope, evalIDs []string) ([]string, error) {
+	var pending []string
+	for _, id := range evalIDs {
+		if id == "" {
+			continue
+		}
+
+		eval, _, err := client.Evaluations().Info(id, scope.queryOptions())
+		if err != nil {
+			return nil, &ErrNomad{Op: ErrNomadEvaluations, Err: err}
+		}
+		if eval.Status == "pending" {
+			pending = append(pending, id)
+		}
+	}
+	return pending, nil
+}
+
+// activeAllocations counts the pending or running allocations for jobID and
+// its periodic children.
+func activeAllocations(client *nomad.Client, scope nomadScope, jobID string) (int, error) {
+	jobIDs, err := jobFamilyIDs(client, scope, jobID)
+	if err != nil {
+		return 0, err
+	}
+
+	var active int
+	for _, id := range jobIDs {
+		allocs, _, err := client.Jobs().Allocations(id, false, scope.queryOptions())
+		if err != nil {
+			return 0, &ErrNomad{Op: ErrNomadAllocations, Err: err}
+		}
+
+		for _, alloc := range allocs {
+			switch alloc.ClientStatus {
+			case "pending", "running":
+				active++
+			}
+		}
+	}
+
+	return active, nil
+}
+
+// jobFamilyIDs returns jobID along with the IDs of every periodic child job
+// Nomad has launched from it in scope.
+func jobFamilyIDs(client *nomad.Client, scope nomadScope, jobID string) ([]string, error) {
+	jobIDs := []string{jobID}
+
+	children, err := childJobs(client, scope, jobID)
+	if err != nil {
+		return nil, &ErrNomad{Op: ErrNomadChildJobs, Err: err}
+	}
+	for _, child := range children {
+		if child.ParentID == jobID {
+			jobIDs = append(jobIDs, child.ID)
+		}
+	}
+
+	return jobIDs, nil
+}
+
+// childJobs lists the jobs in scope which are named like the periodic
+// children of jobID.
+func childJobs(client *nomad.Client, scope nomadScope, jobID string) ([]*nomad.JobListStub, error) {
+	q := scope.queryOptions()
+	q.Prefix = jobID + "/"
+
+	children, _, err := client.Jobs().List(q)
+	return children, err
+}
+
+// registerJob validates and registers job, then, if force is set, forces a
+// periodic instance of it to run unless its reconciles are suspended.
+func registerJob(ctx context.Context, job *nomad.Job, force bool) (*JobSubmission, error) {
+	defer observeNomadCall(nomadCallRegister, time.Now())
+
 	client, ok := handlers.GetNomadClient(ctx)
 	if !ok {
-		log.Error().Err(handlers.ErrNoNomadClient)
-		return false, handlers.ErrNoNomadClient
+		handlers.Logger(ctx).Error().Err(handlers.ErrNoNomadClient).
+			Str("job_name", *job.ID).
+			Msg("orchestrator: unable to register job")
+		return nil, handlers.ErrNoNomadClient
 	}
+	defer invalidateJobInfo(ctx, *job.ID)
+	scope := nomadScopeOf(ctx)
 
-	_, _, err := client.Jobs().Validate(job, nil)
+	err := validateJob(ctx, client, job)
 	if err != nil {
-		return false, fmt.Errorf("Failed to validate Nomad Job: %v", err)
+		return nil, err
 	}
 
-	_, _, err = client.Jobs().Register(job, nil)
+	submission := &JobSubmission{
+		Datacenter: handlers.GetAuthSession(ctx).Datacenter,
+		JobID:      *job.ID,
+	}
+
+	err = retryNomad(ctx, "register", func() error {
+		resp, _, err := client.Jobs().Register(job, scope.writeOptions())
+		if err == nil {
+			submission.EvalID = resp.EvalID
+		}
+		return err
+	})
 	if err != nil {
-		return false, fmt.Errorf("Unable to register job with Nomad: %v", err)
+		return nil, &ErrNomad{Op: ErrNomadRegister, Err: err}
+	}
+
+	if job.Periodic == nil {
+		return submission, nil
+	}
+
+	if !force {
+		handlers.Logger(ctx).Info().
+			Str("job_id", *job.ID).
+			Msg("orchestrator: leaving the first periodic instance of job to its schedule")
+		return submission, nil
+	}
+
+	if !periodicEnabled(job) {
+		handlers.Logger(ctx).Info().
+			Str("job_id", *job.ID).
+			Msg("orchestrator: reconciles are suspended, not triggering a periodic instance of job")
+		return submission, nil
 	}
 
-	_, _, err = client.Jobs().PeriodicForce(*job.ID, nil)
+	err = retryNomad(ctx, "periodic force", func() error {
+		evalID, _, err := client.Jobs().PeriodicForce(*job.ID, scope.writeOptions())
+		if err == nil {
+			submission.PeriodicEvalID = evalID
+		}
+		return err
+	})
 	if err != nil {
-		return false, fmt.Errorf("Unable to trigger a periodic instance of job: %v", err)
+		return nil, &ErrNomad{Op: ErrNomadPeriodicForce, Err: err}
 	}
 
-	return true, nil
+	return submission, nil
+}
+
+func validateJob(ctx context.Context, client *nomad.Client, job *nomad.Job) error {
+	err := retryNomad(ctx, "validate", func() error {
+		_, _, err := client.Jobs().Validate(job, nomadScopeOf(ctx).writeOptions())
+		return err
+	})
+	if err != nil {
+		return &ErrNomad{Op: ErrNomadValidate, Err: err}
+	}
+	return nil
 }
 
 func prepareJob(ctx context.Context, t *templates_v1.InstanceTemplate, group *ServiceGroup) (*nomad.Job, error) {
-	session := handlers.GetAuthSession(ctx)
+	details, err := prepareJobDetails(ctx, t, group)
+	if err != nil {
+		return nil, err
+	}
 
-	tpl := &bytes.Buffer{}
-	details := createJobDetails(t, group)
-	details.Datacenter = session.Datacenter
-	details.TSGCliVersion = config.GetTSGCliVersion()
-	if err := details.getTritonAccountDetails(ctx); err != nil {
+	logger := handlers.Logger(ctx).With().
+		Str("account_id", handlers.GetAuthSession(ctx).AccountID).
+		Str("group_name", group.GroupName).
+		Logger()
+	logJobSpec(&logger, details)
+
+	job, err := buildJob(details)
+	if err != nil {
+		if line, ok := jobSpecErrorLine(err); ok {
+			logger.Warn().Err(err).Int("line", line).Msg("orchestrator: rendered job is not valid HCL")
+		}
 		return nil, err
 	}
+	limitReconciles(group, job)
+	pauseReconciles(group, job)
+
+	return job, nil
+}
 
-	funcMap := template.FuncMap{
-		"base64_encode":   base64Encode,
-		"escape_newlines": escapeNewlines,
+// logJobSpec logs the job rendered for details at debug level, with the
+// account's private key redacted, so that a job template which renders to
+// something unexpected can be seen as it was parsed.
+func logJobSpec(logger *zerolog.Logger, details OrchestratorJob) {
+	event := logger.Debug()
+	if !event.Enabled() {
+		return
 	}
 
-	jobT := template.Must(template.New("job").Funcs(funcMap).Parse(jobTemplate))
-	err := jobT.Execute(tpl, details)
+	details.TritonKeyMaterial = ""
+	spec, err := renderJobSpec(details)
 	if err != nil {
-		return nil, err
+		event.Err(err).Msg("orchestrator: unable to render job")
+		return
+	}
+
+	event.Str("job_name", details.JobName).
+		Str("jobspec", spec).
+		Msg("orchestrator: rendered job")
+}
+
+var jobSpecErrorPos = regexp.MustCompile(`At (\d+):\d+: `)
+
+// jobSpecErrorLine returns the line of the rendered job at which parsing it
+// failed, if err has one. HCL reports the position of syntax errors as
+// "At line:column: ...", which jobspec.Parse only passes on as text.
+func jobSpecErrorLine(err error) (int, bool) {
+	renderErr, ok := err.(*ErrJobRender)
+	if !ok {
+		return 0, false
+	}
+
+	m := jobSpecErrorPos.FindStringSubmatch(renderErr.Err.Error())
+	if m == nil {
+		return 0, false
+	}
+
+	line, err := strconv.Atoi(m[1])
+	if err != nil {
+		return 0, false
 	}
+	return line, true
+}
 
-	job, err := jobspec.Parse(tpl)
+// buildJob renders and parses the job for the given details, rejecting specs
+// too large for Nomad to accept before they are submitted.
+func buildJob(details OrchestratorJob) (*nomad.Job, error) {
+	spec, err := renderJobSpec(details)
 	if err != nil {
 		return nil, err
 	}
 
+	if err := checkJobSize(details, spec); err != nil {
+		return nil, err
+	}
+
+	job, err := jobspec.Parse(strings.NewReader(spec))
+	if err != nil {
+		return nil, &ErrJobRender{Err: err}
+	}
+
 	return job, nil
 }
 
+func prepareJobDetails(ctx context.Context, t *templates_v1.InstanceTemplate, group *ServiceGroup) (OrchestratorJob, error) {
+	session := handlers.GetAuthSession(ctx)
+
+	details, err := createJobDetails(t, group)
+	if err != nil {
+		return details, err
+	}
+	details.Datacenters = jobDatacenters(session)
+	if len(details.Datacenters) == 0 {
+		return details, errNoDatacenters
+	}
+	details.Region = nomadScopeOf(ctx).Region
+	details.InstanceName = instanceNamePattern(group.InstanceNamePattern, group.GroupName, session.Datacenter)
+
+	jobType, err := config.GetJobType()
+	if err != nil {
+		return details, err
+	}
+	details.JobType = jobType
+
+	artifact, err := config.GetArtifact()
+	if err != nil {
+		return details, err
+	}
+	details.setArtifact(artifact)
+
+	if err := details.getTritonAccountDetails(ctx); err != nil {
+		return details, err
+	}
+
+	if max := config.GetMaxCapacity(details.TritonAccount); details.DesiredCount > max {
+		return details, &ErrInvalidCapacity{Capacity: details.DesiredCount, Max: max}
+	}
+
+	return details, nil
+}
+
+// errNoDatacenters is returned when there's no datacenter to place a job in.
+var errNoDatacenters = errors.New("a job requires at least one datacenter")
+
+// jobDatacenters returns the Nomad datacenters the jobs of session may be
+// placed in, by default the one named after its Triton datacenter.
+func jobDatacenters(session *auth.Session) []string {
+	if len(session.NomadDatacenters) > 0 {
+		return session.NomadDatacenters
+	}
+	if session.Datacenter == "" {
+		return nil
+	}
+	return []string{session.Datacenter}
+}
+
+// Datacenter returns the first of the job's datacenters, for job templates
+// written when a job had only one.
+func (j OrchestratorJob) Datacenter() string {
+	if len(j.Datacenters) == 0 {
+		return ""
+	}
+	return j.Datacenters[0]
+}
+
+// jobFuncs are the functions available to job templates.
+var jobFuncs = template.FuncMap{
+	"base64_encode":   base64Encode,
+	"escape_newlines": escapeNewlines,
+	"hcl_string":      hclString,
+	"json_encode":     jsonEncode,
+}
+
+// renderJobSpec renders the HCL jobspec for the given job details, with the
+// custom job template if one is set.
+func renderJobSpec(details OrchestratorJob) (string, error) {
+	jobT := getCustomJobTemplate()
+	if jobT == nil {
+		jobType := details.JobType
+		if jobType == "" {
+			jobType = config.JobTypeBatch
+		}
+		var ok bool
+		jobT, ok = parsedJobTemplates[jobType]
+		if !ok {
+			return "", fmt.Errorf("unsupported nomad job type: %q", jobType)
+		}
+	}
+
+	tpl := &bytes.Buffer{}
+	if err := jobT.Execute(tpl, details); err != nil {
+		return "", &ErrJobRender{Err: err}
+	}
+
+	return tpl.String(), nil
+}
+
+// parseJobTemplate parses the template of a job, which may refer to the task
+// group defined by scaleGroupTemplate as {{ template "scale" . }}, or define
+// a "scale" template of its own in its place.
+func parseJobTemplate(src string) (*template.Template, error) {
+	jobT, err := template.New("job").Funcs(jobFuncs).Parse(scaleGroupTemplate)
+	if err != nil {
+		return nil, err
+	}
+	return jobT.Parse(src)
+}
+
+// parseJobTemplates parses the template of each job type, panicking if any
+// of them doesn't parse.
+func parseJobTemplates(srcs map[string]string) map[string]*template.Template {
+	parsed := make(map[string]*template.Template, len(srcs))
+	for jobType, src := range srcs {
+		parsed[jobType] = template.Must(parseJobTemplate(src))
+	}
+	return parsed
+}
+
+// setArtifact configures where the tsg-cli release is fetched from and to,
+// along with the command which runs it from there. The release is verified
+// against its checksum when one is known for its version.
+func (j *OrchestratorJob) setArtifact(artifact config.Artifact) {
+	j.Artifact = artifact
+	j.TSGCliSource = artifact.Source(j.TSGCliVersion)
+
+	if checksum, ok := artifact.Checksums[j.TSGCliVersion]; ok {
+		options := make(map[string]string, len(artifact.Options)+1)
+		for key, value := range artifact.Options {
+			options[key] = value
+		}
+		options["checksum"] = checksum
+		j.Artifact.Options = options
+	} else if _, ok := artifact.Options["checksum"]; !ok {
+		log.Warn().
+			Str("version", j.TSGCliVersion).
+			Msg("orchestrator: no checksum is known for the tsg-cli release, fetching it unverified")
+	}
+
+	j.TSGCliCommand = artifact.Command(j.TSGCliVersion)
+}
+
 func (j *OrchestratorJob) getTritonAccountDetails(ctx context.Context) error {
 	session := handlers.GetAuthSession(ctx)
 
 	db, ok := handlers.GetDBPool(ctx)
 	if !ok {
-		log.Error().Err(handlers.ErrNoConnPool)
+		handlers.Logger(ctx).Error().Err(handlers.ErrNoConnPool).
+			Str("account_id", session.AccountID).
+			Msg("orchestrator: unable to find account")
 		return handlers.ErrNoConnPool
 	}
 
@@ -207,121 +970,433 @@ func (j *OrchestratorJob) getTritonAccountDetails(ctx context.Context) error {
 
 	account, err := store.FindByID(ctx, session.AccountID)
 	if err != nil {
-		log.Error().Err(err)
+		handlers.Logger(ctx).Error().Err(err).
+			Str("account_id", session.AccountID).
+			Msg("orchestrator: unable to find account")
 		return err
 	}
 
 	credential, err := account.GetTritonCredential(ctx)
 	if err != nil {
-		log.Error().Err(err)
+		handlers.Logger(ctx).Error().Err(err).
+			Str("account_id", account.ID).
+			Str("account_name", account.AccountName).
+			Msg("orchestrator: unable to find triton credentials for account")
 		return err
 	}
 
-	log.Debug().
+	handlers.Logger(ctx).Debug().
 		Str("account_id", account.ID).
 		Str("account_name", account.AccountName).
 		Str("fingerprint", credential.KeyID).
 		Msg("orchestrator: found triton credentials for account")
 
-	j.TritonKeyMaterial = credential.KeyMaterial
+	source, err := config.GetKeySource()
+	if err != nil {
+		return err
+	}
+	if source.Source == config.KeySourceVault {
+		j.VaultKeyPath = source.SecretPath(account.ID)
+		j.VaultKeyField = source.VaultField
+		j.VaultPolicies = source.VaultPolicies
+	} else {
+		j.TritonKeyMaterial = credential.KeyMaterial
+	}
 	j.TritonAccount = credential.AccountName
 	j.TritonKeyID = credential.KeyID
 	j.TritonURL = session.TritonURL
 
-	j.JobName = fmt.Sprintf("%s_%s", j.ServiceGroupName, account.TritonUUID)
+	j.JobName = jobName(j.ServiceGroupName, account.JobRef())
 
 	return nil
 }
 
-func createJobDetails(template *templates_v1.InstanceTemplate, group *ServiceGroup) OrchestratorJob {
+// resolveJobName looks up the account owning the current session and returns
+// the Nomad job name used for group.
+func resolveJobName(ctx context.Context, group *ServiceGroup) (string, error) {
+	account, err := findSessionAccount(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return jobName(group.GroupName, account.JobRef()), nil
+}
+
+// findSessionAccount is swapped out by tests.
+var findSessionAccount = func(ctx context.Context) (*accounts.Account, error) {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return nil, handlers.ErrNoConnPool
+	}
+
+	return accounts.NewStore(db).FindByID(ctx, handlers.GetAuthSession(ctx).AccountID)
+}
+
+// retireLegacyJob stops the job group has under its legacy name, if it still
+// has one, so that only its job under the current name manages its
+// instances. With purge the legacy job and its history are removed, as when
+// the group is deleted.
+func retireLegacyJob(ctx context.Context, group *ServiceGroup, purge bool) error {
+	client, ok := handlers.GetNomadClient(ctx)
+	if !ok {
+		return handlers.ErrNoNomadClient
+	}
+
+	account, err := findSessionAccount(ctx)
+	if err != nil {
+		return err
+	}
+	if account.TritonUUID == "" {
+		return nil
+	}
+	name := legacyJobName(group.GroupName, account.TritonUUID)
+
+	_, _, err = client.Jobs().Info(name, nomadScopeOf(ctx).queryOptions())
+	if err != nil {
+		if isNotFound(err) {
+			return nil
+		}
+		return &ErrNomad{Op: ErrNomadJobInfo, Err: err}
+	}
+
+	if _, err := deregisterJob(ctx, name, purge); err != nil {
+		return err
+	}
+
+	handlers.Logger(ctx).Info().
+		Str("group_name", group.GroupName).
+		Str("job_id", name).
+		Msg("orchestrator: retired job with legacy name")
+	return nil
+}
+
+// logRetireLegacyJob retires the legacy job of group once its current job has
+// been registered. Failing to is only logged, since the group's current job
+// is registered by then, and drift detection reports the legacy job as
+// orphaned until it's retired.
+func logRetireLegacyJob(ctx context.Context, group *ServiceGroup) {
+	if err := retireLegacyJob(ctx, group, false); err != nil {
+		handlers.Logger(ctx).Error().Err(err).
+			Str("group_name", group.GroupName).
+			Msg("orchestrator: unable to retire job with legacy name")
+	}
+}
+
+// jobName builds the Nomad job name for a service group owned by the account
+// identified by jobRef. The opaque account reference is used instead of the
+// Triton UUID so internal identifiers aren't exposed through Nomad.
+func jobName(groupName, jobRef string) string {
+	return fmt.Sprintf("%s_%s", groupName, jobRef)
+}
+
+// legacyJobName is the name the job of a group had before jobs were named
+// after their account's job reference, with the account's Triton UUID in its
+// place. Such jobs are retired once their group's job is next registered or
+// removed, see retireLegacyJob.
+func legacyJobName(groupName, tritonUUID string) string {
+	return jobName(groupName, tritonUUID)
+}
+
+// parseJobName reverses jobName, splitting a Nomad job name into the service
+// group name and the owning account's job reference.
+func parseJobName(name string) (string, string, bool) {
+	idx := strings.LastIndex(name, "_")
+	if idx <= 0 || idx == len(name)-1 {
+		return "", "", false
+	}
+
+	return name[:idx], name[idx+1:], true
+}
+
+// periodicParent returns the name of the job a periodic instance was launched
+// from, or name itself if it isn't one. Nomad names periodic instances after
+// their parent followed by "/periodic-" and the time they were launched.
+func periodicParent(name string) string {
+	if idx := strings.LastIndex(name, "/periodic-"); idx > 0 {
+		return name[:idx]
+	}
+	return name
+}
+
+// createJobDetails collects the details of the group's job from the group and
+// its template, rejecting values which can't be written into it safely.
+func createJobDetails(template *templates_v1.InstanceTemplate, group *ServiceGroup) (OrchestratorJob, error) {
+	template = withInstanceOverrides(template, group)
+
+	if err := checkJobValues(template, group); err != nil {
+		return OrchestratorJob{}, err
+	}
+
+	packageID, err := tritonID("package", template.Package)
+	if err != nil {
+		return OrchestratorJob{}, err
+	}
+	imageID, err := tritonID("image", template.ImageID)
+	if err != nil {
+		return OrchestratorJob{}, err
+	}
+
+	if group.Capacity < 0 {
+		return OrchestratorJob{}, &ErrInvalidCapacity{Capacity: group.Capacity}
+	}
+
 	job := OrchestratorJob{
 		DesiredCount:     group.Capacity,
-		PackageID:        template.Package,
-		ImageID:          template.ImageID,
+		PackageID:        packageID,
+		ImageID:          imageID,
+		ServiceGroupID:   group.ID,
 		ServiceGroupName: group.GroupName,
 		FirewallEnabled:  template.FirewallEnabled,
+		FirewallRules:    template.FirewallRules,
 		TemplateID:       template.ID,
+		TSGCliVersion:    tsgCliVersion(group),
+		CPU:              config.GetTaskCPU(),
+		MemoryMB:         config.GetTaskMemoryMB(),
+		JSONArgs:         config.GetTSGCliJSONArgs(),
+	}
+
+	if template.TaskCPU > 0 {
+		job.CPU = template.TaskCPU
+	}
+
+	if template.TaskMemoryMB > 0 {
+		job.MemoryMB = template.TaskMemoryMB
 	}
 
-	if template.UserData != "" {
-		job.UserData = template.UserData
+	constraints, err := config.GetConstraints()
+	if err != nil {
+		return job, err
 	}
+	job.Constraints = constraints
 
-	if len(template.Networks) > 0 {
-		job.Networks = template.Networks
+	if job.Restart, err = restartPolicy(group); err != nil {
+		return job, err
 	}
 
-	if template.Tags != nil {
-		job.Tags = template.Tags
+	if job.Reschedule, err = reschedulePolicy(group); err != nil {
+		return job, err
 	}
 
-	if template.MetaData != nil {
-		job.MetaData = template.MetaData
+	if job.TimeZone, err = jobTimeZone(group); err != nil {
+		return job, err
 	}
 
-	return job
+	job.UserData = template.UserData
+	job.Networks = template.Networks
+	job.Tags = template.Tags
+	job.MetaData = template.MetaData
+
+	return job, nil
 }
 
 func base64Encode(s string) string {
 	return base64.StdEncoding.EncodeToString([]byte(s))
 }
 
+// jsonEncode serializes v, such as the tags or metadata of a job, to JSON.
+// Map keys are sorted, so the same map always renders the same job.
+func jsonEncode(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
 func escapeNewlines(s string) string {
 	return strings.Replace(s, "\n", "\\n", -1)
 }
 
-const jobTemplate = `
-job "{{ .JobName }}" {
+// jobTemplates holds the template of the job of each job type. Both run the
+// task group defined by scaleGroupTemplate.
+var jobTemplates = map[string]string{
+	config.JobTypeBatch:   batchJobTemplate,
+	config.JobTypeService: serviceJobTemplate,
+}
+
+// parsedJobTemplates holds jobTemplates parsed once rather than on every
+// render. Parsed templates are safe to execute concurrently, so each render
+// executes them as they are.
+var parsedJobTemplates = parseJobTemplates(jobTemplates)
+
+// batchJobTemplate reconciles the group on every tick of its periodic
+// schedule, with each run of tsg-cli launched as a child job.
+const batchJobTemplate = `
+job "{{ .JobName | hcl_string }}" {
   type = "batch"
+  meta {
+    tsg_group_id = "{{ .ServiceGroupID | hcl_string }}"
+  }
   periodic {
 	cron = "*/2 * * * * *"
 	prohibit_overlap = true
+	{{- with .TimeZone }}
+	time_zone = "{{ . | hcl_string }}"
+	{{- end }}
+  }
+  datacenters = [{{ range $i, $dc := .Datacenters }}{{ if $i }}, {{ end }}"{{ $dc | hcl_string }}"{{ end }}]
+  {{- with .Region }}
+  region = "{{ . | hcl_string }}"
+  {{- end }}
+  {{ template "scale" . }}
+}
+`
+
+// serviceJobTemplate reconciles the group continuously from a single
+// long-running task, which runs tsg-cli in a loop.
+const serviceJobTemplate = `
+job "{{ .JobName | hcl_string }}" {
+  type = "service"
+  meta {
+    tsg_group_id = "{{ .ServiceGroupID | hcl_string }}"
   }
-  datacenters = ["{{ .Datacenter }}"]
-  group "scale" {
+  datacenters = [{{ range $i, $dc := .Datacenters }}{{ if $i }}, {{ end }}"{{ $dc | hcl_string }}"{{ end }}]
+  {{- with .Region }}
+  region = "{{ . | hcl_string }}"
+  {{- end }}
+  {{ template "scale" . }}
+}
+`
+
+// scaleGroupTemplate is the task group which runs tsg-cli. Services run it
+// through a shell loop, which passes along the same arguments.
+const scaleGroupTemplate = `
+{{- define "scale" -}}
+group "scale" {
     constraint {
       distinct_hosts = true
     }
+    {{- range .Constraints }}
     constraint {
-      operator = "="
-      attribute = "${meta.role}"
-      value = "automater"
+      operator = "{{ .Operator | hcl_string }}"
+      attribute = "{{ .Attribute | hcl_string }}"
+      value = "{{ .Value | hcl_string }}"
     }
+    {{- end }}
+    {{- with .Restart }}
+    {{- if or .Attempts .Interval .Delay .Mode }}
+    restart {
+      {{- with .Attempts }}
+      attempts = {{ . }}
+      {{- end }}
+      {{- with .Interval }}
+      interval = "{{ . }}"
+      {{- end }}
+      {{- with .Delay }}
+      delay = "{{ . }}"
+      {{- end }}
+      {{- with .Mode }}
+      mode = "{{ . | hcl_string }}"
+      {{- end }}
+    }
+    {{- end }}
+    {{- end }}
+    {{- with .Reschedule }}
+    {{- if or .Attempts .Interval }}
+    reschedule {
+      {{- with .Attempts }}
+      attempts = {{ . }}
+      {{- end }}
+      {{- with .Interval }}
+      interval = "{{ . }}"
+      {{- end }}
+    }
+    {{- end }}
+    {{- end }}
     task "healthy" {
       driver = "exec"
       artifact {
-        source = "https://github.com/joyent/tsg-cli/releases/download/v{{ .TSGCliVersion }}/tsg-cli_{{ .TSGCliVersion }}_linux_amd64.tar.gz"
+        source = "{{ .TSGCliSource | hcl_string }}"
+        {{- with .Artifact }}
+        {{- if .Destination }}
+        destination = "{{ .Destination | hcl_string }}"
+        mode = "{{ .Mode | hcl_string }}"
+        {{- end }}
+        {{- if .Options }}
+        options {
+          {{- range $key, $value := .Options }}
+          "{{ $key | hcl_string }}" = "{{ $value | hcl_string }}"
+          {{- end }}
+        }
+        {{- end }}
+        {{- end }}
+      }
+      resources {
+        cpu = {{ .CPU }}
+        memory = {{ .MemoryMB }}
       }
+      {{- if .VaultKeyPath }}
+      vault {
+        policies = [{{ range $i, $policy := .VaultPolicies }}{{ if $i }}, {{ end }}"{{ $policy | hcl_string }}"{{ end }}]
+      }
+      template {
+        data = "TSG_KEY_MATERIAL=[[ with secret \"{{ .VaultKeyPath | hcl_string }}\" ]][[ .Data.{{ .VaultKeyField }} | base64Encode ]][[ end ]]"
+        destination = "secrets/triton_key.env"
+        env = true
+        left_delimiter = "[["
+        right_delimiter = "]]"
+      }
+      {{- end }}
       config {
-        command = "tsg-cli"
+        {{- if eq .JobType "service" }}
+        command = "/bin/sh"
+	args = [
+	  "-c", "while true; do \"$0\" \"$@\"; sleep 2; done",
+	  "{{ or .TSGCliCommand "tsg-cli" | hcl_string }}",
+        {{- else }}
+        command = "{{ or .TSGCliCommand "tsg-cli" | hcl_string }}"
 	args = [
+        {{- end }}
 	  "scale",
 	  "--count", "{{ .DesiredCount }}",
-	  "--pkg-id", "{{ .PackageID }}",
-	  "--img-id", "{{ .ImageID }}",
-	  "--tsg-name", "{{ .ServiceGroupName }}",
-	  "--template-id", "{{ .TemplateID }}",
+	  "--pkg-id", "{{ .PackageID | hcl_string }}",
+	  "--img-id", "{{ .ImageID | hcl_string }}",
+	  "--tsg-name", "{{ .ServiceGroupName | hcl_string }}",
+	  "--template-id", "{{ .TemplateID | hcl_string }}",
+	  {{if .InstanceName -}}
+	  "--name-pattern", "{{ .InstanceName | hcl_string }}",
+	  {{- end }}
 	  {{if .UserData -}}
 	  "--userdata", "{{ .UserData | base64_encode }}",
 	  {{- end }}
 	  {{ range .Networks }}
-	  "--networks", "{{ . }}",
+	  "--networks", "{{ . | hcl_string }}",
+	  {{- end }}
+	  {{ if .FirewallEnabled -}}
+	  "--firewall",
+	  {{- end }}
+	  {{ range .FirewallRules }}
+	  "--firewall-rule", "{{ . | hcl_string }}",
 	  {{- end }}
+	  {{ if .JSONArgs -}}
+	  {{ if .Tags -}}
+	  "--tags-json", "{{ json_encode .Tags | base64_encode }}",
+	  {{- end }}
+	  {{ if .MetaData -}}
+	  "--metadata-json", "{{ json_encode .MetaData | base64_encode }}",
+	  {{- end }}
+	  {{- else -}}
 	  {{ range $key, $value := .Tags }}
-	  "--tag", "{{ $key }}={{ $value }}",
+	  "--tag", "{{ printf "%s=%s" $key $value | hcl_string }}",
 	  {{- end }}
 	  {{ range $key, $value := .MetaData }}
 	  "--metadata", "{{ printf "%s=%s" $key $value | base64_encode }}",
 	  {{- end }}
-	  "-A", "{{ .TritonAccount }}",
-	  "-K", "{{ .TritonKeyID }}",
-	  "-U", "{{ .TritonURL }}",
-	  {{ if .TritonKeyMaterial -}}
+	  {{- end }}
+	  "-A", "{{ .TritonAccount | hcl_string }}",
+	  "-K", "{{ .TritonKeyID | hcl_string }}",
+	  "-U", "{{ .TritonURL | hcl_string }}",
+	  {{ if gt (len .Datacenters) 1 -}}
+	  "--datacenter", "${node.datacenter}",
+	  {{- end }}
+	  {{ if .VaultKeyPath -}}
+	  "--key-material", "${TSG_KEY_MATERIAL}",
+	  {{- else if .TritonKeyMaterial -}}
 	  "--key-material", "{{ .TritonKeyMaterial | base64_encode }}",
 	  {{- end }}
 	]
       }
     }
   }
-}
+{{- end -}}
 `
@@ -1,7 +1,29 @@
 package groups_v1
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"path"
+	"strings"
 	"testing"
+	"time"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/jobspec"
+	"github.com/joyent/triton-service-groups/accounts"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/joyent/triton-service-groups/testutils"
+	"github.com/rs/zerolog"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestBase64Encode(t *testing.T) {
@@ -29,6 +51,213 @@ func TestBase64Encode(t *testing.T) {
 
 }
 
+func TestJSONEncode(t *testing.T) {
+	encoded, err := jsonEncode(map[string]string{"b": "2", "a": "x=y"})
+	require.NoError(t, err)
+	assert.Equal(t, `{"a":"x=y","b":"2"}`, encoded)
+
+	encoded, err = jsonEncode(map[string]string(nil))
+	require.NoError(t, err)
+	assert.Equal(t, "null", encoded)
+}
+
+func TestRenderJSONArgs(t *testing.T) {
+	tmpl := &templates_v1.InstanceTemplate{
+		ID:      "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+		Package: "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+		ImageID: "342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+		Tags:    map[string]string{"role": "web=frontend", "env": "prod"},
+		MetaData: map[string]string{
+			"user-script": "#!/bin/sh\necho \"${HOME}\"\n",
+			"owner":       "ops",
+		},
+	}
+
+	render := func(t *testing.T, jsonArgs bool) []string {
+		defer viper.Reset()
+		viper.Set(config.KeyTSGCliJSONArgs, jsonArgs)
+
+		details, err := createJobDetails(tmpl, &ServiceGroup{GroupName: "web", Capacity: 2})
+		require.NoError(t, err)
+		details.JobName = jobName("web", "c2e4d1491ce423e3")
+		details.Datacenters = []string{"us-sw-1"}
+
+		job, err := buildJob(details)
+		require.NoError(t, err)
+
+		var args []string
+		for _, arg := range job.TaskGroups[0].Tasks[0].Config["args"].([]interface{}) {
+			args = append(args, arg.(string))
+		}
+		return args
+	}
+
+	decode := func(t *testing.T, arg string) map[string]string {
+		b, err := base64.StdEncoding.DecodeString(arg)
+		require.NoError(t, err)
+		var m map[string]string
+		require.NoError(t, json.Unmarshal(b, &m))
+		return m
+	}
+
+	t.Run("json", func(t *testing.T) {
+		args := render(t, true)
+		assert.Empty(t, filterArgs(args, "--tag"))
+		assert.Empty(t, filterArgs(args, "--metadata"))
+		require.Len(t, filterArgs(args, "--tags-json"), 1)
+		require.Len(t, filterArgs(args, "--metadata-json"), 1)
+		assert.Equal(t, tmpl.Tags, decode(t, argValue(args, "--tags-json")))
+		assert.Equal(t, tmpl.MetaData, decode(t, argValue(args, "--metadata-json")))
+	})
+
+	t.Run("pairs", func(t *testing.T) {
+		args := render(t, false)
+		assert.Empty(t, filterArgs(args, "--tags-json"))
+		assert.Empty(t, filterArgs(args, "--metadata-json"))
+		assert.Len(t, filterArgs(args, "--tag"), 2)
+		assert.Len(t, filterArgs(args, "--metadata"), 2)
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		defer func(tags, metadata map[string]string) { tmpl.Tags, tmpl.MetaData = tags, metadata }(tmpl.Tags, tmpl.MetaData)
+		tmpl.Tags, tmpl.MetaData = nil, nil
+
+		args := render(t, true)
+		assert.Empty(t, filterArgs(args, "--tags-json"))
+		assert.Empty(t, filterArgs(args, "--metadata-json"))
+	})
+}
+
+func TestRenderFirewallArgs(t *testing.T) {
+	render := func(t *testing.T, tmpl *templates_v1.InstanceTemplate) []string {
+		details, err := createJobDetails(tmpl, &ServiceGroup{GroupName: "web", Capacity: 2})
+		require.NoError(t, err)
+		details.JobName = jobName("web", "c2e4d1491ce423e3")
+		details.Datacenters = []string{"us-sw-1"}
+
+		job, err := buildJob(details)
+		require.NoError(t, err)
+
+		var args []string
+		for _, arg := range job.TaskGroups[0].Tasks[0].Config["args"].([]interface{}) {
+			args = append(args, arg.(string))
+		}
+		return args
+	}
+
+	tmpl := &templates_v1.InstanceTemplate{
+		ID:              "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+		Package:         "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+		ImageID:         "342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+		FirewallEnabled: true,
+		FirewallRules: []string{
+			`FROM any TO tag "role" = "web" ALLOW tcp PORT 443`,
+			"FROM subnet 10.0.0.0/8 TO all vms ALLOW tcp PORT 22",
+		},
+	}
+
+	args := render(t, tmpl)
+	assert.Len(t, filterArgs(args, "--firewall"), 1)
+
+	var rules []string
+	for i, arg := range args {
+		if arg == "--firewall-rule" {
+			rules = append(rules, args[i+1])
+		}
+	}
+	assert.Equal(t, tmpl.FirewallRules, rules)
+
+	args = render(t, &templates_v1.InstanceTemplate{
+		ID:      tmpl.ID,
+		Package: tmpl.Package,
+		ImageID: tmpl.ImageID,
+	})
+	assert.Empty(t, filterArgs(args, "--firewall"))
+	assert.Empty(t, filterArgs(args, "--firewall-rule"))
+}
+
+func TestRenderVaultKey(t *testing.T) {
+	for _, jobType := range []string{config.JobTypeBatch, config.JobTypeService} {
+		t.Run(jobType, func(t *testing.T) {
+			details := sampleJobDetails(jobType)
+			material := details.TritonKeyMaterial
+			details.TritonKeyMaterial = ""
+			details.VaultKeyPath = "secret/tsg/6d6f3c38-2ed5-4b7a-a0ad-8a2caa32bbb4"
+			details.VaultKeyField = "data.key_material"
+			details.VaultPolicies = []string{"tsg", "tsg-keys"}
+
+			spec, err := renderJobSpec(details)
+			require.NoError(t, err)
+			assert.NotContains(t, spec, base64Encode(material))
+
+			job, err := buildJob(details)
+			require.NoError(t, err)
+
+			task := job.TaskGroups[0].Tasks[0]
+			require.NotNil(t, task.Vault)
+			assert.Equal(t, []string{"tsg", "tsg-keys"}, task.Vault.Policies)
+
+			require.Len(t, task.Templates, 1)
+			tmpl := task.Templates[0]
+			assert.Equal(t, `TSG_KEY_MATERIAL=[[ with secret "secret/tsg/6d6f3c38-2ed5-4b7a-a0ad-8a2caa32bbb4" ]][[ .Data.data.key_material | base64Encode ]][[ end ]]`, *tmpl.EmbeddedTmpl)
+			assert.Equal(t, "secrets/triton_key.env", *tmpl.DestPath)
+			assert.True(t, *tmpl.Envvars)
+			assert.Equal(t, "[[", *tmpl.LeftDelim)
+			assert.Equal(t, "]]", *tmpl.RightDelim)
+
+			var args []string
+			for _, arg := range task.Config["args"].([]interface{}) {
+				args = append(args, arg.(string))
+			}
+			assert.Len(t, filterArgs(args, "--key-material"), 1)
+			assert.Equal(t, "${TSG_KEY_MATERIAL}", argValue(args, "--key-material"))
+		})
+	}
+
+	job, err := buildJob(sampleJobDetails(config.JobTypeBatch))
+	require.NoError(t, err)
+	assert.Nil(t, job.TaskGroups[0].Tasks[0].Vault)
+	assert.Empty(t, job.TaskGroups[0].Tasks[0].Templates)
+}
+
+func TestLogJobSpec(t *testing.T) {
+	var out bytes.Buffer
+	logger := zerolog.New(&out).Level(zerolog.DebugLevel)
+
+	details := sampleJobDetails(config.JobTypeBatch)
+	logJobSpec(&logger, details)
+
+	var entry map[string]interface{}
+	require.NoError(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "debug", entry["level"])
+	assert.Equal(t, details.JobName, entry["job_name"])
+
+	spec, ok := entry["jobspec"].(string)
+	require.True(t, ok)
+	assert.Contains(t, spec, details.ServiceGroupName)
+	assert.NotContains(t, spec, "--key-material")
+	assert.NotContains(t, spec, base64Encode(details.TritonKeyMaterial))
+
+	out.Reset()
+	logger = logger.Level(zerolog.InfoLevel)
+	logJobSpec(&logger, details)
+	assert.Empty(t, out.String())
+}
+
+func TestJobSpecErrorLine(t *testing.T) {
+	_, err := jobspec.Parse(strings.NewReader("job \"web\" {\n  type = \"batch\"\n  meta {\n}\n"))
+	require.Error(t, err)
+
+	line, ok := jobSpecErrorLine(&ErrJobRender{Err: err})
+	assert.True(t, ok)
+	assert.Equal(t, 5, line)
+
+	_, ok = jobSpecErrorLine(err)
+	assert.False(t, ok, "only render errors have a line")
+	_, ok = jobSpecErrorLine(&ErrJobRender{Err: errors.New("template: job:1: function \"shout\" not defined")})
+	assert.False(t, ok)
+}
+
 func TestEscapeNewlines(t *testing.T) {
 	tests := []struct {
 		value   string
@@ -60,3 +289,729 @@ func TestEscapeNewlines(t *testing.T) {
 		}
 	}
 }
+
+func TestWaitForAllocations(t *testing.T) {
+	allocPollInterval = 10 * time.Millisecond
+
+	const jobID = "test-group_6f873d02"
+	childID := jobID + "/periodic-1525209600"
+
+	fake := testutils.NewFakeNomad(t)
+	defer fake.Close()
+
+	fake.HandleJSON("/v1/jobs", []*nomad.JobListStub{
+		{ID: childID, ParentID: jobID, Status: "running"},
+	})
+
+	var polls int
+	fake.HandleFunc("/v1/job/", func(w http.ResponseWriter, r *http.Request) {
+		var allocs []*nomad.AllocationListStub
+
+		if r.URL.Path == "/v1/job/"+childID+"/allocations" {
+			status := "running"
+			if polls++; polls > 2 {
+				status = "complete"
+			}
+			allocs = append(allocs, &nomad.AllocationListStub{
+				ID:           "b5ae4c4b-2cc7-4c7d-9e05-4b3e5a7e1c2f",
+				JobID:        childID,
+				ClientStatus: status,
+			})
+		}
+
+		testutils.WriteJSON(w, allocs)
+	})
+
+	t.Run("running then complete", func(t *testing.T) {
+		polls = 0
+
+		forced, err := waitForAllocations(context.Background(), fake.Client, jobID, nil, time.Second)
+		require.NoError(t, err)
+		assert.False(t, forced)
+		assert.Equal(t, 3, polls)
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		polls = -100
+
+		forced, err := waitForAllocations(context.Background(), fake.Client, jobID, nil, 50*time.Millisecond)
+		require.NoError(t, err)
+		assert.True(t, forced)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		polls = -100
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := waitForAllocations(ctx, fake.Client, jobID, nil, time.Second)
+		assert.Equal(t, context.Canceled, err)
+	})
+}
+
+func TestJobName(t *testing.T) {
+	const (
+		groupName  = "jolly-jelly"
+		tritonUUID = "87307a00-ab96-4fec-8df7-1a256e49fbcc"
+	)
+
+	name := jobName(groupName, accounts.JobRef(tritonUUID))
+	assert.NotContains(t, name, tritonUUID)
+	assert.Equal(t, "jolly-jelly_c2e4d1491ce423e3", name)
+
+	// the same group always derives the same name, so deletes hit the job
+	// that was originally submitted
+	assert.Equal(t, name, jobName(groupName, accounts.JobRef(tritonUUID)))
+
+	parsedName, jobRef, ok := parseJobName(name)
+	require.True(t, ok)
+	assert.Equal(t, groupName, parsedName)
+	assert.Equal(t, accounts.JobRef(tritonUUID), jobRef)
+
+	parsedName, _, ok = parseJobName(jobName("under_scored", "c2e4d1491ce423e3"))
+	require.True(t, ok)
+	assert.Equal(t, "under_scored", parsedName)
+
+	for _, bad := range []string{"", "nounderscore", "_leading", "trailing_"} {
+		_, _, ok := parseJobName(bad)
+		assert.False(t, ok, bad)
+	}
+}
+
+func TestPeriodicParent(t *testing.T) {
+	const jobID = "web_c2e4d1491ce423e3"
+
+	assert.Equal(t, jobID, periodicParent(jobID+"/periodic-1525209600"))
+	assert.Equal(t, jobID, periodicParent(jobID))
+	assert.Equal(t, "/periodic-1525209600", periodicParent("/periodic-1525209600"))
+}
+
+func TestRetireLegacyJob(t *testing.T) {
+	const tritonUUID = "87307a00-ab96-4fec-8df7-1a256e49fbcc"
+
+	// The legacy job has no allocations to wait on.
+	defer viper.Reset()
+	viper.Set(config.KeyNomadDeregisterWait, 0)
+
+	defer func(f func(ctx context.Context) (*accounts.Account, error)) {
+		findSessionAccount = f
+	}(findSessionAccount)
+	findSessionAccount = func(ctx context.Context) (*accounts.Account, error) {
+		return &accounts.Account{TritonUUID: tritonUUID}, nil
+	}
+
+	fake := testutils.NewFakeNomad(t)
+	defer fake.Close()
+
+	legacyID := legacyJobName("web", tritonUUID)
+	var deregistered []string
+	fake.HandleFunc("/v1/job/"+legacyID, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deregistered = append(deregistered, r.URL.Query().Get("purge"))
+			testutils.WriteJSON(w, &nomad.JobDeregisterResponse{})
+			return
+		}
+		testutils.WriteJSON(w, &nomad.Job{ID: &legacyID})
+	})
+
+	ctx := handlers.WithAuthSession(context.Background(), &auth.Session{Datacenter: "us-east-1"})
+	ctx = handlers.WithNomadClient(ctx, fake.Client)
+
+	// The job of a group created before jobs were named after the account's
+	// job reference is stopped once the group's job is replaced.
+	require.NoError(t, retireLegacyJob(ctx, &ServiceGroup{GroupName: "web"}, false))
+	assert.Equal(t, []string{"false"}, deregistered)
+
+	// And purged when the group is deleted.
+	require.NoError(t, retireLegacyJob(ctx, &ServiceGroup{GroupName: "web"}, true))
+	assert.Equal(t, []string{"false", "true"}, deregistered)
+
+	// Groups created since have no legacy job to retire.
+	require.NoError(t, retireLegacyJob(ctx, &ServiceGroup{GroupName: "db"}, false))
+	assert.Len(t, deregistered, 2)
+}
+
+func TestListEvaluations(t *testing.T) {
+	const jobID = "test-group_c2e4d1491ce423e3"
+	childID := jobID + "/periodic-1525209600"
+
+	fake := testutils.NewFakeNomad(t)
+	defer fake.Close()
+
+	fake.HandleJSON("/v1/jobs", []*nomad.JobListStub{
+		{ID: childID, ParentID: jobID},
+		{ID: "other_c2e4d1491ce423e3/periodic-1525209600", ParentID: "other_c2e4d1491ce423e3"},
+	})
+	fake.HandleFunc("/v1/job/", func(w http.ResponseWriter, r *http.Request) {
+		var evals []*nomad.Evaluation
+
+		switch r.URL.Path {
+		case "/v1/job/" + jobID + "/evaluations":
+			evals = []*nomad.Evaluation{
+				{ID: "eval-1", JobID: jobID, Status: "complete", TriggeredBy: "job-register", CreateIndex: 10},
+			}
+		case "/v1/job/" + childID + "/evaluations":
+			evals = []*nomad.Evaluation{
+				{ID: "eval-2", JobID: childID, Status: "complete", TriggeredBy: "periodic-job", CreateIndex: 20},
+				{ID: "eval-3", JobID: childID, Status: "failed", TriggeredBy: "alloc-failure", CreateIndex: 30},
+			}
+		}
+
+		testutils.WriteJSON(w, evals)
+	})
+
+	page, err := listEvaluations(fake.Client, nomadScope{}, jobID, 0, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, page.Total)
+	assert.Equal(t, defaultEvaluationLimit, page.Limit)
+	require.Len(t, page.Evaluations, 3)
+
+	// newest evaluation first
+	assert.Equal(t, "eval-3", page.Evaluations[0].ID)
+	assert.Equal(t, "failed", page.Evaluations[0].Status)
+	assert.Equal(t, "alloc-failure", page.Evaluations[0].TriggeredBy)
+	require.NotNil(t, page.Evaluations[0].LaunchedAt)
+	assert.Equal(t, int64(1525209600), page.Evaluations[0].LaunchedAt.Unix())
+	assert.Equal(t, "eval-1", page.Evaluations[2].ID)
+	assert.Nil(t, page.Evaluations[2].LaunchedAt)
+
+	page, err = listEvaluations(fake.Client, nomadScope{}, jobID, 1, 1)
+	require.NoError(t, err)
+	assert.Equal(t, 3, page.Total)
+	require.Len(t, page.Evaluations, 1)
+	assert.Equal(t, "eval-2", page.Evaluations[0].ID)
+
+	page, err = listEvaluations(fake.Client, nomadScope{}, jobID, 1000, 5)
+	require.NoError(t, err)
+	assert.Equal(t, maxEvaluationLimit, page.Limit)
+	assert.Empty(t, page.Evaluations)
+}
+
+func TestMissingTagsStatus(t *testing.T) {
+	err := &templates_v1.ErrMissingTags{Keys: []string{"cost-center", "owner"}}
+	assert.Equal(t, http.StatusUnprocessableEntity, orchestratorErrorStatus(err))
+
+	// Every datacenter rejects the same template, so the group is rejected.
+	assert.Equal(t, http.StatusUnprocessableEntity, orchestratorErrorStatus(&ErrDatacenters{
+		Errors: map[string]error{"us-east-1": err, "us-west-1": err},
+	}))
+}
+
+func TestBuildJobTypes(t *testing.T) {
+	details := testJobDetails(nil)
+
+	batch, err := buildJob(details)
+	require.NoError(t, err)
+	assert.Equal(t, "batch", *batch.Type)
+	require.NotNil(t, batch.Periodic)
+	task := batch.TaskGroups[0].Tasks[0]
+	assert.Equal(t, "tsg-cli", task.Config["command"])
+	assert.Equal(t, "scale", task.Config["args"].([]interface{})[0])
+
+	details.JobType = config.JobTypeService
+	service, err := buildJob(details)
+	require.NoError(t, err)
+	assert.Equal(t, "service", *service.Type)
+	assert.Nil(t, service.Periodic)
+	assert.True(t, periodicEnabled(service))
+
+	// The loop passes the same arguments along to tsg-cli.
+	task = service.TaskGroups[0].Tasks[0]
+	assert.Equal(t, "/bin/sh", task.Config["command"])
+	args := task.Config["args"].([]interface{})
+	assert.Equal(t, []interface{}{"-c", `while true; do "$0" "$@"; sleep 2; done`, "tsg-cli", "scale"}, args[:4])
+	assert.Equal(t, batch.TaskGroups[0].Tasks[0].Config["args"], args[3:])
+
+	details.JobType = "system"
+	_, err = buildJob(details)
+	assert.EqualError(t, err, `unsupported nomad job type: "system"`)
+}
+
+func TestReplaceJob(t *testing.T) {
+	// Registering isn't retried, so each failure is final.
+	defer viper.Reset()
+	viper.Set(config.KeyNomadRetryAttempts, 1)
+
+	job, err := buildJob(testJobDetails(nil))
+	require.NoError(t, err)
+	jobID := *job.ID
+
+	previous, err := buildJob(testJobDetails(nil))
+	require.NoError(t, err)
+	previous.Meta["version"] = "previous"
+
+	type nomadCalls struct {
+		deregistered bool
+		registered   []*nomad.Job
+	}
+
+	setup := func(t *testing.T, invalid bool, failRegisters int) (context.Context, *nomadCalls, func()) {
+		fake := testutils.NewFakeNomad(t)
+		calls := &nomadCalls{}
+
+		fake.HandleFunc("/v1/validate/job", func(w http.ResponseWriter, r *http.Request) {
+			if invalid {
+				http.Error(w, "task group scale: missing driver", http.StatusBadRequest)
+				return
+			}
+			testutils.WriteJSON(w, &nomad.JobValidateResponse{})
+		})
+		fake.HandleFunc("/v1/job/"+jobID, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodDelete {
+				calls.deregistered = true
+				// Updates keep the history of the previous job.
+				assert.Equal(t, "false", r.URL.Query().Get("purge"))
+				testutils.WriteJSON(w, &nomad.JobDeregisterResponse{})
+				return
+			}
+			testutils.WriteJSON(w, previous)
+		})
+		fake.HandleFunc("/v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+			var req nomad.RegisterJobRequest
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+			if len(calls.registered) < failRegisters {
+				calls.registered = append(calls.registered, nil)
+				http.Error(w, "no leader", http.StatusInternalServerError)
+				return
+			}
+			calls.registered = append(calls.registered, req.Job)
+			testutils.WriteJSON(w, &nomad.JobRegisterResponse{EvalID: "register-eval"})
+		})
+		fake.HandleJSON("/v1/job/"+jobID+"/periodic/force", map[string]string{"EvalID": "periodic-eval"})
+
+		ctx := handlers.WithAuthSession(context.Background(), &auth.Session{Datacenter: "us-east-1"})
+		ctx = handlers.WithNomadClient(ctx, fake.Client)
+		return ctx, calls, fake.Close
+	}
+
+	t.Run("replaced", func(t *testing.T) {
+		ctx, calls, done := setup(t, false, 0)
+		defer done()
+
+		submission, err := replaceJob(ctx, job)
+		require.NoError(t, err)
+		// The job is updated in place rather than stopped first.
+		assert.False(t, calls.deregistered)
+		require.Len(t, calls.registered, 1)
+		assert.Empty(t, calls.registered[0].Meta["version"])
+		assert.Equal(t, &JobSubmission{
+			Datacenter:     "us-east-1",
+			JobID:          jobID,
+			EvalID:         "register-eval",
+			PeriodicEvalID: "periodic-eval",
+		}, submission)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		ctx, calls, done := setup(t, true, 0)
+		defer done()
+
+		_, err := replaceJob(ctx, job)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "Failed to validate Nomad Job")
+		// The previous job is left running.
+		assert.False(t, calls.deregistered)
+		assert.Empty(t, calls.registered)
+	})
+
+	t.Run("rolled back", func(t *testing.T) {
+		ctx, calls, done := setup(t, false, 1)
+		defer done()
+
+		_, err := replaceJob(ctx, job)
+		updateErr, ok := err.(*ErrJobUpdate)
+		require.True(t, ok)
+		assert.True(t, updateErr.RolledBack)
+		assert.NoError(t, updateErr.RollbackErr)
+		assert.Contains(t, err.Error(), "Unable to register job with Nomad")
+		assert.Contains(t, err.Error(), "rolled back to the previous job")
+
+		require.Len(t, calls.registered, 2)
+		assert.Equal(t, "previous", calls.registered[1].Meta["version"])
+		assert.False(t, calls.deregistered)
+		assert.True(t, errors.Is(err, ErrNomadRegister))
+		assert.Equal(t, http.StatusBadGateway, orchestratorErrorStatus(err))
+	})
+
+	t.Run("rollback failed", func(t *testing.T) {
+		ctx, calls, done := setup(t, false, 2)
+		defer done()
+
+		_, err := replaceJob(ctx, job)
+		updateErr, ok := err.(*ErrJobUpdate)
+		require.True(t, ok)
+		assert.False(t, updateErr.RolledBack)
+		assert.Error(t, updateErr.RollbackErr)
+		assert.Contains(t, err.Error(), "unable to roll back to the previous job")
+		assert.Len(t, calls.registered, 2)
+	})
+}
+
+func TestRemoveJob(t *testing.T) {
+	// Neither step is retried, so each failure is final, and the scale-down
+	// run isn't waited on, see TestRemoveJobDrains.
+	defer viper.Reset()
+	viper.Set(config.KeyNomadRetryAttempts, 1)
+	viper.Set(config.KeyNomadDeregisterWait, 0)
+
+	job, err := buildJob(testJobDetails(nil))
+	require.NoError(t, err)
+	jobID := *job.ID
+
+	setup := func(t *testing.T, failRegister, failDeregister bool) (context.Context, *[]string, func()) {
+		fake := testutils.NewFakeNomad(t)
+		var calls []string
+
+		fake.HandleJSON("/v1/validate/job", &nomad.JobValidateResponse{})
+		fake.HandleFunc("/v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+			calls = append(calls, "register")
+			if failRegister {
+				http.Error(w, "no leader", http.StatusInternalServerError)
+				return
+			}
+			testutils.WriteJSON(w, &nomad.JobRegisterResponse{})
+		})
+		fake.HandleFunc("/v1/job/"+jobID+"/periodic/force", func(w http.ResponseWriter, r *http.Request) {
+			calls = append(calls, "periodic force")
+			testutils.WriteJSON(w, struct{}{})
+		})
+		fake.HandleFunc("/v1/job/"+jobID, func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodDelete {
+				t.Errorf("unexpected nomad call: %s %s", r.Method, r.URL.Path)
+				return
+			}
+			calls = append(calls, "deregister")
+			assert.Equal(t, "true", r.URL.Query().Get("purge"))
+			if failDeregister {
+				http.Error(w, "no leader", http.StatusInternalServerError)
+				return
+			}
+			testutils.WriteJSON(w, &nomad.JobDeregisterResponse{})
+		})
+
+		ctx := handlers.WithAuthSession(context.Background(), &auth.Session{Datacenter: "us-east-1"})
+		ctx = handlers.WithNomadClient(ctx, fake.Client)
+		return ctx, &calls, fake.Close
+	}
+
+	t.Run("removed", func(t *testing.T) {
+		ctx, calls, done := setup(t, false, false)
+		defer done()
+
+		require.NoError(t, removeJob(ctx, job))
+		assert.Equal(t, []string{"register", "periodic force", "deregister"}, *calls)
+	})
+
+	t.Run("register failed", func(t *testing.T) {
+		ctx, calls, done := setup(t, true, false)
+		defer done()
+
+		// The previous job is left registered, rather than the group being
+		// left without a job while its instances run on.
+		err := removeJob(ctx, job)
+		assert.True(t, errors.Is(err, ErrNomadRegister))
+		assert.Equal(t, []string{"register"}, *calls)
+	})
+
+	t.Run("deregister failed", func(t *testing.T) {
+		ctx, calls, done := setup(t, false, true)
+		defer done()
+
+		// Only the scaled down job is left registered, which runs no
+		// instances.
+		err := removeJob(ctx, job)
+		assert.True(t, errors.Is(err, ErrNomadDeregister))
+		assert.Equal(t, []string{"register", "periodic force", "deregister"}, *calls)
+	})
+}
+
+func TestRemoveJobDrains(t *testing.T) {
+	allocPollInterval = 10 * time.Millisecond
+	defer viper.Reset()
+	viper.Set(config.KeyNomadDeregisterWait, time.Second)
+
+	job, err := buildJob(testJobDetails(nil))
+	require.NoError(t, err)
+	jobID := *job.ID
+	childID := jobID + "/periodic-1525209600"
+
+	fake := testutils.NewFakeNomad(t)
+	defer fake.Close()
+
+	var calls []string
+	record := func(call string) {
+		if len(calls) == 0 || calls[len(calls)-1] != call {
+			calls = append(calls, call)
+		}
+	}
+
+	// The scale-down run is evaluated, placed and then runs to completion
+	// over a few polls each.
+	var evalPolls, allocPolls int
+	fake.HandleJSON("/v1/validate/job", &nomad.JobValidateResponse{})
+	fake.HandleFunc("/v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			testutils.WriteJSON(w, []*nomad.JobListStub{{ID: childID, ParentID: jobID}})
+			return
+		}
+		record("register")
+		testutils.WriteJSON(w, &nomad.JobRegisterResponse{EvalID: "register-eval"})
+	})
+	fake.HandleJSON("/v1/job/"+jobID+"/periodic/force", map[string]string{"EvalID": "periodic-eval"})
+	fake.HandleFunc("/v1/evaluation/", func(w http.ResponseWriter, r *http.Request) {
+		record("evaluation")
+		status := "complete"
+		if r.URL.Path == "/v1/evaluation/periodic-eval" {
+			if evalPolls++; evalPolls < 3 {
+				status = "pending"
+			}
+		}
+		testutils.WriteJSON(w, &nomad.Evaluation{ID: path.Base(r.URL.Path), Status: status})
+	})
+	fake.HandleFunc("/v1/job/", func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v1/job/" + jobID:
+			require.Equal(t, http.MethodDelete, r.Method)
+			record("deregister")
+			testutils.WriteJSON(w, &nomad.JobDeregisterResponse{})
+		case "/v1/job/" + childID + "/allocations":
+			record("allocations")
+			status := "running"
+			if allocPolls++; allocPolls > 2 {
+				status = "complete"
+			}
+			testutils.WriteJSON(w, []*nomad.AllocationListStub{{ID: "b5ae4c4b", JobID: childID, ClientStatus: status}})
+		default:
+			testutils.WriteJSON(w, []*nomad.AllocationListStub{})
+		}
+	})
+
+	ctx := handlers.WithAuthSession(context.Background(), &auth.Session{Datacenter: "us-east-1"})
+	ctx = handlers.WithNomadClient(ctx, fake.Client)
+
+	require.NoError(t, removeJob(ctx, job))
+	assert.Equal(t, []string{"register", "evaluation", "allocations", "deregister"}, calls)
+	assert.Equal(t, 3, evalPolls)
+	assert.Equal(t, 3, allocPolls)
+}
+
+func TestOrchestratorJobCanceled(t *testing.T) {
+	fake := testutils.NewFakeNomad(t)
+	defer fake.Close()
+	fake.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected nomad call: %s %s", r.Method, r.URL.Path)
+	})
+
+	ctx := handlers.WithAuthSession(context.Background(), &auth.Session{Datacenter: "us-east-1"})
+	ctx = handlers.WithNomadClient(ctx, fake.Client)
+	ctx, cancel := context.WithCancel(ctx)
+	cancel()
+
+	group := &ServiceGroup{ID: "722d25ed-f32a-4944-9861-8990e204850e", GroupName: "web", Capacity: 3}
+
+	jobs, err := SubmitOrchestratorJob(ctx, group)
+	assert.Equal(t, context.Canceled, err)
+	assert.Empty(t, jobs)
+	jobs, err = UpdateOrchestratorJob(ctx, group)
+	assert.Equal(t, context.Canceled, err)
+	assert.Empty(t, jobs)
+	assert.Equal(t, context.Canceled, DeleteOrchestratorJob(ctx, group))
+	assert.Equal(t, 3, group.Capacity)
+}
+
+func TestJobRegion(t *testing.T) {
+	details := testJobDetails(nil)
+	job, err := buildJob(details)
+	require.NoError(t, err)
+	assert.Nil(t, job.Region)
+
+	details.Region = "eu-west"
+	job, err = buildJob(details)
+	require.NoError(t, err)
+	require.NotNil(t, job.Region)
+	assert.Equal(t, "eu-west", *job.Region)
+	jobID := *job.ID
+
+	fake := testutils.NewFakeNomad(t)
+	defer fake.Close()
+
+	regions := make(map[string]string)
+	record := func(call string, r *http.Request) {
+		regions[call] = r.URL.Query().Get("region")
+	}
+	fake.HandleFunc("/v1/validate/job", func(w http.ResponseWriter, r *http.Request) {
+		record("validate", r)
+		testutils.WriteJSON(w, &nomad.JobValidateResponse{})
+	})
+	fake.HandleFunc("/v1/job/"+jobID, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			record("deregister", r)
+			testutils.WriteJSON(w, &nomad.JobDeregisterResponse{})
+			return
+		}
+		record("info", r)
+		testutils.WriteJSON(w, job)
+	})
+	fake.HandleFunc("/v1/jobs", func(w http.ResponseWriter, r *http.Request) {
+		record("register", r)
+		testutils.WriteJSON(w, &nomad.JobRegisterResponse{})
+	})
+	fake.HandleFunc("/v1/job/"+jobID+"/periodic/force", func(w http.ResponseWriter, r *http.Request) {
+		record("periodic force", r)
+		testutils.WriteJSON(w, struct{}{})
+	})
+
+	ctx := handlers.WithAuthSession(context.Background(), &auth.Session{
+		Datacenter:  "us-east-1",
+		NomadRegion: "eu-west",
+	})
+	ctx = handlers.WithNomadClient(ctx, fake.Client)
+
+	_, err = replaceJob(ctx, job)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"validate":       "eu-west",
+		"info":           "eu-west",
+		"register":       "eu-west",
+		"periodic force": "eu-west",
+	}, regions)
+}
+
+func TestRegisterJobForce(t *testing.T) {
+	details := testJobDetails(nil)
+	details.JobType = "batch"
+	job, err := buildJob(details)
+	require.NoError(t, err)
+	require.NotNil(t, job.Periodic)
+	jobID := *job.ID
+
+	fake := testutils.NewFakeNomad(t)
+	defer fake.Close()
+
+	var forced int
+	fake.HandleJSON("/v1/validate/job", &nomad.JobValidateResponse{})
+	fake.HandleJSON("/v1/jobs", &nomad.JobRegisterResponse{EvalID: "register-eval"})
+	fake.HandleFunc("/v1/job/"+jobID+"/periodic/force", func(w http.ResponseWriter, r *http.Request) {
+		forced++
+		testutils.WriteJSON(w, map[string]string{"EvalID": "periodic-eval"})
+	})
+
+	ctx := handlers.WithAuthSession(context.Background(), &auth.Session{Datacenter: "us-east-1"})
+	ctx = handlers.WithNomadClient(ctx, fake.Client)
+
+	// The first run is left to the job's schedule.
+	submission, err := registerJob(ctx, job, false)
+	require.NoError(t, err)
+	assert.Equal(t, 0, forced)
+	assert.Equal(t, "register-eval", submission.EvalID)
+	assert.Empty(t, submission.PeriodicEvalID)
+
+	submission, err = registerJob(ctx, job, true)
+	require.NoError(t, err)
+	assert.Equal(t, 1, forced)
+	assert.Equal(t, "periodic-eval", submission.PeriodicEvalID)
+}
+
+func TestJobDatacenters(t *testing.T) {
+	args := func(job *nomad.Job) []string {
+		var args []string
+		for _, arg := range job.TaskGroups[0].Tasks[0].Config["args"].([]interface{}) {
+			args = append(args, arg.(string))
+		}
+		return args
+	}
+
+	details := testJobDetails(nil)
+	job, err := buildJob(details)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"us-sw-1"}, job.Datacenters)
+	assert.Empty(t, filterArgs(args(job), "--datacenter"))
+
+	// tsg-cli is told which of the datacenters each run was placed in.
+	details.Datacenters = []string{"us-sw-1a", "us-sw-1b"}
+	job, err = buildJob(details)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"us-sw-1a", "us-sw-1b"}, job.Datacenters)
+	assert.Equal(t, "${node.datacenter}", argValue(args(job), "--datacenter"))
+	assert.Equal(t, "us-sw-1a", details.Datacenter())
+
+	session := &auth.Session{Datacenter: "us-sw-1"}
+	assert.Equal(t, []string{"us-sw-1"}, jobDatacenters(session))
+
+	session.NomadDatacenters = []string{"us-sw-1a", "us-sw-1b"}
+	assert.Equal(t, []string{"us-sw-1a", "us-sw-1b"}, jobDatacenters(session))
+
+	assert.Empty(t, jobDatacenters(&auth.Session{}))
+}
+
+func TestJobTaskResources(t *testing.T) {
+	defer viper.Reset()
+	viper.Set(config.KeyNomadTaskCPU, 250)
+
+	tmpl := &templates_v1.InstanceTemplate{
+		Package: "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+		ImageID: "342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+	}
+	group := &ServiceGroup{GroupName: "web", Capacity: 2}
+
+	resources := func(tmpl *templates_v1.InstanceTemplate) *nomad.Resources {
+		details, err := createJobDetails(tmpl, group)
+		require.NoError(t, err)
+		details.JobName = jobName(group.GroupName, "c2e4d1491ce423e3")
+
+		job, err := buildJob(details)
+		require.NoError(t, err)
+		return job.TaskGroups[0].Tasks[0].Resources
+	}
+
+	defaults := resources(tmpl)
+	assert.Equal(t, 250, *defaults.CPU)
+	assert.Equal(t, config.DefaultTaskMemoryMB, *defaults.MemoryMB)
+
+	tmpl.TaskMemoryMB = 4096
+	custom := resources(tmpl)
+	assert.Equal(t, 250, *custom.CPU)
+	assert.Equal(t, 4096, *custom.MemoryMB)
+
+	tmpl.TaskCPU = -100
+	_, err := createJobDetails(tmpl, group)
+	assert.EqualError(t, err, `task cpu "-100" can't be used in a job: must be a positive integer`)
+}
+
+func TestJobConstraints(t *testing.T) {
+	defer viper.Reset()
+
+	// The default renders exactly the constraint every job had before it
+	// was configurable.
+	spec, err := renderJobSpec(testJobDetails(nil))
+	require.NoError(t, err)
+	assert.Contains(t, spec, `
+  group "scale" {
+    constraint {
+      distinct_hosts = true
+    }
+    constraint {
+      operator = "="
+      attribute = "${meta.role}"
+      value = "automater"
+    }
+    task "healthy" {`)
+
+	viper.Set(config.KeyNomadConstraints, []map[string]interface{}{
+		{"attribute": "${node.class}", "value": "tsg"},
+		{"attribute": "${attr.kernel.name}", "operator": "!=", "value": "windows"},
+	})
+	job, err := buildJob(testJobDetails(nil))
+	require.NoError(t, err)
+	constraints := job.TaskGroups[0].Constraints
+	require.Len(t, constraints, 3)
+	assert.Equal(t, "distinct_hosts", constraints[0].Operand)
+	assert.Equal(t, &nomad.Constraint{LTarget: "${node.class}", Operand: "=", RTarget: "tsg"}, constraints[1])
+	assert.Equal(t, &nomad.Constraint{LTarget: "${attr.kernel.name}", Operand: "!=", RTarget: "windows"}, constraints[2])
+
+	viper.Set(config.KeyNomadConstraints, []interface{}{})
+	job, err = buildJob(testJobDetails(nil))
+	require.NoError(t, err)
+	assert.Len(t, job.TaskGroups[0].Constraints, 1)
+}
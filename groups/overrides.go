@@ -0,0 +1,94 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"encoding/json"
+
+	"github.com/joyent/triton-service-groups/templates"
+)
+
+// withInstanceOverrides returns a copy of template with the networks, tags and
+// metadata of the group's instances in place of its own. Networks set by the
+// group replace the template's wholesale, an empty list included. Tags and
+// metadata are merged by key: a key set by the group overrides the
+// template's, and a key set to null removes it. The template itself is left
+// untouched, and applying the overrides again changes nothing.
+func withInstanceOverrides(template *templates_v1.InstanceTemplate, group *ServiceGroup) *templates_v1.InstanceTemplate {
+	if group.Networks == nil && group.Tags == nil && group.MetaData == nil {
+		return template
+	}
+
+	t := *template
+	if group.Networks != nil {
+		t.Networks = append([]string{}, *group.Networks...)
+	}
+	t.Tags = mergeValues(template.Tags, group.Tags)
+	t.MetaData = mergeValues(template.MetaData, group.MetaData)
+	return &t
+}
+
+// mergeValues returns a copy of values with overrides applied by key, those
+// set to nil removing the key. values is returned as is when there are no
+// overrides.
+func mergeValues(values map[string]string, overrides map[string]*string) map[string]string {
+	if overrides == nil {
+		return values
+	}
+
+	merged := make(map[string]string, len(values)+len(overrides))
+	for key, value := range values {
+		merged[key] = value
+	}
+	for key, value := range overrides {
+		if value == nil {
+			delete(merged, key)
+			continue
+		}
+		merged[key] = *value
+	}
+	return merged
+}
+
+// instanceOverrides are the overrides of a group's instances as they're
+// stored. Networks is kept even when empty, since an empty list replaces the
+// template's networks.
+type instanceOverrides struct {
+	Networks *[]string          `json:"networks,omitempty"`
+	Tags     map[string]*string `json:"tags,omitempty"`
+	MetaData map[string]*string `json:"metadata,omitempty"`
+}
+
+func encodeInstanceOverrides(group *ServiceGroup) (string, error) {
+	if group.Networks == nil && group.Tags == nil && group.MetaData == nil {
+		return "", nil
+	}
+
+	bytes, err := json.Marshal(instanceOverrides{
+		Networks: group.Networks,
+		Tags:     group.Tags,
+		MetaData: group.MetaData,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
+func decodeInstanceOverrides(data string, group *ServiceGroup) error {
+	if data == "" {
+		return nil
+	}
+
+	var overrides instanceOverrides
+	if err := json.Unmarshal([]byte(data), &overrides); err != nil {
+		return err
+	}
+	group.Networks = overrides.Networks
+	group.Tags = overrides.Tags
+	group.MetaData = overrides.MetaData
+	return nil
+}
@@ -0,0 +1,94 @@
+package groups_v1
+
+import (
+	"testing"
+
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstanceOverrides(t *testing.T) {
+	tmpl := func() *templates_v1.InstanceTemplate {
+		return &templates_v1.InstanceTemplate{
+			ID:       "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+			Package:  "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+			ImageID:  "342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+			Networks: []string{"5e7ce8c0-a0f1-4a0e-8d1a-d7a0d3d3c5a1"},
+			Tags:     map[string]string{"role": "web", "env": "prod"},
+			MetaData: map[string]string{"owner": "ops"},
+		}
+	}
+
+	t.Run("none", func(t *testing.T) {
+		template := tmpl()
+		assert.Equal(t, template, withInstanceOverrides(template, &ServiceGroup{}))
+	})
+
+	t.Run("precedence", func(t *testing.T) {
+		template := tmpl()
+		group, err := decodeGroupResponseBodyAndValidate([]byte(`{
+			"template_id": "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+			"networks": ["9ec60129-9034-47b4-b111-3026f9b1a10f", "b2d2e1c1-5a45-4d90-9bcc-8e5b5de154de"],
+			"tags": {"role": "api", "env": null, "tier": "1"},
+			"metadata": {}
+		}`))
+		require.NoError(t, err)
+
+		merged := withInstanceOverrides(template, group)
+		assert.Equal(t, []string{"9ec60129-9034-47b4-b111-3026f9b1a10f", "b2d2e1c1-5a45-4d90-9bcc-8e5b5de154de"}, merged.Networks)
+		assert.Equal(t, map[string]string{"role": "api", "tier": "1"}, merged.Tags)
+		assert.Equal(t, map[string]string{"owner": "ops"}, merged.MetaData)
+
+		// The template is left as it was, and overriding again is a no-op.
+		assert.Equal(t, tmpl(), template)
+		assert.Equal(t, merged, withInstanceOverrides(merged, group))
+	})
+
+	t.Run("explicitly empty", func(t *testing.T) {
+		group, err := decodeGroupResponseBodyAndValidate([]byte(`{
+			"template_id": "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+			"networks": [],
+			"tags": {"role": null, "env": null}
+		}`))
+		require.NoError(t, err)
+
+		details, err := createJobDetails(tmpl(), group)
+		require.NoError(t, err)
+		assert.Empty(t, details.Networks)
+		assert.Empty(t, details.Tags)
+		assert.Equal(t, map[string]string{"owner": "ops"}, details.MetaData)
+	})
+
+	t.Run("checked", func(t *testing.T) {
+		bad := "${attr.unique.hostname}"
+		_, err := createJobDetails(tmpl(), &ServiceGroup{
+			GroupName: "web",
+			Tags:      map[string]*string{"role": &bad},
+		})
+		assert.EqualError(t, err, `tag value "${attr.unique.hostname}" can't be used in a job: must not contain "${"`)
+	})
+
+	t.Run("stored", func(t *testing.T) {
+		role := "api"
+		group := &ServiceGroup{
+			Networks: &[]string{},
+			Tags:     map[string]*string{"role": &role, "env": nil},
+		}
+
+		data, err := encodeInstanceOverrides(group)
+		require.NoError(t, err)
+		assert.Equal(t, `{"networks":[],"tags":{"env":null,"role":"api"}}`, data)
+
+		var decoded ServiceGroup
+		require.NoError(t, decodeInstanceOverrides(data, &decoded))
+		assert.Equal(t, group.Networks, decoded.Networks)
+		assert.Equal(t, group.Tags, decoded.Tags)
+		assert.Nil(t, decoded.MetaData)
+
+		data, err = encodeInstanceOverrides(&ServiceGroup{})
+		require.NoError(t, err)
+		assert.Empty(t, data)
+		require.NoError(t, decodeInstanceOverrides("", &decoded))
+	})
+}
@@ -0,0 +1,85 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/joyent/triton-go/compute"
+	tritonerrors "github.com/joyent/triton-go/errors"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/pkg/errors"
+)
+
+// ErrPackageNotFound is returned when a template's package no longer exists
+// in Triton, which would otherwise only fail once tsg-cli runs.
+type ErrPackageNotFound struct {
+	PackageID string
+}
+
+func (e *ErrPackageNotFound) Error() string {
+	return fmt.Sprintf("package %q referenced by the template no longer exists", e.PackageID)
+}
+
+// packageCacheSize bounds the number of package lookups held in
+// packageExistsCache.
+const packageCacheSize = 1024
+
+var packageExistsCache = newExistsCache("package", packageCacheSize, config.GetPackageCacheTTL)
+
+// lookupPackage reports whether a package exists in Triton as seen by the
+// account.
+var lookupPackage = func(ctx context.Context, accountID, tritonURL, packageID string) (bool, error) {
+	c, err := newComputeClient(ctx, accountID, tritonURL)
+	if err != nil {
+		return false, err
+	}
+	return packageExists(ctx, c, packageID)
+}
+
+// checkPackage returns an ErrPackageNotFound if checking packages is enabled
+// and the template's package no longer exists.
+func checkPackage(ctx context.Context, t *templates_v1.InstanceTemplate) error {
+	if !config.GetCheckPackages() {
+		return nil
+	}
+	if _, err := tritonID("package", t.Package); err != nil {
+		return err
+	}
+
+	session := handlers.GetAuthSession(ctx)
+
+	key := resourceKey{
+		tritonURL: session.TritonURL,
+		accountID: session.AccountID,
+		id:        t.Package,
+	}
+	exists, err := packageExistsCache.Exists(key, func() (bool, error) {
+		return lookupPackage(ctx, session.AccountID, session.TritonURL, t.Package)
+	})
+	if err != nil {
+		return errors.Wrap(err, "unable to check template package")
+	}
+	if !exists {
+		return &ErrPackageNotFound{PackageID: t.Package}
+	}
+
+	return nil
+}
+
+func packageExists(ctx context.Context, c *compute.ComputeClient, packageID string) (bool, error) {
+	_, err := c.Packages().Get(ctx, &compute.GetPackageInput{ID: packageID})
+	if err != nil {
+		if tritonerrors.IsResourceNotFound(err) || tritonerrors.IsStatusNotFoundCode(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
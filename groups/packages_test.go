@@ -0,0 +1,80 @@
+package groups_v1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joyent/triton-go"
+	"github.com/joyent/triton-go/authentication"
+	"github.com/joyent/triton-go/compute"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPackage(t *testing.T) {
+	defer viper.Reset()
+
+	const testPackageID = "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0"
+
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/testacct/packages/"+testPackageID {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"code": "ResourceNotFound", "message": "package not found"}`))
+			return
+		}
+		w.Write([]byte(`{"id": "7b17343c-94af-6266-e0e8-893a3b9993d0", "name": "g4-highcpu-4G"}`))
+	}))
+	defer srv.Close()
+
+	signer, err := authentication.NewTestSigner()
+	require.NoError(t, err)
+	c, err := compute.NewClient(&triton.ClientConfig{
+		TritonURL:   srv.URL,
+		AccountName: "testacct",
+		Signers:     []authentication.Signer{signer},
+	})
+	require.NoError(t, err)
+
+	defer func(lookup func(ctx context.Context, accountID, tritonURL, packageID string) (bool, error)) {
+		lookupPackage = lookup
+	}(lookupPackage)
+	lookupPackage = func(ctx context.Context, accountID, tritonURL, packageID string) (bool, error) {
+		return packageExists(ctx, c, packageID)
+	}
+
+	defer func(cache *existsCache) { packageExistsCache = cache }(packageExistsCache)
+	packageExistsCache = newExistsCache("package", packageCacheSize, config.GetPackageCacheTTL)
+
+	ctx := handlers.WithAuthSession(context.Background(), &auth.Session{
+		AccountID: "6d6f3c38-2ed5-4b7a-a0ad-8a2caa32bbb4",
+		TritonURL: "https://us-east-1.api.joyent.com",
+	})
+
+	// Disabled by default.
+	require.NoError(t, checkPackage(ctx, &templates_v1.InstanceTemplate{Package: testPackageID}))
+	assert.Equal(t, 0, requests)
+
+	viper.Set(config.KeyTritonCheckPackages, true)
+
+	require.NoError(t, checkPackage(ctx, &templates_v1.InstanceTemplate{Package: "7b17343c-94af-6266-e0e8-893a3b9993d0"}))
+
+	err = checkPackage(ctx, &templates_v1.InstanceTemplate{Package: testPackageID})
+	assert.EqualError(t, err, `package "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0" referenced by the template no longer exists`)
+	assert.Equal(t, http.StatusUnprocessableEntity, orchestratorErrorStatus(err))
+	assert.Equal(t, 2, requests)
+
+	// Malformed packages aren't looked up.
+	err = checkPackage(ctx, &templates_v1.InstanceTemplate{Package: "g4-highcpu-1G"})
+	assert.Equal(t, &ErrInvalidTritonID{Field: "package", Value: "g4-highcpu-1G"}, err)
+	assert.Equal(t, 2, requests)
+}
@@ -0,0 +1,148 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/helper"
+	"github.com/joyent/triton-service-groups/health"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/rs/zerolog/log"
+)
+
+// PauseServiceGroup stops the reconciles of group without changing its
+// capacity, such as during maintenance. Its job stays registered, with the
+// periodic schedule of a batch job disabled and the task group of a service
+// job stopped, so no instances are created or removed until it's resumed.
+func PauseServiceGroup(ctx context.Context, group *ServiceGroup) ([]*JobSubmission, error) {
+	return setPaused(ctx, group, true)
+}
+
+// ResumeServiceGroup restarts the reconciles of a group paused by
+// PauseServiceGroup.
+func ResumeServiceGroup(ctx context.Context, group *ServiceGroup) ([]*JobSubmission, error) {
+	return setPaused(ctx, group, false)
+}
+
+// setPaused saves whether group is paused and registers its job again to
+// match. The job is registered even if the group was already paused or
+// resumed, so that a job left behind by an earlier failure is corrected.
+func setPaused(ctx context.Context, group *ServiceGroup, paused bool) ([]*JobSubmission, error) {
+	session := handlers.GetAuthSession(ctx)
+
+	if group.Paused != paused {
+		if err := SetGroupPaused(ctx, group.ID, session.AccountID, paused); err != nil {
+			return nil, err
+		}
+		group.Paused = paused
+	}
+
+	op := jobOpResume
+	if paused {
+		op = jobOpPause
+	}
+	return reregisterJobs(ctx, group, op)
+}
+
+// reregisterJobs registers the job of group again in each of its
+// datacenters, see reregisterJob.
+func reregisterJobs(ctx context.Context, group *ServiceGroup, op string) ([]*JobSubmission, error) {
+	if group.isMultiDatacenter() {
+		return forEachSubmission(ctx, group, func(ctx context.Context, group *ServiceGroup) ([]*JobSubmission, error) {
+			return reregisterJobs(ctx, group, op)
+		})
+	}
+
+	submission, err := reregisterJob(ctx, group, op)
+	if err != nil {
+		return nil, err
+	}
+	return []*JobSubmission{submission}, nil
+}
+
+// pauseReconciles keeps the job of a paused group from reconciling it. The
+// periodic schedule of a batch job is disabled, while the task group of a
+// service job, which reconciles continuously, is scaled to zero. The group's
+// capacity, passed to tsg-cli, is left as it is.
+func pauseReconciles(group *ServiceGroup, job *nomad.Job) {
+	if !group.Paused {
+		return
+	}
+
+	log.Info().
+		Str("group_id", group.ID).
+		Str("job_id", *job.ID).
+		Msg("orchestrator: group is paused, registering job without reconciles")
+
+	if job.Periodic != nil {
+		job.Periodic.Enabled = helper.BoolToPtr(false)
+		return
+	}
+	for _, tg := range job.TaskGroups {
+		tg.Count = helper.IntToPtr(0)
+	}
+}
+
+// trackConvergence waits for group to converge on its capacity after its job
+// was registered, unless it's paused and so won't.
+func trackConvergence(group *ServiceGroup) {
+	if group.Paused {
+		health.Convergences.Forget(group.ID)
+		return
+	}
+	health.Convergences.Submitted(group.ID)
+}
+
+// Pause pauses the reconciles of a group.
+func Pause(w http.ResponseWriter, r *http.Request) {
+	writePaused(w, r, PauseServiceGroup)
+}
+
+// Resume resumes the reconciles of a paused group.
+func Resume(w http.ResponseWriter, r *http.Request) {
+	writePaused(w, r, ResumeServiceGroup)
+}
+
+func writePaused(w http.ResponseWriter, r *http.Request, fn func(ctx context.Context, group *ServiceGroup) ([]*JobSubmission, error)) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	vars := mux.Vars(r)
+	identifier := vars["identifier"]
+
+	group, ok := FindGroupByID(ctx, identifier, session.AccountID)
+	if !ok {
+		groupNotFound(w, r, identifier)
+		return
+	}
+
+	jobs, err := fn(ctx, group)
+	if err != nil {
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
+		return
+	}
+
+	group, ok = FindGroupByID(ctx, identifier, session.AccountID)
+	if !ok {
+		groupNotFound(w, r, identifier)
+		return
+	}
+	group.Jobs = jobs
+
+	bytes, err := json.Marshal(group)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeETag(w, group)
+	writeJSONResponse(w, bytes, http.StatusOK)
+}
@@ -0,0 +1,57 @@
+package groups_v1
+
+import (
+	"testing"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/helper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPauseReconciles(t *testing.T) {
+	group := &ServiceGroup{ID: "web-id", Capacity: 3}
+
+	build := func(jobType string) *nomad.Job {
+		details := sampleJobDetails(jobType)
+		details.DesiredCount = group.Capacity
+
+		job, err := buildJob(details)
+		require.NoError(t, err)
+		return job
+	}
+
+	t.Run("running", func(t *testing.T) {
+		job := build("batch")
+		pauseReconciles(group, job)
+		assert.True(t, periodicEnabled(job))
+
+		job = build("service")
+		pauseReconciles(group, job)
+		assert.Nil(t, job.TaskGroups[0].Count)
+	})
+
+	paused := *group
+	paused.Paused = true
+
+	t.Run("paused batch job", func(t *testing.T) {
+		job := build("batch")
+		pauseReconciles(&paused, job)
+
+		assert.False(t, periodicEnabled(job))
+
+		var args []string
+		for _, arg := range job.TaskGroups[0].Tasks[0].Config["args"].([]interface{}) {
+			args = append(args, arg.(string))
+		}
+		assert.Equal(t, "3", argValue(args, "--count"))
+	})
+
+	t.Run("paused service job", func(t *testing.T) {
+		job := build("service")
+		pauseReconciles(&paused, job)
+
+		assert.Nil(t, job.Periodic)
+		assert.Equal(t, helper.IntToPtr(0), job.TaskGroups[0].Count)
+	})
+}
@@ -0,0 +1,127 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/joyent/triton-service-groups/messages"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/templates"
+)
+
+// TemplateVersionInput is the body of a request to pin a group to a version
+// of its template.
+type TemplateVersionInput struct {
+	// Version is the version to pin the group to, the latest when unset.
+	Version int `json:"version,omitempty"`
+}
+
+// pinnedRevision returns the revision of versions with the given version, or
+// the latest when version is 0. nil is returned if there's no such version.
+func pinnedRevision(versions []*templates_v1.InstanceTemplate, version int) *templates_v1.InstanceTemplate {
+	if version == 0 && len(versions) > 0 {
+		return versions[len(versions)-1]
+	}
+	for _, revision := range versions {
+		if revision.Version == version {
+			return revision
+		}
+	}
+	return nil
+}
+
+// PinTemplateVersion moves a group onto another version of its template,
+// usually its latest, and resubmits its job rendered from that version.
+func PinTemplateVersion(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	vars := mux.Vars(r)
+	identifier := vars["identifier"]
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var input TemplateVersionInput
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &input); err != nil {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+	}
+	if input.Version < 0 {
+		http.Error(w, "version must be a positive integer", http.StatusUnprocessableEntity)
+		return
+	}
+
+	group, ok := FindGroupByID(ctx, identifier, session.AccountID)
+	if !ok {
+		groupNotFound(w, r, identifier)
+		return
+	}
+
+	if !ifMatch(r, group) {
+		messages.Write(w, r, ErrGroupModified, http.StatusPreconditionFailed)
+		return
+	}
+
+	versions, err := templates_v1.FindTemplateVersions(ctx, group.TemplateID, session.AccountID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	revision := pinnedRevision(versions, input.Version)
+	if revision == nil {
+		http.Error(w, fmt.Sprintf("The group's template has no version %d.", input.Version),
+			http.StatusNotFound)
+		return
+	}
+
+	if revision.ID != group.TemplateID {
+		current := *group
+		group.TemplateID = revision.ID
+
+		err = updateGroup(ctx, r, session.AccountID, &current, group)
+		if err == ErrGroupModified {
+			messages.Write(w, r, err, http.StatusPreconditionFailed)
+			return
+		}
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		group, ok = FindGroupByID(ctx, identifier, session.AccountID)
+		if !ok {
+			groupNotFound(w, r, identifier)
+			return
+		}
+
+		group.Jobs, err = UpdateOrchestratorJob(ctx, group)
+		if err != nil {
+			http.Error(w, err.Error(), orchestratorErrorStatus(err))
+			return
+		}
+	}
+
+	bytes, err := json.Marshal(group)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeETag(w, group)
+	writeJSONResponse(w, bytes, http.StatusOK)
+}
@@ -0,0 +1,22 @@
+package groups_v1
+
+import (
+	"testing"
+
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPinnedRevision(t *testing.T) {
+	versions := []*templates_v1.InstanceTemplate{
+		{ID: "9ec60129-9034-47b4-b111-3026f9b1a10f", Version: 1, Superseded: true},
+		{ID: "b2d2e1c1-5a45-4d90-9bcc-8e5b5de154de", Version: 2, Superseded: true},
+		{ID: "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5", Version: 3},
+	}
+
+	assert.Equal(t, versions[2], pinnedRevision(versions, 0))
+	assert.Equal(t, versions[0], pinnedRevision(versions, 1))
+	assert.Equal(t, versions[1], pinnedRevision(versions, 2))
+	assert.Nil(t, pinnedRevision(versions, 4))
+	assert.Nil(t, pinnedRevision(nil, 0))
+}
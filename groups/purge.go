@@ -0,0 +1,87 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/rs/zerolog/log"
+)
+
+// GroupPurger periodically removes the groups which were deleted longer ago
+// than the retention, after which they can no longer be restored.
+type GroupPurger struct {
+	interval  time.Duration
+	retention time.Duration
+	pool      *pgx.ConnPool
+
+	now   func() time.Time
+	purge func(ctx context.Context, deletedBefore time.Time) (int64, error)
+}
+
+// NewGroupPurger constructs a purger of the groups deleted longer ago than
+// retention. A zero retention keeps deleted groups forever.
+func NewGroupPurger(interval, retention time.Duration, pool *pgx.ConnPool) *GroupPurger {
+	return &GroupPurger{
+		interval:  interval,
+		retention: retention,
+		pool:      pool,
+		now:       time.Now,
+		purge:     PurgeDeletedGroups,
+	}
+}
+
+// Run purges deleted groups once per interval until ctx is done.
+func (p *GroupPurger) Run(ctx context.Context) {
+	if p.retention <= 0 {
+		log.Debug().Msg("purge: deleted groups are kept forever")
+		return
+	}
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Sweep(ctx); err != nil {
+				log.Error().Err(err).Msg("purge: failed to purge deleted groups")
+			}
+		}
+	}
+}
+
+// Sweep purges every group deleted longer ago than the retention.
+func (p *GroupPurger) Sweep(ctx context.Context) error {
+	ctx = handlers.WithDBPool(ctx, p.pool)
+
+	purged, err := p.purge(ctx, p.now().Add(-p.retention))
+	if err != nil {
+		return err
+	}
+
+	if purged > 0 {
+		log.Info().
+			Int64("groups", purged).
+			Dur("retention", p.retention).
+			Msg("purge: purged deleted groups past their retention")
+	}
+	return nil
+}
+
+// restorableSince returns the earliest time a group can have been deleted at
+// and still be restored, the zero time if deleted groups are kept forever.
+func restorableSince(now time.Time, retention time.Duration) time.Time {
+	if retention <= 0 {
+		return time.Time{}
+	}
+	return now.Add(-retention)
+}
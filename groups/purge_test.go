@@ -0,0 +1,139 @@
+package groups_v1
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/joyent/triton-service-groups/accounts"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/joyent/triton-service-groups/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupPurgerSweep(t *testing.T) {
+	now := time.Date(2018, 4, 14, 15, 0, 0, 0, time.UTC)
+
+	var deletedBefore time.Time
+	p := NewGroupPurger(time.Hour, 7*24*time.Hour, nil)
+	p.now = func() time.Time { return now }
+	p.purge = func(ctx context.Context, before time.Time) (int64, error) {
+		deletedBefore = before
+		return 2, nil
+	}
+
+	require.NoError(t, p.Sweep(context.Background()))
+	assert.Equal(t, time.Date(2018, 4, 7, 15, 0, 0, 0, time.UTC), deletedBefore)
+
+	p.purge = func(ctx context.Context, before time.Time) (int64, error) {
+		return 0, errors.New("connection refused")
+	}
+	assert.EqualError(t, p.Sweep(context.Background()), "connection refused")
+}
+
+func TestGroupPurgerKeepsForever(t *testing.T) {
+	p := NewGroupPurger(time.Millisecond, 0, nil)
+	p.purge = func(ctx context.Context, before time.Time) (int64, error) {
+		t.Fatal("purged with a zero retention")
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		p.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("purger ran with a zero retention")
+	}
+}
+
+func TestRestorableSince(t *testing.T) {
+	now := time.Date(2018, 4, 14, 15, 0, 0, 0, time.UTC)
+
+	assert.Equal(t, now.Add(-time.Hour), restorableSince(now, time.Hour))
+	assert.True(t, restorableSince(now, 0).IsZero())
+}
+
+func TestRestoreNotFound(t *testing.T) {
+	const groupID = "722d25ed-f32a-4944-9861-8990e204850e"
+
+	ctx := handlers.WithAuthSession(context.Background(), &auth.Session{AccountID: "6d6f3c38-2ed5-4b7a-a0ad-8a2caa32bbb4"})
+	r := httptest.NewRequest(http.MethodPost, "/v1/tsg/groups/"+groupID+"/restore", nil).WithContext(ctx)
+	r = mux.SetURLVars(r, map[string]string{"identifier": groupID})
+
+	w := httptest.NewRecorder()
+	Restore(w, r)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestPurgedGroupGone(t *testing.T) {
+	if os.Getenv("TSG_TEST") == "" {
+		t.Skip("Acceptance tests skipped unless env 'TSG_TEST=1' set")
+		return
+	}
+
+	db, err := testutils.NewTestDB()
+	if err != nil {
+		t.Error(err)
+	}
+	db.Clear(t)
+	defer db.Clear(t)
+
+	account := accounts.New(accounts.NewStore(db.Conn))
+	account.AccountName = "baconuser"
+	account.TritonUUID = "f5435e8b-70b8-4e4d-8c59-1dbe5d100b5b"
+	require.NoError(t, account.Insert(context.Background()))
+
+	ctx := handlers.WithDBPool(context.Background(), db.Conn)
+	ctx = handlers.WithAuthSession(ctx, &auth.Session{AccountID: account.ID})
+
+	require.NoError(t, templates_v1.SaveTemplate(ctx, account.ID, &templates_v1.InstanceTemplate{
+		TemplateName: "web",
+		Package:      "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+		ImageID:      "342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+	}))
+	tmpl, ok := templates_v1.FindTemplateByName(ctx, "web", account.ID)
+	require.True(t, ok)
+
+	require.NoError(t, SaveGroup(ctx, account.ID, &ServiceGroup{GroupName: "web", TemplateID: tmpl.ID, Capacity: 1}))
+	group, ok := FindGroupByName(ctx, "web", account.ID)
+	require.True(t, ok)
+	require.NoError(t, RemoveGroup(ctx, group.ID, account.ID))
+
+	get := func() int {
+		w := httptest.NewRecorder()
+		r := httptest.NewRequest(http.MethodGet, "/v1/tsg/groups/"+group.ID, nil).WithContext(ctx)
+		groupNotFound(w, r, group.ID)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusGone, get())
+
+	purged, err := PurgeDeletedGroups(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), purged)
+
+	// The purged group can't be restored, but is still known to be deleted.
+	_, _, ok = FindDeletedGroup(ctx, group.ID, account.ID)
+	assert.False(t, ok)
+	assert.Equal(t, http.StatusGone, get())
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodPost, "/v1/tsg/groups/"+group.ID+"/restore", nil).WithContext(ctx)
+	Restore(w, mux.SetURLVars(r, map[string]string{"identifier": group.ID}))
+	assert.Equal(t, http.StatusGone, w.Code)
+}
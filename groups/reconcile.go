@@ -0,0 +1,276 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/templates"
+)
+
+// The reasons a group is skipped by a bulk reconcile.
+const (
+	reconcileSkippedPaused    = "paused"
+	reconcileSkippedExhausted = "reconcile budget exhausted"
+)
+
+// ReconcileResult is the outcome of forcing a reconcile of a single group.
+type ReconcileResult struct {
+	GroupID   string           `json:"group_id"`
+	GroupName string           `json:"group_name"`
+	Jobs      []*JobSubmission `json:"jobs,omitempty"`
+	// Skipped is why the group wasn't reconciled, if it wasn't.
+	Skipped string `json:"skipped,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ReconcileResults are the outcomes of a bulk reconcile, in the order the
+// groups were listed.
+type ReconcileResults struct {
+	Forced  int                `json:"forced"`
+	Skipped int                `json:"skipped"`
+	Failed  int                `json:"failed"`
+	Groups  []*ReconcileResult `json:"groups"`
+}
+
+// valueFilter matches maps holding every key and value of the filter, such as
+// the tags of a group's instances or the labels of a group. A key without a
+// value matches any value.
+type valueFilter map[string]*string
+
+// parseValueFilter reads the param query parameters of the request, each
+// either "key=value" or "key".
+func parseValueFilter(r *http.Request, param string) (valueFilter, error) {
+	filter := valueFilter{}
+	for _, v := range r.URL.Query()[param] {
+		key, value := v, (*string)(nil)
+		if i := strings.Index(v, "="); i >= 0 {
+			key = v[:i]
+			s := v[i+1:]
+			value = &s
+		}
+		if key == "" {
+			return nil, fmt.Errorf("%s %q must be a key or key=value", param, v)
+		}
+		filter[key] = value
+	}
+	return filter, nil
+}
+
+func (f valueFilter) matches(values map[string]string) bool {
+	for key, value := range f {
+		v, ok := values[key]
+		if !ok || (value != nil && v != *value) {
+			return false
+		}
+	}
+	return true
+}
+
+// findReconcileGroups and forceGroupReconciles are swapped out by tests.
+var (
+	findReconcileGroups  = FindGroupsByLabels
+	forceGroupReconciles = forceReconciles
+)
+
+// ReconcileGroups forces a periodic instance of the job of every group of the
+// account with the given labels and instance tags to run straight away,
+// rather than on their next tick, such as after an update to the image of
+// their template. Up to config.GetReconcileWorkers groups are forced at once,
+// and a group which fails doesn't stop the others from being forced. Paused
+// groups, and those which exhausted their reconcile budget, are skipped.
+func ReconcileGroups(ctx context.Context, labels, tags valueFilter) (*ReconcileResults, error) {
+	session := handlers.GetAuthSession(ctx)
+
+	groups, err := findReconcileGroups(ctx, session.AccountID, labels)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tags) > 0 {
+		groups = filterGroupsByTags(ctx, groups, tags)
+	}
+
+	results := make([]*ReconcileResult, len(groups))
+	indexes := make(chan int)
+
+	var wg sync.WaitGroup
+	for i := 0; i < config.GetReconcileWorkers(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				results[i] = reconcileGroup(ctx, groups[i])
+			}
+		}()
+	}
+	for i := range groups {
+		indexes <- i
+	}
+	close(indexes)
+	wg.Wait()
+
+	out := &ReconcileResults{Groups: results}
+	for _, result := range results {
+		switch {
+		case result.Skipped != "":
+			out.Skipped++
+		case result.Error != "":
+			out.Failed++
+		default:
+			out.Forced++
+		}
+	}
+	return out, nil
+}
+
+// filterGroupsByTags returns the groups whose tags match filter, reading each
+// template the groups' tags are merged over once. Groups whose template can't
+// be found have no tags to match.
+func filterGroupsByTags(ctx context.Context, groups []*ServiceGroup, filter valueFilter) []*ServiceGroup {
+	session := handlers.GetAuthSession(ctx)
+
+	found := make(map[string]*templates_v1.InstanceTemplate)
+	var matched []*ServiceGroup
+	for _, group := range groups {
+		t, ok := found[group.TemplateID]
+		if !ok {
+			t, ok = templates_v1.FindTemplateByID(ctx, group.TemplateID, session.AccountID)
+			if !ok {
+				continue
+			}
+			found[group.TemplateID] = t
+		}
+
+		if filter.matches(withInstanceOverrides(t, group).Tags) {
+			matched = append(matched, group)
+		}
+	}
+	return matched
+}
+
+// reconcileGroup forces a periodic instance of the job of group to run in
+// each of its datacenters.
+func reconcileGroup(ctx context.Context, group *ServiceGroup) *ReconcileResult {
+	result := &ReconcileResult{
+		GroupID:   group.ID,
+		GroupName: group.GroupName,
+	}
+
+	switch {
+	case group.Paused:
+		result.Skipped = reconcileSkippedPaused
+		return result
+	case Budgets.Exhausted(group.ID):
+		result.Skipped = reconcileSkippedExhausted
+		return result
+	}
+
+	jobs, err := forceGroupReconciles(ctx, group)
+	result.Jobs = jobs
+	if err != nil {
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// forceReconciles forces a periodic instance of the job of group to run in
+// each of its datacenters, see forceReconcile.
+func forceReconciles(ctx context.Context, group *ServiceGroup) ([]*JobSubmission, error) {
+	if group.isMultiDatacenter() {
+		return forEachSubmission(ctx, group, forceReconciles)
+	}
+
+	submission, err := forceReconcile(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+	return []*JobSubmission{submission}, nil
+}
+
+// forceReconcile forces a periodic instance of the job of a single datacenter
+// group to run, leaving the job itself as it's registered.
+func forceReconcile(ctx context.Context, group *ServiceGroup) (submission *JobSubmission, err error) {
+	defer func() { countJobOp(jobOpReconcile, err) }()
+	defer func() { auditJobOp(ctx, jobOpReconcile, group, err) }()
+
+	client, ok := handlers.GetNomadClient(ctx)
+	if !ok {
+		return nil, handlers.ErrNoNomadClient
+	}
+
+	jobID, err := resolveJobName(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	submission = &JobSubmission{
+		Datacenter: handlers.GetAuthSession(ctx).Datacenter,
+		JobID:      jobID,
+	}
+
+	err = retryNomad(ctx, "periodic force", func() error {
+		evalID, _, err := client.Jobs().PeriodicForce(jobID, nomadScopeOf(ctx).writeOptions())
+		if err == nil {
+			submission.PeriodicEvalID = evalID
+		}
+		return err
+	})
+	if err != nil {
+		return nil, &ErrNomad{Op: ErrNomadPeriodicForce, Err: err}
+	}
+
+	return submission, nil
+}
+
+// Reconcile forces a reconcile of every group of the account, or those with
+// the labels and instance tags given as label and tag query parameters.
+func Reconcile(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	jobType, err := config.GetJobType()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if jobType != config.JobTypeBatch {
+		http.Error(w, "the jobs of groups reconcile continuously rather than on a schedule", http.StatusConflict)
+		return
+	}
+
+	labels, err := parseLabelSelector(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tags, err := parseValueFilter(r, "tag")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	results, err := ReconcileGroups(ctx, labels, tags)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	bytes, err := json.Marshal(results)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, bytes, http.StatusOK)
+}
@@ -0,0 +1,109 @@
+package groups_v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseValueFilter(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/v1/tsg/groups/reconcile?tag=role=web&tag=canary&tag=env=", nil)
+	filter, err := parseValueFilter(r, "tag")
+	require.NoError(t, err)
+
+	assert.True(t, filter.matches(map[string]string{"role": "web", "canary": "yes", "env": ""}))
+	assert.False(t, filter.matches(map[string]string{"role": "db", "canary": "yes", "env": ""}))
+	assert.False(t, filter.matches(map[string]string{"role": "web", "env": ""}))
+	assert.True(t, valueFilter{}.matches(nil))
+
+	r = httptest.NewRequest(http.MethodPost, "/v1/tsg/groups/reconcile?tag==web", nil)
+	_, err = parseValueFilter(r, "tag")
+	assert.EqualError(t, err, `tag "=web" must be a key or key=value`)
+}
+
+func TestReconcileGroups(t *testing.T) {
+	defer viper.Reset()
+	viper.Set(config.KeyNomadReconcileWorkers, 2)
+
+	env := "staging"
+	labels := valueFilter{"env": &env}
+
+	groups := []*ServiceGroup{
+		{ID: "7c8a3c6e-6f1a-4d0c-9d8a-1d2f0e3b4a51", GroupName: "web"},
+		{ID: "2f0c1b7e-8a9d-4e6f-b5c4-3d2e1f0a9b87", GroupName: "db"},
+		{ID: "9e1d2c3b-4a5f-4e6d-8c7b-6a5f4e3d2c1b", GroupName: "cache", Paused: true},
+		{ID: "5a4b3c2d-1e0f-4a9b-8c7d-6e5f4a3b2c1d", GroupName: "queue"},
+	}
+
+	defer func(find func(ctx context.Context, accountID string, selector valueFilter) ([]*ServiceGroup, error)) {
+		findReconcileGroups = find
+	}(findReconcileGroups)
+	findReconcileGroups = func(ctx context.Context, accountID string, selector valueFilter) ([]*ServiceGroup, error) {
+		assert.Equal(t, labels, selector)
+		return groups, nil
+	}
+
+	var running, maxRunning int32
+	defer func(force func(ctx context.Context, group *ServiceGroup) ([]*JobSubmission, error)) {
+		forceGroupReconciles = force
+	}(forceGroupReconciles)
+	forceGroupReconciles = func(ctx context.Context, group *ServiceGroup) ([]*JobSubmission, error) {
+		n := atomic.AddInt32(&running, 1)
+		defer atomic.AddInt32(&running, -1)
+		for {
+			max := atomic.LoadInt32(&maxRunning)
+			if n <= max || atomic.CompareAndSwapInt32(&maxRunning, max, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+
+		if group.GroupName == "db" {
+			return nil, errors.New("no leader")
+		}
+		return []*JobSubmission{{JobID: group.GroupName + "_acct", PeriodicEvalID: "eval-" + group.GroupName}}, nil
+	}
+
+	ctx := handlers.WithAuthSession(context.Background(), &auth.Session{AccountID: "6d6f3c38-2ed5-4b7a-a0ad-8a2caa32bbb4"})
+	results, err := ReconcileGroups(ctx, labels, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, 2, results.Forced)
+	assert.Equal(t, 1, results.Skipped)
+	assert.Equal(t, 1, results.Failed)
+	assert.True(t, maxRunning <= 2, "ran %d groups at once", maxRunning)
+
+	// Results keep the order of the groups, and a failure doesn't stop the
+	// groups after it.
+	require.Len(t, results.Groups, 4)
+	assert.Equal(t, "eval-web", results.Groups[0].Jobs[0].PeriodicEvalID)
+	assert.Equal(t, "no leader", results.Groups[1].Error)
+	assert.Equal(t, reconcileSkippedPaused, results.Groups[2].Skipped)
+	assert.Empty(t, results.Groups[2].Jobs)
+	assert.Equal(t, "eval-queue", results.Groups[3].Jobs[0].PeriodicEvalID)
+
+	bytes, err := json.Marshal(results.Groups[1])
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"group_id": "2f0c1b7e-8a9d-4e6f-b5c4-3d2e1f0a9b87", "group_name": "db", "error": "no leader"}`, string(bytes))
+}
+
+func TestReconcileServiceJobs(t *testing.T) {
+	defer viper.Reset()
+	viper.Set(config.KeyNomadJobType, config.JobTypeService)
+
+	w := httptest.NewRecorder()
+	Reconcile(w, httptest.NewRequest(http.MethodPost, "/v1/tsg/groups/reconcile", nil))
+	assert.Equal(t, http.StatusConflict, w.Code)
+}
@@ -0,0 +1,75 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"errors"
+
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/templates"
+)
+
+// RenderInput selects the template revision and capacity used to simulate a
+// reconcile of a service group. Either defaults to the group's current value.
+type RenderInput struct {
+	TemplateID string `json:"template_id"`
+	Capacity   *int   `json:"capacity"`
+}
+
+// RenderedJob is the jobspec a service group would have deployed with a given
+// template revision and capacity. Credentials are never included.
+type RenderedJob struct {
+	GroupID    string `json:"group_id"`
+	TemplateID string `json:"template_id"`
+	Capacity   int    `json:"capacity"`
+	JobSpec    string `json:"jobspec"`
+}
+
+// RenderOrchestratorJob renders, without submitting, the job a service group
+// would run from a specific (possibly archived) template and capacity. This
+// answers what would have been deployed at the time that template was in use.
+func RenderOrchestratorJob(ctx context.Context, group *ServiceGroup, input *RenderInput) (*RenderedJob, error) {
+	session := handlers.GetAuthSession(ctx)
+
+	templateID := input.TemplateID
+	if templateID == "" {
+		templateID = group.TemplateID
+	}
+
+	t, found := templates_v1.FindTemplateRevisionByID(ctx, templateID, session.AccountID)
+	if !found {
+		return nil, errors.New("Error finding template by ID")
+	}
+
+	g := *group
+	if input.Capacity != nil {
+		g.Capacity = *input.Capacity
+	}
+
+	details, err := prepareJobDetails(ctx, t, &g)
+	if err != nil {
+		return nil, err
+	}
+
+	return renderRedactedJob(t, &g, details)
+}
+
+func renderRedactedJob(t *templates_v1.InstanceTemplate, group *ServiceGroup, details OrchestratorJob) (*RenderedJob, error) {
+	details.TritonKeyMaterial = ""
+
+	spec, err := renderJobSpec(details)
+	if err != nil {
+		return nil, err
+	}
+
+	return &RenderedJob{
+		GroupID:    group.ID,
+		TemplateID: t.ID,
+		Capacity:   group.Capacity,
+		JobSpec:    spec,
+	}, nil
+}
@@ -0,0 +1,111 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/templates"
+)
+
+// Restore undoes the deletion of a group which hasn't been purged yet and
+// registers its job again, so it's reconciled back to its capacity.
+func Restore(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	vars := mux.Vars(r)
+	identifier := vars["identifier"]
+
+	if _, ok := FindGroupByID(ctx, identifier, session.AccountID); ok {
+		http.Error(w, fmt.Sprintf("group %s is not deleted", identifier),
+			http.StatusConflict)
+		return
+	}
+
+	deleted, deletedAt, ok := FindDeletedGroup(ctx, identifier, session.AccountID)
+	if !ok {
+		groupNotFound(w, r, identifier)
+		return
+	}
+
+	since := restorableSince(time.Now(), config.GetGroupRetention())
+	if deletedAt.Before(since) {
+		http.Error(w, fmt.Sprintf("group %s was deleted at %s and can no longer be restored",
+			identifier, deletedAt.UTC().Format(time.RFC3339)), http.StatusGone)
+		return
+	}
+
+	groupExists, err := CheckGroupExistsByName(ctx, deleted.GroupName, session.AccountID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if groupExists {
+		http.Error(w, fmt.Sprintf("Cannot restore group %q, "+
+			"group name conflicts with existing group.",
+			deleted.GroupName), http.StatusConflict)
+		return
+	}
+
+	jobNameTaken, err := CheckJobNameTaken(ctx, deleted.GroupName, session.AccountID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if jobNameTaken {
+		http.Error(w, fmt.Sprintf("Cannot restore group %q, "+
+			"its job name conflicts with a group of another account.",
+			deleted.GroupName), http.StatusConflict)
+		return
+	}
+
+	if _, ok := templates_v1.FindTemplateByID(ctx, deleted.TemplateID, session.AccountID); !ok {
+		http.Error(w, fmt.Sprintf("Cannot restore group %q, "+
+			"template %s has been deleted.",
+			deleted.GroupName, deleted.TemplateID), http.StatusConflict)
+		return
+	}
+
+	restored, err := RestoreGroup(ctx, identifier, session.AccountID, since)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if !restored {
+		// The group was restored or purged by a concurrent request.
+		http.Error(w, fmt.Sprintf("group %s could not be restored", identifier),
+			http.StatusConflict)
+		return
+	}
+
+	group, ok := FindGroupByID(ctx, identifier, session.AccountID)
+	if !ok {
+		groupNotFound(w, r, identifier)
+		return
+	}
+
+	group.Jobs, err = SubmitOrchestratorJob(ctx, group)
+	if err != nil {
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
+		return
+	}
+
+	bytes, err := json.Marshal(group)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeETag(w, group)
+	writeJSONResponse(w, bytes, http.StatusOK)
+}
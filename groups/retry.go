@@ -0,0 +1,66 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+)
+
+var responseCodeRe = regexp.MustCompile(`Unexpected response code: (\d{3})`)
+
+// retryNomad calls fn until it succeeds, backing off exponentially between
+// attempts as configured. Only transient errors are retried, anything Nomad
+// rejected outright is returned immediately, as is the last error once the
+// attempts are used up or ctx is done.
+func retryNomad(ctx context.Context, op string, fn func() error) error {
+	retry := config.GetNomadRetry()
+
+	backoff := retry.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= retry.Attempts || !isTransient(err) {
+			return err
+		}
+
+		handlers.Logger(ctx).Warn().Err(err).
+			Str("op", op).
+			Int("attempt", attempt).
+			Dur("backoff", backoff).
+			Msg("orchestrator: nomad call failed, retrying")
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+		if backoff > retry.MaxBackoff {
+			backoff = retry.MaxBackoff
+		}
+	}
+}
+
+// isTransient returns true if err is worth retrying. The Nomad client reports
+// the status of failed requests in the error message; server errors are
+// transient while client errors, such as an invalid job, are not. Errors
+// without a status never reached Nomad, like a refused connection, and are
+// transient as well.
+func isTransient(err error) bool {
+	match := responseCodeRe.FindStringSubmatch(err.Error())
+	if match == nil {
+		return true
+	}
+
+	code, _ := strconv.Atoi(match[1])
+	return code >= 500
+}
@@ -0,0 +1,65 @@
+package groups_v1
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryNomad(t *testing.T) {
+	defer viper.Reset()
+	viper.Set(config.KeyNomadRetryAttempts, 3)
+	viper.Set(config.KeyNomadRetryInitialBackoff, "1ms")
+	viper.Set(config.KeyNomadRetryMaxBackoff, "2ms")
+
+	failing := func(errs ...error) (func() error, *int) {
+		var calls int
+		return func() error {
+			calls++
+			if calls <= len(errs) {
+				return errs[calls-1]
+			}
+			return nil
+		}, &calls
+	}
+
+	t.Run("transient", func(t *testing.T) {
+		fn, calls := failing(
+			errors.New("Unexpected response code: 500 (No cluster leader)"),
+			errors.New("Put http://127.0.0.1:4646/v1/jobs: dial tcp 127.0.0.1:4646: connect: connection refused"),
+		)
+		assert.NoError(t, retryNomad(context.Background(), "register", fn))
+		assert.Equal(t, 3, *calls)
+	})
+
+	t.Run("rejected", func(t *testing.T) {
+		rejected := errors.New("Unexpected response code: 400 (1 error occurred: missing driver)")
+		fn, calls := failing(rejected)
+		assert.Equal(t, rejected, retryNomad(context.Background(), "register", fn))
+		assert.Equal(t, 1, *calls)
+	})
+
+	t.Run("attempts used up", func(t *testing.T) {
+		unavailable := errors.New("Unexpected response code: 503 (unavailable)")
+		fn, calls := failing(unavailable, unavailable, unavailable, unavailable)
+		assert.Equal(t, unavailable, retryNomad(context.Background(), "deregister", fn))
+		assert.Equal(t, 3, *calls)
+	})
+
+	t.Run("canceled", func(t *testing.T) {
+		viper.Set(config.KeyNomadRetryInitialBackoff, "1h")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		unavailable := errors.New("Unexpected response code: 503 (unavailable)")
+		fn, calls := failing(unavailable, unavailable)
+		time.AfterFunc(10*time.Millisecond, cancel)
+
+		assert.Equal(t, unavailable, retryNomad(ctx, "deregister", fn))
+		assert.Equal(t, 1, *calls)
+	})
+}
@@ -0,0 +1,185 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/joyent/triton-service-groups/messages"
+	"github.com/joyent/triton-service-groups/server/handlers"
+)
+
+// ScaleInput is the body of a request to scale a group.
+type ScaleInput struct {
+	Capacity *int `json:"capacity"`
+}
+
+// ScaleResult is the outcome of scaling a group. When the group already had
+// the requested capacity nothing is changed, and no job is registered.
+type ScaleResult struct {
+	Capacity int  `json:"capacity"`
+	Changed  bool `json:"changed"`
+	// JobID and EvalID are the job registered with the new capacity and
+	// the evaluation its registration triggered.
+	JobID          string `json:"job_id,omitempty"`
+	EvalID         string `json:"eval_id,omitempty"`
+	PeriodicEvalID string `json:"periodic_eval_id,omitempty"`
+}
+
+// Scale sets the capacity of group and registers its job with the new
+// capacity. Unlike UpdateOrchestratorJob the group's image and networks
+// aren't looked up again, and its job is registered over the current one
+// rather than replacing it. If the job fails to register the group keeps its
+// previous capacity. Scaling to the group's current capacity is a no-op which
+// doesn't call Nomad.
+func Scale(ctx context.Context, group *ServiceGroup, capacity int) (*ScaleResult, error) {
+	if group.isMultiDatacenter() {
+		return nil, ErrMultiDatacenter
+	}
+	if capacity < 0 {
+		return nil, &ErrInvalidCapacity{Capacity: capacity}
+	}
+	if capacity == group.Capacity {
+		return &ScaleResult{Capacity: capacity}, nil
+	}
+
+	session := handlers.GetAuthSession(ctx)
+
+	scaled := *group
+	scaled.Capacity = capacity
+	if err := checkCapacity(ctx, session.AccountID, &scaled); err != nil {
+		return nil, err
+	}
+
+	// The update is conditional on the group being as it was read, so that
+	// a concurrent scale can't register a job for a capacity which was
+	// overwritten.
+	err := UpdateGroupIfUnmodified(ctx, group.ID, session.AccountID, &scaled, group.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	// A new capacity starts a new canary.
+	Canaries.Forget(group.ID)
+
+	submission, err := reregisterJob(ctx, &scaled, jobOpScale)
+	if err != nil {
+		restoreScaledGroup(ctx, session.AccountID, group, &scaled)
+		return nil, err
+	}
+
+	return &ScaleResult{
+		Capacity:       capacity,
+		Changed:        true,
+		JobID:          submission.JobID,
+		EvalID:         submission.EvalID,
+		PeriodicEvalID: submission.PeriodicEvalID,
+	}, nil
+}
+
+// restoreScaledGroup puts group back as it was before it was saved as scaled,
+// once the job with the new capacity failed to register, so the group's
+// capacity stays that of the job which is still running. A group modified
+// again since it was scaled is left as it is.
+func restoreScaledGroup(ctx context.Context, accountID string, group, scaled *ServiceGroup) {
+	saved, ok := FindGroupByID(ctx, group.ID, accountID)
+	if !ok || saved.Capacity != scaled.Capacity {
+		return
+	}
+
+	if err := UpdateGroupIfUnmodified(ctx, group.ID, accountID, group, saved.UpdatedAt); err != nil {
+		handlers.Logger(ctx).Error().Err(err).
+			Str("group_id", group.ID).
+			Int("capacity", scaled.Capacity).
+			Msg("orchestrator: unable to restore the capacity of group after failing to register its job")
+	}
+}
+
+// reregisterJob renders the job of a single datacenter group and registers
+// it in place of the current job, which keeps running if it's rejected. The
+// group's template isn't checked again, as it was when the job was first
+// submitted. The operation is counted as op.
+func reregisterJob(ctx context.Context, group *ServiceGroup, op string) (submission *JobSubmission, err error) {
+	defer func() { countJobOp(op, err) }()
+	defer func() { auditJobOp(ctx, op, group, err) }()
+
+	capacity, err := canaryCapacity(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	return registerGroupJob(ctx, group, capacity, false, true)
+}
+
+// ScaleCapacity sets the capacity of a group to the one in the request body.
+func ScaleCapacity(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	vars := mux.Vars(r)
+	identifier := vars["identifier"]
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var input ScaleInput
+	if err := json.Unmarshal(body, &input); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+	if input.Capacity == nil {
+		http.Error(w, "capacity is required", http.StatusUnprocessableEntity)
+		return
+	}
+
+	group, ok := FindGroupByID(ctx, identifier, session.AccountID)
+	if !ok {
+		groupNotFound(w, r, identifier)
+		return
+	}
+
+	if !ifMatch(r, group) {
+		messages.Write(w, r, ErrGroupModified, http.StatusPreconditionFailed)
+		return
+	}
+
+	result, err := Scale(ctx, group, *input.Capacity)
+	switch {
+	case err == nil:
+	case err == ErrMultiDatacenter:
+		messages.Write(w, r, err, http.StatusBadRequest)
+		return
+	case err == ErrGroupModified:
+		status := http.StatusConflict
+		if r.Header.Get("If-Match") != "" {
+			status = http.StatusPreconditionFailed
+		}
+		messages.Write(w, r, err, status)
+		return
+	default:
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
+		return
+	}
+
+	bytes, err := json.Marshal(result)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	status := http.StatusOK
+	if result.Changed {
+		status = http.StatusAccepted
+	}
+	writeJSONResponse(w, bytes, status)
+}
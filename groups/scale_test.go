@@ -0,0 +1,136 @@
+package groups_v1
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/joyent/triton-service-groups/accounts"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/joyent/triton-service-groups/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScale(t *testing.T) {
+	ctx := handlers.WithAuthSession(context.Background(), &auth.Session{AccountID: "6d6f3c38-2ed5-4b7a-a0ad-8a2caa32bbb4"})
+	group := &ServiceGroup{
+		ID:         "722d25ed-f32a-4944-9861-8990e204850e",
+		GroupName:  "jolly-jelly",
+		TemplateID: "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+		Capacity:   3,
+	}
+
+	t.Run("to the current capacity", func(t *testing.T) {
+		result, err := Scale(ctx, group, 3)
+		require.NoError(t, err)
+
+		assert.Equal(t, &ScaleResult{Capacity: 3}, result)
+	})
+
+	t.Run("to a negative capacity", func(t *testing.T) {
+		_, err := Scale(ctx, group, -1)
+		assert.Equal(t, &ErrInvalidCapacity{Capacity: -1}, err)
+	})
+
+	t.Run("in several datacenters", func(t *testing.T) {
+		multi := *group
+		multi.Datacenters = map[string]int{"us-east-1": 2, "us-west-1": 1}
+
+		_, err := Scale(ctx, &multi, 3)
+		assert.Equal(t, ErrMultiDatacenter, err)
+	})
+}
+
+func TestReregisterJobSharesGroupJob(t *testing.T) {
+	defer testReconciles()()
+
+	defer func(f func(ctx context.Context, group *ServiceGroup, capacity int, check bool) (*nomad.Job, error)) {
+		buildGroupJob = f
+	}(buildGroupJob)
+	var built []bool
+	buildGroupJob = func(ctx context.Context, group *ServiceGroup, capacity int, check bool) (*nomad.Job, error) {
+		built = append(built, check)
+		return nil, &ErrTemplateNotFound{TemplateID: group.TemplateID}
+	}
+
+	group := &ServiceGroup{GroupName: "web", TemplateID: "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5", Capacity: 2}
+
+	// Registering the job again builds it as submitting it does, without
+	// checking the template it was first submitted with again.
+	_, err := reregisterJob(context.Background(), group, jobOpScale)
+	assert.Equal(t, http.StatusNotFound, orchestratorErrorStatus(err))
+	_, err = SubmitOrchestratorJob(context.Background(), group)
+	assert.Equal(t, http.StatusNotFound, orchestratorErrorStatus(err))
+	assert.Equal(t, []bool{false, true}, built)
+}
+
+func TestScaleRegisterFailedRestoresGroup(t *testing.T) {
+	if os.Getenv("TSG_TEST") == "" {
+		t.Skip("Acceptance tests skipped unless env 'TSG_TEST=1' set")
+		return
+	}
+
+	db, err := testutils.NewTestDB()
+	if err != nil {
+		t.Error(err)
+	}
+	db.Clear(t)
+	defer db.Clear(t)
+
+	account := accounts.New(accounts.NewStore(db.Conn))
+	account.AccountName = "baconuser"
+	account.TritonUUID = "f5435e8b-70b8-4e4d-8c59-1dbe5d100b5b"
+	require.NoError(t, account.Insert(context.Background()))
+
+	// No Nomad client is configured, so the scaled job can't be registered.
+	ctx := handlers.WithDBPool(context.Background(), db.Conn)
+	ctx = handlers.WithAuthSession(ctx, &auth.Session{AccountID: account.ID, Datacenter: "us-east-1"})
+
+	require.NoError(t, templates_v1.SaveTemplate(ctx, account.ID, &templates_v1.InstanceTemplate{
+		TemplateName: "web",
+		Package:      "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+		ImageID:      testImageID,
+	}))
+	tmpl, ok := templates_v1.FindTemplateByName(ctx, "web", account.ID)
+	require.True(t, ok)
+
+	require.NoError(t, SaveGroup(ctx, account.ID, &ServiceGroup{GroupName: "web", TemplateID: tmpl.ID, Capacity: 1}))
+	group, ok := FindGroupByName(ctx, "web", account.ID)
+	require.True(t, ok)
+
+	_, err = Scale(ctx, group, 3)
+	require.Error(t, err)
+
+	saved, ok := FindGroupByID(ctx, group.ID, account.ID)
+	require.True(t, ok)
+	assert.Equal(t, 1, saved.Capacity, "the group should keep the capacity of its running job")
+}
+
+func TestScaleCapacityInput(t *testing.T) {
+	const groupID = "722d25ed-f32a-4944-9861-8990e204850e"
+
+	scale := func(body string) *httptest.ResponseRecorder {
+		ctx := handlers.WithAuthSession(context.Background(), &auth.Session{AccountID: "6d6f3c38-2ed5-4b7a-a0ad-8a2caa32bbb4"})
+		r := httptest.NewRequest(http.MethodPost, "/v1/tsg/groups/"+groupID+"/scale", strings.NewReader(body)).WithContext(ctx)
+		r = mux.SetURLVars(r, map[string]string{"identifier": groupID})
+
+		w := httptest.NewRecorder()
+		ScaleCapacity(w, r)
+		return w
+	}
+
+	w := scale(`{}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+	assert.Equal(t, "capacity is required\n", w.Body.String())
+
+	w = scale(`{"capacity": "three"}`)
+	assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+}
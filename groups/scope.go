@@ -0,0 +1,68 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+)
+
+// nomadScope is the Nomad namespace and region the jobs of groups are managed
+// in. An empty region targets the region of the Nomad agent.
+type nomadScope struct {
+	Namespace string
+	Region    string
+}
+
+// configuredScope returns the configured Nomad namespace and region.
+func configuredScope() nomadScope {
+	return nomadScope{
+		Namespace: config.GetNomadNamespace(),
+		Region:    config.GetNomadRegion(),
+	}
+}
+
+// backgroundSession returns the session background work, such as drift
+// remediation, acts as on behalf of accountID. Like the sessions of requests,
+// it's scoped to the configured Nomad namespace, region and datacenters.
+func backgroundSession(accountID, datacenter, tritonURL string) *auth.Session {
+	return &auth.Session{
+		AccountID:        accountID,
+		Datacenter:       datacenter,
+		TritonURL:        tritonURL,
+		NomadNamespace:   config.GetNomadNamespace(),
+		NomadRegion:      config.GetNomadRegion(),
+		NomadDatacenters: config.GetNomadDatacenters(),
+	}
+}
+
+// nomadScopeOf returns the Nomad scope the jobs of the session in ctx are
+// managed in. Background work acting without a session uses the configured
+// namespace and region.
+func nomadScopeOf(ctx context.Context) nomadScope {
+	session := handlers.GetAuthSession(ctx)
+
+	scope := configuredScope()
+	if session.NomadNamespace != "" {
+		scope.Namespace = session.NomadNamespace
+	}
+	if session.NomadRegion != "" {
+		scope.Region = session.NomadRegion
+	}
+	return scope
+}
+
+func (s nomadScope) queryOptions() *nomad.QueryOptions {
+	return &nomad.QueryOptions{Namespace: s.Namespace, Region: s.Region}
+}
+
+func (s nomadScope) writeOptions() *nomad.WriteOptions {
+	return &nomad.WriteOptions{Namespace: s.Namespace, Region: s.Region}
+}
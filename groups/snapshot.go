@@ -0,0 +1,122 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/templates"
+)
+
+// GroupSnapshot is the effective desired state of a service group, with its
+// template resolved. Identifiers, timestamps and anything else assigned by the
+// server are left out so that a snapshot can be diffed against the source the
+// group was created from.
+//
+// Fields are declared in alphabetical order, which together with the sorted
+// keys of encoding/json maps keeps the serialized form canonical.
+type GroupSnapshot struct {
+	Alerts              AlertThresholds   `json:"alerts"`
+	Canary              *CanaryConfig     `json:"canary,omitempty"`
+	Capacity            int               `json:"capacity"`
+	Datacenters         map[string]int    `json:"datacenters,omitempty"`
+	GroupName           string            `json:"group_name"`
+	InstanceNamePattern string            `json:"instance_name_pattern"`
+	Labels              map[string]string `json:"labels,omitempty"`
+	Reschedule          *ReschedulePolicy `json:"reschedule,omitempty"`
+	Restart             *RestartPolicy    `json:"restart,omitempty"`
+	Template            TemplateSnapshot  `json:"template"`
+	TimeZone            string            `json:"time_zone,omitempty"`
+	TSGCliVersion       string            `json:"tsg_cli_version,omitempty"`
+}
+
+// TemplateSnapshot is the part of a GroupSnapshot describing its instances.
+type TemplateSnapshot struct {
+	FirewallEnabled bool              `json:"firewall_enabled"`
+	FirewallRules   []string          `json:"firewall_rules,omitempty"`
+	ImageID         string            `json:"image_id"`
+	MetaData        map[string]string `json:"metadata"`
+	Networks        []string          `json:"networks"`
+	Package         string            `json:"package"`
+	Tags            map[string]string `json:"tags"`
+	TaskCPU         int               `json:"task_cpu,omitempty"`
+	TaskMemoryMB    int               `json:"task_memory_mb,omitempty"`
+	TemplateName    string            `json:"template_name"`
+	UserData        string            `json:"userdata"`
+}
+
+// GetGroupSnapshot resolves the current template of group and returns the
+// group's snapshot.
+func GetGroupSnapshot(ctx context.Context, group *ServiceGroup) (*GroupSnapshot, error) {
+	session := handlers.GetAuthSession(ctx)
+
+	t, found := templates_v1.FindTemplateByID(ctx, group.TemplateID, session.AccountID)
+	if !found {
+		return nil, errors.New("Error finding template by ID")
+	}
+
+	return SnapshotGroup(group, t), nil
+}
+
+// SnapshotGroup builds the snapshot of group running template t. Missing
+// collections are normalized to empty ones, so a template saved without tags
+// snapshots the same as one saved with no tags. The order of networks is kept
+// since it selects the primary network of each instance. The template is
+// snapshotted with the group's instance overrides applied.
+func SnapshotGroup(group *ServiceGroup, t *templates_v1.InstanceTemplate) *GroupSnapshot {
+	t = withInstanceOverrides(t, group)
+
+	networks := make([]string, len(t.Networks))
+	copy(networks, t.Networks)
+
+	return &GroupSnapshot{
+		Alerts:              group.Alerts,
+		Canary:              group.Canary,
+		Capacity:            group.Capacity,
+		Datacenters:         group.Datacenters,
+		GroupName:           group.GroupName,
+		InstanceNamePattern: group.InstanceNamePattern,
+		Labels:              group.Labels,
+		Reschedule:          group.Reschedule,
+		Restart:             group.Restart,
+		TimeZone:            group.TimeZone,
+		TSGCliVersion:       group.TSGCliVersion,
+		Template: TemplateSnapshot{
+			FirewallEnabled: t.FirewallEnabled,
+			FirewallRules:   append([]string(nil), t.FirewallRules...),
+			ImageID:         t.ImageID,
+			MetaData:        copyStringMap(t.MetaData),
+			Networks:        networks,
+			Package:         t.Package,
+			Tags:            copyStringMap(t.Tags),
+			TaskCPU:         t.TaskCPU,
+			TaskMemoryMB:    t.TaskMemoryMB,
+			TemplateName:    t.TemplateName,
+			UserData:        t.UserData,
+		},
+	}
+}
+
+// Marshal serializes the snapshot canonically: the same group always
+// serializes to the same bytes.
+func (s *GroupSnapshot) Marshal() ([]byte, error) {
+	bytes, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(bytes, '\n'), nil
+}
+
+func copyStringMap(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package groups_v1
+
+import (
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroupSnapshotStable(t *testing.T) {
+	group := &ServiceGroup{
+		ID:                  "722d25ed-f32a-4944-9861-8990e204850e",
+		GroupName:           "jolly-jelly",
+		TemplateID:          "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+		Capacity:            3,
+		CreatedAt:           time.Now(),
+		UpdatedAt:           time.Now(),
+		InstanceNamePattern: "{{group}}-{{index}}",
+		Alerts:              AlertThresholds{BelowCapacityMinutes: 10},
+		Account:             &GroupAccount{AccountName: "testacct", TritonUUID: "ad4a3f3e-2e3c-4f0e-8d3b-4d7a6d9f0b11"},
+	}
+
+	newTemplate := func() *templates_v1.InstanceTemplate {
+		tmpl := &templates_v1.InstanceTemplate{
+			ID:           "0f7fe6b8-5d24-4db3-a5e9-f5834e2bfbb5",
+			TemplateName: "jolly-jelly-template",
+			Package:      "g4-highcpu-1G",
+			ImageID:      "342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+			Networks: []string{
+				"0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+				"0a5c1c5f-1e4a-4d4c-9f1c-7e0d6f8e6b3a",
+			},
+			MetaData:  map[string]string{},
+			Tags:      map[string]string{},
+			CreatedAt: time.Now(),
+		}
+		for _, k := range []string{"zulu", "alpha", "mike", "bravo", "yankee", "charlie"} {
+			tmpl.MetaData[k] = k + "-value"
+			tmpl.Tags[k] = k
+		}
+		return tmpl
+	}
+
+	first, err := SnapshotGroup(group, newTemplate()).Marshal()
+	require.NoError(t, err)
+
+	for i := 0; i < 20; i++ {
+		bytes, err := SnapshotGroup(group, newTemplate()).Marshal()
+		require.NoError(t, err)
+		require.Equal(t, string(first), string(bytes))
+	}
+
+	snapshot := string(first)
+	assert.Contains(t, snapshot, `"group_name": "jolly-jelly"`)
+	assert.Contains(t, snapshot, `"template_name": "jolly-jelly-template"`)
+	for _, computed := range []string{group.ID, group.TemplateID, group.Account.TritonUUID, "testacct", "created_at", "updated_at"} {
+		assert.NotContains(t, snapshot, computed)
+	}
+}
+
+func TestGroupSnapshotNormalizesEmpty(t *testing.T) {
+	group := &ServiceGroup{GroupName: "jolly-jelly", Capacity: 1}
+
+	unset, err := SnapshotGroup(group, &templates_v1.InstanceTemplate{}).Marshal()
+	require.NoError(t, err)
+
+	empty, err := SnapshotGroup(group, &templates_v1.InstanceTemplate{
+		Networks: []string{},
+		MetaData: map[string]string{},
+		Tags:     map[string]string{},
+	}).Marshal()
+	require.NoError(t, err)
+
+	assert.Equal(t, string(empty), string(unset))
+	assert.Contains(t, string(unset), `"networks": []`)
+}
@@ -0,0 +1,257 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/joyent/triton-service-groups/server/handlers"
+)
+
+// PlacementFailure explains why the scheduler could not place a group's
+// scaling task.
+type PlacementFailure struct {
+	Datacenter        string   `json:"datacenter,omitempty"`
+	EvaluationID      string   `json:"evaluation_id"`
+	JobID             string   `json:"job_id"`
+	TaskGroup         string   `json:"task_group"`
+	NodesEvaluated    int      `json:"nodes_evaluated"`
+	CoalescedFailures int      `json:"coalesced_failures"`
+	Reasons           []string `json:"reasons"`
+}
+
+// GroupStatus is the orchestration state of a service group.
+type GroupStatus struct {
+	GroupID  string `json:"group_id"`
+	JobID    string `json:"job_id"`
+	Capacity int    `json:"capacity"`
+	// Paused is set while the group's reconciles are paused.
+	Paused            bool                `json:"paused"`
+	Labels            map[string]string   `json:"labels,omitempty"`
+	PlacementFailures []*PlacementFailure `json:"placement_failures"`
+	ReconcileBudget   *BudgetStatus       `json:"reconcile_budget,omitempty"`
+	Canary            *CanaryStatus       `json:"canary,omitempty"`
+	// Datacenters holds the status of each datacenter of a multi-datacenter
+	// group. Their placement failures are also listed above.
+	Datacenters map[string]*DatacenterStatus `json:"datacenters,omitempty"`
+}
+
+// DatacenterStatus is the orchestration state of a multi-datacenter group in
+// one of its datacenters. Datacenters which could not be reached report why.
+type DatacenterStatus struct {
+	Capacity          int                 `json:"capacity"`
+	PlacementFailures []*PlacementFailure `json:"placement_failures"`
+	Error             string              `json:"error,omitempty"`
+}
+
+// GetOrchestratorStatus returns the orchestration state of a service group,
+// including any placement failures reported by the latest evaluation of its
+// job and how much of its reconcile budget remains.
+func GetOrchestratorStatus(ctx context.Context, group *ServiceGroup) (*GroupStatus, error) {
+	if group.isMultiDatacenter() {
+		return getDatacentersStatus(ctx, group)
+	}
+	return getOrchestratorStatus(ctx, group)
+}
+
+// getOrchestratorStatus returns the status of a group's job in the
+// datacenter of the session.
+var getOrchestratorStatus = func(ctx context.Context, group *ServiceGroup) (*GroupStatus, error) {
+	name, err := resolveJobName(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	client, ok := handlers.GetNomadClient(ctx)
+	if !ok {
+		return nil, handlers.ErrNoNomadClient
+	}
+
+	failures, err := placementFailures(client, nomadScopeOf(ctx), name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &GroupStatus{
+		GroupID:           group.ID,
+		JobID:             name,
+		Capacity:          group.Capacity,
+		Paused:            group.Paused,
+		Labels:            group.Labels,
+		PlacementFailures: failures,
+		ReconcileBudget:   Budgets.Status(group.ID),
+		Canary:            Canaries.Status(group.ID),
+	}, nil
+}
+
+// getDatacentersStatus aggregates the status of a multi-datacenter group
+// across its datacenters. A datacenter which can't be reached is reported
+// without failing the others.
+func getDatacentersStatus(ctx context.Context, group *ServiceGroup) (*GroupStatus, error) {
+	status := &GroupStatus{
+		GroupID:           group.ID,
+		Capacity:          group.Capacity,
+		Paused:            group.Paused,
+		Labels:            group.Labels,
+		PlacementFailures: []*PlacementFailure{},
+		Datacenters:       make(map[string]*DatacenterStatus, len(group.Datacenters)),
+	}
+
+	err := forEachDatacenter(ctx, group, group.Datacenters, func(ctx context.Context, g *ServiceGroup) error {
+		datacenter := handlers.GetAuthSession(ctx).Datacenter
+		status.Datacenters[datacenter] = &DatacenterStatus{
+			Capacity:          g.Capacity,
+			PlacementFailures: []*PlacementFailure{},
+		}
+
+		dcStatus, err := getOrchestratorStatus(ctx, g)
+		if err != nil {
+			return err
+		}
+		status.JobID = dcStatus.JobID
+
+		for _, failure := range dcStatus.PlacementFailures {
+			failure.Datacenter = datacenter
+		}
+		status.Datacenters[datacenter].PlacementFailures = dcStatus.PlacementFailures
+		status.PlacementFailures = append(status.PlacementFailures, dcStatus.PlacementFailures...)
+		return nil
+	})
+	if errs, ok := err.(*ErrDatacenters); ok {
+		for name, err := range errs.Errors {
+			if _, ok := status.Datacenters[name]; !ok {
+				status.Datacenters[name] = &DatacenterStatus{
+					Capacity:          group.Datacenters[name],
+					PlacementFailures: []*PlacementFailure{},
+				}
+			}
+			status.Datacenters[name].Error = err.Error()
+		}
+	} else if err != nil {
+		return nil, err
+	}
+
+	return status, nil
+}
+
+// placementFailures reads the failed allocations of the most recent
+// evaluation across a job and its periodic runs.
+func placementFailures(client *nomad.Client, scope nomadScope, jobID string) ([]*PlacementFailure, error) {
+	jobIDs, err := jobFamilyIDs(client, scope, jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	var evals []*nomad.Evaluation
+	for _, id := range jobIDs {
+		jobEvals, _, err := client.Jobs().Evaluations(id, scope.queryOptions())
+		if err != nil {
+			return nil, &ErrNomad{Op: ErrNomadEvaluations, Err: err}
+		}
+		evals = append(evals, jobEvals...)
+	}
+
+	failures := []*PlacementFailure{}
+	if len(evals) == 0 {
+		return failures, nil
+	}
+
+	sort.Sort(nomad.EvalIndexSort(evals))
+	latest := evals[0]
+
+	taskGroups := make([]string, 0, len(latest.FailedTGAllocs))
+	for tg := range latest.FailedTGAllocs {
+		taskGroups = append(taskGroups, tg)
+	}
+	sort.Strings(taskGroups)
+
+	for _, tg := range taskGroups {
+		metric := latest.FailedTGAllocs[tg]
+		failures = append(failures, &PlacementFailure{
+			EvaluationID:      latest.ID,
+			JobID:             latest.JobID,
+			TaskGroup:         tg,
+			NodesEvaluated:    metric.NodesEvaluated,
+			CoalescedFailures: metric.CoalescedFailures,
+			Reasons:           placementReasons(metric),
+		})
+	}
+
+	return failures, nil
+}
+
+// placementReasons maps the raw scheduler metrics of a failed allocation
+// into human readable reasons, most common first.
+func placementReasons(metric *nomad.AllocationMetric) []string {
+	type reason struct {
+		msg   string
+		nodes int
+	}
+
+	var reasons []reason
+	if metric.NodesEvaluated == 0 && metric.NodesFiltered == 0 {
+		reasons = append(reasons, reason{msg: "no nodes available in datacenter"})
+	}
+	for class, n := range metric.ClassFiltered {
+		reasons = append(reasons, reason{fmt.Sprintf("no nodes matched class %s", class), n})
+	}
+	for constraint, n := range metric.ConstraintFiltered {
+		reasons = append(reasons, reason{fmt.Sprintf("no nodes matched %s", formatConstraint(constraint)), n})
+	}
+	for dimension, n := range metric.DimensionExhausted {
+		reasons = append(reasons, reason{formatDimension(dimension), n})
+	}
+	for _, quota := range metric.QuotaExhausted {
+		reasons = append(reasons, reason{msg: fmt.Sprintf("quota exhausted: %s", quota)})
+	}
+
+	sort.Slice(reasons, func(i, j int) bool {
+		if reasons[i].nodes != reasons[j].nodes {
+			return reasons[i].nodes > reasons[j].nodes
+		}
+		return reasons[i].msg < reasons[j].msg
+	})
+
+	msgs := make([]string, 0, len(reasons))
+	for _, r := range reasons {
+		msg := r.msg
+		if r.nodes > 0 {
+			msg = fmt.Sprintf("%s (%d %s)", msg, r.nodes, pluralize(r.nodes, "node", "nodes"))
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs
+}
+
+var matchInterpolation = regexp.MustCompile(`\$\{(?:meta|attr|node)\.([^}]+)\}`)
+
+// formatConstraint simplifies a scheduler constraint such as
+// "${meta.role} = automater" into "role=automater".
+func formatConstraint(constraint string) string {
+	constraint = matchInterpolation.ReplaceAllString(constraint, "$1")
+	return strings.Replace(constraint, " = ", "=", -1)
+}
+
+// formatDimension describes an exhausted resource dimension such as
+// "memory exhausted" as "insufficient memory".
+func formatDimension(dimension string) string {
+	if strings.HasSuffix(dimension, " exhausted") {
+		return "insufficient " + strings.TrimSuffix(dimension, " exhausted")
+	}
+	return "resources exhausted: " + dimension
+}
+
+func pluralize(n int, singular, plural string) string {
+	if n == 1 {
+		return singular
+	}
+	return plural
+}
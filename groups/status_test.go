@@ -0,0 +1,117 @@
+package groups_v1
+
+import (
+	"net/http"
+	"testing"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/joyent/triton-service-groups/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPlacementFailures(t *testing.T) {
+	const jobID = "test-group_c2e4d1491ce423e3"
+	childID := jobID + "/periodic-1525209600"
+
+	fake := testutils.NewFakeNomad(t)
+	defer fake.Close()
+
+	fake.HandleJSON("/v1/jobs", []*nomad.JobListStub{
+		{ID: childID, ParentID: jobID},
+	})
+	fake.HandleFunc("/v1/job/", func(w http.ResponseWriter, r *http.Request) {
+		var evals []*nomad.Evaluation
+
+		switch r.URL.Path {
+		case "/v1/job/" + jobID + "/evaluations":
+			evals = []*nomad.Evaluation{
+				{ID: "eval-1", JobID: jobID, Status: "complete", CreateIndex: 10},
+			}
+		case "/v1/job/" + childID + "/evaluations":
+			evals = []*nomad.Evaluation{
+				{
+					ID:          "eval-2",
+					JobID:       childID,
+					Status:      "complete",
+					CreateIndex: 20,
+					FailedTGAllocs: map[string]*nomad.AllocationMetric{
+						"scale": {
+							NodesEvaluated: 4,
+							NodesFiltered:  3,
+							ConstraintFiltered: map[string]int{
+								"${meta.role} = automater": 3,
+							},
+							NodesExhausted: 1,
+							DimensionExhausted: map[string]int{
+								"memory exhausted": 1,
+							},
+							CoalescedFailures: 2,
+						},
+					},
+				},
+			}
+		}
+
+		testutils.WriteJSON(w, evals)
+	})
+
+	failures, err := placementFailures(fake.Client, nomadScope{}, jobID)
+	require.NoError(t, err)
+	require.Len(t, failures, 1)
+
+	failure := failures[0]
+	assert.Equal(t, "eval-2", failure.EvaluationID)
+	assert.Equal(t, childID, failure.JobID)
+	assert.Equal(t, "scale", failure.TaskGroup)
+	assert.Equal(t, 4, failure.NodesEvaluated)
+	assert.Equal(t, 2, failure.CoalescedFailures)
+	assert.Equal(t, []string{
+		"no nodes matched role=automater (3 nodes)",
+		"insufficient memory (1 node)",
+	}, failure.Reasons)
+}
+
+func TestPlacementReasons(t *testing.T) {
+	tests := []struct {
+		name     string
+		metric   *nomad.AllocationMetric
+		expected []string
+	}{
+		{
+			"no nodes",
+			&nomad.AllocationMetric{},
+			[]string{"no nodes available in datacenter"},
+		},
+		{
+			"distinct hosts",
+			&nomad.AllocationMetric{
+				NodesEvaluated:     2,
+				NodesFiltered:      2,
+				ConstraintFiltered: map[string]int{"distinct_hosts": 2},
+			},
+			[]string{"no nodes matched distinct_hosts (2 nodes)"},
+		},
+		{
+			"resources",
+			&nomad.AllocationMetric{
+				NodesEvaluated: 3,
+				NodesExhausted: 3,
+				DimensionExhausted: map[string]int{
+					"cpu exhausted":      1,
+					"bandwidth exceeded": 2,
+				},
+			},
+			[]string{
+				"resources exhausted: bandwidth exceeded (2 nodes)",
+				"insufficient cpu (1 node)",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, placementReasons(tt.metric))
+		})
+	}
+}
@@ -0,0 +1,235 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/jackc/pgx"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/rs/zerolog/log"
+)
+
+// Statuses caches the job status of every group, so that status requests
+// don't each wait on Nomad. It's disabled until configured.
+var Statuses = NewStatusCache()
+
+type statusCacheEntry struct {
+	status *JobStatus
+	// job is the job the status describes, nil if it isn't registered, from
+	// which its next launch is worked out as the status is served.
+	job *nomad.Job
+}
+
+// StatusCache holds the most recently read status of each job, refreshed in
+// the background by a StatusRefresher. Jobs are keyed as in the job info
+// cache.
+type StatusCache struct {
+	mu       sync.Mutex
+	interval time.Duration
+	entries  map[jobCacheKey]statusCacheEntry
+	// generation is bumped on every invalidation so that a status read
+	// before the job changed isn't cached after it.
+	generation uint64
+
+	now func() time.Time
+}
+
+// NewStatusCache constructs a disabled status cache.
+func NewStatusCache() *StatusCache {
+	return &StatusCache{
+		entries: make(map[jobCacheKey]statusCacheEntry),
+		now:     time.Now,
+	}
+}
+
+// Configure sets how often the cache is refreshed. A zero interval disables
+// the cache, so every status is read from Nomad.
+func (c *StatusCache) Configure(interval time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.interval = interval
+}
+
+// Enabled returns true if statuses are cached.
+func (c *StatusCache) Enabled() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.interval > 0
+}
+
+// Get returns a copy of the cached status of a job, marked stale if it
+// hasn't been refreshed for two intervals.
+func (c *StatusCache) Get(datacenter string, scope nomadScope, jobID string) (*JobStatus, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.interval <= 0 {
+		return nil, false
+	}
+
+	entry, ok := c.entries[jobCacheKey{datacenter, scope, jobID}]
+	if !ok {
+		metrics.IncrCounter([]string{"nomad", "status_cache", "miss"}, 1)
+		return nil, false
+	}
+	metrics.IncrCounter([]string{"nomad", "status_cache", "hit"}, 1)
+
+	now := c.now()
+	status := *entry.status
+	status.Cached = true
+	status.Stale = now.Sub(status.RefreshedAt) > 2*c.interval
+	if entry.job != nil && entry.job.Periodic != nil {
+		status.NextLaunchAt = nextLaunch(entry.job, now)
+	}
+	return &status, true
+}
+
+// Generation returns the current generation of the cache, to be passed to
+// Set along with a status read after it.
+func (c *StatusCache) Generation() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+// Set caches the status of a job, and the job it describes, unless the cache
+// was invalidated since generation.
+func (c *StatusCache) Set(datacenter string, scope nomadScope, jobID string, status *JobStatus, job *nomad.Job, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.interval <= 0 || generation != c.generation {
+		return
+	}
+	c.entries[jobCacheKey{datacenter, scope, jobID}] = statusCacheEntry{status: status, job: job}
+}
+
+// Invalidate drops the cached status of a job, which must be done whenever
+// the job is changed.
+func (c *StatusCache) Invalidate(datacenter string, scope nomadScope, jobID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	delete(c.entries, jobCacheKey{datacenter, scope, jobID})
+}
+
+// Retain drops the cached status of every job in the datacenter and scope
+// which isn't one of jobIDs, such as those of deleted groups.
+func (c *StatusCache) Retain(datacenter string, scope nomadScope, jobIDs map[string]bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key := range c.entries {
+		if key.datacenter == datacenter && key.scope == scope && !jobIDs[key.jobID] {
+			delete(c.entries, key)
+		}
+	}
+}
+
+// StatusRefresher periodically reads the job status of every group in the
+// local datacenter from Nomad into a status cache. A job which can't be read
+// keeps its previous status, which is reported stale once it's old enough.
+type StatusRefresher struct {
+	interval   time.Duration
+	datacenter string
+	scope      nomadScope
+	pool       *pgx.ConnPool
+	client     *nomad.Client
+	statuses   *StatusCache
+
+	now        func() time.Time
+	findGroups func(ctx context.Context) ([]*ManagedGroup, error)
+	readStatus func(ctx context.Context, group *ManagedGroup, now time.Time) (*JobStatus, *nomad.Job, error)
+}
+
+// NewStatusRefresher constructs a refresher of the given status cache.
+func NewStatusRefresher(interval time.Duration, datacenter string, pool *pgx.ConnPool, client *nomad.Client, statuses *StatusCache) *StatusRefresher {
+	r := &StatusRefresher{
+		interval:   interval,
+		datacenter: datacenter,
+		scope:      configuredScope(),
+		pool:       pool,
+		client:     client,
+		statuses:   statuses,
+		now:        time.Now,
+		findGroups: findLocalGroups,
+	}
+	r.readStatus = func(ctx context.Context, group *ManagedGroup, now time.Time) (*JobStatus, *nomad.Job, error) {
+		return getJobStatus(ctx, r.client, group.ID, group.JobName(), now)
+	}
+	return r
+}
+
+// Run refreshes the status of every group once per interval until ctx is
+// done.
+func (r *StatusRefresher) Run(ctx context.Context) {
+	if !r.statuses.Enabled() {
+		log.Debug().Msg("status: status cache disabled")
+		return
+	}
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := r.Refresh(ctx); err != nil {
+				log.Error().Err(err).Msg("status: failed to refresh job statuses")
+			}
+		}
+	}
+}
+
+// Refresh reads the status of every group's job once, and drops the cached
+// status of jobs whose groups are gone.
+func (r *StatusRefresher) Refresh(ctx context.Context) error {
+	ctx = handlers.WithDBPool(ctx, r.pool)
+
+	groups, err := r.findGroups(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx = handlers.WithAuthSession(ctx, &auth.Session{Datacenter: r.datacenter})
+	ctx = handlers.WithNomadClient(ctx, r.client)
+
+	jobIDs := make(map[string]bool, len(groups))
+	var failed int
+	for _, group := range groups {
+		jobID := group.JobName()
+		jobIDs[jobID] = true
+
+		generation := r.statuses.Generation()
+		status, job, err := r.readStatus(ctx, group, r.now())
+		if err != nil {
+			failed++
+			log.Error().Err(err).
+				Str("group_id", group.ID).
+				Str("job_id", jobID).
+				Msg("status: failed to refresh job status")
+			continue
+		}
+		r.statuses.Set(r.datacenter, r.scope, jobID, status, job, generation)
+	}
+	r.statuses.Retain(r.datacenter, r.scope, jobIDs)
+
+	metrics.SetGauge([]string{"status_cache", "refresh_failures"}, float32(failed))
+
+	return nil
+}
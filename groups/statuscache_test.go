@@ -0,0 +1,141 @@
+package groups_v1
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/hashicorp/nomad/helper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testStatusCache(interval time.Duration, now *time.Time) *StatusCache {
+	c := NewStatusCache()
+	c.now = func() time.Time { return *now }
+	c.Configure(interval)
+	return c
+}
+
+func TestStatusCache(t *testing.T) {
+	now := time.Date(2018, 4, 14, 15, 0, 0, 0, time.UTC)
+	c := testStatusCache(30*time.Second, &now)
+	scope := nomadScope{Namespace: "default"}
+
+	_, ok := c.Get("us-east-1", scope, "web_c2e4d1491ce423e3")
+	assert.False(t, ok)
+
+	job := &nomad.Job{
+		ID: helper.StringToPtr("web_c2e4d1491ce423e3"),
+		Periodic: &nomad.PeriodicConfig{
+			Enabled:  helper.BoolToPtr(true),
+			Spec:     helper.StringToPtr("*/5 * * * *"),
+			SpecType: helper.StringToPtr(nomad.PeriodicSpecCron),
+		},
+	}
+	c.Set("us-east-1", scope, "web_c2e4d1491ce423e3", &JobStatus{
+		JobID:       "web_c2e4d1491ce423e3",
+		Status:      "running",
+		RefreshedAt: now,
+	}, job, c.Generation())
+
+	// The next launch is worked out as the status is served.
+	now = now.Add(20 * time.Second)
+	status, ok := c.Get("us-east-1", scope, "web_c2e4d1491ce423e3")
+	require.True(t, ok)
+	assert.Equal(t, "running", status.Status)
+	assert.True(t, status.Cached)
+	assert.False(t, status.Stale)
+	require.NotNil(t, status.NextLaunchAt)
+	assert.Equal(t, time.Date(2018, 4, 14, 15, 5, 0, 0, time.UTC), *status.NextLaunchAt)
+
+	now = now.Add(time.Minute)
+	status, ok = c.Get("us-east-1", scope, "web_c2e4d1491ce423e3")
+	require.True(t, ok)
+	assert.True(t, status.Stale)
+
+	_, ok = c.Get("us-west-1", scope, "web_c2e4d1491ce423e3")
+	assert.False(t, ok)
+
+	c.Invalidate("us-east-1", scope, "web_c2e4d1491ce423e3")
+	_, ok = c.Get("us-east-1", scope, "web_c2e4d1491ce423e3")
+	assert.False(t, ok)
+}
+
+func TestStatusCacheInvalidatedWhileReading(t *testing.T) {
+	now := time.Date(2018, 4, 14, 15, 0, 0, 0, time.UTC)
+	c := testStatusCache(30*time.Second, &now)
+
+	generation := c.Generation()
+	c.Invalidate("us-east-1", nomadScope{}, "web_c2e4d1491ce423e3")
+	c.Set("us-east-1", nomadScope{}, "web_c2e4d1491ce423e3", &JobStatus{RefreshedAt: now}, nil, generation)
+
+	_, ok := c.Get("us-east-1", nomadScope{}, "web_c2e4d1491ce423e3")
+	assert.False(t, ok)
+}
+
+func TestStatusCacheDisabled(t *testing.T) {
+	now := time.Date(2018, 4, 14, 15, 0, 0, 0, time.UTC)
+	c := testStatusCache(0, &now)
+	assert.False(t, c.Enabled())
+
+	c.Set("us-east-1", nomadScope{}, "web_c2e4d1491ce423e3", &JobStatus{RefreshedAt: now}, nil, c.Generation())
+	_, ok := c.Get("us-east-1", nomadScope{}, "web_c2e4d1491ce423e3")
+	assert.False(t, ok)
+}
+
+func TestStatusRefresher(t *testing.T) {
+	now := time.Date(2018, 4, 14, 15, 0, 0, 0, time.UTC)
+	statuses := testStatusCache(30*time.Second, &now)
+
+	groups := testManagedGroups("web", "db")
+	failing := map[string]bool{}
+
+	r := &StatusRefresher{
+		datacenter: "us-east-1",
+		statuses:   statuses,
+		now:        func() time.Time { return now },
+		findGroups: func(ctx context.Context) ([]*ManagedGroup, error) {
+			return groups, nil
+		},
+		readStatus: func(ctx context.Context, group *ManagedGroup, now time.Time) (*JobStatus, *nomad.Job, error) {
+			if failing[group.GroupName] {
+				return nil, nil, errors.New("connection refused")
+			}
+			return &JobStatus{
+				GroupID:     group.ID,
+				JobID:       group.JobName(),
+				Status:      "running",
+				RefreshedAt: now,
+			}, nil, nil
+		},
+	}
+
+	require.NoError(t, r.Refresh(context.Background()))
+	for _, group := range groups {
+		status, ok := statuses.Get("us-east-1", nomadScope{}, group.JobName())
+		require.True(t, ok, group.GroupName)
+		assert.Equal(t, group.ID, status.GroupID)
+		assert.Equal(t, now, status.RefreshedAt)
+	}
+
+	// A job which can't be read keeps its previous status until it's stale.
+	failing["web"] = true
+	now = now.Add(time.Minute + time.Second)
+	require.NoError(t, r.Refresh(context.Background()))
+
+	web, ok := statuses.Get("us-east-1", nomadScope{}, groups[0].JobName())
+	require.True(t, ok)
+	assert.True(t, web.Stale)
+	db, ok := statuses.Get("us-east-1", nomadScope{}, groups[1].JobName())
+	require.True(t, ok)
+	assert.False(t, db.Stale)
+
+	// The statuses of deleted groups are dropped.
+	groups = groups[1:]
+	require.NoError(t, r.Refresh(context.Background()))
+	_, ok = statuses.Get("us-east-1", nomadScope{}, jobName("web", groups[0].JobRef))
+	assert.False(t, ok)
+}
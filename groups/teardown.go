@@ -0,0 +1,123 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/joyent/triton-go/compute"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/rs/zerolog/log"
+)
+
+// teardownPollInterval is how often Triton is polled while waiting on the
+// instances of a deleted group to be destroyed.
+var teardownPollInterval = 5 * time.Second
+
+// TeardownResult reports how far the instances of a deleted group were torn
+// down by the time a synchronous delete returned.
+type TeardownResult struct {
+	GroupID string `json:"group_id"`
+	// Complete is true once every instance of the group was destroyed.
+	Complete bool `json:"complete"`
+	// Destroyed counts the instances running when the group was deleted
+	// which have since been destroyed.
+	Destroyed int `json:"destroyed"`
+	// Remaining lists the IDs of the instances yet to be destroyed.
+	Remaining []string `json:"remaining"`
+}
+
+// listTeardownInstances lists the instances of a group in every datacenter it
+// runs in. It's a variable so tests can run without Triton.
+var listTeardownInstances = func(ctx context.Context, group *ServiceGroup) ([]*compute.Instance, error) {
+	var found []*compute.Instance
+	err := forEachDatacenter(ctx, group, datacenterCapacity(ctx, group), func(ctx context.Context, g *ServiceGroup) error {
+		session := handlers.GetAuthSession(ctx)
+		instances, err := listGroupInstances(ctx, session.AccountID, session.TritonURL, g)
+		if err != nil {
+			return err
+		}
+		found = append(found, instances...)
+		return nil
+	})
+	return found, err
+}
+
+// liveInstances returns the IDs of the instances of a group which have yet to
+// be destroyed.
+func liveInstances(ctx context.Context, group *ServiceGroup) ([]string, error) {
+	instances, err := listTeardownInstances(ctx, group)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, instance := range instances {
+		if instance.State != "deleted" {
+			ids = append(ids, instance.ID)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+// waitForTeardown polls the instances of a deleted group until none remain or
+// timeout elapses, in which case the result reports what remains. initial
+// holds the IDs of the instances which ran when the group was deleted. Only
+// the cancellation of ctx itself is returned as an error.
+func waitForTeardown(ctx context.Context, group *ServiceGroup, initial []string, timeout time.Duration) (*TeardownResult, error) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	ticker := time.NewTicker(teardownPollInterval)
+	defer ticker.Stop()
+
+	remaining := initial
+	for {
+		ids, err := liveInstances(ctx, group)
+		if err != nil {
+			log.Warn().Err(err).
+				Str("group_id", group.ID).
+				Msg("orchestrator: failed to list instances while waiting on teardown")
+		} else {
+			remaining = ids
+			if len(remaining) == 0 {
+				return teardownResult(group, initial, remaining), nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-deadline.C:
+			return teardownResult(group, initial, remaining), nil
+		case <-ticker.C:
+		}
+	}
+}
+
+func teardownResult(group *ServiceGroup, initial, remaining []string) *TeardownResult {
+	live := make(map[string]bool, len(remaining))
+	for _, id := range remaining {
+		live[id] = true
+	}
+
+	var destroyed int
+	for _, id := range initial {
+		if !live[id] {
+			destroyed++
+		}
+	}
+
+	return &TeardownResult{
+		GroupID:   group.ID,
+		Complete:  len(remaining) == 0,
+		Destroyed: destroyed,
+		Remaining: remaining,
+	}
+}
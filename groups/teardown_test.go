@@ -0,0 +1,93 @@
+package groups_v1
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-go/compute"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withTeardownInstances(polls ...[]*compute.Instance) func() {
+	list, interval := listTeardownInstances, teardownPollInterval
+	teardownPollInterval = time.Millisecond
+
+	var calls int
+	listTeardownInstances = func(ctx context.Context, group *ServiceGroup) ([]*compute.Instance, error) {
+		if calls >= len(polls) {
+			return polls[len(polls)-1], nil
+		}
+		instances := polls[calls]
+		calls++
+		if instances == nil {
+			return nil, errors.New("connection refused")
+		}
+		return instances, nil
+	}
+
+	return func() { listTeardownInstances, teardownPollInterval = list, interval }
+}
+
+func testTeardownInstances(ids ...string) []*compute.Instance {
+	instances := []*compute.Instance{}
+	for _, id := range ids {
+		instances = append(instances, &compute.Instance{ID: id, State: "stopping"})
+	}
+	return instances
+}
+
+func TestWaitForTeardown(t *testing.T) {
+	gone := testTeardownInstances("instance-3")
+	gone[0].State = "deleted"
+
+	defer withTeardownInstances(
+		testTeardownInstances("instance-1", "instance-2", "instance-3"),
+		nil,
+		testTeardownInstances("instance-3"),
+		gone,
+	)()
+
+	group := &ServiceGroup{ID: "web-id"}
+	initial := []string{"instance-1", "instance-2", "instance-3"}
+
+	result, err := waitForTeardown(context.Background(), group, initial, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, &TeardownResult{
+		GroupID:   "web-id",
+		Complete:  true,
+		Destroyed: 3,
+		Remaining: []string{},
+	}, result)
+}
+
+func TestWaitForTeardownTimeout(t *testing.T) {
+	defer withTeardownInstances(
+		testTeardownInstances("instance-2", "instance-1", "instance-3"),
+		testTeardownInstances("instance-3", "instance-1"),
+	)()
+
+	group := &ServiceGroup{ID: "web-id"}
+	initial := []string{"instance-1", "instance-2", "instance-3"}
+
+	result, err := waitForTeardown(context.Background(), group, initial, 20*time.Millisecond)
+	require.NoError(t, err)
+	assert.Equal(t, &TeardownResult{
+		GroupID:   "web-id",
+		Complete:  false,
+		Destroyed: 1,
+		Remaining: []string{"instance-1", "instance-3"},
+	}, result)
+}
+
+func TestWaitForTeardownCancel(t *testing.T) {
+	defer withTeardownInstances(testTeardownInstances("instance-1"))()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := waitForTeardown(ctx, &ServiceGroup{ID: "web-id"}, []string{"instance-1"}, time.Minute)
+	assert.Equal(t, context.Canceled, err)
+}
@@ -0,0 +1,44 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"time"
+
+	"github.com/joyent/triton-service-groups/config"
+)
+
+// validateTimeZone checks that the time zone of a group is one Nomad can run
+// the group's periodic schedule in. An empty time zone runs it in the
+// configured one.
+func validateTimeZone(tz string) error {
+	if tz == "" {
+		return nil
+	}
+	// LoadLocation reads "" and "Local" as the agent's own time zone, which
+	// needn't be that of the Nomad servers.
+	if _, err := time.LoadLocation(tz); err != nil || tz == "Local" {
+		return &ErrUnsafeJobValue{
+			Field:  "time zone",
+			Value:  tz,
+			Reason: "must be an IANA time zone such as Europe/Berlin",
+		}
+	}
+	return nil
+}
+
+// jobTimeZone returns the time zone the periodic schedule of the group's job
+// runs in, either its own or the configured one.
+func jobTimeZone(group *ServiceGroup) (string, error) {
+	tz := group.TimeZone
+	if tz == "" {
+		tz = config.GetNomadTimeZone()
+	}
+	if err := validateTimeZone(tz); err != nil {
+		return "", err
+	}
+	return tz, nil
+}
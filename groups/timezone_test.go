@@ -0,0 +1,67 @@
+package groups_v1
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTimeZone(t *testing.T) {
+	for _, tz := range []string{"", "UTC", "Europe/Berlin", "America/New_York"} {
+		assert.NoError(t, validateTimeZone(tz), tz)
+	}
+
+	for _, tz := range []string{"Local", "Mars/Olympus_Mons", "CEST", "../../etc/passwd"} {
+		err := validateTimeZone(tz)
+		require.Error(t, err, tz)
+		assert.Equal(t, http.StatusUnprocessableEntity, orchestratorErrorStatus(err))
+	}
+}
+
+func TestTimeZoneOverride(t *testing.T) {
+	defer viper.Reset()
+
+	tmpl := &templates_v1.InstanceTemplate{
+		Package: "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+		ImageID: "342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+	}
+
+	details, err := createJobDetails(tmpl, &ServiceGroup{GroupName: "web"})
+	require.NoError(t, err)
+	assert.Equal(t, config.DefaultNomadTimeZone, details.TimeZone)
+
+	viper.Set(config.KeyNomadTimeZone, "America/New_York")
+	details, err = createJobDetails(tmpl, &ServiceGroup{GroupName: "web"})
+	require.NoError(t, err)
+	assert.Equal(t, "America/New_York", details.TimeZone)
+
+	details, err = createJobDetails(tmpl, &ServiceGroup{GroupName: "web", TimeZone: "Europe/Berlin"})
+	require.NoError(t, err)
+	assert.Equal(t, "Europe/Berlin", details.TimeZone)
+
+	_, err = createJobDetails(tmpl, &ServiceGroup{GroupName: "web", TimeZone: "Mars/Olympus_Mons"})
+	assert.EqualError(t, err, `time zone "Mars/Olympus_Mons" can't be used in a job: must be an IANA time zone such as Europe/Berlin`)
+}
+
+func TestBuildJobTimeZone(t *testing.T) {
+	details := testJobDetails(nil)
+	details.TimeZone = "Europe/Berlin"
+
+	job, err := buildJob(details)
+	require.NoError(t, err)
+	require.NotNil(t, job.Periodic)
+	require.NotNil(t, job.Periodic.TimeZone)
+	assert.Equal(t, "Europe/Berlin", *job.Periodic.TimeZone)
+
+	// Like custom templates which predate it, the time zone may be left out.
+	details.TimeZone = ""
+	job, err = buildJob(details)
+	require.NoError(t, err)
+	require.NotNil(t, job.Periodic)
+	assert.Nil(t, job.Periodic.TimeZone)
+}
@@ -0,0 +1,25 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"github.com/google/uuid"
+)
+
+// tritonID returns the canonical, lower case form of the Triton UUID value,
+// the package or image of a template, as it's passed to tsg-cli. UUIDs are
+// accepted in either case, but not as the URNs uuid.Parse also accepts.
+func tritonID(field, value string) (string, error) {
+	if len(value) != 36 {
+		return "", &ErrInvalidTritonID{Field: field, Value: value}
+	}
+
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return "", &ErrInvalidTritonID{Field: field, Value: value}
+	}
+	return id.String(), nil
+}
@@ -0,0 +1,61 @@
+package groups_v1
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTritonID(t *testing.T) {
+	for _, value := range []string{
+		"342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+		"342045CE-6AF1-4ADF-9EF1-E5BFAF9DE28C",
+	} {
+		id, err := tritonID("image", value)
+		require.NoError(t, err, value)
+		assert.Equal(t, "342045ce-6af1-4adf-9ef1-e5bfaf9de28c", id, value)
+	}
+
+	for _, value := range []string{
+		"",
+		"g4-highcpu-1G",
+		"342045ce6af14adf9ef1e5bfaf9de28c",
+		"342045ce-6af1-4adf-9ef1-e5bfaf9de28",
+		"342045ce-6af1-4adf-9ef1-e5bfaf9de28g",
+		"{342045ce-6af1-4adf-9ef1-e5bfaf9de28c}",
+		"urn:uuid:342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+		" 342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+	} {
+		_, err := tritonID("image", value)
+		assert.Equal(t, &ErrInvalidTritonID{Field: "image", Value: value}, err, value)
+	}
+}
+
+func TestJobDetailsTritonIDs(t *testing.T) {
+	group := &ServiceGroup{GroupName: "web", Capacity: 1}
+
+	details, err := createJobDetails(&templates_v1.InstanceTemplate{
+		Package: "0106D35D-90FA-48DC-B0DD-2EBCBAA64CA0",
+		ImageID: "342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+	}, group)
+	require.NoError(t, err)
+	assert.Equal(t, "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0", details.PackageID)
+	assert.Equal(t, "342045ce-6af1-4adf-9ef1-e5bfaf9de28c", details.ImageID)
+
+	_, err = createJobDetails(&templates_v1.InstanceTemplate{
+		Package: "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+		ImageID: "ubuntu-16.04",
+	}, group)
+	assert.EqualError(t, err, `image "ubuntu-16.04" is not a valid Triton UUID`)
+	assert.Equal(t, http.StatusBadRequest, orchestratorErrorStatus(err))
+
+	_, err = createJobDetails(&templates_v1.InstanceTemplate{
+		Package: "g4-highcpu-1G",
+		ImageID: "342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+	}, group)
+	assert.EqualError(t, err, `package "g4-highcpu-1G" is not a valid Triton UUID`)
+	assert.Equal(t, http.StatusBadRequest, orchestratorErrorStatus(err))
+}
@@ -0,0 +1,39 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"regexp"
+
+	"github.com/joyent/triton-service-groups/config"
+)
+
+// tsgCliVersionRule matches the versions of tsg-cli releases, which name the
+// path their artifact is fetched from.
+var tsgCliVersionRule = regexp.MustCompile(`^[0-9]+\.[0-9]+\.[0-9]+(-[0-9A-Za-z.-]+)?$`)
+
+// validateTSGCliVersion checks that the tsg-cli version of a group names a
+// release, such as 1.2.3 or 1.2.3-rc.1. An empty version runs the configured
+// release.
+func validateTSGCliVersion(version string) error {
+	if version == "" || tsgCliVersionRule.MatchString(version) {
+		return nil
+	}
+	return &ErrUnsafeJobValue{
+		Field:  "tsg-cli version",
+		Value:  version,
+		Reason: "must be a version such as 1.2.3",
+	}
+}
+
+// tsgCliVersion returns the version of tsg-cli which runs the job of group,
+// either its own or the configured one.
+func tsgCliVersion(group *ServiceGroup) string {
+	if group.TSGCliVersion != "" {
+		return group.TSGCliVersion
+	}
+	return config.GetTSGCliVersion()
+}
@@ -0,0 +1,48 @@
+package groups_v1
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/templates"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateTSGCliVersion(t *testing.T) {
+	for _, version := range []string{"", "0.1.0", "1.12.3", "1.2.3-rc.1"} {
+		assert.NoError(t, validateTSGCliVersion(version), version)
+	}
+
+	for _, version := range []string{"v0.1.0", "0.1", "latest", "0.1.0/../../evil", "0.1.0 "} {
+		err := validateTSGCliVersion(version)
+		require.Error(t, err, version)
+		assert.Equal(t, http.StatusUnprocessableEntity, orchestratorErrorStatus(err))
+	}
+}
+
+func TestTSGCliVersionOverride(t *testing.T) {
+	defer viper.Reset()
+	viper.Set(config.KeyTSGCliVersion, "0.1.0")
+
+	tmpl := &templates_v1.InstanceTemplate{
+		Package: "0106d35d-90fa-48dc-b0dd-2ebcbaa64ca0",
+		ImageID: "342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+	}
+
+	details, err := createJobDetails(tmpl, &ServiceGroup{GroupName: "web"})
+	require.NoError(t, err)
+	assert.Equal(t, "0.1.0", details.TSGCliVersion)
+
+	details, err = createJobDetails(tmpl, &ServiceGroup{GroupName: "web", TSGCliVersion: "0.2.0-rc.1"})
+	require.NoError(t, err)
+	assert.Equal(t, "0.2.0-rc.1", details.TSGCliVersion)
+
+	details.setArtifact(config.Artifact{Destination: "local/", Mode: "any", Unpack: true})
+	assert.Equal(t, "https://github.com/joyent/tsg-cli/releases/download/v0.2.0-rc.1/tsg-cli_0.2.0-rc.1_linux_amd64.tar.gz", details.TSGCliSource)
+
+	_, err = createJobDetails(tmpl, &ServiceGroup{GroupName: "web", TSGCliVersion: "latest"})
+	assert.EqualError(t, err, `tsg-cli version "latest" can't be used in a job: must be a version such as 1.2.3`)
+}
@@ -0,0 +1,215 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package groups_v1
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/joyent/triton-service-groups/names"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/templates"
+)
+
+// validationGroupID is the ID of the group a template is validated for,
+// which never exists.
+const validationGroupID = "00000000-0000-0000-0000-000000000000"
+
+// redacted replaces the account's private key in validation messages.
+const redacted = "[REDACTED]"
+
+// TemplateValidation is the outcome of validating the job a template would
+// render to. Valid is false whenever Errors has any.
+type TemplateValidation struct {
+	Valid    bool     `json:"valid"`
+	Errors   []string `json:"errors,omitempty"`
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// validateFunc validates a job with Nomad, without registering it.
+type validateFunc func(job *nomad.Job) (*nomad.JobValidateResponse, error)
+
+// validationGroup returns the group a template is validated for when no
+// group of the account uses it yet.
+func validationGroup(t *templates_v1.InstanceTemplate) *ServiceGroup {
+	return &ServiceGroup{
+		ID:         validationGroupID,
+		GroupName:  "validate",
+		TemplateID: t.ID,
+		Capacity:   1,
+	}
+}
+
+// ValidateTemplate renders the job group would run from template t and has
+// Nomad validate it, without registering anything. Problems with the
+// template, including a job which doesn't parse, are returned in the
+// validation, while an error is only returned if the job couldn't be
+// checked. The account's private key never appears in either.
+func ValidateTemplate(ctx context.Context, t *templates_v1.InstanceTemplate, group *ServiceGroup) (*TemplateValidation, error) {
+	if err := validateTemplateFields(t); err != nil {
+		return invalidTemplate(err.Error()), nil
+	}
+
+	details, err := prepareJobDetails(ctx, t, group)
+	if err != nil {
+		redact := newRedactor(details.TritonKeyMaterial)
+		if orchestratorErrorStatus(err) < http.StatusInternalServerError {
+			return invalidTemplate(redact(err.Error())), nil
+		}
+		return nil, errors.New(redact(err.Error()))
+	}
+
+	client, ok := handlers.GetNomadClient(ctx)
+	if !ok {
+		return nil, handlers.ErrNoNomadClient
+	}
+
+	return validateJobDetails(group, details, func(job *nomad.Job) (*nomad.JobValidateResponse, error) {
+		var resp *nomad.JobValidateResponse
+		err := retryNomad(ctx, "validate", func() error {
+			var err error
+			resp, _, err = client.Jobs().Validate(job, nomadScopeOf(ctx).writeOptions())
+			return err
+		})
+		return resp, err
+	})
+}
+
+// validateTemplateFields checks the fields of t which would be rejected when
+// saving it.
+func validateTemplateFields(t *templates_v1.InstanceTemplate) error {
+	if err := templates_v1.Validate(t); err != nil {
+		return err
+	}
+	if err := names.Validate("template", t.TemplateName); err != nil {
+		return err
+	}
+	return templates_v1.CheckRequiredTags(t)
+}
+
+// validateJobDetails builds the job for details and validates it with
+// validate, redacting the account's private key from every message.
+func validateJobDetails(group *ServiceGroup, details OrchestratorJob, validate validateFunc) (*TemplateValidation, error) {
+	redact := newRedactor(details.TritonKeyMaterial)
+
+	job, err := buildJob(details)
+	if err != nil {
+		if _, ok := err.(*ErrJobRender); ok || orchestratorErrorStatus(err) < http.StatusInternalServerError {
+			return invalidTemplate(redact(err.Error())), nil
+		}
+		return nil, errors.New(redact(err.Error()))
+	}
+	limitReconciles(group, job)
+
+	resp, err := validate(job)
+	if err != nil {
+		return nil, &ErrNomad{Op: ErrNomadValidate, Err: errors.New(redact(err.Error()))}
+	}
+
+	validation := &TemplateValidation{}
+	for _, msg := range resp.ValidationErrors {
+		validation.Errors = append(validation.Errors, redact(msg))
+	}
+	if len(validation.Errors) == 0 && resp.Error != "" {
+		validation.Errors = []string{redact(resp.Error)}
+	}
+	for _, msg := range splitWarnings(resp.Warnings) {
+		validation.Warnings = append(validation.Warnings, redact(msg))
+	}
+	validation.Valid = len(validation.Errors) == 0
+
+	return validation, nil
+}
+
+func invalidTemplate(msg string) *TemplateValidation {
+	return &TemplateValidation{Errors: []string{msg}}
+}
+
+// splitWarnings splits the warnings Nomad returns as a single multierror
+// message, "2 warnings:\n\n* first\n* second", into each warning.
+func splitWarnings(warnings string) []string {
+	warnings = strings.TrimSpace(warnings)
+	if warnings == "" {
+		return nil
+	}
+
+	var split []string
+	for _, line := range strings.Split(warnings, "\n") {
+		if strings.HasPrefix(line, "* ") {
+			split = append(split, strings.TrimSpace(line[2:]))
+		}
+	}
+	if len(split) == 0 {
+		return []string{warnings}
+	}
+	return split
+}
+
+// newRedactor returns a function replacing the private key material, in
+// every form a job template can render it in, with a placeholder. Each line
+// of the key is redacted too, since HCL quotes the text its errors are at.
+func newRedactor(material string) func(string) string {
+	if material == "" {
+		return func(s string) string { return s }
+	}
+
+	var pairs []string
+	for _, form := range []string{
+		material,
+		base64Encode(material),
+		escapeNewlines(material),
+		hclString(material),
+	} {
+		pairs = append(pairs, form, redacted)
+	}
+	for _, line := range strings.Split(material, "\n") {
+		line = strings.TrimSpace(line)
+		if line != "" && !strings.HasPrefix(line, "-----") {
+			pairs = append(pairs, line, redacted)
+		}
+	}
+
+	return strings.NewReplacer(pairs...).Replace
+}
+
+// ValidateTemplateJob validates the template in the request body against
+// Nomad, as the job of a group using it, without saving the template.
+func ValidateTemplateJob(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var template templates_v1.InstanceTemplate
+	if err := json.Unmarshal(body, &template); err != nil {
+		http.Error(w, "error in unmarshal request body", http.StatusUnprocessableEntity)
+		return
+	}
+
+	templates_v1.WarnDeprecated(ctx, &template)
+
+	validation, err := ValidateTemplate(ctx, &template, validationGroup(&template))
+	if err != nil {
+		http.Error(w, err.Error(), orchestratorErrorStatus(err))
+		return
+	}
+
+	bytes, err := json.Marshal(validation)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, bytes, http.StatusOK)
+}
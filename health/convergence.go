@@ -0,0 +1,230 @@
+package health
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+
+	metrics "github.com/armon/go-metrics"
+)
+
+const (
+	// DefaultConvergenceTarget is how quickly a group must converge by default
+	// for its reconcile to meet the SLO.
+	DefaultConvergenceTarget = 5 * time.Minute
+	// DefaultConvergenceTimeout is how long a group may take to converge by
+	// default before it's given up on and counted as a breach.
+	DefaultConvergenceTimeout = 30 * time.Minute
+	// DefaultConvergenceWindow is how far back convergences are considered by
+	// default.
+	DefaultConvergenceWindow = 24 * time.Hour
+)
+
+// convergenceBuckets are the upper bounds of the convergence histogram.
+var convergenceBuckets = []time.Duration{
+	30 * time.Second,
+	time.Minute,
+	2 * time.Minute,
+	5 * time.Minute,
+	10 * time.Minute,
+	30 * time.Minute,
+}
+
+// Convergences tracks how long the reconciles performed by this process take
+// to converge.
+var Convergences = NewConvergenceTracker(DefaultConvergenceTarget, DefaultConvergenceTimeout, DefaultConvergenceWindow)
+
+type convergence struct {
+	at       time.Time
+	duration time.Duration
+	timedOut bool
+}
+
+// ConvergenceTracker measures the time from a group's job being submitted to
+// the group being observed running its desired capacity.
+type ConvergenceTracker struct {
+	mu           sync.Mutex
+	target       time.Duration
+	timeout      time.Duration
+	window       time.Duration
+	pending      map[string]time.Time
+	convergences []convergence
+	now          func() time.Time
+}
+
+// NewConvergenceTracker returns a tracker which measures compliance against
+// target over window, counting groups which haven't converged within timeout
+// as breaches.
+func NewConvergenceTracker(target, timeout, window time.Duration) *ConvergenceTracker {
+	return &ConvergenceTracker{
+		target:  target,
+		timeout: timeout,
+		window:  window,
+		pending: map[string]time.Time{},
+		now:     time.Now,
+	}
+}
+
+// Configure changes the target, timeout and window of the tracker.
+func (t *ConvergenceTracker) Configure(target, timeout, window time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.target = target
+	t.timeout = timeout
+	t.window = window
+	t.prune()
+}
+
+// Submitted starts timing the convergence of a group. Submitting a group which
+// hasn't converged yet restarts its clock, since its desired state changed.
+func (t *ConvergenceTracker) Submitted(groupID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pending[groupID] = t.now()
+}
+
+// Observed records whether a group was seen to be running its desired
+// capacity, completing its convergence if it was.
+func (t *ConvergenceTracker) Observed(groupID string, converged bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	start, ok := t.pending[groupID]
+	if !ok || !converged {
+		return
+	}
+
+	delete(t.pending, groupID)
+	t.record(convergence{at: t.now(), duration: t.now().Sub(start)})
+}
+
+// Forget stops timing a group, such as when it's deleted.
+func (t *ConvergenceTracker) Forget(groupID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.pending, groupID)
+}
+
+// Pending returns the groups which are still converging, after expiring any
+// which have exceeded the timeout.
+func (t *ConvergenceTracker) Pending() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.expire()
+
+	ids := make([]string, 0, len(t.pending))
+	for id := range t.pending {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// ConvergenceBucket is a cumulative histogram bucket of convergence times. A
+// zero UpperBound is the bucket of every convergence, timeouts included.
+type ConvergenceBucket struct {
+	UpperBound time.Duration
+	Count      int
+}
+
+// ConvergenceReport summarizes the convergences within the window.
+type ConvergenceReport struct {
+	Target   time.Duration
+	Samples  int
+	Breaches int
+	TimedOut int
+	// Compliance is the ratio of convergences which met the target, or 1 when
+	// there are no samples.
+	Compliance float64
+	Histogram  []ConvergenceBucket
+}
+
+// Report returns the SLO compliance and histogram of convergence times within
+// the window.
+func (t *ConvergenceTracker) Report() *ConvergenceReport {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.expire()
+	t.prune()
+
+	report := &ConvergenceReport{
+		Target:     t.target,
+		Samples:    len(t.convergences),
+		Compliance: 1,
+		Histogram:  make([]ConvergenceBucket, len(convergenceBuckets)+1),
+	}
+	for i, bound := range convergenceBuckets {
+		report.Histogram[i].UpperBound = bound
+	}
+
+	for _, c := range t.convergences {
+		if c.timedOut {
+			report.TimedOut++
+		}
+		if c.timedOut || c.duration > t.target {
+			report.Breaches++
+		}
+
+		for i, bound := range convergenceBuckets {
+			if !c.timedOut && c.duration <= bound {
+				report.Histogram[i].Count++
+			}
+		}
+		report.Histogram[len(convergenceBuckets)].Count++
+	}
+
+	if report.Samples > 0 {
+		met := float64(report.Samples-report.Breaches) / float64(report.Samples)
+		report.Compliance = math.Round(met*10000) / 10000
+	}
+
+	return report
+}
+
+// expire records every pending group which has exceeded the timeout as a
+// breach. The caller must hold mu.
+func (t *ConvergenceTracker) expire() {
+	if t.timeout <= 0 {
+		return
+	}
+
+	now := t.now()
+	for id, start := range t.pending {
+		if now.Sub(start) >= t.timeout {
+			delete(t.pending, id)
+			t.record(convergence{at: now, duration: now.Sub(start), timedOut: true})
+		}
+	}
+}
+
+// record appends a convergence. The caller must hold mu.
+func (t *ConvergenceTracker) record(c convergence) {
+	metrics.AddSample([]string{"reconcile", "convergence_seconds"}, float32(c.duration.Seconds()))
+	if c.timedOut {
+		metrics.IncrCounter([]string{"reconcile", "convergence_timeouts"}, 1)
+	}
+
+	t.convergences = append(t.convergences, c)
+	if len(t.convergences) > maxOutcomes {
+		t.convergences = t.convergences[len(t.convergences)-maxOutcomes:]
+	}
+	t.prune()
+}
+
+// prune drops every convergence older than the window. The caller must hold
+// mu.
+func (t *ConvergenceTracker) prune() {
+	cutoff := t.now().Add(-t.window)
+
+	i := 0
+	for i < len(t.convergences) && t.convergences[i].at.Before(cutoff) {
+		i++
+	}
+	t.convergences = t.convergences[i:]
+}
@@ -0,0 +1,111 @@
+package health
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvergenceCompliance(t *testing.T) {
+	now := time.Now()
+
+	tracker := NewConvergenceTracker(5*time.Minute, 30*time.Minute, 24*time.Hour)
+	tracker.now = func() time.Time { return now }
+
+	report := tracker.Report()
+	assert.Equal(t, 0, report.Samples)
+	assert.Equal(t, 1.0, report.Compliance)
+
+	// each group is submitted at the same moment and observed converged after
+	// its own duration
+	durations := []time.Duration{
+		20 * time.Second,
+		90 * time.Second,
+		4 * time.Minute,
+		5 * time.Minute,
+		7 * time.Minute,
+	}
+	for i := range durations {
+		tracker.Submitted(fmt.Sprintf("group-%d", i))
+	}
+	start := now
+	for i, d := range durations {
+		now = start.Add(d)
+		tracker.Observed(fmt.Sprintf("group-%d", i), false)
+		tracker.Observed(fmt.Sprintf("group-%d", i), true)
+	}
+
+	report = tracker.Report()
+	assert.Equal(t, 5*time.Minute, report.Target)
+	assert.Equal(t, 5, report.Samples)
+	assert.Equal(t, 1, report.Breaches)
+	assert.Equal(t, 0, report.TimedOut)
+	assert.Equal(t, 0.8, report.Compliance)
+	assert.Equal(t, []ConvergenceBucket{
+		{30 * time.Second, 1},
+		{time.Minute, 1},
+		{2 * time.Minute, 2},
+		{5 * time.Minute, 4},
+		{10 * time.Minute, 5},
+		{30 * time.Minute, 5},
+		{0, 5},
+	}, report.Histogram)
+	assert.Empty(t, tracker.Pending())
+}
+
+func TestConvergenceTimeout(t *testing.T) {
+	now := time.Now()
+
+	tracker := NewConvergenceTracker(5*time.Minute, 30*time.Minute, 24*time.Hour)
+	tracker.now = func() time.Time { return now }
+
+	tracker.Submitted("fast")
+	tracker.Submitted("stuck")
+
+	now = now.Add(time.Minute)
+	tracker.Observed("fast", true)
+	assert.Equal(t, []string{"stuck"}, tracker.Pending())
+
+	// a group which never converges is a breach once it times out
+	now = now.Add(30 * time.Minute)
+	assert.Empty(t, tracker.Pending())
+
+	report := tracker.Report()
+	assert.Equal(t, 2, report.Samples)
+	assert.Equal(t, 1, report.Breaches)
+	assert.Equal(t, 1, report.TimedOut)
+	assert.Equal(t, 0.5, report.Compliance)
+	assert.Equal(t, 1, report.Histogram[len(report.Histogram)-2].Count)
+	assert.Equal(t, 2, report.Histogram[len(report.Histogram)-1].Count)
+
+	// observing a group after it timed out doesn't count it twice
+	tracker.Observed("stuck", true)
+	assert.Equal(t, 2, tracker.Report().Samples)
+
+	// convergences age out of the window
+	now = now.Add(25 * time.Hour)
+	assert.Equal(t, 0, tracker.Report().Samples)
+}
+
+func TestConvergenceResubmit(t *testing.T) {
+	now := time.Now()
+
+	tracker := NewConvergenceTracker(5*time.Minute, 30*time.Minute, 24*time.Hour)
+	tracker.now = func() time.Time { return now }
+
+	tracker.Submitted("group")
+	now = now.Add(4 * time.Minute)
+	tracker.Submitted("group")
+	now = now.Add(2 * time.Minute)
+	tracker.Observed("group", true)
+
+	report := tracker.Report()
+	assert.Equal(t, 1, report.Samples)
+	assert.Equal(t, 0, report.Breaches, "the clock restarts when a group is resubmitted")
+
+	tracker.Submitted("deleted")
+	tracker.Forget("deleted")
+	assert.Empty(t, tracker.Pending())
+}
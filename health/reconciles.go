@@ -0,0 +1,91 @@
+package health
+
+import (
+	"sync"
+	"time"
+)
+
+// DefaultWindow is how far back reconcile outcomes are considered by default.
+const DefaultWindow = 5 * time.Minute
+
+// maxOutcomes bounds the memory used by a tracker regardless of its window.
+const maxOutcomes = 10000
+
+// Reconciles tracks the outcome of every orchestrator reconcile performed by
+// this process.
+var Reconciles = NewTracker(DefaultWindow)
+
+type outcome struct {
+	at     time.Time
+	failed bool
+}
+
+// Tracker records the outcome of operations over a sliding time window.
+type Tracker struct {
+	mu       sync.Mutex
+	window   time.Duration
+	outcomes []outcome
+	now      func() time.Time
+}
+
+// NewTracker returns a new tracker which considers outcomes within window.
+func NewTracker(window time.Duration) *Tracker {
+	return &Tracker{
+		window: window,
+		now:    time.Now,
+	}
+}
+
+// SetWindow changes how far back outcomes are considered.
+func (t *Tracker) SetWindow(window time.Duration) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.window = window
+	t.prune()
+}
+
+// Record records the outcome of a single operation, where a non-nil err is a
+// failure.
+func (t *Tracker) Record(err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.outcomes = append(t.outcomes, outcome{at: t.now(), failed: err != nil})
+	if len(t.outcomes) > maxOutcomes {
+		t.outcomes = t.outcomes[len(t.outcomes)-maxOutcomes:]
+	}
+	t.prune()
+}
+
+// FailureRatio returns the ratio of failed operations within the window along
+// with the number of operations it was calculated from.
+func (t *Tracker) FailureRatio() (float64, int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.prune()
+	if len(t.outcomes) == 0 {
+		return 0, 0
+	}
+
+	var failed int
+	for _, o := range t.outcomes {
+		if o.failed {
+			failed++
+		}
+	}
+
+	return float64(failed) / float64(len(t.outcomes)), len(t.outcomes)
+}
+
+// prune drops every outcome older than the window. The caller must hold mu.
+func (t *Tracker) prune() {
+	cutoff := t.now().Add(-t.window)
+
+	i := 0
+	for i < len(t.outcomes) && t.outcomes[i].at.Before(cutoff) {
+		i++
+	}
+	t.outcomes = t.outcomes[i:]
+}
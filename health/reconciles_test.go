@@ -0,0 +1,37 @@
+package health
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrackerFailureRatio(t *testing.T) {
+	now := time.Now()
+
+	tracker := NewTracker(time.Minute)
+	tracker.now = func() time.Time { return now }
+
+	ratio, samples := tracker.FailureRatio()
+	assert.Equal(t, 0.0, ratio)
+	assert.Equal(t, 0, samples)
+
+	tracker.Record(errors.New("nomad down"))
+	tracker.Record(nil)
+	tracker.Record(errors.New("nomad down"))
+	tracker.Record(nil)
+
+	ratio, samples = tracker.FailureRatio()
+	assert.Equal(t, 0.5, ratio)
+	assert.Equal(t, 4, samples)
+
+	// outcomes age out of the window
+	now = now.Add(2 * time.Minute)
+	tracker.Record(nil)
+
+	ratio, samples = tracker.FailureRatio()
+	assert.Equal(t, 0.0, ratio)
+	assert.Equal(t, 1, samples)
+}
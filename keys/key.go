@@ -23,7 +23,10 @@ type Key struct {
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	AccountID   string
-	Archived    bool
+	// RetiredAt is when the key was replaced as its account's active key,
+	// zero while it's still active.
+	RetiredAt time.Time
+	Archived  bool
 
 	store *Store
 }
@@ -48,13 +51,13 @@ VALUES ($1, $2, $3, $4, $5, NOW(), NOW());
 
 	pool := k.store.pool
 
-	tx, err := pool.Begin()
+	tx, err := pool.BeginEx(ctx, nil)
 	if err != nil {
 		return errors.Wrap(err, "failed to begin transaction")
 	}
 	defer tx.Rollback() // nolint: errcheck
 
-	_, err = pool.ExecEx(ctx, query, nil,
+	_, err = tx.ExecEx(ctx, query, nil,
 		k.Name,
 		k.Fingerprint,
 		k.Material,
@@ -65,7 +68,7 @@ VALUES ($1, $2, $3, $4, $5, NOW(), NOW());
 		return errors.Wrap(err, "failed to insert key")
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := tx.CommitEx(ctx); err != nil {
 		return errors.Wrap(err, "failed to commit transaction")
 	}
 
@@ -88,32 +91,38 @@ func (k *Key) Save(ctx context.Context) error {
 	}
 
 	query := `
-UPDATE tsg_keys SET (name, fingerprint, material, archived, updated_at) = ($2, $3, $4, $5, $6)
+UPDATE tsg_keys SET (name, fingerprint, material, archived, retired_at, updated_at) = ($2, $3, $4, $5, $6, $7)
 WHERE id = $1;
 `
 	updatedAt := time.Now()
 
 	pool := k.store.pool
 
-	tx, err := pool.Begin()
+	tx, err := pool.BeginEx(ctx, nil)
 	if err != nil {
 		return errors.Wrap(err, "failed to begin transaction")
 	}
 	defer tx.Rollback() // nolint: errcheck
 
-	_, err = pool.ExecEx(ctx, query, nil,
+	var retiredAt *time.Time
+	if !k.RetiredAt.IsZero() {
+		retiredAt = &k.RetiredAt
+	}
+
+	_, err = tx.ExecEx(ctx, query, nil,
 		k.ID,
 		k.Name,
 		k.Fingerprint,
 		k.Material,
 		k.Archived,
+		retiredAt,
 		updatedAt,
 	)
 	if err != nil {
 		return errors.Wrap(err, "failed to update key")
 	}
 
-	if err := tx.Commit(); err != nil {
+	if err := tx.CommitEx(ctx); err != nil {
 		return errors.Wrap(err, "failed to commit transaction")
 	}
 
@@ -2,6 +2,7 @@ package keys
 
 import (
 	"context"
+	"time"
 
 	"github.com/jackc/pgx"
 	"github.com/jackc/pgx/pgtype"
@@ -28,10 +29,11 @@ func (s *Store) FindByID(ctx context.Context, keyID string) (*Key, error) {
 		material    string
 		createdAt   pgtype.Timestamp
 		updatedAt   pgtype.Timestamp
+		retiredAt   pgtype.Timestamptz
 	)
 
 	query := `
-SELECT id, name, fingerprint, material, created_at, updated_at
+SELECT id, name, fingerprint, material, created_at, updated_at, retired_at
 FROM tsg_keys
 WHERE id = $1 AND archived = false;
 `
@@ -42,6 +44,7 @@ WHERE id = $1 AND archived = false;
 		&material,
 		&createdAt,
 		&updatedAt,
+		&retiredAt,
 	)
 	if err != nil {
 		return nil, err
@@ -54,6 +57,7 @@ WHERE id = $1 AND archived = false;
 	key.Material = material
 	key.CreatedAt = createdAt.Time
 	key.UpdatedAt = updatedAt.Time
+	key.RetiredAt = retiredAt.Time
 
 	return key, nil
 }
@@ -67,10 +71,11 @@ func (s *Store) FindByName(ctx context.Context, keyName string, accountID string
 		material    string
 		createdAt   pgtype.Timestamp
 		updatedAt   pgtype.Timestamp
+		retiredAt   pgtype.Timestamptz
 	)
 
 	query := `
-SELECT id, name, fingerprint, material, created_at, updated_at
+SELECT id, name, fingerprint, material, created_at, updated_at, retired_at
 FROM tsg_keys
 WHERE name = $1 AND account_id = $2 AND archived = false;
 `
@@ -81,6 +86,7 @@ WHERE name = $1 AND account_id = $2 AND archived = false;
 		&material,
 		&createdAt,
 		&updatedAt,
+		&retiredAt,
 	)
 	if err != nil {
 		return nil, err
@@ -94,6 +100,64 @@ WHERE name = $1 AND account_id = $2 AND archived = false;
 	key.Material = material
 	key.CreatedAt = createdAt.Time
 	key.UpdatedAt = updatedAt.Time
+	key.RetiredAt = retiredAt.Time
 
 	return key, nil
 }
+
+// FindRetired finds the keys of every account which were retired before the
+// given time and haven't been archived since.
+func (s *Store) FindRetired(ctx context.Context, before time.Time) ([]*Key, error) {
+	query := `
+SELECT id, name, fingerprint, material, account_id, created_at, updated_at, retired_at
+FROM tsg_keys
+WHERE retired_at < $1 AND archived = false;
+`
+	rows, err := s.pool.QueryEx(ctx, query, nil, before)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var found []*Key
+	for rows.Next() {
+		var (
+			id          pgtype.UUID
+			name        string
+			fingerprint string
+			material    string
+			accountID   pgtype.UUID
+			createdAt   pgtype.Timestamp
+			updatedAt   pgtype.Timestamp
+			retiredAt   pgtype.Timestamptz
+		)
+
+		err := rows.Scan(
+			&id,
+			&name,
+			&fingerprint,
+			&material,
+			&accountID,
+			&createdAt,
+			&updatedAt,
+			&retiredAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		key := New(s)
+		key.ID = convert.BytesToUUID(id.Bytes)
+		key.AccountID = convert.BytesToUUID(accountID.Bytes)
+		key.Name = name
+		key.Fingerprint = fingerprint
+		key.Material = material
+		key.CreatedAt = createdAt.Time
+		key.UpdatedAt = updatedAt.Time
+		key.RetiredAt = retiredAt.Time
+
+		found = append(found, key)
+	}
+
+	return found, rows.Err()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/joyent/triton-service-groups/keys"
 	"github.com/joyent/triton-service-groups/testutils"
@@ -95,3 +96,48 @@ func TestFindByName(t *testing.T) {
 	assert.Equal(t, key.CreatedAt, found.CreatedAt)
 	assert.Equal(t, key.UpdatedAt, found.UpdatedAt)
 }
+
+func TestFindRetired(t *testing.T) {
+	if os.Getenv("TSG_TEST") == "" {
+		t.Skip("Acceptance tests skipped unless env 'TSG_TEST=1' set")
+		return
+	}
+
+	db, err := testutils.NewTestDB()
+	if err != nil {
+		t.Error(err)
+	}
+	db.Clear(t)
+	defer db.Clear(t)
+
+	ctx := context.Background()
+	store := keys.NewStore(db.Conn)
+	now := time.Now()
+
+	insert := func(name string, retiredAt time.Time, archived bool) *keys.Key {
+		key := keys.New(store)
+		key.Name = name
+		key.Fingerprint = "12:23:34:45:56:67:78:89:90:0A:AB:BC:CD:DE:AD:01"
+		key.Material = "this is key material"
+		key.AccountID = "d255305d-aa60-49bc-acc2-3713cf0beb1c"
+		require.NoError(t, key.Insert(ctx))
+
+		key.RetiredAt = retiredAt
+		key.Archived = archived
+		require.NoError(t, key.Save(ctx))
+		return key
+	}
+
+	expired := insert("TSG_Management_1", now.Add(-48*time.Hour), false)
+	insert("TSG_Management_2", now.Add(-time.Hour), false)
+	insert("TSG_Management_3", now.Add(-48*time.Hour), true)
+	insert("TSG_Management_4", time.Time{}, false)
+
+	retired, err := store.FindRetired(ctx, now.Add(-24*time.Hour))
+	require.NoError(t, err)
+	require.Len(t, retired, 1)
+
+	assert.Equal(t, expired.ID, retired[0].ID)
+	assert.Equal(t, expired.Name, retired[0].Name)
+	assert.WithinDuration(t, expired.RetiredAt, retired[0].RetiredAt, time.Millisecond)
+}
@@ -0,0 +1,206 @@
+// Package messages localizes the fixed error messages returned by the API.
+// Every message has a stable code, returned in the X-TSG-Error-Code header,
+// and is translated into the language preferred by the client's
+// Accept-Language header, falling back to English.
+package messages
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Code identifies a message. Codes never change between releases or
+// languages, so clients can act on them without matching message text.
+type Code string
+
+const (
+	FailedAuth      Code = "FailedAuthentication"
+	FailedSession   Code = "FailedSession"
+	FailedAccount   Code = "FailedAccount"
+	FailedKey       Code = "FailedKey"
+	GroupModified   Code = "GroupModified"
+	MultiDatacenter Code = "MultiDatacenter"
+	NoSigningKey    Code = "NoSigningKey"
+	FeatureDisabled Code = "FeatureDisabled"
+	RateLimited     Code = "RateLimited"
+)
+
+// Fallback is the language of messages for clients which accept none of the
+// supported languages.
+const Fallback = "en"
+
+// HeaderCode is the response header holding the code of an error.
+const HeaderCode = "X-TSG-Error-Code"
+
+// catalogs holds the format of every message in each supported language.
+var catalogs = map[string]map[Code]string{
+	"en": {
+		FailedAuth:      "failed request authentication",
+		FailedSession:   "failed session authentication",
+		FailedAccount:   "failed account authentication",
+		FailedKey:       "failed key authentication",
+		GroupModified:   "group has been modified",
+		MultiDatacenter: "not supported for groups with per-datacenter capacity",
+		NoSigningKey:    "bundle signing key is not configured",
+		FeatureDisabled: "feature %q is not enabled for this account",
+		RateLimited:     "too many requests, retry later",
+	},
+	"es": {
+		FailedAuth:      "falló la autenticación de la solicitud",
+		FailedSession:   "falló la autenticación de la sesión",
+		FailedAccount:   "falló la autenticación de la cuenta",
+		FailedKey:       "falló la autenticación de la clave",
+		GroupModified:   "el grupo ha sido modificado",
+		MultiDatacenter: "no es compatible con grupos con capacidad por centro de datos",
+		NoSigningKey:    "la clave de firma de paquetes no está configurada",
+		FeatureDisabled: "la función %q no está habilitada para esta cuenta",
+		RateLimited:     "demasiadas solicitudes, inténtelo más tarde",
+	},
+	"de": {
+		FailedAuth:      "Authentifizierung der Anfrage fehlgeschlagen",
+		FailedSession:   "Authentifizierung der Sitzung fehlgeschlagen",
+		FailedAccount:   "Authentifizierung des Kontos fehlgeschlagen",
+		FailedKey:       "Authentifizierung des Schlüssels fehlgeschlagen",
+		GroupModified:   "Gruppe wurde geändert",
+		MultiDatacenter: "nicht unterstützt für Gruppen mit Kapazität pro Rechenzentrum",
+		NoSigningKey:    "Signaturschlüssel für Bundles ist nicht konfiguriert",
+		FeatureDisabled: "Funktion %q ist für dieses Konto nicht aktiviert",
+		RateLimited:     "zu viele Anfragen, später erneut versuchen",
+	},
+}
+
+// Languages returns every supported language.
+func Languages() []string {
+	langs := make([]string, 0, len(catalogs))
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	return langs
+}
+
+// Error is an error with a stable code whose message can be localized.
+type Error struct {
+	Code Code
+	Args []interface{}
+}
+
+// New returns an error with the message of code, formatted with args.
+func New(code Code, args ...interface{}) *Error {
+	return &Error{Code: code, Args: args}
+}
+
+// Error returns the message in English.
+func (e *Error) Error() string {
+	return e.Localize(Fallback)
+}
+
+// Localize returns the message in the given language, or in English if the
+// language isn't supported.
+func (e *Error) Localize(lang string) string {
+	format, ok := catalogs[lang][e.Code]
+	if !ok {
+		format = catalogs[Fallback][e.Code]
+	}
+	return fmt.Sprintf(format, e.Args...)
+}
+
+// Negotiate returns the supported language most preferred by an
+// Accept-Language header. Regional variants match their language, so "es-MX"
+// selects Spanish.
+func Negotiate(acceptLanguage string) string {
+	best, bestQ := Fallback, 0.0
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, q := parseLanguage(part)
+		if q <= bestQ {
+			continue
+		}
+		if tag == "*" {
+			best, bestQ = Fallback, q
+			continue
+		}
+		if i := strings.IndexByte(tag, '-'); i >= 0 {
+			tag = tag[:i]
+		}
+		if _, ok := catalogs[tag]; ok {
+			best, bestQ = tag, q
+		}
+	}
+	return best
+}
+
+// parseLanguage reads a single language range of an Accept-Language header,
+// along with its quality. Malformed qualities are treated as zero.
+func parseLanguage(part string) (string, float64) {
+	fields := strings.Split(part, ";")
+	tag := strings.ToLower(strings.TrimSpace(fields[0]))
+	if tag == "" {
+		return "", 0
+	}
+
+	q := 1.0
+	for _, param := range fields[1:] {
+		param = strings.TrimSpace(param)
+		if !strings.HasPrefix(param, "q=") {
+			continue
+		}
+		v, err := strconv.ParseFloat(param[2:], 64)
+		if err != nil {
+			return tag, 0
+		}
+		q = v
+	}
+	return tag, q
+}
+
+// Write replies to the request with err as a plain text error, like
+// http.Error. Errors with a code are localized to the request's preferred
+// language and their code is returned alongside.
+func Write(w http.ResponseWriter, r *http.Request, err error, status int) {
+	e, ok := err.(*Error)
+	if !ok {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	lang := Negotiate(r.Header.Get("Accept-Language"))
+	w.Header().Set(HeaderCode, string(e.Code))
+	w.Header().Set("Content-Language", lang)
+	w.Header().Add("Vary", "Accept-Language")
+	http.Error(w, e.Localize(lang), status)
+}
+
+// jsonError is the body written by WriteJSON.
+type jsonError struct {
+	Code    Code   `json:"code,omitempty"`
+	Message string `json:"message"`
+}
+
+// WriteJSON replies to the request like Write, but with err as a JSON object
+// holding its code and message, for clients which can't read a plain text
+// body such as those rejected by authentication.
+func WriteJSON(w http.ResponseWriter, r *http.Request, err error, status int) {
+	body := jsonError{Message: err.Error()}
+	if e, ok := err.(*Error); ok {
+		lang := Negotiate(r.Header.Get("Accept-Language"))
+		w.Header().Set(HeaderCode, string(e.Code))
+		w.Header().Set("Content-Language", lang)
+		w.Header().Add("Vary", "Accept-Language")
+		body = jsonError{Code: e.Code, Message: e.Localize(lang)}
+	}
+
+	bytes, err := json.Marshal(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	w.Write(bytes) // nolint: errcheck
+}
@@ -0,0 +1,92 @@
+package messages
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalogsComplete(t *testing.T) {
+	for _, lang := range Languages() {
+		for code := range catalogs[Fallback] {
+			assert.NotEmpty(t, catalogs[lang][code], "%s is missing %s", lang, code)
+		}
+		assert.Len(t, catalogs[lang], len(catalogs[Fallback]), "%s has unknown codes", lang)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                          "en",
+		"es":                        "es",
+		"es-MX":                     "es",
+		"DE-de":                     "de",
+		"fr":                        "en",
+		"fr, de;q=0.5":              "de",
+		"en;q=0.3, es;q=0.9, de":    "de",
+		"de;q=0, es;q=0.1":          "es",
+		"*;q=0.8, es;q=0.5":         "en",
+		"es;q=bogus, de;q=0.2":      "de",
+		" ja-JP , es-419 ;q=0.7 , ": "es",
+	} {
+		assert.Equal(t, want, Negotiate(header), "Accept-Language: %q", header)
+	}
+}
+
+func TestLocalize(t *testing.T) {
+	err := New(FeatureDisabled, "adopt")
+	assert.EqualError(t, err, `feature "adopt" is not enabled for this account`)
+	assert.Equal(t, `la función "adopt" no está habilitada para esta cuenta`, err.Localize("es"))
+	assert.Equal(t, `Funktion "adopt" ist für dieses Konto nicht aktiviert`, err.Localize("de"))
+	assert.Equal(t, `feature "adopt" is not enabled for this account`, err.Localize("fr"))
+}
+
+func TestWrite(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPut, "/v1/tsg/groups/722d25ed-f32a-4944-9861-8990e204850e", nil)
+	r.Header.Set("Accept-Language", "de-AT, en;q=0.5")
+
+	rec := httptest.NewRecorder()
+	Write(rec, r, New(GroupModified), http.StatusPreconditionFailed)
+
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	assert.Equal(t, "Gruppe wurde geändert\n", rec.Body.String())
+	assert.Equal(t, "GroupModified", rec.Header().Get(HeaderCode))
+	assert.Equal(t, "de", rec.Header().Get("Content-Language"))
+
+	// An unsupported language falls back to English with the same code.
+	r.Header.Set("Accept-Language", "fr")
+	rec = httptest.NewRecorder()
+	Write(rec, r, New(GroupModified), http.StatusPreconditionFailed)
+	assert.Equal(t, "group has been modified\n", rec.Body.String())
+	assert.Equal(t, "GroupModified", rec.Header().Get(HeaderCode))
+
+	// Errors without a code are written as they are.
+	rec = httptest.NewRecorder()
+	Write(rec, r, errors.New("connection refused"), http.StatusInternalServerError)
+	assert.Equal(t, "connection refused\n", rec.Body.String())
+	assert.Empty(t, rec.Header().Get(HeaderCode))
+	assert.Empty(t, rec.Header().Get("Content-Language"))
+}
+
+func TestWriteJSON(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/tsg/groups", nil)
+	r.Header.Set("Accept-Language", "es")
+
+	rec := httptest.NewRecorder()
+	WriteJSON(rec, r, New(FailedAuth), http.StatusUnauthorized)
+
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	assert.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"code": "FailedAuthentication", "message": "`+New(FailedAuth).Localize("es")+`"}`, rec.Body.String())
+	assert.Equal(t, "FailedAuthentication", rec.Header().Get(HeaderCode))
+	assert.Equal(t, "es", rec.Header().Get("Content-Language"))
+
+	// Errors without a code only have a message.
+	rec = httptest.NewRecorder()
+	WriteJSON(rec, r, errors.New("connection refused"), http.StatusInternalServerError)
+	assert.JSONEq(t, `{"message": "connection refused"}`, rec.Body.String())
+	assert.Empty(t, rec.Header().Get(HeaderCode))
+}
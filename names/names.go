@@ -0,0 +1,123 @@
+// Package names validates the names given to templates and groups. Names flow
+// into Nomad job IDs, instance tags and URLs so only a conservative charset is
+// allowed.
+package names
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/joyent/triton-service-groups/config"
+)
+
+const (
+	DefaultMinLength = 1
+	DefaultMaxLength = 182
+	// DefaultPattern only allows characters which are safe within a Nomad job
+	// ID and a URL path segment.
+	DefaultPattern = `^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`
+)
+
+var defaultPattern = regexp.MustCompile(DefaultPattern)
+
+// configured holds the configured pattern compiled, which is only compiled
+// again once the config is reloaded with a different pattern.
+var configured struct {
+	sync.Mutex
+	pattern string
+	re      *regexp.Regexp
+}
+
+// Error describes the rule a name violated.
+type Error struct {
+	Kind string
+	Name string
+	Rule string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s name %q is invalid: %s", e.Kind, e.Name, e.Rule)
+}
+
+// Rules are the constraints applied to a name.
+type Rules struct {
+	MinLength int
+	MaxLength int
+	Pattern   *regexp.Regexp
+}
+
+// DefaultRules returns the rules configured for this process, falling back to
+// the defaults for anything which isn't set.
+func DefaultRules() (*Rules, error) {
+	rules := &Rules{
+		MinLength: DefaultMinLength,
+		MaxLength: DefaultMaxLength,
+	}
+
+	if min := config.GetNameMinLength(); min > 0 {
+		rules.MinLength = min
+	}
+	if max := config.GetNameMaxLength(); max > 0 {
+		rules.MaxLength = max
+	}
+
+	re, err := configuredPattern()
+	if err != nil {
+		return nil, err
+	}
+	rules.Pattern = re
+
+	return rules, nil
+}
+
+// configuredPattern returns the configured pattern compiled, or the default
+// pattern if none is configured. An invalid pattern is rejected on startup by
+// config.NewDefault.
+func configuredPattern() (*regexp.Regexp, error) {
+	pattern := config.GetNamePattern()
+	if pattern == "" {
+		return defaultPattern, nil
+	}
+
+	configured.Lock()
+	defer configured.Unlock()
+
+	if configured.re != nil && configured.pattern == pattern {
+		return configured.re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name pattern %q: %v", pattern, err)
+	}
+	configured.pattern, configured.re = pattern, re
+
+	return re, nil
+}
+
+// Validate checks name against the configured rules, where kind describes what
+// is being named (e.g. "group"). A violation is returned as an *Error.
+func Validate(kind, name string) error {
+	rules, err := DefaultRules()
+	if err != nil {
+		return err
+	}
+	return rules.Validate(kind, name)
+}
+
+// Validate checks name against the rules. A violation is returned as an
+// *Error.
+func (r *Rules) Validate(kind, name string) error {
+	switch {
+	case name == "":
+		return &Error{kind, name, "cannot be empty"}
+	case len(name) < r.MinLength:
+		return &Error{kind, name, fmt.Sprintf("must be at least %d characters", r.MinLength)}
+	case len(name) > r.MaxLength:
+		return &Error{kind, name, fmt.Sprintf("cannot be more than %d characters", r.MaxLength)}
+	case !r.Pattern.MatchString(name):
+		return &Error{kind, name, fmt.Sprintf("must match %s", r.Pattern)}
+	}
+	return nil
+}
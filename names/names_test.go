@@ -0,0 +1,69 @@
+package names_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/names"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name  string
+		valid bool
+		rule  string
+	}{
+		{"jolly-jelly", true, ""},
+		{"web_tier.v2", true, ""},
+		{"", false, "cannot be empty"},
+		{strings.Repeat("a", names.DefaultMaxLength+1), false, "cannot be more than 182 characters"},
+		{"jolly jelly", false, "must match"},
+		{"jolly/jelly", false, "must match"},
+		{"-leading-dash", false, "must match"},
+		{"jöllý", false, "must match"},
+	}
+
+	for _, tt := range tests {
+		err := names.Validate("group", tt.name)
+		if tt.valid {
+			assert.NoError(t, err, tt.name)
+			continue
+		}
+
+		require.Error(t, err, tt.name)
+		nameErr, ok := err.(*names.Error)
+		require.True(t, ok, "expected *names.Error")
+		assert.Equal(t, "group", nameErr.Kind)
+		assert.Contains(t, nameErr.Rule, tt.rule)
+	}
+}
+
+func TestValidateConfigured(t *testing.T) {
+	viper.Set(config.KeyNamesMinLength, 3)
+	viper.Set(config.KeyNamesMaxLength, 8)
+	viper.Set(config.KeyNamesPattern, `^[a-z]+$`)
+	defer viper.Reset()
+
+	assert.NoError(t, names.Validate("template", "web"))
+	assert.Error(t, names.Validate("template", "ab"))
+	assert.Error(t, names.Validate("template", "abcdefghi"))
+	assert.Error(t, names.Validate("template", "Web"))
+}
+
+func TestValidateFollowsReload(t *testing.T) {
+	defer viper.Reset()
+
+	viper.Set(config.KeyNamesPattern, `^[a-z]+$`)
+	assert.NoError(t, names.Validate("template", "web"))
+	assert.Error(t, names.Validate("template", "web01"))
+
+	viper.Set(config.KeyNamesPattern, `^[a-z0-9]+$`)
+	assert.NoError(t, names.Validate("template", "web01"))
+
+	viper.Set(config.KeyNamesPattern, "")
+	assert.NoError(t, names.Validate("template", "Web-01"))
+}
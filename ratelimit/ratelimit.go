@@ -0,0 +1,208 @@
+// Package ratelimit limits how often clients may call the API, so that a
+// misbehaving client can't flood Nomad with job registrations. Each client
+// has an in-memory token bucket.
+package ratelimit
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/joyent/triton-service-groups/messages"
+	"github.com/joyent/triton-service-groups/server/handlers"
+)
+
+// ErrRateLimited is returned to clients which exceed their rate limit.
+var ErrRateLimited = messages.New(messages.RateLimited)
+
+// Limiter holds a token bucket for each key, such as an account ID. Buckets
+// refill at a fixed rate up to their burst. Buckets which have refilled
+// completely are no different from new ones, so they're dropped every cleanup
+// interval to bound the memory used by clients which have gone idle.
+type Limiter struct {
+	mu          sync.Mutex
+	rate        float64
+	burst       float64
+	cleanup     time.Duration
+	lastCleanup time.Time
+	buckets     map[string]*bucket
+	now         func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	at     time.Time
+}
+
+// New returns a limiter which allows rate requests per second for each key,
+// in bursts of up to burst. A rate of zero disables limiting.
+func New(rate float64, burst int, cleanup time.Duration) *Limiter {
+	return &Limiter{
+		rate:    rate,
+		burst:   float64(burst),
+		cleanup: cleanup,
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+// Allow takes a token from the bucket of key. If the bucket is empty it
+// returns false along with how long until a token is available.
+func (l *Limiter) Allow(key string) (bool, time.Duration) {
+	if l.rate <= 0 {
+		return true, 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	b := l.fill(key)
+	if b.tokens < 1 {
+		return false, l.wait(b)
+	}
+	b.tokens--
+	return true, 0
+}
+
+// Limited returns how long until the bucket of key has a token, or zero if it
+// has one already, without taking it.
+func (l *Limiter) Limited(key string) time.Duration {
+	if l.rate <= 0 {
+		return 0
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b := l.fill(key); b.tokens < 1 {
+		return l.wait(b)
+	}
+	return 0
+}
+
+// Take takes a token from the bucket of key, if it has one.
+func (l *Limiter) Take(key string) {
+	if l.rate <= 0 {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if b := l.fill(key); b.tokens >= 1 {
+		b.tokens--
+	}
+}
+
+// fill returns the bucket of key, refilled for the time since it was last
+// used, and drops idle buckets once the cleanup interval has passed. Must be
+// called with the lock held.
+func (l *Limiter) fill(key string) *bucket {
+	now := l.now()
+	if now.Sub(l.lastCleanup) >= l.cleanup {
+		l.lastCleanup = now
+		for k, b := range l.buckets {
+			if b.tokens+now.Sub(b.at).Seconds()*l.rate >= l.burst {
+				delete(l.buckets, k)
+			}
+		}
+	}
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: l.burst, at: now}
+		l.buckets[key] = b
+		return b
+	}
+
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.at).Seconds()*l.rate)
+	b.at = now
+	return b
+}
+
+func (l *Limiter) wait(b *bucket) time.Duration {
+	return time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+}
+
+// AccountHandler limits the requests served by h by the account of their
+// authenticated session.
+func AccountHandler(l *Limiter, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		session := handlers.GetAuthSession(r.Context())
+		if ok, wait := l.Allow(session.AccountID); !ok {
+			writeLimited(w, r, wait)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// UnauthenticatedHandler limits requests which h, the authenticating handler,
+// rejects as unauthorized by the IP address of their client. Clients share
+// this stricter limit until they authenticate, so they can't hammer Triton
+// with bad credentials, and can't use up the limit of the account they claim
+// to be without its key.
+func UnauthenticatedHandler(l *Limiter, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r)
+		if wait := l.Limited(ip); wait > 0 {
+			writeLimited(w, r, wait)
+			return
+		}
+
+		sw := &statusWriter{ResponseWriter: w}
+		h.ServeHTTP(sw, r)
+		if sw.status == http.StatusUnauthorized {
+			l.Take(ip)
+		}
+	})
+}
+
+// writeLimited replies with a 429 telling the client, in whole seconds, when
+// to retry.
+func writeLimited(w http.ResponseWriter, r *http.Request, wait time.Duration) {
+	seconds := int(math.Ceil(wait.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	messages.Write(w, r, ErrRateLimited, http.StatusTooManyRequests)
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// statusWriter records the status of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(statusCode int) {
+	if w.status == 0 {
+		w.status = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush passes flushes through so that streamed responses aren't buffered.
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
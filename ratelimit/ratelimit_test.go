@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	now := time.Now()
+
+	l := New(2, 3, time.Minute)
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		ok, _ := l.Allow("a")
+		assert.True(t, ok, "burst %d", i)
+	}
+	ok, wait := l.Allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, 500*time.Millisecond, wait)
+
+	// other keys have their own bucket
+	ok, _ = l.Allow("b")
+	assert.True(t, ok)
+
+	now = now.Add(500 * time.Millisecond)
+	ok, _ = l.Allow("a")
+	assert.True(t, ok)
+	ok, _ = l.Allow("a")
+	assert.False(t, ok)
+}
+
+func TestLimiterCleanup(t *testing.T) {
+	now := time.Now()
+
+	l := New(1, 2, time.Minute)
+	l.now = func() time.Time { return now }
+
+	l.Allow("idle")
+	l.Allow("busy")
+	assert.Len(t, l.buckets, 2)
+
+	now = now.Add(time.Minute)
+	l.Take("busy")
+	l.Take("busy")
+	assert.Len(t, l.buckets, 1)
+	assert.Contains(t, l.buckets, "busy")
+}
+
+func TestLimiterDisabled(t *testing.T) {
+	l := New(0, 1, time.Minute)
+	for i := 0; i < 10; i++ {
+		ok, _ := l.Allow("a")
+		assert.True(t, ok)
+	}
+	assert.Empty(t, l.buckets)
+}
+
+func TestAccountHandler(t *testing.T) {
+	h := AccountHandler(New(1, 1, time.Minute), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	serve := func(accountID string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPut, "/v1/tsg/groups/abc/scale", nil)
+		req = req.WithContext(handlers.WithAuthSession(req.Context(), &auth.Session{AccountID: accountID}))
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, serve("acct-1").Code)
+	assert.Equal(t, http.StatusOK, serve("acct-2").Code)
+
+	w := serve("acct-1")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+}
+
+func TestUnauthenticatedHandler(t *testing.T) {
+	authorized := false
+	h := UnauthenticatedHandler(New(1, 2, time.Minute), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !authorized {
+			w.WriteHeader(http.StatusUnauthorized)
+		}
+	}))
+
+	serve := func(remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/v1/tsg/groups", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusUnauthorized, serve("10.0.0.1:1000"))
+	assert.Equal(t, http.StatusUnauthorized, serve("10.0.0.1:1001"))
+	assert.Equal(t, http.StatusTooManyRequests, serve("10.0.0.1:1002"))
+	assert.Equal(t, http.StatusUnauthorized, serve("10.0.0.2:1000"))
+
+	// authenticated requests don't use up the limit of their address
+	authorized = true
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, http.StatusOK, serve("10.0.0.2:1000"))
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/jackc/pgx"
 	"github.com/joyent/triton-service-groups/accounts"
 	"github.com/joyent/triton-service-groups/keys"
+	"github.com/joyent/triton-service-groups/messages"
 	"github.com/joyent/triton-service-groups/server/handlers/auth"
 	"github.com/rs/zerolog/log"
 )
@@ -39,6 +40,12 @@ func GetAuthSession(ctx context.Context) *auth.Session {
 	return &auth.Session{}
 }
 
+// WithAuthSession returns a copy of ctx which carries the given session. This
+// is used to act on behalf of an account outside of an HTTP request.
+func WithAuthSession(ctx context.Context, session *auth.Session) context.Context {
+	return context.WithValue(ctx, authKey, session)
+}
+
 // ServeHTTP serves HTTP requests through the authentication process scoped to
 // whatever pre-defined data we need accessible through the authHandler
 // struct. This method finalizes by calling ServeHTTP on the handler that this
@@ -47,12 +54,16 @@ func GetAuthSession(ctx context.Context) *auth.Session {
 func (a authHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	ctx := req.Context()
 
+	// NOTE: A session fails to be created when the request isn't signed
+	// with a key of a Triton account, which is verified against Triton
+	// itself below.
 	session, err := auth.NewSession(req, a.config)
 	if err != nil {
 		log.Debug().
 			Str("module", "auth").
-			Err(err)
-		http.Error(w, ErrFailedSession.Error(), http.StatusInternalServerError)
+			Err(err).
+			Msg("auth: failed to create session")
+		messages.WriteJSON(w, req, ErrFailedSession, http.StatusUnauthorized)
 		return
 	}
 
@@ -63,8 +74,9 @@ func (a authHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		if err != nil {
 			log.Debug().
 				Str("module", "auth").
-				Err(err)
-			http.Error(w, ErrFailedAccount.Error(), http.StatusUnauthorized)
+				Err(err).
+				Msg("auth: failed to ensure account")
+			messages.WriteJSON(w, req, ErrFailedAccount, http.StatusUnauthorized)
 			return
 		}
 
@@ -73,17 +85,18 @@ func (a authHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		if err := session.EnsureKeys(ctx, acct, keyStore); err != nil {
 			log.Debug().
 				Str("module", "auth").
-				Err(err)
-			http.Error(w, ErrFailedKey.Error(), http.StatusUnauthorized)
+				Err(err).
+				Msg("auth: failed to ensure keys")
+			messages.WriteJSON(w, req, ErrFailedKey, http.StatusUnauthorized)
 			return
 		}
 	}
 
 	if !session.IsAuthenticated() {
-		http.Error(w, ErrFailedAuth.Error(), http.StatusUnauthorized)
+		messages.WriteJSON(w, req, ErrFailedAuth, http.StatusUnauthorized)
 		return
 	}
 
-	ctx = context.WithValue(ctx, authKey, session)
+	ctx = WithAuthSession(ctx, session)
 	a.handler.ServeHTTP(w, req.WithContext(ctx))
 }
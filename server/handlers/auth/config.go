@@ -10,6 +10,20 @@ type Config struct {
 	// available within the HTTP request Session object.
 	TritonURL string
 
+	// Nomad namespace in which the jobs of groups are managed. This is made
+	// available within the HTTP request Session object.
+	NomadNamespace string
+
+	// Nomad region in which the jobs of groups are managed, empty for the
+	// region of the Nomad agent. This is made available within the HTTP
+	// request Session object.
+	NomadRegion string
+
+	// Nomad datacenters in which the jobs of groups may be placed, empty for
+	// the one named after Datacenter. This is made available within the HTTP
+	// request Session object.
+	NomadDatacenters []string
+
 	// URL of Triton's CloudAPI in which to authenticate incoming API
 	// requests. This is only used by internal auth processes. It can be set to
 	// the same CloudAPI used by TritonURL as well.
@@ -11,6 +11,8 @@ var (
 	ErrNameLen       = errors.New("parsed name is too short")
 	ErrNameFormat    = errors.New("parsed name is not formatted properly")
 	ErrKeyConflict   = errors.New("auth: found conflicting key state")
+	ErrNoActiveKey   = errors.New("auth: account has no active key to rotate")
+	ErrRotateDevMode = errors.New("auth: keys can't be rotated in dev mode")
 
 	ErrWhitelist = errors.New("service only accessible by whitelist")
 )
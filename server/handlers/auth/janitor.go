@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx"
+	"github.com/joyent/triton-service-groups/accounts"
+	"github.com/joyent/triton-service-groups/keys"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// KeyJanitor periodically removes the keys retired by a rotation from Triton,
+// once they've been retired for longer than the grace period, and archives
+// them in the database.
+type KeyJanitor struct {
+	interval    time.Duration
+	gracePeriod time.Duration
+	authURL     string
+	pool        *pgx.ConnPool
+
+	now             func() time.Time
+	findRetired     func(ctx context.Context, before time.Time) ([]*keys.Key, error)
+	deleteTritonKey func(ctx context.Context, key *keys.Key) error
+	saveKey         func(ctx context.Context, key *keys.Key) error
+}
+
+// NewKeyJanitor constructs a key janitor which removes keys from the Triton
+// CloudAPI at authURL.
+func NewKeyJanitor(interval, gracePeriod time.Duration, authURL string, pool *pgx.ConnPool) *KeyJanitor {
+	j := &KeyJanitor{
+		interval:    interval,
+		gracePeriod: gracePeriod,
+		authURL:     authURL,
+		pool:        pool,
+		now:         time.Now,
+		saveKey:     func(ctx context.Context, key *keys.Key) error { return key.Save(ctx) },
+	}
+	j.findRetired = func(ctx context.Context, before time.Time) ([]*keys.Key, error) {
+		return keys.NewStore(j.pool).FindRetired(ctx, before)
+	}
+	j.deleteTritonKey = j.deleteRetiredKey
+	return j
+}
+
+// Run sweeps retired keys once per interval until ctx is done.
+func (j *KeyJanitor) Run(ctx context.Context) {
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := j.Sweep(ctx); err != nil {
+				log.Error().Err(err).Msg("auth: failed to sweep retired keys")
+			}
+		}
+	}
+}
+
+// Sweep removes every key whose grace period has passed. A key which can't be
+// removed from Triton is left retired and tried again on the next sweep.
+func (j *KeyJanitor) Sweep(ctx context.Context) error {
+	retired, err := j.findRetired(ctx, j.now().Add(-j.gracePeriod))
+	if err != nil {
+		return errors.Wrap(err, "failed to find retired keys")
+	}
+
+	for _, key := range retired {
+		if err := j.deleteTritonKey(ctx, key); err != nil {
+			log.Error().Err(err).
+				Str("account_id", key.AccountID).
+				Str("fingerprint", key.Fingerprint).
+				Msg("auth: failed to remove retired key from triton")
+			continue
+		}
+
+		key.Archived = true
+		if err := j.saveKey(ctx, key); err != nil {
+			log.Error().Err(err).
+				Str("account_id", key.AccountID).
+				Str("fingerprint", key.Fingerprint).
+				Msg("auth: failed to archive retired key")
+			continue
+		}
+
+		log.Info().
+			Str("account_id", key.AccountID).
+			Str("fingerprint", key.Fingerprint).
+			Msg("auth: removed retired key")
+	}
+
+	return nil
+}
+
+// deleteRetiredKey removes key from Triton, signed with the active key of its
+// account since there's no user request to act on behalf of.
+func (j *KeyJanitor) deleteRetiredKey(ctx context.Context, key *keys.Key) error {
+	acct, err := accounts.NewStore(j.pool).FindByID(ctx, key.AccountID)
+	if err != nil {
+		return errors.Wrap(err, "failed to find account of key")
+	}
+
+	cred, err := acct.GetTritonCredential(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get account credential")
+	}
+
+	a, err := keyClient(j.authURL, cred.AccountName, cred.KeyID, cred.KeyMaterial)
+	if err != nil {
+		return err
+	}
+
+	return deleteKey(ctx, a, key.Name)
+}
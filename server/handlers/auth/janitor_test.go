@@ -0,0 +1,51 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-service-groups/keys"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKeyJanitorSweep(t *testing.T) {
+	now := time.Date(2018, 6, 2, 12, 0, 0, 0, time.UTC)
+
+	j := NewKeyJanitor(time.Minute, 24*time.Hour, "https://us-east-1.api.joyent.com", nil)
+	j.now = func() time.Time { return now }
+
+	var before time.Time
+	j.findRetired = func(ctx context.Context, b time.Time) ([]*keys.Key, error) {
+		before = b
+		return []*keys.Key{
+			{ID: "gone", Name: "TSG_Management_us-east-1", AccountID: "account-id", RetiredAt: now.Add(-25 * time.Hour)},
+			{ID: "stuck", Name: "TSG_Management_us-east-1_1527854400", AccountID: "account-id", RetiredAt: now.Add(-30 * time.Hour)},
+		}, nil
+	}
+
+	var deleted []string
+	j.deleteTritonKey = func(ctx context.Context, key *keys.Key) error {
+		deleted = append(deleted, key.Name)
+		if key.ID == "stuck" {
+			return errors.New("ServiceUnavailable")
+		}
+		return nil
+	}
+
+	archived := map[string]bool{}
+	j.saveKey = func(ctx context.Context, key *keys.Key) error {
+		archived[key.ID] = key.Archived
+		return nil
+	}
+
+	require.NoError(t, j.Sweep(context.Background()))
+
+	assert.Equal(t, now.Add(-24*time.Hour), before)
+	assert.Equal(t, []string{"TSG_Management_us-east-1", "TSG_Management_us-east-1_1527854400"}, deleted)
+	// A key which couldn't be removed from Triton stays retired, to be
+	// swept again.
+	assert.Equal(t, map[string]bool{"gone": true}, archived)
+}
@@ -61,7 +61,7 @@ func (k *KeyCheck) OnTriton(ctx context.Context) error {
 	a.SetHeader(k.ParsedRequest.Header())
 
 	input := &account.GetKeyInput{
-		KeyName: k.keyName,
+		KeyName: k.tritonKeyName(),
 	}
 	key, err := a.Keys().Get(ctx, input)
 	if err != nil {
@@ -94,9 +94,22 @@ func (k *KeyCheck) InDatabase(ctx context.Context) error {
 	}
 }
 
+// tritonKeyName is the name in Triton of the account's key, which differs from
+// the name of a new key once the key has been rotated.
+func (k *KeyCheck) tritonKeyName() string {
+	if k.Key != nil && k.Key.Name != "" {
+		return k.Key.Name
+	}
+	return k.keyName
+}
+
 // AddKey adds an account key into Triton, converting the passed in KeyPair into
 // a Triton-Go account.Key for use by external consumers.
 func (k *KeyCheck) AddTritonKey(ctx context.Context, keypair *KeyPair) error {
+	return k.addTritonKey(ctx, k.tritonKeyName(), keypair)
+}
+
+func (k *KeyCheck) addTritonKey(ctx context.Context, name string, keypair *KeyPair) error {
 	a, err := k.newClient()
 	if err != nil {
 		return errors.Wrap(err, "failed to create new key client")
@@ -105,7 +118,7 @@ func (k *KeyCheck) AddTritonKey(ctx context.Context, keypair *KeyPair) error {
 	a.SetHeader(k.ParsedRequest.Header())
 
 	createInput := &account.CreateKeyInput{
-		Name: k.keyName,
+		Name: name,
 		Key:  keypair.PublicKeyBase64(),
 	}
 	key, err := a.Keys().Create(ctx, createInput)
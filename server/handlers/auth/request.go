@@ -43,7 +43,7 @@ func validateName(name string) error {
 
 	matched, err := regexp.MatchString(matchName, name)
 	if err != nil {
-		log.Error().Err(err)
+		log.Error().Err(err).Msg("auth: unable to match account name")
 		return ErrNameFormat
 	}
 	if !matched {
@@ -73,7 +73,7 @@ func ParseRequest(req *http.Request) (*ParsedRequest, error) {
 
 	accountName, userName, fingerprint, err := parseKeyId(matches[1])
 	if err != nil {
-		log.Error().Err(err)
+		log.Error().Err(err).Msg("auth: unable to parse request key ID")
 		return nil, err
 	}
 
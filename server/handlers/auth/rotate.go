@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	triton "github.com/joyent/triton-go"
+	"github.com/joyent/triton-go/account"
+	"github.com/joyent/triton-go/authentication"
+	terrors "github.com/joyent/triton-go/errors"
+	"github.com/joyent/triton-service-groups/accounts"
+	"github.com/joyent/triton-service-groups/keys"
+	"github.com/pkg/errors"
+	"github.com/rs/zerolog/log"
+)
+
+// verifyAttempts and verifyInterval bound how long a new key is given to
+// start working, since CloudAPI may not accept a key the moment it's added.
+var (
+	verifyAttempts = 5
+	verifyInterval = time.Second
+)
+
+// KeyRotation is the outcome of rotating an account's key. Previous stays on
+// Triton, retired, until its grace period has passed.
+type KeyRotation struct {
+	Previous *keys.Key
+	Current  *keys.Key
+}
+
+// keyRotator replaces the active key of an account. Each step is a field so
+// that tests can stand in for Triton and the database.
+type keyRotator struct {
+	check *KeyCheck
+
+	now             func() time.Time
+	newKeyPair      func() (*KeyPair, error)
+	addTritonKey    func(ctx context.Context, name string, keypair *KeyPair) error
+	verifyKey       func(ctx context.Context, keypair *KeyPair) error
+	deleteTritonKey func(ctx context.Context, name string) error
+	insertKey       func(ctx context.Context, key *keys.Key) error
+	saveKey         func(ctx context.Context, key *keys.Key) error
+	saveAccount     func(ctx context.Context, acct *accounts.Account) error
+}
+
+func newKeyRotator(check *KeyCheck) *keyRotator {
+	return &keyRotator{
+		check:      check,
+		now:        time.Now,
+		newKeyPair: func() (*KeyPair, error) { return NewKeyPair(1024) },
+		addTritonKey: func(ctx context.Context, name string, keypair *KeyPair) error {
+			return check.addTritonKey(ctx, name, keypair)
+		},
+		verifyKey: func(ctx context.Context, keypair *KeyPair) error {
+			return verifyKey(ctx, check.config.TritonURL, check.AccountName, keypair)
+		},
+		deleteTritonKey: check.deleteTritonKey,
+		insertKey:       func(ctx context.Context, key *keys.Key) error { return key.Insert(ctx) },
+		saveKey:         func(ctx context.Context, key *keys.Key) error { return key.Save(ctx) },
+		saveAccount:     func(ctx context.Context, acct *accounts.Account) error { return acct.Save(ctx) },
+	}
+}
+
+// RotateKey replaces the active key of the session's account with a new one.
+// The new key is added to Triton and must authenticate with CloudAPI before
+// the account is switched over to it, so a key which doesn't work leaves the
+// account as it was. Jobs rendered from then on use the new key.
+func (s *Session) RotateKey(ctx context.Context, acct *accounts.Account, store *keys.Store) (*KeyRotation, error) {
+	if s.devMode {
+		return nil, ErrRotateDevMode
+	}
+
+	check := NewKeyCheck(s.ParsedRequest, acct, store, s.config)
+
+	if err := check.InDatabase(ctx); err != nil {
+		return nil, errors.Wrap(err, "failed to check database for key")
+	}
+
+	rotation, err := newKeyRotator(check).rotate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Fingerprint = rotation.Current.Fingerprint
+
+	return rotation, nil
+}
+
+func (r *keyRotator) rotate(ctx context.Context) (*KeyRotation, error) {
+	if !r.check.HasKey() {
+		return nil, ErrNoActiveKey
+	}
+	previous := r.check.Key
+
+	keypair, err := r.newKeyPair()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate new keypair")
+	}
+
+	now := r.now()
+	name := fmt.Sprintf("%s_%d", r.check.keyName, now.Unix())
+
+	if err := r.addTritonKey(ctx, name, keypair); err != nil {
+		return nil, errors.Wrap(err, "failed to add new key")
+	}
+
+	// Until the account is switched over, a failure removes the new key
+	// from Triton again.
+	abandon := func(err error) error {
+		if derr := r.deleteTritonKey(ctx, name); derr != nil {
+			log.Error().Err(derr).
+				Str("account_name", r.check.account.AccountName).
+				Str("key_name", name).
+				Msg("auth: failed to remove abandoned key from triton")
+		}
+		return err
+	}
+
+	if err := r.verifyKey(ctx, keypair); err != nil {
+		return nil, abandon(errors.Wrap(err, "new key failed to authenticate with triton"))
+	}
+
+	key := keys.New(r.check.store)
+	key.Name = name
+	key.Fingerprint = keypair.FingerprintMD5
+	key.Material = keypair.PrivateKeyPEM()
+	key.AccountID = r.check.account.ID
+
+	if err := r.insertKey(ctx, key); err != nil {
+		return nil, abandon(errors.Wrap(err, "failed to store new key"))
+	}
+
+	r.check.account.KeyID = key.ID
+	if err := r.saveAccount(ctx, r.check.account); err != nil {
+		r.check.account.KeyID = previous.ID
+		return nil, abandon(errors.Wrap(err, "failed to switch account to new key"))
+	}
+	r.check.Key = key
+
+	// The account already uses the new key, so failing to retire the
+	// previous one only leaves it in place for longer.
+	previous.RetiredAt = now
+	if err := r.saveKey(ctx, previous); err != nil {
+		log.Error().Err(err).
+			Str("account_name", r.check.account.AccountName).
+			Str("fingerprint", previous.Fingerprint).
+			Msg("auth: failed to retire previous key")
+	}
+
+	log.Info().
+		Str("account_name", r.check.account.AccountName).
+		Str("fingerprint", key.Fingerprint).
+		Str("previous_fingerprint", previous.Fingerprint).
+		Msg("auth: rotated account key")
+
+	return &KeyRotation{
+		Previous: previous,
+		Current:  key,
+	}, nil
+}
+
+// deleteTritonKey removes a key from Triton as the requesting user. A key
+// which is already gone isn't an error.
+func (k *KeyCheck) deleteTritonKey(ctx context.Context, name string) error {
+	a, err := k.newClient()
+	if err != nil {
+		return errors.Wrap(err, "failed to create account key client")
+	}
+
+	a.SetHeader(k.ParsedRequest.Header())
+
+	return deleteKey(ctx, a, name)
+}
+
+func deleteKey(ctx context.Context, a *account.AccountClient, name string) error {
+	err := a.Keys().Delete(ctx, &account.DeleteKeyInput{
+		KeyName: name,
+	})
+	if err != nil && !terrors.IsSpecificStatusCode(err, http.StatusNotFound) {
+		return errors.Wrap(err, "failed to delete triton key")
+	}
+	return nil
+}
+
+// verifyKey checks that keypair authenticates as the account with CloudAPI.
+func verifyKey(ctx context.Context, tritonURL, accountName string, keypair *KeyPair) error {
+	a, err := keyClient(tritonURL, accountName, keypair.FingerprintMD5, keypair.PrivateKeyPEM())
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		_, err = a.Get(ctx, &account.GetInput{})
+		if err == nil || attempt == verifyAttempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(verifyInterval):
+		}
+	}
+}
+
+// keyClient returns a client which acts as the account signed with the given
+// key, rather than on behalf of the requesting user.
+func keyClient(tritonURL, accountName, fingerprint, material string) (*account.AccountClient, error) {
+	signer, err := authentication.NewPrivateKeySigner(authentication.PrivateKeySignerInput{
+		KeyID:              fingerprint,
+		PrivateKeyMaterial: []byte(material),
+		AccountName:        accountName,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create key signer")
+	}
+
+	return account.NewClient(&triton.ClientConfig{
+		TritonURL:   tritonURL,
+		AccountName: accountName,
+		Signers:     []authentication.Signer{signer},
+	})
+}
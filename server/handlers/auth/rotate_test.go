@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-service-groups/accounts"
+	"github.com/joyent/triton-service-groups/keys"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRotation struct {
+	calls     []string
+	verifyErr error
+	saveErr   error
+	saved     *accounts.Account
+}
+
+func newTestRotator(f *fakeRotation, now time.Time) *keyRotator {
+	check := &KeyCheck{
+		ParsedRequest: &ParsedRequest{AccountName: "testaccount"},
+		Key: &keys.Key{
+			ID:          "old-key",
+			Name:        "TSG_Management_us-east-1",
+			Fingerprint: "old-fingerprint",
+			AccountID:   "account-id",
+		},
+		account: &accounts.Account{
+			ID:          "account-id",
+			AccountName: "testaccount",
+			KeyID:       "old-key",
+		},
+		keyName: "TSG_Management_us-east-1",
+	}
+
+	r := newKeyRotator(check)
+	r.now = func() time.Time { return now }
+	r.newKeyPair = func() (*KeyPair, error) {
+		return &KeyPair{FingerprintMD5: "new-fingerprint", privateKeyPEM: "new-material"}, nil
+	}
+	r.addTritonKey = func(ctx context.Context, name string, keypair *KeyPair) error {
+		f.calls = append(f.calls, "add "+name)
+		return nil
+	}
+	r.verifyKey = func(ctx context.Context, keypair *KeyPair) error {
+		f.calls = append(f.calls, "verify "+keypair.FingerprintMD5)
+		return f.verifyErr
+	}
+	r.deleteTritonKey = func(ctx context.Context, name string) error {
+		f.calls = append(f.calls, "delete "+name)
+		return nil
+	}
+	r.insertKey = func(ctx context.Context, key *keys.Key) error {
+		f.calls = append(f.calls, "insert "+key.Name)
+		key.ID = "new-key"
+		return nil
+	}
+	r.saveAccount = func(ctx context.Context, acct *accounts.Account) error {
+		f.calls = append(f.calls, "activate "+acct.KeyID)
+		if f.saveErr != nil {
+			return f.saveErr
+		}
+		saved := *acct
+		f.saved = &saved
+		return nil
+	}
+	r.saveKey = func(ctx context.Context, key *keys.Key) error {
+		f.calls = append(f.calls, "retire "+key.ID)
+		return nil
+	}
+	return r
+}
+
+func TestRotateKey(t *testing.T) {
+	now := time.Date(2018, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	t.Run("flips the active key", func(t *testing.T) {
+		f := &fakeRotation{}
+		r := newTestRotator(f, now)
+
+		rotation, err := r.rotate(context.Background())
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{
+			"add TSG_Management_us-east-1_1527854400",
+			"verify new-fingerprint",
+			"insert TSG_Management_us-east-1_1527854400",
+			"activate new-key",
+			"retire old-key",
+		}, f.calls)
+
+		assert.Equal(t, "new-key", f.saved.KeyID)
+		assert.Equal(t, "new-key", rotation.Current.ID)
+		assert.Equal(t, "new-fingerprint", rotation.Current.Fingerprint)
+		assert.Equal(t, "new-material", rotation.Current.Material)
+		assert.Equal(t, "account-id", rotation.Current.AccountID)
+		assert.True(t, rotation.Current.RetiredAt.IsZero())
+		assert.Equal(t, "old-key", rotation.Previous.ID)
+		assert.Equal(t, now, rotation.Previous.RetiredAt)
+		assert.False(t, rotation.Previous.Archived)
+		assert.Equal(t, rotation.Current, r.check.Key)
+	})
+
+	t.Run("verification fails", func(t *testing.T) {
+		f := &fakeRotation{verifyErr: errors.New("InvalidCredentials")}
+		r := newTestRotator(f, now)
+
+		_, err := r.rotate(context.Background())
+		assert.EqualError(t, err, "new key failed to authenticate with triton: InvalidCredentials")
+
+		assert.Equal(t, []string{
+			"add TSG_Management_us-east-1_1527854400",
+			"verify new-fingerprint",
+			"delete TSG_Management_us-east-1_1527854400",
+		}, f.calls)
+		assert.Equal(t, "old-key", r.check.account.KeyID)
+		assert.True(t, r.check.Key.RetiredAt.IsZero())
+	})
+
+	t.Run("account fails to save", func(t *testing.T) {
+		f := &fakeRotation{saveErr: errors.New("connection reset")}
+		r := newTestRotator(f, now)
+
+		_, err := r.rotate(context.Background())
+		assert.EqualError(t, err, "failed to switch account to new key: connection reset")
+
+		assert.Equal(t, "delete TSG_Management_us-east-1_1527854400", f.calls[len(f.calls)-1])
+		assert.Equal(t, "old-key", r.check.account.KeyID)
+		assert.Equal(t, "old-key", r.check.Key.ID)
+		assert.True(t, r.check.Key.RetiredAt.IsZero())
+	})
+
+	t.Run("no active key", func(t *testing.T) {
+		f := &fakeRotation{}
+		r := newTestRotator(f, now)
+		r.check.Key = nil
+
+		_, err := r.rotate(context.Background())
+		assert.Equal(t, ErrNoActiveKey, err)
+		assert.Empty(t, f.calls)
+	})
+}
@@ -20,6 +20,14 @@ type Session struct {
 	Fingerprint string
 	Datacenter  string
 	TritonURL   string
+	// NomadNamespace is the Nomad namespace the session's jobs are managed
+	// in.
+	NomadNamespace string
+	// NomadRegion is the Nomad region the session's jobs are managed in.
+	NomadRegion string
+	// NomadDatacenters are the Nomad datacenters the session's jobs may be
+	// placed in, empty for the one named after Datacenter.
+	NomadDatacenters []string
 
 	devMode bool
 	config  Config
@@ -35,11 +43,14 @@ func NewSession(req *http.Request, cfg Config) (*Session, error) {
 			Msg("auth: skipping authentication and using seeded defaults")
 
 		return &Session{
-			AccountID:   testAccountID,
-			Fingerprint: testFingerprint,
-			Datacenter:  cfg.Datacenter,
-			TritonURL:   cfg.TritonURL,
-			devMode:     true,
+			AccountID:        testAccountID,
+			Fingerprint:      testFingerprint,
+			Datacenter:       cfg.Datacenter,
+			TritonURL:        cfg.TritonURL,
+			NomadNamespace:   cfg.NomadNamespace,
+			NomadRegion:      cfg.NomadRegion,
+			NomadDatacenters: cfg.NomadDatacenters,
+			devMode:          true,
 		}, nil
 	}
 
@@ -49,10 +60,13 @@ func NewSession(req *http.Request, cfg Config) (*Session, error) {
 	}
 
 	return &Session{
-		ParsedRequest: parsedReq,
-		Datacenter:    cfg.Datacenter,
-		TritonURL:     cfg.TritonURL,
-		config:        cfg,
+		ParsedRequest:    parsedReq,
+		Datacenter:       cfg.Datacenter,
+		TritonURL:        cfg.TritonURL,
+		NomadNamespace:   cfg.NomadNamespace,
+		NomadRegion:      cfg.NomadRegion,
+		NomadDatacenters: cfg.NomadDatacenters,
+		config:           cfg,
 	}, nil
 }
 
@@ -74,19 +88,19 @@ func (s *Session) EnsureAccount(ctx context.Context, store *accounts.Store) (*ac
 
 	if err := check.OnTriton(ctx); err != nil {
 		err = errors.Wrap(err, "failed to check triton for account")
-		log.Error().Err(err)
+		log.Error().Err(err).Msg("auth: unable to ensure account")
 		return nil, err
 	}
 
 	if !check.HasTritonAccount() {
 		err := errors.New("could not authenticate account with triton")
-		log.Error().Err(err)
+		log.Error().Err(err).Msg("auth: unable to ensure account")
 		return nil, err
 	}
 
 	if err := check.SaveAccount(ctx); err != nil {
 		err := errors.Wrap(err, "failed to save account in database")
-		log.Error().Err(err)
+		log.Error().Err(err).Msg("auth: unable to ensure account")
 		return nil, err
 	}
 
@@ -105,15 +119,17 @@ func (s *Session) EnsureAccount(ctx context.Context, store *accounts.Store) (*ac
 func (s *Session) EnsureKeys(ctx context.Context, acct *accounts.Account, store *keys.Store) error {
 	check := NewKeyCheck(s.ParsedRequest, acct, store, s.config)
 
-	if err := check.OnTriton(ctx); err != nil {
-		err = errors.Wrap(err, "failed to check triton for key")
-		log.Error().Err(err)
+	// The key in the database is checked first, since a rotated key is
+	// named apart from the key first created for the account.
+	if err := check.InDatabase(ctx); err != nil {
+		err = errors.Wrap(err, "failed to check database for key")
+		log.Error().Err(err).Str("account_name", acct.AccountName).Msg("auth: unable to ensure keys")
 		return err
 	}
 
-	if err := check.InDatabase(ctx); err != nil {
-		err = errors.Wrap(err, "failed to check database for key")
-		log.Error().Err(err)
+	if err := check.OnTriton(ctx); err != nil {
+		err = errors.Wrap(err, "failed to check triton for key")
+		log.Error().Err(err).Str("account_name", acct.AccountName).Msg("auth: unable to ensure keys")
 		return err
 	}
 
@@ -132,20 +148,21 @@ func (s *Session) EnsureKeys(ctx context.Context, acct *accounts.Account, store
 
 			log.Error().
 				Str("account_name", acct.AccountName).
-				Err(ErrKeyConflict)
+				Err(ErrKeyConflict).
+				Msg("auth: unable to ensure keys")
 			return ErrKeyConflict
 		} else {
 			keypair, err := DecodeKeyPair(check.Key.Material)
 			if err != nil {
 				err = errors.Wrap(err, "failed to generate new keypair")
-				log.Error().Err(err)
+				log.Error().Err(err).Str("account_name", acct.AccountName).Msg("auth: unable to ensure keys")
 				return err
 			}
 
 			err = check.AddTritonKey(ctx, keypair)
 			if err != nil {
 				err = errors.Wrap(err, "failed to add new key")
-				log.Error().Err(err)
+				log.Error().Err(err).Str("account_name", acct.AccountName).Msg("auth: unable to ensure keys")
 				return err
 			}
 		}
@@ -155,28 +172,29 @@ func (s *Session) EnsureKeys(ctx context.Context, acct *accounts.Account, store
 			log.Error().
 				Str("account_name", acct.AccountName).
 				Str("fingerprint", check.TritonKey.Fingerprint).
-				Err(err)
+				Err(err).
+				Msg("auth: unable to ensure keys")
 			return err
 		}
 
 		keypair, err := NewKeyPair(1024)
 		if err != nil {
 			err = errors.Wrap(err, "failed to generate new keypair")
-			log.Error().Err(err)
+			log.Error().Err(err).Str("account_name", acct.AccountName).Msg("auth: unable to ensure keys")
 			return err
 		}
 
 		if !check.HasTritonKey() {
 			if err := check.AddTritonKey(ctx, keypair); err != nil {
 				err = errors.Wrap(err, "failed to add new key")
-				log.Error().Err(err)
+				log.Error().Err(err).Str("account_name", acct.AccountName).Msg("auth: unable to ensure keys")
 				return err
 			}
 		}
 
 		if err := check.InsertKey(ctx, keypair); err != nil {
 			err = errors.Wrap(err, "failed to save new key")
-			log.Error().Err(err)
+			log.Error().Err(err).Str("account_name", acct.AccountName).Msg("auth: unable to ensure keys")
 			return err
 		}
 	}
@@ -0,0 +1,36 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAuthHandlerUnsigned(t *testing.T) {
+	h := handlers.AuthHandler(nil, auth.Config{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Fatal("unsigned request was served")
+	}))
+
+	for name, header := range map[string]string{
+		"missing":   "",
+		"malformed": `Signature keyId="joyent",algorithm="rsa-sha256" abc123`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/v1/tsg", nil)
+			req.Header.Set("Date", "Mon, 14 Oct 2026 12:00:00 GMT")
+			if header != "" {
+				req.Header.Set("Authorization", header)
+			}
+
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, req)
+			assert.Equal(t, http.StatusUnauthorized, w.Code)
+			assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+			assert.JSONEq(t, `{"code": "FailedSession", "message": "failed session authentication"}`, w.Body.String())
+		})
+	}
+}
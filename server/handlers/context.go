@@ -6,6 +6,8 @@ import (
 
 	nomad "github.com/hashicorp/nomad/api"
 	"github.com/jackc/pgx"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 )
 
 type contextKey int
@@ -14,6 +16,8 @@ const (
 	dbKeyName contextKey = iota
 	authKey
 	nomadKeyName
+	datacentersKeyName
+	requestIDKeyName
 )
 
 type dbValue struct {
@@ -33,31 +37,109 @@ func GetDBPool(ctx context.Context) (*pgx.ConnPool, bool) {
 	return nil, false
 }
 
+// WithDBPool returns a copy of ctx which carries the given database pool.
+func WithDBPool(ctx context.Context, pool *pgx.ConnPool) context.Context {
+	return context.WithValue(ctx, dbKeyName, dbValue{pool})
+}
+
 // GetNomadClient pulls a configured nomad client out of the current request
 // context.
 func GetNomadClient(ctx context.Context) (*nomad.Client, bool) {
-	if nomad, ok := ctx.Value(nomadKeyName).(nomadValue); ok {
+	if nomad, ok := ctx.Value(nomadKeyName).(nomadValue); ok && nomad.client != nil {
 		return nomad.client, true
 	}
 	return nil, false
 }
 
+// WithNomadClient returns a copy of ctx which carries the given nomad client.
+func WithNomadClient(ctx context.Context, client *nomad.Client) context.Context {
+	return context.WithValue(ctx, nomadKeyName, nomadValue{client})
+}
+
+// GetRequestID pulls the ID of the current request out of its context, or
+// returns an empty string outside of a request.
+func GetRequestID(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDKeyName).(string); ok {
+		return id
+	}
+	return ""
+}
+
+// WithRequestID returns a copy of ctx which carries the given request ID.
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKeyName, id)
+}
+
+// Logger returns the logger to use within ctx, which tags every line with the
+// ID of the current request so that its steps can be correlated.
+func Logger(ctx context.Context) *zerolog.Logger {
+	logger := log.Logger
+	if id := GetRequestID(ctx); id != "" {
+		logger = logger.With().Str("request_id", id).Logger()
+	}
+	return &logger
+}
+
+// Datacenter is a remote datacenter which the jobs of multi-datacenter groups
+// are submitted to.
+type Datacenter struct {
+	TritonURL      string
+	Nomad          *nomad.Client
+	NomadNamespace string
+	NomadRegion    string
+	// NomadDatacenters are the Nomad datacenters the datacenter's jobs may
+	// be placed in, empty for the datacenter named after it.
+	NomadDatacenters []string
+}
+
+// Datacenters are the remote datacenters by name.
+type Datacenters map[string]*Datacenter
+
+// GetDatacenter pulls the named remote datacenter out of the current request
+// context.
+func GetDatacenter(ctx context.Context, name string) (*Datacenter, bool) {
+	if dcs, ok := ctx.Value(datacentersKeyName).(Datacenters); ok {
+		dc, ok := dcs[name]
+		return dc, ok
+	}
+	return nil, false
+}
+
+// KnownDatacenter returns true if name is either the datacenter of the
+// current session or one of the configured remote datacenters.
+func KnownDatacenter(ctx context.Context, name string) bool {
+	if GetAuthSession(ctx).Datacenter == name {
+		return true
+	}
+	_, ok := GetDatacenter(ctx, name)
+	return ok
+}
+
+// WithDatacenters returns a copy of ctx which carries the given remote
+// datacenters.
+func WithDatacenters(ctx context.Context, dcs Datacenters) context.Context {
+	return context.WithValue(ctx, datacentersKeyName, dcs)
+}
+
 type contextHandler struct {
-	pool    *pgx.ConnPool
-	nomad   *nomad.Client
-	handler http.Handler
+	pool        *pgx.ConnPool
+	nomad       *nomad.Client
+	datacenters Datacenters
+	handler     http.Handler
 }
 
-func ContextHandler(pool *pgx.ConnPool, nomad *nomad.Client, h http.Handler) *contextHandler {
+func ContextHandler(pool *pgx.ConnPool, nomad *nomad.Client, dcs Datacenters, h http.Handler) *contextHandler {
 	return &contextHandler{
-		pool:    pool,
-		nomad:   nomad,
-		handler: h,
+		pool:        pool,
+		nomad:       nomad,
+		datacenters: dcs,
+		handler:     h,
 	}
 }
 
 func (h *contextHandler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
-	ctx := context.WithValue(req.Context(), dbKeyName, dbValue{h.pool})
-	ctx = context.WithValue(ctx, nomadKeyName, nomadValue{h.nomad})
+	ctx := WithDBPool(req.Context(), h.pool)
+	ctx = WithNomadClient(ctx, h.nomad)
+	ctx = WithDatacenters(ctx, h.datacenters)
 	h.handler.ServeHTTP(w, req.WithContext(ctx))
 }
@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"context"
+	"testing"
+
+	nomad "github.com/hashicorp/nomad/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetNomadClient(t *testing.T) {
+	_, ok := GetNomadClient(context.Background())
+	assert.False(t, ok)
+
+	// A server which couldn't connect to Nomad has no client to hand out.
+	_, ok = GetNomadClient(WithNomadClient(context.Background(), nil))
+	assert.False(t, ok)
+
+	client := &nomad.Client{}
+	got, ok := GetNomadClient(WithNomadClient(context.Background(), client))
+	assert.True(t, ok)
+	assert.Equal(t, client, got)
+}
@@ -1,13 +1,17 @@
 package handlers
 
-import "errors"
+import (
+	"errors"
+
+	"github.com/joyent/triton-service-groups/messages"
+)
 
 var (
 	ErrNoConnPool    = errors.New("handlers can't access database pool")
 	ErrNoNomadClient = errors.New("handlers can't access nomad client")
-	ErrFailedAuth    = errors.New("failed request authentication")
-	ErrFailedSession = errors.New("failed session authentication")
-	ErrFailedAccount = errors.New("failed account authentication")
-	ErrFailedKey     = errors.New("failed key authentication")
+	ErrFailedAuth    = messages.New(messages.FailedAuth)
+	ErrFailedSession = messages.New(messages.FailedSession)
+	ErrFailedAccount = messages.New(messages.FailedAccount)
+	ErrFailedKey     = messages.New(messages.FailedKey)
 	ErrNoSession     = errors.New("failed to get authenticated session")
 )
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/joyent/triton-service-groups/health"
+	"github.com/rs/zerolog/log"
+)
+
+// ReadyConfig configures when the server reports itself as not ready.
+type ReadyConfig struct {
+	// FailureThreshold is the ratio of failed reconciles, between 0 and 1,
+	// above which the server is not ready. Zero disables the check.
+	FailureThreshold float64
+	// MinSamples is the number of reconciles required within the window before
+	// the failure ratio is considered.
+	MinSamples int
+}
+
+type probeResponse struct {
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// HealthHandler reports that the process is alive.
+func HealthHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeProbe(w, http.StatusOK, "")
+	})
+}
+
+// ReadyHandler reports whether the server should receive traffic. The server
+// is not ready if the database check fails or if too many recent reconciles
+// have failed, e.g. because Nomad is unavailable.
+func ReadyHandler(dbCheck func(context.Context) error, tracker *health.Tracker, cfg ReadyConfig) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+		defer cancel()
+
+		if err := dbCheck(ctx); err != nil {
+			log.Warn().Err(err).Msg("http: readiness database check failed")
+			writeProbe(w, http.StatusServiceUnavailable, "database unavailable")
+			return
+		}
+
+		if cfg.FailureThreshold > 0 {
+			ratio, samples := tracker.FailureRatio()
+			if samples >= cfg.MinSamples && ratio > cfg.FailureThreshold {
+				reason := fmt.Sprintf("reconcile failure ratio %.2f exceeds %.2f", ratio, cfg.FailureThreshold)
+				log.Warn().
+					Float64("failure_ratio", ratio).
+					Int("samples", samples).
+					Msg("http: " + reason)
+				writeProbe(w, http.StatusServiceUnavailable, reason)
+				return
+			}
+		}
+
+		writeProbe(w, http.StatusOK, "")
+	})
+}
+
+func writeProbe(w http.ResponseWriter, statusCode int, reason string) {
+	resp := probeResponse{Status: "ok", Reason: reason}
+	if statusCode != http.StatusOK {
+		resp.Status = "unavailable"
+	}
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(statusCode)
+	if err := json.NewEncoder(w).Encode(resp); err != nil {
+		log.Error().Err(err).Msg("http: failed to write probe response")
+	}
+}
+
+type sloBucket struct {
+	LE    string `json:"le"`
+	Count int    `json:"count"`
+}
+
+type sloResponse struct {
+	TargetSeconds float64     `json:"target_seconds"`
+	Samples       int         `json:"samples"`
+	Breaches      int         `json:"breaches"`
+	TimedOut      int         `json:"timed_out"`
+	Compliance    float64     `json:"compliance"`
+	Histogram     []sloBucket `json:"histogram"`
+}
+
+// SLOHandler reports how long recent reconciles took to converge and the
+// ratio of them which met the convergence target.
+func SLOHandler(tracker *health.ConvergenceTracker) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		report := tracker.Report()
+
+		resp := sloResponse{
+			TargetSeconds: report.Target.Seconds(),
+			Samples:       report.Samples,
+			Breaches:      report.Breaches,
+			TimedOut:      report.TimedOut,
+			Compliance:    report.Compliance,
+		}
+		for _, bucket := range report.Histogram {
+			le := "+Inf"
+			if bucket.UpperBound > 0 {
+				le = strconv.FormatFloat(bucket.UpperBound.Seconds(), 'f', -1, 64)
+			}
+			resp.Histogram = append(resp.Histogram, sloBucket{LE: le, Count: bucket.Count})
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if err := json.NewEncoder(w).Encode(resp); err != nil {
+			log.Error().Err(err).Msg("http: failed to write slo response")
+		}
+	})
+}
@@ -0,0 +1,88 @@
+package handlers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-service-groups/health"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadyHandler(t *testing.T) {
+	dbOK := func(context.Context) error { return nil }
+	cfg := handlers.ReadyConfig{
+		FailureThreshold: 0.5,
+		MinSamples:       4,
+	}
+
+	probe := func(h http.Handler) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		return w.Code
+	}
+
+	t.Run("failure ratio above threshold", func(t *testing.T) {
+		tracker := health.NewTracker(time.Minute)
+		h := handlers.ReadyHandler(dbOK, tracker, cfg)
+
+		tracker.Record(nil)
+		tracker.Record(errors.New("nomad down"))
+		tracker.Record(errors.New("nomad down"))
+		assert.Equal(t, http.StatusOK, probe(h), "too few samples")
+
+		tracker.Record(nil)
+		assert.Equal(t, http.StatusOK, probe(h), "at threshold")
+
+		tracker.Record(errors.New("nomad down"))
+		assert.Equal(t, http.StatusServiceUnavailable, probe(h))
+	})
+
+	t.Run("threshold disabled", func(t *testing.T) {
+		tracker := health.NewTracker(time.Minute)
+		for i := 0; i < 10; i++ {
+			tracker.Record(errors.New("nomad down"))
+		}
+
+		h := handlers.ReadyHandler(dbOK, tracker, handlers.ReadyConfig{})
+		assert.Equal(t, http.StatusOK, probe(h))
+	})
+
+	t.Run("database unavailable", func(t *testing.T) {
+		dbDown := func(context.Context) error { return errors.New("connection refused") }
+
+		h := handlers.ReadyHandler(dbDown, health.NewTracker(time.Minute), cfg)
+		assert.Equal(t, http.StatusServiceUnavailable, probe(h))
+	})
+}
+
+func TestSLOHandler(t *testing.T) {
+	tracker := health.NewConvergenceTracker(5*time.Minute, 30*time.Minute, time.Hour)
+	tracker.Submitted("group")
+	tracker.Observed("group", true)
+
+	w := httptest.NewRecorder()
+	handlers.SLOHandler(tracker).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/slo", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{
+		"target_seconds": 300,
+		"samples": 1,
+		"breaches": 0,
+		"timed_out": 0,
+		"compliance": 1,
+		"histogram": [
+			{"le": "30", "count": 1},
+			{"le": "60", "count": 1},
+			{"le": "120", "count": 1},
+			{"le": "300", "count": 1},
+			{"le": "600", "count": 1},
+			{"le": "1800", "count": 1},
+			{"le": "+Inf", "count": 1}
+		]
+	}`, w.Body.String())
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// LoggingHandler logs a line for every request served by h, other than the
+// liveness probes at /healthz which would only be noise.
+func LoggingHandler(logger zerolog.Logger, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		lw := &loggingResponseWriter{ResponseWriter: w}
+		h.ServeHTTP(lw, r)
+
+		event := logger.Info()
+		if id := GetRequestID(r.Context()); id != "" {
+			event = event.Str("request_id", id)
+		}
+		event.
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Int("status", lw.status()).
+			Int("size", lw.size).
+			Str("remote_addr", r.RemoteAddr).
+			Dur("duration", time.Since(start)).
+			Msg("http: served request")
+	})
+}
+
+// loggingResponseWriter records the status and size of a response, which
+// http.ResponseWriter doesn't expose.
+type loggingResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+	size       int
+}
+
+func (w *loggingResponseWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *loggingResponseWriter) Write(b []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// Flush passes flushes through so that streamed responses aren't buffered.
+func (w *loggingResponseWriter) Flush() {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// status returns the status of the response, which is 200 if the handler
+// never wrote one.
+func (w *loggingResponseWriter) status() int {
+	if w.statusCode == 0 {
+		return http.StatusOK
+	}
+	return w.statusCode
+}
@@ -0,0 +1,61 @@
+package handlers_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoggingHandler(t *testing.T) {
+	var out bytes.Buffer
+	h := handlers.LoggingHandler(zerolog.New(&out), http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("hello"))
+	}))
+
+	serve := func(path string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	t.Run("logs the request", func(t *testing.T) {
+		out.Reset()
+		serve("/v1/tsg")
+
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(out.Bytes(), &line))
+		assert.Equal(t, "info", line["level"])
+		assert.Equal(t, "GET", line["method"])
+		assert.Equal(t, "/v1/tsg", line["path"])
+		assert.Equal(t, float64(http.StatusOK), line["status"])
+		assert.Equal(t, float64(len("hello")), line["size"])
+		assert.Equal(t, "10.0.0.1:1234", line["remote_addr"])
+		assert.Contains(t, line, "duration")
+	})
+
+	t.Run("logs the status written", func(t *testing.T) {
+		out.Reset()
+		serve("/missing")
+
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(out.Bytes(), &line))
+		assert.Equal(t, float64(http.StatusNotFound), line["status"])
+	})
+
+	t.Run("skips liveness probes", func(t *testing.T) {
+		out.Reset()
+		serve("/healthz")
+		assert.Empty(t, out.String())
+	})
+}
@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/google/uuid"
+)
+
+// RequestIDHeader carries the ID of a request, both from callers and back to
+// them.
+const RequestIDHeader = "X-Request-ID"
+
+// validRequestID keeps caller supplied IDs from injecting anything into logs
+// or response headers.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestIDHandler tags every request with an ID, either the one the caller
+// sent in the X-Request-ID header or a generated UUID, and echoes it back in
+// the response.
+func RequestIDHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID.MatchString(id) {
+			id = uuid.New().String()
+		}
+
+		w.Header().Set(RequestIDHeader, id)
+		h.ServeHTTP(w, r.WithContext(WithRequestID(r.Context(), id)))
+	})
+}
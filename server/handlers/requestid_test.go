@@ -0,0 +1,47 @@
+package handlers_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestIDHandler(t *testing.T) {
+	var seen string
+	h := handlers.RequestIDHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = handlers.GetRequestID(r.Context())
+	}))
+
+	serve := func(id string) string {
+		req := httptest.NewRequest(http.MethodGet, "/v1/tsg", nil)
+		if id != "" {
+			req.Header.Set(handlers.RequestIDHeader, id)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, req)
+		return w.Header().Get(handlers.RequestIDHeader)
+	}
+
+	t.Run("caller supplied", func(t *testing.T) {
+		assert.Equal(t, "abc-123", serve("abc-123"))
+		assert.Equal(t, "abc-123", seen)
+	})
+
+	t.Run("generated", func(t *testing.T) {
+		id := serve("")
+		_, err := uuid.Parse(id)
+		assert.NoError(t, err)
+		assert.Equal(t, id, seen)
+	})
+
+	t.Run("invalid replaced", func(t *testing.T) {
+		id := serve("bad id\r\nX-Injected: 1")
+		_, err := uuid.Parse(id)
+		assert.NoError(t, err)
+		assert.Equal(t, id, seen)
+	})
+}
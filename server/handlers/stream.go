@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// arrayFlushEvery is the number of elements written between flushes of the
+// response, bounding how much of a streamed array is buffered.
+const arrayFlushEvery = 100
+
+// ArrayEncoder streams a JSON array to a response one element at a time, so
+// that listing many rows never holds all of them in memory.
+type ArrayEncoder struct {
+	w       http.ResponseWriter
+	count   int
+	started bool
+}
+
+// NewArrayEncoder returns an encoder writing a JSON array to w with a 200
+// status. Nothing is written until the first element or Close.
+func NewArrayEncoder(w http.ResponseWriter) *ArrayEncoder {
+	return &ArrayEncoder{w: w}
+}
+
+// Started returns true once the response has been written to, after which
+// errors can no longer change its status.
+func (e *ArrayEncoder) Started() bool {
+	return e.started
+}
+
+// Encode writes v as the next element of the array.
+func (e *ArrayEncoder) Encode(v interface{}) error {
+	// Marshal ahead of writing so an element which fails to encode can still
+	// be reported with an error status if it's the first.
+	bytes, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+
+	sep := ","
+	if !e.started {
+		e.start()
+		sep = "["
+	}
+	if _, err := e.w.Write(append([]byte(sep), bytes...)); err != nil {
+		return err
+	}
+
+	e.count++
+	if e.count%arrayFlushEvery == 0 {
+		e.flush()
+	}
+	return nil
+}
+
+// Close terminates the array, writing an empty one if no element was
+// encoded.
+func (e *ArrayEncoder) Close() error {
+	end := "]\n"
+	if !e.started {
+		e.start()
+		end = "[]\n"
+	}
+	_, err := e.w.Write([]byte(end))
+	e.flush()
+	return err
+}
+
+func (e *ArrayEncoder) start() {
+	e.started = true
+	e.w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	e.w.WriteHeader(http.StatusOK)
+}
+
+func (e *ArrayEncoder) flush() {
+	if f, ok := e.w.(http.Flusher); ok {
+		f.Flush()
+	}
+}
@@ -0,0 +1,83 @@
+package handlers_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/server/handlers"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// flushCounter records how much of the response had been written at each
+// flush.
+type flushCounter struct {
+	*httptest.ResponseRecorder
+	flushedAt []int
+}
+
+func (f *flushCounter) Flush() {
+	f.flushedAt = append(f.flushedAt, f.Body.Len())
+	f.ResponseRecorder.Flush()
+}
+
+type streamRow struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+func TestArrayEncoderLarge(t *testing.T) {
+	const n = 10000
+
+	w := &flushCounter{ResponseRecorder: httptest.NewRecorder()}
+	enc := handlers.NewArrayEncoder(w)
+	assert.False(t, enc.Started())
+
+	for i := 0; i < n; i++ {
+		require.NoError(t, enc.Encode(streamRow{
+			ID:   fmt.Sprintf("row-%05d", i),
+			Name: fmt.Sprintf("group-%d", i),
+		}))
+	}
+	assert.True(t, enc.Started())
+
+	// The array was flushed to the client as it was written rather than
+	// once complete.
+	require.True(t, len(w.flushedAt) >= n/100)
+	assert.True(t, w.flushedAt[0] < w.Body.Len()/10)
+
+	require.NoError(t, enc.Close())
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	require.True(t, json.Valid(w.Body.Bytes()))
+
+	var rows []streamRow
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rows))
+	require.Len(t, rows, n)
+	assert.Equal(t, "row-00000", rows[0].ID)
+	assert.Equal(t, "row-09999", rows[n-1].ID)
+}
+
+func TestArrayEncoderEmpty(t *testing.T) {
+	w := httptest.NewRecorder()
+	enc := handlers.NewArrayEncoder(w)
+	require.NoError(t, enc.Close())
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "[]\n", w.Body.String())
+}
+
+func TestArrayEncoderInvalidElement(t *testing.T) {
+	w := httptest.NewRecorder()
+	enc := handlers.NewArrayEncoder(w)
+
+	// Nothing is written for an element which fails to encode, leaving the
+	// handler free to report the error.
+	assert.Error(t, enc.Encode(make(chan int)))
+	assert.False(t, enc.Started())
+	assert.Equal(t, 0, w.Body.Len())
+}
@@ -0,0 +1,204 @@
+// Package openapi generates the OpenAPI 3 document of the API from its route
+// table, so that the document can't drift from the routes actually served.
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/joyent/triton-service-groups/buildtime"
+	"github.com/joyent/triton-service-groups/server/router"
+	"github.com/rs/zerolog/log"
+)
+
+// Path is where the document is served.
+const Path = "/v1/openapi.json"
+
+// signatureScheme is the name of the security scheme of the API's requests.
+const signatureScheme = "httpSignature"
+
+// Document is an OpenAPI 3 document.
+type Document struct {
+	OpenAPI    string                           `json:"openapi"`
+	Info       Info                             `json:"info"`
+	Paths      map[string]map[string]*Operation `json:"paths"`
+	Components Components                       `json:"components"`
+	Security   []map[string][]string            `json:"security"`
+}
+
+type Info struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+// Operation is a route of the API, under its path and lowercase method.
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema         `json:"schemas"`
+	SecuritySchemes map[string]*SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type        string `json:"type"`
+	In          string `json:"in"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// pathParam matches the variables of route patterns, such as {identifier}.
+var pathParam = regexp.MustCompile(`{([^}:]+)(:[^}]*)?}`)
+
+// New returns the document of the routes of table. Every route requires
+// requests to be signed, as they're authenticated ahead of routing.
+func New(table router.RouteTable) *Document {
+	s := newSchemas()
+
+	doc := &Document{
+		OpenAPI: "3.0.0",
+		Info: Info{
+			Title:   "Triton Service Groups",
+			Version: version(),
+		},
+		Paths: make(map[string]map[string]*Operation),
+		Components: Components{
+			Schemas: s.components,
+			SecuritySchemes: map[string]*SecurityScheme{
+				signatureScheme: {
+					Type: "apiKey",
+					In:   "header",
+					Name: "Authorization",
+					Description: "An HTTP Signature of the request's Date header with a key of the " +
+						"Triton account, as for CloudAPI.",
+				},
+			},
+		},
+		Security: []map[string][]string{{signatureScheme: {}}},
+	}
+
+	for _, routes := range table {
+		for _, route := range routes {
+			path := pathParam.ReplaceAllString(route.Pattern, "{$1}")
+			if doc.Paths[path] == nil {
+				doc.Paths[path] = make(map[string]*Operation)
+			}
+			doc.Paths[path][strings.ToLower(route.Method)] = operation(s, route)
+		}
+	}
+
+	return doc
+}
+
+// operation describes route, deriving the schemas of its request and
+// response bodies from their types.
+func operation(s *schemas, route router.Route) *Operation {
+	op := &Operation{
+		OperationID: route.Name,
+		Summary:     route.Summary,
+		Responses:   make(map[string]*Response),
+	}
+
+	// Routes are grouped by the collection they're under, such as groups.
+	segments := strings.Split(strings.TrimPrefix(route.Pattern, "/v1/tsg/"), "/")
+	op.Tags = []string{segments[0]}
+
+	for _, match := range pathParam.FindAllStringSubmatch(route.Pattern, -1) {
+		op.Parameters = append(op.Parameters, &Parameter{
+			Name:     match[1],
+			In:       "path",
+			Required: true,
+			Schema:   &Schema{Type: "string"},
+		})
+	}
+
+	if route.Request != nil {
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  content(s, route.Request),
+		}
+	}
+
+	status := route.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	response := &Response{Description: http.StatusText(status)}
+	if route.Response != nil {
+		response.Content = content(s, route.Response)
+	}
+	op.Responses[strconv.Itoa(status)] = response
+
+	op.Responses["default"] = &Response{Description: "The reason the request failed."}
+
+	return op
+}
+
+// content returns the media type of body, JSON unless body is a string.
+func content(s *schemas, body interface{}) map[string]*MediaType {
+	t := reflect.TypeOf(body)
+	if t.Kind() == reflect.String {
+		return map[string]*MediaType{"text/plain": {Schema: &Schema{Type: "string"}}}
+	}
+	return map[string]*MediaType{"application/json": {Schema: s.of(t)}}
+}
+
+func version() string {
+	if buildtime.Version == "" {
+		return "dev"
+	}
+	return buildtime.Version
+}
+
+// Handler serves doc. The document is serialized once, since the routes it
+// describes don't change while the server runs.
+func Handler(doc *Document) http.Handler {
+	bytes, err := json.Marshal(doc)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		if _, err := w.Write(bytes); err != nil {
+			log.Printf("%v", err)
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/server/router"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testRoutes = router.RouteTable{
+	router.Routes{
+		router.Route{
+			Name:     "ListNodes",
+			Method:   http.MethodGet,
+			Pattern:  "/v1/tsg/nodes",
+			Summary:  "List the nodes.",
+			Response: []*node{},
+		},
+		router.Route{
+			Name:     "CreateNode",
+			Method:   http.MethodPost,
+			Pattern:  "/v1/tsg/nodes",
+			Request:  node{},
+			Response: node{},
+			Status:   http.StatusCreated,
+		},
+		router.Route{
+			Name:    "DeleteNode",
+			Method:  http.MethodDelete,
+			Pattern: "/v1/tsg/nodes/{identifier}",
+			Status:  http.StatusNoContent,
+		},
+		router.Route{
+			Name:     "GetNodeSpec",
+			Method:   http.MethodGet,
+			Pattern:  "/v1/tsg/nodes/{identifier:[a-z]+}/spec",
+			Response: "",
+		},
+	},
+}
+
+func TestNew(t *testing.T) {
+	doc := New(testRoutes)
+
+	assert.Equal(t, "3.0.0", doc.OpenAPI)
+	assert.Equal(t, []map[string][]string{{signatureScheme: {}}}, doc.Security)
+	assert.Contains(t, doc.Components.SecuritySchemes, signatureScheme)
+	assert.Contains(t, doc.Components.Schemas, "node")
+
+	list := doc.Paths["/v1/tsg/nodes"]["get"]
+	require.NotNil(t, list)
+	assert.Equal(t, "ListNodes", list.OperationID)
+	assert.Equal(t, "List the nodes.", list.Summary)
+	assert.Equal(t, []string{"nodes"}, list.Tags)
+	assert.Nil(t, list.RequestBody)
+	assert.Equal(t, &Schema{Type: "array", Items: &Schema{Ref: "#/components/schemas/node"}},
+		list.Responses["200"].Content["application/json"].Schema)
+
+	create := doc.Paths["/v1/tsg/nodes"]["post"]
+	require.NotNil(t, create)
+	assert.Equal(t, &Schema{Ref: "#/components/schemas/node"},
+		create.RequestBody.Content["application/json"].Schema)
+	assert.Contains(t, create.Responses, "201")
+	assert.Contains(t, create.Responses, "default")
+
+	remove := doc.Paths["/v1/tsg/nodes/{identifier}"]["delete"]
+	require.NotNil(t, remove)
+	assert.Equal(t, []*Parameter{{Name: "identifier", In: "path", Required: true, Schema: &Schema{Type: "string"}}},
+		remove.Parameters)
+	assert.Equal(t, &Response{Description: "No Content"}, remove.Responses["204"])
+
+	spec := doc.Paths["/v1/tsg/nodes/{identifier}/spec"]["get"]
+	require.NotNil(t, spec)
+	assert.Contains(t, spec.Responses["200"].Content, "text/plain")
+}
+
+func TestHandler(t *testing.T) {
+	handler := Handler(New(testRoutes))
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, Path, nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+
+	var doc map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.0", doc["openapi"])
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, Path, nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+	assert.Equal(t, "GET, HEAD", w.Header().Get("Allow"))
+}
@@ -0,0 +1,63 @@
+package openapi
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type node struct {
+	Name     string            `json:"name"`
+	Weight   *int              `json:"weight,omitempty"`
+	Tags     map[string]string `json:"tags"`
+	Children []*node           `json:"children"`
+	Created  time.Time         `json:"created_at"`
+	Raw      json.RawMessage   `json:"raw"`
+	Secret   string            `json:"-"`
+	Untagged bool
+	hidden   string
+}
+
+type wrapper struct {
+	node
+	Extra float64 `json:"extra"`
+}
+
+func TestSchemaOf(t *testing.T) {
+	s := newSchemas()
+
+	assert.Equal(t, &Schema{Ref: "#/components/schemas/node"}, s.of(reflect.TypeOf(&node{})))
+	assert.Equal(t, &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"name":       {Type: "string"},
+			"weight":     {Type: "integer", Format: "int32", Nullable: true},
+			"tags":       {Type: "object", AdditionalProperties: &Schema{Type: "string"}},
+			"children":   {Type: "array", Items: &Schema{Ref: "#/components/schemas/node"}},
+			"created_at": {Type: "string", Format: "date-time"},
+			"raw":        {},
+			"Untagged":   {Type: "boolean"},
+		},
+	}, s.components["node"])
+
+	// Fields of embedded structs are promoted.
+	s.of(reflect.TypeOf(wrapper{}))
+	assert.Contains(t, s.components["wrapper"].Properties, "name")
+	assert.Equal(t, &Schema{Type: "number", Format: "double"}, s.components["wrapper"].Properties["extra"])
+	assert.Len(t, s.components, 2)
+}
+
+type Location struct {
+	Name string `json:"name"`
+}
+
+func TestSchemaComponentNames(t *testing.T) {
+	s := newSchemas()
+
+	assert.Equal(t, "Location", s.component(reflect.TypeOf(Location{})))
+	assert.Equal(t, "TimeLocation", s.component(reflect.TypeOf(time.Location{})))
+	assert.Equal(t, "Location", s.component(reflect.TypeOf(Location{})))
+}
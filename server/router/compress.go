@@ -0,0 +1,225 @@
+package router
+
+import (
+	"bytes"
+	"compress/gzip"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// compressedTypes are the media types, or their prefixes, of content which is
+// already compressed and gains nothing from compressing it again.
+var compressedTypes = []string{
+	"application/gzip",
+	"application/x-gzip",
+	"application/zip",
+	"application/x-bzip2",
+	"application/x-xz",
+	"application/zstd",
+	"image/",
+	"audio/",
+	"video/",
+}
+
+// CompressionHandler gzips the responses of h for clients which accept it,
+// once their body reaches minSize bytes. Smaller bodies, which gain little,
+// are written as they are. A negative minSize disables compression.
+//
+// Bodies are buffered until they reach minSize or are flushed, so a streamed
+// response is compressed from its first flush if enough was written by then.
+func CompressionHandler(minSize int, h http.Handler) http.Handler {
+	if minSize < 0 {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		if r.Method == http.MethodHead || !acceptsGzip(r.Header.Get("Accept-Encoding")) {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressResponseWriter{ResponseWriter: w, minSize: minSize}
+		defer cw.Close()
+
+		h.ServeHTTP(cw, r)
+	})
+}
+
+// acceptsGzip returns true if an Accept-Encoding header value includes gzip
+// without a zero quality.
+func acceptsGzip(header string) bool {
+	for _, coding := range strings.Split(header, ",") {
+		params := strings.Split(coding, ";")
+		name := strings.ToLower(strings.TrimSpace(params[0]))
+		if name != "gzip" && name != "*" {
+			continue
+		}
+
+		accepted := true
+		for _, param := range params[1:] {
+			param = strings.TrimSpace(param)
+			if !strings.HasPrefix(param, "q=") {
+				continue
+			}
+			if q, err := strconv.ParseFloat(param[2:], 64); err == nil && q == 0 {
+				accepted = false
+			}
+		}
+		if name == "gzip" {
+			return accepted
+		}
+		if accepted {
+			return true
+		}
+	}
+	return false
+}
+
+// compressible returns true if a response with the given headers and status
+// has a body worth compressing.
+func compressible(header http.Header, statusCode int) bool {
+	switch {
+	case statusCode < http.StatusOK,
+		statusCode == http.StatusNoContent,
+		statusCode == http.StatusNotModified:
+		return false
+	case header.Get("Content-Encoding") != "":
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		// Content which hasn't got a type is sniffed as it's written, and so
+		// is compressible unless it turns out otherwise.
+		return header.Get("Content-Type") == ""
+	}
+	for _, compressed := range compressedTypes {
+		if strings.HasPrefix(mediaType, compressed) {
+			return false
+		}
+	}
+	return true
+}
+
+// compressResponseWriter holds back the status and body of a response until
+// it's known whether the body will be compressed: once it reaches the minimum
+// size, or the response is flushed or closed.
+type compressResponseWriter struct {
+	http.ResponseWriter
+	minSize int
+
+	statusCode  int
+	wroteHeader bool
+	buf         bytes.Buffer
+	gz          *gzip.Writer
+	// decided is true once the status has been written through, with or
+	// without compression.
+	decided bool
+}
+
+func (w *compressResponseWriter) WriteHeader(statusCode int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	w.statusCode = statusCode
+
+	if !compressible(w.Header(), statusCode) {
+		w.decide(false)
+	}
+}
+
+func (w *compressResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+
+	switch {
+	case w.gz != nil:
+		return w.gz.Write(b)
+	case w.decided:
+		return w.ResponseWriter.Write(b)
+	}
+
+	w.buf.Write(b)
+	if w.buf.Len() >= w.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush settles whether the body is compressed, by what's been written so
+// far, and passes the flush through so that streamed responses aren't held
+// back.
+func (w *compressResponseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		w.decide(w.buf.Len() >= w.minSize)
+	}
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close writes out whatever's still held back, ending the compressed body if
+// there is one.
+func (w *compressResponseWriter) Close() error {
+	if !w.wroteHeader {
+		// Nothing was written, so let the server write its own default
+		// response.
+		return nil
+	}
+	if !w.decided {
+		if err := w.decide(w.buf.Len() >= w.minSize); err != nil {
+			return err
+		}
+	}
+	if w.gz != nil {
+		return w.gz.Close()
+	}
+	return nil
+}
+
+// decide writes the status, compressing the body from here on if compress is
+// true, and then the buffered body.
+func (w *compressResponseWriter) decide(compress bool) error {
+	w.decided = true
+
+	// The type of an untyped body is sniffed from its start before it's
+	// compressed, as the server would otherwise sniff the compressed bytes.
+	header := w.Header()
+	if header.Get("Content-Type") == "" && w.buf.Len() > 0 {
+		header.Set("Content-Type", http.DetectContentType(w.buf.Bytes()))
+		compress = compress && compressible(header, w.statusCode)
+	}
+
+	if compress {
+		header.Set("Content-Encoding", "gzip")
+		header.Del("Content-Length")
+		w.gz = gzip.NewWriter(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(w.statusCode)
+
+	if w.buf.Len() == 0 {
+		return nil
+	}
+	var err error
+	if w.gz != nil {
+		_, err = w.gz.Write(w.buf.Bytes())
+	} else {
+		_, err = w.ResponseWriter.Write(w.buf.Bytes())
+	}
+	w.buf.Reset()
+	return err
+}
@@ -0,0 +1,154 @@
+package router
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var largeBody = `[` + strings.Repeat(`{"group_name": "web", "capacity": 3},`, 100) + `{}]`
+
+func serveCompressed(t *testing.T, h http.HandlerFunc, acceptEncoding string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	r := httptest.NewRequest(http.MethodGet, "/v1/tsg/groups", nil)
+	if acceptEncoding != "" {
+		r.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+
+	w := httptest.NewRecorder()
+	CompressionHandler(1024, h).ServeHTTP(w, r)
+	return w
+}
+
+func gunzip(t *testing.T, w *httptest.ResponseRecorder) string {
+	t.Helper()
+
+	gz, err := gzip.NewReader(w.Body)
+	require.NoError(t, err)
+	body, err := ioutil.ReadAll(gz)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func writeJSON(body string, statusCode int) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(statusCode)
+		w.Write([]byte(body))
+	}
+}
+
+func TestCompressionHandler(t *testing.T) {
+	w := serveCompressed(t, writeJSON(largeBody, http.StatusCreated), "gzip, deflate")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.True(t, w.Body.Len() < len(largeBody))
+	assert.Equal(t, largeBody, gunzip(t, w))
+}
+
+func TestCompressionHandlerNotAccepted(t *testing.T) {
+	for _, acceptEncoding := range []string{"", "deflate", "gzip;q=0", "*;q=0"} {
+		w := serveCompressed(t, writeJSON(largeBody, http.StatusOK), acceptEncoding)
+		assert.Equal(t, http.StatusOK, w.Code, acceptEncoding)
+		assert.Empty(t, w.Header().Get("Content-Encoding"), acceptEncoding)
+		assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"), acceptEncoding)
+		assert.Equal(t, largeBody, w.Body.String(), acceptEncoding)
+	}
+}
+
+func TestCompressionHandlerMinSize(t *testing.T) {
+	const body = `{"id": "722d25ed-f32a-4944-9861-8990e204850e"}`
+
+	w := serveCompressed(t, writeJSON(body, http.StatusOK), "gzip")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, body, w.Body.String())
+
+	// The body is compressed once it reaches the minimum size over several
+	// writes.
+	w = serveCompressed(t, func(w http.ResponseWriter, r *http.Request) {
+		for _, part := range strings.SplitAfter(largeBody, ",") {
+			w.Write([]byte(part))
+		}
+	}, "gzip")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, largeBody, gunzip(t, w))
+}
+
+func TestCompressionHandlerCompressed(t *testing.T) {
+	w := serveCompressed(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/gzip")
+		w.Write([]byte(largeBody))
+	}, "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, largeBody, w.Body.String())
+
+	w = serveCompressed(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		w.Write([]byte(largeBody))
+	}, "gzip")
+	assert.Equal(t, "br", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, largeBody, w.Body.String())
+
+	w = serveCompressed(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}, "gzip")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, 0, w.Body.Len())
+}
+
+func TestCompressionHandlerFlush(t *testing.T) {
+	// A flush ahead of the minimum size writes the body as it is.
+	w := serveCompressed(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(http.StatusAccepted)
+		w.Write([]byte("["))
+		w.(http.Flusher).Flush()
+		w.Write([]byte(largeBody[1:]))
+	}, "gzip")
+	assert.Equal(t, http.StatusAccepted, w.Code)
+	assert.True(t, w.Flushed)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, largeBody, w.Body.String())
+
+	// A flush after it sends what's been compressed so far.
+	r := httptest.NewRequest(http.MethodGet, "/v1/tsg/groups", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	var flushed int
+	w = httptest.NewRecorder()
+	CompressionHandler(1024, http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		rw.Header().Set("Content-Type", "application/json; charset=utf-8")
+		rw.Write([]byte(largeBody[:len(largeBody)/2]))
+		rw.(http.Flusher).Flush()
+		flushed = w.Body.Len()
+		rw.Write([]byte(largeBody[len(largeBody)/2:]))
+	})).ServeHTTP(w, r)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, w.Flushed)
+	assert.NotZero(t, flushed)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	assert.Equal(t, largeBody, gunzip(t, w))
+}
+
+func TestCompressionHandlerDisabled(t *testing.T) {
+	r := httptest.NewRequest(http.MethodGet, "/v1/tsg/groups", nil)
+	r.Header.Set("Accept-Encoding", "gzip")
+
+	w := httptest.NewRecorder()
+	CompressionHandler(-1, writeJSON(largeBody, http.StatusOK)).ServeHTTP(w, r)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, largeBody, w.Body.String())
+}
@@ -0,0 +1,90 @@
+package router
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// corsMaxAge is how long, in seconds, browsers may cache the outcome of a
+// preflight request.
+const corsMaxAge = 10 * 60
+
+var (
+	// corsAllowedMethods are the methods of the API's routes.
+	corsAllowedMethods = []string{
+		http.MethodGet,
+		http.MethodPost,
+		http.MethodPut,
+		http.MethodDelete,
+	}
+
+	// corsAllowedHeaders are the request headers the API reads: those
+	// requests are signed with, and those of request IDs, conditional
+	// updates and idempotent creates.
+	corsAllowedHeaders = []string{
+		"Authorization",
+		"Content-Type",
+		"Date",
+		"If-Match",
+		"Idempotency-Key",
+		"X-Request-ID",
+	}
+
+	// corsExposedHeaders are the response headers browsers let scripts
+	// read, beyond the simple ones.
+	corsExposedHeaders = []string{
+		"ETag",
+		"Idempotent-Replayed",
+		"Location",
+		"Retry-After",
+		"Warning",
+		"X-Request-ID",
+	}
+)
+
+// CORSHandler lets browser scripts served from allowedOrigins call h across
+// origins, answering their preflight requests itself. Requests from any other
+// origin are rejected with a 403, while requests without an Origin, such as
+// those of other clients, are served by h as usual. An origin of "*" allows
+// every origin, and no origins disables CORS.
+func CORSHandler(allowedOrigins []string, h http.Handler) http.Handler {
+	if len(allowedOrigins) == 0 {
+		return h
+	}
+
+	allowed := make(map[string]bool, len(allowedOrigins))
+	for _, origin := range allowedOrigins {
+		allowed[strings.ToLower(origin)] = true
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			h.ServeHTTP(w, r)
+			return
+		}
+		if !allowed["*"] && !allowed[strings.ToLower(origin)] {
+			http.Error(w, "origin "+strconv.Quote(origin)+" is not allowed", http.StatusForbidden)
+			return
+		}
+
+		header := w.Header()
+		header.Set("Access-Control-Allow-Origin", origin)
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			header.Add("Vary", "Access-Control-Request-Method")
+			header.Add("Vary", "Access-Control-Request-Headers")
+			header.Set("Access-Control-Allow-Methods", strings.Join(corsAllowedMethods, ", "))
+			header.Set("Access-Control-Allow-Headers", strings.Join(corsAllowedHeaders, ", "))
+			header.Set("Access-Control-Max-Age", strconv.Itoa(corsMaxAge))
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		header.Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,81 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func serveCORS(allowedOrigins []string, method, origin string, header http.Header) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, "/v1/tsg/groups", nil)
+	for name, values := range header {
+		r.Header[name] = values
+	}
+	if origin != "" {
+		r.Header.Set("Origin", origin)
+	}
+
+	w := httptest.NewRecorder()
+	CORSHandler(allowedOrigins, writeJSON(`[]`, http.StatusOK)).ServeHTTP(w, r)
+	return w
+}
+
+func TestCORSHandlerPreflight(t *testing.T) {
+	w := serveCORS([]string{"https://dashboard.example.com"}, http.MethodOptions, "https://dashboard.example.com", http.Header{
+		"Access-Control-Request-Method":  {http.MethodPost},
+		"Access-Control-Request-Headers": {"authorization, date, idempotency-key"},
+	})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "https://dashboard.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "GET, POST, PUT, DELETE", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Authorization, Content-Type, Date, If-Match, Idempotency-Key, X-Request-ID",
+		w.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+	assert.Contains(t, w.Header()["Vary"], "Origin")
+	assert.Equal(t, 0, w.Body.Len())
+}
+
+func TestCORSHandlerRequest(t *testing.T) {
+	w := serveCORS([]string{"https://dashboard.example.com"}, http.MethodGet, "https://Dashboard.example.com", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "https://Dashboard.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "X-Request-ID")
+	assert.Equal(t, `[]`, w.Body.String())
+
+	w = serveCORS([]string{"*"}, http.MethodGet, "http://localhost:8080", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "http://localhost:8080", w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSHandlerRejected(t *testing.T) {
+	for _, method := range []string{http.MethodOptions, http.MethodGet} {
+		w := serveCORS([]string{"https://dashboard.example.com"}, method, "https://evil.example.com", http.Header{
+			"Access-Control-Request-Method": {http.MethodDelete},
+		})
+		assert.Equal(t, http.StatusForbidden, w.Code, method)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"), method)
+		assert.Equal(t, "origin \"https://evil.example.com\" is not allowed\n", w.Body.String(), method)
+	}
+}
+
+func TestCORSHandlerWithoutOrigin(t *testing.T) {
+	w := serveCORS([]string{"https://dashboard.example.com"}, http.MethodGet, "", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, `[]`, w.Body.String())
+}
+
+func TestCORSHandlerDisabled(t *testing.T) {
+	w := serveCORS(nil, http.MethodGet, "https://dashboard.example.com", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Vary"))
+
+	// Preflight requests are left to the router, as they are without CORS.
+	w = serveCORS(nil, http.MethodOptions, "https://dashboard.example.com", http.Header{
+		"Access-Control-Request-Method": {http.MethodPost},
+	})
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+}
@@ -2,6 +2,7 @@ package router
 
 import (
 	"net/http"
+	"time"
 
 	"github.com/gorilla/mux"
 )
@@ -15,17 +16,35 @@ type Route struct {
 	Method  string
 	Pattern string
 	Handler http.HandlerFunc
+	// Summary describes the route in the API's OpenAPI document. Request and
+	// Response are values of the types of its request and response bodies,
+	// if it has them, and Status that of its successful responses when it
+	// isn't 200 OK. See package openapi.
+	Summary  string
+	Request  interface{}
+	Response interface{}
+	Status   int
+	// Timeout overrides the default timeout of the route's requests, or is
+	// NoTimeout if they're never timed out.
+	Timeout time.Duration
 }
 
-func WithRoutes(routes RouteTable) *mux.Router {
+// WithRoutes constructs a router of the given routes, whose requests time out
+// after timeout unless their route overrides it.
+func WithRoutes(routes RouteTable, timeout time.Duration) *mux.Router {
 	router := mux.NewRouter().StrictSlash(true)
 
 	for _, rs := range routes {
 		for _, r := range rs {
+			routeTimeout := timeout
+			if r.Timeout != 0 {
+				routeTimeout = r.Timeout
+			}
+
 			router.Path(r.Pattern).
 				Methods(r.Method).
 				Name(r.Name).
-				HandlerFunc(r.Handler)
+				Handler(TimeoutHandler(routeTimeout, r.Handler))
 		}
 	}
 
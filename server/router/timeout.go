@@ -0,0 +1,159 @@
+package router
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// NoTimeout is the Timeout of a route whose requests are never timed out,
+// such as those bounded by timeouts of their own.
+const NoTimeout time.Duration = -1
+
+// TimeoutHandler cancels the context of a request to h once it's run for
+// timeout, and responds with a 504 if h hadn't started its response by then.
+// Anything h writes after that is dropped. A timeout of zero or less never
+// times out.
+//
+// A response h had already started when it timed out is left to h to finish,
+// which it's expected to do promptly once its context is cancelled.
+func TimeoutHandler(timeout time.Duration, h http.Handler) http.Handler {
+	if timeout <= 0 {
+		return h
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := context.WithTimeout(r.Context(), timeout)
+		defer cancel()
+
+		tw := &timeoutResponseWriter{
+			ResponseWriter: w,
+			header:         make(http.Header),
+			ctx:            ctx,
+			parent:         r.Context(),
+			msg:            fmt.Sprintf("request timed out after %s", timeout),
+		}
+		done := make(chan struct{})
+		panicked := make(chan interface{}, 1)
+		go func() {
+			defer func() {
+				if p := recover(); p != nil {
+					panicked <- p
+					return
+				}
+				close(done)
+			}()
+			h.ServeHTTP(tw, r.WithContext(ctx))
+		}()
+
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+			tw.timeout()
+			return
+		case <-ctx.Done():
+			if tw.timeout() {
+				return
+			}
+		}
+
+		select {
+		case p := <-panicked:
+			panic(p)
+		case <-done:
+		}
+	})
+}
+
+// timeoutResponseWriter guards the response of a handler run by
+// TimeoutHandler, so that it's either written by the handler or replaced by
+// a timeout, never both. The handler writes its headers to a map of its own
+// until it writes its status.
+type timeoutResponseWriter struct {
+	http.ResponseWriter
+	header http.Header
+	ctx    context.Context
+	// parent is the context of the request, which is done if its client went
+	// away rather than because it timed out.
+	parent context.Context
+	msg    string
+
+	mu          sync.Mutex
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (w *timeoutResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutResponseWriter) WriteHeader(statusCode int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writeHeader(statusCode)
+}
+
+func (w *timeoutResponseWriter) writeHeader(statusCode int) {
+	if w.wroteHeader || w.timeoutLocked() {
+		return
+	}
+	w.wroteHeader = true
+
+	header := w.ResponseWriter.Header()
+	for name, values := range w.header {
+		header[name] = values
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *timeoutResponseWriter) Write(b []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writeHeader(http.StatusOK)
+	if w.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush passes flushes through so that streamed responses aren't buffered.
+func (w *timeoutResponseWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.writeHeader(http.StatusOK)
+	if w.timedOut {
+		return
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// timeout responds with a 504 and returns true if the request timed out
+// before the handler started its response. Anything the handler writes after
+// that is dropped.
+func (w *timeoutResponseWriter) timeout() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.timeoutLocked()
+}
+
+func (w *timeoutResponseWriter) timeoutLocked() bool {
+	switch {
+	case w.timedOut:
+		return true
+	case w.wroteHeader, w.ctx.Err() == nil, w.parent.Err() != nil:
+		return false
+	}
+
+	w.timedOut = true
+	http.Error(w.ResponseWriter, w.msg, http.StatusGatewayTimeout)
+	return true
+}
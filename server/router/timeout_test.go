@@ -0,0 +1,104 @@
+package router
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeoutHandler(t *testing.T) {
+	cancelled := make(chan error, 1)
+	h := TimeoutHandler(20*time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+
+		// The timeout was already written, so this is dropped.
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		_, err := w.Write([]byte(`{}`))
+		assert.Equal(t, http.ErrHandlerTimeout, err)
+
+		cancelled <- r.Context().Err()
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/tsg/groups/web/render", nil))
+	assert.Equal(t, http.StatusGatewayTimeout, w.Code)
+	assert.Equal(t, "request timed out after 20ms\n", w.Body.String())
+	assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+
+	select {
+	case err := <-cancelled:
+		assert.Equal(t, context.DeadlineExceeded, err)
+	case <-time.After(time.Second):
+		t.Fatal("handler's context wasn't cancelled")
+	}
+}
+
+func TestTimeoutHandlerStarted(t *testing.T) {
+	h := TimeoutHandler(20*time.Millisecond, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.Write([]byte(`[`))
+		w.(http.Flusher).Flush()
+
+		<-r.Context().Done()
+		w.Write([]byte(`]`))
+	}))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/tsg/groups", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, w.Flushed)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `[]`, w.Body.String())
+}
+
+func TestTimeoutHandlerInTime(t *testing.T) {
+	h := TimeoutHandler(time.Second, writeJSON(`{}`, http.StatusCreated))
+
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/v1/tsg/groups", nil))
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `{}`, w.Body.String())
+}
+
+func TestTimeoutHandlerPanic(t *testing.T) {
+	h := TimeoutHandler(time.Second, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	}))
+
+	w := httptest.NewRecorder()
+	assert.PanicsWithValue(t, "boom", func() {
+		h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/tsg/groups", nil))
+	})
+}
+
+func TestWithRoutesTimeout(t *testing.T) {
+	slow := func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(50 * time.Millisecond):
+			w.WriteHeader(http.StatusOK)
+		}
+	}
+
+	router := WithRoutes(RouteTable{{
+		{Name: "Render", Method: http.MethodPost, Pattern: "/render", Handler: slow},
+		{Name: "Create", Method: http.MethodPost, Pattern: "/create", Handler: slow, Timeout: NoTimeout},
+		{Name: "Scale", Method: http.MethodPost, Pattern: "/scale", Handler: slow, Timeout: time.Second},
+	}}, 10*time.Millisecond)
+
+	for pattern, status := range map[string]int{
+		"/render": http.StatusGatewayTimeout,
+		"/create": http.StatusOK,
+		"/scale":  http.StatusOK,
+	} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, pattern, nil))
+		require.Equal(t, status, w.Code, pattern)
+	}
+}
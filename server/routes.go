@@ -2,91 +2,345 @@ package server
 
 import (
 	"net/http"
+	"time"
 
+	"github.com/joyent/triton-service-groups/account"
+	"github.com/joyent/triton-service-groups/audit"
+	"github.com/joyent/triton-service-groups/bundles"
+	"github.com/joyent/triton-service-groups/features"
 	"github.com/joyent/triton-service-groups/groups"
 	"github.com/joyent/triton-service-groups/server/router"
 	"github.com/joyent/triton-service-groups/templates"
 )
 
+// renderTimeout bounds the requests which only render jobs, which are quick
+// unless something's wrong.
+const renderTimeout = 10 * time.Second
+
 var templateRoutes = router.Routes{
 	router.Route{
-		Name:    "ListTemplates",
-		Method:  http.MethodGet,
-		Pattern: "/v1/tsg/templates",
-		Handler: templates_v1.List,
+		Name:     "ListTemplates",
+		Method:   http.MethodGet,
+		Pattern:  "/v1/tsg/templates",
+		Handler:  templates_v1.List,
+		Summary:  "List the templates of the account.",
+		Response: []*templates_v1.InstanceTemplate{},
 	},
 	router.Route{
-		Name:    "GetTemplate",
-		Method:  http.MethodGet,
-		Pattern: "/v1/tsg/templates/{identifier}",
-		Handler: templates_v1.Get,
+		Name:     "GetTemplate",
+		Method:   http.MethodGet,
+		Pattern:  "/v1/tsg/templates/{identifier}",
+		Handler:  templates_v1.Get,
+		Summary:  "Get the latest version of a template.",
+		Response: templates_v1.InstanceTemplate{},
+	},
+	router.Route{
+		Name:     "CreateTemplate",
+		Method:   http.MethodPost,
+		Pattern:  "/v1/tsg/templates",
+		Handler:  templates_v1.Create,
+		Summary:  "Create a template.",
+		Request:  templates_v1.InstanceTemplate{},
+		Response: templates_v1.InstanceTemplate{},
+		Status:   http.StatusCreated,
+	},
+	router.Route{
+		Name:     "ValidateTemplate",
+		Method:   http.MethodPost,
+		Pattern:  "/v1/tsg/templates/validate",
+		Handler:  groups_v1.ValidateTemplateJob,
+		Summary:  "Validate a template as the job of a group, without saving it.",
+		Request:  templates_v1.InstanceTemplate{},
+		Response: groups_v1.TemplateValidation{},
+		Timeout:  renderTimeout,
+	},
+	router.Route{
+		Name:     "UpdateTemplate",
+		Method:   http.MethodPut,
+		Pattern:  "/v1/tsg/templates/{identifier}",
+		Handler:  templates_v1.Update,
+		Summary:  "Save a new version of a template.",
+		Request:  templates_v1.InstanceTemplate{},
+		Response: templates_v1.InstanceTemplate{},
+		Status:   http.StatusCreated,
 	},
 	router.Route{
-		Name:    "CreateTemplate",
-		Method:  http.MethodPost,
-		Pattern: "/v1/tsg/templates",
-		Handler: templates_v1.Create,
+		Name:     "ListTemplateVersions",
+		Method:   http.MethodGet,
+		Pattern:  "/v1/tsg/templates/{identifier}/versions",
+		Handler:  templates_v1.ListVersions,
+		Summary:  "List every version of a template, oldest first.",
+		Response: []*templates_v1.InstanceTemplate{},
 	},
 	router.Route{
 		Name:    "DeleteTemplate",
 		Method:  http.MethodDelete,
 		Pattern: "/v1/tsg/templates/{identifier}",
 		Handler: templates_v1.Delete,
+		Summary: "Delete a template.",
+		Status:  http.StatusNoContent,
 	},
 }
 
 var groupRoutes = router.Routes{
 	router.Route{
-		Name:    "GetGroup",
-		Method:  http.MethodGet,
-		Pattern: "/v1/tsg/groups/{identifier}",
-		Handler: groups_v1.Get,
+		Name:     "GetGroup",
+		Method:   http.MethodGet,
+		Pattern:  "/v1/tsg/groups/{identifier}",
+		Handler:  groups_v1.Get,
+		Summary:  "Get a group.",
+		Response: groups_v1.ServiceGroup{},
 	},
 	router.Route{
-		Name:    "CreateGroup",
-		Method:  http.MethodPost,
-		Pattern: "/v1/tsg/groups",
-		Handler: groups_v1.Create,
+		Name:     "CreateGroup",
+		Method:   http.MethodPost,
+		Pattern:  "/v1/tsg/groups",
+		Handler:  groups_v1.Create,
+		Summary:  "Create a group and register its job.",
+		Request:  groups_v1.ServiceGroup{},
+		Response: groups_v1.ServiceGroup{},
+		Status:   http.StatusCreated,
+		// Bounded by nomad.first-run-timeout instead, as it may wait on the
+		// job's first run.
+		Timeout: router.NoTimeout,
 	},
 	router.Route{
-		Name:    "UpdateGroup",
-		Method:  http.MethodPut,
-		Pattern: "/v1/tsg/groups/{identifier}",
-		Handler: groups_v1.Update,
+		Name:     "UpdateGroup",
+		Method:   http.MethodPut,
+		Pattern:  "/v1/tsg/groups/{identifier}",
+		Handler:  groups_v1.Update,
+		Summary:  "Update a group and register its job again.",
+		Request:  groups_v1.ServiceGroup{},
+		Response: groups_v1.ServiceGroup{},
+		Timeout:  router.NoTimeout,
 	},
 	router.Route{
 		Name:    "DeleteGroup",
 		Method:  http.MethodDelete,
 		Pattern: "/v1/tsg/groups/{identifier}",
 		Handler: groups_v1.Delete,
+		Summary: "Delete a group and deregister its job.",
+		Status:  http.StatusNoContent,
+		// Bounded by triton.teardown-timeout instead.
+		Timeout: router.NoTimeout,
+	},
+	router.Route{
+		Name:     "ListGroups",
+		Method:   http.MethodGet,
+		Pattern:  "/v1/tsg/groups",
+		Handler:  groups_v1.List,
+		Summary:  "List the groups of the account.",
+		Response: []*groups_v1.ServiceGroup{},
+	},
+	router.Route{
+		Name:     "ReconcileGroups",
+		Method:   http.MethodPost,
+		Pattern:  "/v1/tsg/groups/reconcile",
+		Handler:  groups_v1.Reconcile,
+		Summary:  "Force a reconcile of every group of the account, or those selected.",
+		Response: groups_v1.ReconcileResults{},
+		// Bounded by the number of groups of the account instead, each
+		// forced with the usual retries of calls to Nomad.
+		Timeout: router.NoTimeout,
+	},
+	router.Route{
+		Name:     "PinGroupTemplateVersion",
+		Method:   http.MethodPut,
+		Pattern:  "/v1/tsg/groups/{identifier}/template",
+		Handler:  groups_v1.PinTemplateVersion,
+		Summary:  "Pin a group to a version of its template.",
+		Request:  groups_v1.TemplateVersionInput{},
+		Response: groups_v1.ServiceGroup{},
+	},
+	router.Route{
+		Name:     "PauseGroup",
+		Method:   http.MethodPost,
+		Pattern:  "/v1/tsg/groups/{identifier}/pause",
+		Handler:  groups_v1.Pause,
+		Summary:  "Pause the reconciles of a group.",
+		Response: groups_v1.ServiceGroup{},
+	},
+	router.Route{
+		Name:     "ResumeGroup",
+		Method:   http.MethodPost,
+		Pattern:  "/v1/tsg/groups/{identifier}/resume",
+		Handler:  groups_v1.Resume,
+		Summary:  "Resume the reconciles of a paused group.",
+		Response: groups_v1.ServiceGroup{},
+	},
+	router.Route{
+		Name:     "RestoreGroup",
+		Method:   http.MethodPost,
+		Pattern:  "/v1/tsg/groups/{identifier}/restore",
+		Handler:  groups_v1.Restore,
+		Summary:  "Undo the deletion of a group.",
+		Response: groups_v1.ServiceGroup{},
+	},
+	router.Route{
+		Name:     "ScaleGroup",
+		Method:   http.MethodPost,
+		Pattern:  "/v1/tsg/groups/{identifier}/scale",
+		Handler:  groups_v1.ScaleCapacity,
+		Summary:  "Set the capacity of a group.",
+		Request:  groups_v1.ScaleInput{},
+		Response: groups_v1.ScaleResult{},
+		Status:   http.StatusAccepted,
+	},
+	router.Route{
+		Name:     "IncrementGroupCapacity",
+		Method:   http.MethodPut,
+		Pattern:  "/v1/tsg/groups/{identifier}/increment",
+		Handler:  groups_v1.Increment,
+		Summary:  "Increase the capacity of a group.",
+		Request:  groups_v1.ActionableInput{},
+		Response: groups_v1.JobSubmissions{},
+		Status:   http.StatusAccepted,
+	},
+	router.Route{
+		Name:     "DecrementGroupCapacity",
+		Method:   http.MethodPut,
+		Pattern:  "/v1/tsg/groups/{identifier}/decrement",
+		Handler:  groups_v1.Decrement,
+		Summary:  "Decrease the capacity of a group.",
+		Request:  groups_v1.ActionableInput{},
+		Response: groups_v1.JobSubmissions{},
+		Status:   http.StatusAccepted,
+	},
+	router.Route{
+		Name:     "ListInstancesInGroup",
+		Method:   http.MethodGet,
+		Pattern:  "/v1/tsg/groups/{identifier}/instances",
+		Handler:  groups_v1.ListInstances,
+		Summary:  "List the instances of a group.",
+		Response: []*groups_v1.Instance{},
+	},
+	router.Route{
+		Name:     "ListGroupEvaluations",
+		Method:   http.MethodGet,
+		Pattern:  "/v1/tsg/groups/{identifier}/evaluations",
+		Handler:  groups_v1.ListEvaluations,
+		Summary:  "List the scheduling history of a group, newest first.",
+		Response: groups_v1.EvaluationPage{},
+	},
+	router.Route{
+		Name:     "GetGroupStatus",
+		Method:   http.MethodGet,
+		Pattern:  "/v1/tsg/groups/{identifier}/status",
+		Handler:  groups_v1.GetStatus,
+		Summary:  "Get the orchestration status of a group.",
+		Response: groups_v1.GroupStatus{},
 	},
 	router.Route{
-		Name:    "ListGroups",
-		Method:  http.MethodGet,
-		Pattern: "/v1/tsg/groups",
-		Handler: groups_v1.List,
+		Name:     "GetGroupJobStatus",
+		Method:   http.MethodGet,
+		Pattern:  "/v1/tsg/groups/{identifier}/job",
+		Handler:  groups_v1.GetJobStatus,
+		Summary:  "Get the status of the runs of the job of a group.",
+		Response: groups_v1.JobStatus{},
 	},
 	router.Route{
-		Name:    "IncrementGroupCapacity",
-		Method:  http.MethodPut,
-		Pattern: "/v1/tsg/groups/{identifier}/increment",
-		Handler: groups_v1.Increment,
+		Name:     "RenderGroupJob",
+		Method:   http.MethodPost,
+		Pattern:  "/v1/tsg/groups/{identifier}/render",
+		Handler:  groups_v1.Render,
+		Summary:  "Render the job of a group from a template version and capacity.",
+		Request:  groups_v1.RenderInput{},
+		Response: groups_v1.RenderedJob{},
+		Timeout:  renderTimeout,
 	},
 	router.Route{
-		Name:    "DecrementGroupCapacity",
-		Method:  http.MethodPut,
-		Pattern: "/v1/tsg/groups/{identifier}/decrement",
-		Handler: groups_v1.Decrement,
+		Name:     "GetGroupJobSpec",
+		Method:   http.MethodGet,
+		Pattern:  "/v1/tsg/groups/{identifier}/jobspec",
+		Handler:  groups_v1.JobSpec,
+		Summary:  "Get the jobspec of the current job of a group.",
+		Response: "",
+		Timeout:  renderTimeout,
 	},
 	router.Route{
-		Name:    "ListInstancesInGroup",
-		Method:  http.MethodGet,
-		Pattern: "/v1/tsg/groups/{identifier}/instances",
-		Handler: groups_v1.ListInstances,
+		Name:     "GetGroupSnapshot",
+		Method:   http.MethodGet,
+		Pattern:  "/v1/tsg/groups/{identifier}/snapshot",
+		Handler:  groups_v1.Snapshot,
+		Summary:  "Get the effective desired state of a group.",
+		Response: groups_v1.GroupSnapshot{},
+	},
+	router.Route{
+		Name:     "AdoptGroupJob",
+		Method:   http.MethodPost,
+		Pattern:  "/v1/tsg/groups/{identifier}/adopt",
+		Handler:  features.Require(features.Adopt, groups_v1.Adopt),
+		Summary:  "Adopt a job registered outside of the service as that of a group.",
+		Response: groups_v1.AdoptResult{},
+	},
+}
+
+var bundleRoutes = router.Routes{
+	router.Route{
+		Name:     "ExportAccount",
+		Method:   http.MethodGet,
+		Pattern:  "/v1/tsg/export",
+		Handler:  features.Require(features.Export, bundles_v1.Export),
+		Summary:  "Export the templates and groups of the account as a signed bundle.",
+		Response: bundles_v1.SignedBundle{},
+	},
+	router.Route{
+		Name:     "ImportAccount",
+		Method:   http.MethodPost,
+		Pattern:  "/v1/tsg/import",
+		Handler:  features.Require(features.Export, bundles_v1.Import),
+		Summary:  "Import the templates and groups of a signed bundle.",
+		Request:  bundles_v1.SignedBundle{},
+		Response: bundles_v1.ImportResult{},
+		// Bounded by the number of groups of the bundle instead, each
+		// submitted with the usual retries of calls to Nomad.
+		Timeout: router.NoTimeout,
+	},
+}
+
+var accountRoutes = router.Routes{
+	router.Route{
+		Name:     "GetAccount",
+		Method:   http.MethodGet,
+		Pattern:  "/v1/tsg/account",
+		Handler:  account_v1.Get,
+		Summary:  "Get the account.",
+		Response: account_v1.Account{},
+	},
+	router.Route{
+		Name:     "UpdateAccount",
+		Method:   http.MethodPut,
+		Pattern:  "/v1/tsg/account",
+		Handler:  account_v1.Update,
+		Summary:  "Update the settings of the account.",
+		Request:  account_v1.AccountInput{},
+		Response: account_v1.Account{},
+	},
+	router.Route{
+		Name:     "RotateAccountKey",
+		Method:   http.MethodPost,
+		Pattern:  "/v1/tsg/account/key/rotate",
+		Handler:  account_v1.RotateKey,
+		Summary:  "Rotate the key the service signs requests to Triton with.",
+		Response: account_v1.KeyRotation{},
+	},
+}
+
+var auditRoutes = router.Routes{
+	router.Route{
+		Name:     "ListAuditLog",
+		Method:   http.MethodGet,
+		Pattern:  "/v1/tsg/audit",
+		Handler:  audit.List,
+		Summary:  "List a page of the audit log of the account.",
+		Response: audit.Page{},
 	},
 }
 
 var RoutingTable = router.RouteTable{
+	accountRoutes,
+	auditRoutes,
 	templateRoutes,
 	groupRoutes,
+	bundleRoutes,
 }
@@ -7,15 +7,20 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"sync/atomic"
 	"time"
 
-	ghandlers "github.com/gorilla/handlers"
 	nomad "github.com/hashicorp/nomad/api"
 	"github.com/jackc/pgx"
 	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/health"
+	"github.com/joyent/triton-service-groups/ratelimit"
 	"github.com/joyent/triton-service-groups/server/handlers"
 	"github.com/joyent/triton-service-groups/server/handlers/auth"
+	"github.com/joyent/triton-service-groups/server/openapi"
 	"github.com/joyent/triton-service-groups/server/router"
+	"github.com/joyent/triton-service-groups/telemetry"
+	"github.com/joyent/triton-service-groups/warnings"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
 )
@@ -32,88 +37,180 @@ type HTTPServer struct {
 	logger     zerolog.Logger
 	pool       *pgx.ConnPool
 	nomad      *nomad.Client
+	dcs        handlers.Datacenters
 	authConfig auth.Config
+	ready      handlers.ReadyConfig
+	rateLimit  config.RateLimit
+	// compressionMinSize is the smallest response body which is gzipped.
+	compressionMinSize int
+	// requestTimeout is the default timeout of requests to the API.
+	requestTimeout time.Duration
+	// corsAllowedOrigins are the origins allowed to call the API from
+	// browsers.
+	corsAllowedOrigins []string
+
+	// conns counts the connections which are open.
+	conns int64
 
 	http.Server
 }
 
-func New(cfg config.HTTPServer, pool *pgx.ConnPool, nomad *nomad.Client) *HTTPServer {
+func New(cfg config.HTTPServer, pool *pgx.ConnPool, nomad *nomad.Client, dcs handlers.Datacenters) *HTTPServer {
 	log.Debug().Msg("http: creating new HTTP server")
 	addr := fmt.Sprintf("%s:%d", cfg.Bind, cfg.Port)
 
 	authConfig := auth.Config{
-		Datacenter:      cfg.DC,
-		TritonURL:       cfg.TritonURL,
-		AuthURL:         cfg.AuthURL,
-		KeyNamePrefix:   cfg.KeyNamePrefix,
-		EnableWhitelist: cfg.EnableWhitelist,
+		Datacenter:       cfg.DC,
+		TritonURL:        cfg.TritonURL,
+		NomadNamespace:   config.GetNomadNamespace(),
+		NomadRegion:      config.GetNomadRegion(),
+		NomadDatacenters: config.GetNomadDatacenters(),
+		AuthURL:          cfg.AuthURL,
+		KeyNamePrefix:    cfg.KeyNamePrefix,
+		EnableWhitelist:  cfg.EnableWhitelist,
 	}
 
+	health.Reconciles.SetWindow(cfg.ReadyFailureWindow)
+
 	return &HTTPServer{
 		Addr:       addr,
 		Bind:       cfg.Bind,
 		Port:       cfg.Port,
 		logger:     cfg.Logger,
 		authConfig: authConfig,
-		pool:       pool,
-		nomad:      nomad,
+		ready: handlers.ReadyConfig{
+			FailureThreshold: cfg.ReadyFailureThreshold,
+			MinSamples:       cfg.ReadyMinSamples,
+		},
+		rateLimit:          cfg.RateLimit,
+		compressionMinSize: cfg.CompressionMinSize,
+		requestTimeout:     cfg.RequestTimeout,
+		corsAllowedOrigins: cfg.CORSAllowedOrigins,
+		pool:               pool,
+		nomad:              nomad,
+		dcs:                dcs,
 	}
 }
 
-func (srv *HTTPServer) Start() {
+// Start listens at the server's address and serves requests in the
+// background. The returned channel receives the error which stopped the
+// server, either because it couldn't listen or because serving failed, but
+// never after Stop.
+func (srv *HTTPServer) Start() <-chan error {
 	log.Debug().Msg("http: starting up HTTP server")
 
 	srv.setup()
-}
 
-func (srv *HTTPServer) setup() {
-	log.Debug().Msg("http: mounting routes as endpoints")
+	errCh := make(chan error, 1)
 
-	router := router.WithRoutes(RoutingTable)
-
-	authHandler := handlers.AuthHandler(srv.pool, srv.authConfig, router)
-	contextHandler := handlers.ContextHandler(srv.pool, srv.nomad, authHandler)
-	srv.Handler = ghandlers.LoggingHandler(srv.logger, contextHandler)
-
-	ln := srv.listenWithRetry()
+	ln, err := srv.listenWithRetry()
+	if err != nil {
+		errCh <- err
+		return errCh
+	}
 
 	go func() {
 		log.Info().Msgf("http: started serving at %q", srv.Addr)
-		err := srv.Serve(ln)
-		if err != nil {
-			log.Warn().Err(err)
+		if err := srv.Serve(ln); err != nil && err != http.ErrServerClosed {
+			errCh <- err
 		}
 	}()
+
+	return errCh
+}
+
+func (srv *HTTPServer) setup() {
+	log.Debug().Msg("http: mounting routes as endpoints")
+
+	apiRouter := router.WithRoutes(RoutingTable, srv.requestTimeout)
+
+	rl := srv.rateLimit
+	accountLimiter := ratelimit.New(rl.AccountRate, rl.AccountBurst, rl.CleanupInterval)
+	unauthLimiter := ratelimit.New(rl.UnauthenticatedRate, rl.UnauthenticatedBurst, rl.CleanupInterval)
+
+	authHandler := handlers.AuthHandler(srv.pool, srv.authConfig,
+		ratelimit.AccountHandler(accountLimiter, apiRouter))
+	contextHandler := handlers.ContextHandler(srv.pool, srv.nomad, srv.dcs,
+		ratelimit.UnauthenticatedHandler(unauthLimiter, authHandler))
+
+	// NOTE: Probes and metrics are served ahead of authentication so that
+	// load balancers, schedulers and Prometheus can reach them.
+	mux := http.NewServeMux()
+	mux.Handle("/healthz", handlers.HealthHandler())
+	mux.Handle("/readyz", handlers.ReadyHandler(srv.pingDB, health.Reconciles, srv.ready))
+	mux.Handle("/slo", handlers.SLOHandler(health.Convergences))
+	mux.Handle("/metrics", telemetry.Metrics)
+	// NOTE: The API's document is public too, so that clients can be
+	// generated from it without an account.
+	mux.Handle(openapi.Path, openapi.Handler(openapi.New(RoutingTable)))
+	// NOTE: Preflight requests are answered ahead of authentication, as
+	// browsers send them without credentials.
+	mux.Handle("/", router.CORSHandler(srv.corsAllowedOrigins,
+		router.CompressionHandler(srv.compressionMinSize, warnings.Handler(contextHandler))))
+
+	srv.Handler = handlers.RequestIDHandler(handlers.LoggingHandler(srv.logger, mux))
+	srv.ConnState = srv.trackConn
 }
 
-// listenWithRetry attempts to listen on our socket, failing after 10 seconds.
-func (srv *HTTPServer) listenWithRetry() net.Listener {
+// trackConn counts connections as they're opened and closed.
+func (srv *HTTPServer) trackConn(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		atomic.AddInt64(&srv.conns, 1)
+	case http.StateHijacked, http.StateClosed:
+		atomic.AddInt64(&srv.conns, -1)
+	}
+}
+
+// pingDB checks that the database is reachable.
+func (srv *HTTPServer) pingDB(ctx context.Context) error {
+	_, err := srv.pool.ExecEx(ctx, "SELECT 1", nil)
+	return err
+}
+
+// listenAttempts is how many times, a second apart, listening is attempted.
+var listenAttempts = 10
+
+// listenWithRetry attempts to listen on our socket, failing after
+// listenAttempts seconds.
+func (srv *HTTPServer) listenWithRetry() (net.Listener, error) {
 	var (
 		err error
 		ln  net.Listener
 	)
 
-	for i := 0; i < 10; i++ {
+	for i := 0; i < listenAttempts; i++ {
+		if i > 0 {
+			time.Sleep(time.Second)
+		}
+
 		ln, err = net.Listen("tcp", srv.Addr)
 		if err == nil {
 			log.Debug().
 				Str("http-bind", srv.Bind).
 				Int("http-port", int(srv.Port)).
 				Msgf("http: server listening at %q", srv.Addr)
-			return ln
+			return ln, nil
 		}
-
-		time.Sleep(time.Second)
 	}
-	return nil
+	return nil, fmt.Errorf("http: unable to listen at %q: %v", srv.Addr, err)
 }
 
-// Stop handles gracefully shutting down the server, finally forcing shutdown
-// after 3 seconds.
+// Stop handles gracefully shutting down the server, draining in-flight
+// requests until ctx is done and then forcing the connections still open
+// closed.
 func (srv *HTTPServer) Stop(ctx context.Context) error {
 	log.Debug().Msg("http: gracefully shutting down HTTP server")
 
 	if err := srv.Shutdown(ctx); err != nil {
+		log.Warn().
+			Err(err).
+			Int64("connections", atomic.LoadInt64(&srv.conns)).
+			Msg("http: timed out draining connections, closing them")
+
+		if closeErr := srv.Close(); closeErr != nil {
+			return closeErr
+		}
 		return err
 	}
 	return nil
@@ -0,0 +1,118 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/joyent/triton-service-groups/server/openapi"
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStartListenError(t *testing.T) {
+	defer func(attempts int) { listenAttempts = attempts }(listenAttempts)
+	listenAttempts = 1
+
+	taken, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer taken.Close()
+	port := taken.Addr().(*net.TCPAddr).Port
+
+	srv := New(config.HTTPServer{Bind: "127.0.0.1", Port: uint16(port)}, nil, nil, nil)
+
+	select {
+	case err := <-srv.Start():
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unable to listen")
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the server to fail to listen")
+	}
+}
+
+func TestStartStop(t *testing.T) {
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := free.Addr().(*net.TCPAddr).Port
+	require.NoError(t, free.Close())
+
+	cfg := config.HTTPServer{Bind: "127.0.0.1", Port: uint16(port), Logger: zerolog.Nop()}
+	srv := New(cfg, nil, nil, nil)
+	errCh := srv.Start()
+
+	resp, err := http.Get("http://" + srv.Addr + "/healthz")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	require.NoError(t, srv.Stop(context.Background()))
+
+	// Stopping isn't reported as a failure.
+	select {
+	case err := <-errCh:
+		t.Fatalf("unexpected server error: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestStopTimeout(t *testing.T) {
+	free, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := free.Addr().(*net.TCPAddr).Port
+	require.NoError(t, free.Close())
+
+	cfg := config.HTTPServer{Bind: "127.0.0.1", Port: uint16(port), Logger: zerolog.Nop()}
+	srv := New(cfg, nil, nil, nil)
+	srv.Start()
+
+	// A connection which hasn't sent a request yet keeps shutdown waiting.
+	conn, err := net.Dial("tcp", srv.Addr)
+	require.NoError(t, err)
+	defer conn.Close()
+	for i := 0; atomic.LoadInt64(&srv.conns) != 1; i++ {
+		require.True(t, i < 100, "connection wasn't tracked")
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, srv.Stop(ctx))
+
+	// The connection was closed rather than left to drain.
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	_, err = conn.Read(make([]byte, 1))
+	assert.Error(t, err)
+}
+
+func TestOpenAPIDocument(t *testing.T) {
+	srv := New(config.HTTPServer{Logger: zerolog.Nop()}, nil, nil, nil)
+	srv.setup()
+
+	// The document is served without authentication.
+	w := httptest.NewRecorder()
+	srv.Handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, openapi.Path, nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var doc openapi.Document
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+
+	for _, routes := range RoutingTable {
+		for _, route := range routes {
+			assert.NotEmpty(t, route.Summary, route.Name)
+
+			op := doc.Paths[route.Pattern][strings.ToLower(route.Method)]
+			if assert.NotNil(t, op, route.Name) {
+				assert.Equal(t, route.Name, op.OperationID)
+			}
+		}
+	}
+	assert.Contains(t, doc.Components.Schemas, "ServiceGroup")
+	assert.Contains(t, doc.Components.Schemas, "InstanceTemplate")
+}
@@ -0,0 +1,225 @@
+package telemetry
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	metrics "github.com/armon/go-metrics"
+)
+
+// DefaultBuckets are the upper bounds, in seconds, of the histograms samples
+// are collected into. They span quick Nomad calls through to groups which
+// take half an hour to converge.
+var DefaultBuckets = []float64{
+	0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10,
+	30, 60, 120, 300, 600, 1800,
+}
+
+const (
+	typeCounter   = "counter"
+	typeGauge     = "gauge"
+	typeHistogram = "histogram"
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_:]`)
+
+// PrometheusSink is a go-metrics sink which keeps the current value of every
+// metric and serves them in the Prometheus text format. Counters accumulate,
+// gauges keep the last value set and samples are collected into histograms.
+type PrometheusSink struct {
+	mu       sync.Mutex
+	buckets  []float64
+	families map[string]*family
+}
+
+// family is every series of a single metric, by their rendered labels.
+type family struct {
+	typ    string
+	series map[string]*series
+}
+
+type series struct {
+	labels []metrics.Label
+	value  float64
+	// counts are the number of samples in each bucket, not cumulative.
+	counts []uint64
+	count  uint64
+}
+
+// NewPrometheusSink returns an empty sink which collects samples into
+// histograms with the given bucket upper bounds.
+func NewPrometheusSink(buckets []float64) *PrometheusSink {
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	return &PrometheusSink{
+		buckets:  sorted,
+		families: make(map[string]*family),
+	}
+}
+
+func (s *PrometheusSink) SetGauge(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+func (s *PrometheusSink) SetGaugeWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.update(typeGauge, key, labels, func(m *series) {
+		m.value = float64(val)
+	})
+}
+
+// EmitKey is kept as a gauge, since Prometheus has no notion of events.
+func (s *PrometheusSink) EmitKey(key []string, val float32) {
+	s.SetGaugeWithLabels(key, val, nil)
+}
+
+func (s *PrometheusSink) IncrCounter(key []string, val float32) {
+	s.IncrCounterWithLabels(key, val, nil)
+}
+
+func (s *PrometheusSink) IncrCounterWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.update(typeCounter, key, labels, func(m *series) {
+		m.value += float64(val)
+	})
+}
+
+func (s *PrometheusSink) AddSample(key []string, val float32) {
+	s.AddSampleWithLabels(key, val, nil)
+}
+
+func (s *PrometheusSink) AddSampleWithLabels(key []string, val float32, labels []metrics.Label) {
+	s.update(typeHistogram, key, labels, func(m *series) {
+		if m.counts == nil {
+			m.counts = make([]uint64, len(s.buckets))
+		}
+		for i, bound := range s.buckets {
+			if float64(val) <= bound {
+				m.counts[i]++
+				break
+			}
+		}
+		m.value += float64(val)
+		m.count++
+	})
+}
+
+// update applies fn to the series of key with labels, creating it if need be.
+// A metric used as more than one type keeps the first, and the rest of its
+// updates are dropped.
+func (s *PrometheusSink) update(typ string, key []string, labels []metrics.Label, fn func(*series)) {
+	name := metricName(key)
+	labels = sortLabels(labels)
+	id := formatLabels(labels)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	f, ok := s.families[name]
+	if !ok {
+		f = &family{typ: typ, series: make(map[string]*series)}
+		s.families[name] = f
+	}
+	if f.typ != typ {
+		return
+	}
+
+	m, ok := f.series[id]
+	if !ok {
+		m = &series{labels: labels}
+		f.series[id] = m
+	}
+	fn(m)
+}
+
+// ServeHTTP writes every metric in the Prometheus text format, sorted by name
+// and labels so that scrapes are stable.
+func (s *PrometheusSink) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	s.write(&buf)
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(buf.Bytes())
+}
+
+func (s *PrometheusSink) write(buf *bytes.Buffer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names := make([]string, 0, len(s.families))
+	for name := range s.families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := s.families[name]
+		fmt.Fprintf(buf, "# TYPE %s %s\n", name, f.typ)
+
+		ids := make([]string, 0, len(f.series))
+		for id := range f.series {
+			ids = append(ids, id)
+		}
+		sort.Strings(ids)
+
+		for _, id := range ids {
+			m := f.series[id]
+			if f.typ != typeHistogram {
+				fmt.Fprintf(buf, "%s%s %s\n", name, id, formatFloat(m.value))
+				continue
+			}
+
+			var cumulative uint64
+			for i, bound := range s.buckets {
+				cumulative += m.counts[i]
+				le := metrics.Label{Name: "le", Value: formatFloat(bound)}
+				fmt.Fprintf(buf, "%s_bucket%s %d\n", name, formatLabels(append(m.labels, le)), cumulative)
+			}
+			inf := metrics.Label{Name: "le", Value: "+Inf"}
+			fmt.Fprintf(buf, "%s_bucket%s %d\n", name, formatLabels(append(m.labels, inf)), m.count)
+			fmt.Fprintf(buf, "%s_sum%s %s\n", name, id, formatFloat(m.value))
+			fmt.Fprintf(buf, "%s_count%s %d\n", name, id, m.count)
+		}
+	}
+}
+
+// metricName joins the parts of a go-metrics key into a valid Prometheus
+// metric name.
+func metricName(key []string) string {
+	return invalidNameChars.ReplaceAllString(strings.Join(key, "_"), "_")
+}
+
+func sortLabels(labels []metrics.Label) []metrics.Label {
+	sorted := make([]metrics.Label, len(labels))
+	for i, label := range labels {
+		sorted[i] = metrics.Label{
+			Name:  invalidNameChars.ReplaceAllString(label.Name, "_"),
+			Value: label.Value,
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+	return sorted
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatLabels(labels []metrics.Label) string {
+	if len(labels) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(labels))
+	for i, label := range labels {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, label.Name, labelValueEscaper.Replace(label.Value))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
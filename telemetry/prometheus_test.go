@@ -0,0 +1,72 @@
+package telemetry
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	metrics "github.com/armon/go-metrics"
+	"github.com/stretchr/testify/assert"
+)
+
+func scrape(sink *PrometheusSink) (*httptest.ResponseRecorder, string) {
+	w := httptest.NewRecorder()
+	sink.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	return w, w.Body.String()
+}
+
+func TestPrometheusSink(t *testing.T) {
+	sink := NewPrometheusSink([]float64{1, 0.5})
+
+	sink.IncrCounterWithLabels([]string{"tsg", "orchestrator", "jobs_total"}, 1, []metrics.Label{
+		{Name: "result", Value: "success"},
+		{Name: "op", Value: "submit"},
+	})
+	sink.IncrCounterWithLabels([]string{"tsg", "orchestrator", "jobs_total"}, 2, []metrics.Label{
+		{Name: "op", Value: "submit"},
+		{Name: "result", Value: "success"},
+	})
+	sink.IncrCounterWithLabels([]string{"tsg", "orchestrator", "jobs_total"}, 1, []metrics.Label{
+		{Name: "op", Value: "delete"},
+		{Name: "result", Value: "failure"},
+	})
+	sink.SetGauge([]string{"tsg", "groups", "tracked"}, 3)
+	sink.SetGauge([]string{"tsg", "groups", "tracked"}, 5)
+	sink.AddSample([]string{"tsg", "nomad", "request_duration_seconds"}, 0.25)
+	sink.AddSample([]string{"tsg", "nomad", "request_duration_seconds"}, 0.75)
+	sink.AddSample([]string{"tsg", "nomad", "request_duration_seconds"}, 4)
+
+	// A metric keeps the type it was first recorded as.
+	sink.SetGauge([]string{"tsg", "orchestrator", "jobs_total"}, 100)
+
+	w, body := scrape(sink)
+	assert.Equal(t, "text/plain; version=0.0.4; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, `# TYPE tsg_groups_tracked gauge
+tsg_groups_tracked 5
+# TYPE tsg_nomad_request_duration_seconds histogram
+tsg_nomad_request_duration_seconds_bucket{le="0.5"} 1
+tsg_nomad_request_duration_seconds_bucket{le="1"} 2
+tsg_nomad_request_duration_seconds_bucket{le="+Inf"} 3
+tsg_nomad_request_duration_seconds_sum 5
+tsg_nomad_request_duration_seconds_count 3
+# TYPE tsg_orchestrator_jobs_total counter
+tsg_orchestrator_jobs_total{op="delete",result="failure"} 1
+tsg_orchestrator_jobs_total{op="submit",result="success"} 3
+`, body)
+}
+
+func TestPrometheusSinkNames(t *testing.T) {
+	sink := NewPrometheusSink(nil)
+
+	sink.IncrCounter([]string{"triton", "image-cache", "hit"}, 1)
+	sink.SetGaugeWithLabels([]string{"tsg", "up"}, 1, []metrics.Label{
+		{Name: "data-center", Value: "us \"east\"\n1"},
+	})
+
+	_, body := scrape(sink)
+	assert.Equal(t, `# TYPE triton_image_cache_hit counter
+triton_image_cache_hit 1
+# TYPE tsg_up gauge
+tsg_up{data_center="us \"east\"\n1"} 1
+`, body)
+}
@@ -0,0 +1,23 @@
+package telemetry
+
+import (
+	metrics "github.com/armon/go-metrics"
+)
+
+// ServiceName prefixes the name of every metric.
+const ServiceName = "tsg"
+
+// Metrics collects the metrics recorded by this process once Start is called,
+// and serves them to Prometheus.
+var Metrics = NewPrometheusSink(DefaultBuckets)
+
+// Start routes the metrics recorded through go-metrics into Metrics. Until
+// it's called they're discarded.
+func Start() error {
+	cfg := metrics.DefaultConfig(ServiceName)
+	cfg.EnableHostname = false
+	cfg.EnableRuntimeMetrics = false
+
+	_, err := metrics.NewGlobal(cfg, Metrics)
+	return err
+}
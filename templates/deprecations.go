@@ -0,0 +1,49 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package templates_v1
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/joyent/triton-service-groups/warnings"
+)
+
+type deprecation struct {
+	// Field is the JSON name of the deprecated field.
+	Field string
+	// Replacement is the JSON name of the field to use instead, if any.
+	Replacement string
+	isSet       func(t *InstanceTemplate) bool
+}
+
+func (d deprecation) message() string {
+	if d.Replacement == "" {
+		return fmt.Sprintf("template field %s is deprecated", d.Field)
+	}
+	return fmt.Sprintf("template field %s is deprecated, use %s instead", d.Field, d.Replacement)
+}
+
+// deprecations lists every deprecated template field. Add an entry when a
+// field is superseded, and remove it once the field itself is removed.
+var deprecations []deprecation
+
+// WarnDeprecated adds a warning to the current request for each deprecated
+// field set on t.
+func WarnDeprecated(ctx context.Context, t *InstanceTemplate) {
+	if t == nil {
+		return
+	}
+
+	for _, d := range deprecations {
+		if d.isSet(t) {
+			warnings.Add(ctx, warnings.Warning{
+				Code: warnings.CodeMiscellaneous,
+				Text: d.message(),
+			})
+		}
+	}
+}
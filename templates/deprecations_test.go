@@ -0,0 +1,32 @@
+package templates_v1
+
+import (
+	"context"
+	"testing"
+
+	"github.com/joyent/triton-service-groups/warnings"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWarnDeprecated(t *testing.T) {
+	defer func(d []deprecation) { deprecations = d }(deprecations)
+	deprecations = append(deprecations, deprecation{
+		Field:       "userdata",
+		Replacement: "metadata",
+		isSet:       func(t *InstanceTemplate) bool { return t.UserData != "" },
+	})
+
+	ctx := warnings.WithCollector(context.Background())
+
+	WarnDeprecated(ctx, &InstanceTemplate{})
+	assert.Empty(t, warnings.Get(ctx))
+
+	WarnDeprecated(ctx, &InstanceTemplate{UserData: "#!/bin/sh"})
+	got := warnings.Get(ctx)
+	require.Len(t, got, 1)
+	assert.Equal(t, warnings.Warning{
+		Code: warnings.CodeMiscellaneous,
+		Text: "template field userdata is deprecated, use metadata instead",
+	}, got[0])
+}
@@ -6,32 +6,47 @@
 package templates_v1
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
 	"path"
+	"strings"
 	"time"
 
 	"errors"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/mux"
+	"github.com/joyent/triton-service-groups/audit"
+	"github.com/joyent/triton-service-groups/names"
 	"github.com/joyent/triton-service-groups/server/handlers"
 	"github.com/rs/zerolog/log"
 )
 
 type InstanceTemplate struct {
-	ID              string            `json:"id"`
-	TemplateName    string            `json:"template_name"`
-	Package         string            `json:"package"`
-	ImageID         string            `json:"image_id"`
-	FirewallEnabled bool              `json:"firewall_enabled"`
-	Networks        []string          `json:"networks"`
-	UserData        string            `json:"userdata"`
-	MetaData        map[string]string `json:"metadata"`
-	Tags            map[string]string `json:"tags"`
-	CreatedAt       time.Time         `json:"created_at"`
+	ID              string `json:"id"`
+	TemplateName    string `json:"template_name"`
+	Package         string `json:"package"`
+	ImageID         string `json:"image_id"`
+	FirewallEnabled bool   `json:"firewall_enabled"`
+	// FirewallRules are Triton firewall rules created for the instances of
+	// groups of the template, in the CloudAPI rule syntax.
+	FirewallRules []string          `json:"firewall_rules,omitempty"`
+	Networks      []string          `json:"networks"`
+	UserData      string            `json:"userdata"`
+	MetaData      map[string]string `json:"metadata"`
+	Tags          map[string]string `json:"tags"`
+	// TaskCPU and TaskMemoryMB optionally reserve more resources for the
+	// scheduler task which scales groups of the template, in MHz and MB.
+	TaskCPU      int `json:"task_cpu,omitempty"`
+	TaskMemoryMB int `json:"task_memory_mb,omitempty"`
+	// Version counts the revisions of the template, starting at 1. Each
+	// revision is immutable, and Superseded once the template is revised.
+	Version    int       `json:"version"`
+	Superseded bool      `json:"superseded,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
 }
 
 func (t *InstanceTemplate) ShortID() string {
@@ -81,6 +96,16 @@ func Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if err := names.Validate("template", template.TemplateName); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := CheckRequiredTags(template); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
 	templateExists, err := CheckTemplateExistsByName(ctx, template.TemplateName, session.AccountID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -93,17 +118,22 @@ func Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	WarnDeprecated(ctx, template)
+
 	err = SaveTemplate(ctx, session.AccountID, template)
 	if err != nil {
+		auditTemplate(ctx, "create", template, err)
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
 	com, ok := FindTemplateByName(ctx, template.TemplateName, session.AccountID)
 	if !ok {
+		auditTemplate(ctx, "create", template, nil)
 		http.NotFound(w, r)
 		return
 	}
+	auditTemplate(ctx, "create", com, nil)
 
 	bytes, err := json.Marshal(com)
 	if err != nil {
@@ -115,6 +145,102 @@ func Create(w http.ResponseWriter, r *http.Request) {
 	writeJSONResponse(w, bytes, http.StatusCreated)
 }
 
+// Update revises a template, saving the request body as its next version.
+// The revision being replaced is kept, so groups pinned to it render it as
+// they did until they're moved to the new version.
+func Update(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	vars := mux.Vars(r)
+	uuid := vars["identifier"]
+
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	template, err := decodeResponseBodyAndValidate(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	if err := CheckRequiredTags(template); err != nil {
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	current, ok := FindTemplateByID(ctx, uuid, session.AccountID)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	if template.TemplateName != "" && template.TemplateName != current.TemplateName {
+		http.Error(w, fmt.Sprintf("The template name %q does not match "+
+			"the name on the record.", template.TemplateName),
+			http.StatusBadRequest)
+		return
+	}
+
+	WarnDeprecated(ctx, template)
+
+	err = ReviseTemplate(ctx, session.AccountID, current, template)
+	auditTemplate(ctx, "update", current, err)
+	if err == ErrTemplateSuperseded {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	com, ok := FindTemplateByName(ctx, current.TemplateName, session.AccountID)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	bytes, err := json.Marshal(com)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Location", path.Join(path.Dir(r.URL.Path), com.ID))
+	writeJSONResponse(w, bytes, http.StatusCreated)
+}
+
+// ListVersions lists every revision of a template, oldest first.
+func ListVersions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	session := handlers.GetAuthSession(ctx)
+
+	vars := mux.Vars(r)
+	uuid := vars["identifier"]
+
+	versions, err := FindTemplateVersions(ctx, uuid, session.AccountID)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if len(versions) == 0 {
+		http.NotFound(w, r)
+		return
+	}
+
+	bytes, err := json.Marshal(versions)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, bytes, http.StatusOK)
+}
+
 func Delete(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	session := handlers.GetAuthSession(ctx)
@@ -143,6 +269,7 @@ func Delete(w http.ResponseWriter, r *http.Request) {
 	}
 
 	err = RemoveTemplate(ctx, template.ID, session.AccountID)
+	auditTemplate(ctx, "delete", template, err)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -155,24 +282,35 @@ func List(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	session := handlers.GetAuthSession(ctx)
 
-	rows, err := FindTemplates(ctx, session.AccountID)
-	if err != nil {
-		http.NotFound(w, r)
-		return
-	}
+	enc := handlers.NewArrayEncoder(w)
 
-	if rows == nil || len(rows) == 0 {
-		writeJSONResponse(w, []byte("[]"), http.StatusOK)
+	err := EachTemplate(ctx, session.AccountID, func(template *InstanceTemplate) error {
+		return enc.Encode(template)
+	})
+	if err != nil {
+		if !enc.Started() {
+			http.NotFound(w, r)
+			return
+		}
+		// The array is left unterminated so clients see the truncated
+		// response is invalid rather than mistaking it for a complete list.
+		log.Printf("failed to stream templates: %v", err)
 		return
 	}
 
-	bytes, err := json.Marshal(rows)
-	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return
+	if err := enc.Close(); err != nil {
+		log.Printf("%v", err)
 	}
+}
 
-	writeJSONResponse(w, bytes, http.StatusOK)
+// auditTemplate records a change to a template in the audit log.
+func auditTemplate(ctx context.Context, action string, template *InstanceTemplate, err error) {
+	audit.Record(ctx, audit.Entry{
+		Action:     action,
+		TargetType: audit.TargetTemplate,
+		TargetID:   template.ID,
+		TargetName: template.TemplateName,
+	}, err)
 }
 
 func writeJSONResponse(w http.ResponseWriter, bytes []byte, statusCode int) {
@@ -192,18 +330,47 @@ func decodeResponseBodyAndValidate(body []byte) (*InstanceTemplate, error) {
 		return nil, errors.New("error in unmarshal request body")
 	}
 
+	if err := Validate(template); err != nil {
+		return nil, err
+	}
+
+	return template, nil
+}
+
+// Validate checks the fields of template which are required to be well
+// formed before it's saved.
+func Validate(template *InstanceTemplate) error {
 	if !isValidUUID(template.Package) {
-		return nil, errors.New("package must be a valid UUID")
+		return errors.New("package must be a valid UUID")
 	}
 
 	if !isValidUUID(template.ImageID) {
-		return nil, errors.New("imageID must be a valid UUID")
+		return errors.New("imageID must be a valid UUID")
 	}
 
-	return template, nil
+	if template.TaskCPU < 0 {
+		return errors.New("task_cpu must be a positive integer")
+	}
+
+	if template.TaskMemoryMB < 0 {
+		return errors.New("task_memory_mb must be a positive integer")
+	}
+
+	for _, rule := range template.FirewallRules {
+		if strings.TrimSpace(rule) == "" {
+			return errors.New("firewall_rules must not contain empty rules")
+		}
+	}
+
+	return nil
 }
 
+// isValidUUID reports whether u is a Triton UUID. Triton doesn't accept the
+// URNs uuid.Parse also does.
 func isValidUUID(u string) bool {
+	if len(u) != 36 {
+		return false
+	}
 	_, err := uuid.Parse(u)
 	return err == nil
 }
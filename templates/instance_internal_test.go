@@ -0,0 +1,46 @@
+package templates_v1
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDecodeFirewallRules(t *testing.T) {
+	const body = `{
+	"template_name": "web",
+	"package": "5c8a2a0e-3b3a-4b0e-8d7c-6c1a3b1a6c1f",
+	"image_id": "342045ce-6af1-4adf-9ef1-e5bfaf9de28c",
+	"firewall_enabled": true,
+	"firewall_rules": %s
+}`
+
+	template, err := decodeResponseBodyAndValidate([]byte(fmt.Sprintf(body, `["FROM any TO all vms ALLOW tcp PORT 80"]`)))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"FROM any TO all vms ALLOW tcp PORT 80"}, template.FirewallRules)
+
+	for _, rules := range []string{`[""]`, `["FROM any TO all vms ALLOW tcp PORT 80", "  "]`} {
+		_, err := decodeResponseBodyAndValidate([]byte(fmt.Sprintf(body, rules)))
+		assert.EqualError(t, err, "firewall_rules must not contain empty rules", "rules %s", rules)
+	}
+}
+
+func TestConvertRulesJson(t *testing.T) {
+	data, err := convertRulesToJson(nil)
+	require.NoError(t, err)
+	assert.Empty(t, data)
+
+	rules, err := convertRulesFromJson(data)
+	require.NoError(t, err)
+	assert.Nil(t, rules)
+
+	expected := []string{`FROM any TO tag "role" = "web" ALLOW tcp PORT 443`}
+	data, err = convertRulesToJson(expected)
+	require.NoError(t, err)
+
+	rules, err = convertRulesFromJson(data)
+	require.NoError(t, err)
+	assert.Equal(t, expected, rules)
+}
@@ -34,6 +34,10 @@ func TestShortID(t *testing.T) {
 	assert.Equal(t, "f5435e8b", tmpl.ShortID())
 }
 
+func TestAPITypesSnakeCase(t *testing.T) {
+	testutils.AssertSnakeCaseJSON(t, templates_v1.InstanceTemplate{})
+}
+
 // TODO: We should refactor how/where our database initializes so we can half
 // bootstrap the application from our tests with a simple one-liner.
 func initDB() (*pgx.ConnPool, error) {
@@ -79,9 +83,9 @@ func TestAcc_Get(t *testing.T) {
 		AuthURL:    authURL,
 	}
 
-	router := router.WithRoutes(server.RoutingTable)
+	router := router.WithRoutes(server.RoutingTable, 0)
 	authHandler := handlers.AuthHandler(pool, authConfig, router)
-	contextHandler := handlers.ContextHandler(pool, nomad, authHandler)
+	contextHandler := handlers.ContextHandler(pool, nomad, nil, authHandler)
 
 	req := httptest.NewRequest("GET", "http://example.com/v1/tsg/templates/319209784155176962", nil)
 	recorder := httptest.NewRecorder()
@@ -119,9 +123,9 @@ func TestAcc_GetIncorrectTemplateName(t *testing.T) {
 		AuthURL:    authURL,
 	}
 
-	router := router.WithRoutes(server.RoutingTable)
+	router := router.WithRoutes(server.RoutingTable, 0)
 	authHandler := handlers.AuthHandler(pool, authConfig, router)
-	contextHandler := handlers.ContextHandler(pool, nomad, authHandler)
+	contextHandler := handlers.ContextHandler(pool, nomad, nil, authHandler)
 
 	req := httptest.NewRequest("GET", "http://example.com/v1/tsg/templates/12345", nil)
 	recorder := httptest.NewRecorder()
@@ -155,9 +159,9 @@ func TestAcc_List(t *testing.T) {
 		AuthURL:    authURL,
 	}
 
-	router := router.WithRoutes(server.RoutingTable)
+	router := router.WithRoutes(server.RoutingTable, 0)
 	authHandler := handlers.AuthHandler(pool, authConfig, router)
-	contextHandler := handlers.ContextHandler(pool, nomad, authHandler)
+	contextHandler := handlers.ContextHandler(pool, nomad, nil, authHandler)
 
 	req := httptest.NewRequest("GET", "http://example.com/v1/tsg/templates", nil)
 	recorder := httptest.NewRecorder()
@@ -196,9 +200,9 @@ func TestAcc_Delete(t *testing.T) {
 		AuthURL:    authURL,
 	}
 
-	router := router.WithRoutes(server.RoutingTable)
+	router := router.WithRoutes(server.RoutingTable, 0)
 	authHandler := handlers.AuthHandler(pool, authConfig, router)
-	contextHandler := handlers.ContextHandler(pool, nomad, authHandler)
+	contextHandler := handlers.ContextHandler(pool, nomad, nil, authHandler)
 
 	req := httptest.NewRequest("DELETE", "http://example.com/v1/tsg/templates/328937419456806913", nil)
 	recorder := httptest.NewRecorder()
@@ -233,9 +237,9 @@ func TestAcc_DeleteNonExistantTemplate(t *testing.T) {
 		AuthURL:    authURL,
 	}
 
-	router := router.WithRoutes(server.RoutingTable)
+	router := router.WithRoutes(server.RoutingTable, 0)
 	authHandler := handlers.AuthHandler(pool, authConfig, router)
-	contextHandler := handlers.ContextHandler(pool, nomad, authHandler)
+	contextHandler := handlers.ContextHandler(pool, nomad, nil, authHandler)
 
 	req := httptest.NewRequest("DELETE", "http://example.com/v1/tsg/templates/1234", nil)
 	recorder := httptest.NewRecorder()
@@ -269,9 +273,9 @@ func TestAcc_CreateTemplate(t *testing.T) {
 		AuthURL:    authURL,
 	}
 
-	router := router.WithRoutes(server.RoutingTable)
+	router := router.WithRoutes(server.RoutingTable, 0)
 	authHandler := handlers.AuthHandler(pool, authConfig, router)
-	contextHandler := handlers.ContextHandler(pool, nomad, authHandler)
+	contextHandler := handlers.ContextHandler(pool, nomad, nil, authHandler)
 
 	testBody := `{
 	"template_name": "test-template-7",
@@ -300,3 +304,61 @@ func TestAcc_CreateTemplate(t *testing.T) {
 
 	assert.Equal(t, http.StatusCreated, resp.StatusCode)
 }
+
+func TestAcc_UpdateTemplate(t *testing.T) {
+	if os.Getenv("TRITON_TEST") == "" {
+		t.Skip("Acceptance tests skipped unless env 'TRITON_TEST=1' set")
+		return
+	}
+
+	pool, err := initDB()
+	if err != nil {
+		t.Error(err)
+	}
+
+	nomad, err := testutils.NewNomadClient()
+	if err != nil {
+		t.Error(err)
+	}
+
+	authConfig := auth.Config{
+		Datacenter: datacenter,
+		TritonURL:  tritonURL,
+		AuthURL:    authURL,
+	}
+
+	router := router.WithRoutes(server.RoutingTable, 0)
+	authHandler := handlers.AuthHandler(pool, authConfig, router)
+	contextHandler := handlers.ContextHandler(pool, nomad, nil, authHandler)
+
+	testBody := `{
+	"package": "test-package",
+	"image_id": "49b22aec-0c8a-11e6-8807-a3eb4db576ba",
+	"networks": [
+		"f7ed95d3-faaf-43ef-9346-15644403b963"
+	],
+	"userdata": "bash script here"
+}`
+
+	req := httptest.NewRequest("PUT", "http://example.com/v1/tsg/templates/319209784155176962", bytes.NewReader([]byte(testBody)))
+	recorder := httptest.NewRecorder()
+	contextHandler.ServeHTTP(recorder, req)
+
+	resp := recorder.Result()
+	_, _ = ioutil.ReadAll(resp.Body)
+
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+
+	// Only the latest version can be revised.
+	req = httptest.NewRequest("PUT", "http://example.com/v1/tsg/templates/319209784155176962", bytes.NewReader([]byte(testBody)))
+	recorder = httptest.NewRecorder()
+	contextHandler.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusConflict, recorder.Result().StatusCode)
+
+	req = httptest.NewRequest("GET", "http://example.com/v1/tsg/templates/319209784155176962/versions", nil)
+	recorder = httptest.NewRecorder()
+	contextHandler.ServeHTTP(recorder, req)
+
+	assert.Equal(t, http.StatusOK, recorder.Result().StatusCode)
+}
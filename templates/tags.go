@@ -0,0 +1,41 @@
+//  Copyright (c) 2018, Joyent, Inc. All rights reserved.
+//  This Source Code Form is subject to the terms of the Mozilla Public
+//  License, v. 2.0. If a copy of the MPL was not distributed with this
+//  file, You can obtain one at http://mozilla.org/MPL/2.0/.
+
+package templates_v1
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/joyent/triton-service-groups/config"
+)
+
+// ErrMissingTags is returned when a template doesn't set every tag required
+// on provisioned instances by the server's tagging policy.
+type ErrMissingTags struct {
+	Keys []string
+}
+
+func (e *ErrMissingTags) Error() string {
+	return fmt.Sprintf("template is missing required tags: %s", strings.Join(e.Keys, ", "))
+}
+
+// CheckRequiredTags returns an ErrMissingTags naming every required tag which
+// t doesn't set. Tags set to an empty value don't count.
+func CheckRequiredTags(t *InstanceTemplate) error {
+	var missing []string
+	for _, key := range config.GetRequiredTags() {
+		if strings.TrimSpace(t.Tags[key]) == "" {
+			missing = append(missing, key)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	sort.Strings(missing)
+	return &ErrMissingTags{Keys: missing}
+}
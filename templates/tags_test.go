@@ -0,0 +1,29 @@
+package templates_v1
+
+import (
+	"testing"
+
+	"github.com/joyent/triton-service-groups/config"
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckRequiredTags(t *testing.T) {
+	defer viper.Reset()
+
+	template := &InstanceTemplate{
+		Tags: map[string]string{"owner": " ", "role": "web"},
+	}
+	assert.NoError(t, CheckRequiredTags(template), "no tags are required by default")
+
+	viper.Set(config.KeyTagsRequired, []string{"owner", "cost-center"})
+	err := CheckRequiredTags(template)
+	assert.EqualError(t, err, "template is missing required tags: cost-center, owner")
+	assert.Equal(t, &ErrMissingTags{Keys: []string{"cost-center", "owner"}}, err)
+
+	template.Tags["owner"] = "web-team"
+	template.Tags["cost-center"] = "1234"
+	assert.NoError(t, CheckRequiredTags(template))
+
+	assert.Error(t, CheckRequiredTags(&InstanceTemplate{}))
+}
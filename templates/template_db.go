@@ -8,6 +8,7 @@ package templates_v1
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"strings"
 
 	"github.com/jackc/pgx"
@@ -41,6 +42,8 @@ SELECT EXISTS
 	return exists, nil
 }
 
+// CheckTemplateAllocationByID returns whether any group uses a revision of
+// the template which the revision with the given ID belongs to.
 func CheckTemplateAllocationByID(ctx context.Context, templateID, accountID string) (bool, error) {
 	db, ok := handlers.GetDBPool(ctx)
 	if !ok {
@@ -56,7 +59,11 @@ SELECT EXISTS
         tsg_groups AS g
    WHERE (t.id = g.template_id
           AND t.account_id = g.account_id)
-     AND (t.id = $1
+     AND (t.template_name =
+            (SELECT template_name
+             FROM tsg_templates
+             WHERE id = $1
+               AND account_id = $2)
           AND t.account_id = $2)
      AND g.archived IS FALSE);`
 
@@ -68,61 +75,92 @@ SELECT EXISTS
 	return allocated, nil
 }
 
-func FindTemplateByName(ctx context.Context, key string, accountID string) (*InstanceTemplate, bool) {
-	db, ok := handlers.GetDBPool(ctx)
-	if !ok {
-		return nil, false
-	}
-
-	sqlStatement := `
-SELECT id, template_name, package, image_id, firewall_enabled, networks, COALESCE(metadata,''), userdata, COALESCE(tags,''), created_at
-FROM tsg_templates
-WHERE template_name = $1 and account_id = $2
-AND archived = false
-`
+// templateColumns are the columns of a template read by scanTemplate, in
+// order.
+const templateColumns = `id, template_name, package, image_id, firewall_enabled, COALESCE(firewall_rules,''), networks, COALESCE(metadata,''), userdata, COALESCE(tags,''), task_cpu, task_memory_mb, version, superseded, created_at`
 
+// scanTemplate reads a template selected with templateColumns from row.
+func scanTemplate(row interface {
+	Scan(dest ...interface{}) error
+}) (*InstanceTemplate, error) {
 	var (
 		template     InstanceTemplate
 		metaDataJson string
 		tagsJson     string
+		rulesJson    string
 		networksList string
 		templateID   pgtype.UUID
 		createdAt    pgtype.Timestamp
 	)
 
-	err := db.QueryRowEx(ctx, sqlStatement, nil, key, accountID).Scan(
+	err := row.Scan(
 		&templateID,
 		&template.TemplateName,
 		&template.Package,
 		&template.ImageID,
 		&template.FirewallEnabled,
+		&rulesJson,
 		&networksList,
 		&metaDataJson,
 		&template.UserData,
 		&tagsJson,
+		&template.TaskCPU,
+		&template.TaskMemoryMB,
+		&template.Version,
+		&template.Superseded,
 		&createdAt,
 	)
-	switch err {
-	case nil:
-		template.ID = convert.BytesToUUID(templateID.Bytes)
+	if err != nil {
+		return nil, err
+	}
 
-		metaData, err := convertFromJson(metaDataJson)
-		if err != nil {
-			panic(err)
-		}
-		template.MetaData = metaData
+	template.ID = convert.BytesToUUID(templateID.Bytes)
 
-		tags, err := convertFromJson(tagsJson)
-		if err != nil {
-			panic(err)
-		}
-		template.Tags = tags
+	metaData, err := convertFromJson(metaDataJson)
+	if err != nil {
+		panic(err)
+	}
+	template.MetaData = metaData
 
-		template.Networks = strings.Split(networksList, ",")
+	tags, err := convertFromJson(tagsJson)
+	if err != nil {
+		panic(err)
+	}
+	template.Tags = tags
+
+	rules, err := convertRulesFromJson(rulesJson)
+	if err != nil {
+		panic(err)
+	}
+	template.FirewallRules = rules
+
+	template.Networks = strings.Split(networksList, ",")
+
+	template.CreatedAt = createdAt.Time
+
+	return &template, nil
+}
+
+// FindTemplateByName finds the latest revision of the named template.
+func FindTemplateByName(ctx context.Context, key string, accountID string) (*InstanceTemplate, bool) {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return nil, false
+	}
 
-		template.CreatedAt = createdAt.Time
+	sqlStatement := `
+SELECT ` + templateColumns + `
+FROM tsg_templates
+WHERE template_name = $1 and account_id = $2
+AND archived = false AND superseded = false
+`
 
-		return &template, true
+	template, err := scanTemplate(db.QueryRowEx(ctx, sqlStatement, nil, key, accountID))
+	switch err {
+	case nil:
+		WarnDeprecated(ctx, template)
+
+		return template, true
 	case pgx.ErrNoRows:
 		return nil, false
 	default:
@@ -130,7 +168,20 @@ AND archived = false
 	}
 }
 
+// FindTemplateByID finds a revision of a template by its ID, whether or not
+// it's the latest, so a group renders the revision it's pinned to.
 func FindTemplateByID(ctx context.Context, key string, accountID string) (*InstanceTemplate, bool) {
+	return findTemplateByID(ctx, key, accountID, false)
+}
+
+// FindTemplateRevisionByID finds a template by ID even if it has since been
+// archived. Templates are immutable so this returns the template exactly as it
+// was when in use.
+func FindTemplateRevisionByID(ctx context.Context, key string, accountID string) (*InstanceTemplate, bool) {
+	return findTemplateByID(ctx, key, accountID, true)
+}
+
+func findTemplateByID(ctx context.Context, key string, accountID string, includeArchived bool) (*InstanceTemplate, bool) {
 	db, ok := handlers.GetDBPool(ctx)
 	if !ok {
 
@@ -138,54 +189,18 @@ func FindTemplateByID(ctx context.Context, key string, accountID string) (*Insta
 	}
 
 	sqlStatement := `
-SELECT id, template_name, package, image_id, firewall_enabled, networks, COALESCE(metadata,''), userdata, COALESCE(tags,''), created_at
+SELECT ` + templateColumns + `
 FROM tsg_templates
 WHERE id = $1 and account_id = $2
-AND archived = false
+AND (archived = false OR $3)
 `
 
-	var (
-		template     InstanceTemplate
-		metaDataJson string
-		tagsJson     string
-		networksList string
-		templateID   pgtype.UUID
-		createdAt    pgtype.Timestamp
-	)
-
-	err := db.QueryRowEx(ctx, sqlStatement, nil, key, accountID).Scan(
-		&templateID,
-		&template.TemplateName,
-		&template.Package,
-		&template.ImageID,
-		&template.FirewallEnabled,
-		&networksList,
-		&metaDataJson,
-		&template.UserData,
-		&tagsJson,
-		&createdAt,
-	)
+	template, err := scanTemplate(db.QueryRowEx(ctx, sqlStatement, nil, key, accountID, includeArchived))
 	switch err {
 	case nil:
-		template.ID = convert.BytesToUUID(templateID.Bytes)
-
-		metaData, err := convertFromJson(metaDataJson)
-		if err != nil {
-			panic(err)
-		}
-		template.MetaData = metaData
+		WarnDeprecated(ctx, template)
 
-		tags, err := convertFromJson(tagsJson)
-		if err != nil {
-			panic(err)
-		}
-		template.Tags = tags
-
-		template.Networks = strings.Split(networksList, ",")
-
-		template.CreatedAt = createdAt.Time
-
-		return &template, true
+		return template, true
 	case pgx.ErrNoRows:
 		return nil, false
 	default:
@@ -193,82 +208,174 @@ AND archived = false
 	}
 }
 
-func FindTemplates(ctx context.Context, accountID string) ([]*InstanceTemplate, error) {
+// FindTemplateVersions returns every revision of the template which the
+// revision with the given ID belongs to, oldest first.
+func FindTemplateVersions(ctx context.Context, key string, accountID string) ([]*InstanceTemplate, error) {
 	db, ok := handlers.GetDBPool(ctx)
 	if !ok {
 		return nil, handlers.ErrNoConnPool
 	}
 
-	sqlStatement := `SELECT id, template_name, package, image_id, firewall_enabled, networks, COALESCE(metadata,''), userdata, COALESCE(tags, ''), created_at
+	sqlStatement := `
+SELECT ` + templateColumns + `
 FROM tsg_templates
-WHERE account_id = $1
-AND archived = false;`
-
-	var (
-		templates    []*InstanceTemplate
-		metaDataJson string
-		tagsJson     string
-		networksList string
-		templateID   pgtype.UUID
-		createdAt    pgtype.Timestamp
-	)
+WHERE template_name = (SELECT template_name FROM tsg_templates WHERE id = $1 AND account_id = $2)
+AND account_id = $2
+AND archived = false
+ORDER BY version ASC
+`
 
-	rows, err := db.QueryEx(ctx, sqlStatement, nil, accountID)
+	rows, err := db.QueryEx(ctx, sqlStatement, nil, key, accountID)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
+
+	var versions []*InstanceTemplate
 	for rows.Next() {
-		var template InstanceTemplate
-		err := rows.Scan(
-			&templateID,
-			&template.TemplateName,
-			&template.Package,
-			&template.ImageID,
-			&template.FirewallEnabled,
-			&networksList,
-			&metaDataJson,
-			&template.UserData,
-			&tagsJson,
-			&createdAt,
-		)
+		template, err := scanTemplate(rows)
 		if err != nil {
 			return nil, err
 		}
+		versions = append(versions, template)
+	}
+
+	return versions, rows.Err()
+}
+
+func FindTemplates(ctx context.Context, accountID string) ([]*InstanceTemplate, error) {
+	var templates []*InstanceTemplate
+
+	err := EachTemplate(ctx, accountID, func(template *InstanceTemplate) error {
+		templates = append(templates, template)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 
-		template.ID = convert.BytesToUUID(templateID.Bytes)
+	return templates, nil
+}
+
+// FindTemplateRevisions returns every revision of every active template of
+// the account, including those superseded by a later revision which groups
+// may still be pinned to. The revisions of each template are oldest first.
+func FindTemplateRevisions(ctx context.Context, accountID string) ([]*InstanceTemplate, error) {
+	var templates []*InstanceTemplate
+
+	err := eachTemplate(ctx, accountID, true, func(template *InstanceTemplate) error {
+		templates = append(templates, template)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return templates, nil
+}
+
+// EachTemplate calls fn for the latest revision of every active template of
+// the account as it's read from the database, without holding all of them in
+// memory. Iteration stops at the first error returned by fn.
+func EachTemplate(ctx context.Context, accountID string, fn func(template *InstanceTemplate) error) error {
+	return eachTemplate(ctx, accountID, false, fn)
+}
 
-		metaData, err := convertFromJson(metaDataJson)
+// eachTemplate is EachTemplate for every revision of the templates, ordered
+// by name and version, if superseded is set.
+func eachTemplate(ctx context.Context, accountID string, superseded bool, fn func(template *InstanceTemplate) error) error {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return handlers.ErrNoConnPool
+	}
+
+	sqlStatement := `SELECT ` + templateColumns + `
+FROM tsg_templates
+WHERE account_id = $1
+AND archived = false AND superseded = false;`
+	if superseded {
+		sqlStatement = `SELECT ` + templateColumns + `
+FROM tsg_templates
+WHERE account_id = $1
+AND archived = false
+ORDER BY template_name ASC, version ASC;`
+	}
+
+	rows, err := db.QueryEx(ctx, sqlStatement, nil, accountID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		template, err := scanTemplate(rows)
 		if err != nil {
-			panic(err)
+			return err
 		}
-		template.MetaData = metaData
 
-		tags, err := convertFromJson(tagsJson)
-		if err != nil {
-			panic(err)
+		if err := fn(template); err != nil {
+			return err
 		}
-		template.Tags = tags
+	}
 
-		template.Networks = strings.Split(networksList, ",")
+	return rows.Err()
+}
 
-		template.CreatedAt = createdAt.Time
+// ErrTemplateSuperseded is returned when revising a template from a revision
+// which is no longer its latest.
+var ErrTemplateSuperseded = errors.New("template has been revised since, only its latest version can be revised")
 
-		templates = append(templates, &template)
+// SaveTemplate saves a new template, as the first revision of it.
+func SaveTemplate(ctx context.Context, accountID string, template *InstanceTemplate) error {
+	db, ok := handlers.GetDBPool(ctx)
+	if !ok {
+		return handlers.ErrNoConnPool
 	}
 
-	return templates, nil
+	return insertTemplate(ctx, db, accountID, template, 1)
 }
 
-func SaveTemplate(ctx context.Context, accountID string, template *InstanceTemplate) error {
+// ReviseTemplate saves next as a new revision of the template whose latest
+// revision is current, which is kept as it was for the groups pinned to it.
+// ErrTemplateSuperseded is returned if current isn't the latest revision.
+func ReviseTemplate(ctx context.Context, accountID string, current, next *InstanceTemplate) error {
 	db, ok := handlers.GetDBPool(ctx)
 	if !ok {
 		return handlers.ErrNoConnPool
 	}
 
+	tx, err := db.BeginEx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // nolint: errcheck
+
+	sqlStatement := `UPDATE tsg_templates
+SET superseded = true
+WHERE id = $1 and account_id = $2
+AND archived = false AND superseded = false`
+
+	tag, err := tx.ExecEx(ctx, sqlStatement, nil, current.ID, accountID)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() != 1 {
+		return ErrTemplateSuperseded
+	}
+
+	next.TemplateName = current.TemplateName
+	if err := insertTemplate(ctx, tx, accountID, next, current.Version+1); err != nil {
+		return err
+	}
+
+	return tx.CommitEx(ctx)
+}
+
+func insertTemplate(ctx context.Context, db interface {
+	ExecEx(ctx context.Context, sql string, options *pgx.QueryExOptions, arguments ...interface{}) (pgx.CommandTag, error)
+}, accountID string, template *InstanceTemplate, version int) error {
 	sqlStatement := `
-INSERT INTO tsg_templates (template_name, package, image_id, account_id, firewall_enabled, networks, metadata, userdata, tags, created_at)
-VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+INSERT INTO tsg_templates (template_name, package, image_id, account_id, firewall_enabled, firewall_rules, networks, metadata, userdata, tags, task_cpu, task_memory_mb, version, created_at)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, NOW())
 `
 
 	metaDataJson, err := convertToJson(template.MetaData)
@@ -281,6 +388,11 @@ VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
 		return err
 	}
 
+	rulesJson, err := convertRulesToJson(template.FirewallRules)
+	if err != nil {
+		return err
+	}
+
 	networksList := strings.Join(template.Networks, ",")
 
 	_, err = db.ExecEx(ctx, sqlStatement, nil,
@@ -289,10 +401,14 @@ VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
 		template.ImageID,
 		accountID,
 		template.FirewallEnabled,
+		rulesJson,
 		networksList,
 		metaDataJson,
 		template.UserData,
 		tagsJson,
+		template.TaskCPU,
+		template.TaskMemoryMB,
+		version,
 	)
 	if err != nil {
 		return err
@@ -301,6 +417,8 @@ VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
 	return nil
 }
 
+// RemoveTemplate archives every revision of the template which the revision
+// with the given ID belongs to.
 func RemoveTemplate(ctx context.Context, identifier string, accountID string) error {
 	db, ok := handlers.GetDBPool(ctx)
 	if !ok {
@@ -309,7 +427,8 @@ func RemoveTemplate(ctx context.Context, identifier string, accountID string) er
 
 	sqlStatement := `UPDATE triton.tsg_templates
 SET archived = true
-WHERE id = $1 and account_id = $2`
+WHERE template_name = (SELECT template_name FROM triton.tsg_templates WHERE id = $1 and account_id = $2)
+and account_id = $2`
 
 	_, err := db.ExecEx(ctx, sqlStatement, nil, identifier, accountID)
 	if err != nil {
@@ -346,3 +465,30 @@ func convertFromJson(data string) (map[string]string, error) {
 
 	return result, nil
 }
+
+func convertRulesToJson(rules []string) (string, error) {
+	if len(rules) == 0 {
+		return "", nil
+	}
+
+	json, err := json.Marshal(rules)
+	if err != nil {
+		return "", err
+	}
+
+	return string(json), nil
+}
+
+func convertRulesFromJson(data string) ([]string, error) {
+	if data == "" {
+		return nil, nil
+	}
+
+	var rules []string
+	err := json.Unmarshal([]byte(data), &rules)
+	if err != nil {
+		return nil, err
+	}
+
+	return rules, nil
+}
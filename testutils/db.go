@@ -40,6 +40,11 @@ func (db *TestDB) Clear(t *testing.T) {
 		t.Fatalf("conn.Exec failed: %v", err)
 	}
 
+	_, err = db.Conn.Exec(`DELETE FROM tsg_group_tombstones`)
+	if err != nil {
+		t.Fatalf("conn.Exec failed: %v", err)
+	}
+
 	_, err2 := db.Conn.Exec(`DELETE FROM tsg_templates`)
 	if err2 != nil {
 		t.Fatalf("conn.Exec failed: %v", err)
@@ -0,0 +1,53 @@
+package testutils
+
+import (
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+var matchSnakeCase = regexp.MustCompile(`^[a-z][a-z0-9]*(_[a-z0-9]+)*$`)
+
+// AssertSnakeCaseJSON asserts that every exported field of each given API
+// type, and of any struct types nested within them, carries an explicit
+// snake_case json tag.
+func AssertSnakeCaseJSON(t *testing.T, values ...interface{}) {
+	seen := map[reflect.Type]bool{}
+	for _, v := range values {
+		assertSnakeCaseType(t, reflect.TypeOf(v), seen)
+	}
+}
+
+func assertSnakeCaseType(t *testing.T, typ reflect.Type, seen map[reflect.Type]bool) {
+	for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice ||
+		typ.Kind() == reflect.Array || typ.Kind() == reflect.Map {
+		typ = typ.Elem()
+	}
+
+	if typ.Kind() != reflect.Struct || typ == reflect.TypeOf(time.Time{}) || seen[typ] {
+		return
+	}
+	seen[typ] = true
+
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" {
+			continue
+		}
+
+		tag, ok := field.Tag.Lookup("json")
+		name := strings.Split(tag, ",")[0]
+		switch {
+		case !ok:
+			t.Errorf("%s.%s is missing a json tag", typ.Name(), field.Name)
+		case name == "-":
+			continue
+		case !matchSnakeCase.MatchString(name):
+			t.Errorf("%s.%s has json tag %q which is not snake_case", typ.Name(), field.Name, name)
+		}
+
+		assertSnakeCaseType(t, field.Type, seen)
+	}
+}
@@ -1,14 +1,76 @@
 package testutils
 
 import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
 	nomad "github.com/hashicorp/nomad/api"
 )
 
 // NewNomadClient creates and returns a new Nomad client used for testing.
-//
-// TODO(justinwr): configure an HTTP client we can use to stub out requests to
-// Nomad for unit testing
 func NewNomadClient() (*nomad.Client, error) {
 	nomadCfg := nomad.DefaultConfig()
 	return nomad.NewClient(nomadCfg)
 }
+
+// FakeNomad is a stubbed out Nomad HTTP API used for unit testing. Tests
+// register handlers for the API paths they expect to be called and use Client
+// as they would a real Nomad client.
+type FakeNomad struct {
+	Client *nomad.Client
+
+	mux    *http.ServeMux
+	server *httptest.Server
+}
+
+// NewFakeNomad starts a new stubbed Nomad API server and constructs a client
+// which talks to it.
+func NewFakeNomad(t *testing.T) *FakeNomad {
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+
+	nomadCfg := nomad.DefaultConfig()
+	nomadCfg.Address = server.URL
+
+	client, err := nomad.NewClient(nomadCfg)
+	if err != nil {
+		server.Close()
+		t.Fatalf("failed to create nomad client: %v", err)
+	}
+
+	return &FakeNomad{
+		Client: client,
+		mux:    mux,
+		server: server,
+	}
+}
+
+// HandleFunc registers a handler for the given API path pattern.
+func (f *FakeNomad) HandleFunc(pattern string, handler http.HandlerFunc) {
+	f.mux.HandleFunc(pattern, handler)
+}
+
+// HandleJSON registers a handler that always responds with the JSON encoding
+// of resp.
+func (f *FakeNomad) HandleJSON(pattern string, resp interface{}) {
+	f.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+		WriteJSON(w, resp)
+	})
+}
+
+// Close shuts down the stubbed Nomad API server.
+func (f *FakeNomad) Close() {
+	f.server.Close()
+}
+
+// WriteJSON writes the JSON encoding of resp to w along with the headers the
+// Nomad client expects on query responses.
+func WriteJSON(w http.ResponseWriter, resp interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Nomad-Index", "1")
+	w.Header().Set("X-Nomad-KnownLeader", "true")
+	w.Header().Set("X-Nomad-LastContact", "0")
+	json.NewEncoder(w).Encode(resp) // nolint: errcheck
+}
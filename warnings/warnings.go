@@ -0,0 +1,137 @@
+// Package warnings collects non-fatal warnings raised while handling a request
+// and returns them to the client as standard Warning headers, leaving the
+// response body untouched.
+package warnings
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// CodeMiscellaneous is the RFC 7234 warn-code for a persistent warning which
+// doesn't fit any other code.
+const CodeMiscellaneous = 299
+
+// agent names TSG as the source of a warning.
+const agent = "tsg"
+
+// Warning is a single warning returned with a response.
+type Warning struct {
+	Code int
+	Text string
+}
+
+// String formats the warning as the value of a Warning header.
+func (w Warning) String() string {
+	return fmt.Sprintf("%d %s %s", w.Code, agent, strconv.Quote(w.Text))
+}
+
+type contextKey struct{}
+
+type collector struct {
+	mu       sync.Mutex
+	warnings []Warning
+}
+
+// WithCollector returns a copy of ctx which collects warnings.
+func WithCollector(ctx context.Context) context.Context {
+	return context.WithValue(ctx, contextKey{}, &collector{})
+}
+
+// Add records a warning for the request of ctx. Identical warnings are
+// only recorded once, and warnings raised outside of a request are dropped.
+func Add(ctx context.Context, w Warning) {
+	c, ok := ctx.Value(contextKey{}).(*collector)
+	if !ok {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, existing := range c.warnings {
+		if existing == w {
+			return
+		}
+	}
+	c.warnings = append(c.warnings, w)
+}
+
+// Get returns the warnings recorded for the request of ctx.
+func Get(ctx context.Context) []Warning {
+	c, ok := ctx.Value(contextKey{}).(*collector)
+	if !ok {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return append([]Warning(nil), c.warnings...)
+}
+
+// Handler collects the warnings raised by h and writes them as Warning
+// headers ahead of its response.
+func Handler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithCollector(r.Context())
+		h.ServeHTTP(&responseWriter{ResponseWriter: w, ctx: ctx}, r.WithContext(ctx))
+	})
+}
+
+type responseWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	wroteHeader bool
+}
+
+func (w *responseWriter) WriteHeader(statusCode int) {
+	if !w.wroteHeader {
+		w.wroteHeader = true
+		for _, warning := range Get(w.ctx) {
+			w.Header().Add("Warning", warning.String())
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush passes flushes through so that streamed responses aren't buffered.
+func (w *responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Parse reads a Warning header value written by this package.
+func Parse(value string) (Warning, error) {
+	parts := strings.SplitN(value, " ", 3)
+	if len(parts) != 3 || parts[1] != agent {
+		return Warning{}, fmt.Errorf("invalid warning: %q", value)
+	}
+
+	code, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return Warning{}, fmt.Errorf("invalid warning code: %q", parts[0])
+	}
+
+	text, err := strconv.Unquote(parts[2])
+	if err != nil {
+		return Warning{}, fmt.Errorf("invalid warning text: %q", parts[2])
+	}
+
+	return Warning{Code: code, Text: text}, nil
+}
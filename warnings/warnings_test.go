@@ -0,0 +1,40 @@
+package warnings
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	h := Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Add(r.Context(), Warning{Code: CodeMiscellaneous, Text: `field "a" is deprecated`})
+		Add(r.Context(), Warning{Code: CodeMiscellaneous, Text: `field "a" is deprecated`})
+		Add(r.Context(), Warning{Code: CodeMiscellaneous, Text: "field b is deprecated"})
+		w.Write([]byte("{}"))
+	}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/tsg/templates", nil))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "{}", rec.Body.String())
+
+	values := rec.Header()["Warning"]
+	require.Len(t, values, 2)
+	assert.Equal(t, `299 tsg "field \"a\" is deprecated"`, values[0])
+
+	warning, err := Parse(values[1])
+	require.NoError(t, err)
+	assert.Equal(t, Warning{Code: CodeMiscellaneous, Text: "field b is deprecated"}, warning)
+}
+
+func TestAddWithoutCollector(t *testing.T) {
+	ctx := context.Background()
+	Add(ctx, Warning{Code: CodeMiscellaneous, Text: "dropped"})
+	assert.Empty(t, Get(ctx))
+}
@@ -0,0 +1,184 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// SignatureHeader carries the HMAC-SHA256 of the request body, keyed with
+	// the webhook secret, as "sha256=<hex>".
+	SignatureHeader = "X-TSG-Signature"
+	EventHeader     = "X-TSG-Event"
+	DeliveryHeader  = "X-TSG-Delivery"
+
+	signaturePrefix = "sha256="
+	defaultTimeout  = 10 * time.Second
+
+	// CloudEventsSpecVersion is the version of the CloudEvents spec
+	// deliveries conform to.
+	CloudEventsSpecVersion = "1.0"
+	// CloudEventsTypePrefix is prepended to event names to form the
+	// CloudEvents type.
+	CloudEventsTypePrefix = "com.joyent.tsg."
+
+	jsonContentType        = "application/json; charset=utf-8"
+	cloudEventsContentType = "application/cloudevents+json; charset=utf-8"
+)
+
+// Format is how deliveries are encoded.
+type Format string
+
+const (
+	// FormatRaw delivers the payload itself as the request body.
+	FormatRaw Format = "raw"
+	// FormatCloudEvents wraps the payload in a structured mode CloudEvents
+	// envelope.
+	FormatCloudEvents Format = "cloudevents"
+)
+
+// ParseFormat returns the format named s. An empty name is FormatRaw.
+func ParseFormat(s string) (Format, error) {
+	switch format := Format(strings.ToLower(s)); format {
+	case "", FormatRaw:
+		return FormatRaw, nil
+	case FormatCloudEvents:
+		return FormatCloudEvents, nil
+	default:
+		return "", fmt.Errorf("unsupported webhook format: %q", s)
+	}
+}
+
+// Event is implemented by payloads which know what they're about and when
+// they occurred, filling in the subject and time of CloudEvents deliveries.
+type Event interface {
+	EventSubject() string
+	EventTime() time.Time
+}
+
+// CloudEvent is the structured mode CloudEvents envelope of a delivery.
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	Type            string          `json:"type"`
+	Source          string          `json:"source"`
+	Subject         string          `json:"subject,omitempty"`
+	ID              string          `json:"id"`
+	Time            time.Time       `json:"time"`
+	DataContentType string          `json:"datacontenttype"`
+	Data            json.RawMessage `json:"data"`
+}
+
+// Sender delivers signed events to a single webhook.
+type Sender struct {
+	url    string
+	secret []byte
+	format Format
+	source string
+	client *http.Client
+
+	now func() time.Time
+}
+
+// NewSender returns a sender which signs every delivery to url with secret.
+// Deliveries in FormatCloudEvents identify source as where they came from.
+func NewSender(url, secret string, format Format, source string) *Sender {
+	return &Sender{
+		url:    url,
+		secret: []byte(secret),
+		format: format,
+		source: source,
+		client: &http.Client{Timeout: defaultTimeout},
+		now:    time.Now,
+	}
+}
+
+// Send delivers payload as JSON, identifying it as event. Any response other
+// than a 2xx is treated as a failed delivery.
+func (s *Sender) Send(ctx context.Context, event string, payload interface{}) error {
+	delivery := uuid.New().String()
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	contentType := jsonContentType
+	if s.format == FormatCloudEvents {
+		body, err = s.cloudEvent(event, delivery, payload, body)
+		if err != nil {
+			return err
+		}
+		contentType = cloudEventsContentType
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set(EventHeader, event)
+	req.Header.Set(DeliveryHeader, delivery)
+	req.Header.Set(SignatureHeader, Sign(s.secret, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("unable to deliver webhook: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+
+	return nil
+}
+
+// cloudEvent wraps the encoded payload in a CloudEvents envelope, using the
+// delivery as its ID.
+func (s *Sender) cloudEvent(event, delivery string, payload interface{}, data []byte) ([]byte, error) {
+	envelope := &CloudEvent{
+		SpecVersion:     CloudEventsSpecVersion,
+		Type:            CloudEventsTypePrefix + event,
+		Source:          s.source,
+		ID:              delivery,
+		Time:            s.now().UTC(),
+		DataContentType: "application/json",
+		Data:            data,
+	}
+	if e, ok := payload.(Event); ok {
+		envelope.Subject = e.EventSubject()
+		if at := e.EventTime(); !at.IsZero() {
+			envelope.Time = at.UTC()
+		}
+	}
+
+	return json.Marshal(envelope)
+}
+
+// Sign returns the signature header value of body.
+func Sign(secret, body []byte) string {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(body) // nolint: errcheck
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is a valid signature header value of body.
+// Receivers use it to authenticate deliveries.
+func Verify(secret, body []byte, signature string) bool {
+	if !strings.HasPrefix(signature, signaturePrefix) {
+		return false
+	}
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
@@ -0,0 +1,151 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSend(t *testing.T) {
+	const secret = "s3cret"
+
+	var (
+		event    string
+		delivery string
+		verified bool
+		payload  map[string]string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		event = r.Header.Get(EventHeader)
+		delivery = r.Header.Get(DeliveryHeader)
+		verified = Verify([]byte(secret), body, r.Header.Get(SignatureHeader))
+		require.NoError(t, json.Unmarshal(body, &payload))
+	}))
+	defer server.Close()
+
+	sender := NewSender(server.URL, secret, FormatRaw, "")
+	err := sender.Send(context.Background(), "test.event", map[string]string{"hello": "world"})
+	require.NoError(t, err)
+
+	assert.Equal(t, "test.event", event)
+	assert.NotEmpty(t, delivery)
+	assert.True(t, verified)
+	assert.Equal(t, map[string]string{"hello": "world"}, payload)
+}
+
+// testAlert stands in for a payload which knows its subject and time.
+type testAlert struct {
+	GroupID string    `json:"group_id"`
+	At      time.Time `json:"at"`
+}
+
+func (a *testAlert) EventSubject() string { return "groups/" + a.GroupID }
+func (a *testAlert) EventTime() time.Time { return a.At }
+
+func TestSendCloudEvents(t *testing.T) {
+	const secret = "s3cret"
+
+	var (
+		contentType string
+		delivery    string
+		verified    bool
+		envelope    map[string]json.RawMessage
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		contentType = r.Header.Get("Content-Type")
+		delivery = r.Header.Get(DeliveryHeader)
+		verified = Verify([]byte(secret), body, r.Header.Get(SignatureHeader))
+		require.NoError(t, json.Unmarshal(body, &envelope))
+	}))
+	defer server.Close()
+
+	at := time.Date(2018, 4, 14, 15, 4, 5, 0, time.UTC)
+	sender := NewSender(server.URL, secret, FormatCloudEvents, "/tsg/us-east-1")
+	err := sender.Send(context.Background(), "alert.firing", &testAlert{GroupID: "722d25ed", At: at})
+	require.NoError(t, err)
+
+	assert.Equal(t, "application/cloudevents+json; charset=utf-8", contentType)
+	assert.True(t, verified)
+
+	// Every required context attribute of the envelope is present.
+	for _, attr := range []string{"specversion", "type", "source", "id"} {
+		require.Contains(t, envelope, attr)
+	}
+
+	var event CloudEvent
+	raw, err := json.Marshal(envelope)
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(raw, &event))
+
+	assert.Equal(t, "1.0", event.SpecVersion)
+	assert.Equal(t, "com.joyent.tsg.alert.firing", event.Type)
+	assert.Equal(t, "/tsg/us-east-1", event.Source)
+	assert.Equal(t, "groups/722d25ed", event.Subject)
+	assert.Equal(t, delivery, event.ID)
+	assert.Equal(t, at, event.Time)
+	assert.Equal(t, "application/json", event.DataContentType)
+	assert.JSONEq(t, `{"group_id": "722d25ed", "at": "2018-04-14T15:04:05Z"}`, string(event.Data))
+}
+
+func TestSendCloudEventsWithoutSubject(t *testing.T) {
+	var envelope map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&envelope))
+	}))
+	defer server.Close()
+
+	now := time.Date(2018, 4, 14, 15, 4, 5, 0, time.UTC)
+	sender := NewSender(server.URL, "s3cret", FormatCloudEvents, "/tsg/us-east-1")
+	sender.now = func() time.Time { return now }
+
+	require.NoError(t, sender.Send(context.Background(), "test.event", map[string]string{"hello": "world"}))
+	assert.NotContains(t, envelope, "subject")
+	assert.Equal(t, "2018-04-14T15:04:05Z", envelope["time"])
+	assert.Equal(t, map[string]interface{}{"hello": "world"}, envelope["data"])
+}
+
+func TestParseFormat(t *testing.T) {
+	format, err := ParseFormat("")
+	require.NoError(t, err)
+	assert.Equal(t, FormatRaw, format)
+
+	format, err = ParseFormat("cloudevents")
+	require.NoError(t, err)
+	assert.Equal(t, FormatCloudEvents, format)
+
+	_, err = ParseFormat("xml")
+	assert.EqualError(t, err, `unsupported webhook format: "xml"`)
+}
+
+func TestSendFailure(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	err := NewSender(server.URL, "s3cret", FormatRaw, "").Send(context.Background(), "test.event", nil)
+	assert.EqualError(t, err, "webhook responded with 502 Bad Gateway")
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"hello":"world"}`)
+	signature := Sign([]byte("s3cret"), body)
+
+	assert.True(t, Verify([]byte("s3cret"), body, signature))
+	assert.False(t, Verify([]byte("other"), body, signature))
+	assert.False(t, Verify([]byte("s3cret"), []byte(`{"hello":"there"}`), signature))
+	assert.False(t, Verify([]byte("s3cret"), body, signature[len("sha256="):]))
+}